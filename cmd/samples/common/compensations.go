@@ -0,0 +1,150 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The compensations of a saga undo the steps that completed before a later step failed, see recipes/saga. A workflow
+ * adds the compensation of every step once the step completed, and compensates in reverse order when the saga fails or
+ * is cancelled. The compensations run with a disconnected context, a cancelled workflow still executes them.
+ *
+ * A compensation that fails is retried according to the RetryPolicy of the compensations. This version of the client
+ * has no RetryPolicy in the ActivityOptions, the retries are executed by the workflow. A compensation that still fails
+ * doesn't stop the others, Compensate returns the failed ones for the workflow to report.
+ */
+
+const (
+	defaultCompensationAttempts = 3
+	defaultCompensationInterval = time.Second
+)
+
+type (
+	// RetryPolicy retries a failed activity, with the fields of the activity retry policy of the server.
+	RetryPolicy struct {
+		// InitialInterval is the backoff before the first retry.
+		InitialInterval time.Duration
+		// BackoffCoefficient multiplies the backoff after every retry. Zero means 2.
+		BackoffCoefficient float64
+		// MaximumInterval caps the backoff. Zero means no cap.
+		MaximumInterval time.Duration
+		// MaximumAttempts is the maximum number of attempts including the first one.
+		MaximumAttempts int
+	}
+
+	// Compensations are the compensations of the completed steps of a saga. The zero value retries every compensation
+	// up to 3 times, with a backoff starting at a second.
+	Compensations struct {
+		RetryPolicy   RetryPolicy
+		compensations []compensation
+	}
+
+	compensation struct {
+		activity interface{}
+		args     []interface{}
+	}
+
+	// disconnectedContext has the values of its parent, but is not cancelled with it. This version of the client has
+	// no cadence.NewDisconnectedContext, activities started with a context without Done channel are not cancellable.
+	disconnectedContext struct {
+		cadence.Context
+	}
+)
+
+// NewDisconnectedContext returns a context with the values of the given one that isn't cancelled with it. The cleanup
+// of a cancelled workflow executes its activities with it, they would be cancelled right away otherwise.
+func NewDisconnectedContext(ctx cadence.Context) cadence.Context {
+	return disconnectedContext{ctx}
+}
+
+func (disconnectedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (disconnectedContext) Done() cadence.Channel {
+	return nil
+}
+
+func (disconnectedContext) Err() error {
+	return nil
+}
+
+// Backoff returns the time to wait after the given failed attempt, the first attempt is 0.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	coefficient := p.BackoffCoefficient
+	if coefficient == 0 {
+		coefficient = 2
+	}
+	backoff := time.Duration(float64(p.InitialInterval) * math.Pow(coefficient, float64(attempt)))
+	if p.MaximumInterval > 0 && backoff > p.MaximumInterval {
+		backoff = p.MaximumInterval
+	}
+	return backoff
+}
+
+// AddCompensation adds the activity that compensates the step that just completed, it is executed with the given
+// arguments.
+func (c *Compensations) AddCompensation(activity interface{}, args ...interface{}) {
+	c.compensations = append(c.compensations, compensation{activity: activity, args: args})
+}
+
+// Compensate executes the compensations in reverse order, the last step first, even if the context is cancelled. It
+// returns an error listing the compensations that failed after their retries, nil if all of them succeeded. The
+// compensations are removed, a second call compensates nothing.
+func (c *Compensations) Compensate(ctx cadence.Context) error {
+	ctx = NewDisconnectedContext(ctx)
+	var failed []string
+	for i := len(c.compensations) - 1; i >= 0; i-- {
+		compensation := c.compensations[i]
+		name := activityName(compensation.activity)
+		if err := c.execute(ctx, compensation); err != nil {
+			cadence.GetLogger(ctx).Error("Compensation failed.", zap.String("Compensation", name), zap.Error(err))
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		cadence.GetLogger(ctx).Info("Compensation completed.", zap.String("Compensation", name))
+	}
+	c.compensations = nil
+	if len(failed) > 0 {
+		return fmt.Errorf("%d compensations failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// execute executes the compensation, and retries it according to the policy.
+func (c *Compensations) execute(ctx cadence.Context, compensation compensation) error {
+	policy := c.RetryPolicy
+	if policy.MaximumAttempts == 0 {
+		policy.MaximumAttempts = defaultCompensationAttempts
+	}
+	if policy.InitialInterval == 0 {
+		policy.InitialInterval = defaultCompensationInterval
+	}
+	for attempt := 0; ; attempt++ {
+		err := cadence.ExecuteActivity(ctx, compensation.activity, compensation.args...).Get(ctx, nil)
+		if err == nil || attempt+1 >= policy.MaximumAttempts {
+			return err
+		}
+		backoff := policy.Backoff(attempt)
+		cadence.GetLogger(ctx).Info("Compensation failed, retrying.", zap.String("Compensation",
+			activityName(compensation.activity)), zap.Int("Attempt", attempt), zap.Duration("Backoff", backoff),
+			zap.Error(err))
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// activityName returns the name of the activity function without its package, e.g. refundPaymentActivity.
+func activityName(activity interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(activity).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
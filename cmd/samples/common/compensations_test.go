@@ -0,0 +1,21 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialInterval: time.Second, MaximumInterval: time.Second * 5}
+	for attempt, expected := range []time.Duration{1, 2, 4, 5} {
+		require.Equal(t, expected*time.Second, policy.Backoff(attempt))
+	}
+	policy = RetryPolicy{InitialInterval: time.Second, BackoffCoefficient: 3}
+	require.Equal(t, time.Second*9, policy.Backoff(2))
+}
+
+func TestActivityName(t *testing.T) {
+	require.Equal(t, "loggingTestActivity", activityName(loggingTestActivity))
+}
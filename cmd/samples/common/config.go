@@ -0,0 +1,115 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// configFileEnv overrides the path of the config file, the samples read config/development.yaml by default.
+	configFileEnv = "CADENCE_SAMPLES_CONFIG"
+	// domainEnv and hostEnv override the domain and the host of the config file.
+	domainEnv = "CADENCE_SAMPLES_DOMAIN"
+	hostEnv   = "CADENCE_SAMPLES_HOST"
+
+	defaultHostNameAndPort = "127.0.0.1:7933"
+)
+
+type (
+	// MetricsConfiguration configures the metrics of the samples, they are logged every ReportInterval, or served to
+	// prometheus on PrometheusListenAddress, e.g. :9090.
+	MetricsConfiguration struct {
+		ReportInterval          string `yaml:"reportInterval"`
+		PrometheusListenAddress string `yaml:"prometheusListenAddress"`
+	}
+)
+
+// configPath returns the path of the config file, the given path if it is not empty, or the one set by the
+// environment.
+func configPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if path := os.Getenv(configFileEnv); path != "" {
+		return path
+	}
+	return configFile
+}
+
+// LoadConfiguration reads the config of the samples from the given file, an empty path means the file set by
+// CADENCE_SAMPLES_CONFIG, or config/development.yaml. The domain and the host can be overridden by
+// CADENCE_SAMPLES_DOMAIN and CADENCE_SAMPLES_HOST, the TLS material by CADENCE_SAMPLES_TLS_CA, CADENCE_SAMPLES_TLS_CERT
+// and CADENCE_SAMPLES_TLS_KEY, the encryption keys by CADENCE_SAMPLES_ENCRYPTION_KEYS and
+// CADENCE_SAMPLES_ENCRYPTION_KEY_ID.
+func LoadConfiguration(path string) (Configuration, error) {
+	var config Configuration
+	path = configPath(path)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, fmt.Errorf("config file %s not found, run the sample from the root of the repository or set %s",
+			path, configFileEnv)
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	if domain := os.Getenv(domainEnv); domain != "" {
+		config.DomainName = domain
+	}
+	if host := os.Getenv(hostEnv); host != "" {
+		config.HostNameAndPort = host
+	}
+	config.TLS = tlsFromEnv(config.TLS)
+	if config.Encryption, err = encryptionFromEnv(config.Encryption); err != nil {
+		return config, err
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = cadenceFrontendService
+	}
+	if config.HostNameAndPort == "" {
+		config.HostNameAndPort = defaultHostNameAndPort
+	}
+	if config.DomainName == "" {
+		return config, fmt.Errorf("config file %s has no domain, set domain or %s", path, domainEnv)
+	}
+	if _, err := config.metricsInterval(); err != nil {
+		return config, fmt.Errorf("config file %s: %v", path, err)
+	}
+	if err := config.Worker.validate(); err != nil {
+		return config, fmt.Errorf("config file %s: %v", path, err)
+	}
+	if _, err := config.Encryption.keyring(); err != nil {
+		return config, fmt.Errorf("config file %s: %v", path, err)
+	}
+	if _, err := config.Logging.zapConfig(); err != nil {
+		return config, fmt.Errorf("config file %s: %v", path, err)
+	}
+	if config.Metrics != nil && config.Metrics.ReportInterval != "" && config.Metrics.PrometheusListenAddress != "" {
+		return config, fmt.Errorf("config file %s: metrics reportInterval and prometheusListenAddress are exclusive",
+			path)
+	}
+	return config, nil
+}
+
+// metricsInterval returns the report interval of the metrics, it is 0 if the metrics are not configured.
+func (c Configuration) metricsInterval() (time.Duration, error) {
+	if c.Metrics == nil || c.Metrics.ReportInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(c.Metrics.ReportInterval)
+	if err != nil {
+		return 0, fmt.Errorf("metrics reportInterval %q is not a duration, e.g. 10s", c.Metrics.ReportInterval)
+	}
+	if interval <= 0 {
+		return 0, errors.New("metrics reportInterval must be positive")
+	}
+	return interval, nil
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file with the given content to a temporary directory, and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfiguration(t *testing.T) {
+	path := writeConfig(t, "domain: \"samples-domain\"\nservice: \"frontend\"\nhost: \"cadence:7933\"\n"+
+		"metrics:\n  reportInterval: \"10s\"\n")
+	config, err := LoadConfiguration(path)
+	require.NoError(t, err)
+	require.Equal(t, Configuration{DomainName: "samples-domain", ServiceName: "frontend", HostNameAndPort: "cadence:7933",
+		Metrics: &MetricsConfiguration{ReportInterval: "10s"}}, config)
+	interval, err := config.metricsInterval()
+	require.NoError(t, err)
+	require.Equal(t, time.Second*10, interval)
+}
+
+func TestLoadConfiguration_Defaults(t *testing.T) {
+	config, err := LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, Configuration{DomainName: "samples-domain", ServiceName: "cadence-frontend",
+		HostNameAndPort: "127.0.0.1:7933"}, config)
+	interval, err := config.metricsInterval()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), interval)
+}
+
+func TestLoadConfiguration_EnvOverrides(t *testing.T) {
+	path := writeConfig(t, "domain: \"samples-domain\"\nhost: \"127.0.0.1:7933\"\n")
+	t.Setenv(configFileEnv, path)
+	t.Setenv(domainEnv, "staging-domain")
+	t.Setenv(hostEnv, "cadence.staging:7933")
+	config, err := LoadConfiguration("")
+	require.NoError(t, err)
+	require.Equal(t, "staging-domain", config.DomainName)
+	require.Equal(t, "cadence.staging:7933", config.HostNameAndPort)
+
+	// a path given explicitly wins over the environment.
+	_, err = LoadConfiguration(filepath.Join(filepath.Dir(path), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestLoadConfiguration_Errors(t *testing.T) {
+	_, err := LoadConfiguration(filepath.Join(os.TempDir(), "missing", "config.yaml"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+	require.Contains(t, err.Error(), configFileEnv)
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: [samples-domain\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to parse")
+
+	_, err = LoadConfiguration(writeConfig(t, "host: \"127.0.0.1:7933\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no domain")
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nmetrics:\n  reportInterval: \"often\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not a duration")
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nmetrics:\n  reportInterval: \"10s\"\n"+
+		"  prometheusListenAddress: \":9090\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exclusive")
+}
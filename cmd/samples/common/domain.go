@@ -0,0 +1,74 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+	"go.uber.org/zap"
+)
+
+const (
+	// domainRegistrationWait is how long a registered domain may take to be found, registration is eventually
+	// consistent on some setups.
+	domainRegistrationWait = 2 * time.Second
+	domainPollInterval     = 200 * time.Millisecond
+)
+
+// The flags are shared by all the samples, they're parsed with the flags of the sample.
+var (
+	registerDomain = flag.Bool("register-domain", false,
+		"Register the domain of the config file if it doesn't exist.")
+	domainRetentionDays = flag.Int("retention", 3,
+		"Retention period in days of the closed workflows of a domain registered by -register-domain.")
+)
+
+// ensureDomain returns nil if the domain exists. A missing domain is an error, unless register is true, then it is
+// registered with the given retention and waited for until it is found.
+func ensureDomain(client cadence.DomainClient, logger *zap.Logger, name string, register bool, retentionDays int32,
+	wait time.Duration) error {
+	_, _, err := client.Describe(name)
+	if err == nil {
+		logger.Info("Domain found.", zap.String("Domain", name))
+		return nil
+	}
+	if _, ok := err.(*s.EntityNotExistsError); !ok {
+		return fmt.Errorf("failed to describe domain %s: %v", name, err)
+	}
+	if !register {
+		return fmt.Errorf("domain %s not found, run with --register-domain", name)
+	}
+
+	request := &s.RegisterDomainRequest{
+		Name:                                   common.StringPtr(name),
+		Description:                            common.StringPtr("domain for cadence sample code"),
+		WorkflowExecutionRetentionPeriodInDays: common.Int32Ptr(retentionDays)}
+	err = client.Register(request)
+	if _, ok := err.(*s.DomainAlreadyExistsError); ok {
+		// another sample registered it after the describe.
+		logger.Info("Domain already registered.", zap.String("Domain", name))
+	} else if err != nil {
+		return fmt.Errorf("failed to register domain %s: %v", name, err)
+	} else {
+		logger.Info("Domain succeesfully registered.", zap.String("Domain", name),
+			zap.Int32("RetentionDays", retentionDays))
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		_, _, err := client.Describe(name)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*s.EntityNotExistsError); !ok {
+			return fmt.Errorf("failed to describe domain %s: %v", name, err)
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("domain %s registered but not found after %v", name, wait)
+		}
+		time.Sleep(domainPollInterval)
+	}
+}
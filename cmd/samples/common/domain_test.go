@@ -0,0 +1,81 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/zap"
+)
+
+// newDomainClient returns a domain client of a mocked service.
+func newDomainClient() (cadence.DomainClient, *mocks.TChanWorkflowService) {
+	service := &mocks.TChanWorkflowService{}
+	return cadence.NewDomainClient(service, &cadence.ClientOptions{}), service
+}
+
+func TestEnsureDomain_Exists(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(&s.DescribeDomainResponse{}, nil).Once()
+	require.NoError(t, ensureDomain(client, zap.NewNop(), "samples-domain", true, 3, 0))
+	service.AssertExpectations(t)
+	service.AssertNotCalled(t, "RegisterDomain", mock.Anything, mock.Anything)
+}
+
+func TestEnsureDomain_NotFound(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.EntityNotExistsError{}).Once()
+	err := ensureDomain(client, zap.NewNop(), "samples-domain", false, 3, 0)
+	require.EqualError(t, err, "domain samples-domain not found, run with --register-domain")
+	service.AssertNotCalled(t, "RegisterDomain", mock.Anything, mock.Anything)
+}
+
+func TestEnsureDomain_Registers(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.EntityNotExistsError{}).Twice()
+	service.On("RegisterDomain", mock.Anything, mock.MatchedBy(func(request *s.RegisterDomainRequest) bool {
+		return request.GetName() == "samples-domain" && request.GetWorkflowExecutionRetentionPeriodInDays() == 7
+	})).Return(nil).Once()
+	// the registered domain is found on the second describe after the registration.
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(&s.DescribeDomainResponse{}, nil).Once()
+	require.NoError(t, ensureDomain(client, zap.NewNop(), "samples-domain", true, 7, domainRegistrationWait))
+	service.AssertExpectations(t)
+}
+
+func TestEnsureDomain_RegisteredConcurrently(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.EntityNotExistsError{}).Once()
+	service.On("RegisterDomain", mock.Anything, mock.Anything).Return(&s.DomainAlreadyExistsError{}).Once()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(&s.DescribeDomainResponse{}, nil).Once()
+	require.NoError(t, ensureDomain(client, zap.NewNop(), "samples-domain", true, 3, 0))
+	service.AssertExpectations(t)
+}
+
+func TestEnsureDomain_NotFoundAfterRegistration(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.EntityNotExistsError{})
+	service.On("RegisterDomain", mock.Anything, mock.Anything).Return(nil).Once()
+	err := ensureDomain(client, zap.NewNop(), "samples-domain", true, 3, 0)
+	require.EqualError(t, err, "domain samples-domain registered but not found after 0s")
+}
+
+func TestEnsureDomain_RegistrationFails(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.EntityNotExistsError{}).Once()
+	service.On("RegisterDomain", mock.Anything, mock.Anything).Return(&s.BadRequestError{Message: "bad retention"}).Once()
+	err := ensureDomain(client, zap.NewNop(), "samples-domain", true, 0, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to register domain samples-domain")
+}
+
+func TestEnsureDomain_DescribeFails(t *testing.T) {
+	client, service := newDomainClient()
+	service.On("DescribeDomain", mock.Anything, mock.Anything).Return(nil, &s.BadRequestError{Message: "bad name"}).Once()
+	err := ensureDomain(client, zap.NewNop(), "samples-domain", true, 3, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to describe domain samples-domain")
+	service.AssertNotCalled(t, "RegisterDomain", mock.Anything, mock.Anything)
+}
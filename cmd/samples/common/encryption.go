@@ -0,0 +1,189 @@
+package common
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/**
+ * This version of the client has no DataConverter: the client encodes every input and result with gob, and there is
+ * no hook to encrypt the payloads on their way to the history. The samples encrypt the values that may hold customer
+ * data themselves, with Keyring.Seal before they pass them to the client, and Keyring.Open where they are used. A
+ * sealed value is the string "enc:v1:<key ID>:<base64 of the nonce and the AES-GCM ciphertext>", so the types of the
+ * inputs don't change and the histories of values that weren't sealed still replay. The keyring encrypts with the key
+ * of its key ID, and decrypts with any of its keys, so that a rotated key stays in the keyring until the values sealed
+ * with it are gone.
+ */
+
+const (
+	// encryptionKeysEnv sets the keys of the keyring as comma separated id=base64 pairs, encryptionKeyIDEnv the key ID
+	// to encrypt with.
+	encryptionKeysEnv  = "CADENCE_SAMPLES_ENCRYPTION_KEYS"
+	encryptionKeyIDEnv = "CADENCE_SAMPLES_ENCRYPTION_KEY_ID"
+
+	sealedPrefix = "enc:v1:"
+)
+
+type (
+	// EncryptionConfiguration configures the keyring of the samples. Keys are the base64 AES keys by key ID, 16, 24 or
+	// 32 bytes long, KeyID is the one to encrypt with. The keys are better set by CADENCE_SAMPLES_ENCRYPTION_KEYS than
+	// in the config file.
+	EncryptionConfiguration struct {
+		KeyID string            `yaml:"keyID"`
+		Keys  map[string]string `yaml:"keys"`
+	}
+
+	// Keyring seals and opens values with AES-GCM. A nil keyring leaves the values it seals as they are, and fails to
+	// open a sealed value.
+	Keyring struct {
+		keyID string
+		aeads map[string]cipher.AEAD
+	}
+
+	keyringKey struct{}
+)
+
+// NewKeyring returns a keyring that seals with the key of the given key ID, and opens with any of the given keys.
+func NewKeyring(keyID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[keyID]; !ok {
+		return nil, fmt.Errorf("encryption key %s is not in the keyring", keyID)
+	}
+	k := &Keyring{keyID: keyID, aeads: make(map[string]cipher.AEAD)}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("encryption key ID %q must be non empty and have no colon", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %v", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// IsSealed returns true if the value was sealed by a keyring.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Seal encrypts the value with the key of the key ID of the keyring.
+func (k *Keyring) Seal(value string) (string, error) {
+	if k == nil {
+		return value, nil
+	}
+	aead := k.aeads[k.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	// the key ID is authenticated, a sealed value can't be passed off as sealed with another key.
+	header := sealedPrefix + k.keyID + ":"
+	data := aead.Seal(nonce, nonce, []byte(value), []byte(header))
+	return header + base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// Open decrypts a sealed value, a value that isn't sealed is returned as it is.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, sealedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("sealed value has no key ID")
+	}
+	keyID := parts[0]
+	if k == nil {
+		return "", fmt.Errorf("value is sealed with encryption key %s, but no encryption keys are configured", keyID)
+	}
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return "", fmt.Errorf("value is sealed with encryption key %s that is not in the keyring", keyID)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("sealed value of encryption key %s is malformed", keyID)
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(sealedPrefix+keyID+":"))
+	if err != nil {
+		return "", fmt.Errorf("failed to open value sealed with encryption key %s: %v", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// ContextWithKeyring returns a context for the BackgroundActivityContext of the worker options that gives the given
+// keyring to the activities, see ActivityKeyring.
+func ContextWithKeyring(ctx context.Context, keyring *Keyring) context.Context {
+	return context.WithValue(ctx, keyringKey{}, keyring)
+}
+
+// ActivityKeyring returns the keyring of the context of an activity, nil if the worker has no keyring.
+func ActivityKeyring(ctx context.Context) *Keyring {
+	keyring, _ := ctx.Value(keyringKey{}).(*Keyring)
+	return keyring
+}
+
+// encryptionFromEnv adds the keys and the key ID set by the environment to the given configuration, and returns it.
+// Keys in the environment enable the encryption.
+func encryptionFromEnv(config *EncryptionConfiguration) (*EncryptionConfiguration, error) {
+	keys, keyID := os.Getenv(encryptionKeysEnv), os.Getenv(encryptionKeyIDEnv)
+	if keys == "" && keyID == "" {
+		return config, nil
+	}
+	if config == nil {
+		config = &EncryptionConfiguration{}
+	}
+	if keyID != "" {
+		config.KeyID = keyID
+	}
+	for _, pair := range strings.Split(keys, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s must be comma separated id=base64 keys", encryptionKeysEnv)
+		}
+		if config.Keys == nil {
+			config.Keys = make(map[string]string)
+		}
+		config.Keys[parts[0]] = parts[1]
+	}
+	return config, nil
+}
+
+// keyring returns the keyring of the configuration, nil if the encryption isn't configured. A key ID without its key
+// is an error, the samples must not start without the keys they are configured to encrypt with.
+func (c *EncryptionConfiguration) keyring() (*Keyring, error) {
+	if c == nil || (c.KeyID == "" && len(c.Keys) == 0) {
+		return nil, nil
+	}
+	if c.KeyID == "" {
+		return nil, fmt.Errorf("encryption has keys but no keyID, set keyID or %s", encryptionKeyIDEnv)
+	}
+	if _, ok := c.Keys[c.KeyID]; !ok {
+		return nil, fmt.Errorf("encryption key %s is missing, set it in keys or %s", c.KeyID, encryptionKeysEnv)
+	}
+	keys := make(map[string][]byte)
+	for id, encoded := range c.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not base64: %v", id, err)
+		}
+		keys[id] = key
+	}
+	return NewKeyring(c.KeyID, keys)
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeyOld = bytes.Repeat([]byte{1}, 32)
+	testKeyNew = bytes.Repeat([]byte{2}, 32)
+)
+
+func newTestKeyring(t *testing.T, keyID string, keys map[string][]byte) *Keyring {
+	keyring, err := NewKeyring(keyID, keys)
+	require.NoError(t, err)
+	return keyring
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	keyring := newTestKeyring(t, "2024-01", map[string][]byte{"2024-01": testKeyNew})
+	for _, value := range []string{"customer-4711", "", "ünïcode: with colons"} {
+		sealed, err := keyring.Seal(value)
+		require.NoError(t, err)
+		require.True(t, IsSealed(sealed))
+		require.True(t, strings.HasPrefix(sealed, "enc:v1:2024-01:"), sealed)
+		opened, err := keyring.Open(sealed)
+		require.NoError(t, err)
+		require.Equal(t, value, opened)
+	}
+}
+
+func TestKeyring_NotPlaintext(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", map[string][]byte{"k1": testKeyNew})
+	sealed, err := keyring.Seal("customer-4711")
+	require.NoError(t, err)
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, "enc:v1:k1:"))
+	require.NoError(t, err)
+	require.NotContains(t, sealed, "customer-4711")
+	require.False(t, bytes.Contains(data, []byte("customer-4711")))
+	// every seal has its own nonce.
+	again, err := keyring.Seal("customer-4711")
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again)
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	oldKeyring := newTestKeyring(t, "old", map[string][]byte{"old": testKeyOld})
+	sealed, err := oldKeyring.Seal("customer-4711")
+	require.NoError(t, err)
+
+	// the rotated keyring seals with the new key, and still opens the values of the old one.
+	rotated := newTestKeyring(t, "new", map[string][]byte{"new": testKeyNew, "old": testKeyOld})
+	opened, err := rotated.Open(sealed)
+	require.NoError(t, err)
+	require.Equal(t, "customer-4711", opened)
+	resealed, err := rotated.Seal(opened)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(resealed, "enc:v1:new:"))
+
+	// once the old key is dropped its values fail to open.
+	_, err = newTestKeyring(t, "new", map[string][]byte{"new": testKeyNew}).Open(sealed)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "encryption key old that is not in the keyring")
+}
+
+func TestKeyring_OpenErrors(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", map[string][]byte{"k1": testKeyNew, "k2": testKeyOld})
+	sealed, err := keyring.Seal("customer-4711")
+	require.NoError(t, err)
+
+	// a value that isn't sealed is returned as it is, also without a keyring.
+	opened, err := keyring.Open("plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", opened)
+	var none *Keyring
+	opened, err = none.Open("plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", opened)
+	unsealed, err := none.Seal("plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", unsealed)
+
+	_, err = none.Open(sealed)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no encryption keys are configured")
+	// the key ID is authenticated.
+	_, err = keyring.Open(strings.Replace(sealed, "enc:v1:k1:", "enc:v1:k2:", 1))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open value sealed with encryption key k2")
+	_, err = keyring.Open(sealed[:len(sealed)-2])
+	require.Error(t, err)
+	_, err = keyring.Open("enc:v1:k1:!!")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "malformed")
+}
+
+func TestNewKeyring_Errors(t *testing.T) {
+	_, err := NewKeyring("k1", map[string][]byte{"k2": testKeyNew})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "encryption key k1 is not in the keyring")
+	_, err = NewKeyring("k1", map[string][]byte{"k1": []byte("short")})
+	require.Error(t, err)
+	_, err = NewKeyring("k:1", map[string][]byte{"k:1": testKeyNew})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no colon")
+}
+
+func TestActivityKeyring(t *testing.T) {
+	require.Nil(t, ActivityKeyring(context.Background()))
+	keyring := newTestKeyring(t, "k1", map[string][]byte{"k1": testKeyNew})
+	require.Equal(t, keyring, ActivityKeyring(ContextWithKeyring(context.Background(), keyring)))
+}
+
+func TestLoadConfiguration_Encryption(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKeyNew)
+	config, err := LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nencryption:\n  keyID: \"k1\"\n"+
+		"  keys:\n    k1: \""+encoded+"\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, &EncryptionConfiguration{KeyID: "k1", Keys: map[string]string{"k1": encoded}}, config.Encryption)
+
+	// a key ID without its key fails, the samples must not start without their keys.
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nencryption:\n  keyID: \"k1\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "encryption key k1 is missing")
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nencryption:\n  keyID: \"k1\"\n"+
+		"  keys:\n    k1: \"not base64\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not base64")
+
+	// the keys of the environment are added to the keys of the config file.
+	t.Setenv(encryptionKeysEnv, "k2="+base64.StdEncoding.EncodeToString(testKeyOld)+",k3="+encoded)
+	t.Setenv(encryptionKeyIDEnv, "k2")
+	config, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nencryption:\n  keyID: \"k1\"\n"+
+		"  keys:\n    k1: \""+encoded+"\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, "k2", config.Encryption.KeyID)
+	require.Len(t, config.Encryption.Keys, 3)
+
+	t.Setenv(encryptionKeysEnv, "k2")
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "comma separated id=base64 keys")
+}
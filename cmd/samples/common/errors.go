@@ -0,0 +1,116 @@
+package common
+
+import (
+	"errors"
+	"strings"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * ClassifyError tells the workflow what went wrong with an activity, see recipes/errorhandling. A workflow that gets an
+ * error from an activity future has one of the error interfaces of the client: a TimeoutError with the type of the
+ * timeout, a CanceledError, a PanicError, or an ErrorWithDetails.
+ *
+ * In this version of the client every error an activity returns arrives as an ErrorWithDetails, the ones created with
+ * cadence.NewErrorWithDetails with their reason and details, any other error with its message as the reason. A panic of
+ * an activity arrives as an ErrorWithDetails too, with the panic value as the reason and the stack trace of the
+ * activity worker as the details, only a panic of workflow code is a PanicError. The custom errors are told apart from
+ * the others by their reasons, the panics by their stack trace.
+ */
+
+// The kinds of failures.
+const (
+	// FailureCustom is an ErrorWithDetails with one of the custom reasons, a failure the activity reported on purpose.
+	FailureCustom FailureKind = "custom"
+	// FailureGeneric is any other error the activity returned.
+	FailureGeneric FailureKind = "generic"
+	// FailurePanic is a panic of the activity or of workflow code.
+	FailurePanic FailureKind = "panic"
+	// FailureTimeout is a timeout of the activity.
+	FailureTimeout FailureKind = "timeout"
+	// FailureCanceled is a cancellation of the activity.
+	FailureCanceled FailureKind = "canceled"
+)
+
+// the first line of the stack trace of a panic of an activity is "activity for <task list> [panic]:".
+const (
+	activityPanicPrefix = "activity for "
+	activityPanicSuffix = " [panic]:"
+)
+
+type (
+	// FailureKind is the kind of an error.
+	FailureKind string
+
+	// Failure describes an error of an activity.
+	Failure struct {
+		Kind FailureKind
+		// Reason is the reason of a custom or generic failure, and the panic value of a panic.
+		Reason string
+		// TimeoutType is the type of the timeout of a timeout.
+		TimeoutType shared.TimeoutType
+		// StackTrace is the stack trace of a panic.
+		StackTrace string
+		details    []byte
+	}
+)
+
+// ClassifyError returns the kind of the error and what it carries, an ErrorWithDetails with one of the custom reasons
+// is a custom failure. It returns a generic failure for an error that didn't come from an activity.
+func ClassifyError(err error, customReasons ...string) Failure {
+	switch err := err.(type) {
+	case cadence.TimeoutError:
+		failure := Failure{Kind: FailureTimeout, Reason: err.Error(), TimeoutType: err.TimeoutType()}
+		// only a heartbeat timeout has details, the details of the last heartbeat.
+		if err.TimeoutType() == shared.TimeoutType_HEARTBEAT {
+			err.Details(&failure.details)
+		}
+		return failure
+	case cadence.CanceledError:
+		failure := Failure{Kind: FailureCanceled, Reason: err.Error()}
+		err.Details(&failure.details)
+		return failure
+	case cadence.PanicError:
+		return Failure{Kind: FailurePanic, Reason: err.Error(), StackTrace: err.StackTrace()}
+	case cadence.ErrorWithDetails:
+		var details []byte
+		err.Details(&details)
+		for _, reason := range customReasons {
+			if err.Reason() == reason {
+				return Failure{Kind: FailureCustom, Reason: reason, details: details}
+			}
+		}
+		if stackTrace := string(details); isActivityPanic(stackTrace) {
+			return Failure{Kind: FailurePanic, Reason: err.Reason(), StackTrace: stackTrace}
+		}
+		return Failure{Kind: FailureGeneric, Reason: err.Reason(), details: details}
+	}
+	return Failure{Kind: FailureGeneric, Reason: err.Error()}
+}
+
+func isActivityPanic(stackTrace string) bool {
+	firstLine := strings.SplitN(stackTrace, "\n", 2)[0]
+	return strings.HasPrefix(firstLine, activityPanicPrefix) && strings.HasSuffix(firstLine, activityPanicSuffix)
+}
+
+// Retryable returns false for the failures that a retry doesn't fix: the custom failures, which the activity reports on
+// purpose, and the cancellations.
+func (f Failure) Retryable() bool {
+	return f.Kind != FailureCustom && f.Kind != FailureCanceled
+}
+
+// HasDetails returns true if the failure carries details.
+func (f Failure) HasDetails() bool {
+	return len(f.details) > 0
+}
+
+// Details decodes the details of the failure into the pointers. The details of activity errors are wrapped once more
+// in this version of the client, they are decoded in two steps and ClassifyError took the first.
+func (f Failure) Details(valuePtr ...interface{}) error {
+	if !f.HasDetails() {
+		return errors.New("failure has no details")
+	}
+	return cadence.EncodedValues(f.details).Get(valuePtr...)
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestClassifyError_Custom(t *testing.T) {
+	failure := ClassifyError(cadence.NewErrorWithDetails("invalidInput", "field", 3), "invalidInput")
+	require.Equal(t, FailureCustom, failure.Kind)
+	require.Equal(t, "invalidInput", failure.Reason)
+	require.False(t, failure.Retryable())
+	var field string
+	var value int
+	require.NoError(t, failure.Details(&field, &value))
+	require.Equal(t, "field", field)
+	require.Equal(t, 3, value)
+}
+
+func TestClassifyError_Generic(t *testing.T) {
+	failure := ClassifyError(cadence.NewErrorWithDetails("connection refused"), "invalidInput")
+	require.Equal(t, Failure{Kind: FailureGeneric, Reason: "connection refused", details: failure.details}, failure)
+	require.True(t, failure.Retryable())
+
+	failure = ClassifyError(errors.New("not from an activity"))
+	require.Equal(t, Failure{Kind: FailureGeneric, Reason: "not from an activity"}, failure)
+	require.False(t, failure.HasDetails())
+	require.Error(t, failure.Details(new(string)))
+}
+
+func TestClassifyError_ActivityPanic(t *testing.T) {
+	stackTrace := "activity for sampleGroup [panic]:\nmain.sampleActivity(...)"
+	failure := ClassifyError(cadence.NewErrorWithDetails("inventory is corrupted", []byte(stackTrace)))
+	require.Equal(t, Failure{Kind: FailurePanic, Reason: "inventory is corrupted", StackTrace: stackTrace}, failure)
+	require.True(t, failure.Retryable())
+}
+
+func TestClassifyError_Timeout(t *testing.T) {
+	failure := ClassifyError(cadence.NewTimeoutError(shared.TimeoutType_START_TO_CLOSE))
+	require.Equal(t, FailureTimeout, failure.Kind)
+	require.Equal(t, shared.TimeoutType_START_TO_CLOSE, failure.TimeoutType)
+	require.False(t, failure.HasDetails())
+	require.True(t, failure.Retryable())
+
+	failure = ClassifyError(cadence.NewHeartbeatTimeoutError(5))
+	require.Equal(t, shared.TimeoutType_HEARTBEAT, failure.TimeoutType)
+	var progress int
+	require.NoError(t, failure.Details(&progress))
+	require.Equal(t, 5, progress)
+}
+
+func TestClassifyError_Canceled(t *testing.T) {
+	failure := ClassifyError(cadence.NewCanceledError())
+	require.Equal(t, FailureCanceled, failure.Kind)
+	require.False(t, failure.Retryable())
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"crypto/tls"
 	"errors"
 
 	"go.uber.org/cadence"
@@ -19,10 +20,14 @@ const (
 // WorkflowClientBuilder build client to cadence service
 type WorkflowClientBuilder struct {
 	tchanClient    thrift.TChanClient
+	tchan          *tchannel.Channel
+	tunnel         *tlsTunnel
 	hostPort       string
+	serviceName    string
 	domain         string
 	clientIdentity string
 	metricsScope   tally.Scope
+	tlsConfig      *tls.Config
 }
 
 // NewBuilder creates a new WorkflowClientBuilder
@@ -36,6 +41,12 @@ func (b *WorkflowClientBuilder) SetHostPort(hostport string) *WorkflowClientBuil
 	return b
 }
 
+// SetServiceName sets the name of the cadence frontend service for the builder
+func (b *WorkflowClientBuilder) SetServiceName(serviceName string) *WorkflowClientBuilder {
+	b.serviceName = serviceName
+	return b
+}
+
 // SetDomain sets the domain for the builder
 func (b *WorkflowClientBuilder) SetDomain(domain string) *WorkflowClientBuilder {
 	b.domain = domain
@@ -54,6 +65,12 @@ func (b *WorkflowClientBuilder) SetMetricsScope(metricsScope tally.Scope) *Workf
 	return b
 }
 
+// SetTLSConfig sets the TLS config of the connection to cadence service, nil means no TLS
+func (b *WorkflowClientBuilder) SetTLSConfig(config *tls.Config) *WorkflowClientBuilder {
+	b.tlsConfig = config
+	return b
+}
+
 // BuildCadenceClient builds a client to cadence service
 func (b *WorkflowClientBuilder) BuildCadenceClient() (cadence.Client, error) {
 	service, err := b.BuildServiceClient()
@@ -98,7 +115,35 @@ func (b *WorkflowClientBuilder) build() error {
 		return err
 	}
 
-	opts := &thrift.ClientOptions{HostPort: b.hostPort}
-	b.tchanClient = thrift.NewClient(tchan, cadenceFrontendService, opts)
+	serviceName := b.serviceName
+	if serviceName == "" {
+		serviceName = cadenceFrontendService
+	}
+	hostPort := b.hostPort
+	if b.tlsConfig != nil {
+		tunnel, err := startTLSTunnel(hostPort, b.tlsConfig)
+		if err != nil {
+			tchan.Close()
+			return err
+		}
+		hostPort = tunnel.Addr()
+		b.tunnel = tunnel
+	}
+	opts := &thrift.ClientOptions{HostPort: hostPort}
+	b.tchan = tchan
+	b.tchanClient = thrift.NewClient(tchan, serviceName, opts)
 	return nil
+}
+
+// Close closes the connections of the clients built so far, and the TLS tunnel to cadence service. The clients fail
+// after it, the next client that is built connects again.
+func (b *WorkflowClientBuilder) Close() {
+	if b.tchanClient == nil {
+		return
+	}
+	b.tchan.Close()
+	if b.tunnel != nil {
+		b.tunnel.Close()
+	}
+	b.tchanClient, b.tchan, b.tunnel = nil, nil, nil
 }
\ No newline at end of file
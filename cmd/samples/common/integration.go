@@ -0,0 +1,137 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * The integration tests of the samples run their workflows on a Cadence server, the one of the config file, to catch
+ * what the test environment of the client doesn't: workflows and activities that aren't registered, task lists that
+ * don't match, and inputs that don't encode. They are built with the integration tag only:
+ *
+ *   go test -tags integration ./cron
+ *
+ * The helpers connect the test to the server, wait for the workflows it started and terminate the ones left open.
+ */
+
+const (
+	// integrationDialInterval is how often the server is dialed until it is reachable.
+	integrationDialInterval = 500 * time.Millisecond
+	// integrationPollInterval is how often the history of a workflow is read until it is closed.
+	integrationPollInterval = 500 * time.Millisecond
+)
+
+// ConnectIntegration returns a helper connected to the server of the given config file, an empty path means the file
+// of CADENCE_SAMPLES_CONFIG or the config/development.yaml of the closest parent directory that has one. The domain is
+// registered if it doesn't exist. It fails once no server was reachable within the timeout, instead of leaving the
+// test to the retries of the client.
+func ConnectIntegration(configFile string, timeout time.Duration) (h *SampleHelper, err error) {
+	if configFile == "" && os.Getenv(configFileEnv) == "" {
+		if configFile, err = findConfigFile(); err != nil {
+			return nil, err
+		}
+	}
+	config, err := LoadConfiguration(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitForServer(config.HostNameAndPort, timeout); err != nil {
+		return nil, err
+	}
+	h = &SampleHelper{ConfigFile: configFile, RegisterDomain: true}
+	defer func() {
+		// the setup of the samples panics, a test reports the error instead.
+		if p := recover(); p != nil {
+			h, err = nil, fmt.Errorf("failed to set up the samples for %s: %v", config.HostNameAndPort, p)
+		}
+	}()
+	h.SetupServiceConfig()
+	return h, nil
+}
+
+// findConfigFile returns the config/development.yaml of the working directory or of its closest parent that has one,
+// the tests of a sample run in the directory of the sample.
+func findConfigFile() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, configFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s in the working directory or its parents, set %s", configFile, configFileEnv)
+		}
+		dir = parent
+	}
+}
+
+// waitForServer dials the host until it accepts a connection, and fails once the timeout passed.
+func waitForServer(hostPort string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", hostPort, integrationDialInterval)
+		if err == nil {
+			return conn.Close()
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("no Cadence server reachable at %s within %v, start one or set %s: %v", hostPort, timeout,
+				hostEnv, err)
+		}
+		time.Sleep(integrationDialInterval)
+	}
+}
+
+// WaitForClose returns the history of the run once it is closed, completed or not. It fails once the timeout passed,
+// with the last event of the run.
+func WaitForClose(client cadence.Client, workflowID, runID string, timeout time.Duration) (*s.History, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		history, err := client.GetWorkflowHistory(workflowID, runID)
+		if err != nil {
+			return nil, err
+		}
+		last := "none"
+		if len(history.Events) > 0 {
+			eventType := history.Events[len(history.Events)-1].GetEventType()
+			if isCloseEvent(eventType) {
+				return history, nil
+			}
+			last = eventType.String()
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("workflow %s not closed within %v, its last event is %s, are the workers running?",
+				workflowID, timeout, last)
+		}
+		time.Sleep(integrationPollInterval)
+	}
+}
+
+func isCloseEvent(eventType s.EventType) bool {
+	switch eventType {
+	case s.EventType_WorkflowExecutionCompleted, s.EventType_WorkflowExecutionFailed,
+		s.EventType_WorkflowExecutionTimedOut, s.EventType_WorkflowExecutionCanceled,
+		s.EventType_WorkflowExecutionTerminated, s.EventType_WorkflowExecutionContinuedAsNew:
+		return true
+	}
+	return false
+}
+
+// TerminateIfOpen terminates the workflow if it is still running, e.g. after a test that failed or timed out.
+func TerminateIfOpen(client cadence.Client, workflowID, reason string) error {
+	err := client.TerminateWorkflow(workflowID, "", reason, nil)
+	if _, ok := err.(*s.EntityNotExistsError); ok {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/mocks"
+)
+
+func TestFindConfigFile(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "config"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cmd", "samples", "cron"), 0755))
+	path := writeConfigAt(t, filepath.Join(root, configFile))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	defer os.Chdir(wd)
+
+	// a sample's test runs in the directory of the sample.
+	require.NoError(t, os.Chdir(filepath.Join(root, "cmd", "samples", "cron")))
+	found, err := findConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, path, found)
+
+	require.NoError(t, os.Chdir(t.TempDir()))
+	_, err = findConfigFile()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), configFileEnv)
+}
+
+func writeConfigAt(t *testing.T, path string) string {
+	require.NoError(t, ioutil.WriteFile(path, []byte("domain: \"samples-domain\"\n"), 0644))
+	// the working directory of the test may be a symbolic link.
+	resolved, err := filepath.EvalSymlinks(path)
+	require.NoError(t, err)
+	return resolved
+}
+
+func TestWaitForServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, waitForServer(address, time.Second))
+
+	// once the server is gone the wait fails with the address, instead of hanging.
+	require.NoError(t, listener.Close())
+	start := time.Now()
+	err = waitForServer(address, time.Second)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no Cadence server reachable at "+address)
+	require.True(t, time.Since(start) < time.Second*5)
+}
+
+func historyOf(eventTypes ...s.EventType) *s.GetWorkflowExecutionHistoryResponse {
+	var events []*s.HistoryEvent
+	for i := range eventTypes {
+		events = append(events, &s.HistoryEvent{EventType: &eventTypes[i]})
+	}
+	return &s.GetWorkflowExecutionHistoryResponse{History: &s.History{Events: events}}
+}
+
+func TestWaitForClose(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	client := cadence.NewClient(service, "samples-domain", &cadence.ClientOptions{})
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(
+		historyOf(s.EventType_WorkflowExecutionStarted), nil).Once()
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(
+		historyOf(s.EventType_WorkflowExecutionStarted, s.EventType_WorkflowExecutionCompleted), nil).Once()
+	history, err := WaitForClose(client, "cron_1", "", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, history.Events, 2)
+	service.AssertExpectations(t)
+
+	service = &mocks.TChanWorkflowService{}
+	client = cadence.NewClient(service, "samples-domain", &cadence.ClientOptions{})
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(
+		historyOf(s.EventType_WorkflowExecutionStarted, s.EventType_DecisionTaskScheduled), nil)
+	_, err = WaitForClose(client, "cron_1", "", 0)
+	require.EqualError(t, err, "workflow cron_1 not closed within 0s, its last event is DecisionTaskScheduled, "+
+		"are the workers running?")
+}
+
+func TestTerminateIfOpen(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	client := cadence.NewClient(service, "samples-domain", &cadence.ClientOptions{})
+	service.On("TerminateWorkflowExecution", mock.Anything, mock.Anything).Return(
+		&s.EntityNotExistsError{Message: "workflow execution already completed"}).Once()
+	require.NoError(t, TerminateIfOpen(client, "cron_1", "test done"))
+	service.On("TerminateWorkflowExecution", mock.Anything, mock.Anything).Return(&s.BadRequestError{}).Once()
+	require.Error(t, TerminateIfOpen(client, "cron_1", "test done"))
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+/**
+ * The logger of the samples is built from the logging section of the config file, the workers get it with their
+ * options, so that cadence.GetLogger and cadence.GetActivityLogger log with it, and the starters log with it too.
+ * Without the section the samples log as before, with the development logger of zap at debug level.
+ *
+ * The fields of the log lines are named the same across samples: the client adds WorkflowType, WorkflowID and RunID to
+ * the logger of a workflow, ActivityLogger adds WorkflowID, RunID, ActivityType and ActivityID to the logger of an
+ * activity, and the samples name their own fields in the same CamelCase, e.g. ScheduleInterval or TraceID.
+ */
+
+type (
+	// LoggingConfiguration configures the logger of the samples. Level is debug, info, warn or error, info by
+	// default. Encoding is console or json, console by default. OutputPath is the file the logs are written to,
+	// stderr by default. Sampling drops repeated log lines, see LoggingSampling.
+	LoggingConfiguration struct {
+		Level      string           `yaml:"level"`
+		Encoding   string           `yaml:"encoding"`
+		OutputPath string           `yaml:"outputPath"`
+		Sampling   *LoggingSampling `yaml:"sampling"`
+	}
+
+	// LoggingSampling logs the first Initial lines with the same level and message every second, and every
+	// Thereafter-th line after that.
+	LoggingSampling struct {
+		Initial    int `yaml:"initial"`
+		Thereafter int `yaml:"thereafter"`
+	}
+)
+
+// zapConfig returns the zap configuration of the logging configuration.
+func (c *LoggingConfiguration) zapConfig() (zap.Config, error) {
+	if c == nil {
+		return zap.NewDevelopmentConfig(), nil
+	}
+	var config zap.Config
+	switch c.Encoding {
+	case "", "console":
+		config = zap.NewDevelopmentConfig()
+	case "json":
+		config = zap.NewProductionConfig()
+	default:
+		return config, fmt.Errorf("logging encoding %q is neither console nor json", c.Encoding)
+	}
+	level := zapcore.InfoLevel
+	if c.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			return config, fmt.Errorf("logging level %q is not debug, info, warn or error", c.Level)
+		}
+	}
+	config.Level = zap.NewAtomicLevelAt(level)
+	if c.OutputPath != "" {
+		config.OutputPaths = []string{c.OutputPath}
+	}
+	config.Sampling = nil
+	if c.Sampling != nil {
+		if c.Sampling.Initial <= 0 || c.Sampling.Thereafter <= 0 {
+			return config, fmt.Errorf("logging sampling initial and thereafter must be positive, got %d and %d",
+				c.Sampling.Initial, c.Sampling.Thereafter)
+		}
+		config.Sampling = &zap.SamplingConfig{Initial: c.Sampling.Initial, Thereafter: c.Sampling.Thereafter}
+	}
+	return config, nil
+}
+
+// logger builds the logger of the configuration.
+func (c *LoggingConfiguration) logger() (*zap.Logger, error) {
+	config, err := c.zapConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Build()
+}
+
+// ActivityLogger returns the logger of the activity with the workflow and the activity it logs for.
+func ActivityLogger(ctx context.Context) *zap.Logger {
+	info := cadence.GetActivityInfo(ctx)
+	return cadence.GetActivityLogger(ctx).With(
+		zap.String("WorkflowID", info.WorkflowExecution.ID),
+		zap.String("RunID", info.WorkflowExecution.RunID),
+		zap.String("ActivityType", info.ActivityType.Name),
+		zap.String("ActivityID", info.ActivityID))
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func loggingTestActivity(ctx context.Context) error {
+	ActivityLogger(ctx).Info("Activity ran.")
+	return nil
+}
+
+func init() {
+	cadence.RegisterActivity(loggingTestActivity)
+}
+
+func TestLoggingConfiguration_Level(t *testing.T) {
+	config, err := (&LoggingConfiguration{Level: "warn", Encoding: "json"}).zapConfig()
+	require.NoError(t, err)
+	core, logs := observer.New(config.Level)
+	logger := zap.New(core)
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	require.Equal(t, []string{"warn", "error"}, messages)
+
+	// info is the default level of a configured logger, debug the one of the samples without a logging section.
+	config, err = (&LoggingConfiguration{}).zapConfig()
+	require.NoError(t, err)
+	require.False(t, config.Level.Enabled(zapcore.DebugLevel))
+	require.True(t, config.Level.Enabled(zapcore.InfoLevel))
+	var none *LoggingConfiguration
+	config, err = none.zapConfig()
+	require.NoError(t, err)
+	require.True(t, config.Level.Enabled(zapcore.DebugLevel))
+}
+
+func TestLoggingConfiguration_JSONOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.log")
+	logger, err := (&LoggingConfiguration{Encoding: "json", OutputPath: path,
+		Sampling: &LoggingSampling{Initial: 2, Thereafter: 100}}).logger()
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		logger.Info("Cron job running.", zap.String("WorkflowID", "cron_1"))
+	}
+	require.NoError(t, logger.Sync())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	// the sampling drops the repetitions after the first 2 lines.
+	require.Len(t, lines, 2)
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	require.Equal(t, "info", line["level"])
+	require.Equal(t, "Cron job running.", line["msg"])
+	require.Equal(t, "cron_1", line["WorkflowID"])
+}
+
+func TestLoggingConfiguration_Errors(t *testing.T) {
+	_, err := (&LoggingConfiguration{Encoding: "xml"}).zapConfig()
+	require.EqualError(t, err, `logging encoding "xml" is neither console nor json`)
+	_, err = (&LoggingConfiguration{Level: "loud"}).zapConfig()
+	require.EqualError(t, err, `logging level "loud" is not debug, info, warn or error`)
+	_, err = (&LoggingConfiguration{Sampling: &LoggingSampling{Initial: 1}}).zapConfig()
+	require.Error(t, err)
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nlogging:\n  level: \"loud\"\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "logging level")
+	config, err := LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nlogging:\n  level: \"debug\"\n"+
+		"  encoding: \"json\"\n  sampling:\n    initial: 10\n    thereafter: 50\n"))
+	require.NoError(t, err)
+	require.Equal(t, &LoggingConfiguration{Level: "debug", Encoding: "json",
+		Sampling: &LoggingSampling{Initial: 10, Thereafter: 50}}, config.Logging)
+}
+
+func TestActivityLogger(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	core, logs := observer.New(zap.InfoLevel)
+	suite.SetLogger(zap.New(core))
+	env := suite.NewTestActivityEnvironment()
+	_, err := env.ExecuteActivity(loggingTestActivity)
+	require.NoError(t, err)
+
+	entries := logs.FilterMessage("Activity ran.").All()
+	require.Len(t, entries, 1)
+	fields := make(map[string]string)
+	for _, field := range entries[0].Context {
+		fields[field.Key] = field.String
+	}
+	require.Contains(t, fields["ActivityType"], "loggingTestActivity")
+	for _, key := range []string{"WorkflowID", "RunID", "ActivityID"} {
+		require.Contains(t, fields, key)
+	}
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// loggingReporter is a tally reporter that logs the metrics it is given, so that the metrics of a sample show up next
+// to its logs without a metrics backend.
+type loggingReporter struct {
+	logger *zap.Logger
+}
+
+func (r loggingReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.logger.Info("Counter", zap.String("Name", name), zap.Any("Tags", tags), zap.Int64("Value", value))
+}
+
+func (r loggingReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.logger.Info("Gauge", zap.String("Name", name), zap.Any("Tags", tags), zap.Float64("Value", value))
+}
+
+func (r loggingReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.logger.Info("Timer", zap.String("Name", name), zap.Any("Tags", tags), zap.Duration("Value", interval))
+}
+
+func (r loggingReporter) ReportHistogramValueSamples(name string, tags map[string]string, buckets tally.Buckets,
+	bucketLowerBound, bucketUpperBound float64, samples int64) {
+	r.logger.Info("Histogram", zap.String("Name", name), zap.Any("Tags", tags),
+		zap.Float64("LowerBound", bucketLowerBound), zap.Float64("UpperBound", bucketUpperBound),
+		zap.Int64("Samples", samples))
+}
+
+func (r loggingReporter) ReportHistogramDurationSamples(name string, tags map[string]string, buckets tally.Buckets,
+	bucketLowerBound, bucketUpperBound time.Duration, samples int64) {
+	r.logger.Info("Histogram", zap.String("Name", name), zap.Any("Tags", tags),
+		zap.Duration("LowerBound", bucketLowerBound), zap.Duration("UpperBound", bucketUpperBound),
+		zap.Int64("Samples", samples))
+}
+
+func (r loggingReporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r loggingReporter) Reporting() bool {
+	return true
+}
+
+func (r loggingReporter) Tagging() bool {
+	return true
+}
+
+func (r loggingReporter) Flush() {}
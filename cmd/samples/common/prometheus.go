@@ -0,0 +1,246 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+/**
+ * The prometheus reporter of tally needs the prometheus client, which isn't vendored. prometheusReporter keeps the
+ * metrics reported by tally and serves them in the text format of prometheus: a counter is the sum of its reports, a
+ * gauge its last value, a timer a summary of the count and the sum of its durations in seconds, and a histogram the
+ * cumulative count of its buckets. The names and the tag keys are sanitized to the characters prometheus allows, the
+ * scope joins the names with "_".
+ */
+
+const (
+	prometheusMetricsPath = "/metrics"
+	// prometheusReportInterval is how often the scope reports the counters and the gauges to the reporter.
+	prometheusReportInterval = time.Second
+)
+
+type (
+	prometheusReporter struct {
+		sync.Mutex
+		metrics map[string]*prometheusMetric
+	}
+
+	prometheusMetric struct {
+		name, kind string
+		tags       map[string]string
+		value      float64
+		count      int64
+		// buckets are the samples of a histogram by the upper bound of their bucket.
+		buckets map[float64]int64
+	}
+
+	// prometheusServer serves the metrics of a reporter until it is stopped.
+	prometheusServer struct {
+		listener net.Listener
+		server   *http.Server
+	}
+)
+
+// prometheusEscaper escapes the tag values, prometheus only escapes backslashes, quotes and new lines.
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func newPrometheusReporter() *prometheusReporter {
+	return &prometheusReporter{metrics: make(map[string]*prometheusMetric)}
+}
+
+// startPrometheusServer serves the metrics of the reporter on the given address, it fails if the address is in use.
+func startPrometheusServer(address string, reporter *prometheusReporter) (*prometheusServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for prometheus on %s: %v", address, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(prometheusMetricsPath, reporter)
+	server := &prometheusServer{listener: listener, server: &http.Server{Handler: mux}}
+	go server.server.Serve(listener)
+	return server, nil
+}
+
+// Addr returns the address the metrics are served on.
+func (s *prometheusServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop closes the listener and the connections of the server.
+func (s *prometheusServer) Stop() error {
+	return s.server.Close()
+}
+
+// metric returns the metric with the given name and tags, it is created with the given kind if it doesn't exist.
+func (r *prometheusReporter) metric(kind, name string, tags map[string]string) *prometheusMetric {
+	name = sanitizePrometheusName(name)
+	sanitized := make(map[string]string, len(tags))
+	for key, value := range tags {
+		sanitized[sanitizePrometheusName(key)] = value
+	}
+	id := name + formatPrometheusTags(sanitized, "", "")
+	metric, ok := r.metrics[id]
+	if !ok {
+		metric = &prometheusMetric{name: name, kind: kind, tags: sanitized, buckets: make(map[float64]int64)}
+		r.metrics[id] = metric
+	}
+	return metric
+}
+
+func (r *prometheusReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.metric("counter", name, tags).value += float64(value)
+}
+
+func (r *prometheusReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.Lock()
+	defer r.Unlock()
+	r.metric("gauge", name, tags).value = value
+}
+
+func (r *prometheusReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	metric := r.metric("summary", name, tags)
+	metric.value += interval.Seconds()
+	metric.count++
+}
+
+func (r *prometheusReporter) ReportHistogramValueSamples(name string, tags map[string]string, buckets tally.Buckets,
+	bucketLowerBound, bucketUpperBound float64, samples int64) {
+	r.reportHistogram(name, tags, bucketUpperBound, samples)
+}
+
+func (r *prometheusReporter) ReportHistogramDurationSamples(name string, tags map[string]string, buckets tally.Buckets,
+	bucketLowerBound, bucketUpperBound time.Duration, samples int64) {
+	upperBound := bucketUpperBound.Seconds()
+	if bucketUpperBound == time.Duration(math.MaxInt64) {
+		upperBound = math.Inf(1)
+	}
+	r.reportHistogram(name, tags, upperBound, samples)
+}
+
+func (r *prometheusReporter) reportHistogram(name string, tags map[string]string, upperBound float64, samples int64) {
+	r.Lock()
+	defer r.Unlock()
+	metric := r.metric("histogram", name, tags)
+	metric.buckets[upperBound] += samples
+	metric.count += samples
+}
+
+func (r *prometheusReporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *prometheusReporter) Reporting() bool {
+	return true
+}
+
+func (r *prometheusReporter) Tagging() bool {
+	return true
+}
+
+func (r *prometheusReporter) Flush() {}
+
+// ServeHTTP writes the metrics in the text format of prometheus, sorted by name and tags.
+func (r *prometheusReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(r.format())
+}
+
+func (r *prometheusReporter) format() []byte {
+	r.Lock()
+	defer r.Unlock()
+	ids := make([]string, 0, len(r.metrics))
+	for id := range r.metrics {
+		ids = append(ids, id)
+	}
+	// the lines of a name must be together, the ids of other names may sort between them.
+	sort.Slice(ids, func(i, j int) bool {
+		if r.metrics[ids[i]].name != r.metrics[ids[j]].name {
+			return r.metrics[ids[i]].name < r.metrics[ids[j]].name
+		}
+		return ids[i] < ids[j]
+	})
+
+	var out bytes.Buffer
+	typed := make(map[string]bool)
+	for _, id := range ids {
+		metric := r.metrics[id]
+		if !typed[metric.name] {
+			fmt.Fprintf(&out, "# TYPE %s %s\n", metric.name, metric.kind)
+			typed[metric.name] = true
+		}
+		tags := formatPrometheusTags(metric.tags, "", "")
+		switch metric.kind {
+		case "counter", "gauge":
+			fmt.Fprintf(&out, "%s%s %s\n", metric.name, tags, formatPrometheusValue(metric.value))
+		case "summary":
+			fmt.Fprintf(&out, "%s_sum%s %s\n", metric.name, tags, formatPrometheusValue(metric.value))
+			fmt.Fprintf(&out, "%s_count%s %d\n", metric.name, tags, metric.count)
+		case "histogram":
+			bounds := make([]float64, 0, len(metric.buckets)+1)
+			for bound := range metric.buckets {
+				bounds = append(bounds, bound)
+			}
+			if metric.buckets[math.Inf(1)] == 0 {
+				bounds = append(bounds, math.Inf(1))
+			}
+			sort.Float64s(bounds)
+			var cumulative int64
+			for _, bound := range bounds {
+				cumulative += metric.buckets[bound]
+				fmt.Fprintf(&out, "%s_bucket%s %d\n", metric.name,
+					formatPrometheusTags(metric.tags, "le", formatPrometheusValue(bound)), cumulative)
+			}
+			fmt.Fprintf(&out, "%s_count%s %d\n", metric.name, tags, metric.count)
+		}
+	}
+	return out.Bytes()
+}
+
+// sanitizePrometheusName replaces the characters prometheus doesn't allow in names with "_".
+func sanitizePrometheusName(name string) string {
+	sanitized := []byte(name)
+	for i, c := range sanitized {
+		valid := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && i > 0
+		if !valid {
+			sanitized[i] = '_'
+		}
+	}
+	return string(sanitized)
+}
+
+// formatPrometheusTags returns the tags sorted by key in braces, with an extra tag if extraKey isn't empty.
+func formatPrometheusTags(tags map[string]string, extraKey, extraValue string) string {
+	pairs := make([]string, 0, len(tags)+1)
+	for key, value := range tags {
+		pairs = append(pairs, key+"=\""+prometheusEscaper.Replace(value)+"\"")
+	}
+	sort.Strings(pairs)
+	if extraKey != "" {
+		pairs = append(pairs, extraKey+"=\""+prometheusEscaper.Replace(extraValue)+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatPrometheusValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// scrape returns the metrics served on the given address.
+func scrape(t *testing.T, address string) string {
+	response, err := http.Get("http://" + address + prometheusMetricsPath)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestEnablePrometheus(t *testing.T) {
+	h := SampleHelper{Logger: zap.NewNop(), Builder: NewBuilder()}
+	require.NoError(t, h.EnablePrometheus("127.0.0.1:0"))
+	address := h.prometheus.Addr()
+
+	h.Scope.Tagged(map[string]string{"schedule": "nightly"}).Counter("cron.runs_started").Inc(3)
+	// the scope reports the counter to the reporter every prometheusReportInterval.
+	deadline := time.Now().Add(prometheusReportInterval * 5)
+	for !strings.Contains(scrape(t, address), "cron_runs_started{schedule=\"nightly\"} 3\n") {
+		require.True(t, time.Now().Before(deadline), "counter not scraped")
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	// stopping the workers stops the server.
+	h.StopWorkers()
+	_, err := http.Get("http://" + address + prometheusMetricsPath)
+	require.Error(t, err)
+}
+
+func TestEnablePrometheus_AddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	h := SampleHelper{Logger: zap.NewNop(), Builder: NewBuilder()}
+	err = h.EnablePrometheus(listener.Addr().String())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to listen for prometheus on "+listener.Addr().String())
+}
+
+func TestPrometheusReporter_Format(t *testing.T) {
+	reporter := newPrometheusReporter()
+	reporter.ReportCounter("cron.runs", map[string]string{"workflow-id": "cron_1"}, 2)
+	reporter.ReportCounter("cron.runs", map[string]string{"workflow-id": "cron_1"}, 1)
+	reporter.ReportCounter("cron.runs", nil, 4)
+	reporter.ReportCounter("cron.runs_failed", nil, 1)
+	reporter.ReportGauge("cron.queue", map[string]string{"reason": "a \"quoted\"\nreason"}, 2)
+	reporter.ReportGauge("cron.queue", map[string]string{"reason": "a \"quoted\"\nreason"}, 5)
+	reporter.ReportTimer("cron.latency", nil, time.Millisecond*1500)
+	reporter.ReportTimer("cron.latency", nil, time.Millisecond*500)
+	buckets := tally.DurationBuckets{time.Second, time.Second * 2}
+	reporter.ReportHistogramDurationSamples("cron.duration", nil, buckets, 0, time.Second, 2)
+	reporter.ReportHistogramDurationSamples("cron.duration", nil, buckets, time.Second, time.Second*2, 1)
+	reporter.ReportHistogramValueSamples("1st.size", nil, tally.ValueBuckets{10}, 0, 10, 1)
+
+	require.Equal(t, `# TYPE _st_size histogram
+_st_size_bucket{le="10"} 1
+_st_size_bucket{le="+Inf"} 1
+_st_size_count 1
+# TYPE cron_duration histogram
+cron_duration_bucket{le="1"} 2
+cron_duration_bucket{le="2"} 3
+cron_duration_bucket{le="+Inf"} 3
+cron_duration_count 3
+# TYPE cron_latency summary
+cron_latency_sum 2
+cron_latency_count 2
+# TYPE cron_queue gauge
+cron_queue{reason="a \"quoted\"\nreason"} 5
+# TYPE cron_runs counter
+cron_runs 4
+cron_runs{workflow_id="cron_1"} 3
+# TYPE cron_runs_failed counter
+cron_runs_failed 1
+`, string(reporter.format()))
+}
@@ -0,0 +1,221 @@
+package common
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * The vendored client carries the patches in patches/go.uber.org/cadence, the replay tests here fail without them.
+ * replayHistory records the history of a workflow that only sleeps and records markers, and runs every decision task
+ * through the workflow task handler of the workers. The handler doesn't cache workflow state, so every decision task
+ * replays the history recorded so far.
+ */
+
+type replayHistory struct {
+	t                      *testing.T
+	handler                cadence.WorkflowTaskHandler
+	workflowType           string
+	events                 []*s.HistoryEvent
+	previousStartedEventID int64
+	now                    time.Time
+	// timers are the durations of the timers started by the last decision, by timer ID.
+	timers map[string]time.Duration
+}
+
+func newReplayHistory(t *testing.T, start time.Time, workflowFn interface{}) *replayHistory {
+	h := &replayHistory{
+		t:            t,
+		handler:      cadence.NewWorkflowTaskHandler("replay-domain", "replay-identity", zap.NewNop()),
+		workflowType: workflowTypeName(workflowFn),
+		now:          start,
+		timers:       make(map[string]time.Duration),
+	}
+	taskList, timeout := "replay-tasklist", int32(time.Hour.Seconds())
+	h.addEvent(s.EventType_WorkflowExecutionStarted, func(e *s.HistoryEvent) {
+		e.WorkflowExecutionStartedEventAttributes = &s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType:                        &s.WorkflowType{Name: &h.workflowType},
+			TaskList:                            &s.TaskList{Name: &taskList},
+			ExecutionStartToCloseTimeoutSeconds: &timeout,
+			TaskStartToCloseTimeoutSeconds:      &timeout,
+		}
+	})
+	return h
+}
+
+// workflowTypeName returns the name a workflow function is registered with.
+func workflowTypeName(workflowFn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(workflowFn).Pointer()).Name()
+}
+
+// run drives the workflow until it completes, and returns its result.
+func (h *replayHistory) run() []byte {
+	for {
+		if result, closed := h.decide(); closed {
+			return result
+		}
+		h.fireTimer()
+	}
+}
+
+// fireTimer fires one of the timers started by the last decision.
+func (h *replayHistory) fireTimer() {
+	require.NotEmpty(h.t, h.timers, "workflow is stuck, no timer to wake it up")
+	// the timers of a decision fire one after the other, each one in a decision of its own.
+	for id, d := range h.timers {
+		delete(h.timers, id)
+		h.now = h.now.Add(d)
+		timerID := id
+		h.addEvent(s.EventType_TimerFired, func(e *s.HistoryEvent) {
+			e.TimerFiredEventAttributes = &s.TimerFiredEventAttributes{TimerId: &timerID}
+		})
+		return
+	}
+}
+
+// processTask runs a decision task through the workflow task handler.
+func (h *replayHistory) processTask() (*s.RespondDecisionTaskCompletedRequest, error) {
+	h.addEvent(s.EventType_DecisionTaskScheduled, func(e *s.HistoryEvent) {
+		e.DecisionTaskScheduledEventAttributes = &s.DecisionTaskScheduledEventAttributes{}
+	})
+	started := h.addEvent(s.EventType_DecisionTaskStarted, func(e *s.HistoryEvent) {
+		e.DecisionTaskStartedEventAttributes = &s.DecisionTaskStartedEventAttributes{}
+	})
+	workflowID, runID := "replay-workflow", "replay-run"
+	events := make([]*s.HistoryEvent, len(h.events))
+	copy(events, h.events)
+	response, _, err := h.handler.ProcessWorkflowTask(&s.PollForDecisionTaskResponse{
+		TaskToken:              []byte("replay-task"),
+		WorkflowExecution:      &s.WorkflowExecution{WorkflowId: &workflowID, RunId: &runID},
+		WorkflowType:           &s.WorkflowType{Name: &h.workflowType},
+		PreviousStartedEventId: &h.previousStartedEventID,
+		History:                &s.History{Events: events},
+	}, false)
+	h.previousStartedEventID = started
+	return response, err
+}
+
+// decide runs a decision task and records its decisions, it returns the result once the workflow completed.
+func (h *replayHistory) decide() ([]byte, bool) {
+	response, err := h.processTask()
+	require.NoError(h.t, err)
+	h.addEvent(s.EventType_DecisionTaskCompleted, func(e *s.HistoryEvent) {
+		e.DecisionTaskCompletedEventAttributes = &s.DecisionTaskCompletedEventAttributes{}
+	})
+
+	for _, d := range response.Decisions {
+		switch d.GetDecisionType() {
+		case s.DecisionType_StartTimer:
+			attributes := d.StartTimerDecisionAttributes
+			h.timers[attributes.GetTimerId()] = time.Duration(attributes.GetStartToFireTimeoutSeconds()) * time.Second
+			h.addEvent(s.EventType_TimerStarted, func(e *s.HistoryEvent) {
+				e.TimerStartedEventAttributes = &s.TimerStartedEventAttributes{
+					TimerId:                   attributes.TimerId,
+					StartToFireTimeoutSeconds: attributes.StartToFireTimeoutSeconds,
+				}
+			})
+		case s.DecisionType_RecordMarker:
+			attributes := d.RecordMarkerDecisionAttributes
+			h.addEvent(s.EventType_MarkerRecorded, func(e *s.HistoryEvent) {
+				e.MarkerRecordedEventAttributes = &s.MarkerRecordedEventAttributes{
+					MarkerName: attributes.MarkerName,
+					Details:    attributes.Details,
+				}
+			})
+		case s.DecisionType_CompleteWorkflowExecution:
+			return d.CompleteWorkflowExecutionDecisionAttributes.Result_, true
+		default:
+			h.t.Fatalf("unsupported decision %v", d.GetDecisionType())
+		}
+	}
+	return nil, false
+}
+
+func (h *replayHistory) addEvent(eventType s.EventType, setAttributes func(e *s.HistoryEvent)) int64 {
+	id, timestamp := int64(len(h.events)+1), h.now.UnixNano()
+	event := &s.HistoryEvent{EventId: &id, Timestamp: &timestamp, EventType: &eventType}
+	setAttributes(event)
+	h.events = append(h.events, event)
+	return id
+}
+
+// decodeResult decodes the result of a workflow the way the client encodes it.
+func decodeResult(t *testing.T, result []byte, value interface{}) {
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(result)).Decode(value))
+}
+
+// replayClockWorkflow sleeps until an hour after it started in two steps, the second one is computed from the clock.
+func replayClockWorkflow(ctx cadence.Context) (time.Duration, error) {
+	deadline := cadence.Now(ctx).Add(time.Hour)
+	if err := cadence.Sleep(ctx, time.Minute*10); err != nil {
+		return 0, err
+	}
+	remaining := deadline.Sub(cadence.Now(ctx))
+	return remaining, cadence.Sleep(ctx, remaining)
+}
+
+func init() {
+	cadence.RegisterWorkflow(replayClockWorkflow)
+	cadence.RegisterWorkflow(replaySideEffectWorkflow)
+	cadence.RegisterWorkflow(replaySleepWorkflow)
+}
+
+func TestReplay_Now(t *testing.T) {
+	// the stock client reads the clock of the next decision on replay, the second timer doesn't match its history.
+	h := newReplayHistory(t, time.Unix(0, 0), replayClockWorkflow)
+	var remaining time.Duration
+	decodeResult(t, h.run(), &remaining)
+	require.Equal(t, time.Minute*50, remaining)
+}
+
+// replaySideEffectWorkflow generates a value with a side effect before it sleeps, and returns it after.
+func replaySideEffectWorkflow(ctx cadence.Context) (string, error) {
+	generated := 0
+	value, err := SideEffectString(ctx, func() string {
+		generated++
+		return fmt.Sprintf("generated %d times", generated)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value, cadence.Sleep(ctx, time.Minute)
+}
+
+func TestReplay_SideEffectString(t *testing.T) {
+	// the stock client panics on replay, the marker of the side effect is processed before its decision exists.
+	h := newReplayHistory(t, time.Unix(0, 0), replaySideEffectWorkflow)
+	var value string
+	decodeResult(t, h.run(), &value)
+	// the replay read the value from the marker instead of generating it again.
+	require.Equal(t, "generated 1 times", value)
+}
+
+// replaySleepWorkflow is replaySideEffectWorkflow without the side effect.
+func replaySleepWorkflow(ctx cadence.Context) (string, error) {
+	return "", cadence.Sleep(ctx, time.Minute)
+}
+
+func TestReplay_SideEffectRemoved(t *testing.T) {
+	h := newReplayHistory(t, time.Unix(0, 0), replaySideEffectWorkflow)
+	_, closed := h.decide()
+	require.False(t, closed)
+	h.fireTimer()
+	// the history has the marker of a side effect the workflow no longer records, the replay is nondeterministic.
+	h.workflowType = workflowTypeName(replaySleepWorkflow)
+	defer func() {
+		// the unknown decision is the marker, not the timer after it.
+		require.Contains(t, fmt.Sprint(recover()), "unknown decision DecisionType: Marker, ID: SideEffect_0")
+	}()
+	_, err := h.processTask()
+	t.Fatalf("replay of a removed side effect didn't fail, got error %v", err)
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+)
+
+/**
+ * A workflow is replayed from its history whenever a worker picks it up without it in its cache, and the replay has
+ * to make the same decisions as the original execution. Workflow code must not call time.Now, rand or uuid.New
+ * directly, they return a different value on replay, and an ID handed to an activity or kept in the state of the
+ * workflow would change under the feet of the systems that already saw it. Time is read with cadence.Now instead.
+ *
+ * NewRunID generates a random ID once with cadence.SideEffect, which records the ID in the history as a marker and
+ * returns the recorded ID on replay without calling the generator again. DeterministicRunID derives the ID from values
+ * that are already part of the history, e.g. the workflow ID and the number of a run, it needs no marker and is the
+ * same for a run that is recreated, but repeats when a workflow with the same ID starts numbering its runs over.
+ *
+ * The pinned version of the client fails to replay the markers of SideEffect without
+ * patches/go.uber.org/cadence/0002-replay-side-effect-markers.patch.
+ */
+
+// NewRunID returns a random UUID that is generated once and read from the history on replay.
+func NewRunID(ctx cadence.Context) (string, error) {
+	return SideEffectString(ctx, uuid.New)
+}
+
+// SideEffectString returns the string generate returns. It calls generate only the first time the workflow executes
+// it, a replay returns the string recorded in the history.
+func SideEffectString(ctx cadence.Context, generate func() string) (string, error) {
+	var value string
+	err := cadence.SideEffect(ctx, func(cadence.Context) interface{} {
+		return generate()
+	}).Get(&value)
+	return value, err
+}
+
+// DeterministicRunID returns a name-based UUID of the given workflow ID and sequence, the same for the same arguments.
+func DeterministicRunID(workflowID string, sequence uint) string {
+	return uuid.NewSHA1(uuid.NameSpace_URL, []byte(fmt.Sprintf("%s/%d", workflowID, sequence))).String()
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func runIDTestWorkflow(ctx cadence.Context) ([]string, error) {
+	generated := 0
+	first, err := SideEffectString(ctx, func() string {
+		generated++
+		return strconv.Itoa(generated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	random, err := NewRunID(ctx)
+	return []string{first, random}, err
+}
+
+func init() {
+	cadence.RegisterWorkflow(runIDTestWorkflow)
+}
+
+func TestSideEffectString(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(runIDTestWorkflow)
+	require.NoError(t, env.GetWorkflowError())
+	var ids []string
+	require.NoError(t, env.GetWorkflowResult(&ids))
+	require.Equal(t, "1", ids[0])
+	require.NotNil(t, uuid.Parse(ids[1]))
+}
+
+func TestDeterministicRunID(t *testing.T) {
+	id := DeterministicRunID("cron_nightly", 7)
+	require.Equal(t, id, DeterministicRunID("cron_nightly", 7))
+	require.NotNil(t, uuid.Parse(id))
+	require.NotEqual(t, id, DeterministicRunID("cron_nightly", 8))
+	require.NotEqual(t, id, DeterministicRunID("cron_hourly", 7))
+}
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"time"
 
 	"go.uber.org/cadence"
 	m "go.uber.org/cadence/.gen/go/cadence"
@@ -11,7 +14,6 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/uber-go/tally"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -26,18 +28,34 @@ type (
 		Logger  *zap.Logger
 		Config  Configuration
 		Builder *WorkflowClientBuilder
+		// ConfigFile is the path of the config file, see LoadConfiguration.
+		ConfigFile string
+		// Keyring seals the values that may hold customer data, nil if the encryption isn't configured.
+		Keyring *Keyring
+		// RegisterDomain registers the domain if it doesn't exist, as -register-domain does.
+		RegisterDomain bool
+
+		workers          []cadence.Worker
+		metricsCloser    io.Closer
+		prometheus       *prometheusServer
+		activities       *activityTracker
+		activityContext  context.Context
+		cancelActivities context.CancelFunc
 	}
 
 	// Configuration for running samples.
 	Configuration struct {
-		DomainName      string `yaml:"domain"`
-		ServiceName     string `yaml:"service"`
-		HostNameAndPort string `yaml:"host"`
+		DomainName      string                   `yaml:"domain"`
+		ServiceName     string                   `yaml:"service"`
+		HostNameAndPort string                   `yaml:"host"`
+		Metrics         *MetricsConfiguration    `yaml:"metrics"`
+		TLS             *TLSConfiguration        `yaml:"tls"`
+		Worker          *WorkerConfiguration     `yaml:"worker"`
+		Encryption      *EncryptionConfiguration `yaml:"encryption"`
+		Logging         *LoggingConfiguration    `yaml:"logging"`
 	}
 )
 
-var domainCreated bool
-
 // SetupServiceConfig setup the config for the sample code run
 func (h *SampleHelper) SetupServiceConfig() {
 	if h.Service != nil {
@@ -45,19 +63,34 @@ func (h *SampleHelper) SetupServiceConfig() {
 	}
 
 	// Initialize developer config for running samples
-	configData, err := ioutil.ReadFile(configFile)
+	config, err := LoadConfiguration(h.ConfigFile)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to log config file: %v, Error: %v", configFile, err))
+		panic(fmt.Sprintf("Error initializing configuration: %v", err))
 	}
-
-	if err := yaml.Unmarshal(configData, &h.Config); err != nil {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	config.Worker = flagsWorker.apply(config.Worker, set)
+	if err := config.Worker.validate(); err != nil {
 		panic(fmt.Sprintf("Error initializing configuration: %v", err))
 	}
+	config.TLS = flagsTLS.apply(config.TLS)
+	tlsConfig, err := config.TLS.build()
+	if err != nil {
+		panic(fmt.Sprintf("Error initializing TLS: %v", err))
+	}
+	keyring, err := config.Encryption.keyring()
+	if err != nil {
+		panic(fmt.Sprintf("Error initializing encryption: %v", err))
+	}
+	h.Config = config
+	h.Keyring = keyring
 
 	// Initialize logger for running samples
-	logger, err := zap.NewDevelopment()
+	logger, err := h.Config.Logging.logger()
 	if err != nil {
-		panic(err)
+		panic(fmt.Sprintf("Error initializing logger: %v", err))
 	}
 
 	logger.Info("Logger created.")
@@ -65,32 +98,73 @@ func (h *SampleHelper) SetupServiceConfig() {
 	h.Scope = tally.NoopScope
 	h.Builder = NewBuilder().
 		SetHostPort(h.Config.HostNameAndPort).
+		SetServiceName(h.Config.ServiceName).
 		SetDomain(h.Config.DomainName).
-		SetMetricsScope(h.Scope)
+		SetMetricsScope(h.Scope).
+		SetTLSConfig(tlsConfig)
+	if interval, _ := h.Config.metricsInterval(); interval > 0 {
+		h.EnableMetrics(interval)
+	}
+	if h.Config.Metrics != nil && h.Config.Metrics.PrometheusListenAddress != "" {
+		if err := h.EnablePrometheus(h.Config.Metrics.PrometheusListenAddress); err != nil {
+			panic(err)
+		}
+	}
 	service, err := h.Builder.BuildServiceClient()
 	if err != nil {
 		panic(err)
 	}
 	h.Service = service
 
-	if domainCreated {
-		return
+	domainClient, err := h.Builder.BuildCadenceDomainClient()
+	if err != nil {
+		panic(err)
 	}
-	domainClient, _ := h.Builder.BuildCadenceDomainClient()
-	request := &s.RegisterDomainRequest{
-		Name:                                   common.StringPtr(h.Config.DomainName),
-		Description:                            common.StringPtr("domain for cadence sample code"),
-		WorkflowExecutionRetentionPeriodInDays: common.Int32Ptr(3)}
-	err = domainClient.Register(request)
+	err = ensureDomain(domainClient, logger, h.Config.DomainName, *registerDomain || h.RegisterDomain,
+		int32(*domainRetentionDays), domainRegistrationWait)
 	if err != nil {
-		if _, ok := err.(*s.DomainAlreadyExistsError); !ok {
-			panic(err)
-		}
-		logger.Info("Domain already registered.", zap.String("Domain", h.Config.DomainName))
-	} else {
-		logger.Info("Domain succeesfully registered.", zap.String("Domain", h.Config.DomainName))
+		panic(err)
+	}
+}
+
+// EnableMetrics makes the workers and clients created from now on report their metrics to the log every interval,
+// instead of discarding them
+func (h *SampleHelper) EnableMetrics(interval time.Duration) {
+	h.setMetricsScope(tally.NewRootScope(tally.ScopeOptions{Reporter: loggingReporter{h.Logger}, Separator: "."},
+		interval))
+}
+
+// EnablePrometheus makes the workers and clients created from now on report their metrics to prometheus, served on
+// the given address at /metrics until StopWorkers. It fails if the address is in use.
+func (h *SampleHelper) EnablePrometheus(address string) error {
+	reporter := newPrometheusReporter()
+	server, err := startPrometheusServer(address, reporter)
+	if err != nil {
+		return err
+	}
+	h.setMetricsScope(tally.NewRootScope(tally.ScopeOptions{Reporter: reporter, Separator: "_"},
+		prometheusReportInterval))
+	h.prometheus = server
+	h.Logger.Info("Serving prometheus metrics.", zap.String("Address", server.Addr()+prometheusMetricsPath))
+	return nil
+}
+
+func (h *SampleHelper) setMetricsScope(scope tally.Scope, closer io.Closer) {
+	h.stopMetrics()
+	h.Scope, h.metricsCloser = scope, closer
+	h.Builder.SetMetricsScope(h.Scope)
+}
+
+// stopMetrics reports the metrics of the scope a last time, and stops the prometheus server.
+func (h *SampleHelper) stopMetrics() {
+	if h.metricsCloser != nil {
+		h.metricsCloser.Close()
+		h.metricsCloser = nil
+	}
+	if h.prometheus != nil {
+		h.prometheus.Stop()
+		h.prometheus = nil
 	}
-	domainCreated = true
 }
 
 // StartWorkflow starts a workflow
@@ -111,12 +185,119 @@ func (h *SampleHelper) StartWorkflow(options cadence.StartWorkflowOptions, workf
 	}
 }
 
-// StartWorkers starts workflow worker and activity worker based on configured options.
-func (h *SampleHelper) StartWorkers(domainName, groupName string, options cadence.WorkerOptions) {
-	worker := cadence.NewWorker(h.Service, domainName, groupName, options)
-	err := worker.Start()
+// SignalWorkflow signals a running workflow execution. It returns the error of the client, e.g.
+// *shared.EntityNotExistsError for a workflow that is not running.
+func (h *SampleHelper) SignalWorkflow(workflowID, signal string, data interface{}) error {
+	workflowClient, err := h.Builder.BuildCadenceClient()
 	if err != nil {
-		h.Logger.Error("Failed to start workers.", zap.Error(err))
-		panic("Failed to start workers")
+		return err
+	}
+
+	if err := workflowClient.SignalWorkflow(workflowID, "", signal, data); err != nil {
+		return err
+	}
+	h.Logger.Info("Signaled Workflow", zap.String("WorkflowID", workflowID), zap.String("Signal", signal))
+	return nil
+}
+
+// CancelWorkflow requests the cancellation of a running workflow execution. It returns the error of the client.
+func (h *SampleHelper) CancelWorkflow(workflowID string) error {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		return err
+	}
+
+	if err := workflowClient.CancelWorkflow(workflowID, ""); err != nil {
+		return err
+	}
+	h.Logger.Info("Cancelled Workflow", zap.String("WorkflowID", workflowID))
+	return nil
+}
+
+// ListOpenWorkflows logs the open workflow executions of the given workflow type. It returns the error of the client,
+// the executions of the pages listed before it are logged.
+func (h *SampleHelper) ListOpenWorkflows(workflowType string) error {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		return err
+	}
+
+	request := &s.ListOpenWorkflowExecutionsRequest{
+		StartTimeFilter: &s.StartTimeFilter{
+			EarliestTime: common.Int64Ptr(0),
+			LatestTime:   common.Int64Ptr(time.Now().UnixNano()),
+		},
+		TypeFilter: &s.WorkflowTypeFilter{Name: common.StringPtr(workflowType)},
+	}
+	for {
+		response, err := workflowClient.ListOpenWorkflow(request)
+		if err != nil {
+			return err
+		}
+		for _, execution := range response.Executions {
+			h.Logger.Info("Open Workflow", zap.String("WorkflowID", execution.Execution.GetWorkflowId()),
+				zap.String("RunID", execution.Execution.GetRunId()),
+				zap.Time("StartTime", time.Unix(0, execution.GetStartTime())))
+		}
+		if len(response.NextPageToken) == 0 {
+			return nil
+		}
+		request.NextPageToken = response.NextPageToken
+	}
+}
+
+// GetWorkflowInput decodes the input of the current run of a workflow execution into the given values. It returns the
+// error of the client, or an error if the input doesn't decode into the values.
+func (h *SampleHelper) GetWorkflowInput(workflowID string, valuePtrs ...interface{}) error {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		return err
+	}
+
+	history, err := workflowClient.GetWorkflowHistory(workflowID, "")
+	if err != nil {
+		return err
+	}
+	if len(history.Events) == 0 {
+		return fmt.Errorf("workflow %s has no history", workflowID)
+	}
+	input := history.Events[0].GetWorkflowExecutionStartedEventAttributes().GetInput()
+	if err := cadence.EncodedValues(input).Get(valuePtrs...); err != nil {
+		return fmt.Errorf("failed to decode the input of workflow %s: %v", workflowID, err)
+	}
+	return nil
+}
+
+// StartWorkers starts workflow worker and activity worker based on configured options, as many as the pollers of the
+// worker configuration need.
+func (h *SampleHelper) StartWorkers(domainName, groupName string, options cadence.WorkerOptions) {
+	workerOptions := h.Config.Worker.workerOptions(options)
+	logWorkerOptions(h.Logger, groupName, workerOptions)
+	for _, options := range workerOptions {
+		worker := cadence.NewWorker(h.Service, domainName, groupName, options)
+		err := worker.Start()
+		if err != nil {
+			h.Logger.Error("Failed to start workers.", zap.Error(err))
+			panic("Failed to start workers")
+		}
+		h.workers = append(h.workers, worker)
+	}
+}
+
+// StopWorkers stops the workers started by StartWorkers, the metrics of the sample, and closes the connections of its
+// clients.
+func (h *SampleHelper) StopWorkers() {
+	for _, worker := range h.workers {
+		worker.Stop()
+	}
+	h.workers = nil
+	h.stopMetrics()
+	h.closeClients()
+}
+
+// closeClients closes the connections of the clients of the Builder and its TLS tunnel.
+func (h *SampleHelper) closeClients() {
+	if h.Builder != nil {
+		h.Builder.Close()
 	}
 }
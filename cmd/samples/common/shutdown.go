@@ -0,0 +1,150 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+/**
+ * A worker of this client executes every activity in one of its poll routines, Stop ends the polling and waits for the
+ * routines for at most 10 seconds. The running activities are counted by TrackActivity, with the tracker of the
+ * background context of the activities. On SIGINT or SIGTERM the workers are stopped, so that no new tasks are polled,
+ * and the running activities get the drain timeout to complete. Then the background context is cancelled, which
+ * cancels the contexts of the activities still running, so that they fail right away instead of after their
+ * StartToClose timeout. A second signal ends the drain right away, without waiting for the cancelled activities.
+ */
+
+// activityCancelGrace is how long the activities cancelled by a drain timeout get to report their failure.
+const activityCancelGrace = 5 * time.Second
+
+type (
+	activityTrackerKey struct{}
+
+	// activityTracker counts the running activities.
+	activityTracker struct {
+		sync.Mutex
+		running int
+		// idle is closed when no activity is running.
+		idle chan struct{}
+	}
+)
+
+func newActivityTracker() *activityTracker {
+	idle := make(chan struct{})
+	close(idle)
+	return &activityTracker{idle: idle}
+}
+
+func (t *activityTracker) start() {
+	t.Lock()
+	defer t.Unlock()
+	if t.running == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.running++
+}
+
+func (t *activityTracker) done() {
+	t.Lock()
+	defer t.Unlock()
+	t.running--
+	if t.running == 0 {
+		close(t.idle)
+	}
+}
+
+// state returns the number of running activities, and a channel that is closed when none is running.
+func (t *activityTracker) state() (int, <-chan struct{}) {
+	t.Lock()
+	defer t.Unlock()
+	return t.running, t.idle
+}
+
+// TrackActivity counts the activity of the given context as running until the returned function is called, so that the
+// drain of WaitForShutdown waits for it. It does nothing for a context that isn't derived from
+// BackgroundActivityContext, e.g. in tests.
+func TrackActivity(ctx context.Context) func() {
+	tracker, ok := ctx.Value(activityTrackerKey{}).(*activityTracker)
+	if !ok {
+		return func() {}
+	}
+	tracker.start()
+	return tracker.done
+}
+
+// BackgroundActivityContext returns the context for the BackgroundActivityContext of the worker options, it is
+// cancelled when the drain of WaitForShutdown ends. It gives the Keyring to the activities.
+func (h *SampleHelper) BackgroundActivityContext() context.Context {
+	if h.activityContext == nil {
+		h.activities = newActivityTracker()
+		ctx := context.WithValue(context.Background(), activityTrackerKey{}, h.activities)
+		ctx = ContextWithKeyring(ctx, h.Keyring)
+		h.activityContext, h.cancelActivities = context.WithCancel(ctx)
+	}
+	return h.activityContext
+}
+
+// WaitForShutdown blocks until SIGINT or SIGTERM, and drains the workers. It returns true if the activities completed
+// within the drain timeout.
+func (h *SampleHelper) WaitForShutdown(drainTimeout time.Duration) bool {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	received := <-signals
+	h.Logger.Info("Shutting down the workers.", zap.String("Signal", received.String()),
+		zap.Duration("DrainTimeout", drainTimeout))
+	return h.drain(signals, drainTimeout)
+}
+
+// drain stops the workers, and waits for the running activities until the timeout or the next signal.
+func (h *SampleHelper) drain(signals <-chan os.Signal, timeout time.Duration) bool {
+	h.BackgroundActivityContext()
+	stopped := make(chan struct{})
+	go func() {
+		// the workers stop polling right away, Stop returns once their routines returned.
+		for _, worker := range h.workers {
+			worker.Stop()
+		}
+		close(stopped)
+	}()
+
+	clean := true
+	_, idle := h.activities.state()
+	select {
+	case <-idle:
+		h.Logger.Info("Workers drained.")
+		// the routines return after their current poll.
+		select {
+		case <-stopped:
+		case <-signals:
+			h.Logger.Info("Workers stop interrupted.")
+		}
+	case <-time.After(timeout):
+		running, _ := h.activities.state()
+		h.Logger.Error("Workers drain timed out, cancelling the running activities.", zap.Int("RunningActivities", running))
+		clean = false
+		h.cancelActivities()
+		// the cancelled activities report their failure before their routines return.
+		select {
+		case <-stopped:
+		case <-time.After(activityCancelGrace):
+		case <-signals:
+		}
+	case <-signals:
+		running, _ := h.activities.state()
+		h.Logger.Error("Workers drain interrupted, cancelling the running activities.",
+			zap.Int("RunningActivities", running))
+		clean = false
+	}
+	h.cancelActivities()
+	h.workers = nil
+	h.stopMetrics()
+	h.closeClients()
+	return clean
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+// stubWorker records that it was stopped.
+type stubWorker struct {
+	stopped chan struct{}
+}
+
+func newStubWorker() *stubWorker {
+	return &stubWorker{stopped: make(chan struct{})}
+}
+
+func (w *stubWorker) Start() error {
+	return nil
+}
+
+func (w *stubWorker) Stop() {
+	close(w.stopped)
+}
+
+// startSlowActivity runs an activity of the background context of the helper until it is released or cancelled, the
+// returned channel receives its error.
+func startSlowActivity(h *SampleHelper, release <-chan struct{}) <-chan error {
+	ctx := h.BackgroundActivityContext()
+	done := TrackActivity(ctx)
+	result := make(chan error, 1)
+	go func() {
+		defer done()
+		select {
+		case <-release:
+			result <- nil
+		case <-ctx.Done():
+			result <- ctx.Err()
+		}
+	}()
+	return result
+}
+
+func TestDrain_ActivitiesComplete(t *testing.T) {
+	worker := newStubWorker()
+	h := &SampleHelper{Logger: zap.NewNop(), workers: []cadence.Worker{worker}}
+	release := make(chan struct{})
+	result := startSlowActivity(h, release)
+	go func() {
+		<-worker.stopped
+		close(release)
+	}()
+
+	require.True(t, h.drain(make(chan os.Signal), time.Minute))
+	require.NoError(t, <-result)
+	require.Nil(t, h.workers)
+}
+
+func TestDrain_Timeout(t *testing.T) {
+	worker := newStubWorker()
+	h := &SampleHelper{Logger: zap.NewNop(), workers: []cadence.Worker{worker}}
+	result := startSlowActivity(h, nil)
+
+	require.False(t, h.drain(make(chan os.Signal), time.Millisecond*50))
+	// the activity still running is cancelled.
+	require.Equal(t, context.Canceled, <-result)
+	<-worker.stopped
+}
+
+func TestDrain_SecondSignal(t *testing.T) {
+	h := &SampleHelper{Logger: zap.NewNop(), workers: []cadence.Worker{newStubWorker()}}
+	result := startSlowActivity(h, nil)
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGINT
+
+	start := time.Now()
+	require.False(t, h.drain(signals, time.Minute))
+	require.True(t, time.Since(start) < time.Second*5)
+	require.Equal(t, context.Canceled, <-result)
+}
+
+func TestTrackActivity_WithoutTracker(t *testing.T) {
+	// an activity context of a test environment has no tracker.
+	TrackActivity(context.Background())()
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	// a signal sent before WaitForShutdown listens must not kill the test.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGTERM)
+	defer signal.Stop(guard)
+
+	worker := newStubWorker()
+	h := &SampleHelper{Logger: zap.NewNop(), workers: []cadence.Worker{worker}}
+	release := make(chan struct{})
+	result := startSlowActivity(h, release)
+	clean := make(chan bool)
+	go func() {
+		clean <- h.WaitForShutdown(time.Minute)
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	<-worker.stopped
+	// the worker waits for the running activity.
+	select {
+	case <-clean:
+		t.Fatal("shutdown didn't wait for the running activity")
+	case <-time.After(time.Millisecond * 100):
+	}
+	close(release)
+	require.True(t, <-clean)
+	require.NoError(t, <-result)
+}
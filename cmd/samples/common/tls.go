@@ -0,0 +1,327 @@
+package common
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/**
+ * The TChannel transport of this client dials plain TCP and has no option for TLS. A frontend behind TLS is reached
+ * through a tunnel: the client connects to a local port, and every connection to it is forwarded to the frontend over
+ * TLS. The tunnel shakes hands with the frontend once when it starts, so that a bad certificate fails the sample at
+ * startup with the error of the handshake, instead of as connection resets of the calls later.
+ *
+ * The port of the tunnel is open to every process of the host, and a connection to it is forwarded with the client
+ * certificate of the sample. The tunnel forwards only the connections of its own process: it looks the socket of the
+ * peer up in /proc/self/net/tcp and in the descriptors of the process, and closes the connection if the socket is not
+ * one of them. Where there is no /proc, e.g. on macOS, the peer can't be checked and any local process can call the
+ * frontend with the identity of the sample while it runs. The tunnel is closed with the WorkflowClientBuilder.
+ */
+
+const (
+	// tlsCAEnv, tlsCertEnv and tlsKeyEnv hold the PEM encoded CA bundle, client certificate and key, instead of files.
+	tlsCAEnv   = "CADENCE_SAMPLES_TLS_CA"
+	tlsCertEnv = "CADENCE_SAMPLES_TLS_CERT"
+	tlsKeyEnv  = "CADENCE_SAMPLES_TLS_KEY"
+
+	tlsHandshakeTimeout = 5 * time.Second
+	tlsProbeReadTimeout = 200 * time.Millisecond
+
+	// procNetTCP lists the IPv4 TCP sockets of the network namespace of the process, procFDs its descriptors.
+	procNetTCP = "/proc/self/net/tcp"
+	procFDs    = "/proc/self/fd"
+)
+
+type (
+	// TLSConfiguration configures TLS to the frontend, TLS is used if the configuration is present. The CA bundle
+	// verifies the frontend, the system roots are used without it. The client certificate and key are for frontends
+	// that require client certificates.
+	TLSConfiguration struct {
+		CAFile             string `yaml:"caFile"`
+		CertFile           string `yaml:"certFile"`
+		KeyFile            string `yaml:"keyFile"`
+		ServerName         string `yaml:"serverName"`
+		InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+		// CA, Cert and Key are the PEM material set by the environment, they win over the files.
+		CA   string `yaml:"-"`
+		Cert string `yaml:"-"`
+		Key  string `yaml:"-"`
+	}
+
+	// tlsFlags are the TLS flags shared by all the samples, they win over the config file and the environment.
+	tlsFlags struct {
+		enabled, insecureSkipVerify           *bool
+		caFile, certFile, keyFile, serverName *string
+	}
+)
+
+var flagsTLS = tlsFlags{
+	enabled:            flag.Bool("tls", false, "Connect to the frontend over TLS, implied by the other -tls flags."),
+	caFile:             flag.String("tls-ca", "", "Path of the CA bundle that verifies the frontend."),
+	certFile:           flag.String("tls-cert", "", "Path of the client certificate, for frontends that require one."),
+	keyFile:            flag.String("tls-key", "", "Path of the key of the client certificate."),
+	serverName:         flag.String("tls-server-name", "", "Name the certificate of the frontend is verified for, default is the host."),
+	insecureSkipVerify: flag.Bool("tls-insecure-skip-verify", false, "Don't verify the certificate of the frontend, for tests only."),
+}
+
+// tlsFromEnv adds the PEM material set by the environment to the given configuration, and returns it. Material in the
+// environment enables TLS.
+func tlsFromEnv(config *TLSConfiguration) *TLSConfiguration {
+	ca, cert, key := os.Getenv(tlsCAEnv), os.Getenv(tlsCertEnv), os.Getenv(tlsKeyEnv)
+	if ca == "" && cert == "" && key == "" {
+		return config
+	}
+	if config == nil {
+		config = &TLSConfiguration{}
+	}
+	if ca != "" {
+		config.CA = ca
+	}
+	if cert != "" {
+		config.Cert = cert
+	}
+	if key != "" {
+		config.Key = key
+	}
+	return config
+}
+
+// apply overrides the given configuration with the flags that are set, and returns it. A set flag enables TLS.
+func (f tlsFlags) apply(config *TLSConfiguration) *TLSConfiguration {
+	if !*f.enabled && !*f.insecureSkipVerify && *f.caFile == "" && *f.certFile == "" && *f.keyFile == "" &&
+		*f.serverName == "" {
+		return config
+	}
+	if config == nil {
+		config = &TLSConfiguration{}
+	}
+	if *f.caFile != "" {
+		config.CAFile, config.CA = *f.caFile, ""
+	}
+	if *f.certFile != "" {
+		config.CertFile, config.Cert = *f.certFile, ""
+	}
+	if *f.keyFile != "" {
+		config.KeyFile, config.Key = *f.keyFile, ""
+	}
+	if *f.serverName != "" {
+		config.ServerName = *f.serverName
+	}
+	config.InsecureSkipVerify = config.InsecureSkipVerify || *f.insecureSkipVerify
+	return config
+}
+
+// build returns the tls.Config of the configuration, nil if TLS isn't configured. The errors name the file or the
+// variable that failed to load.
+func (c *TLSConfiguration) build() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	ca, caSource, err := c.material(c.CA, tlsCAEnv, c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if ca != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no PEM certificates found in the CA bundle of %s", caSource)
+		}
+		config.RootCAs = pool
+	}
+
+	cert, certSource, err := c.material(c.Cert, tlsCertEnv, c.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	key, keySource, err := c.material(c.Key, tlsKeyEnv, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if (cert == nil) != (key == nil) {
+		return nil, errors.New("the client certificate and its key must be set together, set both the cert and the key")
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate of %s with the key of %s: %v", certSource,
+				keySource, err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+// material returns the PEM material of the environment if set, or reads the file, with a description of its source.
+func (c *TLSConfiguration) material(pem, env, path string) ([]byte, string, error) {
+	if pem != "" {
+		return []byte(pem), env, nil
+	}
+	if path == "" {
+		return nil, "", nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to read TLS file %s: %v", path, err)
+	}
+	return data, "file " + path, nil
+}
+
+// tlsTunnel forwards the connections to a local port to hostPort over TLS.
+type tlsTunnel struct {
+	listener net.Listener
+	hostPort string
+	config   *tls.Config
+}
+
+// startTLSTunnel checks that a TLS connection to hostPort can be established with the given config, and starts the
+// tunnel to it.
+func startTLSTunnel(hostPort string, config *tls.Config) (*tlsTunnel, error) {
+	tunnel := &tlsTunnel{hostPort: hostPort, config: config}
+	conn, err := tunnel.dial()
+	if err == nil {
+		// with TLS 1.3, a rejected client certificate is reported after the handshake, on the first read.
+		conn.SetReadDeadline(time.Now().Add(tlsProbeReadTimeout))
+		_, err = conn.Read(make([]byte, 1))
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			err = nil
+		}
+		conn.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("TLS connection to %s failed: %v", hostPort, err)
+	}
+	tunnel.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go tunnel.serve()
+	return tunnel, nil
+}
+
+// Addr returns the local address that is forwarded over TLS.
+func (t *tlsTunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Close stops accepting connections, the forwarded connections are closed by their ends.
+func (t *tlsTunnel) Close() error {
+	return t.listener.Close()
+}
+
+func (t *tlsTunnel) dial() (*tls.Conn, error) {
+	return tls.DialWithDialer(&net.Dialer{Timeout: tlsHandshakeTimeout}, "tcp", t.hostPort, t.config)
+}
+
+func (t *tlsTunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		own, err := ownsSocket(local.RemoteAddr(), local.LocalAddr())
+		if os.IsNotExist(err) {
+			// there is no /proc to check the peer with.
+			own, err = true, nil
+		}
+		if err != nil || !own {
+			local.Close()
+			continue
+		}
+		go t.forward(local)
+	}
+}
+
+func (t *tlsTunnel) forward(local net.Conn) {
+	defer local.Close()
+	remote, err := t.dial()
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	// either side closing ends the connection.
+	<-done
+}
+
+// ownsSocket returns true if the process has the TCP socket from the local to the remote address open. It returns the
+// error of reading /proc, which satisfies os.IsNotExist where there is none.
+func ownsSocket(local, remote net.Addr) (bool, error) {
+	inode, err := socketInode(local, remote)
+	if err != nil || inode == "" {
+		return false, err
+	}
+	dir, err := os.Open(procFDs)
+	if err != nil {
+		return false, err
+	}
+	defer dir.Close()
+	fds, err := dir.Readdirnames(-1)
+	if err != nil {
+		return false, err
+	}
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join(procFDs, fd)); err == nil && target == "socket:["+inode+"]" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// socketInode returns the inode of the TCP socket from the local to the remote address in procNetTCP, empty if there
+// is none.
+func socketInode(local, remote net.Addr) (string, error) {
+	localAddress, remoteAddress := procAddress(local), procAddress(remote)
+	if localAddress == "" || remoteAddress == "" {
+		return "", nil
+	}
+	file, err := os.Open(procNetTCP)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 9 && fields[1] == localAddress && fields[2] == remoteAddress {
+			return fields[9], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// procAddress returns the IPv4 TCP address the way procNetTCP lists it, the address in host byte order of a little
+// endian host and the port, both in hex. It returns empty for other addresses.
+func procAddress(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || tcp.IP.To4() == nil {
+		return ""
+	}
+	ip := tcp.IP.To4()
+	return fmt.Sprintf("%02X%02X%02X%02X:%04X", ip[3], ip[2], ip[1], ip[0], tcp.Port)
+}
@@ -0,0 +1,269 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testPKI is a CA with a server certificate for 127.0.0.1 and a client certificate, PEM encoded.
+type testPKI struct {
+	ca, serverCert, serverKey, clientCert, clientKey string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "samples-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, ips ...net.IP) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "samples"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  ips,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	}
+	pki := testPKI{ca: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))}
+	pki.serverCert, pki.serverKey = issue(2, x509.ExtKeyUsageServerAuth, net.ParseIP("127.0.0.1"))
+	pki.clientCert, pki.clientKey = issue(3, x509.ExtKeyUsageClientAuth)
+	return pki
+}
+
+// writeFile writes the content to a file of a temporary directory, and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// startEchoServer starts a TLS server that requires a client certificate of the CA, and echoes what it reads.
+func startEchoServer(t *testing.T, pki testPKI) string {
+	pair, err := tls.X509KeyPair([]byte(pki.serverCert), []byte(pki.serverKey))
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(pki.ca))
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestLoadConfiguration_TLS(t *testing.T) {
+	config, err := LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\ntls:\n  caFile: \"ca.pem\"\n"+
+		"  certFile: \"client.pem\"\n  keyFile: \"client-key.pem\"\n  serverName: \"cadence.internal\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, &TLSConfiguration{CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client-key.pem",
+		ServerName: "cadence.internal"}, config.TLS)
+
+	config, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\n"))
+	require.NoError(t, err)
+	require.Nil(t, config.TLS)
+
+	// the material of the environment enables TLS without a tls section.
+	t.Setenv(tlsCAEnv, "ca-pem")
+	t.Setenv(tlsCertEnv, "cert-pem")
+	t.Setenv(tlsKeyEnv, "key-pem")
+	config, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, &TLSConfiguration{CA: "ca-pem", Cert: "cert-pem", Key: "key-pem"}, config.TLS)
+}
+
+func TestTLSFlags(t *testing.T) {
+	enabled, insecure, none := false, false, ""
+	caFile, serverName := "flag-ca.pem", "cadence.internal"
+	flags := tlsFlags{enabled: &enabled, insecureSkipVerify: &insecure, caFile: &none, certFile: &none,
+		keyFile: &none, serverName: &none}
+	require.Nil(t, flags.apply(nil))
+
+	enabled = true
+	require.Equal(t, &TLSConfiguration{}, flags.apply(nil))
+
+	flags.caFile, flags.serverName, insecure = &caFile, &serverName, true
+	require.Equal(t, &TLSConfiguration{CAFile: "flag-ca.pem", CertFile: "client.pem", ServerName: "cadence.internal",
+		InsecureSkipVerify: true}, flags.apply(&TLSConfiguration{CA: "env-ca", CertFile: "client.pem"}))
+}
+
+func TestTLSConfiguration_Build(t *testing.T) {
+	pki := newTestPKI(t)
+	var none *TLSConfiguration
+	config, err := none.build()
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	// TLS without a CA bundle verifies the frontend with the system roots.
+	config, err = (&TLSConfiguration{ServerName: "cadence.internal"}).build()
+	require.NoError(t, err)
+	require.Nil(t, config.RootCAs)
+	require.Empty(t, config.Certificates)
+	require.Equal(t, "cadence.internal", config.ServerName)
+	require.False(t, config.InsecureSkipVerify)
+
+	config, err = (&TLSConfiguration{CAFile: writeFile(t, "ca.pem", pki.ca),
+		CertFile: writeFile(t, "client.pem", pki.clientCert), KeyFile: writeFile(t, "client-key.pem", pki.clientKey),
+		InsecureSkipVerify: true}).build()
+	require.NoError(t, err)
+	require.NotNil(t, config.RootCAs)
+	require.Len(t, config.Certificates, 1)
+	require.True(t, config.InsecureSkipVerify)
+
+	// the material of the environment wins over the files.
+	config, err = (&TLSConfiguration{CA: pki.ca, CAFile: "missing.pem", Cert: pki.clientCert, Key: pki.clientKey}).build()
+	require.NoError(t, err)
+	require.NotNil(t, config.RootCAs)
+	require.Len(t, config.Certificates, 1)
+}
+
+func TestTLSConfiguration_BuildErrors(t *testing.T) {
+	pki := newTestPKI(t)
+	_, err := (&TLSConfiguration{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).build()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read TLS file")
+
+	_, err = (&TLSConfiguration{CA: "not a certificate"}).build()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no PEM certificates found in the CA bundle of "+tlsCAEnv)
+
+	_, err = (&TLSConfiguration{Cert: pki.clientCert}).build()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must be set together")
+
+	_, err = (&TLSConfiguration{KeyFile: writeFile(t, "client-key.pem", pki.clientKey)}).build()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must be set together")
+
+	// the key of another certificate.
+	_, err = (&TLSConfiguration{Cert: pki.clientCert, Key: pki.serverKey}).build()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to load the client certificate of "+tlsCertEnv)
+}
+
+func TestTLSTunnel(t *testing.T) {
+	pki := newTestPKI(t)
+	hostPort := startEchoServer(t, pki)
+	config, err := (&TLSConfiguration{CA: pki.ca, Cert: pki.clientCert, Key: pki.clientKey}).build()
+	require.NoError(t, err)
+	tunnel, err := startTLSTunnel(hostPort, config)
+	require.NoError(t, err)
+	defer tunnel.Close()
+
+	// the plain connection to the tunnel reaches the server over TLS.
+	conn, err := net.Dial("tcp", tunnel.Addr())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(reply))
+}
+
+func TestTLSTunnel_OwnConnections(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	own, err := ownsSocket(conn.LocalAddr(), conn.RemoteAddr())
+	if os.IsNotExist(err) {
+		t.Skip("no /proc to look the sockets up in")
+	}
+	require.NoError(t, err)
+	require.True(t, own)
+	// no socket of the process, e.g. the connection of another process, connects from this port.
+	other := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: conn.LocalAddr().(*net.TCPAddr).Port + 1}
+	own, err = ownsSocket(other, conn.RemoteAddr())
+	require.NoError(t, err)
+	require.False(t, own)
+}
+
+func TestWorkflowClientBuilder_CloseTunnel(t *testing.T) {
+	pki := newTestPKI(t)
+	hostPort := startEchoServer(t, pki)
+	config, err := (&TLSConfiguration{CA: pki.ca, Cert: pki.clientCert, Key: pki.clientKey}).build()
+	require.NoError(t, err)
+	builder := NewBuilder().SetHostPort(hostPort).SetTLSConfig(config)
+	_, err = builder.BuildServiceClient()
+	require.NoError(t, err)
+	addr := builder.tunnel.Addr()
+
+	builder.Close()
+	_, err = net.Dial("tcp", addr)
+	require.Error(t, err)
+	builder.Close()
+}
+
+func TestTLSTunnel_Errors(t *testing.T) {
+	pki := newTestPKI(t)
+	hostPort := startEchoServer(t, pki)
+
+	// the server isn't verified with the system roots.
+	config, err := (&TLSConfiguration{Cert: pki.clientCert, Key: pki.clientKey}).build()
+	require.NoError(t, err)
+	_, err = startTLSTunnel(hostPort, config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "TLS connection to "+hostPort+" failed")
+
+	// the server requires a client certificate.
+	config, err = (&TLSConfiguration{CA: pki.ca}).build()
+	require.NoError(t, err)
+	_, err = startTLSTunnel(hostPort, config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "TLS connection to "+hostPort+" failed")
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A worker of this client polls with 2 routines for decision tasks and 2 for activity tasks, and every routine
+ * executes the task it polled before polling again, so the pollers are also the limit of the concurrent executions.
+ * The worker options have no poller counts, more pollers are more workers in the process: a worker per 2 pollers,
+ * without the activity worker or the workflow worker if one kind needs fewer of them. The concurrent activities and
+ * decision tasks, the rates of the worker and of the task list, and the sticky execution are options of newer
+ * clients, they are rejected. This version of the client has MaxConcurrentActivityExecutionSize and
+ * MaxActivityExecutionRate in its worker options, but it only defaults them and never reads them.
+ */
+
+// pollersPerWorker are the poll routines of a worker for every kind of task.
+const pollersPerWorker = 2
+
+type (
+	// WorkerConfiguration tunes the workers of the samples. The pollers are 2 of every kind if not set.
+	WorkerConfiguration struct {
+		ActivityPollers *int `yaml:"activityPollers"`
+		DecisionPollers *int `yaml:"decisionPollers"`
+		// not supported by this version of the client.
+		MaxConcurrentActivityExecutionSize     int     `yaml:"maxConcurrentActivityExecutionSize"`
+		WorkerActivitiesPerSecond              float64 `yaml:"workerActivitiesPerSecond"`
+		MaxConcurrentDecisionTaskExecutionSize int     `yaml:"maxConcurrentDecisionTaskExecutionSize"`
+		TaskListActivitiesPerSecond            float64 `yaml:"taskListActivitiesPerSecond"`
+		DisableStickyExecution                 bool    `yaml:"disableStickyExecution"`
+		StickyCacheSize                        int     `yaml:"stickyCacheSize"`
+	}
+
+	// workerFlags are the worker flags shared by all the samples, they win over the config file.
+	workerFlags struct {
+		activityPollers, decisionPollers *int
+	}
+)
+
+var flagsWorker = workerFlags{
+	activityPollers: flag.Int("activityPollers", pollersPerWorker, "Activity pollers of the worker, every poller executes one activity at a time."),
+	decisionPollers: flag.Int("decisionPollers", pollersPerWorker, "Decision pollers of the worker, every poller executes one decision task at a time."),
+}
+
+// apply overrides the given configuration with the flags of the given names that are set on the command line, and
+// returns it.
+func (f workerFlags) apply(config *WorkerConfiguration, set map[string]bool) *WorkerConfiguration {
+	if !set["activityPollers"] && !set["decisionPollers"] {
+		return config
+	}
+	if config == nil {
+		config = &WorkerConfiguration{}
+	}
+	if set["activityPollers"] {
+		pollers := *f.activityPollers
+		config.ActivityPollers = &pollers
+	}
+	if set["decisionPollers"] {
+		pollers := *f.decisionPollers
+		config.DecisionPollers = &pollers
+	}
+	return config
+}
+
+// pollers returns the activity and the decision pollers, 2 of every kind if not set.
+func (c *WorkerConfiguration) pollers() (int, int) {
+	activityPollers, decisionPollers := pollersPerWorker, pollersPerWorker
+	if c != nil && c.ActivityPollers != nil {
+		activityPollers = *c.ActivityPollers
+	}
+	if c != nil && c.DecisionPollers != nil {
+		decisionPollers = *c.DecisionPollers
+	}
+	return activityPollers, decisionPollers
+}
+
+// validate returns an error for options that are invalid, contradict each other, or aren't supported.
+func (c *WorkerConfiguration) validate() error {
+	if c == nil {
+		return nil
+	}
+	switch {
+	case c.MaxConcurrentActivityExecutionSize != 0:
+		return errors.New("worker maxConcurrentActivityExecutionSize is not supported by this version of the " +
+			"cadence client, set activityPollers, every poller executes one activity at a time")
+	case c.WorkerActivitiesPerSecond != 0:
+		return errors.New("worker workerActivitiesPerSecond is not supported by this version of the cadence client")
+	case c.MaxConcurrentDecisionTaskExecutionSize != 0:
+		return errors.New("worker maxConcurrentDecisionTaskExecutionSize is not supported by this version of the " +
+			"cadence client, set decisionPollers, every poller executes one decision task at a time")
+	case c.TaskListActivitiesPerSecond != 0:
+		return errors.New("worker taskListActivitiesPerSecond is not supported by this version of the cadence client")
+	case c.DisableStickyExecution || c.StickyCacheSize != 0:
+		return errors.New("worker sticky execution is not supported by this version of the cadence client, " +
+			"it never caches workflows")
+	}
+	activityPollers, decisionPollers := c.pollers()
+	if activityPollers <= 0 {
+		return fmt.Errorf("worker activityPollers must be positive, got %d", activityPollers)
+	}
+	if decisionPollers <= 0 {
+		return fmt.Errorf("worker decisionPollers must be positive, got %d", decisionPollers)
+	}
+	return nil
+}
+
+// workerOptions returns the options of the workers to start for the configuration, based on the given options, which
+// the configuration overrides where it is set. The workers have pollersPerWorker pollers of every kind, the pollers are
+// rounded up.
+func (c *WorkerConfiguration) workerOptions(base cadence.WorkerOptions) []cadence.WorkerOptions {
+	activityPollers, decisionPollers := c.pollers()
+	activityWorkers := (activityPollers + pollersPerWorker - 1) / pollersPerWorker
+	decisionWorkers := (decisionPollers + pollersPerWorker - 1) / pollersPerWorker
+	var options []cadence.WorkerOptions
+	for i := 0; i < activityWorkers || i < decisionWorkers; i++ {
+		worker := base
+		worker.DisableActivityWorker = base.DisableActivityWorker || i >= activityWorkers
+		worker.DisableWorkflowWorker = base.DisableWorkflowWorker || i >= decisionWorkers
+		options = append(options, worker)
+	}
+	return options
+}
+
+// logWorkerOptions logs the effective options of the workers.
+func logWorkerOptions(logger *zap.Logger, taskList string, options []cadence.WorkerOptions) {
+	var activityPollers, decisionPollers int
+	for _, worker := range options {
+		if !worker.DisableActivityWorker {
+			activityPollers += pollersPerWorker
+		}
+		if !worker.DisableWorkflowWorker {
+			decisionPollers += pollersPerWorker
+		}
+	}
+	logger.Info("Starting workers.", zap.String("TaskList", taskList), zap.Int("Workers", len(options)),
+		zap.Int("ActivityPollers", activityPollers), zap.Int("DecisionPollers", decisionPollers),
+		zap.Bool("AutoHeartBeat", options[0].AutoHeartBeat), zap.String("Identity", options[0].Identity))
+}
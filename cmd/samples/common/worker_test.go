@@ -0,0 +1,87 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestWorkerConfiguration_Defaults(t *testing.T) {
+	base := cadence.WorkerOptions{Identity: "samples"}
+	var config *WorkerConfiguration
+	require.NoError(t, config.validate())
+	// one worker with both kinds of pollers, as without the configuration.
+	require.Equal(t, []cadence.WorkerOptions{base}, config.workerOptions(base))
+	require.Equal(t, []cadence.WorkerOptions{base}, (&WorkerConfiguration{}).workerOptions(base))
+	// a sample's own options are passed through, the configuration has no fields for them.
+	base.MaxActivityExecutionRate = 0.5
+	base.MaxConcurrentActivityExecutionSize = 3
+	require.Equal(t, []cadence.WorkerOptions{base}, (&WorkerConfiguration{ActivityPollers: intPtr(2)}).workerOptions(base))
+}
+
+func TestWorkerConfiguration_WorkerOptions(t *testing.T) {
+	base := cadence.WorkerOptions{Identity: "samples"}
+	config := &WorkerConfiguration{ActivityPollers: intPtr(5), DecisionPollers: intPtr(2)}
+	require.NoError(t, config.validate())
+	activityOnly := base
+	activityOnly.DisableWorkflowWorker = true
+	// the 5 activity pollers are rounded up to 3 workers, only the first one has decision pollers.
+	require.Equal(t, []cadence.WorkerOptions{base, activityOnly, activityOnly}, config.workerOptions(base))
+
+	config = &WorkerConfiguration{ActivityPollers: intPtr(1), DecisionPollers: intPtr(4)}
+	decisionOnly := base
+	decisionOnly.DisableActivityWorker = true
+	require.Equal(t, []cadence.WorkerOptions{base, decisionOnly}, config.workerOptions(base))
+}
+
+func TestWorkerConfiguration_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		config  WorkerConfiguration
+		message string
+	}{
+		{WorkerConfiguration{ActivityPollers: intPtr(0)}, "activityPollers must be positive"},
+		{WorkerConfiguration{DecisionPollers: intPtr(-1)}, "decisionPollers must be positive"},
+		// the client only defaults these two, it never reads them.
+		{WorkerConfiguration{MaxConcurrentActivityExecutionSize: 100}, "not supported"},
+		{WorkerConfiguration{WorkerActivitiesPerSecond: 10}, "not supported"},
+		{WorkerConfiguration{MaxConcurrentDecisionTaskExecutionSize: 10}, "not supported"},
+		{WorkerConfiguration{TaskListActivitiesPerSecond: 10}, "not supported"},
+		{WorkerConfiguration{DisableStickyExecution: true}, "not supported"},
+		{WorkerConfiguration{StickyCacheSize: 10}, "not supported"},
+	} {
+		err := tc.config.validate()
+		require.Error(t, err, "%+v", tc.config)
+		require.Contains(t, err.Error(), tc.message)
+	}
+}
+
+func TestWorkerFlags(t *testing.T) {
+	activityPollers, decisionPollers := 8, 2
+	flags := workerFlags{activityPollers: &activityPollers, decisionPollers: &decisionPollers}
+	require.Nil(t, flags.apply(nil, map[string]bool{"m": true}))
+
+	// the flags that are set win over the config file, the others keep its values.
+	config := flags.apply(&WorkerConfiguration{ActivityPollers: intPtr(3), DecisionPollers: intPtr(4)},
+		map[string]bool{"activityPollers": true})
+	require.Equal(t, &WorkerConfiguration{ActivityPollers: intPtr(8), DecisionPollers: intPtr(4)}, config)
+}
+
+func TestLoadConfiguration_Worker(t *testing.T) {
+	config, err := LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nworker:\n  activityPollers: 6\n"))
+	require.NoError(t, err)
+	require.Equal(t, &WorkerConfiguration{ActivityPollers: intPtr(6)}, config.Worker)
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nworker:\n  decisionPollers: 0\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decisionPollers must be positive")
+
+	_, err = LoadConfiguration(writeConfig(t, "domain: \"samples-domain\"\nworker:\n"+
+		"  maxConcurrentActivityExecutionSize: 100\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "maxConcurrentActivityExecutionSize is not supported")
+}
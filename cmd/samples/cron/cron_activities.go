@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The cron workflow runs the job activity its schedule names, a new kind of job is a new activity registered with the
+ * worker instead of new workflow code. The JobActivityName of a ScheduleSpec and the ActivityName of a JobSpec name one
+ * of the jobActivities, and the workflow executes it by the name it is registered with. Every job activity takes a
+ * CronJobInput, whose JobInput is the JSON JobInput of the spec, and returns a CronJobResult. The job specific result
+ * is the JSON Payload of the result, the workflow keeps it in the records of the recent runs without reading it.
+ *
+ * This version of the client registers an activity with the name of its function, it has no
+ * RegisterActivityWithOptions to give it another one. The job activities are registered by the activity workers, init
+ * maps their names to the names of their functions. The starter rejects a name that isn't one of them: the workflow would
+ * schedule an activity that no worker executes, and the run would only fail with a ScheduleToStart timeout.
+ */
+
+// defaultJobActivity is the job activity of a schedule or a job without an activity name.
+const defaultJobActivity = "sample"
+
+// jobActivities are the activities that run cron jobs, by the name the schedules refer to them with.
+var jobActivities = map[string]interface{}{
+	defaultJobActivity: sampleCronActivity,
+	"report":           reportJobActivity,
+	"archive":          archiveJobActivity,
+	longJobActivity:    longRunningJobActivity,
+}
+
+// longJobActivity is the name of longRunningJobActivity.
+const longJobActivity = "long"
+
+// The time an item of longRunningJobActivity takes, and how often it heartbeats.
+var (
+	longJobItemDuration      = time.Second
+	longJobHeartbeatInterval = time.Second * 5
+)
+
+// jobActivityTypes maps the names of the job activities to the names they are registered with.
+var jobActivityTypes = make(map[string]string)
+
+type (
+	// reportJobInput is the JobInput of reportJobActivity.
+	reportJobInput struct {
+		Report string `json:"report"`
+		Days   int    `json:"days"`
+	}
+
+	// reportJobResult is the Payload of the result of reportJobActivity.
+	reportJobResult struct {
+		Report string `json:"report"`
+		Rows   int    `json:"rows"`
+	}
+
+	// archiveJobInput is the JobInput of archiveJobActivity.
+	archiveJobInput struct {
+		OlderThanDays int `json:"olderThanDays"`
+	}
+
+	// archiveJobResult is the Payload of the result of archiveJobActivity.
+	archiveJobResult struct {
+		Archived int `json:"archived"`
+	}
+
+	// longJobInput is the JobInput of longRunningJobActivity.
+	longJobInput struct {
+		Items int `json:"items"`
+	}
+
+	// longJobProgress is the Payload of the result of longRunningJobActivity, and the details of its CanceledError.
+	longJobProgress struct {
+		Processed int `json:"processed"`
+		Items     int `json:"items"`
+	}
+)
+
+func init() {
+	for name, activity := range jobActivities {
+		jobActivityTypes[name] = runtime.FuncForPC(reflect.ValueOf(activity).Pointer()).Name()
+	}
+}
+
+func knownJobActivities() []string {
+	var names []string
+	for name := range jobActivityTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jobActivityType returns the name the job activity with the given name is registered with, an empty name is the
+// defaultJobActivity.
+func jobActivityType(name string) string {
+	if name == "" {
+		name = defaultJobActivity
+	}
+	return jobActivityTypes[name]
+}
+
+func checkJobActivity(name string) error {
+	if jobActivityType(name) == "" {
+		return fmt.Errorf("unknown job activity %q, known job activities are %s", name,
+			strings.Join(knownJobActivities(), ", "))
+	}
+	return nil
+}
+
+// validateJobActivities checks that the spec names known job activities and that its JobInput is JSON.
+func (s *ScheduleSpec) validateJobActivities() error {
+	if err := checkJobActivity(s.JobActivityName); err != nil {
+		return err
+	}
+	if len(s.JobInput) > 0 && !json.Valid(s.JobInput) {
+		return fmt.Errorf("job input %q is not JSON", s.JobInput)
+	}
+	for _, job := range s.Jobs {
+		if err := checkJobActivity(job.ActivityName); err != nil {
+			return fmt.Errorf("job %q: %v", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// jobActivity returns the name the job activity of the spec is registered with.
+func (s *ScheduleSpec) jobActivity() string {
+	return jobActivityType(s.JobActivityName)
+}
+
+// jobInput returns the JobInput of the runs of the spec. A sealed JobInput is a JSON string, it is passed on as the
+// sealed value.
+func (s *ScheduleSpec) jobInput() string {
+	var sealed string
+	if err := json.Unmarshal(s.JobInput, &sealed); err == nil && common.IsSealed(sealed) {
+		return sealed
+	}
+	return string(s.JobInput)
+}
+
+// openJobInput opens the JobInput of the job activity and decodes it into the given pointer, an empty JobInput leaves
+// it as it is.
+func openJobInput(ctx context.Context, input CronJobInput, valuePtr interface{}) error {
+	jobInput, err := common.ActivityKeyring(ctx).Open(input.JobInput)
+	if err != nil {
+		return cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	if jobInput == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(jobInput), valuePtr); err != nil {
+		return cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	return nil
+}
+
+// jobResult returns the CronJobResult of a job activity with the given payload.
+func jobResult(payload interface{}) (CronJobResult, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return CronJobResult{}, err
+	}
+	return CronJobResult{Payload: data}, nil
+}
+
+// reportJobActivity generates a report over the last days.
+func reportJobActivity(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+	defer common.TrackActivity(ctx)()
+	ctx = withActivityTraceID(ctx, input.TraceID)
+	jobInput := reportJobInput{Report: "daily", Days: 1}
+	if err := openJobInput(ctx, input, &jobInput); err != nil {
+		return CronJobResult{}, err
+	}
+	// ...
+	rows := jobInput.Days * 24
+	activityLogger(ctx).Info("Report generated.", zap.String("Report", jobInput.Report), zap.Int("Rows", rows))
+	return jobResult(reportJobResult{Report: jobInput.Report, Rows: rows})
+}
+
+// archiveJobActivity archives the records older than some days.
+func archiveJobActivity(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+	defer common.TrackActivity(ctx)()
+	ctx = withActivityTraceID(ctx, input.TraceID)
+	jobInput := archiveJobInput{OlderThanDays: 30}
+	if err := openJobInput(ctx, input, &jobInput); err != nil {
+		return CronJobResult{}, err
+	}
+	if jobInput.OlderThanDays <= 0 {
+		return CronJobResult{}, cadence.NewErrorWithDetails(errReasonInvalidInput, "olderThanDays must be positive")
+	}
+	// ...
+	archived := 1000 / jobInput.OlderThanDays
+	activityLogger(ctx).Info("Records archived.", zap.Int("OlderThanDays", jobInput.OlderThanDays),
+		zap.Int("Archived", archived))
+	return jobResult(archiveJobResult{Archived: archived})
+}
+
+// longRunningJobActivity processes items for a while, and stops at the next item once it is cancelled: a run skipped
+// with the skipRun signal or a cancelled workflow. The workflow can't interrupt an activity, it requests the
+// cancellation from the server, and the activity learns about it with its next heartbeat, which cancels its context. It
+// returns a CanceledError with the progress of the run as its details, they are recorded with the cancellation.
+//
+// The activity heartbeats every longJobHeartbeatInterval, not after every item, every heartbeat is a call to the
+// server. The interval bounds how long a cancelled activity runs on, and has to be well within the HeartbeatTimeout of
+// the activity: a heartbeat that doesn't arrive within the timeout times out the activity, and a late heartbeat can
+// only be sent by the next item, so the timeout has to cover the interval and an item.
+func longRunningJobActivity(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+	defer common.TrackActivity(ctx)()
+	ctx = withActivityTraceID(ctx, input.TraceID)
+	jobInput := longJobInput{Items: 60}
+	if err := openJobInput(ctx, input, &jobInput); err != nil {
+		return CronJobResult{}, err
+	}
+	logger := activityLogger(ctx)
+	progress := longJobProgress{Items: jobInput.Items}
+	lastHeartbeat := time.Now()
+	for progress.Processed < progress.Items {
+		select {
+		case <-ctx.Done():
+			logger.Info("Long job cancelled.", zap.Int("Processed", progress.Processed))
+			return CronJobResult{}, cadence.NewCanceledError(progress)
+		case <-time.After(longJobItemDuration):
+		}
+		// ...
+		progress.Processed++
+		if time.Since(lastHeartbeat) >= longJobHeartbeatInterval {
+			// this version of the client returns nothing from the heartbeat, a heartbeat that learns about the
+			// cancellation cancels the context before it returns.
+			cadence.RecordActivityHeartbeat(ctx, progress)
+			lastHeartbeat = time.Now()
+			if ctx.Err() != nil {
+				logger.Info("Long job cancelled.", zap.Int("Processed", progress.Processed))
+				return CronJobResult{}, cadence.NewCanceledError(progress)
+			}
+		}
+	}
+	logger.Info("Long job completed.", zap.Int("Items", progress.Items))
+	return jobResult(progress)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/cadence"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobActivityByName() {
+	for _, c := range []struct{ activity, input, payload string }{
+		{"report", `{"report":"sales","days":7}`, `{"report":"sales","rows":168}`},
+		{"archive", `{"olderThanDays":10}`, `{"archived":100}`},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		executed := make(map[string]int)
+		env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context,
+			args cadence.EncodedValues) {
+			executed[info.ActivityType.Name]++
+		})
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Hour,
+			JobActivityName: c.activity, JobInput: json.RawMessage(c.input)}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted())
+		// every run executed the job activity of the schedule, by the name it is registered with.
+		s.Equal(map[string]int{jobActivityTypes[c.activity]: loopCountBeforeContinueAsNew}, executed, c.activity)
+		state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+		// the records of the runs keep the payloads of the results as they are.
+		s.Equal([]json.RawMessage{json.RawMessage(c.payload)}, state.RecentRuns[0].Payloads)
+		s.Equal(c.payload, state.RecentRuns[0].ResultSummary)
+		s.Equal(json.RawMessage(c.payload), state.LastResults[0].Payload)
+	}
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_UnknownJobActivity() {
+	spec := ScheduleSpec{JobCount: 1, ScheduleInterval: time.Hour, JobActivityName: "reprot"}
+	s.EqualError(spec.Validate(time.Time{}), `invalid schedule: unknown job activity "reprot", known job activities are archive, long, report, sample`)
+	s.Error((&ScheduleSpec{Jobs: []JobSpec{{Name: "billing", Interval: time.Minute, ActivityName: "bill"}}}).
+		validateJobActivities())
+	s.Error((&ScheduleSpec{JobInput: json.RawMessage("days=7")}).validateJobActivities())
+	s.NoError((&ScheduleSpec{JobActivityName: "report", JobInput: json.RawMessage(`{"days":7}`)}).
+		validateJobActivities())
+
+	// the workflow doesn't schedule an activity that no worker executes either.
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(specProblems(env.GetWorkflowError()), `unknown job activity "reprot"`)
+}
+
+func (s *UnitTestSuite) Test_JobActivities() {
+	env := s.NewTestActivityEnvironment()
+	value, err := env.ExecuteActivity(reportJobActivity, CronJobInput{})
+	s.NoError(err)
+	var result CronJobResult
+	s.NoError(value.Get(&result))
+	// without an input the report covers the last day.
+	s.Equal(`{"report":"daily","rows":24}`, string(result.Payload))
+
+	_, err = env.ExecuteActivity(archiveJobActivity, CronJobInput{JobInput: `{"olderThanDays":0}`})
+	s.Error(err)
+	s.Contains(err.Error(), errReasonInvalidInput)
+	_, err = env.ExecuteActivity(archiveJobActivity, CronJobInput{JobInput: "30"})
+	s.Error(err)
+	s.Contains(err.Error(), errReasonInvalidInput)
+}
+
+func (s *UnitTestSuite) Test_LongRunningJobActivity() {
+	defer func(itemDuration, heartbeatInterval time.Duration) {
+		longJobItemDuration, longJobHeartbeatInterval = itemDuration, heartbeatInterval
+	}(longJobItemDuration, longJobHeartbeatInterval)
+	longJobItemDuration = time.Millisecond
+	input := CronJobInput{JobInput: `{"items":10}`}
+
+	// without a heartbeat the activity doesn't learn about a cancellation, it completes.
+	longJobHeartbeatInterval = time.Hour
+	value, err := s.NewTestActivityEnvironment().ExecuteActivity(longRunningJobActivity, input)
+	s.NoError(err)
+	var result CronJobResult
+	s.NoError(value.Get(&result))
+	s.Equal(`{"processed":10,"items":10}`, string(result.Payload))
+
+	// the test environment cancels the activity with its first heartbeat, as the server does once the workflow
+	// requested the cancellation.
+	longJobHeartbeatInterval = 0
+	_, err = s.NewTestActivityEnvironment().ExecuteActivity(longRunningJobActivity, input)
+	_, ok := err.(cadence.CanceledError)
+	s.True(ok, "expected a CanceledError, got %v", err)
+	failure := common.ClassifyError(err)
+	s.True(failure.HasDetails())
+	var progress longJobProgress
+	s.NoError(failure.Details(&progress))
+	// the activity stopped right after the heartbeat of the first item.
+	s.Equal(longJobProgress{Processed: 1, Items: 10}, progress)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with an AggregatorWorkflowID forwards the outcome of every run to the CronAggregatorWorkflow with that ID,
+ * which tallies the runs of all the schedules that forward to it. The run forwards the RunRecord of its outcome, the
+ * record its CronState keeps as a recent run, with the runResult signal. A failed forward doesn't fail the run, it is
+ * logged, and the tallies miss the run.
+ *
+ * This version of the client has no SignalExternalWorkflow and no SignalWithStart, the run forwards the record with
+ * forwardRunActivity, which signals with the client of the worker as the lock activities do. A forward to an aggregator
+ * that is not running drops the record, unless the schedule has StartAggregator set: then the activity starts the
+ * aggregator first. The aggregator continues as new after aggregatorSignalsBeforeContinueAsNew signals, with its
+ * tallies as its input, and keeps the tallies of at most maxAggregatedSchedules schedules.
+ *
+ * There are no queries either. The report mode of the runner reads the tallies the current run of the aggregator was
+ * started with from its history, and adds the runs of the runResult signals it received since, the way the workflow
+ * does.
+ */
+
+const (
+	// runResultSignalName is the signal of the aggregator, it takes an AggregatedRun.
+	runResultSignalName = "runResult"
+	// aggregatorSignalsBeforeContinueAsNew is the number of signals the aggregator handles before it continues as new.
+	aggregatorSignalsBeforeContinueAsNew = 500
+	// maxAggregatedSchedules is the number of schedules the aggregator keeps tallies of, the schedule whose last run
+	// is the oldest is dropped first.
+	maxAggregatedSchedules = 1000
+	// aggregatorWorkflowTimeout is the execution timeout of the aggregator, it continues as new long before.
+	aggregatorWorkflowTimeout = time.Hour * 24 * 365
+)
+
+type (
+	// AggregatedRun is the payload of the runResult signal, the outcome of a run of a schedule.
+	AggregatedRun struct {
+		// WorkflowID is the ID of the workflow of the schedule, it identifies the schedule in the tallies.
+		WorkflowID string
+		Schedule   string
+		Record     RunRecord
+	}
+
+	// ScheduleTally are the runs of a schedule forwarded to the aggregator.
+	ScheduleTally struct {
+		WorkflowID     string
+		Schedule       string
+		TotalRuns      uint
+		SuccessfulRuns uint
+		FailedRuns     uint
+		// SkippedRuns are the runs skipped by the skipRun signal.
+		SkippedRuns uint
+		// LastScheduledAt is the scheduled time of the last run, and LastError the error of the last failed run.
+		LastScheduledAt time.Time
+		LastError       string
+	}
+
+	// AggregatorState is the state of the aggregator, it is carried over continue-as-new.
+	AggregatorState struct {
+		// Tallies are the tallies of the schedules, in the order their first run was forwarded.
+		Tallies []ScheduleTally
+	}
+)
+
+// forwardsRuns returns true if the runs of the schedule forward their outcome to an aggregator.
+func (s *ScheduleSpec) forwardsRuns() bool {
+	return s.AggregatorWorkflowID != ""
+}
+
+// add tallies a forwarded run.
+func (s *AggregatorState) add(run AggregatedRun) {
+	i := 0
+	for i < len(s.Tallies) && s.Tallies[i].WorkflowID != run.WorkflowID {
+		i++
+	}
+	if i == len(s.Tallies) {
+		s.Tallies = append(s.Tallies, ScheduleTally{WorkflowID: run.WorkflowID})
+	}
+	tally := &s.Tallies[i]
+	tally.Schedule = run.Schedule
+	tally.TotalRuns++
+	switch run.Record.Status {
+	case RunSucceeded:
+		tally.SuccessfulRuns++
+	case RunFailed:
+		tally.FailedRuns++
+		tally.LastError = run.Record.Error
+	case RunSkippedBySignal:
+		tally.SkippedRuns++
+	}
+	if run.Record.ScheduledAt.After(tally.LastScheduledAt) {
+		tally.LastScheduledAt = run.Record.ScheduledAt
+	}
+	if len(s.Tallies) > maxAggregatedSchedules {
+		oldest := 0
+		for j := range s.Tallies {
+			if s.Tallies[j].LastScheduledAt.Before(s.Tallies[oldest].LastScheduledAt) {
+				oldest = j
+			}
+		}
+		s.Tallies = append(s.Tallies[:oldest:oldest], s.Tallies[oldest+1:]...)
+	}
+}
+
+// CronAggregatorWorkflow tallies the runs the cron workflows forward to it, until it is cancelled.
+func CronAggregatorWorkflow(ctx cadence.Context, state AggregatorState) error {
+	runResults := cadence.GetSignalChannel(ctx, runResultSignalName)
+	onRunResult := func(run AggregatedRun) {
+		state.add(run)
+		workflowLogger(ctx).Info("Cron run aggregated.", zap.String("WorkflowID", run.WorkflowID),
+			zap.Stringer("Status", run.Record.Status), zap.Int("Schedules", len(state.Tallies)))
+	}
+
+	for signals := 0; signals < aggregatorSignalsBeforeContinueAsNew; signals++ {
+		selector := cadence.NewSelector(ctx)
+		selector.AddReceive(runResults, func(c cadence.Channel, more bool) {
+			var run AggregatedRun
+			c.Receive(ctx, &run)
+			onRunResult(run)
+		})
+		selector.AddReceive(ctx.Done(), func(c cadence.Channel, more bool) {})
+		selector.Select(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	// the signals received with the last decision are carried over to the next run.
+	var run AggregatedRun
+	for runResults.ReceiveAsync(&run) {
+		onRunResult(run)
+	}
+	ctx = cadence.WithExecutionStartToCloseTimeout(ctx, aggregatorWorkflowTimeout)
+	return cadence.NewContinueAsNewError(ctx, CronAggregatorWorkflow, state)
+}
+
+// forwardRunActivity signals the outcome of a run to the aggregator. It starts the aggregator if it is not running and
+// start is set, and drops the outcome otherwise.
+func forwardRunActivity(ctx context.Context, aggregatorID string, start bool, run AggregatedRun) error {
+	if start {
+		options := cadence.StartWorkflowOptions{
+			ID:                              aggregatorID,
+			TaskList:                        ApplicationName,
+			ExecutionStartToCloseTimeout:    aggregatorWorkflowTimeout,
+			DecisionTaskStartToCloseTimeout: time.Minute,
+		}
+		_, err := lockClient.StartWorkflow(options, CronAggregatorWorkflow, AggregatorState{})
+		if _, ok := err.(*shared.WorkflowExecutionAlreadyStartedError); err != nil && !ok {
+			return err
+		}
+	}
+	err := lockClient.SignalWorkflow(aggregatorID, "", runResultSignalName, run)
+	if _, ok := err.(*shared.EntityNotExistsError); ok && !start {
+		activityLogger(ctx).Warn("Cron run not aggregated, the aggregator is not running.",
+			zap.String("Aggregator", aggregatorID))
+		return nil
+	}
+	return err
+}
+
+// forwardRun forwards the outcome of a run to the aggregator of the spec, if it has one. A failed forward doesn't fail
+// the run.
+func forwardRun(ctx cadence.Context, spec ScheduleSpec, record RunRecord) {
+	if !spec.forwardsRuns() {
+		return
+	}
+	run := AggregatedRun{WorkflowID: cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID, Schedule: spec.Name,
+		Record: record}
+	err := cadence.ExecuteActivity(ctx, forwardRunActivity, spec.AggregatorWorkflowID, spec.StartAggregator, run).
+		Get(ctx, nil)
+	if err != nil {
+		workflowLogger(ctx).Error("Cron job run forwarding failed.", zap.String("Aggregator", spec.AggregatorWorkflowID),
+			zap.Error(err))
+	}
+}
+
+// aggregatorReport returns the tallies of the aggregator with the given ID as of now: the tallies its current run was
+// started with, and the runs it received since. It follows the run the aggregator continued as new with.
+func aggregatorReport(client cadence.Client, workflowID string) (AggregatorState, error) {
+	runID := ""
+	for {
+		history, err := client.GetWorkflowHistory(workflowID, runID)
+		if err != nil {
+			return AggregatorState{}, err
+		}
+		events := history.GetEvents()
+		if len(events) > 0 {
+			if continued := events[len(events)-1].GetWorkflowExecutionContinuedAsNewEventAttributes(); continued != nil {
+				runID = continued.GetNewExecutionRunId_()
+				continue
+			}
+		}
+		return replayAggregator(events)
+	}
+}
+
+// replayAggregator returns the state of the aggregator after the given events of its run.
+func replayAggregator(events []*shared.HistoryEvent) (AggregatorState, error) {
+	var state AggregatorState
+	if len(events) == 0 {
+		return state, errors.New("aggregator has no history")
+	}
+	started := events[0].GetWorkflowExecutionStartedEventAttributes()
+	if started == nil {
+		return state, errors.New("history doesn't start with a WorkflowExecutionStarted event")
+	}
+	if err := cadence.EncodedValues(started.Input).Get(&state); err != nil {
+		return state, err
+	}
+	for _, e := range events[1:] {
+		signaled := e.GetWorkflowExecutionSignaledEventAttributes()
+		if signaled == nil || signaled.GetSignalName() != runResultSignalName {
+			continue
+		}
+		var run AggregatedRun
+		if err := cadence.EncodedValue(signaled.Input).Get(&run); err != nil {
+			return state, fmt.Errorf("signal %d: %v", e.GetEventId(), err)
+		}
+		state.add(run)
+	}
+	return state, nil
+}
+
+// printAggregatorReport prints the tallies of the aggregator as a table.
+func printAggregatorReport(out io.Writer, state AggregatorState) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW ID\tSCHEDULE\tRUNS\tSUCCEEDED\tFAILED\tSKIPPED\tLAST RUN\tLAST ERROR")
+	for _, tally := range state.Tallies {
+		schedule, lastError := tally.Schedule, tally.LastError
+		if schedule == "" {
+			schedule = "-"
+		}
+		if lastError == "" {
+			lastError = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n", tally.WorkflowID, schedule, tally.TotalRuns,
+			tally.SuccessfulRuns, tally.FailedRuns, tally.SkippedRuns, tally.LastScheduledAt.Format(time.RFC3339),
+			lastError)
+	}
+	w.Flush()
+}
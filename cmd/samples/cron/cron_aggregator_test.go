@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_ForwardsRuns() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := 0
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		if runs == 2 {
+			return CronJobResult{}, errors.New("dependency unavailable")
+		}
+		return CronJobResult{ProcessedBatches: uint(runs)}, nil
+	})
+	var forwarded []AggregatedRun
+	env.OverrideActivity(forwardRunActivity, func(ctx context.Context, aggregatorID string, start bool,
+		run AggregatedRun) error {
+		s.Equal("cron_report", aggregatorID)
+		s.True(start)
+		forwarded = append(forwarded, run)
+		if len(forwarded) == 3 {
+			return errors.New("aggregator unavailable")
+		}
+		return nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour,
+		FailurePolicy: FailureContinue, Name: "nightly", AggregatorWorkflowID: "cron_report", StartAggregator: true},
+		&CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	// the failed forward of the last run doesn't fail the schedule.
+	s.NoError(env.GetWorkflowError())
+	s.Len(forwarded, 3)
+	var statuses []RunStatus
+	for _, run := range forwarded {
+		s.Equal("default-test-workflow-id", run.WorkflowID)
+		s.Equal("nightly", run.Schedule)
+		statuses = append(statuses, run.Record.Status)
+	}
+	s.Equal([]RunStatus{RunSucceeded, RunFailed, RunSucceeded}, statuses)
+	s.Contains(forwarded[1].Record.Error, "dependency unavailable")
+	s.Equal(time.Unix(3600, 0), forwarded[0].Record.ScheduledAt)
+}
+
+func (s *UnitTestSuite) Test_CronAggregatorWorkflow_ContinueAsNew() {
+	at := func(seconds int) time.Time { return time.Unix(int64(seconds), 0) }
+	env := s.NewTestWorkflowEnvironment()
+	for i := 0; i < aggregatorSignalsBeforeContinueAsNew; i++ {
+		run := AggregatedRun{WorkflowID: "cron_a", Schedule: "a", Record: RunRecord{ScheduledAt: at(i),
+			Status: RunSucceeded}}
+		if i%5 == 0 {
+			run = AggregatedRun{WorkflowID: "cron_b", Record: RunRecord{ScheduledAt: at(i), Status: RunFailed,
+				Error: fmt.Sprintf("run %d failed", i)}}
+		}
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(runResultSignalName, run)
+		}, time.Second*time.Duration(i+1))
+	}
+	env.ExecuteWorkflow(CronAggregatorWorkflow, AggregatorState{Tallies: []ScheduleTally{{WorkflowID: "cron_a",
+		Schedule: "a", TotalRuns: 10, SuccessfulRuns: 10}}})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.ContinueAsNewError)
+	s.True(ok)
+	// the tallies the aggregator was started with are carried over with the new ones.
+	state := continueAsNewArgs(env.GetWorkflowError())[0].(AggregatorState)
+	s.Equal([]ScheduleTally{
+		{WorkflowID: "cron_a", Schedule: "a", TotalRuns: 410, SuccessfulRuns: 410, LastScheduledAt: at(499)},
+		{WorkflowID: "cron_b", TotalRuns: 100, FailedRuns: 100, LastScheduledAt: at(495), LastError: "run 495 failed"},
+	}, state.Tallies)
+}
+
+func (s *UnitTestSuite) Test_AggregatorState_DropsOldestSchedule() {
+	at := func(seconds int) time.Time { return time.Unix(int64(seconds), 0) }
+	var state AggregatorState
+	for i := 0; i <= maxAggregatedSchedules; i++ {
+		// the first schedule runs last, the second one is the oldest.
+		scheduledAt := at(i)
+		if i == 0 {
+			scheduledAt = at(maxAggregatedSchedules + 1)
+		}
+		state.add(AggregatedRun{WorkflowID: fmt.Sprintf("cron_%d", i), Record: RunRecord{ScheduledAt: scheduledAt,
+			Status: RunSkippedBySignal}})
+	}
+	s.Len(state.Tallies, maxAggregatedSchedules)
+	s.Equal("cron_0", state.Tallies[0].WorkflowID)
+	s.Equal(uint(1), state.Tallies[0].SkippedRuns)
+	s.Equal("cron_2", state.Tallies[1].WorkflowID)
+}
+
+// withLockClient makes the activities signal and start workflows with the given client, and returns the function
+// that restores the client of the worker.
+func withLockClient(client cadence.Client) func() {
+	original := lockClient
+	lockClient = client
+	return func() { lockClient = original }
+}
+
+func (s *UnitTestSuite) Test_ForwardRunActivity() {
+	client := &fakeClient{running: make(map[string]string)}
+	defer withLockClient(client)()
+	env := s.NewTestActivityEnvironment()
+	run := AggregatedRun{WorkflowID: "cron_a", Record: RunRecord{Status: RunSucceeded}}
+	// the outcome is dropped while the aggregator is not running.
+	_, err := env.ExecuteActivity(forwardRunActivity, "cron_report", false, run)
+	s.NoError(err)
+	s.Empty(client.signals)
+	s.Equal(0, client.starts)
+
+	// the aggregator is started once, then signalled.
+	for i := 0; i < 2; i++ {
+		_, err = env.ExecuteActivity(forwardRunActivity, "cron_report", true, run)
+		s.NoError(err)
+	}
+	s.Equal(1, client.starts)
+	s.Equal([]signalCall{{"cron_report", "", runResultSignalName, run}, {"cron_report", "", runResultSignalName, run}},
+		client.signals)
+}
+
+func (s *UnitTestSuite) Test_PrintAggregatorReport() {
+	var out bytes.Buffer
+	printAggregatorReport(&out, AggregatorState{Tallies: []ScheduleTally{
+		{WorkflowID: "cron_a", Schedule: "a", TotalRuns: 3, SuccessfulRuns: 3, LastScheduledAt: listStart},
+		{WorkflowID: "cron_b", TotalRuns: 2, SuccessfulRuns: 1, FailedRuns: 1, LastScheduledAt: listStart,
+			LastError: "dependency unavailable"},
+	}})
+	s.Equal("WORKFLOW ID  SCHEDULE  RUNS  SUCCEEDED  FAILED  SKIPPED  LAST RUN              LAST ERROR\n"+
+		"cron_a       a         3     3          0       0        2023-06-01T08:00:00Z  -\n"+
+		"cron_b       -         2     1          1       0        2023-06-01T08:00:00Z  dependency unavailable\n",
+		out.String())
+}
+
+func TestReplay_CronWorkflowsFeedAggregator(t *testing.T) {
+	aggregator := newHistorySimulator(t, time.Unix(0, 0), CronAggregatorWorkflow, AggregatorState{})
+	aggregator.workflowID = "cron_report"
+	crons := make([]*historySimulator, 0, 2)
+	for i, id := range []string{"cron_a", "cron_b"} {
+		spec := ScheduleSpec{JobCount: uint(3 - i), ScheduleInterval: time.Minute * time.Duration(i+1), Name: id,
+			AggregatorWorkflowID: aggregator.workflowID}
+		h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+		h.workflowID, h.runID = id, id+"-run"
+		h.activityDuration = time.Second * 10
+		h.onActivityCompleted = func(scheduled *s.HistoryEvent) {
+			attributes := scheduled.GetActivityTaskScheduledEventAttributes()
+			if attributes.GetActivityType().GetName() != getFunctionName(forwardRunActivity) {
+				return
+			}
+			var aggregatorID string
+			var start bool
+			var run AggregatedRun
+			require.NoError(t, cadence.EncodedValues(attributes.Input).Get(&aggregatorID, &start, &run))
+			require.Equal(t, aggregator.workflowID, aggregatorID)
+			aggregator.signalAt(h.now, runResultSignalName, run)
+		}
+		crons = append(crons, h)
+	}
+
+	runTogether(t, 2, crons[0], crons[1], aggregator)
+	// the aggregator sees the runs forwarded by the activities that completed last after the crons closed.
+	for aggregator.advance() {
+		require.Nil(t, aggregator.decide())
+	}
+	for _, h := range crons {
+		require.Equal(t, s.EventType_WorkflowExecutionCompleted, h.events[len(h.events)-1].GetEventType(), h.workflowID)
+		require.NoError(t, h.replay(), h.workflowID)
+	}
+	require.NoError(t, aggregator.replay())
+	state, err := replayAggregator(aggregator.events)
+	require.NoError(t, err)
+	// the runs are tallied by the time they were scheduled at, not the time they completed.
+	require.Equal(t, []ScheduleTally{
+		{WorkflowID: "cron_a", Schedule: "cron_a", TotalRuns: 3, SuccessfulRuns: 3,
+			LastScheduledAt: time.Unix(0, 0).Add(time.Minute * 3)},
+		{WorkflowID: "cron_b", Schedule: "cron_b", TotalRuns: 2, SuccessfulRuns: 2,
+			LastScheduledAt: time.Unix(0, 0).Add(time.Minute * 4)},
+	}, state.Tallies)
+}
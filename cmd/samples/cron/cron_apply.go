@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+)
+
+/**
+ * The export and apply verbs keep the schedules of a cluster in a document, e.g. under version control:
+ *
+ *   cron export [--output schedules.json]
+ *   cron apply --file schedules.json [--dry-run] [--replace]
+ *
+ * The document is a JSON object of the ScheduleSpecs by schedule name, encoded like the spec query prints them, without
+ * the fields the workflow keeps the state of the schedule in, e.g. a pause or the missed runs, see definition. export
+ * reads the specs of the open cron workflows from the inputs of their current runs, like the query verb, so a signal
+ * since the last continue-as-new is not in the document. A workflow without a name has no workflow ID apply could
+ * derive from the document, the verbs leave it alone.
+ *
+ * apply reconciles the cluster with the document. A schedule that isn't running is started with the workflow ID of its
+ * name, a running schedule whose interval differs gets the updateSchedule signal, and a running schedule that isn't in
+ * the document is drained. Any other difference can't be signalled, the schedule is a conflict: it is reported and
+ * skipped, or terminated and started again from the document with --replace. The progressFields are not compared, the
+ * JobCount of a running schedule is the runs it has left. --dry-run prints the plan without applying it.
+ */
+
+// The actions of the plan of the apply verb.
+const (
+	applyStart    = "start"
+	applyUpdate   = "update"
+	applyDrain    = "drain"
+	applyReplace  = "replace"
+	applyConflict = "conflict"
+
+	// applyDrainReason is the reason of the drain of a schedule that was removed from the document.
+	applyDrainReason = "removed from the schedule document"
+)
+
+// progressFields are the fields of a ScheduleSpec that the runs of a schedule count down or use up, or that only apply
+// to its first run. apply doesn't compare them with the running schedule.
+var progressFields = map[string]bool{"JobCount": true, "InitialDelay": true, "StartAt": true, "JobInputs": true,
+	"JobInputIndex": true}
+
+type (
+	// scheduleDocument is the document of the export and apply verbs, the definitions of the schedules by name.
+	scheduleDocument map[string]ScheduleSpec
+
+	// runningSchedule is the open cron workflow of a schedule with a name, with the spec of its current run.
+	runningSchedule struct {
+		WorkflowID string
+		Spec       ScheduleSpec
+	}
+
+	// applyAction is a step of the plan of the apply verb.
+	applyAction struct {
+		Action     string
+		Schedule   string
+		WorkflowID string
+		// Changes are the fields of the definition that differ from the running schedule.
+		Changes []string
+		// Spec is the definition of the schedule in the document, empty for a drain.
+		Spec ScheduleSpec
+	}
+)
+
+// definition returns the spec without the fields the workflow keeps the state of the schedule in, and without the
+// ones the starter sets for every start, the ChangeVersions and the TraceID.
+func (s *ScheduleSpec) definition() ScheduleSpec {
+	definition := *s
+	definition.Paused, definition.Draining, definition.PendingTrigger, definition.BufferedRun = false, false, nil, false
+	definition.SkippedByExclusions, definition.SkippedByOverlap, definition.BufferedByOverlap = 0, 0, 0
+	definition.Backlog, definition.MissedRuns = nil, 0
+	definition.ChangeVersions, definition.TraceID = nil, ""
+	if s.ConfigSource != nil {
+		source := *s.ConfigSource
+		source.Version = ""
+		definition.ConfigSource = &source
+	}
+	if s.Lock != nil {
+		lock := *s.Lock
+		lock.ActivityTaskList = ""
+		definition.Lock = &lock
+	}
+	return definition
+}
+
+// parseScheduleDocument decodes the document and validates its schedules as of now. The Name of a schedule is its key,
+// a schedule that has another one is rejected. The schedules are kept as they are written, a start normalizes them.
+func parseScheduleDocument(data []byte, now time.Time) (scheduleDocument, error) {
+	var document scheduleDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, errors.New("the document is not an object of schedules by name")
+	}
+	for _, name := range document.names() {
+		spec := document[name]
+		if spec.Name != "" && spec.Name != name {
+			return nil, fmt.Errorf("schedule %q has the name %q", name, spec.Name)
+		}
+		spec.Name = name
+		normalized := spec
+		if err := normalized.Validate(now); err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", name, err)
+		}
+		document[name] = spec.definition()
+	}
+	return document, nil
+}
+
+// names returns the names of the schedules of the document, sorted.
+func (d scheduleDocument) names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runningSchedules returns the open cron workflows of the schedules with a name by name.
+func runningSchedules(client cadence.Client, pageSize int32) (map[string]runningSchedule, error) {
+	executions, err := openExecutions(client, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]runningSchedule)
+	for _, execution := range executions {
+		workflowID := execution.GetExecution().GetWorkflowId()
+		spec, _, _, err := runInput(client, workflowID, execution.GetExecution().GetRunId())
+		if err != nil {
+			return nil, err
+		}
+		if spec.Name == "" || scheduleWorkflowID(spec.Name) != workflowID {
+			continue
+		}
+		running[spec.Name] = runningSchedule{WorkflowID: workflowID, Spec: spec}
+	}
+	return running, nil
+}
+
+// specChanges returns the names of the fields of the definitions of the running and the defined spec that differ, in
+// the order of ScheduleSpec. The progressFields are not compared, and a zero timeout of the defined spec is the
+// default the starter filled in.
+func specChanges(running, defined ScheduleSpec) []string {
+	defined.Timeouts = defined.Timeouts.orDefaults(running.Timeouts)
+	runningValue, definedValue := reflect.ValueOf(running.definition()), reflect.ValueOf(defined.definition())
+	var changes []string
+	for i := 0; i < runningValue.NumField(); i++ {
+		field := runningValue.Type().Field(i)
+		if field.PkgPath != "" || progressFields[field.Name] {
+			continue
+		}
+		if canonicalJSON(runningValue.Field(i).Interface()) != canonicalJSON(definedValue.Field(i).Interface()) {
+			changes = append(changes, field.Name)
+		}
+	}
+	return changes
+}
+
+// canonicalJSON returns the JSON of the value with its object keys sorted, and with empty arrays and objects as null.
+// The input of a run is gob encoded, it decodes an empty slice of the document as nil.
+func canonicalJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err.Error()
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err.Error()
+	}
+	data, _ = json.Marshal(withoutEmpty(decoded))
+	return string(data)
+}
+
+func withoutEmpty(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		for i := range value {
+			value[i] = withoutEmpty(value[i])
+		}
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		for key := range value {
+			value[key] = withoutEmpty(value[key])
+		}
+	}
+	return value
+}
+
+// updatable returns true if the changes of the running schedule to the defined spec can be signalled with
+// updateSchedule, which only changes the interval of a schedule by interval.
+func updatable(defined ScheduleSpec, changes []string) bool {
+	return len(changes) == 1 && changes[0] == "ScheduleInterval" && defined.TimeOfDay == "" && len(defined.Jobs) == 0 &&
+		ScheduleUpdate{ScheduleInterval: defined.ScheduleInterval}.validate() == nil
+}
+
+// planApply returns the actions that reconcile the running schedules with the document, in the order of the names of
+// the schedules. A running schedule that matches its definition needs no action. A conflict is replaced if replace is
+// true.
+func planApply(document scheduleDocument, running map[string]runningSchedule, replace bool) []applyAction {
+	names := document.names()
+	for name := range running {
+		if _, ok := document[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var plan []applyAction
+	for _, name := range names {
+		spec, defined := document[name]
+		schedule, ok := running[name]
+		action := applyAction{Schedule: name, WorkflowID: scheduleWorkflowID(name), Spec: spec}
+		switch {
+		case !ok:
+			action.Action = applyStart
+		case !defined:
+			action.Action = applyDrain
+		default:
+			action.Changes = specChanges(schedule.Spec, spec)
+			switch {
+			case len(action.Changes) == 0:
+				continue
+			case updatable(spec, action.Changes):
+				action.Action = applyUpdate
+			case replace:
+				action.Action = applyReplace
+			default:
+				action.Action = applyConflict
+			}
+		}
+		plan = append(plan, action)
+	}
+	return plan
+}
+
+// execute applies the action to the current run of the workflow of the schedule, a conflict is skipped.
+func (a applyAction) execute(client cadence.Client) error {
+	switch a.Action {
+	case applyStart, applyReplace:
+		// a started schedule takes the new path of every change of the workflow code, like one of the trigger mode.
+		spec := a.Spec
+		if err := spec.Validate(time.Now()); err != nil {
+			return err
+		}
+		spec.withLatestVersions()
+		spec.TraceID = uuid.New()
+		_, err := startSchedule(client, spec.startOptions(a.WorkflowID), a.Action == applyReplace, spec, &CronState{})
+		return err
+	case applyUpdate:
+		return client.SignalWorkflow(a.WorkflowID, "", updateScheduleSignalName,
+			ScheduleUpdate{ScheduleInterval: a.Spec.ScheduleInterval})
+	case applyDrain:
+		return client.SignalWorkflow(a.WorkflowID, "", drainSignalName, applyDrainReason)
+	}
+	return nil
+}
+
+// exportSchedules writes the definitions of the running schedules as a document to the output file of the command, to
+// out if it has none.
+func exportSchedules(client cadence.Client, command cliCommand, out io.Writer) error {
+	running, err := runningSchedules(client, defaultPageSize)
+	if err != nil {
+		return err
+	}
+	document := make(scheduleDocument, len(running))
+	for name, schedule := range running {
+		spec := schedule.Spec.definition()
+		// the name is the key of the schedule.
+		spec.Name = ""
+		document[name] = spec
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if command.Output == "" {
+		_, err = out.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(command.Output, data, 0644)
+}
+
+// applySchedules reconciles the running schedules with the document of the command, and prints the actions it applied.
+// A dry run prints the plan only. The actions are applied in order, the first that fails stops the others.
+func applySchedules(client cadence.Client, command cliCommand, out io.Writer) error {
+	data, err := ioutil.ReadFile(command.File)
+	if err != nil {
+		return cliUsageError{err}
+	}
+	document, err := parseScheduleDocument(data, time.Now())
+	if err != nil {
+		return cliUsageError{fmt.Errorf("invalid schedule document %s: %v", command.File, err)}
+	}
+	running, err := runningSchedules(client, defaultPageSize)
+	if err != nil {
+		return err
+	}
+	plan := planApply(document, running, command.Replace)
+	if command.DryRun {
+		return printPlan(out, command, plan, "planned, dry run")
+	}
+	for i, action := range plan {
+		if err := action.execute(client); err != nil {
+			printPlan(out, command, plan[:i], "applied")
+			return fmt.Errorf("%s of schedule %s failed: %w", action.Action, action.Schedule, err)
+		}
+	}
+	return printPlan(out, command, plan, "applied")
+}
+
+// printPlan prints the actions as a table, and how many of them were applied as the given outcome, or as an
+// applyResult with --output json.
+func printPlan(out io.Writer, command cliCommand, plan []applyAction, outcome string) error {
+	if command.JSON {
+		result := applyResult{Actions: []plannedAction{}, DryRun: command.DryRun}
+		for _, action := range plan {
+			if action.Action == applyConflict {
+				result.Conflicts++
+			}
+			result.Actions = append(result.Actions, plannedAction{action.Action, action.Schedule, action.WorkflowID,
+				action.Changes})
+		}
+		return printJSONLine(out, result)
+	}
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ACTION\tSCHEDULE\tWORKFLOW ID\tCHANGES")
+	var conflicts int
+	for _, action := range plan {
+		changes := strings.Join(action.Changes, ",")
+		switch action.Action {
+		case applyConflict:
+			conflicts++
+			changes += " (skipped, --replace restarts it)"
+		case applyUpdate:
+			changes = fmt.Sprintf("ScheduleInterval=%v", action.Spec.ScheduleInterval)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", action.Action, action.Schedule, action.WorkflowID, changes)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "%d actions %s, %d conflicts skipped\n", len(plan)-conflicts, outcome, conflicts)
+	return err
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+)
+
+// clusterClient is a recordingClient whose cluster runs the given cron workflows, with the specs of their inputs by
+// workflow ID. The starts fail with the startErrs, one each, until there are none left.
+type clusterClient struct {
+	recordingClient
+	t         *testing.T
+	workflows map[string]ScheduleSpec
+	startErrs []error
+}
+
+type startCall struct {
+	WorkflowID string
+	Spec       ScheduleSpec
+}
+
+func (c *clusterClient) ListOpenWorkflow(request *shared.ListOpenWorkflowExecutionsRequest) (
+	*shared.ListOpenWorkflowExecutionsResponse, error) {
+	var executions []*shared.WorkflowExecutionInfo
+	for workflowID := range c.workflows {
+		executions = append(executions, &shared.WorkflowExecutionInfo{Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr("run-" + workflowID)}})
+	}
+	return &shared.ListOpenWorkflowExecutionsResponse{Executions: executions}, nil
+}
+
+func (c *clusterClient) GetWorkflowHistory(workflowID string, runID string) (*shared.History, error) {
+	c.input = encodeValues(c.t, c.workflows[workflowID], &CronState{})
+	return c.recordingClient.GetWorkflowHistory(workflowID, runID)
+}
+
+func (c *clusterClient) StartWorkflow(options cadence.StartWorkflowOptions, workflow interface{},
+	args ...interface{}) (*cadence.WorkflowExecution, error) {
+	spec := args[0].(ScheduleSpec)
+	c.calls = append(c.calls, startCall{options.ID, spec})
+	if len(c.startErrs) > 0 {
+		err := c.startErrs[0]
+		c.startErrs = c.startErrs[1:]
+		return nil, err
+	}
+	return &cadence.WorkflowExecution{ID: options.ID, RunID: "run-" + options.ID}, nil
+}
+
+func Test_ParseScheduleDocument(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	document, err := parseScheduleDocument([]byte(`{
+  "nightly": {"JobCount": 5, "TimeOfDay": "02:00", "Paused": true},
+  "hourly": {"Name": "hourly", "JobCount": 5, "ScheduleInterval": 3600000000000}
+}`), now)
+	require.NoError(t, err)
+	// the name is the key, and the state of a schedule is not part of its definition.
+	require.Equal(t, scheduleDocument{
+		"nightly": {Name: "nightly", JobCount: 5, TimeOfDay: "02:00"},
+		"hourly":  {Name: "hourly", JobCount: 5, ScheduleInterval: time.Hour},
+	}, document)
+	require.Equal(t, []string{"hourly", "nightly"}, document.names())
+
+	for _, c := range []struct {
+		document, message string
+	}{
+		{`{"nightly": {"JobCount": 5, "TimeOfDay": "02:00"}, "hourly": {"JobCount": 5}}`,
+			`schedule "hourly": invalid schedule: schedule interval must be positive`},
+		{`{"hourly": {"Name": "daily", "JobCount": 5, "ScheduleInterval": 60000000000}}`,
+			`schedule "hourly" has the name "daily"`},
+		{`{"hourly": {"JobCount": 5, "Interval": 60000000000}}`, `unknown field "Interval"`},
+		{`null`, "not an object of schedules by name"},
+		{`[]`, "cannot unmarshal array"},
+	} {
+		_, err := parseScheduleDocument([]byte(c.document), now)
+		require.Error(t, err, c.document)
+		require.Contains(t, err.Error(), c.message)
+	}
+}
+
+func Test_ScheduleSpec_Definition(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour, Paused: true, Draining: true,
+		PendingTrigger: &TriggerNowRequest{}, SkippedByExclusions: 1, BufferedRun: true, SkippedByOverlap: 2,
+		BufferedByOverlap: 3, Backlog: []time.Time{time.Unix(0, 0)}, MissedRuns: 4, TraceID: "trace-1",
+		ConfigSource: &ConfigSourceSpec{Name: "nightly", Version: "2"},
+		Lock:         &LockSpec{Name: "db", Permits: 1, ActivityTaskList: "cron-activities"}}
+	spec.withLatestVersions()
+
+	require.Equal(t, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour,
+		ConfigSource: &ConfigSourceSpec{Name: "nightly"}, Lock: &LockSpec{Name: "db", Permits: 1}}, spec.definition())
+	// the definition doesn't share the state of the spec.
+	require.Equal(t, "2", spec.ConfigSource.Version)
+	require.Equal(t, "cron-activities", spec.Lock.ActivityTaskList)
+}
+
+func Test_SpecChanges(t *testing.T) {
+	defined := ScheduleSpec{Name: "nightly", JobCount: 10, ScheduleInterval: time.Hour,
+		JobInput: json.RawMessage(`{"days": 7, "tables": []}`), Exclusions: Exclusions{Dates: []string{}}}
+	running := ScheduleSpec{Name: "nightly", JobCount: 3, ScheduleInterval: time.Hour, Paused: true,
+		JobInput: json.RawMessage(`{"tables":null,"days":7}`), JobInputIndex: 4, StartAt: time.Unix(0, 0)}
+	running.withLatestVersions()
+	// the progress and the state of the running schedule are no changes, nor is the encoding of the same JSON.
+	require.Empty(t, specChanges(running, defined))
+
+	running.ScheduleInterval, running.JobActivityName, running.Parallelism = time.Minute, "report", 2
+	require.Equal(t, []string{"ScheduleInterval", "Parallelism", "JobActivityName"}, specChanges(running, defined))
+}
+
+func Test_PlanApply(t *testing.T) {
+	spec := func(name string, interval time.Duration) ScheduleSpec {
+		return ScheduleSpec{Name: name, JobCount: 10, ScheduleInterval: interval}
+	}
+	report := spec("report", time.Hour)
+	report.JobActivityName = "report"
+	daily := ScheduleSpec{Name: "daily", JobCount: 10, TimeOfDay: "02:00"}
+	document := scheduleDocument{"hourly": spec("hourly", time.Hour), "faster": spec("faster", time.Minute),
+		"report": report, "new": spec("new", time.Hour), "daily": daily}
+	runningDaily := daily
+	runningDaily.TimeOfDay = "03:00"
+	unchanged := spec("hourly", time.Hour)
+	unchanged.JobCount = 2
+	running := map[string]runningSchedule{
+		"hourly": {WorkflowID: "cron_hourly", Spec: unchanged},
+		"faster": {WorkflowID: "cron_faster", Spec: spec("faster", time.Hour)},
+		"report": {WorkflowID: "cron_report", Spec: spec("report", time.Hour)},
+		"daily":  {WorkflowID: "cron_daily", Spec: runningDaily},
+		"old":    {WorkflowID: "cron_old", Spec: spec("old", time.Hour)},
+	}
+
+	require.Equal(t, []applyAction{
+		{Action: applyConflict, Schedule: "daily", WorkflowID: "cron_daily", Changes: []string{"TimeOfDay"},
+			Spec: daily},
+		{Action: applyUpdate, Schedule: "faster", WorkflowID: "cron_faster", Changes: []string{"ScheduleInterval"},
+			Spec: spec("faster", time.Minute)},
+		{Action: applyStart, Schedule: "new", WorkflowID: "cron_new", Spec: spec("new", time.Hour)},
+		{Action: applyDrain, Schedule: "old", WorkflowID: "cron_old"},
+		{Action: applyConflict, Schedule: "report", WorkflowID: "cron_report", Changes: []string{"JobActivityName"},
+			Spec: report},
+	}, planApply(document, running, false))
+
+	// the conflicts are replaced, an interval can't be signalled to a schedule by time of day.
+	runningDaily.ScheduleInterval = time.Hour
+	running["daily"] = runningSchedule{WorkflowID: "cron_daily", Spec: runningDaily}
+	var actions []string
+	for _, action := range planApply(document, running, true) {
+		actions = append(actions, action.Action+" "+action.Schedule)
+	}
+	require.Equal(t, []string{"replace daily", "update faster", "start new", "drain old", "replace report"}, actions)
+}
+
+func Test_RunCommand_ExportAndApply(t *testing.T) {
+	hourly := ScheduleSpec{Name: "hourly", JobCount: 3, ScheduleInterval: time.Hour, Paused: true, TraceID: "trace-1"}
+	hourly.withLatestVersions()
+	client := &clusterClient{t: t, workflows: map[string]ScheduleSpec{
+		"cron_hourly": hourly,
+		"cron_report": {Name: "report", JobCount: 5, ScheduleInterval: time.Minute, JobActivityName: "report"},
+		// a schedule without a name is left alone.
+		"cron_3f2c": {JobCount: 5, ScheduleInterval: time.Minute},
+	}}
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	command, err := parseCommand([]string{"export", "--output", path}, ioutil.Discard)
+	require.NoError(t, err)
+	require.NoError(t, runCommand(client, command, ioutil.Discard))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var exported map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &exported))
+	require.Len(t, exported, 2)
+	require.Equal(t, false, exported["hourly"]["Paused"])
+	require.Equal(t, "", exported["hourly"]["Name"])
+	require.Equal(t, "report", exported["report"]["JobActivityName"])
+
+	// the export applies without changes.
+	out := applyArgs(t, client, "apply", "--file", path)
+	require.Equal(t, "ACTION  SCHEDULE  WORKFLOW ID  CHANGES\n0 actions applied, 0 conflicts skipped\n", out)
+	require.Empty(t, client.calls)
+
+	// a faster hourly schedule, a new one, and the report removed.
+	document := scheduleDocument{"hourly": {JobCount: 3, ScheduleInterval: time.Minute * 30},
+		"nightly": {JobCount: 7, TimeOfDay: "02:00"}}
+	data, err = json.Marshal(document)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+	out = applyArgs(t, client, "apply", "--file", path, "--dry-run")
+	require.Equal(t, "ACTION  SCHEDULE  WORKFLOW ID   CHANGES\n"+
+		"update  hourly    cron_hourly   ScheduleInterval=30m0s\n"+
+		"start   nightly   cron_nightly  \n"+
+		"drain   report    cron_report   \n"+
+		"3 actions planned, dry run, 0 conflicts skipped\n", out)
+	require.Empty(t, client.calls)
+
+	out = applyArgs(t, client, "apply", "--file", path)
+	require.Contains(t, out, "3 actions applied, 0 conflicts skipped\n")
+	require.Len(t, client.calls, 3)
+	require.Equal(t, signalCall{"cron_hourly", "", updateScheduleSignalName,
+		ScheduleUpdate{ScheduleInterval: time.Minute * 30}}, client.calls[0])
+	start := client.calls[1].(startCall)
+	require.Equal(t, "cron_nightly", start.WorkflowID)
+	require.Equal(t, "nightly", start.Spec.Name)
+	require.Equal(t, latestVersions, start.Spec.ChangeVersions)
+	require.NotEmpty(t, start.Spec.TraceID)
+	require.Equal(t, signalCall{"cron_report", "", drainSignalName, applyDrainReason}, client.calls[2])
+}
+
+func Test_RunCommand_ApplyConflicts(t *testing.T) {
+	client := &clusterClient{t: t, workflows: map[string]ScheduleSpec{
+		"cron_report": {Name: "report", JobCount: 5, ScheduleInterval: time.Minute, JobActivityName: "report"}}}
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"report": {"JobCount": 5, "ScheduleInterval": 60000000000,
+		"JobActivityName": "archive"}}`), 0644))
+
+	out := applyArgs(t, client, "apply", "--file", path)
+	require.Equal(t, "ACTION    SCHEDULE  WORKFLOW ID  CHANGES\n"+
+		"conflict  report    cron_report  JobActivityName (skipped, --replace restarts it)\n"+
+		"0 actions applied, 1 conflicts skipped\n", out)
+	require.Empty(t, client.calls)
+
+	// the replaced schedule is terminated when its start is rejected, and started again.
+	client.startErrs = []error{&shared.WorkflowExecutionAlreadyStartedError{RunId: common.StringPtr("run-cron_report")}}
+	out = applyArgs(t, client, "apply", "--file", path, "--replace")
+	require.Contains(t, out, "replace  report    cron_report  JobActivityName\n1 actions applied")
+	require.Len(t, client.calls, 3)
+	require.Equal(t, terminateCall{"cron_report", "run-cron_report", "replaced by a new start"}, client.calls[1])
+	require.Equal(t, "archive", client.calls[2].(startCall).Spec.JobActivityName)
+
+	// the first action that fails stops the apply.
+	client.calls = nil
+	client.startErrs = []error{&shared.BadRequestError{Message: "no domain"}}
+	_, err := applyArgsErr(t, client, "apply", "--file", path, "--replace")
+	require.EqualError(t, err, "replace of schedule report failed: BadRequestError({Message:no domain})")
+	require.Len(t, client.calls, 1)
+
+	// an invalid document applies nothing.
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"report": {"JobCount": 5}}`), 0644))
+	_, err = applyArgsErr(t, client, "apply", "--file", path)
+	require.IsType(t, cliUsageError{}, err)
+	_, err = parseCommand([]string{"apply"}, ioutil.Discard)
+	require.EqualError(t, err, "--file is required")
+}
+
+// applyArgs parses and runs the given arguments with the client, and returns the output.
+func applyArgs(t *testing.T, client *clusterClient, args ...string) string {
+	out, err := applyArgsErr(t, client, args...)
+	require.NoError(t, err)
+	return out
+}
+
+func applyArgsErr(t *testing.T, client *clusterClient, args ...string) (string, error) {
+	command, err := parseCommand(args, ioutil.Discard)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	err = runCommand(client, command, &out)
+	return out.String(), err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with a DailyBudget limits the time its runs execute per day, e.g. a job that uses a metered resource
+ * downstream. Every run adds the time its job executed, from the start of its activities until they completed as
+ * measured with the workflow clock, to the usage of the day it completed on. The day starts at midnight in the
+ * Timezone of the schedule, UTC by default. A run that is due once the usage reached the budget is skipped and
+ * recorded as skipped by the budget, until the next day starts with a new budget. Runs that are in progress when the
+ * budget is used up complete, a day can overrun its budget by them.
+ *
+ * The usage and its day are part of the CronState, so they are carried over continue-as-new, and a replay computes
+ * the same day from the workflow clock. A manual run requested with OverrideBudget starts even if the budget is used
+ * up, its time still counts. This version of the client has no queries, the status query of the cron CLI reads the
+ * usage from the input of the current run, it is the usage as of the last continue-as-new.
+ *
+ * A skipped run doesn't count against the JobCount, but it counts against the loopCountBeforeContinueAsNew runs of a
+ * workflow run, so a schedule that skips runs continues as new after fewer of them. The wait for a skipped run adds
+ * to the history like the one for a run, and the default Timeouts.Workflow only covers loopCountBeforeContinueAsNew
+ * waits, a day of skipped runs would outlast it otherwise.
+ */
+
+// skipsOverBudget returns true if the due run is skipped because the runs used up the budget of the day, and records
+// the skipped run.
+func (j *cronJobs) skipsOverBudget(ctx cadence.Context, run dueRun) bool {
+	if !j.state.budgetUsedUp(j.spec, cadence.Now(ctx)) {
+		return false
+	}
+	if run.trigger != nil && run.trigger.OverrideBudget {
+		workflowLogger(ctx).Info("Cron job manual run overrides the daily budget.",
+			zap.Duration("DailyBudget", j.spec.DailyBudget), zap.Duration("BudgetUsed", j.state.BudgetUsed))
+		return false
+	}
+	j.state.SkippedByBudget++
+	record := RunRecord{ScheduledAt: run.scheduledTime, Status: RunSkippedByBudget,
+		ResultSummary: fmt.Sprintf("daily budget of %v used up", j.spec.DailyBudget)}
+	if run.job != nil {
+		record.Job = run.job.Name
+	}
+	j.state.addRecentRun(record)
+	countSkipped(ctx, skipReasonBudget, 1)
+	workflowLogger(ctx).Info("Cron job run skipped, daily budget used up.", zap.Time("ScheduledTime", run.scheduledTime),
+		zap.Duration("DailyBudget", j.spec.DailyBudget), zap.Duration("BudgetUsed", j.state.BudgetUsed),
+		zap.Time("BudgetDay", j.state.BudgetDay), zap.Uint("TotalSkipped", j.state.SkippedByBudget))
+	return true
+}
+
+// measureExecution starts measuring the execution of a job with the workflow clock, the returned function stores the
+// time since in executionTime.
+func measureExecution(ctx cadence.Context, executionTime *time.Duration) func() {
+	start := cadence.Now(ctx)
+	return func() {
+		*executionTime = cadence.Now(ctx).Sub(start)
+	}
+}
+
+// budgetUsedUp returns true if the spec has a budget and the runs of the day of the given time used it up.
+func (s *CronState) budgetUsedUp(spec *ScheduleSpec, now time.Time) bool {
+	if spec.DailyBudget == 0 {
+		return false
+	}
+	s.rollBudget(spec, now)
+	return s.BudgetUsed >= spec.DailyBudget
+}
+
+// addBudgetUsage adds the execution time of a run that completed at the given time to the usage of its day.
+func (s *CronState) addBudgetUsage(spec *ScheduleSpec, now time.Time, executionTime time.Duration) {
+	if spec.DailyBudget == 0 {
+		return
+	}
+	s.rollBudget(spec, now)
+	s.BudgetUsed += executionTime
+}
+
+// rollBudget starts the usage of a new day if the given time is on another day than the usage.
+func (s *CronState) rollBudget(spec *ScheduleSpec, now time.Time) {
+	if day := spec.budgetDay(now); !day.Equal(s.BudgetDay) {
+		s.BudgetDay, s.BudgetUsed = day, 0
+	}
+}
+
+// budgetDay returns the start of the day of the given time in the timezone of the spec. A spec that wasn't validated
+// has no parsed timezone yet, it is parsed here, and an invalid one, which Validate rejects, falls back to UTC.
+func (s *ScheduleSpec) budgetDay(t time.Time) time.Time {
+	location := s.budgetLocation
+	if location == nil {
+		var err error
+		if location, err = loadLocation(s.Timezone); err != nil {
+			location = time.UTC
+		}
+	}
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// validateBudget checks the daily budget, the timezone of its day is checked with the calendar.
+func (s *ScheduleSpec) validateBudget() error {
+	if s.DailyBudget < 0 {
+		return fmt.Errorf("daily budget must not be negative, got %v", s.DailyBudget)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestReplay_CronWorkflowDailyBudget(t *testing.T) {
+	// every run takes a minute, the third run of the day uses up the budget of 3 minutes.
+	day := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, DailyBudget: time.Minute * 3}
+	spec.withLatestVersions()
+	start, state := day, CronState{}
+	var dispatched []time.Time
+	var states []CronState
+	for {
+		h := newHistorySimulator(t, start, SampleCronWorkflow, spec, &state)
+		h.activityDuration = time.Minute
+		closeDecision := h.run()
+		require.NoError(t, h.replay())
+		for _, input := range h.activityInputs() {
+			dispatched = append(dispatched, input.Run.DispatchTime.UTC())
+		}
+		if closeDecision.GetDecisionType() == s.DecisionType_CompleteWorkflowExecution {
+			var summary CronSummary
+			require.NoError(t, cadence.EncodedValue(
+				closeDecision.GetCompleteWorkflowExecutionDecisionAttributes().Result_).Get(&summary))
+			// the runs from 04:00 to 23:00 were skipped.
+			require.Equal(t, uint(20), summary.SkippedRuns)
+			break
+		}
+		spec, state = continuedArgs(t, closeDecision)
+		start = h.now
+		states = append(states, state)
+	}
+
+	// the runs resume once the day rolled over.
+	next := day.Add(time.Hour * 24)
+	require.Equal(t, []time.Time{day.Add(time.Hour), day.Add(time.Hour * 2), day.Add(time.Hour * 3), next,
+		next.Add(time.Hour)}, dispatched)
+	// the usage is carried over continue-as-new. The skipped runs count against the runs before continue-as-new, the
+	// first run continued after 3 runs and 7 skipped ones.
+	require.Len(t, states, 2)
+	require.Equal(t, time.Minute*3, states[0].BudgetUsed)
+	require.True(t, day.Equal(states[0].BudgetDay), states[0].BudgetDay)
+	require.Equal(t, uint(7), states[0].SkippedByBudget)
+	last := states[1].RecentRuns[len(states[1].RecentRuns)-1]
+	require.Equal(t, RunSkippedByBudget, last.Status)
+	require.Equal(t, "daily budget of 3m0s used up", last.ResultSummary)
+}
+
+func Test_CronWorkflow_DailyBudgetOverride(t *testing.T) {
+	scope, restore := withTestMetrics()
+	defer restore()
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, _ := initialWaitRuns(env)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+	}, time.Minute*90)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true, OverrideBudget: true})
+	}, time.Minute*150)
+	// the budget of the first day is used up already, in the timezone of the schedule the day ends at 20:30 UTC.
+	spec := ScheduleSpec{JobCount: 1, ScheduleInterval: time.Hour * 8, DailyBudget: time.Hour, Timezone: "Asia/Tehran"}
+	require.NoError(t, spec.Validate(time.Time{}))
+	state := &CronState{BudgetDay: spec.budgetDay(time.Unix(0, 0)), BudgetUsed: time.Hour}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, state)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	// the manual run without the override and the scheduled runs at 10:30 and 18:30 UTC were skipped, the day in
+	// Tehran rolled over before the run at 02:30 UTC.
+	require.Equal(t, []time.Duration{time.Minute * 150, time.Minute * 1590}, *runTimes)
+	var summary CronSummary
+	require.NoError(t, env.GetWorkflowResult(&summary))
+	require.Equal(t, uint(3), summary.SkippedRuns)
+	require.Equal(t, int64(3), counterValue(scope, metricRunsSkipped, map[string]string{"reason": skipReasonBudget}))
+}
+
+func Test_CronState_BudgetUsage(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 1, ScheduleInterval: time.Hour, DailyBudget: time.Hour, Timezone: "America/New_York"}
+	require.NoError(t, spec.Validate(time.Time{}))
+	var state CronState
+	// 01:00 UTC is still the day before in New York.
+	evening := time.Date(2023, 6, 2, 1, 0, 0, 0, time.UTC)
+	state.addBudgetUsage(&spec, evening, time.Minute*40)
+	state.addBudgetUsage(&spec, evening.Add(time.Hour), time.Minute*20)
+	require.True(t, state.budgetUsedUp(&spec, evening.Add(time.Hour*2)))
+	require.Equal(t, "2023-06-01T00:00:00-04:00", state.BudgetDay.Format(time.RFC3339))
+	// the day starts at 04:00 UTC.
+	require.False(t, state.budgetUsedUp(&spec, time.Date(2023, 6, 2, 4, 0, 0, 0, time.UTC)))
+	require.Equal(t, time.Duration(0), state.BudgetUsed)
+
+	// without a budget the usage is not tracked.
+	spec.DailyBudget = 0
+	state = CronState{}
+	state.addBudgetUsage(&spec, evening, time.Hour*48)
+	require.False(t, state.budgetUsedUp(&spec, evening))
+	require.Equal(t, CronState{}, state)
+
+	spec.DailyBudget = -time.Hour
+	require.EqualError(t, spec.Validate(time.Time{}), "invalid schedule: daily budget must not be negative, got -1h0m0s")
+}
+
+func Test_ScheduleSpec_BudgetDayUnvalidated(t *testing.T) {
+	evening := time.Date(2023, 6, 2, 1, 0, 0, 0, time.UTC)
+	// the timezone of a spec that wasn't validated is parsed on the fly.
+	spec := ScheduleSpec{DailyBudget: time.Hour, Timezone: "America/New_York"}
+	require.Equal(t, "2023-06-01T00:00:00-04:00", spec.budgetDay(evening).Format(time.RFC3339))
+	// an invalid one falls back to UTC instead of panicking.
+	spec.Timezone = "Nowhere/Special"
+	require.Equal(t, "2023-06-02T00:00:00Z", spec.budgetDay(evening).Format(time.RFC3339))
+	var state CronState
+	state.addBudgetUsage(&spec, evening, time.Hour)
+	require.True(t, state.budgetUsedUp(&spec, evening))
+}
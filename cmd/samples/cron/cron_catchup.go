@@ -0,0 +1,89 @@
+package main
+
+import (
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * Scheduled runs are missed when no worker processes the decision of the timer in time, e.g. because all workers were
+ * down for a while. The timer fires in the history, but the decision runs late. The workflow compares the time it was
+ * woken up at against the time the run was scheduled for, and the CatchUpPolicy of the schedule decides what happens
+ * to the scheduled runs that were missed in the meantime. Either way the regular schedule resumes from the last run
+ * that was started.
+ */
+
+// CatchUpPolicy decides what happens to the scheduled runs that were missed because the workflow woke up late.
+type CatchUpPolicy int
+
+const (
+	// CatchUpSkip drops the missed runs, only the run that woke the workflow up is started.
+	CatchUpSkip CatchUpPolicy = iota
+	// CatchUpBackfill starts the missed runs back-to-back, each with the time it was scheduled for.
+	CatchUpBackfill
+)
+
+// The backlog of missed runs is part of the spec and carried over continue-as-new, it is bounded so that a long
+// outage doesn't blow up the input of the workflow. Missed runs beyond it are skipped.
+const maxBacklog = 100
+
+func (p CatchUpPolicy) String() string {
+	switch p {
+	case CatchUpSkip:
+		return "Skip"
+	case CatchUpBackfill:
+		return "Backfill"
+	}
+	return "Unknown"
+}
+
+// missedRuns returns the times of the runs scheduled after the given one that are already due, following the given
+// number of consecutive failed runs. It returns at most limit times, and true if there are more.
+func (s *ScheduleSpec) missedRuns(ctx cadence.Context, scheduledTime time.Time, failures uint, limit int,
+	history *historyEstimate) ([]time.Time, bool) {
+	now := cadence.Now(ctx)
+	var missed []time.Time
+	for {
+		delay, skipped := s.getDelayBeforeNextRun(ctx, scheduledTime, failures, history)
+		scheduledTime = scheduledTime.Add(delay)
+		if scheduledTime.After(now) || s.isPastDeadline(scheduledTime) {
+			return missed, false
+		}
+		if len(missed) == limit {
+			return missed, true
+		}
+		s.SkippedByExclusions += skipped
+		missed = append(missed, scheduledTime)
+	}
+}
+
+// catchUp applies the catch-up policy to the runs that were missed since the run scheduled for the given time.
+func (s *ScheduleSpec) catchUp(ctx cadence.Context, scheduledTime time.Time, failures uint,
+	history *historyEstimate) {
+	missed, more := s.missedRuns(ctx, scheduledTime, failures, maxBacklog, history)
+	if len(missed) == 0 {
+		return
+	}
+	logger := workflowLogger(ctx).With(zap.Time("ScheduledTime", scheduledTime),
+		zap.Duration("Late", cadence.Now(ctx).Sub(scheduledTime)), zap.Bool("MoreMissed", more))
+	backfill := 0
+	if s.CatchUpPolicy == CatchUpBackfill && len(s.Backlog) < maxBacklog {
+		backfill = maxBacklog - len(s.Backlog)
+		if backfill > len(missed) {
+			backfill = len(missed)
+		}
+		s.Backlog = append(s.Backlog, missed[:backfill]...)
+	}
+	s.MissedRuns += uint(len(missed) - backfill)
+	if backfill > 0 {
+		logger.Info("Cron job runs missed, backfilling.", zap.Int("Missed", len(missed)),
+			zap.Int("Backfilled", backfill), zap.Int("Backlog", len(s.Backlog)))
+	}
+	if backfill < len(missed) || more {
+		// with MoreMissed the count is a lower bound, counting all missed runs of a long outage would take too long.
+		logger.Info("Cron job runs missed, skipped.", zap.Stringer("CatchUpPolicy", s.CatchUpPolicy),
+			zap.Int("Skipped", len(missed)-backfill), zap.Uint("TotalMissed", s.MissedRuns))
+	}
+}
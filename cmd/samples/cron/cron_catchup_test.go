@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_BacklogAcrossContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	var scheduledTimes []time.Time
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		scheduledTimes = append(scheduledTimes, input.ScheduledTime)
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	// the backlog left over by the previous run of the workflow, it is longer than a run of the workflow can take.
+	var backlog []time.Time
+	for i := 0; i < 12; i++ {
+		backlog = append(backlog, time.Unix(0, 0).Add(-time.Hour*time.Duration(12-i)))
+	}
+	spec := ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour, CatchUpPolicy: CatchUpBackfill, Backlog: backlog}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(backlog[:loopCountBeforeContinueAsNew], scheduledTimes)
+	s.Equal(make([]time.Duration, loopCountBeforeContinueAsNew), runTimes)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	next := args[0].(ScheduleSpec)
+	s.Equal(uint(10), next.JobCount)
+	s.Equal(backlog[loopCountBeforeContinueAsNew:], next.Backlog)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_MissedRunsBeyondBacklog() {
+	env := s.NewTestWorkflowEnvironment()
+	spec := ScheduleSpec{ScheduleInterval: time.Minute, CatchUpPolicy: CatchUpBackfill}
+	env.ExecuteWorkflow(func(ctx cadence.Context) error {
+		// the run scheduled for 0s woke the workflow up three hours late.
+		if err := cadence.Sleep(ctx, time.Hour*3); err != nil {
+			return err
+		}
+		spec.catchUp(ctx, time.Unix(0, 0), 0, newHistoryEstimate())
+		return nil
+	})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(spec.Backlog, maxBacklog)
+	s.Equal(time.Unix(0, 0).Add(time.Minute), spec.Backlog[0])
+	s.Equal(time.Unix(0, 0).Add(time.Minute*maxBacklog), spec.Backlog[maxBacklog-1])
+	s.Equal(uint(0), spec.MissedRuns)
+}
+
+func TestReplay_CronWorkflowCatchUp(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(seconds ...int) []time.Time {
+		var result []time.Time
+		for _, s := range seconds {
+			result = append(result, start.Add(time.Duration(s)*time.Second))
+		}
+		return result
+	}
+
+	// the workers are down from 90s to 270s, the runs at 120s, 180s and 240s are missed.
+	testCases := []struct {
+		policy         CatchUpPolicy
+		scheduledTimes []time.Time
+		startTimes     []time.Time
+	}{
+		// the late run at 120s runs anyway, the next run is scheduled from the time the workers are back.
+		{CatchUpSkip, at(60, 120, 330, 390, 450), at(60, 270, 330, 390, 450)},
+		// the next run is scheduled from the start of the last backfilled run.
+		{CatchUpBackfill, at(60, 120, 180, 240, 350), at(60, 270, 280, 290, 350)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, CatchUpPolicy: tc.policy}
+			h := newHistorySimulator(t, start, SampleCronWorkflow, spec, &CronState{})
+			h.activityDuration = time.Second * 10
+			h.workersDownBetween(time.Second*90, time.Second*270)
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			var scheduledTimes []time.Time
+			for _, input := range h.activityInputs() {
+				scheduledTimes = append(scheduledTimes, input.ScheduledTime)
+			}
+			require.Equal(t, tc.scheduledTimes, scheduledTimes)
+			require.Equal(t, tc.startTimes, h.activityScheduleTimes())
+		})
+	}
+}
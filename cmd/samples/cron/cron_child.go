@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * With RunAsChildWorkflow every run of the cron job is a CronJobWorkflow execution with its own ID, history and
+ * visibility record, instead of activities in the history of the cron workflow. The child runs the shards of the run
+ * the same way the cron workflow does otherwise, and returns their results.
+ *
+ * The ID of a child is derived from the ID of the cron workflow and the number of the run, which is carried over
+ * continue-as-new, so replay comes up with the same ID and every run of the schedule gets a different one. The runs in
+ * progress complete before the cron workflow continues as new, no child outlives the run that started it. When the
+ * cron workflow is cancelled its children are cancelled with it, and the cron workflow waits for them to close before
+ * it runs the cleanup. When it is terminated or times out, the server requests the cancellation of the children.
+ *
+ * This version of the client has no WorkflowIDReusePolicy. The server refuses to start a child with the ID of a
+ * workflow that is still running, e.g. a run abandoned by an earlier cron workflow with the same ID. The run fails
+ * instead of running the job twice, and the FailurePolicy decides if the schedule goes on.
+ */
+
+type (
+	// ChildWorkflowSpec configures the CronJobWorkflow executions of a schedule with RunAsChildWorkflow.
+	ChildWorkflowSpec struct {
+		// TaskList of the child workflows, empty means the task list of the cron workflow.
+		TaskList string
+		// ExecutionTimeout of a child workflow, zero means Timeouts.Workflow. A run has to complete within the run of
+		// the cron workflow that started it.
+		ExecutionTimeout time.Duration
+		// DecisionTimeout of a child workflow, zero means Timeouts.Decision.
+		DecisionTimeout time.Duration
+	}
+
+	// CronRunInput is the input of a CronJobWorkflow execution.
+	CronRunInput struct {
+		// Spec is the spec of the schedule as of the start of the run.
+		Spec          ScheduleSpec
+		ScheduledTime time.Time
+		LastResults   []CronJobResult
+		// Run is the RunContext of the run, its FireTime is the ScheduledTime.
+		Run RunContext
+	}
+)
+
+// CronJobWorkflow executes one run of the cron job as a child of SampleCronWorkflow, it returns the results of the
+// shards.
+func CronJobWorkflow(ctx cadence.Context, input CronRunInput) ([]CronJobResult, error) {
+	ctx = cadence.WithActivityOptions(ctx, input.Spec.activityOptions())
+	ctx = withTraceID(ctx, input.Spec.TraceID)
+	run := input.Run
+	if run.FireTime.IsZero() {
+		// a child started by a parent without RunContexts.
+		run.FireTime = input.ScheduledTime
+	}
+	return runShards(ctx, input.Spec, input.LastResults, run, newHistoryEstimate())
+}
+
+// childWorkflowOptions returns the options of the child workflow of the given run.
+func (s *ScheduleSpec) childWorkflowOptions(ctx cadence.Context, run uint) cadence.ChildWorkflowOptions {
+	timeouts := s.timeouts()
+	options := cadence.ChildWorkflowOptions{
+		WorkflowID:                   fmt.Sprintf("%s-run-%d", cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID, run),
+		TaskList:                     s.ChildWorkflow.TaskList,
+		ExecutionStartToCloseTimeout: s.ChildWorkflow.ExecutionTimeout,
+		TaskStartToCloseTimeout:      s.ChildWorkflow.DecisionTimeout,
+		ChildPolicy:                  cadence.ChildWorkflowPolicyRequestCancel,
+		// the cleanup of a cancelled cron workflow runs after its children closed.
+		WaitForCancellation: true,
+	}
+	if options.ExecutionStartToCloseTimeout == 0 {
+		options.ExecutionStartToCloseTimeout = timeouts.Workflow
+	}
+	if options.TaskStartToCloseTimeout == 0 {
+		options.TaskStartToCloseTimeout = timeouts.Decision
+	}
+	return options
+}
+
+// validateChildWorkflow checks that a child workflow can complete within the run of the cron workflow.
+func (s *ScheduleSpec) validateChildWorkflow() error {
+	c := s.ChildWorkflow
+	if c.ExecutionTimeout < 0 || c.DecisionTimeout < 0 {
+		return errors.New("child workflow timeouts must not be negative")
+	}
+	if c.ExecutionTimeout > s.timeouts().Workflow {
+		return errors.New("child workflow execution timeout must not exceed the workflow timeout")
+	}
+	return nil
+}
+
+// runChild executes one run of the job as a CronJobWorkflow execution, and waits for it. A failed child has no
+// result, all shards keep their last results then.
+func runChild(ctx cadence.Context, spec ScheduleSpec, lastResults []CronJobResult, run RunContext) ([]CronJobResult,
+	error) {
+	options := spec.childWorkflowOptions(ctx, run.Sequence)
+	ctx = cadence.WithChildWorkflowOptions(ctx, options)
+	input := CronRunInput{Spec: spec, ScheduledTime: run.FireTime, LastResults: lastResults, Run: run}
+	var results []CronJobResult
+	if err := cadence.ExecuteChildWorkflow(ctx, CronJobWorkflow, input).Get(ctx, &results); err != nil {
+		workflowLogger(ctx).Error("Cron job child workflow failed.", zap.String("WorkflowID", options.WorkflowID),
+			zap.Error(err))
+		return lastResults, err
+	}
+	return results, nil
+}
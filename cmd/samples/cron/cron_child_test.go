@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// childWorkflowIDs collects the IDs of the CronJobWorkflow executions.
+func childWorkflowIDs(env *cadence.TestWorkflowEnvironment) *[]string {
+	var ids []string
+	env.SetOnChildWorkflowStartedListener(func(info *cadence.WorkflowInfo, ctx cadence.Context, args cadence.EncodedValues) {
+		ids = append(ids, info.WorkflowExecution.ID)
+	})
+	return &ids
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_RunAsChildWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		return CronJobResult{ProcessedBatches: input.LastResult.ProcessedBatches + 1}, nil
+	})
+	ids := childWorkflowIDs(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+		RunAsChildWorkflow: true, Parallelism: 2}, &CronState{TotalRuns: 5})
+
+	s.True(env.IsWorkflowCompleted())
+	// the run numbers continue from the runs of earlier runs of the cron workflow.
+	s.Len(*ids, loopCountBeforeContinueAsNew)
+	s.Equal("default-test-workflow-id-run-6", (*ids)[0])
+	s.Equal("default-test-workflow-id-run-15", (*ids)[9])
+	state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+	s.Equal(uint(15), state.TotalRuns)
+	s.Equal([]CronJobResult{{ProcessedBatches: 10}, {ProcessedBatches: 10}}, state.LastResults)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ChildWorkflowFails() {
+	env := s.NewTestWorkflowEnvironment()
+	var lastResults []CronJobResult
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		lastResults = append(lastResults, input.LastResult)
+		if len(lastResults) == 2 {
+			return CronJobResult{}, errors.New("job failed")
+		}
+		return CronJobResult{ProcessedBatches: input.LastResult.ProcessedBatches + 1}, nil
+	})
+	ids := childWorkflowIDs(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		RunAsChildWorkflow: true, FailurePolicy: FailureContinue}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(*ids, 3)
+	// the failed second run kept the result of the first one.
+	s.Equal([]CronJobResult{{}, {ProcessedBatches: 1}, {ProcessedBatches: 1}}, lastResults)
+
+	env = s.NewTestWorkflowEnvironment()
+	failingRuns(env, 1)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		RunAsChildWorkflow: true}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "job failed")
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_CancelWithChildWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{}, nil)
+		}, time.Minute)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	var cancelledChildren []string
+	env.SetOnChildWorkflowCanceledListener(func(info *cadence.WorkflowInfo) {
+		cancelledChildren = append(cancelledChildren, info.WorkflowExecution.ID)
+	})
+	var cleanups []CronCleanupInput
+	env.OverrideActivity(cronCleanupActivity, func(ctx context.Context, input CronCleanupInput) error {
+		cleanups = append(cleanups, input)
+		return nil
+	})
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Second*90)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+		RunAsChildWorkflow: true}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.CanceledError)
+	s.True(ok, "expected a CanceledError, got %v", env.GetWorkflowError())
+	s.Equal([]string{"default-test-workflow-id-run-1"}, cancelledChildren)
+	s.Equal([]CronCleanupInput{{PendingJobCount: 4, TotalRuns: 1}}, cleanups)
+}
+
+func (s *UnitTestSuite) Test_ChildWorkflowSpec_Validate() {
+	validate := func(c ChildWorkflowSpec) error {
+		return (&ScheduleSpec{ScheduleInterval: time.Minute, RunAsChildWorkflow: true, ChildWorkflow: c}).Validate(time.Time{})
+	}
+	s.NoError(validate(ChildWorkflowSpec{}))
+	s.NoError(validate(ChildWorkflowSpec{ExecutionTimeout: workflowTimeout}))
+	s.Error(validate(ChildWorkflowSpec{ExecutionTimeout: workflowTimeout + time.Second}))
+	s.Error(validate(ChildWorkflowSpec{DecisionTimeout: -time.Second}))
+}
+
+func TestReplay_CronWorkflowChildWorkflowIDCollision(t *testing.T) {
+	testCases := []struct {
+		policy FailurePolicy
+		closed s.DecisionType
+	}{
+		// the second run is still running from an earlier cron workflow with the same ID, it is not started twice.
+		{FailureAbort, s.DecisionType_FailWorkflowExecution},
+		{FailureContinue, s.DecisionType_CompleteWorkflowExecution},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute, RunAsChildWorkflow: true,
+				FailurePolicy: tc.policy}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+			h.activityDuration = time.Second * 10
+			h.runningWorkflowIDs = map[string]bool{"replay-workflow-run-2": true}
+
+			closeDecision := h.run()
+			require.Equal(t, tc.closed, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			expected := []string{"replay-workflow-run-1", "replay-workflow-run-2", "replay-workflow-run-3"}
+			if tc.policy == FailureAbort {
+				expected = expected[:2]
+			}
+			require.Equal(t, expected, h.childWorkflowIDs())
+			// the activities run in the histories of the child workflows.
+			require.Empty(t, h.activityScheduleTimes())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * Cancelling the cron workflow cancels the wait for the next run and the activities of the runs in progress. Once they
+ * stopped, the workflow runs cronCleanupActivity to release what the schedule holds, and closes as cancelled. The
+ * cleanup can't use the context of the workflow, anything started with a cancelled context is cancelled right away.
+ */
+
+// CronCleanupInput is the input of the cronCleanupActivity execution.
+type CronCleanupInput struct {
+	// PendingJobCount is the number of runs that won't happen because the schedule was cancelled.
+	PendingJobCount uint
+	TotalRuns       uint
+	LastRunTime     time.Time
+}
+
+// cronCleanupActivity is the cleanup of the cron sample, it runs once when the schedule is cancelled.
+func cronCleanupActivity(ctx context.Context, input CronCleanupInput) error {
+	// ...
+	// release the lease of the schedule and record that it stopped.
+	activityLogger(ctx).Info("Cron schedule stopped.", zap.Uint("PendingJobCount", input.PendingJobCount),
+		zap.Uint("TotalRuns", input.TotalRuns), zap.Time("LastRunTime", input.LastRunTime))
+	return nil
+}
+
+// onCancel waits for the cancelled runs in progress to stop, runs the cleanup, and returns the error that closes the
+// workflow as cancelled, with the summary of the schedule as its details.
+func onCancel(ctx cadence.Context, ao cadence.ActivityOptions, spec *ScheduleSpec, state *CronState,
+	jobs *cronJobs) error {
+	workflowLogger(ctx).Info("Cron workflow cancelled.", zap.Uint("PendingJobCount", spec.JobCount))
+	jobs.wait(ctx)
+
+	cleanupCtx := cadence.WithActivityOptions(common.NewDisconnectedContext(ctx), ao)
+	input := CronCleanupInput{PendingJobCount: spec.JobCount, TotalRuns: state.TotalRuns, LastRunTime: state.LastRunTime}
+	if err := cadence.ExecuteActivity(cleanupCtx, cronCleanupActivity, input).Get(cleanupCtx, nil); err != nil {
+		workflowLogger(ctx).Error("Cron schedule cleanup failed.", zap.Error(err))
+	}
+	return cadence.NewCanceledError(closeSchedule(cleanupCtx, spec, state, summaryCancelled))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_Cancel() {
+	testCases := []struct {
+		name     string
+		cancelAt time.Duration
+		// the number of sampleCronActivity executions and how many of them are cancelled.
+		runs, cancelled int
+	}{
+		{"during sleep", time.Second * 90, 1, 0},
+		{"during activity", time.Second * 150, 2, 1},
+	}
+	for _, tc := range testCases {
+		env := s.NewTestWorkflowEnvironment()
+		var runs, cancelled int
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			runs++
+			if runs == 1 {
+				return CronJobResult{}, nil
+			}
+			// the second run takes longer than the wait for the cancellation.
+			taskToken := cadence.GetActivityInfo(ctx).TaskToken
+			env.RegisterDelayedCallback(func() {
+				env.CompleteActivity(taskToken, CronJobResult{}, nil)
+			}, time.Minute)
+			return CronJobResult{}, cadence.ErrActivityResultPending
+		})
+		env.SetOnActivityCanceledListener(func(info *cadence.ActivityInfo) {
+			cancelled++
+		})
+		var cleanups []CronCleanupInput
+		env.OverrideActivity(cronCleanupActivity, func(ctx context.Context, input CronCleanupInput) error {
+			cleanups = append(cleanups, input)
+			return nil
+		})
+		env.RegisterDelayedCallback(env.CancelWorkflow, tc.cancelAt)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		_, ok := env.GetWorkflowError().(cadence.CanceledError)
+		s.True(ok, "%s: expected a CanceledError, got %v", tc.name, env.GetWorkflowError())
+		s.Equal(tc.runs, runs, tc.name)
+		s.Equal(tc.cancelled, cancelled, tc.name)
+		s.Equal([]CronCleanupInput{{PendingJobCount: uint(5 - tc.runs), TotalRuns: uint(tc.runs),
+			LastRunTime: time.Unix(0, 0).Add(time.Minute)}}, cleanups, tc.name)
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * The verbs drive a running cron workflow from the command line:
+ *
+ *   cron signal --workflow-id X --name pause [--input json|file]
+ *   cron query --workflow-id X [--type status|spec|recentRuns]
+ *   cron cancel --workflow-id X
+ *   cron terminate --workflow-id X --reason "..."
+ *   cron list [--open|--closed|--all] [--page-size N] [--format table|json]
+ *   cron describe --workflow-id X
+ *   cron history --workflow-id X [--output file]
+ *   cron export [--output file]
+ *   cron apply --file schedules.json [--dry-run] [--replace]
+ *
+ * Every verb takes --timeout, and every verb but history and export takes --output text|json, see cron_output.go for
+ * the JSON and the exit codes.
+ *
+ * This version of the client has no queries, a query decodes the input of the current run of the workflow, which is
+ * the schedule and the state as of the last continue-as-new.
+ *
+ * Newer clients query the workflow itself with QueryWorkflowWithOptions. A query with QueryConsistencyLevelStrong sees
+ * the signals the workflow received right before it, an eventually consistent one may miss those that wait for a
+ * decision task, and QueryRejectCondition rejects the query of a workflow that is not open instead of answering it.
+ * None of that applies to this version: the input of the run doesn't change with signals at all, a pause shows once
+ * the workflow continued as new, and a closed workflow is answered like an open one.
+ */
+
+const (
+	exitFailed        = 1
+	exitUsage         = 2
+	exitNotFound      = 3
+	exitAlreadyExists = 4
+	exitServer        = 5
+
+	defaultTerminateReason = "terminated from the command line"
+)
+
+// cliVerbs are the verbs of the command line, the first argument.
+var cliVerbs = map[string]bool{"signal": true, "query": true, "cancel": true, "terminate": true, "list": true,
+	"describe": true, "history": true, "export": true, "apply": true}
+
+// clusterVerbs are the verbs of all the cron workflows, they don't take a workflow ID.
+var clusterVerbs = map[string]bool{"list": true, "export": true, "apply": true}
+
+type (
+	// cliCommand is a verb of the command line with its arguments.
+	cliCommand struct {
+		Verb       string
+		WorkflowID string
+		RunID      string
+		ConfigFile string
+		// Signal is the name of the signal, and Input its JSON payload.
+		Signal string
+		Input  []byte
+		// QueryType is what a query prints.
+		QueryType string
+		Reason    string
+		// Status is the executions a list prints: open, closed or all, PageSize the executions of a page of the
+		// visibility API, and Format table or json.
+		Status   string
+		PageSize int
+		Format   string
+		// Output is the file a history or an export is written to, empty for stdout.
+		Output string
+		// File is the schedule document an apply reads, DryRun prints its plan only, and Replace restarts the
+		// schedules that can't be updated by signal.
+		File    string
+		DryRun  bool
+		Replace bool
+		// JSON prints the result and the error of the verb as JSON objects, Timeout bounds the verb.
+		JSON    bool
+		Timeout time.Duration
+	}
+
+	// cliUsageError is an error of the arguments of the command line.
+	cliUsageError struct {
+		error
+	}
+
+	// scheduleStatus is printed by the status query.
+	scheduleStatus struct {
+		WorkflowID          string
+		Schedule            string `json:",omitempty"`
+		Paused              bool
+		Draining            bool
+		PendingJobCount     uint
+		TotalRuns           uint
+		SuccessfulRuns      uint
+		FailedRuns          uint
+		ConsecutiveFailures uint
+		// EffectiveInterval is the interval before the next run with the backoff of the consecutive failures, empty
+		// for a schedule by time of day.
+		EffectiveInterval string `json:",omitempty"`
+		LastRunTime       time.Time
+		// InitialWait is set while the first scheduled run waits for the StartAt.
+		InitialWait bool       `json:",omitempty"`
+		StartAt     *time.Time `json:",omitempty"`
+		// DeadLetterBuffer is the number of dead letters whose delivery failed, and DroppedDeadLetters the ones dropped
+		// from the full buffer.
+		DeadLetterBuffer   int  `json:",omitempty"`
+		DroppedDeadLetters uint `json:",omitempty"`
+		// BudgetUsed is the time the runs executed on the BudgetDay of a schedule with a DailyBudget, as of the last
+		// continue-as-new, and SkippedByBudget the runs skipped because it was used up.
+		BudgetUsed      string     `json:",omitempty"`
+		BudgetDay       *time.Time `json:",omitempty"`
+		SkippedByBudget uint       `json:",omitempty"`
+	}
+)
+
+// signalPayloads decode the JSON payloads of the signals of the cron workflow into their types, an empty payload is the
+// zero value.
+var signalPayloads = map[string]func(data []byte) (interface{}, error){
+	pauseSignalName:   decodeReason,
+	resumeSignalName:  decodeReason,
+	drainSignalName:   decodeReason,
+	skipRunSignalName: decodeReason,
+	updateScheduleSignalName: func(data []byte) (interface{}, error) {
+		// the interval is a duration string, e.g. {"scheduleInterval": "1m"}.
+		var payload struct{ ScheduleInterval string }
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return nil, err
+			}
+		}
+		interval, err := time.ParseDuration(payload.ScheduleInterval)
+		if err != nil {
+			return nil, fmt.Errorf("scheduleInterval %q is not a duration, e.g. 1m", payload.ScheduleInterval)
+		}
+		update := ScheduleUpdate{ScheduleInterval: interval}
+		return update, update.validate()
+	},
+	triggerNowSignalName: func(data []byte) (interface{}, error) {
+		var request TriggerNowRequest
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &request); err != nil {
+				return nil, err
+			}
+		}
+		return request, nil
+	},
+}
+
+func decodeReason(data []byte) (interface{}, error) {
+	var reason string
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &reason); err != nil {
+			return nil, fmt.Errorf("the input is the reason, a JSON string: %v", err)
+		}
+	}
+	return reason, nil
+}
+
+func signalNames() string {
+	var names []string
+	for name := range signalPayloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// readInput returns the given input if it is JSON, or the content of the file it names.
+func readInput(input string) ([]byte, error) {
+	if input == "" || json.Valid([]byte(input)) {
+		return []byte(input), nil
+	}
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("input is neither JSON nor a readable file: %v", err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("input file %s is not JSON", input)
+	}
+	return data, nil
+}
+
+// parseCommand parses the arguments of the verb given first. The flags shared by the samples, e.g. -tls-ca, are
+// accepted by every verb, it is called before the flags of the runner are defined.
+func parseCommand(args []string, output io.Writer) (cliCommand, error) {
+	var command cliCommand
+	if len(args) == 0 || !cliVerbs[args[0]] {
+		return command, cliUsageError{errors.New(
+			"the verb is signal, query, cancel, terminate, list, describe, history, export or apply")}
+	}
+	command.Verb = args[0]
+	var input, format string
+	var open, closed, all bool
+	flags := flag.NewFlagSet(command.Verb, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		flags.Var(f.Value, f.Name, f.Usage)
+	})
+	flags.StringVar(&command.ConfigFile, "config", "", "Path of the config file, default is $CADENCE_SAMPLES_CONFIG or config/development.yaml.")
+	if !clusterVerbs[command.Verb] {
+		flags.StringVar(&command.WorkflowID, "workflow-id", "", "WorkflowID of the cron workflow.")
+		flags.StringVar(&command.RunID, "run-id", "", "RunID of the cron workflow, default is the current run.")
+	}
+	if command.Verb != "history" && command.Verb != "export" {
+		flags.StringVar(&format, "output", outputText, "Output format: text or json, json prints one JSON object.")
+	}
+	flags.DurationVar(&command.Timeout, "timeout", defaultCommandTimeout, "Time the verb waits for the frontend, 0 waits for the retries of the client.")
+	switch command.Verb {
+	case "signal":
+		flags.StringVar(&command.Signal, "name", "", "Name of the signal: "+signalNames()+".")
+		flags.StringVar(&input, "input", "", "JSON payload of the signal, or the path of a file with it.")
+	case "query":
+		flags.StringVar(&command.QueryType, "type", "status", "What to print: status, spec or recentRuns.")
+	case "terminate":
+		flags.StringVar(&command.Reason, "reason", defaultTerminateReason, "Reason of the termination.")
+	case "list":
+		flags.BoolVar(&open, "open", false, "List the open executions, the default.")
+		flags.BoolVar(&closed, "closed", false, "List the closed executions.")
+		flags.BoolVar(&all, "all", false, "List the open and the closed executions.")
+		flags.IntVar(&command.PageSize, "page-size", defaultPageSize, "Executions of a page of the visibility API, all the pages are listed.")
+		flags.StringVar(&command.Format, "format", "table", "Output format: table or json.")
+	case "history":
+		flags.StringVar(&command.Output, "output", "", "File to write the history JSON to, default is stdout.")
+	case "export":
+		flags.StringVar(&command.Output, "output", "", "File to write the schedule document to, default is stdout.")
+	case "apply":
+		flags.StringVar(&command.File, "file", "", "Schedule document to apply, as written by export.")
+		flags.BoolVar(&command.DryRun, "dry-run", false, "Print the planned actions without applying them.")
+		flags.BoolVar(&command.Replace, "replace", false, "Terminate and start again the schedules whose changes can't be signalled.")
+	}
+	err := flags.Parse(args[1:])
+	// the error of a flag after --output json is printed as JSON too.
+	command.JSON = format == outputJSON
+	if err == flag.ErrHelp {
+		return command, err
+	} else if err != nil {
+		return command, cliUsageError{err}
+	}
+	if format != "" && format != outputText && format != outputJSON {
+		return command, cliUsageError{fmt.Errorf("unknown output %q, the outputs are text and json", format)}
+	}
+	if flags.NArg() > 0 {
+		return command, cliUsageError{fmt.Errorf("unexpected arguments %v", flags.Args())}
+	}
+	if command.WorkflowID == "" && !clusterVerbs[command.Verb] {
+		return command, cliUsageError{errors.New("--workflow-id is required")}
+	}
+	switch command.Verb {
+	case "signal":
+		if _, ok := signalPayloads[command.Signal]; !ok {
+			return command, cliUsageError{fmt.Errorf("unknown signal %q, the signals are %s", command.Signal,
+				signalNames())}
+		}
+		data, err := readInput(input)
+		if err != nil {
+			return command, cliUsageError{err}
+		}
+		command.Input = data
+	case "query":
+		if command.QueryType != "status" && command.QueryType != "spec" && command.QueryType != "recentRuns" {
+			return command, cliUsageError{fmt.Errorf("unknown query type %q, the types are status, spec and recentRuns",
+				command.QueryType)}
+		}
+	case "list":
+		switch {
+		case open && closed || open && all || closed && all:
+			return command, cliUsageError{errors.New("only one of --open, --closed and --all may be set")}
+		case closed:
+			command.Status = "closed"
+		case all:
+			command.Status = "all"
+		default:
+			command.Status = "open"
+		}
+		if command.PageSize <= 0 {
+			return command, cliUsageError{fmt.Errorf("--page-size must be positive, got %d", command.PageSize)}
+		}
+		if command.Format != "table" && command.Format != "json" {
+			return command, cliUsageError{fmt.Errorf("unknown format %q, the formats are table and json", command.Format)}
+		}
+	case "apply":
+		if command.File == "" {
+			return command, cliUsageError{errors.New("--file is required")}
+		}
+	}
+	return command, nil
+}
+
+// runCommand runs the command with the given client, a query, a list, a describe, a history, an export and an apply
+// print to out.
+func runCommand(client cadence.Client, command cliCommand, out io.Writer) error {
+	switch command.Verb {
+	case "signal":
+		payload, err := signalPayloads[command.Signal](command.Input)
+		if err != nil {
+			return cliUsageError{fmt.Errorf("invalid input of signal %s: %v", command.Signal, err)}
+		}
+		if err := client.SignalWorkflow(command.WorkflowID, command.RunID, command.Signal, payload); err != nil {
+			return err
+		}
+		return printResult(out, command, signalResult{command.WorkflowID, command.RunID, command.Signal})
+	case "query":
+		return queryWorkflow(client, command, out)
+	case "cancel":
+		if err := client.CancelWorkflow(command.WorkflowID, command.RunID); err != nil {
+			return err
+		}
+		return printResult(out, command, closeResult{WorkflowID: command.WorkflowID, RunID: command.RunID})
+	case "terminate":
+		if err := client.TerminateWorkflow(command.WorkflowID, command.RunID, command.Reason, nil); err != nil {
+			return err
+		}
+		return printResult(out, command, closeResult{command.WorkflowID, command.RunID, command.Reason})
+	case "list":
+		return listExecutions(client, command, out)
+	case "describe":
+		return describeExecution(client, command, out)
+	case "history":
+		return exportHistory(client, command, out)
+	case "export":
+		return exportSchedules(client, command, out)
+	case "apply":
+		return applySchedules(client, command, out)
+	}
+	return cliUsageError{fmt.Errorf("unknown verb %s", command.Verb)}
+}
+
+// runInput returns the schedule and the state in the input of the run of the cron workflow, and the event that
+// started the run.
+func runInput(client cadence.Client, workflowID, runID string) (ScheduleSpec, *CronState, *shared.HistoryEvent,
+	error) {
+	var spec ScheduleSpec
+	state := &CronState{}
+	history, err := client.GetWorkflowHistory(workflowID, runID)
+	if err != nil {
+		return spec, nil, nil, err
+	}
+	if len(history.Events) == 0 {
+		return spec, nil, nil, fmt.Errorf("workflow %s has no history", workflowID)
+	}
+	input := history.Events[0].GetWorkflowExecutionStartedEventAttributes().GetInput()
+	if err := cadence.EncodedValues(input).Get(&spec, state); err != nil {
+		return spec, nil, nil, fmt.Errorf("workflow %s is not a cron workflow: %v", workflowID, err)
+	}
+	return spec, state, history.Events[0], nil
+}
+
+// queryWorkflow prints the schedule or the state in the input of the run as JSON.
+func queryWorkflow(client cadence.Client, command cliCommand, out io.Writer) error {
+	spec, state, started, err := runInput(client, command.WorkflowID, command.RunID)
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	switch command.QueryType {
+	case "status":
+		status := scheduleStatus{
+			WorkflowID:          command.WorkflowID,
+			Schedule:            spec.Name,
+			Paused:              spec.Paused,
+			Draining:            spec.Draining,
+			PendingJobCount:     spec.JobCount,
+			TotalRuns:           state.TotalRuns,
+			SuccessfulRuns:      state.SuccessfulRuns,
+			FailedRuns:          state.FailedRuns,
+			ConsecutiveFailures: state.ConsecutiveFailures,
+			LastRunTime:         state.LastRunTime,
+			DeadLetterBuffer:    len(state.DeadLetters),
+			DroppedDeadLetters:  state.DroppedDeadLetters,
+			SkippedByBudget:     state.SkippedByBudget,
+		}
+		if spec.DailyBudget > 0 {
+			status.BudgetUsed = fmt.Sprintf("%v of %v", state.BudgetUsed, spec.DailyBudget)
+			if !state.BudgetDay.IsZero() {
+				status.BudgetDay = &state.BudgetDay
+			}
+		}
+		if spec.TimeOfDay == "" && len(spec.Jobs) == 0 {
+			status.EffectiveInterval = spec.backoffInterval(state.ConsecutiveFailures).String()
+		}
+		if spec.InitialDelay > 0 && state.StartTime.IsZero() {
+			// the first run of the schedule, it turns the delay into a StartAt from the start of the run.
+			spec.startInitialWait(time.Unix(0, started.GetTimestamp()))
+		}
+		if spec.waitsForStart() {
+			status.InitialWait, status.StartAt = true, &spec.StartAt
+		}
+		result = status
+	case "spec":
+		result = spec
+	case "recentRuns":
+		result = state.RecentRuns
+	}
+	if command.JSON {
+		return printJSONLine(out, queryResult{command.WorkflowID, command.RunID, command.QueryType, result})
+	}
+	return printJSON(out, result)
+}
+
+// runCLI runs the verb of the given arguments, and returns the exit code.
+func runCLI(args []string) int {
+	command, err := parseCommand(args, os.Stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return reportError(os.Stderr, command, err)
+	}
+	h := common.SampleHelper{ConfigFile: command.ConfigFile}
+	h.SetupServiceConfig()
+	client, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		return reportError(os.Stderr, command, err)
+	}
+	return runAndReport(client, command, os.Stdout, os.Stderr)
+}
+
+// runAndReport runs the command within its timeout, prints its error to errOut, and returns the exit code.
+func runAndReport(client cadence.Client, command cliCommand, out, errOut io.Writer) int {
+	err := withTimeout(command.Timeout, func() error {
+		return runCommand(client, command, out)
+	})
+	return reportError(errOut, command, err)
+}
+
+// commandExitCode returns the exit code and the message of the error of a command.
+func commandExitCode(command cliCommand, err error) (int, string) {
+	code := exitCode(err)
+	switch err := err.(type) {
+	case nil:
+		return 0, ""
+	case cliUsageError:
+		return code, err.Error()
+	case *shared.EntityNotExistsError:
+		return code, fmt.Sprintf("workflow %s not found or already completed: %s", command.WorkflowID, err.Message)
+	case *shared.BadRequestError:
+		return code, fmt.Sprintf("bad request for workflow %s: %s", command.WorkflowID, err.Message)
+	}
+	if command.WorkflowID == "" {
+		return code, fmt.Sprintf("%s failed: %v", command.Verb, err)
+	}
+	return code, fmt.Sprintf("%s of workflow %s failed: %v", command.Verb, command.WorkflowID, err)
+}
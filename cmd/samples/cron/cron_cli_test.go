@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+// recordingClient is a cadence.Client that records the requests of the verbs, and fails them with err.
+type recordingClient struct {
+	cadence.Client
+	calls []interface{}
+	input []byte
+	err   error
+}
+
+type (
+	signalCall struct {
+		WorkflowID, RunID, Signal string
+		Arg                       interface{}
+	}
+	cancelCall struct {
+		WorkflowID, RunID string
+	}
+	terminateCall struct {
+		WorkflowID, RunID, Reason string
+	}
+)
+
+func (c *recordingClient) SignalWorkflow(workflowID string, runID string, signalName string, arg interface{}) error {
+	c.calls = append(c.calls, signalCall{workflowID, runID, signalName, arg})
+	return c.err
+}
+
+func (c *recordingClient) CancelWorkflow(workflowID string, runID string) error {
+	c.calls = append(c.calls, cancelCall{workflowID, runID})
+	return c.err
+}
+
+func (c *recordingClient) TerminateWorkflow(workflowID string, runID string, reason string, details []byte) error {
+	c.calls = append(c.calls, terminateCall{workflowID, runID, reason})
+	return c.err
+}
+
+func (c *recordingClient) GetWorkflowHistory(workflowID string, runID string) (*shared.History, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &shared.History{Events: []*shared.HistoryEvent{{
+		WorkflowExecutionStartedEventAttributes: &shared.WorkflowExecutionStartedEventAttributes{Input: c.input},
+	}}}, nil
+}
+
+// runArgs parses and runs the given arguments with the client, and returns the output.
+func runArgs(t *testing.T, client *recordingClient, args ...string) (string, error) {
+	command, err := parseCommand(args, ioutil.Discard)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	err = runCommand(client, command, &out)
+	return out.String(), err
+}
+
+func Test_ParseCommand(t *testing.T) {
+	command, err := parseCommand([]string{"signal", "--workflow-id", "cron_1", "--name", "pause", "--input",
+		`"maintenance"`}, ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, cliCommand{Verb: "signal", WorkflowID: "cron_1", Signal: "pause", Input: []byte(`"maintenance"`),
+		Timeout: defaultCommandTimeout}, command)
+
+	command, err = parseCommand([]string{"query", "-workflow-id", "cron_1", "-run-id", "run-1"}, ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, cliCommand{Verb: "query", WorkflowID: "cron_1", RunID: "run-1", QueryType: "status",
+		Timeout: defaultCommandTimeout}, command)
+
+	command, err = parseCommand([]string{"terminate", "--workflow-id", "cron_1", "--config", "staging.yaml"},
+		ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, cliCommand{Verb: "terminate", WorkflowID: "cron_1", ConfigFile: "staging.yaml",
+		Reason: defaultTerminateReason, Timeout: defaultCommandTimeout}, command)
+
+	// the input is read from a file that isn't JSON itself.
+	path := filepath.Join(t.TempDir(), "update.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"scheduleInterval": "1m"}`), 0644))
+	command, err = parseCommand([]string{"signal", "--workflow-id", "cron_1", "--name", "updateSchedule", "--input",
+		path}, ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, `{"scheduleInterval": "1m"}`, string(command.Input))
+}
+
+func Test_ParseCommand_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"pause"}, "the verb is"},
+		{[]string{"cancel"}, "--workflow-id is required"},
+		{[]string{"cancel", "--workflow-id", "cron_1", "extra"}, "unexpected arguments"},
+		{[]string{"cancel", "--workflow-id", "cron_1", "--name", "pause"}, "not defined"},
+		{[]string{"signal", "--workflow-id", "cron_1", "--name", "stop"}, "unknown signal \"stop\""},
+		{[]string{"signal", "--workflow-id", "cron_1", "--name", "pause", "--input", "missing.json"}, "neither JSON"},
+		{[]string{"query", "--workflow-id", "cron_1", "--type", "history"}, "unknown query type"},
+	} {
+		_, err := parseCommand(tc.args, ioutil.Discard)
+		require.IsType(t, cliUsageError{}, err, "%v", tc.args)
+		require.Contains(t, err.Error(), tc.message)
+	}
+}
+
+func Test_RunCommand_Signal(t *testing.T) {
+	client := &recordingClient{}
+	_, err := runArgs(t, client, "signal", "--workflow-id", "cron_1", "--name", "pause", "--input", `"maintenance"`)
+	require.NoError(t, err)
+	_, err = runArgs(t, client, "signal", "--workflow-id", "cron_1", "--name", "resume")
+	require.NoError(t, err)
+	_, err = runArgs(t, client, "signal", "--workflow-id", "cron_1", "--name", "updateSchedule", "--input",
+		`{"scheduleInterval": "90s"}`)
+	require.NoError(t, err)
+	_, err = runArgs(t, client, "signal", "--workflow-id", "cron_1", "--run-id", "run-1", "--name", "triggerNow",
+		"--input", `{"keepJobCount": true}`)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		signalCall{"cron_1", "", pauseSignalName, "maintenance"},
+		signalCall{"cron_1", "", resumeSignalName, ""},
+		signalCall{"cron_1", "", updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Second * 90}},
+		signalCall{"cron_1", "run-1", triggerNowSignalName, TriggerNowRequest{KeepJobCount: true}},
+	}, client.calls)
+
+	// an invalid payload isn't sent.
+	client = &recordingClient{}
+	_, err = runArgs(t, client, "signal", "--workflow-id", "cron_1", "--name", "updateSchedule", "--input",
+		`{"scheduleInterval": "0s"}`)
+	require.IsType(t, cliUsageError{}, err)
+	_, err = runArgs(t, client, "signal", "--workflow-id", "cron_1", "--name", "drain", "--input", `{"reason": 1}`)
+	require.IsType(t, cliUsageError{}, err)
+	require.Empty(t, client.calls)
+}
+
+func Test_RunCommand_CancelAndTerminate(t *testing.T) {
+	client := &recordingClient{}
+	_, err := runArgs(t, client, "cancel", "--workflow-id", "cron_1")
+	require.NoError(t, err)
+	_, err = runArgs(t, client, "terminate", "--workflow-id", "cron_1", "--run-id", "run-1", "--reason", "stuck")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{cancelCall{"cron_1", ""}, terminateCall{"cron_1", "run-1", "stuck"}}, client.calls)
+}
+
+func Test_RunCommand_Query(t *testing.T) {
+	lastRun := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+	spec := ScheduleSpec{Name: "nightly", JobCount: 4, ScheduleInterval: time.Hour, Paused: true}
+	state := &CronState{TotalRuns: 3, SuccessfulRuns: 2, FailedRuns: 1, LastRunTime: lastRun,
+		RecentRuns: []RunRecord{{Job: "reports", Status: RunSucceeded}}}
+	client := &recordingClient{input: encodeValues(t, spec, state)}
+
+	out, err := runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "WorkflowID": "cron_nightly",
+  "Schedule": "nightly",
+  "Paused": true,
+  "Draining": false,
+  "PendingJobCount": 4,
+  "TotalRuns": 3,
+  "SuccessfulRuns": 2,
+  "FailedRuns": 1,
+  "ConsecutiveFailures": 0,
+  "EffectiveInterval": "1h0m0s",
+  "LastRunTime": "2023-06-01T08:00:00Z"
+}
+`, out)
+
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly", "--type", "recentRuns")
+	require.NoError(t, err)
+	require.Contains(t, out, `"Job": "reports"`)
+
+	// the interval of a schedule after failed runs is backed off.
+	spec = ScheduleSpec{JobCount: 4, ScheduleInterval: time.Hour, BackoffCoefficient: 2, MaxBackoff: time.Hour * 5}
+	client = &recordingClient{input: encodeValues(t, spec, &CronState{ConsecutiveFailures: 2})}
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.NoError(t, err)
+	require.Contains(t, out, `"EffectiveInterval": "4h0m0s"`)
+
+	// the first scheduled run waits for the start, the status has the StartAt of an InitialDelay too.
+	startAt := time.Date(2023, 6, 1, 9, 0, 0, 0, time.UTC)
+	spec = ScheduleSpec{JobCount: 4, ScheduleInterval: time.Hour, StartAt: startAt}
+	client = &recordingClient{input: encodeValues(t, spec, &CronState{StartTime: lastRun})}
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.NoError(t, err)
+	require.Contains(t, out, `"InitialWait": true,
+  "StartAt": "2023-06-01T09:00:00Z"`)
+	spec = ScheduleSpec{JobCount: 4, ScheduleInterval: time.Hour, InitialDelay: time.Hour}
+	client = &recordingClient{input: encodeValues(t, spec, &CronState{})}
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.NoError(t, err)
+	require.Contains(t, out, `"StartAt": "`+time.Unix(0, 0).Add(time.Hour).Format(time.RFC3339)+`"`)
+
+	// the dead letters whose delivery failed.
+	spec = ScheduleSpec{JobCount: 4, ScheduleInterval: time.Hour}
+	client = &recordingClient{input: encodeValues(t, spec, &CronState{DeadLetters: make([]DeadLetter, 3),
+		DroppedDeadLetters: 2})}
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.NoError(t, err)
+	require.Contains(t, out, `"DeadLetterBuffer": 3,
+  "DroppedDeadLetters": 2`)
+
+	client = &recordingClient{input: []byte("not gob")}
+	_, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not a cron workflow")
+}
+
+func Test_CommandExitCode(t *testing.T) {
+	command := cliCommand{Verb: "signal", WorkflowID: "cron_1"}
+	code, _ := commandExitCode(command, nil)
+	require.Equal(t, 0, code)
+
+	code, message := commandExitCode(command, &shared.EntityNotExistsError{Message: "workflow execution already completed"})
+	require.Equal(t, exitNotFound, code)
+	require.Equal(t, "workflow cron_1 not found or already completed: workflow execution already completed", message)
+
+	code, _ = commandExitCode(command, cliUsageError{errors.New("--workflow-id is required")})
+	require.Equal(t, exitUsage, code)
+
+	code, message = commandExitCode(command, errors.New("connection refused"))
+	require.Equal(t, exitFailed, code)
+	require.Equal(t, "signal of workflow cron_1 failed: connection refused", message)
+
+	// the errors of the client reach the exit code unchanged.
+	client := &recordingClient{err: &shared.EntityNotExistsError{Message: "workflow not found"}}
+	_, err := runArgs(t, client, "cancel", "--workflow-id", "cron_1")
+	code, _ = commandExitCode(command, err)
+	require.Equal(t, exitNotFound, code)
+}
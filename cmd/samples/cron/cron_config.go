@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with a ConfigSource reads its schedule from a config service instead of keeping the one of its input
+ * forever. The workflow fetches the config with getScheduleConfigActivity when a run of the workflow starts, so after
+ * every continue-as-new, and every ConfigSourceSpec.RefreshEvery runs in between. A fetched config replaces the
+ * interval, the jitter, the parallelism, the job activity and the job input of the spec, the other fields stay as they
+ * are. It takes effect with the next wait, a shorter interval can make the next run due right away.
+ *
+ * Newer clients record a value like this with cadence.MutableSideEffect, keyed by an ID, which adds a marker to the
+ * history only when the value changed, so that replay gets the value of the original execution. This version of the
+ * client has no MutableSideEffect, and a cadence.SideEffect would add a copy of the spec on every fetch. The config is
+ * fetched with an activity instead, whose result is in the history and replays as it is. The activity is told the
+ * version of the config the workflow has, and returns no spec while that version is current, so an unchanged config
+ * adds the events of the activity but no copy of the spec. The version is kept in the spec and carried over
+ * continue-as-new.
+ *
+ * A fetched config that is invalid, e.g. without an interval, is rejected and the workflow goes on with the last known
+ * good one, as does a fetch that fails. Both are logged and counted with metricConfigRejected, tagged with the reason.
+ * The config service of the sample is a JSON file the workers read, e.g.
+ * {"version": "2", "scheduleInterval": "30s", "jobActivity": "report", "jobInput": {"days": 7}}.
+ */
+
+// The reasons a fetched config is rejected.
+const (
+	configRejectedInvalid     = "invalid"
+	configRejectedUnavailable = "unavailable"
+)
+
+type (
+	// ConfigSourceSpec is the source of the config of a schedule.
+	ConfigSourceSpec struct {
+		// Name is the name of the config at the config service, the path of its file in the sample.
+		Name string
+		// RefreshEvery fetches the config again after every so many runs. Zero fetches it once per run of the workflow.
+		RefreshEvery uint
+		// Version is the version of the config the schedule runs with, empty until a config was applied.
+		Version string
+	}
+
+	// ScheduleConfigRequest is the input of getScheduleConfigActivity.
+	ScheduleConfigRequest struct {
+		Name string
+		// KnownVersion is the version of the config the workflow has.
+		KnownVersion string
+	}
+
+	// ScheduleConfig is the result of getScheduleConfigActivity.
+	ScheduleConfig struct {
+		Version string
+		// Spec is the schedule of the config, nil if Version is the known version.
+		Spec *ScheduleSpec
+	}
+
+	// scheduleConfigFile is the JSON config of a schedule, durations are duration strings, e.g. 1m.
+	scheduleConfigFile struct {
+		Version          string          `json:"version"`
+		ScheduleInterval string          `json:"scheduleInterval"`
+		Jitter           string          `json:"jitter"`
+		Parallelism      uint            `json:"parallelism"`
+		JobActivity      string          `json:"jobActivity"`
+		JobInput         json.RawMessage `json:"jobInput"`
+	}
+)
+
+func (s *ConfigSourceSpec) validate() error {
+	if s.Name == "" {
+		return errors.New("config source needs the name of the config")
+	}
+	return nil
+}
+
+// refreshDue returns true if the config is fetched before the given run of this run of the workflow.
+func (s *ConfigSourceSpec) refreshDue(runs int) bool {
+	if runs == 0 {
+		return true
+	}
+	return s.RefreshEvery > 0 && uint(runs)%s.RefreshEvery == 0
+}
+
+// withConfig returns a copy of the spec with the schedule of the config applied, or an error if it is invalid.
+func (s *ScheduleSpec) withConfig(config ScheduleConfig) (ScheduleSpec, error) {
+	spec := *s
+	spec.setInterval(config.Spec.ScheduleInterval)
+	spec.Jitter = config.Spec.Jitter
+	spec.Parallelism = config.Spec.Parallelism
+	spec.JobActivityName = config.Spec.JobActivityName
+	spec.JobInput = config.Spec.JobInput
+	configSource := *s.ConfigSource
+	configSource.Version = config.Version
+	spec.ConfigSource = &configSource
+	if len(spec.Jobs) == 0 && spec.TimeOfDay == "" {
+		if err := (ScheduleUpdate{ScheduleInterval: spec.ScheduleInterval}).validate(); err != nil {
+			return ScheduleSpec{}, err
+		}
+	}
+	if err := spec.Validate(time.Time{}); err != nil {
+		return ScheduleSpec{}, err
+	}
+	return spec, nil
+}
+
+// refreshConfig fetches the config of the schedule and applies it to the spec if it changed and is valid.
+func refreshConfig(ctx cadence.Context, spec *ScheduleSpec, history *historyEstimate) {
+	source := spec.ConfigSource
+	logger := workflowLogger(ctx).With(zap.String("Config", source.Name), zap.String("KnownVersion", source.Version))
+	history.addActivity()
+	var config ScheduleConfig
+	request := ScheduleConfigRequest{Name: source.Name, KnownVersion: source.Version}
+	if err := cadence.ExecuteActivity(ctx, getScheduleConfigActivity, request).Get(ctx, &config); err != nil {
+		reason := configRejectedUnavailable
+		if common.ClassifyError(err, errReasonInvalidInput).Kind == common.FailureCustom {
+			reason = configRejectedInvalid
+		}
+		rejectConfig(ctx, logger, reason, err)
+		return
+	}
+	if config.Spec == nil {
+		return
+	}
+	updated, err := spec.withConfig(config)
+	if err != nil {
+		rejectConfig(ctx, logger.With(zap.String("Version", config.Version)), configRejectedInvalid, err)
+		return
+	}
+	*spec = updated
+	logger.Info("Cron workflow config applied.", zap.String("Version", config.Version),
+		zap.Duration("ScheduleInterval", spec.ScheduleInterval), zap.Duration("Jitter", spec.Jitter),
+		zap.Uint("Parallelism", spec.Parallelism), zap.String("JobActivity", spec.JobActivityName))
+}
+
+// rejectConfig keeps the last known good config.
+func rejectConfig(ctx cadence.Context, logger *zap.Logger, reason string, err error) {
+	workflowMetrics(ctx).Tagged(map[string]string{"reason": reason}).Counter(metricConfigRejected).Inc(1)
+	logger.Warn("Cron workflow config rejected, keeping the last known good config.", zap.String("Reason", reason),
+		zap.Error(err))
+}
+
+// getScheduleConfigActivity reads the config of a schedule, it returns no spec if the known version is current.
+func getScheduleConfigActivity(ctx context.Context, request ScheduleConfigRequest) (ScheduleConfig, error) {
+	data, err := ioutil.ReadFile(request.Name)
+	if err != nil {
+		return ScheduleConfig{}, err
+	}
+	var file scheduleConfigFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ScheduleConfig{}, cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	if file.Version == "" {
+		return ScheduleConfig{}, cadence.NewErrorWithDetails(errReasonInvalidInput, "config has no version")
+	}
+	logger := activityLogger(ctx).With(zap.String("Config", request.Name), zap.String("Version", file.Version))
+	if file.Version == request.KnownVersion {
+		logger.Info("Schedule config unchanged.")
+		return ScheduleConfig{Version: file.Version}, nil
+	}
+	spec := &ScheduleSpec{Parallelism: file.Parallelism, JobActivityName: file.JobActivity, JobInput: file.JobInput}
+	if spec.ScheduleInterval, err = parseConfigDuration(file.ScheduleInterval); err != nil {
+		return ScheduleConfig{}, cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	if spec.Jitter, err = parseConfigDuration(file.Jitter); err != nil {
+		return ScheduleConfig{}, cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	logger.Info("Schedule config changed.")
+	return ScheduleConfig{Version: file.Version, Spec: spec}, nil
+}
+
+// parseConfigDuration parses a duration string of a config, an empty string is zero.
+func parseConfigDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration, e.g. 1m", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+// withScheduleConfigs makes getScheduleConfigActivity return the results of fetch, and records the run times of
+// sampleCronActivity since the start of the workflow.
+func withScheduleConfigs(env *cadence.TestWorkflowEnvironment, fetch func(request ScheduleConfigRequest) (
+	ScheduleConfig, error)) *[]time.Duration {
+	var runs []time.Duration
+	start := env.Now()
+	env.OverrideActivity(getScheduleConfigActivity, func(ctx context.Context, request ScheduleConfigRequest) (
+		ScheduleConfig, error) {
+		return fetch(request)
+	})
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, env.Now().Sub(start))
+		return CronJobResult{}, nil
+	})
+	return &runs
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ConfigAcrossContinueAsNew() {
+	configs := map[string]ScheduleConfig{
+		"":  {Version: "1", Spec: &ScheduleSpec{ScheduleInterval: time.Minute * 2}},
+		"1": {Version: "2", Spec: &ScheduleSpec{ScheduleInterval: time.Second * 30, Parallelism: 2}},
+		// the config doesn't change in the last run of the workflow.
+		"2": {Version: "2"},
+	}
+	var knownVersions []string
+	fetch := func(request ScheduleConfigRequest) (ScheduleConfig, error) {
+		s.Equal("schedules/nightly.json", request.Name)
+		knownVersions = append(knownVersions, request.KnownVersion)
+		return configs[request.KnownVersion], nil
+	}
+	spec := ScheduleSpec{JobCount: 25, ScheduleInterval: time.Minute,
+		ConfigSource: &ConfigSourceSpec{Name: "schedules/nightly.json"}}
+	state := &CronState{}
+	var runs [][]time.Duration
+	for generation := 0; generation < 3; generation++ {
+		env := s.NewTestWorkflowEnvironment()
+		generationRuns := withScheduleConfigs(env, fetch)
+		env.ExecuteWorkflow(SampleCronWorkflow, spec, state)
+
+		s.True(env.IsWorkflowCompleted())
+		if generation < 2 {
+			args := continueAsNewArgs(env.GetWorkflowError())
+			spec, state = args[0].(ScheduleSpec), args[1].(*CronState)
+		} else {
+			s.NoError(env.GetWorkflowError())
+		}
+		runs = append(runs, *generationRuns)
+	}
+
+	s.Equal([]string{"", "1", "2"}, knownVersions)
+	// the first generation runs every 2 minutes, the next ones every 30 seconds with 2 shards.
+	s.Len(runs[0], loopCountBeforeContinueAsNew)
+	s.Equal(time.Minute*2, runs[0][0])
+	s.Equal(time.Minute*20, runs[0][loopCountBeforeContinueAsNew-1])
+	s.Len(runs[1], loopCountBeforeContinueAsNew*2)
+	s.Equal([]time.Duration{time.Second * 30, time.Second * 30}, runs[1][:2])
+	s.Len(runs[2], 10)
+	s.Equal(time.Second*150, runs[2][9])
+	s.Equal("2", spec.ConfigSource.Version)
+	s.Equal(time.Second*30, spec.ScheduleInterval)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_InvalidConfigRejected() {
+	scope, restore := withTestMetrics()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	var fetches int
+	runs := withScheduleConfigs(env, func(request ScheduleConfigRequest) (ScheduleConfig, error) {
+		fetches++
+		if fetches == 1 {
+			// a config without an interval is rejected by the workflow.
+			return ScheduleConfig{Version: "2", Spec: &ScheduleSpec{JobActivityName: "report"}}, nil
+		}
+		return ScheduleConfig{}, errors.New("config service unavailable")
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+		ConfigSource: &ConfigSourceSpec{Name: "nightly.json", RefreshEvery: 5}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	spec := continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+	// the workflow went on with the schedule of its input, the last known good one.
+	s.Equal(2, fetches)
+	s.Len(*runs, loopCountBeforeContinueAsNew)
+	s.Equal(time.Minute*10, (*runs)[loopCountBeforeContinueAsNew-1])
+	s.Empty(spec.ConfigSource.Version)
+	s.Empty(spec.JobActivityName)
+	workflow := map[string]string{"workflowID": "default-test-workflow-id"}
+	s.Equal(int64(1), counterValue(scope, metricConfigRejected, map[string]string{"reason": configRejectedInvalid}))
+	s.Equal(int64(1), counterValue(scope, metricConfigRejected, map[string]string{"reason": configRejectedUnavailable}))
+	s.Equal(int64(2), counterValue(scope, metricConfigRejected, workflow))
+}
+
+func (s *UnitTestSuite) Test_GetScheduleConfigActivity() {
+	path := filepath.Join(s.T().TempDir(), "nightly.json")
+	s.NoError(ioutil.WriteFile(path, []byte(`{"version": "2", "scheduleInterval": "30s", "jitter": "5s",
+		"jobActivity": "report", "jobInput": {"days": 7}}`), 0644))
+	env := s.NewTestActivityEnvironment()
+
+	value, err := env.ExecuteActivity(getScheduleConfigActivity, ScheduleConfigRequest{Name: path, KnownVersion: "1"})
+	s.NoError(err)
+	var config ScheduleConfig
+	s.NoError(value.Get(&config))
+	s.Equal(ScheduleConfig{Version: "2", Spec: &ScheduleSpec{ScheduleInterval: time.Second * 30,
+		Jitter: time.Second * 5, JobActivityName: "report", JobInput: json.RawMessage(`{"days": 7}`)}}, config)
+
+	// the current version is not returned again.
+	value, err = env.ExecuteActivity(getScheduleConfigActivity, ScheduleConfigRequest{Name: path, KnownVersion: "2"})
+	s.NoError(err)
+	config = ScheduleConfig{}
+	s.NoError(value.Get(&config))
+	s.Equal(ScheduleConfig{Version: "2"}, config)
+
+	s.NoError(ioutil.WriteFile(path, []byte(`{"version": "3", "scheduleInterval": "30"}`), 0644))
+	_, err = env.ExecuteActivity(getScheduleConfigActivity, ScheduleConfigRequest{Name: path, KnownVersion: "2"})
+	s.Error(err)
+	s.Contains(err.Error(), errReasonInvalidInput)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * Every run gets a correlation ID that the systems its job talks to can use to recognize the run, e.g. to drop a
+ * duplicate delivery. The job activities get it in the RunContext, and the RunRecord of the run keeps it. The
+ * CorrelationIDs scheme of the spec decides how it is generated: CorrelationRandom generates a UUID with
+ * common.NewRunID, which records it in the history so that a replay of the run hands the same ID to its activities.
+ * CorrelationDeterministic derives it from the workflow ID and the sequence of the run without recording anything, a
+ * restarted schedule that numbers its runs from 1 again repeats the IDs of its earlier runs.
+ *
+ * The marker of a random ID is a new decision, runs started before the change have no correlation ID.
+ */
+
+type (
+	// CorrelationIDScheme decides how the correlation IDs of the runs are generated.
+	CorrelationIDScheme int
+)
+
+const (
+	// CorrelationRandom gives every run a random UUID recorded in the history.
+	CorrelationRandom CorrelationIDScheme = iota
+	// CorrelationDeterministic gives every run a UUID derived from the workflow ID and the sequence of the run.
+	CorrelationDeterministic
+)
+
+func (s CorrelationIDScheme) String() string {
+	switch s {
+	case CorrelationRandom:
+		return "Random"
+	case CorrelationDeterministic:
+		return "Deterministic"
+	}
+	return "Unknown"
+}
+
+// recordsCorrelationIDs returns true if the runs record a random correlation ID in the history, runs started before
+// the change have none.
+func (s *ScheduleSpec) recordsCorrelationIDs() bool {
+	return s.hasCorrelationIDs() && s.CorrelationIDs != CorrelationDeterministic
+}
+
+// hasCorrelationIDs returns true if the runs get a correlation ID.
+func (s *ScheduleSpec) hasCorrelationIDs() bool {
+	return s.getVersion(changeAddCorrelationID, DefaultVersion, 1) >= 1
+}
+
+// correlationID returns the correlation ID of the run with the given sequence, empty for a run started before the
+// change.
+func (s *ScheduleSpec) correlationID(ctx cadence.Context, sequence uint) string {
+	if !s.hasCorrelationIDs() {
+		return ""
+	}
+	workflowID := cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID
+	if s.CorrelationIDs == CorrelationDeterministic {
+		return common.DeterministicRunID(workflowID, sequence)
+	}
+	id, err := common.NewRunID(ctx)
+	if err != nil {
+		// the recorded ID can't be read, a replay fails the same way and derives the same ID.
+		workflowLogger(ctx).Error("Cron job correlation ID failed, using the derived one.", zap.Error(err))
+		return common.DeterministicRunID(workflowID, sequence)
+	}
+	return id
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+)
+
+func TestReplay_CronWorkflowCorrelationIDs(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute}
+	spec.withLatestVersions()
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	for len(h.activityInputs()) == 0 {
+		require.Nil(t, h.decide())
+		require.True(t, h.advance())
+	}
+	// the decisions after the first run started replay its marker, the ID in it is the one the run keeps.
+	markers := h.markerEvents()
+	require.Len(t, markers, 1)
+	var sideEffectID int32
+	var recorded []byte
+	decoder := gob.NewDecoder(bytes.NewReader(markers[0].GetMarkerRecordedEventAttributes().GetDetails()))
+	require.NoError(t, decoder.Decode(&sideEffectID))
+	require.NoError(t, decoder.Decode(&recorded))
+	var generated string
+	require.NoError(t, cadence.EncodedValue(recorded).Get(&generated))
+	require.Equal(t, generated, h.activityInputs()[0].Run.CorrelationID)
+	markers[0].MarkerRecordedEventAttributes.Details = encodeValues(t, sideEffectID, encodeValues(t, "recorded-id"))
+
+	state := continuedState(t, h.run())
+	require.NoError(t, h.replay())
+	require.Len(t, h.markerEvents(), loopCountBeforeContinueAsNew)
+	require.Len(t, state.RecentRuns, loopCountBeforeContinueAsNew)
+	require.Equal(t, "recorded-id", state.RecentRuns[0].CorrelationID)
+	seen := map[string]bool{"recorded-id": true}
+	for i, input := range h.activityInputs()[1:] {
+		require.NotNil(t, uuid.Parse(input.Run.CorrelationID), input.Run.CorrelationID)
+		require.False(t, seen[input.Run.CorrelationID], "run %d got the ID of an earlier run", input.Run.Sequence)
+		seen[input.Run.CorrelationID] = true
+		require.Equal(t, input.Run.CorrelationID, state.RecentRuns[i+1].CorrelationID)
+	}
+}
+
+func TestReplay_CronWorkflowCorrelationIDsWithoutMarker(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec ScheduleSpec
+		// latestVersions starts the run with the latest versions of the changes.
+		latestVersions bool
+		// correlationID is the ID of the run with the given sequence.
+		correlationID func(workflowID string, sequence uint) string
+	}{
+		{
+			// the derived IDs need no marker.
+			name:           "deterministic",
+			spec:           ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute, CorrelationIDs: CorrelationDeterministic},
+			latestVersions: true,
+			correlationID:  common.DeterministicRunID,
+		},
+		{
+			// a run started before the change records no marker its history wouldn't have.
+			name: "before the change",
+			spec: ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+				ChangeVersions: map[string]Version{changeAddResultRecording: 1, changeAddSummaryReport: 1}},
+			correlationID: func(string, uint) string { return "" },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.latestVersions {
+				tc.spec.withLatestVersions()
+			}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, tc.spec, &CronState{})
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, h.run().GetDecisionType())
+			require.NoError(t, h.replay())
+			require.Empty(t, h.markerEvents())
+			for _, input := range h.activityInputs() {
+				require.Equal(t, tc.correlationID(h.workflowID, input.Run.Sequence), input.Run.CorrelationID)
+			}
+		})
+	}
+}
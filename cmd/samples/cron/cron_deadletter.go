@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with FailureContinue goes on after a failed run, the run is only counted. With a DeadLetter the workflow
+ * also delivers a DeadLetter of every failed run to deadLetterActivity, once the RetryPolicy of the run is exhausted:
+ * the record of the run, its inputs and the failed attempts of its shards. The sample appends the letters to a JSON
+ * lines file on the workers, the integration point of a real queue. A letter may be appended twice when an attempt
+ * times out after the write, the WorkflowID and the Sequence of its Run identify it.
+ *
+ * The delivery is retried with the RetryPolicy of the DeadLetterSpec. A letter that still can't be delivered is kept
+ * in the CronState, which carries it over continue-as-new, and the buffered letters are delivered again after the next
+ * runs, oldest first, and once more when the schedule closes. The buffer is bounded by maxDeadLetters so that it
+ * doesn't grow the input of the workflow, a full buffer drops its oldest letter and counts it. The status query reports
+ * the depth of the buffer and the dropped letters.
+ */
+
+const (
+	// maxDeadLetters is the number of undelivered dead letters the CronState keeps.
+	maxDeadLetters = 10
+	// maxAttemptErrors is the number of errors of the last failed attempts a ShardFailure keeps.
+	maxAttemptErrors = 5
+)
+
+// defaultDeadLetterRetryPolicy retries the delivery of a dead letter without a RetryPolicy.
+var defaultDeadLetterRetryPolicy = RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}
+
+type (
+	// DeadLetterSpec configures the delivery of the dead letters of the failed runs.
+	DeadLetterSpec struct {
+		// Path is the JSON lines file on the workers deadLetterActivity appends the letters to.
+		Path string
+		// RetryPolicy retries the delivery of a letter, nil means defaultDeadLetterRetryPolicy.
+		RetryPolicy *RetryPolicy
+	}
+
+	// DeadLetter is the record of a run that failed after its retries.
+	DeadLetter struct {
+		Run    RunContext
+		Record RunRecord
+		// JobActivity, JobInput and JobInputIndex are the inputs of the run, a sealed JobInput stays sealed.
+		JobActivity   string
+		JobInput      string
+		JobInputIndex uint
+		// Shards are the failed shards of the run, empty for a run whose error doesn't come from its shards, e.g. a
+		// run as a child workflow.
+		Shards []ShardFailure
+	}
+
+	// ShardFailure are the failed attempts of a shard of a run.
+	ShardFailure struct {
+		Shard    uint
+		Attempts uint
+		// Errors are the errors of the last maxAttemptErrors attempts, oldest first.
+		Errors []string
+	}
+)
+
+// add records a failed attempt.
+func (f *ShardFailure) add(err error) {
+	f.Attempts++
+	f.Errors = append(f.Errors, err.Error())
+	if len(f.Errors) > maxAttemptErrors {
+		f.Errors = append([]string(nil), f.Errors[len(f.Errors)-maxAttemptErrors:]...)
+	}
+}
+
+func (s *DeadLetterSpec) validate() error {
+	if s.Path == "" {
+		return errors.New("dead letter path must not be empty")
+	}
+	if s.RetryPolicy != nil {
+		if err := s.RetryPolicy.validate(); err != nil {
+			return fmt.Errorf("dead letter %v", err)
+		}
+	}
+	return nil
+}
+
+// validateDeadLetter checks the DeadLetter against the rest of the spec.
+func (s *ScheduleSpec) validateDeadLetter() error {
+	if s.DeadLetter == nil {
+		return nil
+	}
+	if s.FailurePolicy != FailureContinue {
+		return errors.New("dead letters apply to a schedule with the failure policy Continue")
+	}
+	if len(s.Jobs) > 0 {
+		return errors.New("a schedule with jobs doesn't support dead letters")
+	}
+	return s.DeadLetter.validate()
+}
+
+func (s *DeadLetterSpec) retryPolicy() *RetryPolicy {
+	if s.RetryPolicy == nil {
+		return &defaultDeadLetterRetryPolicy
+	}
+	return s.RetryPolicy
+}
+
+// newDeadLetter returns the dead letter of the failed run of the spec that completed at the given time.
+func newDeadLetter(spec ScheduleSpec, run RunContext, result runResult, completedAt time.Time) DeadLetter {
+	letter := DeadLetter{Run: run, Record: result.record(completedAt), JobActivity: spec.jobActivity(),
+		JobInput: spec.jobInput(), JobInputIndex: spec.JobInputIndex}
+	if err, ok := result.err.(*shardsError); ok {
+		letter.Shards = err.failed
+	}
+	return letter
+}
+
+// deadLetterActivity appends the dead letter to the JSON lines file at the given path.
+func deadLetterActivity(ctx context.Context, path string, letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	activityLogger(ctx).Info("Cron job run dead-lettered.", zap.String("WorkflowID", letter.Run.WorkflowID),
+		zap.Uint("Sequence", letter.Run.Sequence), zap.String("Path", path))
+	return nil
+}
+
+// deliverDeadLetter executes deadLetterActivity with the letter, and retries it according to the retry policy of the
+// spec. The attempts are added to the history estimate as they happen.
+func deliverDeadLetter(ctx cadence.Context, spec *DeadLetterSpec, letter DeadLetter, history *historyEstimate) error {
+	policy := spec.retryPolicy()
+	firstAttempt := cadence.Now(ctx)
+	for attempt := uint(0); ; attempt++ {
+		history.addActivity()
+		err := cadence.ExecuteActivity(ctx, deadLetterActivity, spec.Path, letter).Get(ctx, nil)
+		if err == nil {
+			return nil
+		}
+		backoff, ok := policy.retryBackoff(ctx, attempt, firstAttempt, err)
+		if !ok {
+			return err
+		}
+		history.addTimer()
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// deadLetter delivers the dead letter of the run if it failed, and then the letters buffered before. A letter that
+// can't be delivered is buffered, the buffered letters wait for the next run then. A run that was skipped or cancelled
+// with the workflow didn't fail.
+func (j *cronJobs) deadLetter(ctx cadence.Context, spec ScheduleSpec, run RunContext, result runResult) {
+	if spec.DeadLetter == nil || ctx.Err() != nil {
+		return
+	}
+	if result.err != nil && !result.skipped {
+		letter := newDeadLetter(spec, run, result, cadence.Now(ctx))
+		if err := deliverDeadLetter(ctx, spec.DeadLetter, letter, j.history); err != nil {
+			j.state.bufferDeadLetter(ctx, letter, err)
+			return
+		}
+	}
+	if !j.deliveringDeadLetters {
+		// the buffered letters are delivered by one run at a time, in order.
+		j.deliveringDeadLetters = true
+		deliverBufferedDeadLetters(ctx, spec.DeadLetter, j.state, j.history)
+		j.deliveringDeadLetters = false
+	}
+}
+
+// bufferDeadLetter keeps a letter whose delivery failed with the given error, and drops the oldest letter if the
+// buffer is full.
+func (s *CronState) bufferDeadLetter(ctx cadence.Context, letter DeadLetter, err error) {
+	s.DeadLetters = append(s.DeadLetters, letter)
+	workflowLogger(ctx).Error("Cron job dead letter delivery failed, buffered.", zap.Uint("Sequence",
+		letter.Run.Sequence), zap.Int("Buffered", len(s.DeadLetters)), zap.Error(err))
+	if len(s.DeadLetters) > maxDeadLetters {
+		dropped := s.DeadLetters[0]
+		s.DeadLetters = append([]DeadLetter(nil), s.DeadLetters[1:]...)
+		s.DroppedDeadLetters++
+		workflowLogger(ctx).Error("Cron job dead letter dropped, the buffer is full.", zap.Uint("Sequence",
+			dropped.Run.Sequence), zap.Time("ScheduledAt", dropped.Record.ScheduledAt),
+			zap.Uint("TotalDropped", s.DroppedDeadLetters))
+	}
+}
+
+// deliverBufferedDeadLetters delivers the buffered letters oldest first, until a delivery fails. A letter that is
+// delivered is removed from the buffer, which a failed run may have added to or dropped from in the meantime.
+func deliverBufferedDeadLetters(ctx cadence.Context, spec *DeadLetterSpec, state *CronState,
+	history *historyEstimate) {
+	for len(state.DeadLetters) > 0 && ctx.Err() == nil {
+		letter := state.DeadLetters[0]
+		if err := deliverDeadLetter(ctx, spec, letter, history); err != nil {
+			workflowLogger(ctx).Warn("Cron job buffered dead letters not delivered.",
+				zap.Int("Buffered", len(state.DeadLetters)), zap.Error(err))
+			return
+		}
+		for i := range state.DeadLetters {
+			if state.DeadLetters[i].Run.Sequence == letter.Run.Sequence {
+				state.DeadLetters = append(state.DeadLetters[:i:i], state.DeadLetters[i+1:]...)
+				break
+			}
+		}
+		workflowLogger(ctx).Info("Cron job buffered dead letter delivered.", zap.Uint("Sequence", letter.Run.Sequence),
+			zap.Int("Buffered", len(state.DeadLetters)))
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+// deadLetterSpec fails every run in its second shard, twice with its retry policy.
+func deadLetterSpec(jobCount uint) ScheduleSpec {
+	return ScheduleSpec{JobCount: jobCount, ScheduleInterval: time.Hour, Parallelism: 2,
+		FailurePolicy: FailureContinue, RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 2},
+		JobInput: json.RawMessage(`{"days":7}`), DeadLetter: &DeadLetterSpec{Path: "/var/dead-letters.jsonl"}}
+}
+
+// failSecondShard fails the job activity in its second shard.
+func failSecondShard(env *cadence.TestWorkflowEnvironment) {
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		if input.Shard == 1 {
+			return CronJobResult{}, errors.New("disk full")
+		}
+		return CronJobResult{ProcessedBatches: 1}, nil
+	})
+}
+
+// deadLetters records the delivered dead letters, the deliveries fail while sinkDown returns true.
+func deadLetters(env *cadence.TestWorkflowEnvironment, sinkDown func() bool) *[]DeadLetter {
+	var delivered []DeadLetter
+	env.OverrideActivity(deadLetterActivity, func(ctx context.Context, path string, letter DeadLetter) error {
+		if sinkDown() {
+			return errors.New("sink down")
+		}
+		delivered = append(delivered, letter)
+		return nil
+	})
+	return &delivered
+}
+
+func Test_DeadLetterActivity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletters")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dead-letters.jsonl")
+
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	for sequence := uint(1); sequence <= 2; sequence++ {
+		letter := DeadLetter{Run: RunContext{WorkflowID: "cron_nightly", Sequence: sequence},
+			Record: RunRecord{Status: RunFailed, Error: "disk full"}, JobInput: `{"days":7}`}
+		_, err = env.ExecuteActivity(deadLetterActivity, path, letter)
+		require.NoError(t, err)
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var sequences []uint
+	for lines := bufio.NewScanner(file); lines.Scan(); {
+		var letter DeadLetter
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &letter))
+		require.Equal(t, "disk full", letter.Record.Error)
+		sequences = append(sequences, letter.Run.Sequence)
+	}
+	require.Equal(t, []uint{1, 2}, sequences)
+}
+
+func Test_CronWorkflow_DeadLetter(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	failSecondShard(env)
+	delivered := deadLetters(env, func() bool { return false })
+	env.ExecuteWorkflow(SampleCronWorkflow, deadLetterSpec(2), &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Len(t, *delivered, 2)
+	letter := (*delivered)[0]
+	require.Equal(t, uint(1), letter.Run.Sequence)
+	require.Equal(t, time.Unix(0, 0).Add(time.Hour), letter.Record.ScheduledAt)
+	require.Equal(t, RunFailed, letter.Record.Status)
+	require.Equal(t, "1 of 2 shards failed [1], first error: disk full", letter.Record.Error)
+	require.Equal(t, jobActivityType(""), letter.JobActivity)
+	require.Equal(t, `{"days":7}`, letter.JobInput)
+	require.Equal(t, []ShardFailure{{Shard: 1, Attempts: 2, Errors: []string{"disk full", "disk full"}}},
+		letter.Shards)
+	require.Equal(t, uint(2), (*delivered)[1].Run.Sequence)
+}
+
+func Test_CronWorkflow_DeadLetterBuffered(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		if input.Shard == 0 {
+			runs++
+		}
+		if input.Shard == 1 && runs == 1 {
+			return CronJobResult{}, errors.New("disk full")
+		}
+		return CronJobResult{}, nil
+	})
+	// the sink is down for the attempts of the letter of the first run, the second run delivers it.
+	var attempts uint
+	delivered := deadLetters(env, func() bool {
+		attempts++
+		return attempts <= defaultDeadLetterRetryPolicy.MaximumAttempts
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, deadLetterSpec(12), &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Len(t, *delivered, 1)
+	require.Equal(t, uint(1), (*delivered)[0].Run.Sequence)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	state := args[1].(*CronState)
+	require.Empty(t, state.DeadLetters)
+	require.Equal(t, uint(1), state.FailedRuns)
+
+	// the sink is down for the attempts after the last run, the schedule delivers the buffered letter as it closes.
+	env = suite.NewTestWorkflowEnvironment()
+	failSecondShard(env)
+	attempts = 0
+	delivered = deadLetters(env, func() bool {
+		attempts++
+		return attempts <= defaultDeadLetterRetryPolicy.MaximumAttempts
+	})
+	spec := deadLetterSpec(1)
+	spec.Parallelism = 1
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{DeadLetters: []DeadLetter{{Run: RunContext{Sequence: 7}}}})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Len(t, *delivered, 1)
+	require.Equal(t, uint(7), (*delivered)[0].Run.Sequence)
+}
+
+func Test_CronWorkflow_DeadLetterBufferAcrossContinueAsNew(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	failSecondShard(env)
+	sinkDown := true
+	delivered := deadLetters(env, func() bool { return sinkDown })
+	env.ExecuteWorkflow(SampleCronWorkflow, deadLetterSpec(loopCountBeforeContinueAsNew+2), &CronState{})
+
+	// every run failed and the sink was down, the buffer is full.
+	require.True(t, env.IsWorkflowCompleted())
+	require.Empty(t, *delivered)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	state := args[1].(*CronState)
+	require.Len(t, state.DeadLetters, maxDeadLetters)
+	require.Equal(t, uint(0), state.DroppedDeadLetters)
+
+	// the next run overflows the buffer and drops the oldest letter, the sink is back for the last run.
+	env = suite.NewTestWorkflowEnvironment()
+	failSecondShard(env)
+	delivered = deadLetters(env, func() bool { return sinkDown })
+	env.RegisterDelayedCallback(func() {
+		sinkDown = false
+	}, time.Hour+time.Minute)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var sequences []uint
+	for _, letter := range *delivered {
+		sequences = append(sequences, letter.Run.Sequence)
+	}
+	// the letter of the last run is delivered with the run, the buffered ones after it.
+	require.Equal(t, []uint{12, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, sequences)
+}
+
+func Test_CronState_BufferDeadLetter(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(func(ctx cadence.Context) (CronState, error) {
+		var state CronState
+		for sequence := uint(1); sequence <= maxDeadLetters+3; sequence++ {
+			state.bufferDeadLetter(ctx, DeadLetter{Run: RunContext{Sequence: sequence}}, errors.New("sink down"))
+		}
+		return state, nil
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	var state CronState
+	require.NoError(t, env.GetWorkflowResult(&state))
+	require.Len(t, state.DeadLetters, maxDeadLetters)
+	require.Equal(t, uint(4), state.DeadLetters[0].Run.Sequence)
+	require.Equal(t, uint(3), state.DroppedDeadLetters)
+}
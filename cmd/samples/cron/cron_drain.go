@@ -0,0 +1,34 @@
+package main
+
+import (
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The drain signal stops the cron workflow cooperatively. Unlike a cancellation, the runs in progress are not
+ * cancelled but complete, while no more runs are started, and the workflow completes instead of continuing as new. A
+ * drain received during the wait for the next run ends the wait right away. A drain received in the last decision
+ * before continue-as-new is drained with the other pending signals, and completes the workflow instead.
+ */
+
+// onDrain waits for the runs in progress to complete, and completes the workflow without the runs left. It returns the
+// summary of the schedule as of the drain.
+func onDrain(ctx cadence.Context, ao cadence.ActivityOptions, spec *ScheduleSpec, state *CronState,
+	jobs *cronJobs) (CronSummary, error) {
+	workflowLogger(ctx).Info("Cron workflow draining, waiting for the runs in progress.",
+		zap.Int("RunsInProgress", len(jobs.running)))
+	if err := jobs.wait(ctx); err != nil {
+		workflowLogger(ctx).Error("Cron workflow aborted while draining.", zap.Error(err),
+			zap.Uint("FailedRuns", state.FailedRuns))
+		return CronSummary{}, err
+	}
+	if ctx.Err() != nil {
+		return CronSummary{}, onCancel(ctx, ao, spec, state, jobs)
+	}
+	workflowMetrics(ctx).Counter(metricWorkflowsDrained).Inc(1)
+	workflowLogger(ctx).Info("Cron workflow drained.", zap.Uint("AbandonedRuns", spec.JobCount),
+		zap.Uint("TotalRuns", state.TotalRuns), zap.Uint("SuccessfulRuns", state.SuccessfulRuns),
+		zap.Uint("FailedRuns", state.FailedRuns), zap.Time("LastRunTime", state.LastRunTime))
+	return closeSchedule(cadence.WithActivityOptions(ctx, ao), spec, state, summaryDrained), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_DrainDuringSleep() {
+	scope, restore := withTestMetrics()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(drainSignalName, "decommission")
+	}, time.Minute*90)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Hour}, runTimes)
+	// the workflow doesn't wait for the next tick.
+	s.Equal(time.Minute*90, env.Now().Sub(time.Unix(0, 0)))
+	s.Equal(int64(1), counterValue(scope, metricWorkflowsDrained, nil))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DrainDuringActivity() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		// the run takes 30 minutes, the drain arrives in the middle of it.
+		runs++
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(drainSignalName, "decommission")
+		}, time.Minute*10)
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{ProcessedBatches: 7}, nil)
+		}, time.Minute*30)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(1, runs)
+	// the run in progress completed instead of being cancelled.
+	s.Equal(time.Minute*90, env.Now().Sub(time.Unix(0, 0)))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DrainBeforeContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		if runs == loopCountBeforeContinueAsNew {
+			// the drain arrives with the completion of the last run, in the decision that would continue as new.
+			env.SignalWorkflow(drainSignalName, "decommission")
+		}
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 13, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(loopCountBeforeContinueAsNew, runs)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DrainWhilePaused() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Once()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(pauseSignalName, "incident")
+	}, time.Minute*90)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(drainSignalName, "decommission")
+	}, time.Hour*3)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
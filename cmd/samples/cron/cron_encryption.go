@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+)
+
+/**
+ * The values of a schedule that may hold customer data are sealed by the starter with the keyring of the config, see
+ * common.Keyring: the inputs of the jobs, also the JobInputs of a list, and the description fields given with
+ * -describe. The sealed JobInput of a schedule without Jobs is the sealed value as a JSON string, so that it stays
+ * JSON. The workflow passes them on without reading them, through continue-as-new and into the CronJobInput of the
+ * runs, and sampleCronActivity opens the input of its job with the keyring of the worker. The other fields of the
+ * spec, e.g. the name that is part of the workflow ID, and the results of the runs stay plain.
+ */
+
+// errReasonInvalidInput is the reason of the error of an activity whose job input can't be opened, e.g. because the
+// worker doesn't have the key it was sealed with. Retrying doesn't help.
+const errReasonInvalidInput = "invalidInput"
+
+// sealSchedule seals the inputs of the jobs of the spec with the keyring, and returns the given description fields
+// sealed. A nil keyring leaves them as they are.
+func sealSchedule(keyring *common.Keyring, spec *ScheduleSpec, fields map[string]string) (map[string]string, error) {
+	var err error
+	if spec.JobInput, err = sealJobInput(keyring, spec.JobInput); err != nil {
+		return nil, err
+	}
+	for i := range spec.JobInputs {
+		if spec.JobInputs[i], err = sealJobInput(keyring, spec.JobInputs[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range spec.Jobs {
+		if spec.Jobs[i].Input == "" {
+			continue
+		}
+		sealed, err := keyring.Seal(spec.Jobs[i].Input)
+		if err != nil {
+			return nil, err
+		}
+		spec.Jobs[i].Input = sealed
+	}
+	var sealedFields map[string]string
+	for k, v := range fields {
+		sealed, err := keyring.Seal(v)
+		if err != nil {
+			return nil, err
+		}
+		if sealedFields == nil {
+			sealedFields = make(map[string]string)
+		}
+		sealedFields[k] = sealed
+	}
+	return sealedFields, nil
+}
+
+// sealJobInput returns the JSON job input sealed as a JSON string, an empty input stays empty.
+func sealJobInput(keyring *common.Keyring, input json.RawMessage) (json.RawMessage, error) {
+	if len(input) == 0 {
+		return input, nil
+	}
+	sealed, err := keyring.Seal(string(input))
+	if err != nil || !common.IsSealed(sealed) {
+		return input, err
+	}
+	return json.Marshal(sealed)
+}
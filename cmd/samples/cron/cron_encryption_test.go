@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/cadence"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+)
+
+func (s *UnitTestSuite) newKeyring() *common.Keyring {
+	keyring, err := common.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	s.NoError(err)
+	return keyring
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SealedJobInput() {
+	keyring := s.newKeyring()
+	spec := ScheduleSpec{JobCount: 2, Jobs: []JobSpec{{Name: "billing", Interval: time.Minute,
+		Input: "customer-4711"}}}
+	fields, err := sealSchedule(keyring, &spec, map[string]string{"owner": "customer-4711"})
+	s.NoError(err)
+	spec.Description = spec.describe(fields)
+	// the input of the workflow in the history has no plaintext.
+	s.False(bytes.Contains(encodeValues(s.T(), spec, &CronState{}), []byte("customer-4711")))
+
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOption(cadence.WorkerOptions{
+		BackgroundActivityContext: common.ContextWithKeyring(context.Background(), keyring)})
+	var inputs []string
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		s.True(common.IsSealed(input.JobInput))
+		jobInput, err := common.ActivityKeyring(ctx).Open(input.JobInput)
+		inputs = append(inputs, jobInput)
+		return CronJobResult{}, err
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"customer-4711", "customer-4711"}, inputs)
+}
+
+func (s *UnitTestSuite) Test_SampleCronActivity_SealedJobInput() {
+	keyring := s.newKeyring()
+	sealed, err := keyring.Seal("customer-4711")
+	s.NoError(err)
+	// the invalid shard makes the activity fail right after it opened the input.
+	input := CronJobInput{Shard: maxParallelism, JobInput: sealed}
+
+	env := s.NewTestActivityEnvironment()
+	env.SetWorkerOption(cadence.WorkerOptions{
+		BackgroundActivityContext: common.ContextWithKeyring(context.Background(), keyring)})
+	_, err = env.ExecuteActivity(sampleCronActivity, input)
+	s.Error(err)
+	s.Contains(err.Error(), errReasonInvalidShard)
+
+	// a worker without the key fails the activity instead of running the job with a garbage input.
+	_, err = s.NewTestActivityEnvironment().ExecuteActivity(sampleCronActivity, input)
+	s.Error(err)
+	s.Contains(err.Error(), errReasonInvalidInput)
+}
+
+func (s *UnitTestSuite) Test_SealSchedule() {
+	spec := ScheduleSpec{Jobs: []JobSpec{{Name: "billing", Input: "customer-4711"}, {Name: "cleanup"}}}
+	fields, err := sealSchedule(nil, &spec, map[string]string{"owner": "payments"})
+	s.NoError(err)
+	// without a keyring nothing is sealed.
+	s.Equal(map[string]string{"owner": "payments"}, fields)
+	s.Equal("customer-4711", spec.Jobs[0].Input)
+
+	keyring := s.newKeyring()
+	fields, err = sealSchedule(keyring, &spec, map[string]string{"owner": "payments"})
+	s.NoError(err)
+	owner, err := keyring.Open(fields["owner"])
+	s.NoError(err)
+	s.Equal("payments", owner)
+	s.True(common.IsSealed(spec.Jobs[0].Input))
+	// an empty input stays empty.
+	s.Equal("", spec.Jobs[1].Input)
+
+	// the sealed job input of a schedule without jobs stays JSON, the runs get the sealed value.
+	spec = ScheduleSpec{JobActivityName: "report", JobInput: json.RawMessage(`{"report":"customer-4711"}`)}
+	_, err = sealSchedule(keyring, &spec, nil)
+	s.NoError(err)
+	s.True(json.Valid(spec.JobInput))
+	s.NoError(spec.validateJobActivities())
+	s.True(common.IsSealed(spec.jobInput()))
+	jobInput, err := keyring.Open(spec.jobInput())
+	s.NoError(err)
+	s.Equal(`{"report":"customer-4711"}`, jobInput)
+
+	// so does every input of a list.
+	spec = ScheduleSpec{JobInputs: []json.RawMessage{json.RawMessage(`"customer-4711"`), json.RawMessage(`7`)}}
+	_, err = sealSchedule(keyring, &spec, nil)
+	s.NoError(err)
+	s.NoError(spec.validateJobInputs())
+	s.False(bytes.Contains(spec.JobInputs[0], []byte("customer-4711")))
+	// the run that takes the input gets it as its JobInput.
+	runSpec := ScheduleSpec{JobInput: spec.JobInputs[0]}
+	jobInput, err = keyring.Open(runSpec.jobInput())
+	s.NoError(err)
+	s.Equal(`"customer-4711"`, jobInput)
+}
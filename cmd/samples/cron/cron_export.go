@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"go.uber.org/cadence"
+)
+
+/**
+ * The history verb exports the history of a run as the JSON the replay tests read from testdata, to capture a new
+ * fixture from a live execution or to update one after a deliberate change of the workflow:
+ *
+ *   cron history --workflow-id X [--run-id Y] --output testdata/cron_history_<name>.json
+ *
+ * The fixture keeps the names the binary registered the workflow and the activities with, e.g. main.SampleCronWorkflow,
+ * the replay tests map them to the names of the test binary.
+ */
+
+// exportHistory writes the history of the run of the command as JSON to the output file of the command, to out if it
+// has none.
+func exportHistory(client cadence.Client, command cliCommand, out io.Writer) error {
+	history, err := client.GetWorkflowHistory(command.WorkflowID, command.RunID)
+	if err != nil {
+		return err
+	}
+	if len(history.Events) == 0 {
+		return fmt.Errorf("workflow %s has no history", command.WorkflowID)
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if command.Output == "" {
+		_, err = out.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(command.Output, data, 0644)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/mocks"
+)
+
+func Test_ExportHistory(t *testing.T) {
+	fixture := "testdata/cron_history_continue_as_new.json"
+	data, err := ioutil.ReadFile(fixture)
+	require.NoError(t, err)
+	var history s.History
+	require.NoError(t, json.Unmarshal(data, &history))
+	service := &mocks.TChanWorkflowService{}
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.MatchedBy(
+		func(request *s.GetWorkflowExecutionHistoryRequest) bool {
+			return request.GetExecution().GetWorkflowId() == "cron_1" && request.GetExecution().GetRunId() == "run-1"
+		})).Return(&s.GetWorkflowExecutionHistoryResponse{History: &history}, nil)
+
+	// the export is the fixture it was read from, byte for byte.
+	out, err := runService(t, service, "history", "--workflow-id", "cron_1", "--run-id", "run-1")
+	require.NoError(t, err)
+	require.Equal(t, string(data), out)
+
+	path := filepath.Join(t.TempDir(), "cron_history.json")
+	out, err = runService(t, service, "history", "--workflow-id", "cron_1", "--run-id", "run-1", "--output", path)
+	require.NoError(t, err)
+	require.Empty(t, out)
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, written)
+	require.NoError(t, loadHistory(t, path, SampleCronWorkflow).replay())
+}
+
+func Test_ExportHistory_Error(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(nil,
+		&s.EntityNotExistsError{Message: "workflow not found"})
+	_, err := runService(t, service, "history", "--workflow-id", "cron_1")
+	code, _ := commandExitCode(cliCommand{Verb: "history", WorkflowID: "cron_1"}, err)
+	require.Equal(t, exitNotFound, code)
+
+	_, err = parseCommand([]string{"history"}, ioutil.Discard)
+	require.Equal(t, cliUsageError{errors.New("--workflow-id is required")}, err)
+}
+
+// replayFixtures are histories of SampleCronWorkflow checked in to catch changes of the workflow that don't replay
+// them. The fixtures are the consecutive runs of a schedule of 3 jobs with MaxHistoryEvents 40: the first continues as
+// new after 2 runs, the second runs the last job and completes. Every run waits on timers and completes activities.
+var replayFixtures = []struct {
+	path string
+	// closedBy is the type of the event that closed the run.
+	closedBy s.EventType
+}{
+	{"testdata/cron_history_continue_as_new.json", s.EventType_WorkflowExecutionContinuedAsNew},
+	{"testdata/cron_history_after_continue_as_new.json", s.EventType_WorkflowExecutionCompleted},
+}
+
+func TestReplay_CronWorkflowFixtures(t *testing.T) {
+	var previous *historySimulator
+	for _, fixture := range replayFixtures {
+		h := loadHistory(t, fixture.path, SampleCronWorkflow)
+		require.NoError(t, h.replay(), fixture.path)
+		require.Equal(t, fixture.closedBy, h.events[len(h.events)-1].GetEventType(), fixture.path)
+		require.NotEmpty(t, h.timerDurations(), fixture.path)
+		require.Contains(t, h.activityTypes(), getFunctionName(sampleCronActivity), fixture.path)
+		if previous != nil {
+			// the run starts with the spec and the state its previous run continued as new with.
+			continued := previous.events[len(previous.events)-1].GetWorkflowExecutionContinuedAsNewEventAttributes()
+			require.Equal(t, continued.Input, h.events[0].GetWorkflowExecutionStartedEventAttributes().Input)
+		}
+		previous = h
+	}
+}
+
+func TestReplay_CronWorkflowNondeterministicFixture(t *testing.T) {
+	// the fixture is cron_history_continue_as_new.json recorded by a workflow that named the activity of the job
+	// runCronJobActivity. Renaming an activity, or changing the order or the durations of the timers and activities,
+	// makes the decisions of the replay differ from the history, and the running workflows fail their decisions.
+	h := loadHistory(t, "testdata/cron_history_nondeterministic.json", SampleCronWorkflow)
+	err := h.replay()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nondeterministic workflow")
+	require.Contains(t, err.Error(), "runCronJobActivity")
+}
+
+func TestReplay_CronWorkflowExportedFixture(t *testing.T) {
+	// a history exported from the cron binary names the workflow and the activities main.<function>.
+	data, err := ioutil.ReadFile("testdata/cron_history_continue_as_new.json")
+	require.NoError(t, err)
+	exported := strings.Replace(string(data), "github.com/samarabbas/cadence-samples/cmd/samples/cron.", "main.", -1)
+	path := filepath.Join(t.TempDir(), "exported.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(exported), 0644))
+	require.NoError(t, loadHistory(t, path, SampleCronWorkflow).replay())
+}
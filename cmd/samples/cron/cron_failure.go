@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+type (
+	// FailurePolicy decides what happens to the schedule when a run fails after its retries.
+	FailurePolicy int
+)
+
+const (
+	// FailureAbort fails the workflow with the error of the run, no more runs happen.
+	FailureAbort FailurePolicy = iota
+	// FailureContinue logs the failed run and continues with the schedule, unless
+	// ScheduleSpec.MaxConsecutiveFailures is exceeded.
+	FailureContinue
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailureAbort:
+		return "Abort"
+	case FailureContinue:
+		return "Continue"
+	}
+	return "Unknown"
+}
+
+// backsOff returns true if the failed runs of the schedule back off.
+func (s *ScheduleSpec) backsOff() bool {
+	return s.BackoffCoefficient > 1
+}
+
+// backoffInterval returns the interval before the next run after the given number of consecutive failed runs, the
+// ScheduleInterval times BackoffCoefficient to the power of the failures, at most MaxBackoff. The first successful run
+// resets the failures, and the schedule is back to its interval.
+func (s *ScheduleSpec) backoffInterval(failures uint) time.Duration {
+	if !s.backsOff() || failures == 0 {
+		return s.ScheduleInterval
+	}
+	interval := float64(s.ScheduleInterval)
+	for i := uint(0); i < failures && interval < float64(s.MaxBackoff); i++ {
+		interval *= s.BackoffCoefficient
+	}
+	if interval > float64(s.MaxBackoff) {
+		return s.MaxBackoff
+	}
+	return time.Duration(interval)
+}
+
+func (s *ScheduleSpec) validateBackoff() error {
+	if s.BackoffCoefficient == 0 && s.MaxBackoff == 0 {
+		return nil
+	}
+	if s.BackoffCoefficient < 1 {
+		return errors.New("backoff coefficient must be at least 1, the interval grows with every failed run")
+	}
+	if s.TimeOfDay != "" || len(s.Jobs) > 0 {
+		return errors.New("backoff applies to a single job scheduled by interval")
+	}
+	if s.MaxBackoff < s.ScheduleInterval {
+		return fmt.Errorf("max backoff %v is shorter than the interval %v", s.MaxBackoff, s.ScheduleInterval)
+	}
+	return nil
+}
+
+// onRunCompleted records the outcome of a run in the state, and returns the error that ends the schedule or nil if it
+// goes on.
+func onRunCompleted(ctx cadence.Context, spec *ScheduleSpec, state *CronState, run runResult) error {
+	if run.err == nil {
+		if state.ConsecutiveFailures > 0 {
+			workflowLogger(ctx).Info("Cron job run succeeded after failures.",
+				zap.Uint("ConsecutiveFailures", state.ConsecutiveFailures))
+		}
+		state.SuccessfulRuns++
+		state.ConsecutiveFailures = 0
+		state.LastRunTime = cadence.Now(ctx)
+		return nil
+	}
+
+	if _, ok := run.err.(cadence.CanceledError); ok && ctx.Err() != nil {
+		// the run was cancelled with the workflow, it didn't fail.
+		workflowLogger(ctx).Info("Cron job run cancelled.")
+		return nil
+	}
+	if run.skipped {
+		// the run was skipped with the skipRun signal, it didn't fail either.
+		state.SkippedBySignal++
+		workflowLogger(ctx).Info("Cron job run skipped by signal.", zap.String("Reason", run.skipReason))
+		return nil
+	}
+	state.FailedRuns++
+	state.ConsecutiveFailures++
+	if spec.FailurePolicy != FailureContinue {
+		return run.err
+	}
+	state.onItemFailed(run.item, run.err)
+	if spec.MaxConsecutiveFailures > 0 && state.ConsecutiveFailures > spec.MaxConsecutiveFailures {
+		return fmt.Errorf("%d consecutive runs failed, last error: %v", state.ConsecutiveFailures, run.err)
+	}
+	workflowLogger(ctx).Warn("Cron job run failed, continuing with the schedule.", zap.Error(run.err),
+		zap.Uint("ConsecutiveFailures", state.ConsecutiveFailures), zap.Uint("FailedRuns", state.FailedRuns))
+	return nil
+}
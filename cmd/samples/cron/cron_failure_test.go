@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+// failingRuns makes the given runs of sampleCronActivity fail, counting from 1.
+func failingRuns(env *cadence.TestWorkflowEnvironment, runs ...int) *int {
+	var count int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		count++
+		for _, run := range runs {
+			if run == count {
+				return CronJobResult{}, errors.New("job failed")
+			}
+		}
+		return CronJobResult{}, nil
+	})
+	return &count
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ContinueOnFailure() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := failingRuns(env, 1, 2, 10)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+		FailurePolicy: FailureContinue, MaxConsecutiveFailures: 2}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(loopCountBeforeContinueAsNew, *runs)
+	// the success of the third run reset the streak.
+	state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+	s.Equal(uint(3), state.FailedRuns)
+	s.Equal(uint(7), state.SuccessfulRuns)
+	s.Equal(uint(1), state.ConsecutiveFailures)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ConsecutiveFailuresAcrossContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	failingRuns(env, 9, 10)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Minute,
+		FailurePolicy: FailureContinue, MaxConsecutiveFailures: 2}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+	s.Equal(uint(2), args[1].(*CronState).ConsecutiveFailures)
+
+	// the first run of the next workflow run extends the streak past the maximum.
+	env = s.NewTestWorkflowEnvironment()
+	runs := failingRuns(env, 1)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(1, *runs)
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "3 consecutive runs failed")
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_AbortOnFailure() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := failingRuns(env, 2)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(2, *runs)
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "job failed")
+}
+
+// backoffRuns makes the given runs of sampleCronActivity fail, counting from 1, and returns the times of the runs.
+func backoffRuns(env *cadence.TestWorkflowEnvironment, failing ...int) *[]time.Duration {
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		for _, run := range failing {
+			if run == len(runTimes) {
+				return CronJobResult{}, errors.New("job failed")
+			}
+		}
+		return CronJobResult{}, nil
+	})
+	return &runTimes
+}
+
+// backoffSpec backs off from an interval of 1h to at most 5h.
+func backoffSpec(jobCount uint) ScheduleSpec {
+	return ScheduleSpec{JobCount: jobCount, ScheduleInterval: time.Hour, FailurePolicy: FailureContinue,
+		BackoffCoefficient: 2, MaxBackoff: time.Hour * 5}
+}
+
+func (s *UnitTestSuite) Test_BackoffInterval() {
+	spec := backoffSpec(1)
+	var intervals []time.Duration
+	for failures := uint(0); failures <= 4; failures++ {
+		intervals = append(intervals, spec.backoffInterval(failures))
+	}
+	s.Equal([]time.Duration{time.Hour, time.Hour * 2, time.Hour * 4, time.Hour * 5, time.Hour * 5}, intervals)
+	s.Equal(time.Hour, (&ScheduleSpec{ScheduleInterval: time.Hour}).backoffInterval(3))
+
+	s.NoError(spec.validateBackoff())
+	s.NoError((&ScheduleSpec{ScheduleInterval: time.Hour}).validateBackoff())
+	s.Error((&ScheduleSpec{ScheduleInterval: time.Hour, BackoffCoefficient: 0.5, MaxBackoff: time.Hour}).validateBackoff())
+	s.Error((&ScheduleSpec{ScheduleInterval: time.Hour, BackoffCoefficient: 2}).validateBackoff())
+	s.Error((&ScheduleSpec{TimeOfDay: "08:00", BackoffCoefficient: 2, MaxBackoff: time.Hour}).validateBackoff())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_Backoff() {
+	for _, tc := range []struct {
+		name     string
+		failing  []int
+		jobCount uint
+		// pauseAt, resumeAt and triggerAt are when the signals are sent, zero sends none.
+		pauseAt, resumeAt, triggerAt time.Duration
+		runTimes                     []time.Duration
+	}{
+		// the delays after the failed runs are 2h, 4h and 5h at most, the success is followed by the interval.
+		{name: "failed runs", failing: []int{1, 2, 3}, jobCount: 5,
+			runTimes: []time.Duration{time.Hour, time.Hour * 3, time.Hour * 7, time.Hour * 12, time.Hour * 13}},
+		// a pause keeps the streak, the wait after the resume backs off as the interrupted one did.
+		{name: "paused", failing: []int{1, 2}, jobCount: 4, pauseAt: time.Hour * 4, resumeAt: time.Hour * 5,
+			runTimes: []time.Duration{time.Hour, time.Hour * 3, time.Hour * 9, time.Hour * 10}},
+		// a manual run counts like any other, its success resets the streak.
+		{name: "triggered", failing: []int{1, 2}, jobCount: 4, triggerAt: time.Hour * 4,
+			runTimes: []time.Duration{time.Hour, time.Hour * 3, time.Hour * 4, time.Hour * 5}},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		runTimes := backoffRuns(env, tc.failing...)
+		if tc.pauseAt > 0 {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(pauseSignalName, "incident")
+			}, tc.pauseAt)
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(resumeSignalName, "resolved")
+			}, tc.resumeAt)
+		}
+		if tc.triggerAt > 0 {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{})
+			}, tc.triggerAt)
+		}
+		env.ExecuteWorkflow(SampleCronWorkflow, backoffSpec(tc.jobCount), &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.NoError(env.GetWorkflowError(), tc.name)
+		s.Equal(tc.runTimes, *runTimes, tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_BackoffAcrossContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	backoffRuns(env, 9, 10)
+	env.ExecuteWorkflow(SampleCronWorkflow, backoffSpec(20), &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+	s.Equal(uint(2), args[1].(*CronState).ConsecutiveFailures)
+
+	// the next workflow run starts with the backoff of the streak it carried over.
+	env = s.NewTestWorkflowEnvironment()
+	runTimes := backoffRuns(env)
+	spec := args[0].(ScheduleSpec)
+	spec.JobCount = 2
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, args[1])
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Hour * 4, time.Hour * 5}, *runTimes)
+}
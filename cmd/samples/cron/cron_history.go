@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+/**
+ * The workflow continues as new before its history grows too large. This version of the client doesn't tell the
+ * workflow the length of its history, so the workflow estimates it from the activities and timers it starts and the
+ * signals it receives. The estimate is derived from the commands of the workflow and the signals in its history
+ * only, so replay arrives at the same estimate and continues as new at the same point as the original execution. An
+ * old history of a spec without MaxHistoryEvents replays with the fixed loop count it was recorded with.
+ *
+ * Every run costs at least an activity, even for a job that takes milliseconds. Newer versions of the client can run
+ * such a job as a local activity in the worker of the workflow, which records a single marker event and saves the
+ * round-trips through the server, at the price of a timeout bound by the decision task. This version of the client
+ * has no local activities, a cheap job shortens the time between continue-as-new the same way an expensive one does.
+ */
+
+const (
+	// every event that wakes the workflow up adds a decision task with its scheduled, started and completed events.
+	eventsPerDecision = 3
+	// the workflow started event and the first decision task.
+	eventsAtStart = 1 + eventsPerDecision
+	// an activity is scheduled, started and closed, and its completion wakes the workflow up.
+	eventsPerActivity = 3 + eventsPerDecision
+	// a timer is started and fired or cancelled, and the fired timer wakes the workflow up.
+	eventsPerTimer = 2 + eventsPerDecision
+	// a signal wakes the workflow up.
+	eventsPerSignal = 1 + eventsPerDecision
+	// a child workflow is initiated, started and closed, and both its start and its close wake the workflow up.
+	eventsPerChildWorkflow = 3 + 2*eventsPerDecision
+	// a marker is recorded by the decision that records it, it doesn't wake the workflow up.
+	eventsPerMarker = 1
+)
+
+// historyEstimate is an upper bound of the number of events in the history of the current run of the workflow. Events
+// that are handled by the same decision task are counted with a decision task each.
+type historyEstimate struct {
+	events uint
+}
+
+func newHistoryEstimate() *historyEstimate {
+	return &historyEstimate{events: eventsAtStart}
+}
+
+func (h *historyEstimate) addActivity() {
+	h.events += eventsPerActivity
+}
+
+func (h *historyEstimate) addTimer() {
+	h.events += eventsPerTimer
+}
+
+func (h *historyEstimate) addSignal() {
+	h.events += eventsPerSignal
+}
+
+func (h *historyEstimate) addChildWorkflow() {
+	h.events += eventsPerChildWorkflow
+}
+
+func (h *historyEstimate) addMarker() {
+	h.events += eventsPerMarker
+}
+
+// runEvents is the estimate of the events the wait for a run, the pick of its host, the first attempts of its shards or
+// its child workflow, and the recording and the forwarding of its result add. Retries add more as they happen, the retries in a child workflow don't.
+func (s *ScheduleSpec) runEvents() uint {
+	events := uint(eventsPerTimer)
+	if s.drawsJitter() {
+		events += eventsPerMarker
+	}
+	if s.recordsCorrelationIDs() {
+		events += eventsPerMarker
+	}
+	if s.RunAsChildWorkflow {
+		events += eventsPerChildWorkflow
+	} else {
+		events += s.shards() * eventsPerActivity
+		if s.HostAffinity != nil {
+			events += eventsPerActivity
+		}
+	}
+	if s.recordsResults() {
+		events += eventsPerActivity
+	}
+	if s.forwardsRuns() {
+		events += eventsPerActivity
+	}
+	return events
+}
+
+// continueAsNewDue returns true if the workflow should continue as new instead of waiting for another run, after the
+// given number of runs and consecutive failed runs at the given time. The history is not the only limit, a workflow
+// timeout shorter than the runs that fit into the history is another one: the workflow continues as new when the next
+// run would not be due before the deadline of its run, see startRunDeadline.
+func (s *ScheduleSpec) continueAsNewDue(runs int, history *historyEstimate, now time.Time, failures uint) bool {
+	wait := s.longestWait()
+	if backoff := s.backoffInterval(failures); backoff > wait {
+		wait = backoff
+	}
+	// the first wait of a run of the workflow can't be shortened by continuing as new.
+	if runs > 0 && s.pastRunDeadline(now.Add(wait+s.Jitter)) {
+		return true
+	}
+	if s.MaxHistoryEvents == 0 {
+		return runs >= loopCountBeforeContinueAsNew
+	}
+	return history.events+s.runEvents() > s.MaxHistoryEvents
+}
+
+// runsBeforeContinueAsNew returns the most runs a run of the workflow starts before its history makes it continue as
+// new.
+func (s *ScheduleSpec) runsBeforeContinueAsNew() uint {
+	if s.MaxHistoryEvents == 0 {
+		return loopCountBeforeContinueAsNew
+	}
+	if s.MaxHistoryEvents <= eventsAtStart {
+		return 0
+	}
+	return (s.MaxHistoryEvents - eventsAtStart) / s.runEvents()
+}
+
+// validateMaxHistoryEvents checks that at least one run fits, the workflow would continue as new forever otherwise.
+func (s *ScheduleSpec) validateMaxHistoryEvents() error {
+	if s.MaxHistoryEvents > 0 && s.MaxHistoryEvents < eventsAtStart+s.runEvents() {
+		return fmt.Errorf("max history events %d don't fit a single run of %d shards", s.MaxHistoryEvents, s.shards())
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_MaxHistoryEventsBeyondLoopCount() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		return CronJobResult{}, nil
+	})
+	// a single cheap activity per run leaves room for more runs than the fixed loop count.
+	spec := ScheduleSpec{JobCount: 15, ScheduleInterval: time.Minute, MaxHistoryEvents: 1000}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(15, runs)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_MaxHistoryEventsBeyondWorkflowTimeout() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		return CronJobResult{}, nil
+	})
+	// the history has room for hundreds of runs, the workflow timeout for 5 of them.
+	spec := ScheduleSpec{JobCount: 20, ScheduleInterval: time.Minute * 10, MaxHistoryEvents: 5000,
+		Timeouts: Timeouts{Workflow: time.Hour}}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(5, runs)
+	s.Equal(time.Minute*50, env.Now().Sub(time.Unix(0, 0)))
+	args := continueAsNewArgs(env.GetWorkflowError())
+	s.Equal(uint(15), args[0].(ScheduleSpec).JobCount)
+}
+
+func TestReplay_CronWorkflowMaxHistoryEvents(t *testing.T) {
+	testCases := []struct {
+		name             string
+		maxHistoryEvents uint
+		runs             int
+	}{
+		// a history recorded without the threshold replays with the fixed loop count.
+		{"loop count", 0, loopCountBeforeContinueAsNew},
+		// every run of 5 shards adds about 35 events.
+		{"fan-out", 120, 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 20, ScheduleInterval: time.Minute, Parallelism: 5,
+				MaxHistoryEvents: tc.maxHistoryEvents}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_ContinueAsNewWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			require.Len(t, h.activityScheduleTimes(), tc.runs*5)
+			if tc.maxHistoryEvents > 0 {
+				require.True(t, len(h.events) <= int(tc.maxHistoryEvents), "history has %d events", len(h.events))
+			}
+			var next ScheduleSpec
+			attributes := closeDecision.GetContinueAsNewWorkflowExecutionDecisionAttributes()
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(attributes.Input)).Decode(&next))
+			require.Equal(t, uint(20-tc.runs), next.JobCount)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * With HostAffinity the shards of a run execute on the same worker host, e.g. to share a file on local disk, like the
+ * sessions of newer clients would. Every worker polls the shared task list and a task list of its own host. A run
+ * starts with pickHostActivity on the shared task list, which returns the task list of the host of the worker that
+ * picked it up, and the shards of the run are scheduled on that task list, see fileprocessing for the same pattern.
+ *
+ * A host that dies in the middle of a run doesn't poll its task list anymore, the shards scheduled on it time out
+ * waiting for a worker after HostAffinitySpec.ScheduleToStart. The run then picks another host and executes the shard
+ * there, instead of failing. The shards of the run that already completed on the dead host are not executed again.
+ */
+
+// defaults of the HostAffinitySpec.
+const (
+	hostScheduleToStartTimeout = time.Second * 10
+	defaultMaxHostPicks        = 3
+)
+
+type (
+	// HostAffinitySpec pins the shards of every run to the worker host that picked up the run.
+	HostAffinitySpec struct {
+		// ScheduleToStart is how long a shard waits for a worker on the host of the run before another host is picked,
+		// it is shorter than the ScheduleToStart of the Timeouts since only one host polls the task list. Zero means
+		// 10 seconds.
+		ScheduleToStart time.Duration
+		// MaxPicks is the maximum number of hosts a run picks, including the first one, the run fails once the last of
+		// them is unavailable as well. Zero means 3.
+		MaxPicks uint
+	}
+
+	// hostRoute is the host the shards of a run execute on, shared by the shards. A shard that finds the host
+	// unavailable picks the next one for all of them.
+	hostRoute struct {
+		spec    HostAffinitySpec
+		history *historyEstimate
+		// taskList is the task list of the host of the run.
+		taskList string
+		picks    uint
+		// picking is the pick in progress, the shards that find the host unavailable meanwhile wait for it.
+		picking cadence.Future
+	}
+)
+
+// hostTaskList is the task list of the host of this worker, the workers of a host poll it next to ApplicationName.
+var hostTaskList = hostTaskListName()
+
+func hostTaskListName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		// a worker without a hostname gets a task list of its own.
+		host = uuid.New()
+	}
+	return ApplicationName + "_" + host
+}
+
+// scheduleToStartTimedOut returns true if the activity failed because no worker picked it up in time, e.g. no worker of
+// its host. The test environment of this client doesn't time out activities, the tests replace it.
+var scheduleToStartTimedOut = func(err error) bool {
+	timeoutErr, ok := err.(cadence.TimeoutError)
+	return ok && timeoutErr.TimeoutType() == shared.TimeoutType_SCHEDULE_TO_START
+}
+
+func (s *HostAffinitySpec) validate() error {
+	if s.ScheduleToStart < 0 {
+		return errors.New("host affinity schedule to start timeout must not be negative")
+	}
+	return nil
+}
+
+// pickHostActivity returns the task list of the host of the worker that executes it.
+func pickHostActivity(ctx context.Context) (string, error) {
+	activityLogger(ctx).Info("Cron job host picked.", zap.String("TaskList", hostTaskList))
+	return hostTaskList, nil
+}
+
+// newHostRoute returns the route of a run of the spec, nil if the spec has no HostAffinity.
+func newHostRoute(spec *ScheduleSpec, history *historyEstimate) *hostRoute {
+	if spec.HostAffinity == nil {
+		return nil
+	}
+	r := &hostRoute{spec: *spec.HostAffinity, history: history}
+	if r.spec.ScheduleToStart == 0 {
+		r.spec.ScheduleToStart = hostScheduleToStartTimeout
+	}
+	if r.spec.MaxPicks == 0 {
+		r.spec.MaxPicks = defaultMaxHostPicks
+	}
+	return r
+}
+
+// pick picks the host of the run, or waits for the pick in progress. The first pick is part of the history estimate
+// of the run already, the next ones are added to it.
+func (r *hostRoute) pick(ctx cadence.Context) error {
+	if r.picking == nil {
+		if r.picks >= r.spec.MaxPicks {
+			return fmt.Errorf("no host available for the run after %d picks", r.picks)
+		}
+		if r.picks > 0 {
+			r.history.addActivity()
+		}
+		r.picks++
+		r.picking = executeJobActivity(ctx, nil, pickHostActivity)
+	}
+	picking := r.picking
+	var taskList string
+	err := picking.Get(ctx, &taskList)
+	if r.picking == picking {
+		r.picking = nil
+		if err == nil {
+			r.taskList = taskList
+			workflowLogger(ctx).Info("Cron job run pinned to host.", zap.String("TaskList", taskList),
+				zap.Uint("Picks", r.picks))
+		}
+	}
+	return err
+}
+
+// execute executes the activity on the host of the run, and on the next host if the host is unavailable. A nil route
+// executes it on the task list of the job activities of the run.
+func (r *hostRoute) execute(ctx cadence.Context, activity interface{}, input CronJobInput, result interface{}) error {
+	if r == nil {
+		return executeJobActivity(ctx, nil, activity, input).Get(ctx, result)
+	}
+	for {
+		taskList := r.taskList
+		err := executeJobActivity(ctx, func(options *cadence.ActivityOptions) {
+			options.TaskList = taskList
+			options.ScheduleToStartTimeout = r.spec.ScheduleToStart
+		}, activity, input).Get(ctx, result)
+		if err == nil || !scheduleToStartTimedOut(err) {
+			return err
+		}
+		workflowLogger(ctx).Warn("Cron job host unavailable, picking another host.", zap.String("TaskList", taskList),
+			zap.Uint("Shard", input.Shard), zap.Error(err))
+		// another shard may have picked the next host already.
+		if r.taskList == taskList || r.picking != nil {
+			if err := r.pick(ctx); err != nil {
+				return err
+			}
+		}
+		r.history.addActivity()
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_HostAffinity() {
+	env := s.NewTestWorkflowEnvironment()
+	// the shards can only execute on the task list of the host, they fail on the shared one.
+	env.SetActivityTaskList("cronGroup_host1", sampleCronActivity)
+	env.OnActivity(pickHostActivity, mock.Anything).Return("cronGroup_host1", nil).Times(2)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(6)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute, Parallelism: 3,
+		HostAffinity: &HostAffinitySpec{}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
+
+// withScheduleToStartTimeouts makes the test environment's failures of activities that return a ScheduleToStart
+// timeout count as an unavailable host or task list, the test environment doesn't time out activities itself. It
+// returns the timeout error for the activities to return.
+func withScheduleToStartTimeouts() (cadence.TimeoutError, func()) {
+	original := scheduleToStartTimedOut
+	timeout := cadence.NewTimeoutError(shared.TimeoutType_SCHEDULE_TO_START)
+	// the test environment fails the activity with an error of the message of the timeout.
+	scheduleToStartTimedOut = func(err error) bool {
+		withDetails, ok := err.(cadence.ErrorWithDetails)
+		return ok && withDetails.Reason() == timeout.Error()
+	}
+	return timeout, func() {
+		scheduleToStartTimedOut = original
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_HostAffinityUnavailableHost() {
+	timeout, restore := withScheduleToStartTimeouts()
+	defer restore()
+	core, logs := observer.New(zap.InfoLevel)
+	s.SetLogger(zap.New(core))
+	env := s.NewTestWorkflowEnvironment()
+	s.SetLogger(nil)
+	env.SetActivityTaskList("cronGroup_host1", sampleCronActivity)
+	env.SetActivityTaskList("cronGroup_host2", sampleCronActivity)
+	env.OnActivity(pickHostActivity, mock.Anything).Return("cronGroup_host1", nil).Once()
+	env.OnActivity(pickHostActivity, mock.Anything).Return("cronGroup_host2", nil).Once()
+	// both shards time out on the first host, which is picked once for the run, and complete on the next one.
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, timeout).Times(2)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{ProcessedBatches: 1}, nil).Times(2)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 1, ScheduleInterval: time.Minute, Parallelism: 2,
+		HostAffinity: &HostAffinitySpec{}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+	unavailable := logs.FilterMessage("Cron job host unavailable, picking another host.")
+	s.Equal(2, unavailable.FilterField(zap.String("TaskList", "cronGroup_host1")).Len())
+	pinned := logs.FilterMessage("Cron job run pinned to host.")
+	s.Equal(1, pinned.FilterField(zap.String("TaskList", "cronGroup_host1")).Len())
+	s.Equal(1, pinned.FilterField(zap.String("TaskList", "cronGroup_host2")).Len())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_HostAffinityNoHostAvailable() {
+	timeout, restore := withScheduleToStartTimeouts()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	env.SetActivityTaskList("cronGroup_host1", sampleCronActivity)
+	env.OnActivity(pickHostActivity, mock.Anything).Return("cronGroup_host1", nil).Times(2)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, timeout).Times(2)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		HostAffinity: &HostAffinitySpec{MaxPicks: 2}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.EqualError(env.GetWorkflowError(), "1 of 1 shards failed [0], first error: no host available for the run "+
+		"after 2 picks")
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_HostAffinity() {
+	s.True(scheduleToStartTimedOut(cadence.NewTimeoutError(shared.TimeoutType_SCHEDULE_TO_START)))
+	s.False(scheduleToStartTimedOut(cadence.NewTimeoutError(shared.TimeoutType_START_TO_CLOSE)))
+	s.False(scheduleToStartTimedOut(errors.New("host unavailable")))
+	s.Error((&HostAffinitySpec{ScheduleToStart: -time.Second}).validate())
+
+	env := s.NewTestActivityEnvironment()
+	value, err := env.ExecuteActivity(pickHostActivity)
+	s.NoError(err)
+	var taskList string
+	s.NoError(value.Get(&taskList))
+	s.Equal(hostTaskList, taskList)
+	s.True(strings.HasPrefix(taskList, ApplicationName+"_"))
+}
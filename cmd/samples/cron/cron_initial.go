@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with an InitialDelay or a StartAt waits for it before its first scheduled run, instead of an interval
+ * after the start, e.g. until a provisioning step completes. The initial wait is the regular wait for the next run with
+ * another due time, the workflow handles the pause, drain and triggerNow signals during it as it does during any wait,
+ * and a manual run doesn't end it. The workflow turns an InitialDelay into a StartAt when the schedule starts, so the
+ * StartAt is carried over a continue-as-new before the first scheduled run, and clears it once that run is due. The
+ * status query reports the initial wait while the StartAt is set. The derived workflow timeout of the first run covers
+ * the initial wait on top of the runs until the next continue-as-new.
+ *
+ * A StartAt that already passed when the workflow waits for it is handled by the CatchUpPolicy: CatchUpBackfill starts
+ * the first run right away, still scheduled for the StartAt, and CatchUpSkip skips to the first slot a whole number of
+ * intervals, or days for a schedule by time of day, after the StartAt that is not before now, counting the slots
+ * skipped as missed runs. The regular schedule follows from the first run.
+ */
+
+// validateInitialWait checks the InitialDelay and the StartAt.
+func (s *ScheduleSpec) validateInitialWait() error {
+	if s.InitialDelay < 0 {
+		return errors.New("initial delay must not be negative")
+	}
+	if s.InitialDelay > 0 && !s.StartAt.IsZero() {
+		return errors.New("initial delay and start at are mutually exclusive")
+	}
+	if (s.InitialDelay > 0 || !s.StartAt.IsZero()) && len(s.Jobs) > 0 {
+		return errors.New("a schedule with jobs doesn't support an initial delay or start at")
+	}
+	return nil
+}
+
+// initialWait returns how long the first scheduled run waits for the InitialDelay or the StartAt, from the given time.
+// A StartAt is only known to be ahead with a time.
+func (s *ScheduleSpec) initialWait(now time.Time) time.Duration {
+	if s.InitialDelay > 0 {
+		return s.InitialDelay
+	}
+	if !now.IsZero() && s.StartAt.After(now) {
+		return s.StartAt.Sub(now)
+	}
+	return 0
+}
+
+// startInitialWait turns the InitialDelay into a StartAt, at the given start of the schedule.
+func (s *ScheduleSpec) startInitialWait(start time.Time) {
+	if s.InitialDelay > 0 {
+		s.StartAt = start.Add(s.InitialDelay)
+		s.InitialDelay = 0
+	}
+}
+
+// waitsForStart returns true while the first scheduled run waits for the StartAt.
+func (s *ScheduleSpec) waitsForStart() bool {
+	return !s.StartAt.IsZero()
+}
+
+// initialRunTime returns the time the first scheduled run is due at. A StartAt that passed is handled by the
+// CatchUpPolicy, a skip moves the StartAt to the slot the run is due at.
+func (s *ScheduleSpec) initialRunTime(ctx cadence.Context) time.Time {
+	now := cadence.Now(ctx)
+	if !s.StartAt.Before(now) || s.CatchUpPolicy == CatchUpBackfill {
+		return s.StartAt
+	}
+	period := s.ScheduleInterval
+	if s.daily != nil {
+		period = time.Hour * 24
+	}
+	slots := (now.Sub(s.StartAt) + period - 1) / period
+	passed := s.StartAt
+	s.StartAt = s.StartAt.Add(slots * period)
+	s.MissedRuns += uint(slots)
+	workflowLogger(ctx).Info("Cron job start time passed, skipped to the next slot.", zap.Time("StartAt", passed),
+		zap.Time("FirstRun", s.StartAt), zap.Int64("Skipped", int64(slots)), zap.Uint("TotalMissed", s.MissedRuns))
+	return s.StartAt
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+// initialWaitRuns records the time since the start of the test environment and the scheduled time of every run.
+func initialWaitRuns(env *cadence.TestWorkflowEnvironment) (runTimes *[]time.Duration, scheduled *[]time.Time) {
+	runTimes, scheduled = new([]time.Duration), new([]time.Time)
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		*runTimes = append(*runTimes, env.Now().Sub(time.Unix(0, 0)))
+		*scheduled = append(*scheduled, input.ScheduledTime)
+		return CronJobResult{}, nil
+	})
+	return runTimes, scheduled
+}
+
+func Test_CronWorkflow_InitialDelay(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, scheduled := initialWaitRuns(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour,
+		InitialDelay: time.Minute * 10}, &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	// the regular schedule follows from the first run.
+	require.Equal(t, []time.Duration{time.Minute * 10, time.Minute * 70, time.Minute * 130}, *runTimes)
+	require.Equal(t, time.Unix(0, 0).Add(time.Minute*10), (*scheduled)[0])
+}
+
+func Test_CronWorkflow_InitialDelayBeyondDefaultTimeout(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, _ := initialWaitRuns(env)
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Second * 5, InitialDelay: time.Hour}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	// the first run is an hour later, the run of the workflow lasts until the runs after it.
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []time.Duration{time.Hour, time.Hour + time.Second*5, time.Hour + time.Second*10}, *runTimes)
+}
+
+func Test_ScheduleSpec_ValidateInitialWaitTimeout(t *testing.T) {
+	now := time.Unix(0, 0)
+	for _, tc := range []struct {
+		name     string
+		spec     ScheduleSpec
+		workflow time.Duration
+	}{
+		{"initial delay", ScheduleSpec{ScheduleInterval: time.Second * 5, InitialDelay: time.Hour},
+			workflowTimeout + time.Hour},
+		{"start at", ScheduleSpec{ScheduleInterval: time.Hour, StartAt: now.Add(time.Hour * 2)},
+			time.Hour * (loopCountBeforeContinueAsNew + 3)},
+		{"start at passed", ScheduleSpec{ScheduleInterval: time.Second * 5, StartAt: now.Add(-time.Hour)},
+			workflowTimeout},
+		{"set", ScheduleSpec{ScheduleInterval: time.Second * 5, InitialDelay: time.Hour,
+			Timeouts: Timeouts{Workflow: time.Hour * 2}}, time.Hour * 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.spec.Validate(now))
+			require.Equal(t, tc.workflow, tc.spec.Timeouts.Workflow)
+		})
+	}
+}
+
+func Test_CronWorkflow_StartAt(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, _ := initialWaitRuns(env)
+	// a manual run during the initial wait doesn't end it.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+	}, time.Minute*5)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		StartAt: time.Unix(0, 0).Add(time.Minute * 30)}, &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []time.Duration{time.Minute * 5, time.Minute * 30, time.Minute * 90}, *runTimes)
+}
+
+func Test_CronWorkflow_StartAtPassed(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	startAt := time.Unix(0, 0).Add(-time.Minute * 150)
+
+	// a backfill runs the first run right away, scheduled for the StartAt.
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, scheduled := initialWaitRuns(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour, StartAt: startAt,
+		CatchUpPolicy: CatchUpBackfill}, &CronState{})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []time.Duration{0, time.Hour}, *runTimes)
+	require.Equal(t, startAt, (*scheduled)[0])
+
+	// a skip runs at the next slot of the StartAt, the slots that passed are missed.
+	env = suite.NewTestWorkflowEnvironment()
+	runTimes, scheduled = initialWaitRuns(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour, StartAt: startAt},
+		&CronState{})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []time.Duration{time.Minute * 30, time.Minute * 90}, *runTimes)
+	require.Equal(t, time.Unix(0, 0).Add(time.Minute*30), (*scheduled)[0])
+	var summary CronSummary
+	require.NoError(t, env.GetWorkflowResult(&summary))
+	require.Equal(t, uint(3), summary.SkippedRuns)
+}
+
+func Test_CronWorkflow_StartAtAcrossContinueAsNew(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	runTimes, _ := initialWaitRuns(env)
+	for i := 1; i <= loopCountBeforeContinueAsNew; i++ {
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+		}, time.Minute*time.Duration(i))
+	}
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		InitialDelay: time.Hour * 10}, &CronState{})
+
+	// the manual runs continued the workflow as new before the first scheduled run, it keeps the StartAt.
+	require.True(t, env.IsWorkflowCompleted())
+	require.Len(t, *runTimes, loopCountBeforeContinueAsNew)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	next := args[0].(ScheduleSpec)
+	require.Equal(t, time.Duration(0), next.InitialDelay)
+	require.Equal(t, time.Unix(0, 0).Add(time.Hour*10), next.StartAt)
+
+	env = suite.NewTestWorkflowEnvironment()
+	runTimes, _ = initialWaitRuns(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []time.Duration{time.Hour * 10, time.Hour * 11}, *runTimes)
+}
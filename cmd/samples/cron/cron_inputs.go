@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with JobInputs works through a list: every run takes the next input off the list and passes it to the
+ * job activity as its JobInput, with its index in the list as JobInputIndex. The schedule completes once the list is
+ * exhausted, its JobCount is optional and limits the runs further. A manual run takes the next input too, even with
+ * KeepJobCount. The inputs left are carried over continue-as-new in the spec, so the list is part of the input of every
+ * run of the workflow. It is limited to maxJobInputsSize, the batch sample pages through larger lists instead of
+ * carrying them, see recipes/batch.
+ *
+ * A run whose input failed is recorded in the CronState as a FailedItem when the schedule goes on with
+ * FailureContinue, and the summary of the schedule lists the failed items once it closes.
+ */
+
+// maxJobInputsSize is the size in bytes the JobInputs of a schedule may have at most.
+const maxJobInputsSize = 256 * 1024
+
+type (
+	// FailedItem is an input of the JobInputs whose run failed.
+	FailedItem struct {
+		// Index is the index of the input in the JobInputs the schedule was started with.
+		Index uint
+		Input json.RawMessage
+		// Error is the error of the run.
+		Error string
+	}
+
+	// jobItem is the input of the JobInputs a run took.
+	jobItem struct {
+		index uint
+		input json.RawMessage
+	}
+)
+
+// hasJobInputs returns true if the schedule works through JobInputs, also once the list is exhausted.
+func (s *ScheduleSpec) hasJobInputs() bool {
+	return len(s.JobInputs) > 0 || s.JobInputIndex > 0
+}
+
+func (s *ScheduleSpec) validateJobInputs() error {
+	if !s.hasJobInputs() {
+		return nil
+	}
+	if len(s.Jobs) > 0 || len(s.JobInput) > 0 {
+		return errors.New("job inputs can't be combined with jobs or a job input, every run takes its input from the list")
+	}
+	size := 0
+	for i, input := range s.JobInputs {
+		if !json.Valid(input) {
+			return fmt.Errorf("job input %d %q is not JSON", s.JobInputIndex+uint(i), input)
+		}
+		size += len(input)
+	}
+	if size > maxJobInputsSize {
+		return fmt.Errorf("the %d job inputs are %d bytes, more than the %d bytes a schedule carries through "+
+			"continue-as-new, use the batch sample for larger lists", len(s.JobInputs), size, maxJobInputsSize)
+	}
+	return nil
+}
+
+// startJobInputs sets the JobCount of a schedule with JobInputs when the workflow starts, a schedule without a
+// JobCount runs all of them.
+func (s *ScheduleSpec) startJobInputs() {
+	if s.JobCount == 0 {
+		s.JobCount = uint(len(s.JobInputs))
+	}
+	s.limitJobCountToInputs()
+}
+
+// limitJobCountToInputs limits the runs left to the JobInputs left.
+func (s *ScheduleSpec) limitJobCountToInputs() {
+	if left := uint(len(s.JobInputs)); s.hasJobInputs() && s.JobCount > left {
+		s.JobCount = left
+	}
+}
+
+// takeJobInput takes the next of the JobInputs off the list for a run with the given spec, which gets the input as
+// its JobInput. It returns nil for a schedule without JobInputs.
+func (s *ScheduleSpec) takeJobInput(ctx cadence.Context, runSpec *ScheduleSpec) *jobItem {
+	if len(s.JobInputs) == 0 {
+		return nil
+	}
+	item := &jobItem{index: s.JobInputIndex, input: s.JobInputs[0]}
+	s.JobInputs = s.JobInputs[1:]
+	s.JobInputIndex++
+	s.limitJobCountToInputs()
+	// the run doesn't carry the list, e.g. into the input of its child workflow.
+	runSpec.JobInputs = nil
+	runSpec.JobInput = item.input
+	runSpec.JobInputIndex = item.index
+	workflowLogger(ctx).Info("Cron job input taken.", zap.Uint("JobInputIndex", item.index),
+		zap.Int("JobInputsLeft", len(s.JobInputs)))
+	return item
+}
+
+// onItemFailed records the failed input of a run of a schedule that goes on.
+func (s *CronState) onItemFailed(item *jobItem, err error) {
+	if item == nil {
+		return
+	}
+	s.FailedItems = append(s.FailedItems, FailedItem{Index: item.index, Input: item.input, Error: err.Error()})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+// jobInputRuns records the JobInputIndex and the JobInput of the runs of sampleCronActivity, the runs with the given
+// indexes fail.
+func jobInputRuns(env *cadence.TestWorkflowEnvironment, failing ...uint) *[]string {
+	var runs []string
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, fmt.Sprintf("%d:%s", input.JobInputIndex, input.JobInput))
+		for _, index := range failing {
+			if index == input.JobInputIndex {
+				return CronJobResult{}, errors.New("item failed")
+			}
+		}
+		return CronJobResult{}, nil
+	})
+	return &runs
+}
+
+// failedItemError is the error of a run whose input failed with jobInputRuns.
+const failedItemError = "1 of 1 shards failed [0], first error: item failed"
+
+func testJobInputs(count int) []json.RawMessage {
+	inputs := make([]json.RawMessage, count)
+	for i := range inputs {
+		inputs[i] = json.RawMessage(fmt.Sprintf(`{"item":"i%d"}`, i))
+	}
+	return inputs
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobInputs() {
+	testCases := []struct {
+		name     string
+		jobCount uint
+		runs     []string
+	}{
+		// the schedule without a JobCount runs every input, in order.
+		{"all inputs", 0, []string{`0:{"item":"i0"}`, `1:{"item":"i1"}`, `2:{"item":"i2"}`}},
+		{"job count limits", 2, []string{`0:{"item":"i0"}`, `1:{"item":"i1"}`}},
+		// the list is exhausted before the JobCount.
+		{"inputs exhausted", 5, []string{`0:{"item":"i0"}`, `1:{"item":"i1"}`, `2:{"item":"i2"}`}},
+	}
+	for _, tc := range testCases {
+		env := s.NewTestWorkflowEnvironment()
+		runs := jobInputRuns(env)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: tc.jobCount, ScheduleInterval: time.Minute,
+			JobInputs: testJobInputs(3)}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.NoError(env.GetWorkflowError(), tc.name)
+		s.Equal(tc.runs, *runs, tc.name)
+		var summary CronSummary
+		s.NoError(env.GetWorkflowResult(&summary), tc.name)
+		s.Equal(uint(len(tc.runs)), summary.SuccessfulRuns, tc.name)
+		s.Equal(time.Minute*time.Duration(len(tc.runs)), env.Now().Sub(time.Unix(0, 0)), tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobInputsTriggered() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := jobInputRuns(env)
+	// the manual run takes the next input as well, it doesn't count against the JobCount but the list is exhausted.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+	}, time.Second*30)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		JobInputs: testJobInputs(3)}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{`0:{"item":"i0"}`, `1:{"item":"i1"}`, `2:{"item":"i2"}`}, *runs)
+	s.Equal(time.Second*150, env.Now().Sub(time.Unix(0, 0)))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobInputsAcrossContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := jobInputRuns(env, 1)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{ScheduleInterval: time.Minute, FailurePolicy: FailureContinue,
+		JobInputs: testJobInputs(13)}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Len(*runs, loopCountBeforeContinueAsNew)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	spec, state := args[0].(ScheduleSpec), args[1].(*CronState)
+	// the next run of the workflow gets the inputs left.
+	s.Equal(testJobInputs(13)[10:], spec.JobInputs)
+	s.Equal(uint(10), spec.JobInputIndex)
+	s.Equal(uint(3), spec.JobCount)
+	s.Equal([]FailedItem{{Index: 1, Input: testJobInputs(13)[1], Error: failedItemError}}, state.FailedItems)
+
+	env = s.NewTestWorkflowEnvironment()
+	runs = jobInputRuns(env, 11)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{`10:{"item":"i10"}`, `11:{"item":"i11"}`, `12:{"item":"i12"}`}, *runs)
+	var summary CronSummary
+	s.NoError(env.GetWorkflowResult(&summary))
+	s.Equal(uint(13), summary.TotalRuns)
+	s.Equal(uint(2), summary.FailedRuns)
+	s.Equal([]FailedItem{{Index: 1, Input: testJobInputs(13)[1], Error: failedItemError},
+		{Index: 11, Input: testJobInputs(13)[11], Error: failedItemError}}, summary.FailedItems)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobInputsAsChildWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	runs := jobInputRuns(env)
+	var childInputs []int
+	env.SetOnChildWorkflowStartedListener(func(info *cadence.WorkflowInfo, ctx cadence.Context,
+		args cadence.EncodedValues) {
+		var input CronRunInput
+		s.NoError(args.Get(&input))
+		childInputs = append(childInputs, len(input.Spec.JobInputs))
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{ScheduleInterval: time.Minute, RunAsChildWorkflow: true,
+		JobInputs: testJobInputs(2)}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{`0:{"item":"i0"}`, `1:{"item":"i1"}`}, *runs)
+	// the child workflows get the input of their run, not the list.
+	s.Equal([]int{0, 0}, childInputs)
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_ValidateJobInputs() {
+	s.NoError((&ScheduleSpec{JobInputs: testJobInputs(3)}).validateJobInputs())
+	s.Error((&ScheduleSpec{JobInputs: []json.RawMessage{json.RawMessage(`{"item"`)}}).validateJobInputs())
+	s.Error((&ScheduleSpec{JobInputs: testJobInputs(1), JobInput: json.RawMessage(`{}`)}).validateJobInputs())
+	s.Error((&ScheduleSpec{JobInputs: testJobInputs(1), Jobs: []JobSpec{{Name: "billing",
+		Interval: time.Minute}}}).validateJobInputs())
+
+	// a list too large to carry through continue-as-new fails the start of the workflow.
+	large := make([]json.RawMessage, 100)
+	for i := range large {
+		large[i] = json.RawMessage(fmt.Sprintf("%q", strings.Repeat("x", maxJobInputsSize/50)))
+	}
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{ScheduleInterval: time.Minute, JobInputs: large},
+		&CronState{})
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(specProblems(env.GetWorkflowError()), "batch sample")
+}
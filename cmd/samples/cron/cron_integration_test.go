@@ -0,0 +1,70 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+)
+
+// The test runs SampleCronWorkflow on the Cadence server of the config, with the workers of the sample:
+//
+//	go test -tags integration -run TestIntegration ./cron
+func TestIntegration_SampleCronWorkflow(t *testing.T) {
+	h, err := common.ConnectIntegration("", time.Second*10)
+	require.NoError(t, err)
+	// the items of the job take milliseconds, the transient failures of the first attempts are retried after 1s.
+	workItemDuration = time.Millisecond * 10
+	startWorkers(h, workerRoles[modeWorker], "")
+	defer h.StopWorkers()
+
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Second, TraceID: uuid.New(),
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3,
+			NonRetriableErrorReasons: []string{errReasonInvalidShard, errReasonInvalidInput}}}
+	spec.withLatestVersions()
+	require.NoError(t, spec.Validate(time.Time{}))
+	timeouts := spec.timeouts()
+	options := cadence.StartWorkflowOptions{
+		ID:                              "cron_integration_" + uuid.New(),
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    timeouts.Workflow,
+		DecisionTaskStartToCloseTimeout: timeouts.Decision,
+	}
+	client, err := h.Builder.BuildCadenceClient()
+	require.NoError(t, err)
+	we, err := startSchedule(client, options, false, spec, &CronState{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, common.TerminateIfOpen(client, we.ID, "integration test done"))
+	}()
+
+	history, err := common.WaitForClose(client, we.ID, we.RunID, time.Minute*2)
+	require.NoError(t, err)
+	last := history.Events[len(history.Events)-1]
+	require.Equal(t, s.EventType_WorkflowExecutionCompleted, last.GetEventType(), "the workflow closed with %v",
+		last)
+
+	// every run completed the job once, after its retries.
+	activityTypes := make(map[int64]string)
+	completed := 0
+	for _, event := range history.Events {
+		switch event.GetEventType() {
+		case s.EventType_ActivityTaskScheduled:
+			activityTypes[event.GetEventId()] = event.GetActivityTaskScheduledEventAttributes().GetActivityType().GetName()
+		case s.EventType_ActivityTaskCompleted:
+			scheduledEventID := event.GetActivityTaskCompletedEventAttributes().GetScheduledEventId()
+			if activityTypes[scheduledEventID] == getFunctionName(sampleCronActivity) {
+				completed++
+			}
+		}
+	}
+	require.Equal(t, 3, completed)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The interceptors log the start and the end of every workflow and activity the worker executes, with its duration,
+ * and count the executions by type, so the workflows and the activities don't log it themselves. The trace ID of the
+ * input is put in the context before the function is called, and is a field of the logs of the interceptors and of
+ * workflowLogger and activityLogger. A panic of an activity is returned as an error with the reason
+ * errReasonActivityPanic, the details are the panic and the stack trace.
+ *
+ * Newer clients have workflow and activity interceptors that are set in the worker options. This version of the client
+ * has registration interceptors, which are called with every workflow and activity registered with the process and
+ * return the function that is registered instead. installInterceptors wraps the functions in one of the same type, the
+ * client calls it as it would call them. The workflow interceptor is workflow code: it logs with the logger of the
+ * workflow and counts with workflowMetrics, which are muted while the workflow is replayed, and the duration is in
+ * workflow time. The workers install the interceptors unless -interceptors=false.
+ */
+
+// errReasonActivityPanic is the reason of the error an activity that panicked returns.
+const errReasonActivityPanic = "activityPanic"
+
+const (
+	// metricWorkflowExecutions counts the executions of the workflows, tagged with the workflow type.
+	metricWorkflowExecutions = "cron.workflow_executions"
+	// metricActivityExecutions counts the executions of the activities, tagged with the activity type.
+	metricActivityExecutions = "cron.activity_executions"
+	// metricActivityPanics counts the executions of the activities that panicked, tagged with the activity type.
+	metricActivityPanics = "cron.activity_panics"
+)
+
+var (
+	workflowContextType = reflect.TypeOf((*cadence.Context)(nil)).Elem()
+	activityContextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType           = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// tracedInput is an input of a workflow or an activity that carries the trace ID of its schedule.
+type tracedInput interface {
+	traceID() string
+}
+
+func (s ScheduleSpec) traceID() string { return s.TraceID }
+func (i CronRunInput) traceID() string { return i.Spec.TraceID }
+func (i CronJobInput) traceID() string { return i.TraceID }
+
+// installInterceptors wraps the workflows and the activities registered with the process, before or after, in the
+// interceptors. It is called once per process.
+func installInterceptors() {
+	cadence.AddWorkflowRegistrationInterceptor(func(name string, workflow interface{}) (string, interface{}) {
+		return name, intercept(name, workflow, workflowContextType, interceptWorkflow)
+	})
+	cadence.AddActivityRegistrationInterceptor(func(name string, activity interface{}) (string, interface{}) {
+		return name, intercept(name, activity, activityContextType, interceptActivity)
+	})
+}
+
+// interceptFunc calls the intercepted function fn with the given arguments, the first of them is its context.
+type interceptFunc func(name, traceID string, fn reflect.Value, args []reflect.Value) []reflect.Value
+
+// intercept returns a function of the type of fn that calls fn through the interceptor, or fn if its first parameter is
+// not a context of the given type.
+func intercept(name string, fn interface{}, contextType reflect.Type, interceptor interceptFunc) interface{} {
+	value := reflect.ValueOf(fn)
+	fnType := value.Type()
+	if fnType.NumIn() == 0 || fnType.In(0) != contextType {
+		return fn
+	}
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		var traceID string
+		for _, arg := range args[1:] {
+			if traced, ok := arg.Interface().(tracedInput); ok {
+				traceID = traced.traceID()
+			}
+		}
+		return interceptor(name, traceID, value, args)
+	}).Interface()
+}
+
+// interceptWorkflow logs and counts the execution of a workflow.
+func interceptWorkflow(name, traceID string, fn reflect.Value, args []reflect.Value) []reflect.Value {
+	ctx := withTraceID(args[0].Interface().(cadence.Context), traceID)
+	args[0] = reflect.ValueOf(ctx)
+	logger := workflowLogger(ctx).With(zap.String("WorkflowType", name))
+	workflowMetrics(ctx).Tagged(map[string]string{"workflowType": name}).Counter(metricWorkflowExecutions).Inc(1)
+	logger.Info("Workflow started.")
+	start := cadence.Now(ctx)
+	results := fn.Call(args)
+	logger.Info("Workflow finished.", zap.Duration("Duration", cadence.Now(ctx).Sub(start)),
+		zap.Error(resultError(results)))
+	return results
+}
+
+// interceptActivity logs and counts the execution of an activity, and returns a panic of the activity as an error.
+func interceptActivity(name, traceID string, fn reflect.Value, args []reflect.Value) (results []reflect.Value) {
+	ctx := withActivityTraceID(args[0].Interface().(context.Context), traceID)
+	args[0] = reflect.ValueOf(ctx)
+	// the logger of the activity has its ActivityType.
+	logger := activityLogger(ctx)
+	metrics := metricsScope.Tagged(map[string]string{"activityType": name})
+	metrics.Counter(metricActivityExecutions).Inc(1)
+	logger.Info("Activity started.")
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			metrics.Counter(metricActivityPanics).Inc(1)
+			results = panicResults(fn.Type(), p, debug.Stack())
+		}
+		logger.Info("Activity finished.", zap.Duration("Duration", time.Since(start)),
+			zap.Error(resultError(results)))
+	}()
+	return fn.Call(args)
+}
+
+// panicResults returns the results of an activity of the given type that panicked: zero values and the error of the
+// panic, with the panic and the stack trace as the details.
+func panicResults(fnType reflect.Type, p interface{}, stack []byte) []reflect.Value {
+	results := make([]reflect.Value, fnType.NumOut())
+	for i := range results {
+		results[i] = reflect.Zero(fnType.Out(i))
+	}
+	var err error = cadence.NewErrorWithDetails(errReasonActivityPanic, fmt.Sprint(p), string(stack))
+	results[len(results)-1] = reflect.ValueOf(&err).Elem()
+	return results
+}
+
+// resultError returns the error of the results of a workflow or an activity, nil if it succeeded.
+func resultError(results []reflect.Value) error {
+	if len(results) == 0 {
+		return nil
+	}
+	last := results[len(results)-1]
+	if last.Type() != errorType || last.IsNil() {
+		return nil
+	}
+	return last.Interface().(error)
+}
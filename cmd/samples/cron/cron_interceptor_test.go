@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// panickingActivity is registered with the process by TestMain, so it is executed through the interceptors.
+func panickingActivity(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+	panic("index out of range")
+}
+
+// hasField returns true if the entry has a field with the given key.
+func hasField(entry observer.LoggedEntry, key string) bool {
+	for _, field := range entry.Context {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_Interceptors_CronWorkflow(t *testing.T) {
+	scope, restore := withTestMetrics()
+	defer restore()
+	core, logs := observer.New(zap.InfoLevel)
+	var suite cadence.WorkflowTestSuite
+	suite.SetLogger(zap.New(core))
+	env := suite.NewTestWorkflowEnvironment()
+	// the workflow of the type is the one registered with the process, wrapped in the interceptor, a function would
+	// be registered with the test suite as it is.
+	env.ExecuteWorkflow(getFunctionName(SampleCronWorkflow), ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		JobActivityName: "report", TraceID: "trace-1"}, &CronState{})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	workflowType := getFunctionName(SampleCronWorkflow)
+	require.Equal(t, 1, logs.FilterMessage("Workflow started.").FilterField(zap.String("WorkflowType", workflowType)).
+		FilterField(zap.String("TraceID", "trace-1")).Len())
+	finished := logs.FilterMessage("Workflow finished.")
+	require.Equal(t, 1, finished.Len())
+	// the first run is due an interval after the start, the second one an interval later.
+	require.Equal(t, 1, finished.FilterField(zap.Duration("Duration", time.Hour*2)).Len())
+	require.False(t, hasField(finished.All()[0], "error"))
+	require.Equal(t, int64(1), counterValue(scope, metricWorkflowExecutions, map[string]string{
+		"workflowType": workflowType}))
+
+	activityType := getFunctionName(reportJobActivity)
+	jobs := logs.FilterMessage("Activity finished.").FilterField(zap.String("ActivityType", activityType))
+	require.Equal(t, 2, jobs.Len())
+	// the trace ID of the input is in the logs of the interceptor.
+	require.Equal(t, 2, jobs.FilterField(zap.String("TraceID", "trace-1")).Len())
+	for _, entry := range jobs.All() {
+		require.True(t, hasField(entry, "Duration"))
+	}
+	require.Equal(t, int64(2), counterValue(scope, metricActivityExecutions, map[string]string{
+		"activityType": activityType}))
+	require.Equal(t, int64(0), counterValue(scope, metricActivityPanics, nil))
+}
+
+func Test_Interceptors_ActivityPanic(t *testing.T) {
+	scope, restore := withTestMetrics()
+	defer restore()
+	core, logs := observer.New(zap.InfoLevel)
+	var suite cadence.WorkflowTestSuite
+	suite.SetLogger(zap.New(core))
+	env := suite.NewTestActivityEnvironment()
+	_, err := env.ExecuteActivity(panickingActivity, CronJobInput{TraceID: "trace-1"})
+	require.Error(t, err)
+	withDetails, ok := err.(cadence.ErrorWithDetails)
+	require.True(t, ok, "%T %v", err, err)
+	require.Equal(t, errReasonActivityPanic, withDetails.Reason())
+	var panicked, stack string
+	withDetails.Details(&panicked, &stack)
+	require.Equal(t, "index out of range", panicked)
+	require.Contains(t, stack, "panickingActivity")
+
+	activityType := getFunctionName(panickingActivity)
+	finished := logs.FilterMessage("Activity finished.").FilterField(zap.String("TraceID", "trace-1"))
+	require.Equal(t, 1, finished.Len())
+	require.True(t, hasField(finished.All()[0], "error"))
+	require.Equal(t, int64(1), counterValue(scope, metricActivityPanics, map[string]string{
+		"activityType": activityType}))
+}
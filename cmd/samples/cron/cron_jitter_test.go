@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_Jitter() {
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, Jitter: time.Minute * 30}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(runTimes, 5)
+	last := time.Duration(0)
+	// the timers round the delay up to a whole second, a jitter just below Jitter reaches it.
+	for _, runTime := range runTimes {
+		delay := runTime - last
+		s.True(delay >= spec.ScheduleInterval && delay <= spec.ScheduleInterval+spec.Jitter, "delay %v out of range", delay)
+		last = runTime
+	}
+}
+
+func TestReplay_CronWorkflowWithJitter(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, Jitter: time.Minute * 30}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+
+	delays := h.timerDurations()
+	require.Len(t, delays, 5)
+	for _, delay := range delays {
+		require.True(t, delay >= spec.ScheduleInterval && delay <= spec.ScheduleInterval+spec.Jitter,
+			"delay %v out of range", delay)
+	}
+}
+
+func TestReplay_CronWorkflowWithJitterAndSignals(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour, Jitter: time.Minute * 10}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	h.activityDuration = time.Minute
+	h.signalAfter(time.Minute*20, pauseSignalName, "incident")
+	h.signalAfter(time.Minute*50, resumeSignalName, "resolved")
+	h.signalAfter(time.Hour*3, triggerNowSignalName, TriggerNowRequest{})
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	require.Len(t, h.activityScheduleTimes(), 3)
+}
+
+func TestReplay_CronWorkflowJitterAfterManualRuns(t *testing.T) {
+	// the manual runs keep the job count, every wait after one of them starts with the same pending job count.
+	testCases := []struct {
+		name       string
+		latest     bool
+		sameJitter bool
+	}{
+		// a run started before the jitter was drawn with a side effect seeds it from the job count on replay.
+		{"seeded", false, true},
+		{"side effect", true, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 1, ScheduleInterval: time.Hour, Jitter: time.Minute * 30}
+			if tc.latest {
+				spec.withLatestVersions()
+			}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+			for _, at := range []time.Duration{time.Minute * 10, time.Minute * 20, time.Minute * 30} {
+				h.signalAfter(at, triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+			}
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			// the scheduled run follows the manual runs.
+			require.Len(t, h.activityInputs(), 4)
+			delays := h.timerDurations()
+			require.Len(t, delays, 4)
+			same := true
+			for _, delay := range delays {
+				require.True(t, delay >= spec.ScheduleInterval && delay <= spec.ScheduleInterval+spec.Jitter,
+					"delay %v out of range", delay)
+				same = same && delay == delays[0]
+			}
+			require.Equal(t, tc.sameJitter, same, "%v", delays)
+			if tc.latest {
+				// a marker for the jitter of every wait besides the correlation ID of every run.
+				require.True(t, len(h.markerEvents()) >= len(delays)+len(h.activityInputs()))
+			} else {
+				require.Empty(t, h.markerEvents())
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * The list verb pages through ListOpenWorkflow and ListClosedWorkflow filtered by the type of the cron workflow. This
+ * version of the client has no advanced visibility, there is no ListWorkflowExecutions to try first and the executions
+ * can't be filtered by search attributes. There is no DescribeWorkflowExecution either, the describe verb reads the
+ * history of the run: the pending activities are the activities scheduled without an event that closes them.
+ */
+
+const defaultPageSize = 100
+
+type (
+	// listedExecution is an execution printed by the list verb.
+	listedExecution struct {
+		WorkflowID    string
+		RunID         string
+		Status        string
+		StartTime     time.Time
+		CloseTime     *time.Time `json:",omitempty"`
+		HistoryLength int64
+	}
+
+	// executionDescription is printed by the describe verb.
+	executionDescription struct {
+		WorkflowID                          string
+		RunID                               string `json:",omitempty"`
+		WorkflowType                        string
+		TaskList                            string
+		Status                              string
+		StartTime                           time.Time
+		CloseTime                           *time.Time `json:",omitempty"`
+		ExecutionStartToCloseTimeoutSeconds int32
+		HistoryLength                       int
+		PendingActivities                   []*pendingActivity
+	}
+
+	// pendingActivity is an activity of a run that is scheduled or started.
+	pendingActivity struct {
+		ActivityID      string
+		ActivityType    string
+		State           string
+		ScheduledTime   time.Time
+		StartedTime     *time.Time `json:",omitempty"`
+		Identity        string     `json:",omitempty"`
+		CancelRequested bool
+		// the timeouts of the activity, a started activity is stuck if it outlives them.
+		StartToCloseTimeoutSeconds int32
+		HeartbeatTimeoutSeconds    int32
+	}
+)
+
+// closeStatuses are the close statuses of the events that close a run.
+var closeStatuses = map[shared.EventType]shared.WorkflowExecutionCloseStatus{
+	shared.EventType_WorkflowExecutionCompleted:      shared.WorkflowExecutionCloseStatus_COMPLETED,
+	shared.EventType_WorkflowExecutionFailed:         shared.WorkflowExecutionCloseStatus_FAILED,
+	shared.EventType_WorkflowExecutionCanceled:       shared.WorkflowExecutionCloseStatus_CANCELED,
+	shared.EventType_WorkflowExecutionTerminated:     shared.WorkflowExecutionCloseStatus_TERMINATED,
+	shared.EventType_WorkflowExecutionContinuedAsNew: shared.WorkflowExecutionCloseStatus_CONTINUED_AS_NEW,
+	shared.EventType_WorkflowExecutionTimedOut:       shared.WorkflowExecutionCloseStatus_TIMED_OUT,
+}
+
+// openExecutions returns the open executions of the cron workflow, it lists all the pages of the given size.
+func openExecutions(client cadence.Client, pageSize int32) ([]*shared.WorkflowExecutionInfo, error) {
+	earliest, latest := int64(0), time.Now().UnixNano()
+	workflowType := cronWorkflowType
+	request := &shared.ListOpenWorkflowExecutionsRequest{MaximumPageSize: &pageSize,
+		StartTimeFilter: &shared.StartTimeFilter{EarliestTime: &earliest, LatestTime: &latest},
+		TypeFilter:      &shared.WorkflowTypeFilter{Name: &workflowType}}
+	var executions []*shared.WorkflowExecutionInfo
+	for {
+		response, err := client.ListOpenWorkflow(request)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, response.Executions...)
+		if len(response.NextPageToken) == 0 {
+			return executions, nil
+		}
+		request.NextPageToken = response.NextPageToken
+	}
+}
+
+// listExecutions prints the executions of the cron workflow of the status of the command, it lists all the pages.
+func listExecutions(client cadence.Client, command cliCommand, out io.Writer) error {
+	earliest, latest := int64(0), time.Now().UnixNano()
+	startTimeFilter := &shared.StartTimeFilter{EarliestTime: &earliest, LatestTime: &latest}
+	workflowType := cronWorkflowType
+	typeFilter := &shared.WorkflowTypeFilter{Name: &workflowType}
+	pageSize := int32(command.PageSize)
+	var executions []*shared.WorkflowExecutionInfo
+	if command.Status != "closed" {
+		open, err := openExecutions(client, pageSize)
+		if err != nil {
+			return err
+		}
+		executions = open
+	}
+	if command.Status != "open" {
+		request := &shared.ListClosedWorkflowExecutionsRequest{MaximumPageSize: &pageSize,
+			StartTimeFilter: startTimeFilter, TypeFilter: typeFilter}
+		for {
+			response, err := client.ListClosedWorkflow(request)
+			if err != nil {
+				return err
+			}
+			executions = append(executions, response.Executions...)
+			if len(response.NextPageToken) == 0 {
+				break
+			}
+			request.NextPageToken = response.NextPageToken
+		}
+	}
+
+	listed := make([]listedExecution, 0, len(executions))
+	for _, execution := range executions {
+		status := "OPEN"
+		var closeTime *time.Time
+		if execution.CloseStatus != nil {
+			status = execution.GetCloseStatus().String()
+			t := time.Unix(0, execution.GetCloseTime()).UTC()
+			closeTime = &t
+		}
+		listed = append(listed, listedExecution{
+			WorkflowID:    execution.GetExecution().GetWorkflowId(),
+			RunID:         execution.GetExecution().GetRunId(),
+			Status:        status,
+			StartTime:     time.Unix(0, execution.GetStartTime()).UTC(),
+			CloseTime:     closeTime,
+			HistoryLength: execution.GetHistoryLength(),
+		})
+	}
+	if command.JSON {
+		return printJSONLine(out, listResult{Executions: listed})
+	}
+	if command.Format == "json" {
+		return printJSON(out, listed)
+	}
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "WORKFLOW ID\tRUN ID\tSTATUS\tSTART TIME\tCLOSE TIME")
+	for _, execution := range listed {
+		closeTime := ""
+		if execution.CloseTime != nil {
+			closeTime = execution.CloseTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", execution.WorkflowID, execution.RunID, execution.Status,
+			execution.StartTime.Format(time.RFC3339), closeTime)
+	}
+	return table.Flush()
+}
+
+// describeExecution prints the run of the command and its pending activities as JSON.
+func describeExecution(client cadence.Client, command cliCommand, out io.Writer) error {
+	history, err := client.GetWorkflowHistory(command.WorkflowID, command.RunID)
+	if err != nil {
+		return err
+	}
+	if len(history.Events) == 0 {
+		return fmt.Errorf("workflow %s has no history", command.WorkflowID)
+	}
+	started := history.Events[0].GetWorkflowExecutionStartedEventAttributes()
+	description := executionDescription{
+		WorkflowID:                          command.WorkflowID,
+		RunID:                               command.RunID,
+		WorkflowType:                        started.GetWorkflowType().GetName(),
+		TaskList:                            started.GetTaskList().GetName(),
+		Status:                              "OPEN",
+		StartTime:                           eventTime(history.Events[0]),
+		ExecutionStartToCloseTimeoutSeconds: started.GetExecutionStartToCloseTimeoutSeconds(),
+		HistoryLength:                       len(history.Events),
+	}
+
+	// the activities by the ID of their scheduled event, and the IDs of the scheduled events by activity ID.
+	pending := make(map[int64]*pendingActivity)
+	scheduledEvents := make(map[string]int64)
+	var order []int64
+	for _, event := range history.Events {
+		var scheduledEventID int64
+		switch event.GetEventType() {
+		case shared.EventType_ActivityTaskScheduled:
+			attributes := event.GetActivityTaskScheduledEventAttributes()
+			pending[event.GetEventId()] = &pendingActivity{
+				ActivityID:                 attributes.GetActivityId(),
+				ActivityType:               attributes.GetActivityType().GetName(),
+				State:                      "SCHEDULED",
+				ScheduledTime:              eventTime(event),
+				StartToCloseTimeoutSeconds: attributes.GetStartToCloseTimeoutSeconds(),
+				HeartbeatTimeoutSeconds:    attributes.GetHeartbeatTimeoutSeconds(),
+			}
+			scheduledEvents[attributes.GetActivityId()] = event.GetEventId()
+			order = append(order, event.GetEventId())
+			continue
+		case shared.EventType_ActivityTaskStarted:
+			attributes := event.GetActivityTaskStartedEventAttributes()
+			if activity, ok := pending[attributes.GetScheduledEventId()]; ok {
+				startedTime := eventTime(event)
+				activity.State = "STARTED"
+				activity.StartedTime = &startedTime
+				activity.Identity = attributes.GetIdentity()
+			}
+			continue
+		case shared.EventType_ActivityTaskCancelRequested:
+			activityID := event.GetActivityTaskCancelRequestedEventAttributes().GetActivityId()
+			if activity, ok := pending[scheduledEvents[activityID]]; ok {
+				activity.CancelRequested = true
+			}
+			continue
+		case shared.EventType_ActivityTaskCompleted:
+			scheduledEventID = event.GetActivityTaskCompletedEventAttributes().GetScheduledEventId()
+		case shared.EventType_ActivityTaskFailed:
+			scheduledEventID = event.GetActivityTaskFailedEventAttributes().GetScheduledEventId()
+		case shared.EventType_ActivityTaskTimedOut:
+			scheduledEventID = event.GetActivityTaskTimedOutEventAttributes().GetScheduledEventId()
+		case shared.EventType_ActivityTaskCanceled:
+			scheduledEventID = event.GetActivityTaskCanceledEventAttributes().GetScheduledEventId()
+		default:
+			if status, ok := closeStatuses[event.GetEventType()]; ok {
+				closeTime := eventTime(event)
+				description.Status = status.String()
+				description.CloseTime = &closeTime
+			}
+			continue
+		}
+		delete(pending, scheduledEventID)
+	}
+	description.PendingActivities = []*pendingActivity{}
+	for _, eventID := range order {
+		if activity, ok := pending[eventID]; ok {
+			description.PendingActivities = append(description.PendingActivities, activity)
+		}
+	}
+	if command.JSON {
+		return printJSONLine(out, description)
+	}
+	return printJSON(out, description)
+}
+
+func eventTime(event *shared.HistoryEvent) time.Time {
+	return time.Unix(0, event.GetTimestamp()).UTC()
+}
+
+func printJSON(out io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+	"go.uber.org/cadence/mocks"
+)
+
+var listStart = time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+
+// runService parses the arguments and runs them with a client of the mocked service, and returns the output.
+func runService(t *testing.T, service *mocks.TChanWorkflowService, args ...string) (string, error) {
+	command, err := parseCommand(args, ioutil.Discard)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	err = runCommand(cadence.NewClient(service, "samples-domain", &cadence.ClientOptions{}), command, &out)
+	return out.String(), err
+}
+
+func executionInfo(workflowID string, closeStatus *s.WorkflowExecutionCloseStatus) *s.WorkflowExecutionInfo {
+	info := &s.WorkflowExecutionInfo{
+		Execution:     &s.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr("run-" + workflowID)},
+		Type:          &s.WorkflowType{Name: common.StringPtr(cronWorkflowType)},
+		StartTime:     common.Int64Ptr(listStart.UnixNano()),
+		HistoryLength: common.Int64Ptr(12),
+	}
+	if closeStatus != nil {
+		info.CloseStatus = closeStatus
+		info.CloseTime = common.Int64Ptr(listStart.Add(time.Hour).UnixNano())
+	}
+	return info
+}
+
+// openPage matches a request of the open executions of the cron workflow with the given page token.
+func openPage(token string) interface{} {
+	return mock.MatchedBy(func(request *s.ListOpenWorkflowExecutionsRequest) bool {
+		return string(request.NextPageToken) == token && request.GetMaximumPageSize() == 2 &&
+			request.GetTypeFilter().GetName() == cronWorkflowType && request.GetDomain() == "samples-domain"
+	})
+}
+
+func closedPage(token string) interface{} {
+	return mock.MatchedBy(func(request *s.ListClosedWorkflowExecutionsRequest) bool {
+		return string(request.NextPageToken) == token && request.GetTypeFilter().GetName() == cronWorkflowType
+	})
+}
+
+func Test_ListExecutions_Pages(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	service.On("ListOpenWorkflowExecutions", mock.Anything, openPage("")).Return(&s.ListOpenWorkflowExecutionsResponse{
+		Executions:    []*s.WorkflowExecutionInfo{executionInfo("cron_a", nil), executionInfo("cron_b", nil)},
+		NextPageToken: []byte("page-2"),
+	}, nil).Once()
+	service.On("ListOpenWorkflowExecutions", mock.Anything, openPage("page-2")).Return(
+		&s.ListOpenWorkflowExecutionsResponse{Executions: []*s.WorkflowExecutionInfo{executionInfo("cron_c", nil)}},
+		nil).Once()
+
+	out, err := runService(t, service, "list", "--page-size", "2")
+	require.NoError(t, err)
+	require.Equal(t, "WORKFLOW ID  RUN ID      STATUS  START TIME            CLOSE TIME\n"+
+		"cron_a       run-cron_a  OPEN    2023-06-01T08:00:00Z  \n"+
+		"cron_b       run-cron_b  OPEN    2023-06-01T08:00:00Z  \n"+
+		"cron_c       run-cron_c  OPEN    2023-06-01T08:00:00Z  \n", out)
+	service.AssertExpectations(t)
+}
+
+func Test_ListExecutions_All(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	service.On("ListOpenWorkflowExecutions", mock.Anything, openPage("")).Return(&s.ListOpenWorkflowExecutionsResponse{
+		Executions: []*s.WorkflowExecutionInfo{executionInfo("cron_a", nil)},
+	}, nil).Once()
+	service.On("ListClosedWorkflowExecutions", mock.Anything, closedPage("")).Return(
+		&s.ListClosedWorkflowExecutionsResponse{
+			Executions: []*s.WorkflowExecutionInfo{executionInfo("cron_b",
+				s.WorkflowExecutionCloseStatusPtr(s.WorkflowExecutionCloseStatus_CONTINUED_AS_NEW))},
+			NextPageToken: []byte("page-2"),
+		}, nil).Once()
+	service.On("ListClosedWorkflowExecutions", mock.Anything, closedPage("page-2")).Return(
+		&s.ListClosedWorkflowExecutionsResponse{}, nil).Once()
+
+	out, err := runService(t, service, "list", "--all", "--page-size", "2", "--format", "json")
+	require.NoError(t, err)
+	var listed []listedExecution
+	require.NoError(t, json.Unmarshal([]byte(out), &listed))
+	closeTime := listStart.Add(time.Hour)
+	require.Equal(t, []listedExecution{
+		{WorkflowID: "cron_a", RunID: "run-cron_a", Status: "OPEN", StartTime: listStart, HistoryLength: 12},
+		{WorkflowID: "cron_b", RunID: "run-cron_b", Status: "CONTINUED_AS_NEW", StartTime: listStart,
+			CloseTime: &closeTime, HistoryLength: 12},
+	}, listed)
+	service.AssertExpectations(t)
+
+	// only the closed executions are listed with --closed.
+	service = &mocks.TChanWorkflowService{}
+	service.On("ListClosedWorkflowExecutions", mock.Anything, closedPage("")).Return(
+		&s.ListClosedWorkflowExecutionsResponse{}, nil).Once()
+	out, err = runService(t, service, "list", "--closed", "--format", "json")
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", out)
+	service.AssertExpectations(t)
+}
+
+func Test_ListExecutions_Error(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	service.On("ListOpenWorkflowExecutions", mock.Anything, mock.Anything).Return(nil,
+		&s.BadRequestError{Message: "invalid page size"}).Once()
+	_, err := runService(t, service, "list")
+	code, message := commandExitCode(cliCommand{Verb: "list"}, err)
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, message, "invalid page size")
+}
+
+func Test_ParseCommand_List(t *testing.T) {
+	command, err := parseCommand([]string{"list"}, ioutil.Discard)
+	require.NoError(t, err)
+	require.Equal(t, cliCommand{Verb: "list", Status: "open", PageSize: defaultPageSize, Format: "table",
+		Timeout: defaultCommandTimeout}, command)
+
+	for _, tc := range []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"list", "--open", "--closed"}, "only one of"},
+		{[]string{"list", "--page-size", "0"}, "--page-size must be positive"},
+		{[]string{"list", "--format", "yaml"}, "unknown format"},
+		{[]string{"list", "--workflow-id", "cron_1"}, "not defined"},
+		{[]string{"describe"}, "--workflow-id is required"},
+	} {
+		_, err := parseCommand(tc.args, ioutil.Discard)
+		require.IsType(t, cliUsageError{}, err, "%v", tc.args)
+		require.Contains(t, err.Error(), tc.message)
+	}
+}
+
+// historyEvent returns an event of the given type, the attributes are set by the caller.
+func historyEvent(id int64, eventType s.EventType, offset time.Duration) *s.HistoryEvent {
+	return &s.HistoryEvent{EventId: common.Int64Ptr(id), EventType: common.EventTypePtr(eventType),
+		Timestamp: common.Int64Ptr(listStart.Add(offset).UnixNano())}
+}
+
+func Test_DescribeExecution(t *testing.T) {
+	started := historyEvent(1, s.EventType_WorkflowExecutionStarted, 0)
+	started.WorkflowExecutionStartedEventAttributes = &s.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        &s.WorkflowType{Name: common.StringPtr(cronWorkflowType)},
+		TaskList:                            &s.TaskList{Name: common.StringPtr(ApplicationName)},
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(600),
+	}
+	scheduled := func(id int64, activityID string) *s.HistoryEvent {
+		event := historyEvent(id, s.EventType_ActivityTaskScheduled, time.Minute)
+		event.ActivityTaskScheduledEventAttributes = &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:                 common.StringPtr(activityID),
+			ActivityType:               &s.ActivityType{Name: common.StringPtr("main.sampleCronActivity")},
+			StartToCloseTimeoutSeconds: common.Int32Ptr(60),
+			HeartbeatTimeoutSeconds:    common.Int32Ptr(10),
+		}
+		return event
+	}
+	startedActivity := historyEvent(4, s.EventType_ActivityTaskStarted, time.Minute*2)
+	startedActivity.ActivityTaskStartedEventAttributes = &s.ActivityTaskStartedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(2), Identity: common.StringPtr("worker-1")}
+	completed := historyEvent(5, s.EventType_ActivityTaskCompleted, time.Minute*3)
+	completed.ActivityTaskCompletedEventAttributes = &s.ActivityTaskCompletedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(3)}
+	cancelRequested := historyEvent(6, s.EventType_ActivityTaskCancelRequested, time.Minute*4)
+	cancelRequested.ActivityTaskCancelRequestedEventAttributes = &s.ActivityTaskCancelRequestedEventAttributes{
+		ActivityId: common.StringPtr("0")}
+	events := []*s.HistoryEvent{started, scheduled(2, "0"), scheduled(3, "1"), startedActivity, completed,
+		cancelRequested}
+
+	service := &mocks.TChanWorkflowService{}
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.MatchedBy(
+		func(request *s.GetWorkflowExecutionHistoryRequest) bool {
+			return request.GetExecution().GetWorkflowId() == "cron_1"
+		})).Return(&s.GetWorkflowExecutionHistoryResponse{History: &s.History{Events: events}}, nil).Once()
+
+	out, err := runService(t, service, "describe", "--workflow-id", "cron_1")
+	require.NoError(t, err)
+	var description executionDescription
+	require.NoError(t, json.Unmarshal([]byte(out), &description))
+	startedTime := listStart.Add(time.Minute * 2)
+	require.Equal(t, executionDescription{
+		WorkflowID:                          "cron_1",
+		WorkflowType:                        cronWorkflowType,
+		TaskList:                            ApplicationName,
+		Status:                              "OPEN",
+		StartTime:                           listStart,
+		ExecutionStartToCloseTimeoutSeconds: 600,
+		HistoryLength:                       6,
+		// the completed activity isn't pending.
+		PendingActivities: []*pendingActivity{{
+			ActivityID:                 "0",
+			ActivityType:               "main.sampleCronActivity",
+			State:                      "STARTED",
+			ScheduledTime:              listStart.Add(time.Minute),
+			StartedTime:                &startedTime,
+			Identity:                   "worker-1",
+			CancelRequested:            true,
+			StartToCloseTimeoutSeconds: 60,
+			HeartbeatTimeoutSeconds:    10,
+		}},
+	}, description)
+
+	// a closed run has its close status.
+	closed := historyEvent(2, s.EventType_WorkflowExecutionContinuedAsNew, time.Hour)
+	service = &mocks.TChanWorkflowService{}
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(
+		&s.GetWorkflowExecutionHistoryResponse{History: &s.History{Events: []*s.HistoryEvent{started, closed}}},
+		nil).Once()
+	out, err = runService(t, service, "describe", "--workflow-id", "cron_1", "--run-id", "run-1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &description))
+	require.Equal(t, "CONTINUED_AS_NEW", description.Status)
+	require.Equal(t, "run-1", description.RunID)
+	require.Equal(t, listStart.Add(time.Hour), *description.CloseTime)
+	require.Empty(t, description.PendingActivities)
+}
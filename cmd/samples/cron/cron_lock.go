@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * Cron workflows with the same Lock share a number of permits, so that no more runs of all of them execute at the same
+ * time than the backend of the job can take. The permits are held by a CronLockWorkflow per lock, which grants leases
+ * in the order they were requested. A run requests a lease before its job starts and releases it when the job
+ * completed, failed or was cancelled. A lease expires after its timeout, so that a holder that never releases it
+ * doesn't block the other holders forever. A run that takes longer than the lease timeout loses the lease while it is
+ * still executing.
+ *
+ * This version of the client has no SignalWithStart and workflows can't signal other workflows, so the signals go
+ * through activities that use the client of the worker. acquireLeaseActivity starts the lock workflow if it is not
+ * running yet, and signals the lease request to it. The lock workflow signals the grant back to the requesting workflow
+ * with grantLeaseActivity, a grant to a workflow that is gone frees the permit right away. There are no queries either,
+ * the workflow logs that a run is waiting for the lock, and times the wait.
+ */
+
+const (
+	// Signals of the lock workflow, acquireLease takes a LeaseRequest and releaseLease the ID of the lease.
+	acquireLeaseSignalName = "acquireLease"
+	releaseLeaseSignalName = "releaseLease"
+	// Signal of the cron workflow that grants a lease, it takes the ID of the lease.
+	leaseGrantedSignalName = "leaseGranted"
+
+	// lockLoopsBeforeContinueAsNew is the number of signals and expirations the lock workflow handles before it
+	// continues as new.
+	lockLoopsBeforeContinueAsNew = 100
+	// lockWorkflowTimeout is the execution timeout of the lock workflow, it continues as new long before.
+	lockWorkflowTimeout = time.Hour * 24 * 365
+)
+
+type (
+	// LockSpec is the lock the runs of a schedule hold while their job executes.
+	LockSpec struct {
+		// Name identifies the lock, the schedules with the same name share its permits.
+		Name string
+		// Permits is the number of runs that can hold the lock at the same time. It is set when the lock workflow is
+		// started, the schedules that start it later can't change it.
+		Permits int
+		// LeaseTimeout is the time after which the lease of a run expires if the run didn't release it.
+		LeaseTimeout time.Duration
+		// ActivityTaskList is the ActivityTaskList of the schedule, set by the workflow. The lock workflow schedules
+		// its activities on the one of the schedule that started it.
+		ActivityTaskList string
+	}
+
+	// LeaseRequest is the payload of the acquireLease signal.
+	LeaseRequest struct {
+		LeaseID string
+		// WorkflowID is the ID of the workflow the lease is granted to.
+		WorkflowID string
+		Timeout    time.Duration
+	}
+
+	// Lease is a lease granted by the lock workflow.
+	Lease struct {
+		LeaseID    string
+		WorkflowID string
+		Expires    time.Time
+	}
+
+	// LockState is the state of the lock workflow, it is carried over continue-as-new.
+	LockState struct {
+		Permits int
+		Holders []Lease
+		// Waiting are the lease requests that wait for a permit, in the order they were received.
+		Waiting []LeaseRequest
+		// ActivityTaskList is the task list of the activities of the lock workflow, empty means its own task list.
+		ActivityTaskList string
+	}
+
+	// cronLeases tracks the leases requested by the runs of a cron workflow.
+	cronLeases struct {
+		granted cadence.Channel
+		// pending settles the futures of the runs waiting for their lease by the ID of the lease.
+		pending map[string]cadence.Settable
+		count   uint
+		history *historyEstimate
+	}
+)
+
+// lockClient is the client the lock activities and forwardRunActivity signal and start workflows with, the worker
+// sets it.
+var lockClient cadence.Client
+
+func (l *LockSpec) validate() error {
+	if l.Name == "" {
+		return errors.New("lock must have a name")
+	}
+	if l.Permits < 1 {
+		return fmt.Errorf("lock %s must have at least 1 permit, got %d", l.Name, l.Permits)
+	}
+	if l.LeaseTimeout <= 0 {
+		return fmt.Errorf("lease timeout of lock %s must be positive, got %v", l.Name, l.LeaseTimeout)
+	}
+	return nil
+}
+
+// lockWorkflowID returns the workflow ID of the lock with the given name.
+func lockWorkflowID(name string) string {
+	return "cron_lock_" + name
+}
+
+// enqueue adds a lease request to the waiting requests, unless a lease with its ID is already waiting or held.
+func (s *LockState) enqueue(request LeaseRequest) bool {
+	for _, waiting := range s.Waiting {
+		if waiting.LeaseID == request.LeaseID {
+			return false
+		}
+	}
+	for _, holder := range s.Holders {
+		if holder.LeaseID == request.LeaseID {
+			return false
+		}
+	}
+	s.Waiting = append(s.Waiting, request)
+	return true
+}
+
+// release drops the lease with the given ID, whether it is held or still waiting. It returns false for an unknown
+// lease, e.g. one that expired.
+func (s *LockState) release(leaseID string) bool {
+	for i, holder := range s.Holders {
+		if holder.LeaseID == leaseID {
+			s.Holders = append(s.Holders[:i:i], s.Holders[i+1:]...)
+			return true
+		}
+	}
+	for i, waiting := range s.Waiting {
+		if waiting.LeaseID == leaseID {
+			s.Waiting = append(s.Waiting[:i:i], s.Waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// expire drops the leases that expired at the given time, and returns them.
+func (s *LockState) expire(now time.Time) []Lease {
+	var expired, holders []Lease
+	for _, holder := range s.Holders {
+		if holder.Expires.After(now) {
+			holders = append(holders, holder)
+		} else {
+			expired = append(expired, holder)
+		}
+	}
+	s.Holders = holders
+	return expired
+}
+
+// nextExpiry returns the time the next lease expires, it returns false if no lease is held.
+func (s *LockState) nextExpiry() (time.Time, bool) {
+	if len(s.Holders) == 0 {
+		return time.Time{}, false
+	}
+	next := s.Holders[0].Expires
+	for _, holder := range s.Holders[1:] {
+		if holder.Expires.Before(next) {
+			next = holder.Expires
+		}
+	}
+	return next, true
+}
+
+// next removes the first waiting request from the queue and returns it, if a permit is free for it.
+func (s *LockState) next() (LeaseRequest, bool) {
+	if len(s.Holders) >= s.Permits || len(s.Waiting) == 0 {
+		return LeaseRequest{}, false
+	}
+	request := s.Waiting[0]
+	s.Waiting = s.Waiting[1:]
+	return request, true
+}
+
+// CronLockWorkflow holds the permits of a lock and grants leases of them to the runs of cron workflows.
+func CronLockWorkflow(ctx cadence.Context, state LockState) error {
+	ctx = cadence.WithActivityOptions(ctx, cadence.ActivityOptions{
+		TaskList:               state.ActivityTaskList,
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	acquire := cadence.GetSignalChannel(ctx, acquireLeaseSignalName)
+	release := cadence.GetSignalChannel(ctx, releaseLeaseSignalName)
+	onAcquire := func(request LeaseRequest) {
+		if state.enqueue(request) {
+			workflowLogger(ctx).Info("Lease requested.", zap.String("LeaseID", request.LeaseID),
+				zap.Int("Waiting", len(state.Waiting)))
+		}
+	}
+	onRelease := func(leaseID string) {
+		if state.release(leaseID) {
+			workflowLogger(ctx).Info("Lease released.", zap.String("LeaseID", leaseID))
+		}
+	}
+
+	receivePending := func() int {
+		received := 0
+		var request LeaseRequest
+		for acquire.ReceiveAsync(&request) {
+			received++
+			onAcquire(request)
+		}
+		var leaseID string
+		for release.ReceiveAsync(&leaseID) {
+			received++
+			onRelease(leaseID)
+		}
+		return received
+	}
+
+	for loops := 0; loops < lockLoopsBeforeContinueAsNew; loops++ {
+		for {
+			request, ok := state.next()
+			if !ok {
+				break
+			}
+			// the grant is signalled before the lease is held, the time of the signal doesn't count against it.
+			if err := cadence.ExecuteActivity(ctx, grantLeaseActivity, request).Get(ctx, nil); err != nil {
+				workflowLogger(ctx).Warn("Lease grant failed, permit freed.", zap.String("LeaseID", request.LeaseID),
+					zap.Error(err))
+				continue
+			}
+			state.Holders = append(state.Holders, Lease{LeaseID: request.LeaseID, WorkflowID: request.WorkflowID,
+				Expires: cadence.Now(ctx).Add(request.Timeout)})
+			workflowLogger(ctx).Info("Lease granted.", zap.String("LeaseID", request.LeaseID),
+				zap.Int("Holders", len(state.Holders)))
+		}
+
+		// the signals buffered already are received without the selector, see cronSignals.selectSignals.
+		if receivePending() > 0 {
+			continue
+		}
+		selector := cadence.NewSelector(ctx)
+		timerCtx, cancelTimer := cadence.WithCancel(ctx)
+		if expires, ok := state.nextExpiry(); ok {
+			selector.AddFuture(cadence.NewTimer(timerCtx, timerDelay(ctx, expires)), func(f cadence.Future) {
+				for _, lease := range state.expire(cadence.Now(ctx)) {
+					workflowLogger(ctx).Warn("Lease expired.", zap.String("LeaseID", lease.LeaseID),
+						zap.String("WorkflowID", lease.WorkflowID))
+				}
+			})
+		}
+		selector.AddReceive(acquire, func(c cadence.Channel, more bool) {
+			var request LeaseRequest
+			c.Receive(ctx, &request)
+			onAcquire(request)
+		})
+		selector.AddReceive(release, func(c cadence.Channel, more bool) {
+			var leaseID string
+			c.Receive(ctx, &leaseID)
+			onRelease(leaseID)
+		})
+		selector.AddReceive(ctx.Done(), func(c cadence.Channel, more bool) {})
+		selector.Select(ctx)
+		cancelTimer()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	// the signals received with the last decision are carried over to the next run.
+	receivePending()
+	ctx = cadence.WithExecutionStartToCloseTimeout(ctx, lockWorkflowTimeout)
+	return cadence.NewContinueAsNewError(ctx, CronLockWorkflow, state)
+}
+
+// acquireLeaseActivity starts the workflow of the lock if it is not running yet, and requests a lease of it.
+func acquireLeaseActivity(ctx context.Context, lock LockSpec, request LeaseRequest) error {
+	options := cadence.StartWorkflowOptions{
+		ID:                              lockWorkflowID(lock.Name),
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    lockWorkflowTimeout,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	_, err := lockClient.StartWorkflow(options, CronLockWorkflow, LockState{Permits: lock.Permits,
+		ActivityTaskList: lock.ActivityTaskList})
+	if _, ok := err.(*shared.WorkflowExecutionAlreadyStartedError); err != nil && !ok {
+		return err
+	}
+	return lockClient.SignalWorkflow(options.ID, "", acquireLeaseSignalName, request)
+}
+
+// releaseLeaseActivity releases a lease of the lock.
+func releaseLeaseActivity(ctx context.Context, lock LockSpec, leaseID string) error {
+	return lockClient.SignalWorkflow(lockWorkflowID(lock.Name), "", releaseLeaseSignalName, leaseID)
+}
+
+// grantLeaseActivity signals a granted lease to the workflow that requested it.
+func grantLeaseActivity(ctx context.Context, request LeaseRequest) error {
+	return lockClient.SignalWorkflow(request.WorkflowID, "", leaseGrantedSignalName, request.LeaseID)
+}
+
+func newCronLeases(ctx cadence.Context, spec *ScheduleSpec, history *historyEstimate) *cronLeases {
+	leases := &cronLeases{granted: cadence.GetSignalChannel(ctx, leaseGrantedSignalName),
+		pending: make(map[string]cadence.Settable), history: history}
+	if spec.Lock != nil {
+		cadence.Go(ctx, leases.dispatch)
+	}
+	return leases
+}
+
+// dispatch hands the granted leases to the runs waiting for them, it runs until the workflow completes. A grant of a
+// lease nobody waits for is dropped, its run stopped waiting and released it already.
+func (l *cronLeases) dispatch(ctx cadence.Context) {
+	for {
+		var leaseID string
+		l.granted.Receive(ctx, &leaseID)
+		l.history.addSignal()
+		settable, ok := l.pending[leaseID]
+		if !ok {
+			workflowLogger(ctx).Info("Cron job lease grant dropped, nobody waits for it.",
+				zap.String("LeaseID", leaseID))
+			continue
+		}
+		delete(l.pending, leaseID)
+		settable.Set(nil, nil)
+	}
+}
+
+// acquire requests a lease of the lock and waits until it is granted, it returns the ID of the lease.
+func (l *cronLeases) acquire(ctx cadence.Context, lock LockSpec) (string, error) {
+	l.count++
+	execution := cadence.GetWorkflowInfo(ctx).WorkflowExecution
+	request := LeaseRequest{LeaseID: fmt.Sprintf("%s/%d", execution.RunID, l.count), WorkflowID: execution.ID,
+		Timeout: lock.LeaseTimeout}
+	granted, settable := cadence.NewFuture(ctx)
+	l.pending[request.LeaseID] = settable
+	if err := cadence.ExecuteActivity(ctx, acquireLeaseActivity, lock, request).Get(ctx, nil); err != nil {
+		delete(l.pending, request.LeaseID)
+		return "", err
+	}
+
+	workflowLogger(ctx).Info("Cron job run waiting for lock.", zap.String("Lock", lock.Name),
+		zap.String("LeaseID", request.LeaseID))
+	waitStart := cadence.Now(ctx)
+	selector := cadence.NewSelector(ctx)
+	selector.AddFuture(granted, func(f cadence.Future) {})
+	selector.AddReceive(ctx.Done(), func(c cadence.Channel, more bool) {})
+	selector.Select(ctx)
+	if ctx.Err() != nil {
+		delete(l.pending, request.LeaseID)
+		l.release(ctx, lock, request.LeaseID)
+		return "", ctx.Err()
+	}
+	workflowMetrics(ctx).Timer(metricLockWait).Record(cadence.Now(ctx).Sub(waitStart))
+	workflowLogger(ctx).Info("Cron job run acquired lock.", zap.String("Lock", lock.Name),
+		zap.String("LeaseID", request.LeaseID))
+	return request.LeaseID, nil
+}
+
+// release releases a lease of the lock, also when the workflow is cancelled. A lease that can't be released expires.
+func (l *cronLeases) release(ctx cadence.Context, lock LockSpec, leaseID string) {
+	releaseCtx := common.NewDisconnectedContext(ctx)
+	if err := cadence.ExecuteActivity(releaseCtx, releaseLeaseActivity, lock, leaseID).Get(releaseCtx, nil); err != nil {
+		workflowLogger(ctx).Error("Cron job lease release failed.", zap.String("LeaseID", leaseID), zap.Error(err))
+	}
+}
+
+// withLease executes a run with a lease of the lock of the spec, if it has one.
+func (l *cronLeases) withLease(ctx cadence.Context, spec ScheduleSpec, run func() error) error {
+	if spec.Lock == nil {
+		return run()
+	}
+	// the lock workflow started by the run schedules its activities where the schedule does.
+	lock := *spec.Lock
+	lock.ActivityTaskList = spec.ActivityTaskList
+	leaseID, err := l.acquire(ctx, lock)
+	if err != nil {
+		return err
+	}
+	defer l.release(ctx, lock, leaseID)
+	return run()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_LockState() {
+	start := time.Unix(0, 0)
+	state := &LockState{Permits: 1}
+	s.True(state.enqueue(LeaseRequest{LeaseID: "a/1"}))
+	s.True(state.enqueue(LeaseRequest{LeaseID: "b/1"}))
+	s.False(state.enqueue(LeaseRequest{LeaseID: "a/1"}))
+	request, ok := state.next()
+	s.True(ok)
+	s.Equal("a/1", request.LeaseID)
+	state.Holders = append(state.Holders, Lease{LeaseID: "a/1", Expires: start.Add(time.Minute)})
+	// the permit is held, and a held lease can't be requested again.
+	_, ok = state.next()
+	s.False(ok)
+	s.False(state.enqueue(LeaseRequest{LeaseID: "a/1"}))
+	expires, ok := state.nextExpiry()
+	s.True(ok)
+	s.Equal(start.Add(time.Minute), expires)
+
+	s.Empty(state.expire(start.Add(time.Second * 59)))
+	s.Equal([]Lease{{LeaseID: "a/1", Expires: start.Add(time.Minute)}}, state.expire(start.Add(time.Minute)))
+	_, ok = state.nextExpiry()
+	s.False(ok)
+	// a waiting request can be released before it is granted.
+	s.True(state.release("b/1"))
+	s.False(state.release("b/1"))
+	_, ok = state.next()
+	s.False(ok)
+}
+
+func (s *UnitTestSuite) Test_LockSpec_Validate() {
+	s.NoError((&LockSpec{Name: "backend", Permits: 2, LeaseTimeout: time.Minute}).validate())
+	s.Error((&LockSpec{Permits: 2, LeaseTimeout: time.Minute}).validate())
+	s.Error((&LockSpec{Name: "backend", LeaseTimeout: time.Minute}).validate())
+	s.Error((&LockSpec{Name: "backend", Permits: 2}).validate())
+}
+
+func (s *UnitTestSuite) Test_CronLockWorkflow_LeaseExpires() {
+	env := s.NewTestWorkflowEnvironment()
+	grants := make(map[string]time.Duration)
+	env.OverrideActivity(grantLeaseActivity, func(ctx context.Context, request LeaseRequest) error {
+		if request.WorkflowID == "cron_gone" {
+			return &shared.EntityNotExistsError{Message: "workflow not running"}
+		}
+		grants[request.WorkflowID] = env.Now().Sub(time.Unix(0, 0))
+		return nil
+	})
+	// cron_a never releases its lease, the permit is free again when the lease expires.
+	for i, id := range []string{"cron_a", "cron_gone", "cron_b"} {
+		request := LeaseRequest{LeaseID: id + "/1", WorkflowID: id, Timeout: time.Minute * 10}
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(acquireLeaseSignalName, request)
+		}, time.Second*time.Duration(i+1))
+	}
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Hour)
+	env.ExecuteWorkflow(CronLockWorkflow, LockState{Permits: 1})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.CanceledError)
+	s.True(ok)
+	// the grant to a workflow that is gone frees the permit right away.
+	s.Equal(map[string]time.Duration{"cron_a": time.Second, "cron_b": time.Minute*10 + time.Second}, grants)
+}
+
+// withLeases makes the lock activities of a cron workflow grant every lease after a minute, and returns the IDs of
+// the leases released.
+func withLeases(env *cadence.TestWorkflowEnvironment) *[]string {
+	var released []string
+	env.OverrideActivity(acquireLeaseActivity, func(ctx context.Context, lock LockSpec, request LeaseRequest) error {
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(leaseGrantedSignalName, request.LeaseID)
+		}, time.Minute)
+		return nil
+	})
+	env.OverrideActivity(releaseLeaseActivity, func(ctx context.Context, lock LockSpec, leaseID string) error {
+		released = append(released, leaseID)
+		return nil
+	})
+	return &released
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_Lock() {
+	env := s.NewTestWorkflowEnvironment()
+	released := withLeases(env)
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		Lock: &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Hour}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	// the job of a run starts once its lease is granted.
+	s.Equal([]time.Duration{time.Hour + time.Minute, time.Hour*2 + time.Minute}, runTimes)
+	s.Equal([]string{"default-test-run-id/1", "default-test-run-id/2"}, *released)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_LockReleasedOnFailure() {
+	env := s.NewTestWorkflowEnvironment()
+	released := withLeases(env)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{},
+		errors.New("dependency unavailable"))
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		Lock: &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Hour}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Equal([]string{"default-test-run-id/1"}, *released)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_LockReleasedOnCancel() {
+	env := s.NewTestWorkflowEnvironment()
+	var released []string
+	// the lease is never granted, the run waits for it until the workflow is cancelled.
+	env.OnActivity(acquireLeaseActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OverrideActivity(releaseLeaseActivity, func(ctx context.Context, lock LockSpec, leaseID string) error {
+		released = append(released, leaseID)
+		return nil
+	})
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Hour*2)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour,
+		Lock: &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Hour}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.CanceledError)
+	s.True(ok)
+	s.Equal([]string{"default-test-run-id/1"}, released)
+}
+
+func TestReplay_CronWorkflowsShareLock(t *testing.T) {
+	lock := newHistorySimulator(t, time.Unix(0, 0), CronLockWorkflow, LockState{Permits: 1})
+	lock.workflowID = lockWorkflowID("backend")
+	lock.activityDuration = time.Second
+	crons := make(map[string]*historySimulator)
+	type execution struct{ start, end time.Time }
+	var executions []execution
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute,
+		Lock: &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Hour}}
+	for _, id := range []string{"cron_a", "cron_b", "cron_c"} {
+		h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+		h.workflowID, h.runID = id, id+"-run"
+		h.activityDuration = time.Second * 10
+		h.onActivityCompleted = func(scheduled *s.HistoryEvent) {
+			attributes := scheduled.GetActivityTaskScheduledEventAttributes()
+			switch attributes.GetActivityType().GetName() {
+			case getFunctionName(acquireLeaseActivity):
+				var lockSpec LockSpec
+				var request LeaseRequest
+				require.NoError(t, cadence.EncodedValues(attributes.Input).Get(&lockSpec, &request))
+				lock.signalAt(h.now, acquireLeaseSignalName, request)
+			case getFunctionName(releaseLeaseActivity):
+				var lockSpec LockSpec
+				var leaseID string
+				require.NoError(t, cadence.EncodedValues(attributes.Input).Get(&lockSpec, &leaseID))
+				lock.signalAt(h.now, releaseLeaseSignalName, leaseID)
+			case getFunctionName(sampleCronActivity):
+				executions = append(executions, execution{time.Unix(0, scheduled.GetTimestamp()), h.now})
+			}
+		}
+		crons[id] = h
+	}
+	lock.onActivityCompleted = func(scheduled *s.HistoryEvent) {
+		var request LeaseRequest
+		require.NoError(t, cadence.EncodedValues(scheduled.GetActivityTaskScheduledEventAttributes().Input).Get(&request))
+		crons[request.WorkflowID].signalAt(lock.now, leaseGrantedSignalName, request.LeaseID)
+	}
+
+	runTogether(t, 3, crons["cron_a"], crons["cron_b"], crons["cron_c"], lock)
+	for id, h := range crons {
+		require.Equal(t, s.EventType_WorkflowExecutionCompleted, h.events[len(h.events)-1].GetEventType(), id)
+		require.NoError(t, h.replay(), id)
+	}
+	// the runs of all three workflows are due at the same time, but execute one after the other.
+	require.Len(t, executions, 6)
+	sort.Slice(executions, func(i, j int) bool { return executions[i].start.Before(executions[j].start) })
+	for i := 1; i < len(executions); i++ {
+		require.False(t, executions[i].start.Before(executions[i-1].end), "execution %d overlaps the one before", i)
+	}
+	require.NoError(t, lock.replay())
+}
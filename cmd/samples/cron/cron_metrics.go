@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The cron workflow and its activities emit metrics to the metrics scope of the worker, tagged with the ID of the cron
+ * workflow, which stays the same across continue-as-new. This version of the client has no cadence.GetMetricsScope,
+ * which would drop the metrics of workflow code while it is replayed. A worker replays the history of the run for every
+ * decision task, and every replay would count the runs again. The logger of the workflow is muted during replay though,
+ * so the workflow emits metrics only while its logger logs. A worker with EnableLoggingInReplay emits the metrics of
+ * replays too. Activities are not replayed.
+ */
+
+const (
+	// metricRunsScheduled counts the runs started by the workflow, manual runs included.
+	metricRunsScheduled = "cron.runs_scheduled"
+	// metricRunsSkipped counts the scheduled runs that didn't start, tagged with the reason.
+	metricRunsSkipped = "cron.runs_skipped"
+	// metricRunsFailed counts the runs that failed after their retries.
+	metricRunsFailed = "cron.runs_failed"
+	// metricWorkflowsDrained counts the workflows that completed because of the drain signal.
+	metricWorkflowsDrained = "cron.workflows_drained"
+	// metricLockWait times the wait of a run for the lease of the lock of the schedule.
+	metricLockWait = "cron.lock_wait"
+	// metricRunLatency times a run from its scheduled time to its completion.
+	metricRunLatency = "cron.run_latency"
+	// metricJobLatency times an attempt of the job body in sampleCronActivity.
+	metricJobLatency = "cron.job_latency"
+	// metricJobRetries counts the attempts of sampleCronActivity that are retries.
+	metricJobRetries = "cron.job_retries"
+	// metricConfigRejected counts the fetched configs that were rejected, tagged with the reason.
+	metricConfigRejected = "cron.config_rejected"
+
+	skipReasonOverlap    = "overlap"
+	skipReasonExclusions = "exclusions"
+	skipReasonSignal     = "signal"
+	skipReasonBudget     = "budget"
+)
+
+// scheduleNameKey is the key of the name of the schedule in the context of the workflow.
+type scheduleNameKey struct{}
+
+// metricsScope is the scope the cron workflow and its activities emit metrics to, the worker sets it to its own.
+var metricsScope = tally.NoopScope
+
+// withScheduleName returns a context that tags the metrics of the workflow with the name of its schedule.
+func withScheduleName(ctx cadence.Context, name string) cadence.Context {
+	return cadence.WithValue(ctx, scheduleNameKey{}, name)
+}
+
+// metricsTags returns the tags of the metrics of the workflow with the given ID, and the name of its schedule if it
+// has one.
+func metricsTags(workflowID, schedule string) map[string]string {
+	tags := map[string]string{"workflowID": workflowID}
+	if schedule != "" {
+		tags["schedule"] = schedule
+	}
+	return tags
+}
+
+// workflowMetrics returns the scope for the metrics of the workflow, which drops the metrics while the workflow is
+// replayed.
+func workflowMetrics(ctx cadence.Context) tally.Scope {
+	if cadence.GetLogger(ctx).Check(zap.ErrorLevel, "") == nil {
+		return tally.NoopScope
+	}
+	schedule, _ := ctx.Value(scheduleNameKey{}).(string)
+	return metricsScope.Tagged(metricsTags(cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID, schedule))
+}
+
+// activityMetrics returns the scope for the metrics of an activity of the schedule with the given name.
+func activityMetrics(ctx context.Context, schedule string) tally.Scope {
+	return metricsScope.Tagged(metricsTags(cadence.GetActivityInfo(ctx).WorkflowExecution.ID, schedule))
+}
+
+// countSkipped counts scheduled runs that didn't start for the given reason.
+func countSkipped(ctx cadence.Context, reason string, runs uint) {
+	workflowMetrics(ctx).Tagged(map[string]string{"reason": reason}).Counter(metricRunsSkipped).Inc(int64(runs))
+}
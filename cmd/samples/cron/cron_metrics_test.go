@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// withTestMetrics makes the workflow and activities emit their metrics to a test scope until the returned function is
+// called.
+func withTestMetrics() (tally.TestScope, func()) {
+	scope := tally.NewTestScope("", nil)
+	metricsScope = scope
+	return scope, func() { metricsScope = tally.NoopScope }
+}
+
+// counterValue returns the sum of the counters with the given name and tags.
+func counterValue(scope tally.TestScope, name string, tags map[string]string) int64 {
+	var value int64
+	for _, counter := range scope.Snapshot().Counters() {
+		if counter.Name() == name && hasTags(counter.Tags(), tags) {
+			value += counter.Value()
+		}
+	}
+	return value
+}
+
+// timerValues returns the values of the timers with the given name.
+func timerValues(scope tally.TestScope, name string) []time.Duration {
+	var values []time.Duration
+	for _, timer := range scope.Snapshot().Timers() {
+		if timer.Name() == name {
+			values = append(values, timer.Values()...)
+		}
+	}
+	return values
+}
+
+func hasTags(tags, expected map[string]string) bool {
+	for k, v := range expected {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_Metrics() {
+	scope, restore := withTestMetrics()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	// every run of sampleCronActivity succeeds on its third attempt.
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	workflow := map[string]string{"workflowID": "default-test-workflow-id"}
+	s.Equal(int64(3), counterValue(scope, metricRunsScheduled, workflow))
+	s.Equal(int64(0), counterValue(scope, metricRunsFailed, workflow))
+	s.Equal(int64(6), counterValue(scope, metricJobRetries, workflow))
+	s.Len(timerValues(scope, metricJobLatency), 9)
+	// every run takes the 1 and 2 seconds of backoff of its retries.
+	s.Equal([]time.Duration{time.Second * 3, time.Second * 3, time.Second * 3}, timerValues(scope, metricRunLatency))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_MetricsFailedAndSkipped() {
+	scope, restore := withTestMetrics()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		// the activity fails after 90 seconds, the tick in between is skipped.
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, nil, errors.New("dependency unavailable"))
+		}, time.Second*90)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		FailurePolicy: FailureContinue}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(int64(3), counterValue(scope, metricRunsScheduled, nil))
+	s.Equal(int64(3), counterValue(scope, metricRunsFailed, nil))
+	s.Equal(int64(2), counterValue(scope, metricRunsSkipped, map[string]string{"reason": skipReasonOverlap}))
+}
+
+func TestReplay_CronWorkflowMetrics(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	metricsScope = scope
+	defer func() { metricsScope = tally.NoopScope }()
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	// the metrics are emitted while the logger of the workflow logs, the default logger of the simulator never does.
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(ioutil.Discard), zap.InfoLevel))
+	h.handler = cadence.NewWorkflowTaskHandler("replay-domain", "replay-identity", logger)
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	// every decision task replayed the runs before it, they are counted once.
+	require.Equal(t, int64(3), scope.Snapshot().Counters()["cron.runs_scheduled+workflowID=replay-workflow"].Value())
+	require.NoError(t, h.replay())
+	require.Equal(t, int64(3), scope.Snapshot().Counters()["cron.runs_scheduled+workflowID=replay-workflow"].Value())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ScheduleNameTagsMetrics() {
+	scope, restore := withTestMetrics()
+	defer restore()
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{Name: "nightly-report", JobCount: 2,
+		ScheduleInterval: time.Minute, RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}},
+		&CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	schedule := map[string]string{"workflowID": "default-test-workflow-id", "schedule": "nightly-report"}
+	s.Equal(int64(2), counterValue(scope, metricRunsScheduled, schedule))
+	for _, timer := range scope.Snapshot().Timers() {
+		s.True(hasTags(timer.Tags(), schedule), timer.Name())
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule with Jobs runs several named jobs with independent intervals in one workflow, instead of one workflow per
+ * job. The workflow sleeps until the earliest next run time of all jobs, jobs that are due at the same time start in
+ * the order of the spec, so a job with a short interval can't starve the others. The runs execute asynchronously and
+ * the OverlapPolicy applies to every job on its own, a slow job doesn't delay the runs of the other jobs. The next run
+ * time and the counters of every job are part of the CronState, they are carried over continue-as-new.
+ *
+ * JobCount counts the runs of all jobs, and the FailurePolicy applies to the runs of all jobs. The pause and resume
+ * signals pause all jobs, on resume the interval of every job starts over. Manual triggers and the calendar and shard
+ * settings of the ScheduleSpec apply to a schedule without Jobs only. A job that woke up late runs once and continues
+ * with its next slot after the current time, the CatchUpPolicy doesn't apply to it.
+ *
+ * This version of the client has no queries, the state of the jobs can't be queried while the workflow is running. It
+ * is the input of the next run after continue-as-new.
+ */
+
+type (
+	// JobSpec is one of the jobs of a schedule with Jobs.
+	JobSpec struct {
+		// Name identifies the job in the CronState, it has to be unique within the schedule.
+		Name     string
+		Interval time.Duration
+		// ActivityName is the name of the job activity that runs the job, one of the jobActivities. Empty means
+		// sampleCronActivity.
+		ActivityName string
+		// Input is passed to the activity as CronJobInput.JobInput.
+		Input string
+		// TaskList is the task list the job activity of the job is scheduled on. Empty means the TaskList of the spec.
+		TaskList string
+	}
+
+	// JobState is what the runs of one job of a schedule with Jobs produced so far.
+	JobState struct {
+		// NextRunTime is the time the next run of the job is due.
+		NextRunTime time.Time
+		// LastRunTime is the time the last successful run of the job completed.
+		LastRunTime time.Time
+		// LastResult is the result of the last successful run of the job.
+		LastResult     CronJobResult
+		TotalRuns      uint
+		SuccessfulRuns uint
+		FailedRuns     uint
+		// BufferedRun is set while a run buffered by OverlapBufferOne waits for the previous run of the job to complete.
+		BufferedRun bool
+		// SkippedByOverlap and BufferedByOverlap count the runs of the job that were due while its previous run was
+		// still executing.
+		SkippedByOverlap  uint
+		BufferedByOverlap uint
+	}
+
+	// queuedJob is a job in the jobQueue.
+	queuedJob struct {
+		spec  *JobSpec
+		state *JobState
+		index int
+	}
+
+	// jobQueue is a heap of the jobs by their next run time, jobs due at the same time by their index in the spec.
+	jobQueue []*queuedJob
+)
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if !q[i].state.NextRunTime.Equal(q[j].state.NextRunTime) {
+		return q[i].state.NextRunTime.Before(q[j].state.NextRunTime)
+	}
+	return q[i].index < q[j].index
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*queuedJob)) }
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return job
+}
+
+// newJobQueue returns the queue of the jobs of the spec. A job without state is new, its first run is due an interval
+// from now.
+func newJobQueue(now time.Time, spec *ScheduleSpec, state *CronState) *jobQueue {
+	if state.Jobs == nil {
+		state.Jobs = make(map[string]*JobState, len(spec.Jobs))
+	}
+	q := make(jobQueue, 0, len(spec.Jobs))
+	for i := range spec.Jobs {
+		job := &spec.Jobs[i]
+		jobState, ok := state.Jobs[job.Name]
+		if !ok {
+			jobState = &JobState{NextRunTime: now.Add(job.Interval)}
+			state.Jobs[job.Name] = jobState
+		}
+		q = append(q, &queuedJob{spec: job, state: jobState, index: i})
+	}
+	heap.Init(&q)
+	return &q
+}
+
+// restart schedules the next run of every job an interval from now, e.g. when the schedule is resumed.
+func (q *jobQueue) restart(now time.Time) {
+	for _, job := range *q {
+		job.state.NextRunTime = now.Add(job.spec.Interval)
+	}
+	heap.Init(q)
+}
+
+// validateJobs checks the jobs of the spec. A schedule with Jobs doesn't support the settings that change how the
+// single job of a schedule without Jobs runs.
+func (s *ScheduleSpec) validateJobs() error {
+	if len(s.Jobs) == 0 {
+		return nil
+	}
+	if s.TimeOfDay != "" || s.AlignToInterval || s.Jitter > 0 || len(s.Exclusions.Weekdays) > 0 ||
+		len(s.Exclusions.Dates) > 0 || s.Parallelism > 1 || s.RunAsChildWorkflow || s.HostAffinity != nil ||
+		s.JobActivityName != "" || len(s.JobInput) > 0 {
+		return errors.New("a schedule with jobs doesn't support time of day, alignment, jitter, exclusions, shards, " +
+			"child workflows, host affinity or a job activity and input of the schedule")
+	}
+	names := make(map[string]bool, len(s.Jobs))
+	for _, job := range s.Jobs {
+		if job.Name == "" {
+			return errors.New("job name must not be empty")
+		}
+		if names[job.Name] {
+			return fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		names[job.Name] = true
+		if job.Interval < time.Second {
+			return fmt.Errorf("interval of job %q must be at least a second", job.Name)
+		}
+	}
+	return nil
+}
+
+// activity returns the name the job activity of the job is registered with.
+func (j *JobSpec) activity() string {
+	return jobActivityType(j.ActivityName)
+}
+
+// nextJobRun returns the next run time of a job after the run that was scheduled for the given time and is due now.
+// The runs missed because the workflow woke up late are not caught up.
+func nextJobRun(scheduledTime time.Time, interval time.Duration, now time.Time) time.Time {
+	next := scheduledTime.Add(interval)
+	if next.After(now) {
+		return next
+	}
+	return next.Add((now.Sub(next)/interval + 1) * interval)
+}
+
+// waitForNextJob is waitForNextRun of a schedule with Jobs, it blocks until a run of one of the jobs is due. A run
+// buffered by the OverlapPolicy starts as soon as the previous run of its job completed.
+func waitForNextJob(ctx cadence.Context, spec *ScheduleSpec, signals *cronSignals, jobs *cronJobs) (dueRun, bool) {
+	if jobs.queue == nil {
+		jobs.queue = newJobQueue(cadence.Now(ctx), spec, jobs.state)
+	}
+	for {
+		if spec.Paused {
+			if !waitForResume(ctx, spec, signals, jobs) {
+				return dueRun{}, false
+			}
+			jobs.queue.restart(cadence.Now(ctx))
+		}
+		if spec.Draining || jobs.err != nil || ctx.Err() != nil || spec.isPastDeadline(cadence.Now(ctx)) {
+			return dueRun{}, false
+		}
+		if spec.PendingTrigger != nil {
+			workflowLogger(ctx).Info("Cron job manual trigger ignored, the schedule has jobs.")
+			spec.PendingTrigger = nil
+		}
+		if job := jobs.bufferedJob(); job != nil {
+			jobs.state.Jobs[job.Name].BufferedRun = false
+			workflowLogger(ctx).Info("Cron job starting buffered run.", zap.String("Job", job.Name))
+			return dueRun{job: job, scheduledTime: cadence.Now(ctx)}, true
+		}
+
+		next := (*jobs.queue)[0]
+		if runTime := next.state.NextRunTime; runTime.After(cadence.Now(ctx)) {
+			afterDeadline := spec.isPastDeadline(runTime)
+			if afterDeadline {
+				runTime = spec.NotAfter
+			}
+			timerCtx, cancelTimer := cadence.WithCancel(ctx)
+			timerFired := false
+			selector := cadence.NewSelector(ctx)
+			jobs.addFutures(ctx, selector)
+			jobs.history.addTimer()
+			selector.AddFuture(cadence.NewTimer(timerCtx, timerDelay(ctx, runTime)), func(f cadence.Future) {
+				timerFired = true
+			})
+			signals.addReceives(ctx, selector, spec)
+			for !timerFired && !spec.Paused && !spec.Draining && jobs.err == nil && jobs.bufferedJob() == nil {
+				signals.selectSignals(ctx, selector, spec)
+			}
+			cancelTimer()
+			if ctx.Err() != nil {
+				// the timer fires when the workflow is cancelled.
+				return dueRun{}, false
+			}
+			if !timerFired {
+				continue
+			}
+			if afterDeadline {
+				return dueRun{}, false
+			}
+			// a pause or drain could arrive in the same decision as the timer.
+			signals.receivePending(ctx, spec)
+			if spec.Paused || spec.Draining {
+				continue
+			}
+		}
+
+		scheduledTime := next.state.NextRunTime
+		next.state.NextRunTime = nextJobRun(scheduledTime, next.spec.Interval, cadence.Now(ctx))
+		heap.Fix(jobs.queue, 0)
+		if !jobs.canStartJob(next.spec.Name, spec.OverlapPolicy) {
+			onJobOverlap(ctx, spec, jobs.state, next, scheduledTime)
+			continue
+		}
+		return dueRun{job: next.spec, scheduledTime: scheduledTime}, true
+	}
+}
+
+// startJob executes the due run of a job asynchronously.
+func (j *cronJobs) startJob(ctx cadence.Context, due dueRun) {
+	future, settable := cadence.NewFuture(ctx)
+	job, scheduledTime := due.job, due.scheduledTime
+	runSpec := *j.spec
+	state := j.state.Jobs[job.Name]
+	j.state.TotalRuns++
+	state.TotalRuns++
+	// the runs of all the jobs are numbered in one sequence.
+	input := CronJobInput{PendingJobCount: runSpec.JobCount, ScheduledTime: scheduledTime, Job: job.Name,
+		JobInput: job.Input, LastResult: state.LastResult, Schedule: runSpec.Name, TraceID: runSpec.TraceID,
+		Run: newRunContext(ctx, runSpec, due, j.state.TotalRuns)}
+	j.runningJobs[job.Name]++
+	if runSpec.recordsCorrelationIDs() {
+		j.history.addMarker()
+	}
+	j.history.addActivity()
+	if runSpec.recordsResults() {
+		j.history.addActivity()
+	}
+	if runSpec.forwardsRuns() {
+		j.history.addActivity()
+	}
+	j.addLeaseEvents(runSpec)
+	name, activity, taskList := job.Name, job.activity(), runSpec.jobTaskList(job)
+	startTime := cadence.Now(ctx)
+	j.state.onRunStarted(startTime)
+	workflowMetrics(ctx).Counter(metricRunsScheduled).Inc(1)
+	cadence.Go(ctx, func(ctx cadence.Context) {
+		runCtx := j.runContext(ctx, future)
+		var result CronJobResult
+		var executionTime time.Duration
+		err := j.leases.withLease(ctx, runSpec, func() (err error) {
+			defer measureExecution(ctx, &executionTime)()
+			result, err = executeWithRetry(runSpec.withJobTaskList(runCtx, taskList), runSpec.RetryPolicy, activity, input,
+				j.history, nil, nil)
+			return runSpec.classifyNoWorker(ctx, taskList, err)
+		})
+		if err != nil {
+			workflowLogger(ctx).Error("Cron job failed.", zap.String("Job", name), zap.Error(err))
+		}
+		run := runResult{job: name, scheduledTime: scheduledTime, startTime: startTime,
+			results: []CronJobResult{result}, err: err, correlationID: input.Run.CorrelationID,
+			executionTime: executionTime}
+		j.checkSkipped(ctx, future, &run)
+		recordRun(ctx, runSpec, scheduledTime, run)
+		settable.SetValue(run)
+	})
+	j.running = append(j.running, future)
+}
+
+// canStartJob returns true if a run of the given job can start now under the given policy.
+func (j *cronJobs) canStartJob(name string, policy OverlapPolicy) bool {
+	return j.runningJobs[name] == 0 || policy == OverlapAllowAll
+}
+
+// bufferedJob returns the first job of the spec with a buffered run that can start now, or nil.
+func (j *cronJobs) bufferedJob() *JobSpec {
+	for i := range j.spec.Jobs {
+		job := &j.spec.Jobs[i]
+		if j.state.Jobs[job.Name].BufferedRun && j.canStartJob(job.Name, j.spec.OverlapPolicy) {
+			return job
+		}
+	}
+	return nil
+}
+
+// onRunCompleted records the outcome of a run of the job.
+func (s *JobState) onRunCompleted(ctx cadence.Context, run runResult) {
+	if run.err == nil {
+		s.SuccessfulRuns++
+		s.LastRunTime = cadence.Now(ctx)
+		s.LastResult = run.results[0]
+		return
+	}
+	if _, ok := run.err.(cadence.CanceledError); ok && ctx.Err() != nil || run.skipped {
+		return
+	}
+	s.FailedRuns++
+}
+
+// onJobOverlap applies the overlap policy to the run of a job scheduled for the given time, which is due while the
+// previous run of the job is still executing.
+func onJobOverlap(ctx cadence.Context, spec *ScheduleSpec, state *CronState, job *queuedJob, scheduledTime time.Time) {
+	name := job.spec.Name
+	if spec.OverlapPolicy == OverlapBufferOne && !job.state.BufferedRun {
+		job.state.BufferedRun = true
+		job.state.BufferedByOverlap++
+		workflowLogger(ctx).Info("Cron job run buffered, previous run still executing.", zap.String("Job", name),
+			zap.Uint("TotalBuffered", job.state.BufferedByOverlap))
+		return
+	}
+	job.state.SkippedByOverlap++
+	state.addRecentRun(RunRecord{Job: name, ScheduledAt: scheduledTime, Status: RunSkippedByOverlap})
+	countSkipped(ctx, skipReasonOverlap, 1)
+	workflowLogger(ctx).Info("Cron job run skipped, previous run still executing.", zap.String("Job", name),
+		zap.Stringer("OverlapPolicy", spec.OverlapPolicy), zap.Uint("TotalSkipped", job.state.SkippedByOverlap))
+}
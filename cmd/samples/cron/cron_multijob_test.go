@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// jobRun is a run of a job of a schedule with Jobs, by the seconds since the start of the workflow.
+type jobRun struct {
+	job string
+	at  time.Duration
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_Jobs() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs []jobRun
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, jobRun{input.Job, env.Now().Sub(time.Unix(0, 0)) / time.Second})
+		return CronJobResult{ProcessedBatches: input.LastResult.ProcessedBatches + 1}, nil
+	})
+	spec := ScheduleSpec{JobCount: 12, Jobs: []JobSpec{{Name: "minutely", Interval: time.Minute},
+		{Name: "every3", Interval: time.Minute * 3}}}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	// the activities of the jobs due at the same time can execute in any order.
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].at < runs[j].at || (runs[i].at == runs[j].at && runs[i].job > runs[j].job)
+	})
+	s.Equal([]jobRun{{"minutely", 60}, {"minutely", 120}, {"minutely", 180}, {"every3", 180}, {"minutely", 240},
+		{"minutely", 300}, {"minutely", 360}, {"every3", 360}, {"minutely", 420}, {"minutely", 480}}, runs)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	spec, state := args[0].(ScheduleSpec), args[1].(*CronState)
+	s.Equal(uint(2), spec.JobCount)
+	s.Equal(uint(10), state.TotalRuns)
+	s.Equal(uint(8), state.Jobs["minutely"].TotalRuns)
+	s.Equal(uint(8), state.Jobs["minutely"].LastResult.ProcessedBatches)
+	s.Equal(uint(2), state.Jobs["every3"].TotalRuns)
+	s.Equal(uint(2), state.Jobs["every3"].SuccessfulRuns)
+
+	// the next run continues with the next run times and counters of the jobs.
+	env = s.NewTestWorkflowEnvironment()
+	runs = nil
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, jobRun{input.Job, env.Now().Sub(time.Unix(0, 0)) / time.Second})
+		return CronJobResult{ProcessedBatches: input.LastResult.ProcessedBatches + 1}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, state)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(runs, 2)
+	s.Contains(runs, jobRun{"minutely", 540})
+	s.Contains(runs, jobRun{"every3", 540})
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_JobsOverlap() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs []jobRun
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, jobRun{input.Job, env.Now().Sub(time.Unix(0, 0)) / time.Second})
+		if input.Job != "slow" {
+			return CronJobResult{}, nil
+		}
+		// the slow job runs for 150 seconds, longer than its interval.
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{}, nil)
+		}, time.Second*150)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	spec := ScheduleSpec{JobCount: 20, Jobs: []JobSpec{{Name: "slow", Interval: time.Minute},
+		{Name: "fast", Interval: time.Minute * 2}}}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	// the slow job skips its runs while its previous run is executing, the fast job runs on time.
+	s.Equal([]jobRun{{"slow", 60}, {"fast", 120}, {"slow", 240}, {"fast", 240}, {"fast", 360}, {"slow", 420},
+		{"fast", 480}, {"slow", 600}, {"fast", 600}, {"fast", 720}}, runs)
+	state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+	s.Equal(uint(8), state.Jobs["slow"].SkippedByOverlap)
+	s.Equal(uint(0), state.Jobs["fast"].SkippedByOverlap)
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_ValidateJobs() {
+	jobs := []JobSpec{{Name: "minutely", Interval: time.Minute}}
+	s.NoError((&ScheduleSpec{Jobs: jobs}).validateJobs())
+	s.NoError((&ScheduleSpec{Jobs: jobs, Parallelism: 1}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: []JobSpec{{Interval: time.Minute}}}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: []JobSpec{{Name: "minutely"}}}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: []JobSpec{{Name: "fast", Interval: time.Millisecond}}}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: jobs, TimeOfDay: "02:00"}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: jobs, Parallelism: 2}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: jobs, RunAsChildWorkflow: true}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: jobs, HostAffinity: &HostAffinitySpec{}}).validateJobs())
+	s.Error((&ScheduleSpec{Jobs: jobs, JobActivityName: "report"}).validateJobs())
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_JobsWorkflowTimeout() {
+	// the workflow timeout covers the runs of the job with the longest interval until continue-as-new.
+	spec := ScheduleSpec{JobCount: 5, Jobs: []JobSpec{{Name: "a", Interval: time.Hour}}}
+	s.NoError(spec.Validate(time.Time{}))
+	s.Equal(time.Hour*(loopCountBeforeContinueAsNew+1), spec.Timeouts.Workflow)
+	spec = ScheduleSpec{JobCount: 5, Jobs: []JobSpec{{Name: "a", Interval: time.Minute},
+		{Name: "b", Interval: time.Hour * 2}}}
+	s.NoError(spec.Validate(time.Time{}))
+	s.Equal(time.Hour*2*(loopCountBeforeContinueAsNew+1), spec.Timeouts.Workflow)
+}
+
+func (s *UnitTestSuite) Test_NextJobRun() {
+	start := time.Unix(0, 0)
+	s.Equal(start.Add(time.Minute), nextJobRun(start, time.Minute, start))
+	s.Equal(start.Add(time.Minute), nextJobRun(start, time.Minute, start.Add(time.Second*59)))
+	// the runs missed by a late wake up are skipped.
+	s.Equal(start.Add(time.Minute*4), nextJobRun(start, time.Minute, start.Add(time.Minute*3)))
+	s.Equal(start.Add(time.Minute*4), nextJobRun(start, time.Minute, start.Add(time.Second*230)))
+}
+
+func TestReplay_CronWorkflowJobs(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 6, Jobs: []JobSpec{{Name: "minutely", Interval: time.Minute},
+		{Name: "every3", Interval: time.Minute * 3}}}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	h.activityDuration = time.Second * 10
+	// the intervals of both jobs start over on resume.
+	h.signalAfter(time.Second*130, pauseSignalName, "maintenance")
+	h.signalAfter(time.Second*250, resumeSignalName, "done")
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	var runs []string
+	for i, input := range h.activityInputs() {
+		runs = append(runs, fmt.Sprintf("%s@%v", input.Job, h.activityScheduleTimes()[i].Sub(h.start)))
+	}
+	// jobs due at the same time start in the order of the spec.
+	require.Equal(t, []string{"minutely@1m0s", "minutely@2m0s", "minutely@5m10s", "minutely@6m10s",
+		"minutely@7m10s", "every3@7m10s"}, runs)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * With --output json a verb prints its result as a single JSON object on one line to stdout, and its error as one to
+ * stderr, so that automation doesn't have to scrape the text. The text of the verbs stays the default. The trigger mode
+ * takes -output json too. The history and the export verbs write JSON anyway, their --output is the file they write.
+ *
+ * The exit code is the same in both modes: 0 for success, 2 for a usage or a validation error, 3 for a workflow that
+ * isn't found, 4 for a workflow that is already running, 5 for an error of the server or of the transport to it, and 1
+ * for other errors, e.g. a workflow that is not a cron workflow.
+ *
+ * This version of the client takes no context, a call to an unreachable frontend is retried for a minute before it
+ * fails. --timeout bounds the verb instead, it fails with an error of the transport once the timeout passed. The call
+ * is not cancelled, the process exits with it.
+ */
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+
+	defaultCommandTimeout = 30 * time.Second
+)
+
+type (
+	// triggerResult is printed by the trigger mode, AlreadyRunning is set for a running schedule that got the new
+	// interval and job count instead.
+	triggerResult struct {
+		WorkflowID     string       `json:"workflowID"`
+		RunID          string       `json:"runID"`
+		AlreadyRunning bool         `json:"alreadyRunning"`
+		TraceID        string       `json:"traceID,omitempty"`
+		Summary        *CronSummary `json:"summary,omitempty"`
+	}
+
+	// signalResult is printed by the signal verb.
+	signalResult struct {
+		WorkflowID string `json:"workflowID"`
+		RunID      string `json:"runID"`
+		Signal     string `json:"signal"`
+	}
+
+	// closeResult is printed by the cancel and the terminate verbs.
+	closeResult struct {
+		WorkflowID string `json:"workflowID"`
+		RunID      string `json:"runID"`
+		Reason     string `json:"reason,omitempty"`
+	}
+
+	// queryResult is printed by the query verb, the result is what the text of the query prints.
+	queryResult struct {
+		WorkflowID string      `json:"workflowID"`
+		RunID      string      `json:"runID"`
+		Type       string      `json:"type"`
+		Result     interface{} `json:"result"`
+	}
+
+	// listResult is printed by the list verb.
+	listResult struct {
+		Executions []listedExecution `json:"executions"`
+	}
+
+	// applyResult is printed by the apply verb, the actions are the ones applied, or planned by a dry run.
+	applyResult struct {
+		Actions   []plannedAction `json:"actions"`
+		Conflicts int             `json:"conflicts"`
+		DryRun    bool            `json:"dryRun"`
+	}
+
+	// plannedAction is an action of an applyResult.
+	plannedAction struct {
+		Action     string   `json:"action"`
+		Schedule   string   `json:"schedule"`
+		WorkflowID string   `json:"workflowID"`
+		Changes    []string `json:"changes,omitempty"`
+	}
+
+	// errorResult is printed to stderr for the error of a verb, RunID is the run of a schedule that is already
+	// running.
+	errorResult struct {
+		Error      string `json:"error"`
+		ExitCode   int    `json:"exitCode"`
+		WorkflowID string `json:"workflowID,omitempty"`
+		RunID      string `json:"runID,omitempty"`
+	}
+
+	// commandTimeoutError is returned by a verb that didn't complete within its timeout.
+	commandTimeoutError struct {
+		Timeout time.Duration
+	}
+)
+
+func (e commandTimeoutError) Error() string {
+	return fmt.Sprintf("no response from the frontend within %v", e.Timeout)
+}
+
+// withTimeout returns the error of f, or commandTimeoutError if f doesn't return within the timeout. A timeout of 0
+// waits for f.
+func withTimeout(timeout time.Duration, f func() error) error {
+	if timeout <= 0 {
+		return f()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return commandTimeoutError{Timeout: timeout}
+	}
+}
+
+// printResult prints the result of a verb as a JSON object with --output json, the text of a verb that prints nothing
+// else is empty.
+func printResult(out io.Writer, command cliCommand, result interface{}) error {
+	if !command.JSON {
+		return nil
+	}
+	return printJSONLine(out, result)
+}
+
+// printJSONLine prints the value as JSON on one line.
+func printJSONLine(out io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+// exitCode returns the exit code of the error of a command. The cause of a wrapped error decides, e.g. of an action of
+// an apply.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		switch cause.(type) {
+		case cliUsageError, *shared.BadRequestError:
+			return exitUsage
+		case *shared.EntityNotExistsError:
+			return exitNotFound
+		case errScheduleRunning, *shared.WorkflowExecutionAlreadyStartedError, *shared.DomainAlreadyExistsError:
+			return exitAlreadyExists
+		case commandTimeoutError, *shared.InternalServiceError, *shared.ServiceBusyError, tchannel.SystemError,
+			net.Error:
+			// net.Error covers the deadline of a context too.
+			return exitServer
+		}
+	}
+	return exitFailed
+}
+
+// reportError prints the error of the command to out, as text or with --output json as a JSON object, and returns the
+// exit code.
+func reportError(out io.Writer, command cliCommand, err error) int {
+	code, message := commandExitCode(command, err)
+	if code == 0 {
+		return 0
+	}
+	if !command.JSON {
+		fmt.Fprintln(out, message)
+		return code
+	}
+	result := errorResult{Error: message, ExitCode: code, WorkflowID: command.WorkflowID}
+	if running, ok := err.(errScheduleRunning); ok {
+		result.RunID = running.RunID
+	}
+	printJSONLine(out, result)
+	return code
+}
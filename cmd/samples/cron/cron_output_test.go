@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+	"go.uber.org/cadence/mocks"
+)
+
+// blockingClient is a recordingClient whose signals wait until release is closed.
+type blockingClient struct {
+	recordingClient
+	release chan struct{}
+}
+
+func (c *blockingClient) SignalWorkflow(workflowID string, runID string, signalName string, arg interface{}) error {
+	<-c.release
+	return nil
+}
+
+func Test_RunCommand_JSONOutput(t *testing.T) {
+	client := &recordingClient{}
+	for _, c := range []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"signal", "--workflow-id", "cron_1", "--name", "pause", "--output", "json"},
+			`{"workflowID":"cron_1","runID":"","signal":"pause"}`},
+		{[]string{"cancel", "--workflow-id", "cron_1", "--run-id", "run-1", "--output", "json"},
+			`{"workflowID":"cron_1","runID":"run-1"}`},
+		{[]string{"terminate", "--workflow-id", "cron_1", "--reason", "stuck", "--output", "json"},
+			`{"workflowID":"cron_1","runID":"","reason":"stuck"}`},
+	} {
+		out, err := runArgs(t, client, c.args...)
+		require.NoError(t, err)
+		require.Equal(t, c.out+"\n", out, "%v", c.args)
+	}
+	// the text of the verbs that only call the frontend is empty.
+	out, err := runArgs(t, client, "cancel", "--workflow-id", "cron_1")
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	// the result of a query is the JSON of its text.
+	spec := ScheduleSpec{Name: "nightly", JobCount: 4, ScheduleInterval: time.Hour}
+	client = &recordingClient{input: encodeValues(t, spec, &CronState{
+		RecentRuns: []RunRecord{{Job: "reports", Status: RunSucceeded}}})}
+	out, err = runArgs(t, client, "query", "--workflow-id", "cron_nightly", "--type", "recentRuns", "--output", "json")
+	require.NoError(t, err)
+	var query struct {
+		WorkflowID string      `json:"workflowID"`
+		Type       string      `json:"type"`
+		Result     []RunRecord `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &query))
+	require.Equal(t, "cron_nightly", query.WorkflowID)
+	require.Equal(t, "recentRuns", query.Type)
+	require.Equal(t, []RunRecord{{Job: "reports", Status: RunSucceeded}}, query.Result)
+	require.Equal(t, 1, bytes.Count([]byte(out), []byte("\n")))
+
+	started := historyEvent(1, shared.EventType_WorkflowExecutionStarted, 0)
+	started.WorkflowExecutionStartedEventAttributes = &shared.WorkflowExecutionStartedEventAttributes{
+		WorkflowType: &shared.WorkflowType{Name: common.StringPtr(cronWorkflowType)},
+		TaskList:     &shared.TaskList{Name: common.StringPtr(ApplicationName)}}
+	service := &mocks.TChanWorkflowService{}
+	service.On("GetWorkflowExecutionHistory", mock.Anything, mock.Anything).Return(
+		&shared.GetWorkflowExecutionHistoryResponse{History: &shared.History{Events: []*shared.HistoryEvent{started}}},
+		nil).Once()
+	out, err = runService(t, service, "describe", "--workflow-id", "cron_nightly", "--output", "json")
+	require.NoError(t, err)
+	require.Equal(t, `{"WorkflowID":"cron_nightly","WorkflowType":"`+cronWorkflowType+`","TaskList":"`+ApplicationName+`",`+
+		`"Status":"OPEN","StartTime":"2023-06-01T08:00:00Z","ExecutionStartToCloseTimeoutSeconds":0,`+
+		`"HistoryLength":1,"PendingActivities":[]}`+"\n", out)
+
+	cluster := &clusterClient{t: t, workflows: map[string]ScheduleSpec{"cron_nightly": spec}}
+	out = applyArgs(t, cluster, "list", "--output", "json")
+	require.Equal(t, `{"executions":[{"WorkflowID":"cron_nightly","RunID":"run-cron_nightly","Status":"OPEN",`+
+		`"StartTime":"1970-01-01T00:00:00Z","HistoryLength":0}]}`+"\n", out)
+
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"hourly": {"JobCount": 5, "TimeOfDay": "02:00"}}`), 0644))
+	out = applyArgs(t, cluster, "apply", "--file", path, "--dry-run", "--output", "json")
+	require.Equal(t, `{"actions":[{"action":"start","schedule":"hourly","workflowID":"cron_hourly"},`+
+		`{"action":"drain","schedule":"nightly","workflowID":"cron_nightly"}],"conflicts":0,"dryRun":true}`+"\n", out)
+	require.Empty(t, cluster.calls)
+}
+
+func Test_TriggerSchedule(t *testing.T) {
+	spec := ScheduleSpec{Name: "nightly", JobCount: 4, ScheduleInterval: time.Hour, TraceID: "trace-1"}
+	options := spec.startOptions(scheduleWorkflowID(spec.Name))
+	client := &clusterClient{t: t}
+	result, err := triggerSchedule(client, options, false, false, spec)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, printJSONLine(&out, result))
+	require.Equal(t, `{"workflowID":"cron_nightly","runID":"run-cron_nightly","alreadyRunning":false,`+
+		`"traceID":"trace-1"}`+"\n", out.String())
+
+	// the running schedule gets the new schedule with -upsert.
+	running := &shared.WorkflowExecutionAlreadyStartedError{RunId: common.StringPtr("run-1")}
+	client = &clusterClient{t: t, startErrs: []error{running}}
+	result, err = triggerSchedule(client, options, false, true, spec)
+	require.NoError(t, err)
+	require.Equal(t, triggerResult{WorkflowID: "cron_nightly", RunID: "run-1", AlreadyRunning: true,
+		TraceID: "trace-1"}, result)
+
+	// and is an error of a schedule that already exists without it.
+	client = &clusterClient{t: t, startErrs: []error{running}}
+	_, err = triggerSchedule(client, options, false, false, spec)
+	var stderr bytes.Buffer
+	command := cliCommand{Verb: "trigger", WorkflowID: "cron_nightly", JSON: true}
+	require.Equal(t, exitAlreadyExists, reportError(&stderr, command, err))
+	require.Equal(t, `{"error":"trigger of workflow cron_nightly failed: schedule workflow cron_nightly is already `+
+		`running with run ID run-1","exitCode":4,"workflowID":"cron_nightly","runID":"run-1"}`+"\n", stderr.String())
+}
+
+func Test_ExitCode(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		code int
+	}{
+		{nil, 0},
+		{cliUsageError{errors.New("--workflow-id is required")}, exitUsage},
+		{&shared.BadRequestError{Message: "invalid page size"}, exitUsage},
+		{&shared.EntityNotExistsError{Message: "workflow not found"}, exitNotFound},
+		{errScheduleRunning{WorkflowID: "cron_1", RunID: "run-1"}, exitAlreadyExists},
+		{&shared.WorkflowExecutionAlreadyStartedError{}, exitAlreadyExists},
+		{&shared.DomainAlreadyExistsError{}, exitAlreadyExists},
+		{&shared.InternalServiceError{Message: "persistence failure"}, exitServer},
+		{&shared.ServiceBusyError{Message: "rate limited"}, exitServer},
+		{tchannel.ErrTimeout, exitServer},
+		{context.DeadlineExceeded, exitServer},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitServer},
+		{commandTimeoutError{Timeout: time.Second}, exitServer},
+		{errors.New("workflow cron_1 is not a cron workflow"), exitFailed},
+		// the cause of an action of an apply.
+		{fmt.Errorf("drain of schedule nightly failed: %w", &shared.EntityNotExistsError{}), exitNotFound},
+	} {
+		require.Equal(t, c.code, exitCode(c.err), "%v", c.err)
+	}
+}
+
+func Test_RunAndReport(t *testing.T) {
+	// a frontend that doesn't answer fails the verb after its timeout.
+	client := &blockingClient{release: make(chan struct{})}
+	defer close(client.release)
+	command, err := parseCommand([]string{"signal", "--workflow-id", "cron_1", "--name", "pause", "--timeout",
+		"10ms", "--output", "json"}, ioutil.Discard)
+	require.NoError(t, err)
+	var out, stderr bytes.Buffer
+	require.Equal(t, exitServer, runAndReport(client, command, &out, &stderr))
+	require.Empty(t, out.String())
+	require.Equal(t, `{"error":"signal of workflow cron_1 failed: no response from the frontend within 10ms",`+
+		`"exitCode":5,"workflowID":"cron_1"}`+"\n", stderr.String())
+
+	// the error is text without --output json.
+	command.JSON = false
+	stderr.Reset()
+	require.Equal(t, exitServer, runAndReport(client, command, &out, &stderr))
+	require.Equal(t, "signal of workflow cron_1 failed: no response from the frontend within 10ms\n", stderr.String())
+
+	recording := &recordingClient{err: &shared.EntityNotExistsError{Message: "workflow execution already completed"}}
+	command, err = parseCommand([]string{"cancel", "--workflow-id", "cron_1", "--output", "json"}, ioutil.Discard)
+	require.NoError(t, err)
+	stderr.Reset()
+	require.Equal(t, exitNotFound, runAndReport(recording, command, &out, &stderr))
+	require.Equal(t, `{"error":"workflow cron_1 not found or already completed: workflow execution already `+
+		`completed","exitCode":3,"workflowID":"cron_1"}`+"\n", stderr.String())
+
+	// an invalid argument after --output json is a JSON error too.
+	command, err = parseCommand([]string{"list", "--output", "json", "--page-size", "0"}, ioutil.Discard)
+	stderr.Reset()
+	require.Equal(t, exitUsage, reportError(&stderr, command, err))
+	require.Equal(t, `{"error":"--page-size must be positive, got 0","exitCode":2}`+"\n", stderr.String())
+	_, err = parseCommand([]string{"list", "--output", "yaml"}, ioutil.Discard)
+	require.IsType(t, cliUsageError{}, err)
+	require.EqualError(t, err, `unknown output "yaml", the outputs are text and json`)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * Job runs are executed asynchronously, so that a run taking longer than the schedule interval doesn't push back the
+ * whole schedule. The OverlapPolicy of the schedule decides what happens to a run that is due while the previous run is
+ * still executing. Manual runs requested with the triggerNow signal follow the policy as well, except that they are
+ * never dropped, a manual run waits for the previous run to complete unless the policy allows concurrent runs.
+ */
+
+type (
+	// OverlapPolicy decides what happens to a scheduled run that is due while the previous run is still executing.
+	OverlapPolicy int
+
+	// cronJobs tracks the job runs in progress.
+	cronJobs struct {
+		spec    *ScheduleSpec
+		state   *CronState
+		history *historyEstimate
+		leases  *cronLeases
+		running []cadence.Future
+		// cancelRuns cancel the contexts of the runs in progress, and skipReasons are the reasons of the runs skipped
+		// with the skipRun signal.
+		cancelRuns  map[cadence.Future]cadence.CancelFunc
+		skipReasons map[cadence.Future]string
+		// runningJobs counts the runs in progress of every job of a schedule with Jobs, and queue orders the jobs by
+		// their next run.
+		runningJobs map[string]int
+		queue       *jobQueue
+		// err is the error of the first failed run that ended the schedule according to the FailurePolicy.
+		err error
+		// deliveringDeadLetters is set while a run delivers the buffered dead letters.
+		deliveringDeadLetters bool
+	}
+
+	// runResult is the outcome of a run, the results of its shards and the error if any shard failed.
+	runResult struct {
+		// job is the name of the job of a schedule with Jobs, its run has a single result.
+		job           string
+		scheduledTime time.Time
+		startTime     time.Time
+		results       []CronJobResult
+		err           error
+		// skipped is true for a run cancelled by the skipRun signal, with the reason of the signal.
+		skipped    bool
+		skipReason string
+		// item is the input a run of a schedule with JobInputs took, nil without JobInputs.
+		item *jobItem
+		// correlationID is the CorrelationID of the RunContext of the run.
+		correlationID string
+		// executionTime is the time the job of the run executed, without the wait for its lease.
+		executionTime time.Duration
+	}
+)
+
+const (
+	// OverlapSkip drops the scheduled runs that are due while the previous run is still executing.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapBufferOne remembers one scheduled run that was due while the previous run was executing, and starts it as
+	// soon as the previous run completes. Further runs due in the meantime are dropped.
+	OverlapBufferOne
+	// OverlapAllowAll starts every scheduled run when it is due, runs can execute concurrently.
+	OverlapAllowAll
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "Skip"
+	case OverlapBufferOne:
+		return "BufferOne"
+	case OverlapAllowAll:
+		return "AllowAll"
+	}
+	return "Unknown"
+}
+
+func newCronJobs(spec *ScheduleSpec, state *CronState, history *historyEstimate, leases *cronLeases) *cronJobs {
+	return &cronJobs{spec: spec, state: state, history: history, leases: leases, runningJobs: make(map[string]int),
+		cancelRuns: make(map[cadence.Future]cadence.CancelFunc), skipReasons: make(map[cadence.Future]string)}
+}
+
+// start executes the given due run asynchronously.
+func (j *cronJobs) start(ctx cadence.Context, due dueRun) {
+	run, settable := cadence.NewFuture(ctx)
+	scheduledTime := due.scheduledTime
+	// the run uses the spec as of its start, later changes of the spec don't affect it.
+	runSpec := *j.spec
+	item := j.spec.takeJobInput(ctx, &runSpec)
+	lastResults := j.state.LastResults
+	j.state.TotalRuns++
+	runContext := newRunContext(ctx, runSpec, due, j.state.TotalRuns)
+	startTime := runContext.DispatchTime
+	j.state.onRunStarted(startTime)
+	workflowMetrics(ctx).Counter(metricRunsScheduled).Inc(1)
+	// the first attempts are counted right away, so that the next check for continue-as-new includes this run.
+	if runSpec.recordsCorrelationIDs() {
+		j.history.addMarker()
+	}
+	if runSpec.RunAsChildWorkflow {
+		j.history.addChildWorkflow()
+	} else {
+		for shard := uint(0); shard < runSpec.shards(); shard++ {
+			j.history.addActivity()
+		}
+		if runSpec.HostAffinity != nil {
+			j.history.addActivity()
+		}
+	}
+	if runSpec.recordsResults() {
+		j.history.addActivity()
+	}
+	if runSpec.forwardsRuns() {
+		j.history.addActivity()
+	}
+	j.addLeaseEvents(runSpec)
+	cadence.Go(ctx, func(ctx cadence.Context) {
+		runCtx := j.runContext(ctx, run)
+		results := lastResults
+		var executionTime time.Duration
+		err := j.leases.withLease(ctx, runSpec, func() (err error) {
+			defer measureExecution(ctx, &executionTime)()
+			if runSpec.RunAsChildWorkflow {
+				results, err = runChild(runCtx, runSpec, lastResults, runContext)
+			} else {
+				results, err = runShards(runCtx, runSpec, lastResults, runContext, j.history)
+			}
+			return err
+		})
+		result := runResult{scheduledTime: scheduledTime, startTime: startTime, results: results, err: err, item: item,
+			correlationID: runContext.CorrelationID, executionTime: executionTime}
+		j.checkSkipped(ctx, run, &result)
+		recordRun(ctx, runSpec, scheduledTime, result)
+		j.deadLetter(ctx, runSpec, runContext, result)
+		settable.SetValue(result)
+	})
+	j.running = append(j.running, run)
+}
+
+// newRunContext returns the RunContext of the due run of the spec, the given sequence is its number among the runs of
+// the schedule. The TotalRuns of the CronState the sequence is taken from is carried over continue-as-new.
+func newRunContext(ctx cadence.Context, spec ScheduleSpec, due dueRun, sequence uint) RunContext {
+	return RunContext{
+		FireTime:     due.scheduledTime,
+		DispatchTime: cadence.Now(ctx),
+		Sequence:     sequence,
+		Schedule:     spec.Name,
+		WorkflowID:   cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID,
+		Backfill:     due.backfill,
+		Manual:       due.trigger != nil,
+		// the sequence is set before, the ID of a run with the deterministic scheme is derived from it.
+		CorrelationID: spec.correlationID(ctx, sequence),
+	}
+}
+
+// runContext returns the context of a run, it is cancelled when the run is skipped with the skipRun signal. The context
+// is derived from the context of the coroutine of the run, a context derived outside of it would block the coroutine
+// that created it instead. The lease of the run is acquired and released with the context of the coroutine, a skipped
+// run still releases its lease.
+func (j *cronJobs) runContext(ctx cadence.Context, run cadence.Future) cadence.Context {
+	runCtx, cancel := cadence.WithCancel(ctx)
+	j.cancelRuns[run] = cancel
+	if _, skipped := j.skipReasons[run]; skipped {
+		// the run was skipped before its coroutine started.
+		cancel()
+	}
+	return runCtx
+}
+
+// skipRuns cancels the runs in progress without cancelling the workflow, the schedule goes on with the next run. The
+// activities of the runs are cancelled with their context, and stop once they learn about it with their next
+// heartbeat. The workflow doesn't wait for them, the details they are cancelled with are recorded in its history.
+func (j *cronJobs) skipRuns(ctx cadence.Context, reason string) {
+	if len(j.running) == 0 {
+		workflowLogger(ctx).Info("Cron job skip ignored, no run in progress.", zap.String("Reason", reason))
+		return
+	}
+	for _, f := range j.running {
+		if _, ok := j.skipReasons[f]; !ok {
+			j.skipReasons[f] = reason
+			if cancel, ok := j.cancelRuns[f]; ok {
+				cancel()
+			}
+		}
+	}
+	workflowLogger(ctx).Info("Cron job runs skipped.", zap.Int("Runs", len(j.running)), zap.String("Reason", reason))
+}
+
+// checkSkipped marks the result of a run that was cancelled by the skipRun signal. A run that completed before the
+// signal arrived, or that was cancelled with the workflow, wasn't skipped.
+func (j *cronJobs) checkSkipped(ctx cadence.Context, run cadence.Future, result *runResult) {
+	reason, ok := j.skipReasons[run]
+	if _, cancelled := result.err.(cadence.CanceledError); ok && cancelled && ctx.Err() == nil {
+		result.skipped = true
+		result.skipReason = reason
+	}
+}
+
+// addLeaseEvents counts the events of the lease of a run, its request, grant and release.
+func (j *cronJobs) addLeaseEvents(spec ScheduleSpec) {
+	if spec.Lock != nil {
+		j.history.addActivity()
+		j.history.addSignal()
+		j.history.addActivity()
+	}
+}
+
+// canStart returns true if a run can start now under the given policy.
+func (j *cronJobs) canStart(policy OverlapPolicy) bool {
+	return len(j.running) == 0 || policy == OverlapAllowAll
+}
+
+// addFutures adds the runs in progress to the selector, the handlers record the completion of the runs.
+func (j *cronJobs) addFutures(ctx cadence.Context, selector cadence.Selector) {
+	for _, f := range j.running {
+		selector.AddFuture(f, func(f cadence.Future) {
+			j.complete(ctx, f)
+		})
+	}
+}
+
+func (j *cronJobs) complete(ctx cadence.Context, f cadence.Future) {
+	for i := range j.running {
+		if j.running[i] == f {
+			j.running = append(j.running[:i], j.running[i+1:]...)
+			break
+		}
+	}
+	delete(j.cancelRuns, f)
+	delete(j.skipReasons, f)
+	var run runResult
+	f.Get(ctx, &run)
+	if ctx.Err() == nil {
+		j.state.addRecentRun(run.record(cadence.Now(ctx)))
+		metrics := workflowMetrics(ctx)
+		metrics.Timer(metricRunLatency).Record(cadence.Now(ctx).Sub(run.scheduledTime))
+		if run.skipped {
+			countSkipped(ctx, skipReasonSignal, 1)
+		} else if run.err != nil {
+			metrics.Counter(metricRunsFailed).Inc(1)
+		}
+	}
+	j.state.addBudgetUsage(j.spec, cadence.Now(ctx), run.executionTime)
+	if run.job == "" {
+		j.state.LastResults = run.results
+	} else {
+		j.runningJobs[run.job]--
+		j.state.Jobs[run.job].onRunCompleted(ctx, run)
+	}
+	if err := onRunCompleted(ctx, j.spec, j.state, run); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// wait blocks until all runs in progress completed, and returns the error that ended the schedule, if any.
+func (j *cronJobs) wait(ctx cadence.Context) error {
+	for len(j.running) > 0 {
+		j.complete(ctx, j.running[0])
+	}
+	return j.err
+}
+
+// onOverlap applies the overlap policy to the run scheduled for the given time, which is due while the previous run is
+// still executing.
+func onOverlap(ctx cadence.Context, spec *ScheduleSpec, state *CronState, scheduledTime time.Time) {
+	if spec.OverlapPolicy == OverlapBufferOne && !spec.BufferedRun {
+		spec.BufferedRun = true
+		spec.BufferedByOverlap++
+		workflowLogger(ctx).Info("Cron job run buffered, previous run still executing.",
+			zap.Uint("TotalBuffered", spec.BufferedByOverlap))
+		return
+	}
+	spec.SkippedByOverlap++
+	state.addRecentRun(RunRecord{ScheduledAt: scheduledTime, Status: RunSkippedByOverlap})
+	countSkipped(ctx, skipReasonOverlap, 1)
+	workflowLogger(ctx).Info("Cron job run skipped, previous run still executing.",
+		zap.Stringer("OverlapPolicy", spec.OverlapPolicy), zap.Uint("TotalSkipped", spec.SkippedByOverlap))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_OverlapPolicy() {
+	testCases := []struct {
+		policy   OverlapPolicy
+		runTimes []time.Duration
+		skipped  uint
+		buffered uint
+	}{
+		// every other tick happens while the previous run is still executing.
+		{OverlapSkip, []time.Duration{60, 180, 300, 420, 540, 660, 780, 900, 1020, 1140}, 9, 0},
+		// the buffered run starts right when the previous one completes, and the schedule continues from there.
+		{OverlapBufferOne, []time.Duration{60, 150, 240, 330, 420, 510, 600, 690, 780, 870}, 0, 9},
+		{OverlapAllowAll, []time.Duration{60, 120, 180, 240, 300, 360, 420, 480, 540, 600}, 0, 0},
+	}
+	for _, tc := range testCases {
+		env := s.NewTestWorkflowEnvironment()
+		var runTimes []time.Duration
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0))/time.Second)
+			// the activity runs for 90 seconds, longer than the interval.
+			taskToken := cadence.GetActivityInfo(ctx).TaskToken
+			env.RegisterDelayedCallback(func() {
+				env.CompleteActivity(taskToken, CronJobResult{}, nil)
+			}, time.Second*90)
+			return CronJobResult{}, cadence.ErrActivityResultPending
+		})
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+			OverlapPolicy: tc.policy}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.policy.String())
+		s.Equal(tc.runTimes, runTimes, tc.policy.String())
+		spec := continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+		s.Equal(uint(2), spec.JobCount, tc.policy.String())
+		s.Equal(tc.skipped, spec.SkippedByOverlap, tc.policy.String())
+		s.Equal(tc.buffered, spec.BufferedByOverlap, tc.policy.String())
+		s.False(spec.BufferedRun, tc.policy.String())
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ConcurrentRunFails() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		var err error
+		if runs == 1 {
+			err = errors.New("job failed")
+		}
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{}, err)
+		}, time.Second*90)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+		OverlapPolicy: OverlapAllowAll}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), "job failed")
+	// the failure is noticed while the second run is executing.
+	s.Equal(2, runs)
+}
+
+func TestReplay_CronWorkflowOverlapPolicy(t *testing.T) {
+	testCases := []struct {
+		policy   OverlapPolicy
+		expected []time.Duration
+	}{
+		// the manual run at 100s waits for the first run to complete at 150s, the schedule continues from there.
+		{OverlapSkip, []time.Duration{60, 150, 270, 390, 510}},
+		// the tick at 120s is buffered behind the manual run.
+		{OverlapBufferOne, []time.Duration{60, 150, 240, 330, 420}},
+		{OverlapAllowAll, []time.Duration{60, 100, 160, 220, 280}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 4, ScheduleInterval: time.Minute, OverlapPolicy: tc.policy}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+			h.activityDuration = time.Second * 90
+			// a trigger while a run is executing must wait for it, unless runs are allowed to overlap.
+			h.signalAfter(time.Second*100, triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			var runs []time.Duration
+			for _, run := range h.activityScheduleTimes() {
+				runs = append(runs, run.Sub(time.Unix(0, 0))/time.Second)
+			}
+			require.Equal(t, tc.expected, runs)
+		})
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_RunContext() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs []RunContext
+	onRun := func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs = append(runs, input.Run)
+		return CronJobResult{}, nil
+	}
+	env.OverrideActivity(sampleCronActivity, onRun)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{})
+	}, time.Minute*20)
+	missed := time.Unix(0, 0).Add(-time.Hour)
+	spec := ScheduleSpec{Name: "hourly", JobCount: 13, ScheduleInterval: time.Hour, CatchUpPolicy: CatchUpBackfill,
+		Backlog: []time.Time{missed}}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Len(runs, loopCountBeforeContinueAsNew)
+	workflowID := runs[0].WorkflowID
+	s.NotEmpty(workflowID)
+	s.Equal(RunContext{FireTime: missed, DispatchTime: time.Unix(0, 0), Sequence: 1, Schedule: "hourly",
+		WorkflowID: workflowID, Backfill: true}, runs[0])
+	// the manual run is scheduled for the time it was requested at.
+	manual := time.Unix(0, 0).Add(time.Minute * 20)
+	s.Equal(RunContext{FireTime: manual, DispatchTime: manual, Sequence: 2, Schedule: "hourly",
+		WorkflowID: workflowID, Manual: true}, runs[1])
+	s.Equal(RunContext{FireTime: manual.Add(time.Hour), DispatchTime: manual.Add(time.Hour), Sequence: 3,
+		Schedule: "hourly", WorkflowID: workflowID}, runs[2])
+
+	// the next generation goes on with the sequence.
+	args := continueAsNewArgs(env.GetWorkflowError())
+	runs = nil
+	env = s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, onRun)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(runs, 3)
+	for i, run := range runs {
+		s.Equal(uint(loopCountBeforeContinueAsNew+i+1), run.Sequence)
+		s.False(run.Backfill || run.Manual)
+	}
+}
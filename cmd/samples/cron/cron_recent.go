@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/**
+ * The workflow keeps records of its recent runs in the CronState, so that the outcome of the last runs can be looked up
+ * without going through the histories of all runs of the workflow. The records are carried over continue-as-new. The
+ * buffer is bounded so that it doesn't grow the input of the workflow, the oldest record is dropped first. Scheduled
+ * runs that didn't start because of the OverlapPolicy, the Exclusions or the DailyBudget are recorded with their own status. Runs
+ * cancelled with the workflow are not recorded, the state of a cancelled workflow is not carried anywhere.
+ *
+ * This version of the client has no queries. The runner reads the records from the input of the current run of the
+ * workflow instead, so they are as of its last continue-as-new.
+ */
+
+// maxRecentRuns is the number of records of recent runs in the CronState.
+const maxRecentRuns = 20
+
+type (
+	// RunStatus is the outcome of a scheduled run.
+	RunStatus int
+
+	// RunRecord is the record of a recent run.
+	RunRecord struct {
+		// Job is the name of the job of a schedule with Jobs, empty otherwise.
+		Job         string
+		ScheduledAt time.Time
+		// StartedAt and CompletedAt are zero for a run that didn't start.
+		StartedAt   time.Time
+		CompletedAt time.Time
+		Status      RunStatus
+		// Error is the error of a failed run.
+		Error string
+		// ResultSummary describes the results of the shards of the run, the runs skipped by Exclusions, or the reason
+		// of the skipRun signal.
+		ResultSummary string
+		// Payloads are the JSON payloads of the results of the shards, nil if the job activity returns none.
+		Payloads []json.RawMessage
+		// CorrelationID is the correlation ID the job activities of the run got, see cron_correlation.go.
+		CorrelationID string
+	}
+)
+
+const (
+	// RunSucceeded is a run whose shards all succeeded.
+	RunSucceeded RunStatus = iota
+	// RunFailed is a run with a failed shard, after the retries of the RetryPolicy.
+	RunFailed
+	// RunSkippedByOverlap is a scheduled run that was dropped because the previous run was still executing.
+	RunSkippedByOverlap
+	// RunSkippedByExclusions are the scheduled runs that fell on excluded days before the run at ScheduledAt.
+	RunSkippedByExclusions
+	// RunSkippedBySignal is a run that was cancelled by the skipRun signal.
+	RunSkippedBySignal
+	// RunSkippedByBudget is a run that was due after the runs of the day used up the DailyBudget.
+	RunSkippedByBudget
+)
+
+func (s RunStatus) String() string {
+	switch s {
+	case RunSucceeded:
+		return "Succeeded"
+	case RunFailed:
+		return "Failed"
+	case RunSkippedByOverlap:
+		return "SkippedByOverlap"
+	case RunSkippedByExclusions:
+		return "SkippedByExclusions"
+	case RunSkippedBySignal:
+		return "SkippedBySignal"
+	case RunSkippedByBudget:
+		return "SkippedByBudget"
+	}
+	return "Unknown"
+}
+
+// addRecentRun adds the record of a run, and drops the oldest record if the buffer is full.
+func (s *CronState) addRecentRun(record RunRecord) {
+	s.RecentRuns = append(s.RecentRuns, record)
+	if len(s.RecentRuns) > maxRecentRuns {
+		s.RecentRuns = append([]RunRecord(nil), s.RecentRuns[len(s.RecentRuns)-maxRecentRuns:]...)
+	}
+}
+
+// record returns the record of a run that completed at the given time. The payloads of the results summarize the run
+// if the job activity returns them.
+func (r runResult) record(completedAt time.Time) RunRecord {
+	batches := make([]string, len(r.results))
+	var payloads []json.RawMessage
+	var summaries []string
+	for i, result := range r.results {
+		batches[i] = fmt.Sprint(result.ProcessedBatches)
+		if len(result.Payload) > 0 {
+			payloads = append(payloads, result.Payload)
+			summaries = append(summaries, string(result.Payload))
+		}
+	}
+	record := RunRecord{Job: r.job, ScheduledAt: r.scheduledTime, StartedAt: r.startTime, CompletedAt: completedAt,
+		Status: RunSucceeded, ResultSummary: "processed batches " + strings.Join(batches, ","), Payloads: payloads,
+		CorrelationID: r.correlationID}
+	if len(payloads) > 0 {
+		record.ResultSummary = strings.Join(summaries, ",")
+	}
+	if r.skipped {
+		record.Status = RunSkippedBySignal
+		record.ResultSummary = "skipped: " + r.skipReason
+	} else if r.err != nil {
+		record.Status = RunFailed
+		record.Error = r.err.Error()
+	}
+	return record
+}
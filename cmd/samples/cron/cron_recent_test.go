@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+func (s *UnitTestSuite) Test_CronState_RecentRuns() {
+	state := &CronState{}
+	for i := 0; i < maxRecentRuns+5; i++ {
+		state.addRecentRun(RunRecord{ScheduledAt: time.Unix(int64(i), 0)})
+	}
+
+	// the oldest records are dropped first.
+	s.Len(state.RecentRuns, maxRecentRuns)
+	s.Equal(time.Unix(5, 0), state.RecentRuns[0].ScheduledAt)
+	s.Equal(time.Unix(maxRecentRuns+4, 0), state.RecentRuns[maxRecentRuns-1].ScheduledAt)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_RecentRuns() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		var err error
+		if runs == 2 {
+			err = errors.New("dependency unavailable")
+		}
+		// the activity runs for 90 seconds, the tick in between is skipped.
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		result := CronJobResult{ProcessedBatches: input.LastResult.ProcessedBatches + 1}
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, result, err)
+		}, time.Second*90)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+		FailurePolicy: FailureContinue}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+	// the 10 runs and the 9 ticks skipped in between are carried over continue-as-new.
+	s.Len(state.RecentRuns, 19)
+	at := func(seconds int64) time.Time { return time.Unix(seconds, 0) }
+	s.Equal(RunRecord{ScheduledAt: at(120), Status: RunSkippedByOverlap}, state.RecentRuns[0])
+	s.Equal(RunRecord{ScheduledAt: at(60), StartedAt: at(60), CompletedAt: at(150), Status: RunSucceeded,
+		ResultSummary: "processed batches 1"}, state.RecentRuns[1])
+	failed := state.RecentRuns[3]
+	s.Equal(RunFailed, failed.Status)
+	s.Equal(at(180), failed.ScheduledAt)
+	s.Equal(at(270), failed.CompletedAt)
+	s.Contains(failed.Error, "dependency unavailable")
+	// the failed run keeps the last result of the shard.
+	s.Equal("processed batches 1", failed.ResultSummary)
+	s.Equal(at(1140), state.RecentRuns[18].ScheduledAt)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+// CronRunRecord is the input of the recordCronResultActivity execution.
+type CronRunRecord struct {
+	// Job is the name of the job of a schedule with Jobs, empty otherwise.
+	Job           string
+	ScheduledTime time.Time
+	// Results are the results of the shards, the result of a failed shard is the zero value.
+	Results []CronJobResult
+	// Error is the error of the run, empty if all shards succeeded.
+	Error string
+}
+
+// recordCronResultActivity records the result of a run, e.g. for a dashboard of the schedule.
+func recordCronResultActivity(ctx context.Context, record CronRunRecord) error {
+	// ...
+	activityLogger(ctx).Info("Cron job run recorded.", zap.String("Job", record.Job),
+		zap.Time("ScheduledTime", record.ScheduledTime),
+		zap.Int("Shards", len(record.Results)), zap.String("Error", record.Error))
+	return nil
+}
+
+// recordsResults returns true if the runs record their results, runs started before the change don't.
+func (s *ScheduleSpec) recordsResults() bool {
+	return s.getVersion(changeAddResultRecording, DefaultVersion, 1) >= 1
+}
+
+// recordRun records the result of a run if the spec of the run does, and forwards it to the aggregator of the spec. A
+// run that was cancelled with the workflow is not recorded, and a failed recording doesn't fail the run.
+func recordRun(ctx cadence.Context, spec ScheduleSpec, scheduledTime time.Time, run runResult) {
+	if ctx.Err() != nil {
+		return
+	}
+	if spec.recordsResults() {
+		record := CronRunRecord{Job: run.job, ScheduledTime: scheduledTime, Results: run.results}
+		if run.err != nil {
+			record.Error = run.err.Error()
+		}
+		if err := cadence.ExecuteActivity(ctx, recordCronResultActivity, record).Get(ctx, nil); err != nil {
+			workflowLogger(ctx).Error("Cron job run recording failed.", zap.Error(err))
+		}
+	}
+	forwardRun(ctx, spec, run.record(cadence.Now(ctx)))
+}
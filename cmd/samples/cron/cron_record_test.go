@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// recordedRuns collects the records of recordCronResultActivity.
+func recordedRuns(env *cadence.TestWorkflowEnvironment) *[]CronRunRecord {
+	var records []CronRunRecord
+	env.OverrideActivity(recordCronResultActivity, func(ctx context.Context, record CronRunRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return &records
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ResultRecording() {
+	env := s.NewTestWorkflowEnvironment()
+	failingRuns(env, 2)
+	records := recordedRuns(env)
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute, FailurePolicy: FailureContinue}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Len(*records, 2)
+	s.Equal([]CronJobResult{{}}, (*records)[0].Results)
+	s.Empty((*records)[0].Error)
+	s.Contains((*records)[1].Error, "job failed")
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_NoResultRecordingBeforeChange() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	records := recordedRuns(env)
+	// a spec without versions is the input of a run started before the change.
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Empty(*records)
+}
+
+func TestReplay_CronWorkflowBeforeResultRecording(t *testing.T) {
+	// the history was recorded with JobCount 3 before runs recorded their results.
+	h := loadHistory(t, "testdata/cron_history_before_result_recording.json", SampleCronWorkflow)
+	require.NoError(t, h.replay())
+	require.Len(t, h.activityTypes(), 3)
+
+	// without the version guard the workflow would record the result of the first run where the history has the
+	// timer of the next one.
+	started := h.events[0].WorkflowExecutionStartedEventAttributes
+	var spec ScheduleSpec
+	var state CronState
+	decoder := gob.NewDecoder(bytes.NewReader(started.Input))
+	require.NoError(t, decoder.Decode(&spec))
+	require.NoError(t, decoder.Decode(&state))
+	spec.ChangeVersions = map[string]Version{changeAddResultRecording: latestVersions[changeAddResultRecording]}
+	started.Input = encodeValues(t, spec, &state)
+	// the client panics on a decision that doesn't match the history.
+	require.Panics(t, func() { h.replay() })
+}
+
+func TestReplay_CronWorkflowResultRecording(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute}
+	spec.withLatestVersions()
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	h.activityDuration = time.Second * 10
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	job, record := getFunctionName(sampleCronActivity), getFunctionName(recordCronResultActivity)
+	// the schedule reports its summary when it completes.
+	require.Equal(t, []string{job, record, job, record, getFunctionName(reportSummaryActivity)}, h.activityTypes())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * Retries of the cron job. The RetryPolicy has the shape of the activity retry policy of the Cadence server, but this
+ * version of the client can't pass one with the ActivityOptions, so the workflow executes the retries itself like
+ * RetryPolicyWorkflow of recipes/retryactivity does. Every attempt is a new activity execution that receives its
+ * attempt number, and the backoff between attempts is a durable timer.
+ */
+
+type (
+	// RetryPolicy defines how a failed shard of a run is retried.
+	RetryPolicy struct {
+		// InitialInterval is the backoff before the first retry.
+		InitialInterval time.Duration
+		// BackoffCoefficient multiplies the backoff after every retry. Zero means 2.
+		BackoffCoefficient float64
+		// MaximumInterval caps the backoff. Zero means no cap.
+		MaximumInterval time.Duration
+		// MaximumAttempts is the maximum number of attempts including the first one. Zero means no limit, then
+		// ExpirationInterval has to be set.
+		MaximumAttempts uint
+		// ExpirationInterval is the time after the first attempt after which no retry is started. Zero means no limit.
+		ExpirationInterval time.Duration
+		// NonRetriableErrorReasons are the reasons of errors that fail the shard right away, retrying won't fix them.
+		NonRetriableErrorReasons []string
+	}
+)
+
+func (p *RetryPolicy) validate() error {
+	if p.InitialInterval <= 0 {
+		return errors.New("retry policy initial interval must be positive")
+	}
+	if p.BackoffCoefficient != 0 && p.BackoffCoefficient < 1 {
+		return errors.New("retry policy backoff coefficient must not be less than 1")
+	}
+	if p.MaximumAttempts == 0 && p.ExpirationInterval <= 0 {
+		return errors.New("retry policy needs maximum attempts or an expiration interval")
+	}
+	return nil
+}
+
+// backoff returns the time to wait after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt uint) time.Duration {
+	coefficient := p.BackoffCoefficient
+	if coefficient == 0 {
+		coefficient = 2
+	}
+	backoff := time.Duration(float64(p.InitialInterval) * math.Pow(coefficient, float64(attempt)))
+	if p.MaximumInterval > 0 && backoff > p.MaximumInterval {
+		backoff = p.MaximumInterval
+	}
+	return backoff
+}
+
+// retryBackoff returns the backoff before the retry of the given failed attempt, and false if the error is not
+// retriable, the attempts are exhausted or the retry would start after the expiration interval.
+func (p *RetryPolicy) retryBackoff(ctx cadence.Context, attempt uint, firstAttempt time.Time, err error) (time.Duration,
+	bool) {
+	if !p.isRetriable(err) || (p.MaximumAttempts > 0 && attempt+1 >= p.MaximumAttempts) {
+		return 0, false
+	}
+	backoff := p.backoff(attempt)
+	if p.ExpirationInterval > 0 && cadence.Now(ctx).Add(backoff).Sub(firstAttempt) > p.ExpirationInterval {
+		return 0, false
+	}
+	return backoff, true
+}
+
+// isRetriable returns false for a cancelled shard and for the errors with the non retriable reasons, which are the
+// custom failures of the cron job.
+func (p *RetryPolicy) isRetriable(err error) bool {
+	return common.ClassifyError(err, p.NonRetriableErrorReasons...).Retryable()
+}
+
+// executeWithRetry executes one shard of a run with the given activity, and retries it according to the policy. A nil
+// policy means a single attempt. The first attempt is part of the history estimate of the run already, the retries are
+// added to it. The attempts execute on the host of the route, a nil route executes them on any worker. The failed
+// attempts are added to the given failure, if it is not nil.
+func executeWithRetry(ctx cadence.Context, policy *RetryPolicy, activity interface{}, input CronJobInput,
+	history *historyEstimate, route *hostRoute, failure *ShardFailure) (CronJobResult, error) {
+	firstAttempt := cadence.Now(ctx)
+	for {
+		var result CronJobResult
+		err := route.execute(ctx, activity, input, &result)
+		if err != nil && failure != nil {
+			failure.add(err)
+		}
+		if err == nil || policy == nil {
+			return result, err
+		}
+		backoff, ok := policy.retryBackoff(ctx, input.Attempt, firstAttempt, err)
+		if !ok {
+			return result, err
+		}
+
+		workflowLogger(ctx).Info("Cron job shard failed, retrying.", zap.Uint("Shard", input.Shard),
+			zap.Uint("Attempt", input.Attempt), zap.Duration("Backoff", backoff), zap.Error(err))
+		history.addTimer()
+		history.addActivity()
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return result, err
+		}
+		input.Attempt++
+		if progress, ok := progressOf(err); ok && progress > input.Progress {
+			input.Progress = progress
+		}
+	}
+}
+
+// progressOf returns the progress a failed attempt of sampleCronActivity reported, either with the error it failed
+// with or with its last heartbeat before it timed out. This version of the client can't pass the heartbeat details to
+// the next attempt of an activity, the workflow does it instead. The details of activity errors are wrapped once more
+// in this version of the client, they are decoded in two steps.
+func progressOf(err error) (uint, bool) {
+	var details []byte
+	switch err := err.(type) {
+	case cadence.TimeoutError:
+		if err.TimeoutType() != shared.TimeoutType_HEARTBEAT {
+			return 0, false
+		}
+		err.Details(&details)
+	case cadence.ErrorWithDetails:
+		if err.Reason() != errReasonDependencyUnavailable {
+			return 0, false
+		}
+		err.Details(&details)
+	default:
+		return 0, false
+	}
+	var progress uint
+	if err := cadence.EncodedValues(details).Get(&progress); err != nil {
+		return 0, false
+	}
+	return progress, true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_Retry() {
+	testCases := []struct {
+		name        string
+		policy      *RetryPolicy
+		expected    []time.Duration
+		expectedErr string
+	}{
+		// sampleCronActivity fails the first two attempts.
+		{"transient failure", &RetryPolicy{InitialInterval: time.Second * 10, MaximumAttempts: 5},
+			[]time.Duration{60, 70, 90}, ""},
+		{"maximum attempts", &RetryPolicy{InitialInterval: time.Second * 10, MaximumAttempts: 2},
+			[]time.Duration{60, 70}, errReasonDependencyUnavailable},
+		// the second backoff would start the third attempt 30s after the first one.
+		{"expiration", &RetryPolicy{InitialInterval: time.Second * 10, ExpirationInterval: time.Second * 15},
+			[]time.Duration{60, 70}, errReasonDependencyUnavailable},
+		{"no retry policy", nil, []time.Duration{60}, errReasonDependencyUnavailable},
+	}
+	for _, tc := range testCases {
+		env := s.NewTestWorkflowEnvironment()
+		var attempts []time.Duration
+		env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context, args cadence.EncodedValues) {
+			var input CronJobInput
+			s.NoError(args.Get(&input))
+			s.Equal(uint(len(attempts)), input.Attempt, tc.name)
+			attempts = append(attempts, env.Now().Sub(time.Unix(0, 0))/time.Second)
+		})
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 1, ScheduleInterval: time.Minute,
+			RetryPolicy: tc.policy}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.Equal(tc.expected, attempts, tc.name)
+		if tc.expectedErr == "" {
+			s.NoError(env.GetWorkflowError(), tc.name)
+		} else {
+			s.Error(env.GetWorkflowError(), tc.name)
+			s.Contains(env.GetWorkflowError().Error(), tc.expectedErr, tc.name)
+		}
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_NonRetriableError() {
+	env := s.NewTestWorkflowEnvironment()
+	var attempts int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		attempts++
+		return CronJobResult{}, cadence.NewErrorWithDetails(errReasonInvalidShard, input.Shard)
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 5,
+			NonRetriableErrorReasons: []string{errReasonInvalidShard}}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), errReasonInvalidShard)
+	s.Equal(1, attempts)
+}
+
+func (s *UnitTestSuite) Test_RetryPolicy_Validate() {
+	s.NoError((&RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}).validate())
+	s.NoError((&RetryPolicy{InitialInterval: time.Second, ExpirationInterval: time.Minute}).validate())
+	s.Error((&RetryPolicy{MaximumAttempts: 3}).validate())
+	s.Error((&RetryPolicy{InitialInterval: time.Second}).validate())
+	s.Error((&RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3, BackoffCoefficient: 0.5}).validate())
+}
+
+func (s *UnitTestSuite) Test_RetryPolicy_Backoff() {
+	policy := &RetryPolicy{InitialInterval: time.Second, BackoffCoefficient: 3, MaximumInterval: time.Second * 20}
+	var backoffs []time.Duration
+	for attempt := uint(0); attempt < 4; attempt++ {
+		backoffs = append(backoffs, policy.backoff(attempt))
+	}
+	s.Equal([]time.Duration{time.Second, time.Second * 3, time.Second * 9, time.Second * 20}, backoffs)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_HeartbeatProgressAcrossRetries() {
+	env := s.NewTestWorkflowEnvironment()
+	var progress []uint
+	env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context, args cadence.EncodedValues) {
+		var input CronJobInput
+		s.NoError(args.Get(&input))
+		progress = append(progress, input.Progress)
+	})
+	var heartbeats []uint
+	env.SetOnActivityHeartbeatListener(func(info *cadence.ActivityInfo, details cadence.EncodedValues) {
+		var done uint
+		s.NoError(details.Get(&done))
+		heartbeats = append(heartbeats, done)
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 1, ScheduleInterval: time.Minute,
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	// every attempt continues from the progress of the previous one, no work item is processed twice.
+	s.Equal([]uint{0, 2, 4}, progress)
+	s.Equal([]uint{1, 2, 3, 4, 5, 6}, heartbeats)
+}
+
+func (s *UnitTestSuite) Test_ProgressOf() {
+	progress, ok := progressOf(cadence.NewHeartbeatTimeoutError(encodedProgress(s, 3)))
+	s.True(ok)
+	s.Equal(uint(3), progress)
+	_, ok = progressOf(cadence.NewTimeoutError(shared.TimeoutType_START_TO_CLOSE))
+	s.False(ok)
+	_, ok = progressOf(cadence.NewErrorWithDetails(errReasonInvalidShard, encodedProgress(s, 3)))
+	s.False(ok)
+	_, ok = progressOf(errors.New("job failed"))
+	s.False(ok)
+}
+
+// encodedProgress encodes the progress like the details of a heartbeat in the history.
+func encodedProgress(s *UnitTestSuite, progress uint) []byte {
+	var buf bytes.Buffer
+	s.NoError(gob.NewEncoder(&buf).Encode(progress))
+	return buf.Bytes()
+}
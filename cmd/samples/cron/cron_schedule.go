@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	// The timezone database is embedded, so that all workers compute the same run times from the same zone rules
+	// regardless of what is installed on the host. Otherwise replay on a host with different rules could break.
+	_ "time/tzdata"
+)
+
+/**
+ * Calendar rules of the cron workflow schedule.
+ *
+ * With a daily schedule the job runs once a day at a wall clock time in a timezone. Around daylight saving time
+ * transitions the wall clock time might not exist or exist twice on a day. A run that falls into the gap of a spring
+ * forward transition moves to the end of the gap, and in a fall back transition only the first of the two instants is
+ * used, so the job runs exactly once on every day.
+ *
+ * Exclusions are blackout days, a scheduled run that would fire on one of them rolls forward to the next slot of the
+ * schedule on an allowed day. Manual runs requested with the triggerNow signal are not subject to exclusions.
+ *
+ * A schedule by interval waits the interval from the start of the wait, which moves the schedule every time a run
+ * starts off schedule, e.g. a manual or a buffered run, and by the latency of every decision. With AlignToInterval
+ * the runs fire on the multiples of the interval since the Unix epoch instead, an hourly schedule runs at the full
+ * hour no matter when the wait started.
+ */
+
+type (
+	// Exclusions are the days on which the job must not run, in the timezone of the schedule.
+	Exclusions struct {
+		// Weekdays excluded every week, e.g. time.Saturday and time.Sunday.
+		Weekdays []time.Weekday
+		// Dates excluded once, e.g. holidays, in the "2006-01-02" format.
+		Dates []string
+	}
+
+	// dailySchedule is the parsed TimeOfDay and Timezone of a ScheduleSpec.
+	dailySchedule struct {
+		hour     int
+		minute   int
+		location *time.Location
+	}
+
+	// exclusionCalendar is the parsed Exclusions of a ScheduleSpec.
+	exclusionCalendar struct {
+		weekdays [7]bool
+		dates    map[string]bool
+		location *time.Location
+	}
+)
+
+const exclusionDateLayout = "2006-01-02"
+
+// parseCalendar parses the calendar rules of the spec, the workflow can't run with an invalid schedule.
+func (s *ScheduleSpec) parseCalendar() error {
+	if s.TimeOfDay != "" {
+		daily, err := newDailySchedule(s.TimeOfDay, s.Timezone)
+		if err != nil {
+			return err
+		}
+		s.daily = daily
+	}
+	if len(s.Exclusions.Weekdays) > 0 || len(s.Exclusions.Dates) > 0 {
+		location, err := loadLocation(s.Timezone)
+		if err != nil {
+			return err
+		}
+		exclusions, err := newExclusionCalendar(s.Exclusions, location)
+		if err != nil {
+			return err
+		}
+		s.exclusions = exclusions
+	}
+	if s.DailyBudget > 0 {
+		location, err := loadLocation(s.Timezone)
+		if err != nil {
+			return err
+		}
+		s.budgetLocation = location
+	}
+	return nil
+}
+
+func loadLocation(timezone string) (*time.Location, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule timezone %q: %v", timezone, err)
+	}
+	return location, nil
+}
+
+// newDailySchedule parses a time of day in the 24-hour "15:04" format and an IANA timezone name, an empty timezone
+// is UTC.
+func newDailySchedule(timeOfDay, timezone string) (*dailySchedule, error) {
+	t, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule time of day %q, expected HH:MM: %v", timeOfDay, err)
+	}
+	location, err := loadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &dailySchedule{hour: t.Hour(), minute: t.Minute(), location: location}, nil
+}
+
+// next returns the first run strictly after the given time.
+func (d *dailySchedule) next(after time.Time) time.Time {
+	local := after.In(d.location)
+	for day := local.Day(); ; day++ {
+		// time.Date normalizes days past the end of the month.
+		run := d.on(local.Year(), local.Month(), day)
+		if run.After(after) {
+			return run
+		}
+	}
+}
+
+// on returns the run on the given date.
+func (d *dailySchedule) on(year int, month time.Month, day int) time.Time {
+	run := time.Date(year, month, day, d.hour, d.minute, 0, 0, d.location)
+	if !d.isTimeOfDay(run) {
+		// the wall clock skips the time of day on this date.
+		return d.gapEnd(year, month, day)
+	}
+
+	// When the wall clock is set back, the time of day can happen twice. time.Date could pick either one, so look for
+	// an earlier instant with the same wall clock time under the offset used before the transition.
+	_, offsetBefore := run.Add(-time.Hour * 12).Zone()
+	_, offset := run.Zone()
+	if offsetBefore > offset {
+		earlier := run.Add(-time.Second * time.Duration(offsetBefore-offset))
+		if d.isTimeOfDay(earlier) {
+			return earlier
+		}
+	}
+	return run
+}
+
+// gapEnd returns the first instant at which the wall clock is at or past the time of day on the given date.
+func (d *dailySchedule) gapEnd(year int, month time.Month, day int) time.Time {
+	target := time.Date(year, month, day, d.hour, d.minute, 0, 0, time.UTC)
+	reached := func(unixSeconds int64) bool {
+		local := time.Unix(unixSeconds, 0).In(d.location)
+		wallClock := time.Date(local.Year(), local.Month(), local.Day(),
+			local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+		return !wallClock.Before(target)
+	}
+
+	// UTC offsets are within a day, so the wall clock is before the target two days earlier and past it two days
+	// later.
+	low := target.Add(-time.Hour * 48).Unix()
+	high := target.Add(time.Hour * 48).Unix()
+	for low+1 < high {
+		middle := low + (high-low)/2
+		if reached(middle) {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return time.Unix(high, 0).In(d.location)
+}
+
+// nextBoundary returns the first multiple of the interval since the Unix epoch strictly after the given time.
+func nextBoundary(after time.Time, interval time.Duration) time.Time {
+	sinceEpoch := time.Duration(after.UnixNano())
+	return time.Unix(0, 0).Add(sinceEpoch - sinceEpoch%interval + interval)
+}
+
+func (d *dailySchedule) isTimeOfDay(t time.Time) bool {
+	return t.Hour() == d.hour && t.Minute() == d.minute
+}
+
+func newExclusionCalendar(exclusions Exclusions, location *time.Location) (*exclusionCalendar, error) {
+	c := &exclusionCalendar{dates: make(map[string]bool), location: location}
+	for _, weekday := range exclusions.Weekdays {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return nil, fmt.Errorf("invalid excluded weekday %d", weekday)
+		}
+		c.weekdays[weekday] = true
+	}
+	allWeekdays := true
+	for _, excluded := range c.weekdays {
+		allWeekdays = allWeekdays && excluded
+	}
+	if allWeekdays {
+		return nil, errors.New("exclusions leave no day of the week to run the job on")
+	}
+	for _, date := range exclusions.Dates {
+		if _, err := time.Parse(exclusionDateLayout, date); err != nil {
+			return nil, fmt.Errorf("invalid excluded date %q, expected YYYY-MM-DD: %v", date, err)
+		}
+		c.dates[date] = true
+	}
+	return c, nil
+}
+
+// excludes returns true if the given time is on an excluded day.
+func (c *exclusionCalendar) excludes(t time.Time) bool {
+	local := t.In(c.location)
+	return c.weekdays[local.Weekday()] || c.dates[local.Format(exclusionDateLayout)]
+}
+
+// nextAllowed returns the start of the first day after the given time that is not excluded.
+func (c *exclusionCalendar) nextAllowed(t time.Time) time.Time {
+	local := t.In(c.location)
+	for day := local.Day() + 1; ; day++ {
+		// the excluded dates are finite and not all weekdays are excluded, so this ends.
+		start := time.Date(local.Year(), local.Month(), day, 0, 0, 0, 0, c.location)
+		if !c.excludes(start) {
+			return start
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func Test_DailySchedule_Next(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	// In 2023 the US clocks went forward on March 12 at 02:00 EST and back on November 5 at 02:00 EDT.
+	testCases := []struct {
+		name      string
+		timeOfDay string
+		timezone  string
+		after     time.Time
+		expected  time.Time
+	}{
+		{"later today", "02:00", "America/New_York", at(2023, 3, 10, 5, 0), at(2023, 3, 10, 7, 0)},
+		{"tomorrow", "02:00", "America/New_York", at(2023, 3, 10, 7, 0), at(2023, 3, 11, 7, 0)},
+		{"start of spring forward gap", "02:00", "America/New_York", at(2023, 3, 11, 7, 0), at(2023, 3, 12, 7, 0)},
+		{"inside spring forward gap", "02:30", "America/New_York", at(2023, 3, 11, 7, 30), at(2023, 3, 12, 7, 0)},
+		{"day after spring forward", "02:30", "America/New_York", at(2023, 3, 12, 7, 0), at(2023, 3, 13, 6, 30)},
+		{"after spring forward on the same day", "03:00", "America/New_York", at(2023, 3, 12, 6, 0), at(2023, 3, 12, 7, 0)},
+		{"first of fall back overlap", "01:30", "America/New_York", at(2023, 11, 4, 5, 30), at(2023, 11, 5, 5, 30)},
+		{"second of fall back overlap skipped", "01:30", "America/New_York", at(2023, 11, 5, 5, 30), at(2023, 11, 6, 6, 30)},
+		{"outside fall back overlap", "02:00", "America/New_York", at(2023, 11, 4, 6, 0), at(2023, 11, 5, 7, 0)},
+		{"end of month", "23:00", "", at(2023, 1, 31, 23, 0), at(2023, 2, 1, 23, 0)},
+		{"utc by default", "02:00", "", at(2023, 3, 12, 1, 0), at(2023, 3, 12, 2, 0)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			daily, err := newDailySchedule(tc.timeOfDay, tc.timezone)
+			require.NoError(t, err)
+			next := daily.next(tc.after)
+			require.True(t, tc.expected.Equal(next), "expected %v, got %v", tc.expected.In(newYork), next.In(newYork))
+		})
+	}
+}
+
+func Test_DailySchedule_Invalid(t *testing.T) {
+	_, err := newDailySchedule("02:00", "America/Nowhere")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "America/Nowhere")
+
+	_, err = newDailySchedule("25:00", "America/New_York")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "25:00")
+}
+
+func Test_ExclusionCalendar(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	c, err := newExclusionCalendar(Exclusions{
+		Weekdays: []time.Weekday{time.Saturday, time.Sunday},
+		Dates:    []string{"2023-12-25"},
+	}, newYork)
+	require.NoError(t, err)
+
+	// Friday 23:00 in New York is already Saturday in UTC.
+	require.False(t, c.excludes(time.Date(2023, 12, 22, 23, 0, 0, 0, newYork)))
+	require.True(t, c.excludes(time.Date(2023, 12, 23, 0, 0, 0, 0, newYork)))
+	require.True(t, c.excludes(time.Date(2023, 12, 25, 12, 0, 0, 0, newYork)))
+	require.True(t, time.Date(2023, 12, 26, 0, 0, 0, 0, newYork).Equal(
+		c.nextAllowed(time.Date(2023, 12, 23, 10, 0, 0, 0, newYork))))
+
+	_, err = newExclusionCalendar(Exclusions{Weekdays: []time.Weekday{0, 1, 2, 3, 4, 5, 6}}, time.UTC)
+	require.Error(t, err)
+	_, err = newExclusionCalendar(Exclusions{Weekdays: []time.Weekday{7}}, time.UTC)
+	require.Error(t, err)
+	_, err = newExclusionCalendar(Exclusions{Dates: []string{"2023-02-30"}}, time.UTC)
+	require.Error(t, err)
+}
+
+func Test_NextBoundary(t *testing.T) {
+	base := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		after    time.Time
+		interval time.Duration
+		expected time.Time
+	}{
+		{"on a boundary", base, time.Hour, base.Add(time.Hour)},
+		{"between boundaries", base.Add(time.Minute * 5), time.Hour, base.Add(time.Hour)},
+		{"right before a boundary", base.Add(time.Hour - time.Nanosecond), time.Hour, base.Add(time.Hour)},
+		{"in another timezone", base.Add(time.Minute * 5).In(time.FixedZone("IST", 19800)), time.Hour, base.Add(time.Hour)},
+		// 11 minutes don't divide a day, the boundaries are counted from the epoch and not from midnight.
+		{"uneven interval", base, time.Minute * 11, base.Add(time.Minute * 6)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := nextBoundary(tc.after, tc.interval)
+			require.True(t, tc.expected.Equal(next), "expected %v, got %v", tc.expected, next)
+		})
+	}
+}
+
+func TestReplay_CronWorkflowDailyAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2023, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name      string
+		timeOfDay string
+		start     time.Time
+		expected  []time.Time
+	}{
+		// 02:30 doesn't exist on March 12, the run moves to 03:00 EDT.
+		{"spring forward", "02:30", at(3, 10, 17, 0), []time.Time{at(3, 11, 7, 30), at(3, 12, 7, 0), at(3, 13, 6, 30)}},
+		// 01:30 happens twice on November 5, the job runs only at the first one.
+		{"fall back", "01:30", at(11, 3, 16, 0), []time.Time{at(11, 4, 5, 30), at(11, 5, 5, 30), at(11, 6, 6, 30)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: uint(len(tc.expected)), TimeOfDay: tc.timeOfDay, Timezone: "America/New_York"}
+			h := newHistorySimulator(t, tc.start, SampleCronWorkflow, spec, &CronState{})
+			h.activityDuration = time.Minute
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			runs := h.activityScheduleTimes()
+			require.Len(t, runs, len(tc.expected))
+			for i := range runs {
+				require.True(t, tc.expected[i].Equal(runs[i]), "expected %v, got %v", tc.expected[i].In(newYork), runs[i].In(newYork))
+			}
+		})
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ExclusionsSkipWeekend() {
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	// the test environment starts on Thursday, January 1 1970 UTC.
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Hour * 24,
+		Exclusions: Exclusions{Weekdays: []time.Weekday{time.Saturday, time.Sunday}}}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.ContinueAsNewError)
+	s.True(ok)
+	day := time.Hour * 24
+	s.Equal([]time.Duration{day, day * 4, day * 5, day * 6, day * 7, day * 8, day * 11, day * 12, day * 13, day * 14},
+		runTimes)
+	// the skipped runs don't consume the job count.
+	spec := continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+	s.Equal(uint(2), spec.JobCount)
+	s.Equal(uint(4), spec.SkippedByExclusions)
+	// every weekend is recorded once, before the run on the Monday after it.
+	var excluded []RunRecord
+	for _, record := range continueAsNewArgs(env.GetWorkflowError())[1].(*CronState).RecentRuns {
+		if record.Status == RunSkippedByExclusions {
+			excluded = append(excluded, record)
+		}
+	}
+	s.Equal([]RunRecord{
+		{ScheduledAt: time.Unix(0, 0).Add(day * 4), Status: RunSkippedByExclusions,
+			ResultSummary: "2 runs skipped due to blackout"},
+		{ScheduledAt: time.Unix(0, 0).Add(day * 11), Status: RunSkippedByExclusions,
+			ResultSummary: "2 runs skipped due to blackout"},
+	}, excluded)
+}
+
+func TestReplay_CronWorkflowExclusions(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	weekend := []time.Weekday{time.Saturday, time.Sunday}
+
+	testCases := []struct {
+		name     string
+		spec     ScheduleSpec
+		start    time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "friday evening start skips to monday",
+			spec:     ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour * 24, Exclusions: Exclusions{Weekdays: weekend}},
+			start:    time.Date(2023, 3, 17, 20, 0, 0, 0, time.UTC),
+			expected: []time.Time{time.Date(2023, 3, 20, 20, 0, 0, 0, time.UTC), time.Date(2023, 3, 21, 20, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "daily schedule skips weekend and holiday",
+			spec: ScheduleSpec{JobCount: 2, TimeOfDay: "09:00", Timezone: "America/New_York",
+				Exclusions: Exclusions{Weekdays: weekend, Dates: []string{"2023-12-25"}}},
+			start:    time.Date(2023, 12, 22, 18, 0, 0, 0, newYork),
+			expected: []time.Time{time.Date(2023, 12, 26, 9, 0, 0, 0, newYork), time.Date(2023, 12, 27, 9, 0, 0, 0, newYork)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHistorySimulator(t, tc.start, SampleCronWorkflow, tc.spec, &CronState{})
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			runs := h.activityScheduleTimes()
+			require.Len(t, runs, len(tc.expected))
+			for i := range runs {
+				require.True(t, tc.expected[i].Equal(runs[i]), "expected %v, got %v", tc.expected[i], runs[i])
+			}
+		})
+	}
+}
+
+func TestReplay_CronWorkflowAlignToInterval(t *testing.T) {
+	start := time.Unix(17, 0)
+	testCases := []struct {
+		name     string
+		align    bool
+		expected []time.Duration
+	}{
+		// the manual run waits for the first run and starts at 157s, the schedule continues from there.
+		{"unaligned", false, []time.Duration{77, 157, 277, 397}},
+		// the runs stay on the full minutes, the slots at 120s, 180s and 300s are skipped while a run is executing.
+		{"aligned", true, []time.Duration{60, 140, 240, 360}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute, AlignToInterval: tc.align}
+			h := newHistorySimulator(t, start, SampleCronWorkflow, spec, &CronState{})
+			h.activityDuration = time.Second * 80
+			h.signalAfter(time.Second*83, triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			var runs []time.Duration
+			for _, run := range h.activityScheduleTimes() {
+				runs = append(runs, run.Sub(time.Unix(0, 0))/time.Second)
+			}
+			require.Equal(t, tc.expected, runs)
+		})
+	}
+}
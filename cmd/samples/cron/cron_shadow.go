@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * The shadow mode replays the histories of the closed runs of the cron workflow in a domain with the code of the
+ * binary, the way the replay tests replay the histories in testdata. A change of SampleCronWorkflow that doesn't replay
+ * the production histories is caught before it is deployed, and the runs that fail are reported by workflow and run ID.
+ * The runner exits with 1 if any run failed to replay, so the mode can gate a deployment:
+ *
+ *   ./bin/cron -m shadow -shadowDays 7 -shadowSampling 0.1 -shadowCount 200
+ *
+ * Newer clients have a shadow worker for this, worker.NewShadowWorker with ShadowOptions, whose fields the
+ * ShadowOptions of the sample follow. This version of the client has neither, nor advanced visibility to query by. The
+ * shadower lists the closed runs of the workflow type with ListClosedWorkflow and keeps those that closed within
+ * ClosedWithin, the retention of the domain bounds the list. It replays the histories with the WorkflowTaskHandler the
+ * workers use, without polling for tasks, so no task of the domain is touched. Only the workflows are registered, the
+ * replay doesn't execute activities.
+ */
+
+// The defaults of the shadow flags.
+const (
+	defaultShadowDays         = 7
+	defaultShadowConcurrency  = 4
+	defaultShadowScanInterval = time.Minute * 5
+)
+
+type (
+	// ShadowOptions configure which runs are shadowed and when the shadower stops.
+	ShadowOptions struct {
+		// WorkflowType is the type of the runs to replay.
+		WorkflowType string
+		// ClosedWithin replays the runs that closed within this time before the scan.
+		ClosedWithin time.Duration
+		// SamplingRate is the share of the matching runs that are replayed, in (0, 1].
+		SamplingRate float64
+		// Concurrency is the number of histories replayed at the same time.
+		Concurrency int
+		// Continuous scans for new closed runs every ScanInterval until the ExitCondition is met, instead of scanning
+		// once.
+		Continuous   bool
+		ScanInterval time.Duration
+		// ExitCondition stops the shadower before a scan is done.
+		ExitCondition ShadowExitCondition
+	}
+
+	// ShadowExitCondition stops the shadower, zero fields are no limit.
+	ShadowExitCondition struct {
+		// ShadowCount stops after so many runs were replayed.
+		ShadowCount int
+		// ExpirationInterval stops after this time.
+		ExpirationInterval time.Duration
+	}
+
+	// shadowFlags are the flags of the shadow mode.
+	shadowFlags struct {
+		Days            uint
+		SamplingRate    float64
+		Concurrency     uint
+		Count           uint
+		DurationSeconds uint
+		Continuous      bool
+		IntervalSeconds uint
+	}
+
+	// ShadowResult is the outcome of the shadower.
+	ShadowResult struct {
+		// Replayed counts the runs whose histories were replayed, including the failed ones.
+		Replayed int
+		Failures []ShadowFailure
+	}
+
+	// ShadowFailure is a run that failed to replay, or whose history couldn't be read.
+	ShadowFailure struct {
+		WorkflowID string
+		RunID      string
+		Error      string
+	}
+)
+
+// options returns the ShadowOptions of the flags, or an error for flags the shadower can't run with.
+func (f shadowFlags) options() (ShadowOptions, error) {
+	if f.Days == 0 {
+		return ShadowOptions{}, errors.New("-shadowDays must be positive, no run closed within no time")
+	}
+	if f.SamplingRate <= 0 || f.SamplingRate > 1 {
+		return ShadowOptions{}, fmt.Errorf("-shadowSampling %v must be in (0, 1]", f.SamplingRate)
+	}
+	if f.Concurrency == 0 {
+		return ShadowOptions{}, errors.New("-shadowConcurrency must be positive")
+	}
+	if f.IntervalSeconds > 0 && !f.Continuous {
+		return ShadowOptions{}, errors.New("-shadowInterval applies to -shadowContinuous, a single scan doesn't repeat")
+	}
+	options := ShadowOptions{
+		WorkflowType: cronWorkflowType,
+		ClosedWithin: time.Hour * 24 * time.Duration(f.Days),
+		SamplingRate: f.SamplingRate,
+		Concurrency:  int(f.Concurrency),
+		Continuous:   f.Continuous,
+		ExitCondition: ShadowExitCondition{ShadowCount: int(f.Count),
+			ExpirationInterval: time.Second * time.Duration(f.DurationSeconds)},
+	}
+	if f.Continuous {
+		options.ScanInterval = defaultShadowScanInterval
+		if f.IntervalSeconds > 0 {
+			options.ScanInterval = time.Second * time.Duration(f.IntervalSeconds)
+		}
+	}
+	return options, nil
+}
+
+// shadower replays the closed runs of a domain.
+type shadower struct {
+	client  cadence.Client
+	domain  string
+	options ShadowOptions
+	logger  *zap.Logger
+	random  *rand.Rand
+	// expires is the time the ExitCondition stops the shadower at, zero without an ExpirationInterval.
+	expires time.Time
+	// seen are the runs that were replayed or skipped by the sampling, a continuous scan doesn't pick them again.
+	seen map[string]bool
+	// dispatched counts the runs handed to the replayers, the ShadowCount limits them.
+	dispatched int
+
+	mu     sync.Mutex
+	result ShadowResult
+}
+
+// shadowWorkflows replays the runs the options select with the workflows registered in the process, and returns the
+// runs replayed and those that failed. It returns an error if the runs can't be listed.
+func shadowWorkflows(client cadence.Client, domain string, options ShadowOptions, logger *zap.Logger) (ShadowResult,
+	error) {
+	s := &shadower{client: client, domain: domain, options: options, logger: logger,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())), seen: make(map[string]bool)}
+	if options.ExitCondition.ExpirationInterval > 0 {
+		s.expires = time.Now().Add(options.ExitCondition.ExpirationInterval)
+	}
+	for {
+		if err := s.scan(); err != nil {
+			return s.result, err
+		}
+		if !options.Continuous || s.exited() {
+			return s.result, nil
+		}
+		wait := options.ScanInterval
+		if !s.expires.IsZero() && time.Until(s.expires) < wait {
+			wait = time.Until(s.expires)
+		}
+		time.Sleep(wait)
+		if s.exited() {
+			return s.result, nil
+		}
+	}
+}
+
+// exited returns true once the ExitCondition is met.
+func (s *shadower) exited() bool {
+	if !s.expires.IsZero() && !time.Now().Before(s.expires) {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.options.ExitCondition.ShadowCount > 0 && s.result.Replayed >= s.options.ExitCondition.ShadowCount
+}
+
+// scan replays the runs not seen yet that closed within ClosedWithin, Concurrency at a time.
+func (s *shadower) scan() error {
+	now := time.Now()
+	closedAfter := now.Add(-s.options.ClosedWithin).UnixNano()
+	earliest, latest := int64(0), now.UnixNano()
+	workflowType := s.options.WorkflowType
+	pageSize := int32(defaultPageSize)
+	request := &shared.ListClosedWorkflowExecutionsRequest{MaximumPageSize: &pageSize,
+		StartTimeFilter: &shared.StartTimeFilter{EarliestTime: &earliest, LatestTime: &latest},
+		TypeFilter:      &shared.WorkflowTypeFilter{Name: &workflowType}}
+	replays := make(chan *shared.WorkflowExecution)
+	var wg sync.WaitGroup
+	for i := 0; i < s.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the handler replays one history at a time, every replayer has its own.
+			handler := cadence.NewWorkflowTaskHandler(s.domain, "cron-shadow", zap.NewNop())
+			for execution := range replays {
+				s.replay(handler, execution)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(replays)
+	for {
+		response, err := s.client.ListClosedWorkflow(request)
+		if err != nil {
+			return err
+		}
+		for _, execution := range response.Executions {
+			runID := execution.GetExecution().GetRunId()
+			if execution.GetCloseTime() < closedAfter || s.seen[runID] {
+				continue
+			}
+			s.seen[runID] = true
+			if s.random.Float64() >= s.options.SamplingRate {
+				continue
+			}
+			if s.exited() || (s.options.ExitCondition.ShadowCount > 0 &&
+				s.dispatched >= s.options.ExitCondition.ShadowCount) {
+				return nil
+			}
+			s.dispatched++
+			replays <- execution.GetExecution()
+		}
+		if len(response.NextPageToken) == 0 {
+			return nil
+		}
+		request.NextPageToken = response.NextPageToken
+	}
+}
+
+// replay replays the history of the given run, and records the outcome.
+func (s *shadower) replay(handler cadence.WorkflowTaskHandler, execution *shared.WorkflowExecution) {
+	logger := s.logger.With(zap.String("WorkflowID", execution.GetWorkflowId()),
+		zap.String("RunID", execution.GetRunId()))
+	history, err := s.client.GetWorkflowHistory(execution.GetWorkflowId(), execution.GetRunId())
+	if err == nil {
+		err = replayHistory(handler, execution, history)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result.Replayed++
+	if err == nil {
+		logger.Debug("Shadowed run replayed.")
+		return
+	}
+	logger.Error("Shadowed run failed to replay.", zap.Error(err))
+	s.result.Failures = append(s.result.Failures, ShadowFailure{WorkflowID: execution.GetWorkflowId(),
+		RunID: execution.GetRunId(), Error: err.Error()})
+}
+
+// replayHistory replays the complete history of a closed run, all its events are treated as replayed. The client
+// returns an error or panics on a decision that doesn't match the history.
+func replayHistory(handler cadence.WorkflowTaskHandler, execution *shared.WorkflowExecution,
+	history *shared.History) (err error) {
+	if len(history.GetEvents()) == 0 {
+		return errors.New("run has no history")
+	}
+	started := history.Events[0].GetWorkflowExecutionStartedEventAttributes()
+	if started == nil {
+		return errors.New("history doesn't start with a WorkflowExecutionStarted event")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("replay panicked: %v", p)
+		}
+	}()
+	previousStartedEventID := int64(len(history.Events))
+	_, _, err = handler.ProcessWorkflowTask(&shared.PollForDecisionTaskResponse{
+		TaskToken:              []byte("shadow-task"),
+		WorkflowExecution:      execution,
+		WorkflowType:           started.WorkflowType,
+		PreviousStartedEventId: &previousStartedEventID,
+		History:                history,
+	}, false)
+	return err
+}
+
+// printShadowResult prints the number of runs replayed and the runs that failed.
+func printShadowResult(out io.Writer, result ShadowResult) {
+	fmt.Fprintf(out, "Replayed %d runs, %d failed.\n", result.Replayed, len(result.Failures))
+	if len(result.Failures) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW ID\tRUN ID\tERROR")
+	for _, failure := range result.Failures {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", failure.WorkflowID, failure.RunID, failure.Error)
+	}
+	w.Flush()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+	"go.uber.org/cadence/mocks"
+	"go.uber.org/zap"
+)
+
+var testShadowFlags = shadowFlags{Days: 7, SamplingRate: 1, Concurrency: 4}
+
+func Test_ShadowFlags_Options(t *testing.T) {
+	options, err := testShadowFlags.options()
+	require.NoError(t, err)
+	require.Equal(t, ShadowOptions{WorkflowType: cronWorkflowType, ClosedWithin: time.Hour * 24 * 7, SamplingRate: 1,
+		Concurrency: 4}, options)
+
+	continuous := testShadowFlags
+	continuous.SamplingRate = 0.25
+	continuous.Count = 100
+	continuous.DurationSeconds = 3600
+	continuous.Continuous = true
+	options, err = continuous.options()
+	require.NoError(t, err)
+	require.Equal(t, ShadowOptions{WorkflowType: cronWorkflowType, ClosedWithin: time.Hour * 24 * 7,
+		SamplingRate: 0.25, Concurrency: 4, Continuous: true, ScanInterval: defaultShadowScanInterval,
+		ExitCondition: ShadowExitCondition{ShadowCount: 100, ExpirationInterval: time.Hour}}, options)
+
+	continuous.IntervalSeconds = 60
+	options, err = continuous.options()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, options.ScanInterval)
+}
+
+func Test_ShadowFlags_Invalid(t *testing.T) {
+	for _, update := range []func(f *shadowFlags){
+		func(f *shadowFlags) { f.Days = 0 },
+		func(f *shadowFlags) { f.SamplingRate = 0 },
+		func(f *shadowFlags) { f.SamplingRate = 1.5 },
+		func(f *shadowFlags) { f.Concurrency = 0 },
+		// the interval of a single scan.
+		func(f *shadowFlags) { f.IntervalSeconds = 60 },
+	} {
+		flags := testShadowFlags
+		update(&flags)
+		_, err := flags.options()
+		require.Error(t, err, "%+v", flags)
+	}
+}
+
+// shadowExecution returns a run of the cron workflow that closed the given time ago.
+func shadowExecution(runID string, closedAgo time.Duration) *s.WorkflowExecutionInfo {
+	closeTime := time.Now().Add(-closedAgo)
+	return &s.WorkflowExecutionInfo{
+		Execution: &s.WorkflowExecution{WorkflowId: common.StringPtr("cron_" + runID), RunId: common.StringPtr(runID)},
+		Type:      &s.WorkflowType{Name: common.StringPtr(cronWorkflowType)},
+		StartTime: common.Int64Ptr(closeTime.Add(-time.Hour).UnixNano()),
+		CloseTime: common.Int64Ptr(closeTime.UnixNano()),
+	}
+}
+
+// onHistory serves the history in the fixture as the history of the given run.
+func onHistory(t *testing.T, service *mocks.TChanWorkflowService, runID, path string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var history s.History
+	require.NoError(t, json.Unmarshal(data, &history))
+	service.On("GetWorkflowExecutionHistory", mock.Anything,
+		mock.MatchedBy(func(request *s.GetWorkflowExecutionHistoryRequest) bool {
+			return request.GetExecution().GetRunId() == runID
+		})).Return(&s.GetWorkflowExecutionHistoryResponse{History: &history}, nil)
+}
+
+// shadowService returns a service with a page of closed runs: one that replays, one that doesn't, and one that closed
+// before the runs the shadower replays.
+func shadowService(t *testing.T) *mocks.TChanWorkflowService {
+	service := &mocks.TChanWorkflowService{}
+	service.On("ListClosedWorkflowExecutions", mock.Anything, closedPage("")).Return(
+		&s.ListClosedWorkflowExecutionsResponse{Executions: []*s.WorkflowExecutionInfo{
+			shadowExecution("run-ok", time.Hour), shadowExecution("run-nondeterministic", time.Hour*24),
+			shadowExecution("run-old", time.Hour*24*8),
+		}}, nil)
+	onHistory(t, service, "run-ok", "testdata/cron_history_continue_as_new.json")
+	onHistory(t, service, "run-nondeterministic", "testdata/cron_history_nondeterministic.json")
+	return service
+}
+
+func shadowTestClient(service *mocks.TChanWorkflowService) cadence.Client {
+	return cadence.NewClient(service, "samples-domain", &cadence.ClientOptions{})
+}
+
+func Test_ShadowWorkflows(t *testing.T) {
+	service := shadowService(t)
+	options, err := testShadowFlags.options()
+	require.NoError(t, err)
+	result, err := shadowWorkflows(shadowTestClient(service), "samples-domain", options, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Replayed)
+	require.Len(t, result.Failures, 1)
+	require.Equal(t, "cron_run-nondeterministic", result.Failures[0].WorkflowID)
+	require.Equal(t, "run-nondeterministic", result.Failures[0].RunID)
+	require.Contains(t, result.Failures[0].Error, "runCronJobActivity")
+	// the run that closed before the 7 days is not replayed.
+	service.AssertNumberOfCalls(t, "GetWorkflowExecutionHistory", 2)
+
+	var out bytes.Buffer
+	printShadowResult(&out, result)
+	require.Contains(t, out.String(), "Replayed 2 runs, 1 failed.\n")
+	require.Contains(t, out.String(), "cron_run-nondeterministic  run-nondeterministic")
+}
+
+func Test_ShadowWorkflows_ShadowCount(t *testing.T) {
+	service := shadowService(t)
+	flags := testShadowFlags
+	flags.Count = 1
+	options, err := flags.options()
+	require.NoError(t, err)
+	result, err := shadowWorkflows(shadowTestClient(service), "samples-domain", options, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, ShadowResult{Replayed: 1}, result)
+}
+
+func Test_ShadowWorkflows_Continuous(t *testing.T) {
+	service := shadowService(t)
+	options, err := testShadowFlags.options()
+	require.NoError(t, err)
+	options.Continuous = true
+	options.ScanInterval = time.Millisecond * 50
+	options.ExitCondition.ExpirationInterval = time.Millisecond * 200
+	result, err := shadowWorkflows(shadowTestClient(service), "samples-domain", options, zap.NewNop())
+	require.NoError(t, err)
+	// the scans after the first one find no new runs.
+	require.Equal(t, 2, result.Replayed)
+	require.True(t, len(service.Calls) > 3, "scanned once")
+	service.AssertNumberOfCalls(t, "GetWorkflowExecutionHistory", 2)
+}
+
+func Test_ShadowWorkflows_ListFailed(t *testing.T) {
+	service := &mocks.TChanWorkflowService{}
+	service.On("ListClosedWorkflowExecutions", mock.Anything, mock.Anything).Return(nil,
+		&s.BadRequestError{Message: "domain not found"})
+	options, err := testShadowFlags.options()
+	require.NoError(t, err)
+	_, err = shadowWorkflows(shadowTestClient(service), "samples-domain", options, zap.NewNop())
+	var badRequest *s.BadRequestError
+	require.True(t, errors.As(err, &badRequest), "%v", err)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * A run of the cron job can be split into shards that are processed by concurrent sampleCronActivity executions, so
+ * that no single activity has to process everything within its timeout. The run fails if any shard fails. By default
+ * the other shards still run to completion, with CancelShardsOnFailure they are cancelled as soon as one shard fails.
+ *
+ * Every activity of a run is scheduled on the shared task list, any worker may pick it up, so a job made of several
+ * activities can't pass a file on local disk from one to the next. Newer versions of the client pin such activities
+ * to one worker with a session, created with CreateSession on a worker with EnableSessionWorker and completed after
+ * the last activity. This version of the client has no sessions, the HostAffinity of the spec pins the shards of a run
+ * to a host with a task list of the host instead, see cron_host.go.
+ */
+
+// Every shard adds its activity events to the history of the workflow, the fan-out of a run is bounded so that the
+// history stays small until the next continue-as-new.
+const maxParallelism = 10
+
+func validateParallelism(parallelism uint) error {
+	if parallelism > maxParallelism {
+		return fmt.Errorf("parallelism %d exceeds the maximum of %d shards per run", parallelism, maxParallelism)
+	}
+	return nil
+}
+
+// shards returns the number of shards of every run.
+func (s *ScheduleSpec) shards() uint {
+	if s.Parallelism == 0 {
+		return 1
+	}
+	return s.Parallelism
+}
+
+// runShards executes one run of the job as one activity per shard, and waits for all of them. It returns the results
+// of the shards, a failed shard keeps its last result.
+func runShards(ctx cadence.Context, spec ScheduleSpec, lastResults []CronJobResult, run RunContext,
+	history *historyEstimate) ([]CronJobResult, error) {
+	parallelism := spec.shards()
+	results := make([]CronJobResult, parallelism)
+	copy(results, lastResults)
+	// the host of the run is picked by a worker of the task list of the job activity too.
+	taskList := spec.jobTaskList(nil)
+	ctx = spec.withJobTaskList(ctx, taskList)
+	route := newHostRoute(&spec, history)
+	if route != nil {
+		if err := route.pick(ctx); err != nil {
+			workflowLogger(ctx).Error("Cron job host pick failed.", zap.Error(err))
+			return results, spec.classifyNoWorker(ctx, taskList, err)
+		}
+	}
+	shardCtx, cancelShards := cadence.WithCancel(ctx)
+	defer cancelShards()
+
+	var failed []ShardFailure
+	failures := make([]ShardFailure, parallelism)
+	var firstErr error
+	var cancelled uint
+	selector := cadence.NewSelector(ctx)
+	for shard := uint(0); shard < parallelism; shard++ {
+		shard := shard
+		failures[shard].Shard = shard
+		f, settable := cadence.NewFuture(shardCtx)
+		cadence.Go(shardCtx, func(ctx cadence.Context) {
+			input := CronJobInput{PendingJobCount: spec.JobCount, ScheduledTime: run.FireTime, Shard: shard,
+				LastResult: results[shard], JobInput: spec.jobInput(), JobInputIndex: spec.JobInputIndex, Schedule: spec.Name,
+				TraceID: spec.TraceID, Run: run}
+			result, err := executeWithRetry(ctx, spec.RetryPolicy, spec.jobActivity(), input, history, route,
+				&failures[shard])
+			settable.Set(result, spec.classifyNoWorker(ctx, taskList, err))
+		})
+		selector.AddFuture(f, func(f cadence.Future) {
+			var result CronJobResult
+			err := f.Get(ctx, &result)
+			if err == nil {
+				results[shard] = result
+			}
+			if _, ok := err.(cadence.CanceledError); ok && (firstErr != nil || ctx.Err() != nil) {
+				cancelled++
+				return
+			}
+			if err != nil {
+				workflowLogger(ctx).Error("Cron job shard failed.", zap.Uint("Shard", shard), zap.Error(err))
+				failed = append(failed, failures[shard])
+				if firstErr == nil {
+					firstErr = err
+					if spec.CancelShardsOnFailure {
+						cancelShards()
+					}
+				}
+			}
+		})
+	}
+	for shard := uint(0); shard < parallelism; shard++ {
+		selector.Select(ctx)
+	}
+
+	workflowLogger(ctx).Info("Cron job run completed.",
+		zap.Uint("Shards", parallelism),
+		zap.Uint("Succeeded", parallelism-uint(len(failed))-cancelled),
+		zap.Uint("Failed", uint(len(failed))),
+		zap.Uint("Cancelled", cancelled))
+	if firstErr == nil && cancelled > 0 {
+		// without a failed shard, only the cancellation of the workflow cancels shards.
+		return results, ctx.Err()
+	}
+	if firstErr != nil {
+		return results, &shardsError{failed: failed, parallelism: parallelism, first: firstErr}
+	}
+	return results, nil
+}
+
+// shardsError is the error of a run with failed shards, the dead letter of the run has the attempts of the shards.
+type shardsError struct {
+	failed      []ShardFailure
+	parallelism uint
+	first       error
+}
+
+func (e *shardsError) Error() string {
+	shards := make([]uint, len(e.failed))
+	for i, failure := range e.failed {
+		shards[i] = failure.Shard
+	}
+	return fmt.Sprintf("%d of %d shards failed %v, first error: %v", len(e.failed), e.parallelism, shards, e.first)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_ShardFails() {
+	for _, cancelOnFailure := range []bool{false, true} {
+		env := s.NewTestWorkflowEnvironment()
+		var lock sync.Mutex
+		var shards []uint
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			lock.Lock()
+			shards = append(shards, input.Shard)
+			lock.Unlock()
+			if input.Shard == 3 {
+				return CronJobResult{}, errors.New("shard unavailable")
+			}
+			taskToken := cadence.GetActivityInfo(ctx).TaskToken
+			env.RegisterDelayedCallback(func() {
+				env.CompleteActivity(taskToken, CronJobResult{}, nil)
+			}, time.Second*90)
+			return CronJobResult{}, cadence.ErrActivityResultPending
+		})
+		var cancelTimes []time.Duration
+		env.SetOnActivityCanceledListener(func(activityInfo *cadence.ActivityInfo) {
+			cancelTimes = append(cancelTimes, env.Now().Sub(time.Unix(0, 0)))
+		})
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute,
+			Parallelism: 5, CancelShardsOnFailure: cancelOnFailure}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted())
+		s.Error(env.GetWorkflowError())
+		s.Contains(env.GetWorkflowError().Error(), "1 of 5 shards failed [3]")
+		s.Contains(env.GetWorkflowError().Error(), "shard unavailable")
+		if cancelOnFailure {
+			// shards that didn't start yet are cancelled as well.
+			s.Contains(shards, uint(3))
+			s.Equal([]time.Duration{time.Minute, time.Minute, time.Minute, time.Minute}, cancelTimes)
+		} else {
+			// the run fails once the other shards completed.
+			sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+			s.Equal([]uint{0, 1, 2, 3, 4}, shards)
+			s.Empty(cancelTimes)
+			s.Equal(time.Unix(0, 0).Add(time.Second*150), env.Now())
+		}
+	}
+}
+
+func TestReplay_CronWorkflowShards(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute, Parallelism: 3}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	h.activityDuration = time.Second * 30
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	start := time.Unix(0, 0)
+	first, second := start.Add(time.Minute), start.Add(time.Minute*2)
+	require.Equal(t, []time.Time{first, first, first, second, second, second}, h.activityScheduleTimes())
+}
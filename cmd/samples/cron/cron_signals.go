@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * Signals that can be sent to a running SampleCronWorkflow to control its schedule.
+ */
+
+const (
+	// Signals to pause and resume the schedule. Both signals take an optional reason string that is logged.
+	pauseSignalName  = "pause"
+	resumeSignalName = "resume"
+
+	// Signal to change the schedule of a running cron workflow, it takes a ScheduleUpdate.
+	updateScheduleSignalName = "updateSchedule"
+
+	// Signal to run the job right away instead of waiting for the next tick, it takes a TriggerNowRequest.
+	triggerNowSignalName = "triggerNow"
+
+	// Signal to let the runs in progress complete and stop the schedule, it takes an optional reason string that is
+	// logged.
+	drainSignalName = "drain"
+
+	// Signal to cancel the runs in progress without cancelling the workflow, it takes an optional reason string that is
+	// logged. The schedule goes on with the next run, a skipRun while no run is in progress is ignored.
+	skipRunSignalName = "skipRun"
+)
+
+type (
+	// ScheduleUpdate is the payload of the updateSchedule signal. The update takes effect for the next run, and if the
+	// workflow is currently waiting, the remaining wait is recalculated against the new interval. Only a schedule by
+	// interval can be updated, and an update that makes the spec invalid is ignored.
+	ScheduleUpdate struct {
+		ScheduleInterval time.Duration
+		// JobCount replaces the number of runs left if it is not 0.
+		JobCount uint
+	}
+
+	// TriggerNowRequest is the payload of the triggerNow signal. A manual run cancels the pending wait, and the normal
+	// schedule resumes from the time of the manual run. Triggers received while a run is pending are coalesced into
+	// one, and while the schedule is paused the trigger waits for the resume.
+	TriggerNowRequest struct {
+		// KeepJobCount makes the manual run not count against ScheduleSpec.JobCount.
+		KeepJobCount bool
+		// OverrideBudget starts the manual run even if the DailyBudget of the day is used up, see cron_budget.go.
+		OverrideBudget bool
+	}
+
+	// cronSignals holds the signal channels of a cron workflow execution.
+	cronSignals struct {
+		pause          cadence.Channel
+		resume         cadence.Channel
+		updateSchedule cadence.Channel
+		triggerNow     cadence.Channel
+		drain          cadence.Channel
+		skipRun        cadence.Channel
+		history        *historyEstimate
+		jobs           *cronJobs
+	}
+)
+
+func (u ScheduleUpdate) validate() error {
+	if u.ScheduleInterval <= 0 {
+		return fmt.Errorf("schedule interval must be positive, got %v", u.ScheduleInterval)
+	}
+	return nil
+}
+
+// withUpdate returns a copy of the spec with the update applied, or an error if the update doesn't apply to the spec or
+// the updated spec is invalid.
+func (s *ScheduleSpec) withUpdate(update ScheduleUpdate) (ScheduleSpec, error) {
+	if err := update.validate(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if s.TimeOfDay != "" || len(s.Jobs) > 0 {
+		return ScheduleSpec{}, errors.New("only the interval of a schedule by interval can be updated")
+	}
+	spec := *s
+	spec.setInterval(update.ScheduleInterval)
+	if update.JobCount > 0 {
+		spec.JobCount = update.JobCount
+		spec.limitJobCountToInputs()
+	}
+	if err := spec.validateInterval(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if err := spec.validateWorkflowTimeout(0); err != nil {
+		return ScheduleSpec{}, err
+	}
+	return spec, nil
+}
+
+func newCronSignals(ctx cadence.Context, history *historyEstimate, jobs *cronJobs) *cronSignals {
+	return &cronSignals{
+		pause:          cadence.GetSignalChannel(ctx, pauseSignalName),
+		resume:         cadence.GetSignalChannel(ctx, resumeSignalName),
+		updateSchedule: cadence.GetSignalChannel(ctx, updateScheduleSignalName),
+		triggerNow:     cadence.GetSignalChannel(ctx, triggerNowSignalName),
+		drain:          cadence.GetSignalChannel(ctx, drainSignalName),
+		skipRun:        cadence.GetSignalChannel(ctx, skipRunSignalName),
+		history:        history,
+		jobs:           jobs,
+	}
+}
+
+// addReceives adds the signal channels to the selector, the handlers apply the received signals to the spec. The
+// receives have to be added after the futures of the selector, and the selector used with selectSignals.
+func (s *cronSignals) addReceives(ctx cadence.Context, selector cadence.Selector, spec *ScheduleSpec) {
+	selector.AddReceive(s.pause, func(c cadence.Channel, more bool) {
+		var reason string
+		c.Receive(ctx, &reason)
+		s.history.addSignal()
+		setPaused(ctx, spec, true, reason)
+	})
+	selector.AddReceive(s.resume, func(c cadence.Channel, more bool) {
+		var reason string
+		c.Receive(ctx, &reason)
+		s.history.addSignal()
+		setPaused(ctx, spec, false, reason)
+	})
+	selector.AddReceive(s.updateSchedule, func(c cadence.Channel, more bool) {
+		var update ScheduleUpdate
+		c.Receive(ctx, &update)
+		s.history.addSignal()
+		updateSchedule(ctx, spec, update)
+	})
+	selector.AddReceive(s.triggerNow, func(c cadence.Channel, more bool) {
+		var request TriggerNowRequest
+		c.Receive(ctx, &request)
+		s.history.addSignal()
+		triggerNow(ctx, spec, request)
+	})
+	selector.AddReceive(s.drain, func(c cadence.Channel, more bool) {
+		var reason string
+		c.Receive(ctx, &reason)
+		s.history.addSignal()
+		setDraining(ctx, spec, reason)
+	})
+	selector.AddReceive(s.skipRun, func(c cadence.Channel, more bool) {
+		var reason string
+		c.Receive(ctx, &reason)
+		s.history.addSignal()
+		s.jobs.skipRuns(ctx, reason)
+	})
+}
+
+// selectSignals applies the signals that are already buffered, or blocks in the selector until one of its cases is
+// ready if there are none.
+//
+// A Select that returns right away for a ready case leaves the receives of the cases before it registered with their
+// channels, and such a receive takes the next signal of its channel and drops it. Applying the buffered signals first
+// means no receive is ready when the selector is used, and with the receives after the futures a Select returns right
+// away only for a future, before any receive is registered.
+func (s *cronSignals) selectSignals(ctx cadence.Context, selector cadence.Selector, spec *ScheduleSpec) {
+	if s.receivePending(ctx, spec) == 0 {
+		selector.Select(ctx)
+	}
+}
+
+// receivePending applies the signals that are already buffered without blocking, and returns how many it received.
+// When both pause and resume are pending, resume wins since the signals of the two channels can't be ordered.
+func (s *cronSignals) receivePending(ctx cadence.Context, spec *ScheduleSpec) int {
+	received := 0
+	var reason string
+	for s.pause.ReceiveAsync(&reason) {
+		s.history.addSignal()
+		received++
+		setPaused(ctx, spec, true, reason)
+	}
+	for s.resume.ReceiveAsync(&reason) {
+		s.history.addSignal()
+		received++
+		setPaused(ctx, spec, false, reason)
+	}
+	var update ScheduleUpdate
+	for s.updateSchedule.ReceiveAsync(&update) {
+		s.history.addSignal()
+		received++
+		updateSchedule(ctx, spec, update)
+	}
+	var request TriggerNowRequest
+	for s.triggerNow.ReceiveAsync(&request) {
+		s.history.addSignal()
+		received++
+		triggerNow(ctx, spec, request)
+	}
+	for s.drain.ReceiveAsync(&reason) {
+		s.history.addSignal()
+		received++
+		setDraining(ctx, spec, reason)
+	}
+	for s.skipRun.ReceiveAsync(&reason) {
+		s.history.addSignal()
+		received++
+		s.jobs.skipRuns(ctx, reason)
+	}
+	return received
+}
+
+func setPaused(ctx cadence.Context, spec *ScheduleSpec, paused bool, reason string) {
+	spec.Paused = paused
+	workflowLogger(ctx).Info("Cron workflow pause state changed.",
+		zap.Bool("Paused", paused), zap.String("Reason", reason))
+}
+
+func setDraining(ctx cadence.Context, spec *ScheduleSpec, reason string) {
+	spec.Draining = true
+	workflowLogger(ctx).Info("Cron workflow drain requested.", zap.String("Reason", reason))
+}
+
+func updateSchedule(ctx cadence.Context, spec *ScheduleSpec, update ScheduleUpdate) {
+	updated, err := spec.withUpdate(update)
+	if err != nil {
+		// the sender can't be told about the failure, so the update is logged and dropped.
+		workflowLogger(ctx).Warn("Invalid schedule update ignored.", zap.Error(err))
+		return
+	}
+	*spec = updated
+	workflowLogger(ctx).Info("Cron workflow schedule updated.",
+		zap.Duration("ScheduleInterval", spec.ScheduleInterval), zap.Uint("JobCount", spec.JobCount))
+}
+
+func triggerNow(ctx cadence.Context, spec *ScheduleSpec, request TriggerNowRequest) {
+	if spec.PendingTrigger != nil {
+		workflowLogger(ctx).Info("Cron workflow manual run already pending, trigger coalesced.")
+		return
+	}
+	spec.PendingTrigger = &request
+	workflowLogger(ctx).Info("Cron workflow manual run requested.", zap.Bool("KeepJobCount", request.KeepJobCount))
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestReplay_CronWorkflowSignalsInOneDecision(t *testing.T) {
+	// the update and the trigger arrive in the same decision, the selector of the next wait finds the trigger ready
+	// right away. The pause that follows must not be swallowed by that selector.
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow,
+		ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, &CronState{})
+	h.activityDuration = time.Minute
+	h.signalAfter(time.Minute*10, updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute * 30})
+	h.signalAfter(time.Minute*10, triggerNowSignalName, TriggerNowRequest{})
+	h.signalAfter(time.Minute*20, pauseSignalName, "incident")
+	h.signalAfter(time.Hour*3, resumeSignalName, "resolved")
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+
+	// the manual run at 10 minutes, then nothing until the resume.
+	inputs := h.activityInputs()
+	require.True(t, inputs[0].Run.Manual)
+	resumed := inputs[1].Run.DispatchTime
+	require.True(t, time.Unix(0, 0).Add(time.Hour*3+time.Minute*30).Equal(resumed), resumed)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_Pause() {
+	for _, tc := range []struct {
+		name string
+		// pauseAt is when the pause is sent, zero sends it from the first run.
+		pauseAt, resumeAt time.Duration
+		runTimes          []time.Duration
+	}{
+		// the interval starts over when the schedule is resumed.
+		{"during sleep", time.Minute * 30, time.Hour * 3, []time.Duration{time.Hour * 4, time.Hour * 5}},
+		{"during activity", 0, time.Hour * 5, []time.Duration{time.Hour, time.Hour * 6}},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		var runTimes []time.Duration
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			if tc.pauseAt == 0 && len(runTimes) == 0 {
+				env.SignalWorkflow(pauseSignalName, "incident")
+			}
+			runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+			return CronJobResult{}, nil
+		})
+		if tc.pauseAt > 0 {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(pauseSignalName, "incident")
+			}, tc.pauseAt)
+		}
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(resumeSignalName, "resolved")
+		}, tc.resumeAt)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour}, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.NoError(env.GetWorkflowError(), tc.name)
+		s.Equal(tc.runTimes, runTimes, tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_PauseSurvivesContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		if runs == loopCountBeforeContinueAsNew {
+			env.SignalWorkflow(pauseSignalName, "incident")
+		}
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(loopCountBeforeContinueAsNew, runs)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	s.Equal(ScheduleSpec{JobCount: 10, ScheduleInterval: time.Hour, Paused: true, ChangeVersions: latestVersions,
+		Timeouts: Timeouts{Workflow: time.Hour * 11, Decision: decisionTimeout}}, args[0])
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_PauseBeyondWorkflowTimeout() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Once()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(pauseSignalName, "incident")
+	}, time.Second*90)
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	env.AssertExpectations(s.T())
+	// the pause outlasts the run, which continues as new a decision timeout before it would time out.
+	s.Equal(spec.timeouts().Workflow-decisionTimeout, env.Now().Sub(time.Unix(0, 0)))
+	args := continueAsNewArgs(env.GetWorkflowError())
+	next, state := args[0].(ScheduleSpec), args[1].(*CronState)
+	s.True(next.Paused)
+	s.Equal(uint(4), next.JobCount)
+	s.Equal(uint(1), state.SuccessfulRuns)
+
+	// the next run is still paused, until it is resumed.
+	env = s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(resumeSignalName, "resolved")
+	}, time.Minute*10)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Minute * 11, time.Minute * 12, time.Minute * 13, time.Minute * 14}, runTimes)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_UpdateSchedule() {
+	for _, tc := range []struct {
+		name     string
+		jobCount uint
+		interval time.Duration
+		runTimes []time.Duration
+	}{
+		// 30 minutes already passed the new interval, so the first run happens right away.
+		{"shorter", 3, time.Minute, []time.Duration{time.Minute * 30, time.Minute * 31, time.Minute * 32}},
+		{"longer keeps the elapsed wait", 2, time.Hour * 2, []time.Duration{time.Hour * 2, time.Hour * 4}},
+		{"invalid is ignored", 2, -time.Minute, []time.Duration{time.Hour, time.Hour * 2}},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		var runTimes []time.Duration
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+			return CronJobResult{}, nil
+		})
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: tc.interval})
+		}, time.Minute*30)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: tc.jobCount, ScheduleInterval: time.Hour},
+			&CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.NoError(env.GetWorkflowError(), tc.name)
+		s.Equal(tc.runTimes, runTimes, tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_UpdateScheduleSurvivesContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute})
+	}, time.Minute*30)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+	// the workflow timeout derived from the interval follows the update.
+	s.Equal(ScheduleSpec{JobCount: 10, ScheduleInterval: time.Minute, ChangeVersions: latestVersions,
+		Timeouts: Timeouts{Workflow: workflowTimeout, Decision: decisionTimeout}}, args[0])
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_UpdateScheduleBeyondWorkflowTimeout() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(5)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute * 15})
+	}, time.Second*330)
+	spec := ScheduleSpec{JobCount: 20, ScheduleInterval: time.Minute}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	env.AssertExpectations(s.T())
+	// the next run at 20 minutes is due after the run of the workflow ends, it continues as new right away.
+	s.Equal(time.Second*330, env.Now().Sub(time.Unix(0, 0)))
+	next := continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+	s.Equal(uint(15), next.JobCount)
+	s.Equal(time.Minute*15, next.ScheduleInterval)
+	s.Equal(time.Minute*15*(loopCountBeforeContinueAsNew+1), next.Timeouts.Workflow)
+}
+
+func (s *UnitTestSuite) Test_ScheduleUpdate_Validate() {
+	s.NoError(ScheduleUpdate{ScheduleInterval: time.Second}.validate())
+	s.Error(ScheduleUpdate{}.validate())
+	s.Error(ScheduleUpdate{ScheduleInterval: -time.Second}.validate())
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_WithUpdate() {
+	for _, tc := range []struct {
+		name   string
+		spec   ScheduleSpec
+		update ScheduleUpdate
+		valid  bool
+	}{
+		{"interval", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, ScheduleUpdate{ScheduleInterval: time.Minute},
+			true},
+		{"jitter exceeds the interval", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, Jitter: time.Minute * 30},
+			ScheduleUpdate{ScheduleInterval: time.Minute * 10}, false},
+		{"time of day", ScheduleSpec{JobCount: 5, TimeOfDay: "02:00"}, ScheduleUpdate{ScheduleInterval: time.Hour},
+			false},
+		{"jobs", ScheduleSpec{JobCount: 5, Jobs: []JobSpec{{Name: "a", Interval: time.Hour}}},
+			ScheduleUpdate{ScheduleInterval: time.Minute}, false},
+		{"runs take too long", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute},
+			ScheduleUpdate{ScheduleInterval: time.Hour * 24 * 365 * 3}, false},
+		{"job count takes too long", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour * 24},
+			ScheduleUpdate{ScheduleInterval: time.Hour * 24, JobCount: 5000}, false},
+		{"interval beyond the workflow timeout", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			Timeouts: Timeouts{Workflow: time.Hour}}, ScheduleUpdate{ScheduleInterval: time.Hour}, false},
+	} {
+		spec := tc.spec
+		s.NoError(spec.Validate(time.Time{}), tc.name)
+		updated, err := spec.withUpdate(tc.update)
+		if !tc.valid {
+			s.Error(err, tc.name)
+			continue
+		}
+		s.NoError(err, tc.name)
+		s.Equal(tc.update.ScheduleInterval, updated.ScheduleInterval, tc.name)
+		s.Equal(time.Hour, spec.ScheduleInterval, tc.name)
+	}
+}
+
+func TestReplay_CronWorkflowScheduleUpdate(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	// the update is applied in a later decision than the one that started the wait.
+	h.signalAfter(time.Minute*20, updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute * 30})
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	start := time.Unix(0, 0)
+	require.Equal(t, []time.Time{start.Add(time.Minute * 30), start.Add(time.Minute * 60), start.Add(time.Minute * 90)},
+		h.activityScheduleTimes())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_TriggerNowDuringSleep() {
+	for _, tc := range []struct {
+		request  TriggerNowRequest
+		jobCount uint
+	}{
+		{TriggerNowRequest{}, 3},
+		// the manual run doesn't count, the schedule has the same runs with one job less.
+		{TriggerNowRequest{KeepJobCount: true}, 2},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		var runTimes []time.Duration
+		var pendingCounts []uint
+		env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+			runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+			pendingCounts = append(pendingCounts, input.PendingJobCount)
+			return CronJobResult{}, nil
+		})
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(triggerNowSignalName, tc.request)
+		}, time.Minute*20)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: tc.jobCount, ScheduleInterval: time.Hour},
+			&CronState{})
+
+		s.True(env.IsWorkflowCompleted(), "%+v", tc.request)
+		s.NoError(env.GetWorkflowError(), "%+v", tc.request)
+		s.Equal([]time.Duration{time.Minute * 20, time.Minute * 80, time.Minute * 140}, runTimes, "%+v", tc.request)
+		s.Equal([]uint{2, 1, 0}, pendingCounts, "%+v", tc.request)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_TriggerNowDuringActivityCoalesced() {
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		if len(runTimes) == 0 {
+			for i := 0; i < 3; i++ {
+				env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{})
+			}
+		}
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Hour, time.Hour, time.Hour * 2}, runTimes)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_TriggerNowSurvivesContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		if runs == loopCountBeforeContinueAsNew {
+			env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{KeepJobCount: true})
+		}
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+	s.Equal(ScheduleSpec{JobCount: 10, ScheduleInterval: time.Hour,
+		PendingTrigger: &TriggerNowRequest{KeepJobCount: true}, ChangeVersions: latestVersions,
+		Timeouts: Timeouts{Workflow: time.Hour * 11, Decision: decisionTimeout}}, args[0])
+}
+
+func TestReplay_CronWorkflowSignalDuringWait(t *testing.T) {
+	type signal struct {
+		after time.Duration
+		name  string
+	}
+	inputs := map[string]interface{}{
+		triggerNowSignalName:     TriggerNowRequest{},
+		pauseSignalName:          "maintenance",
+		resumeSignalName:         "done",
+		updateScheduleSignalName: ScheduleUpdate{ScheduleInterval: time.Minute * 30},
+	}
+	// the runs are due after 60 and 120 minutes without signals.
+	testCases := []struct {
+		name     string
+		signals  []signal
+		expected []time.Duration
+	}{
+		{"trigger mid wait", []signal{{time.Minute * 30, triggerNowSignalName}},
+			[]time.Duration{time.Minute * 30, time.Minute * 90}},
+		{"trigger right before expiry", []signal{{time.Hour - time.Second, triggerNowSignalName}},
+			[]time.Duration{time.Hour - time.Second, time.Hour*2 - time.Second}},
+		// the timer and the signal are in the same decision, the manual run replaces the scheduled one.
+		{"trigger at expiry", []signal{{time.Hour, triggerNowSignalName}},
+			[]time.Duration{time.Hour, time.Hour * 2}},
+		{"trigger after expiry", []signal{{time.Minute * 61, triggerNowSignalName}},
+			[]time.Duration{time.Hour, time.Minute * 61}},
+		// the pause in the decision of the timer keeps the run from starting.
+		{"pause at expiry", []signal{{time.Hour, pauseSignalName}, {time.Minute * 90, resumeSignalName}},
+			[]time.Duration{time.Minute * 150, time.Minute * 210}},
+		{"update at expiry", []signal{{time.Hour, updateScheduleSignalName}},
+			[]time.Duration{time.Hour, time.Minute * 90}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Hour}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+			for _, signal := range tc.signals {
+				h.signalAfter(signal.after, signal.name, inputs[signal.name])
+			}
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_CompleteWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			var runs []time.Duration
+			for _, run := range h.activityScheduleTimes() {
+				runs = append(runs, run.Sub(h.start))
+			}
+			require.Equal(t, tc.expected, runs)
+			// every timer of a wait that was cut short is cancelled, none is left behind for a later wait.
+			var started, closed int
+			for _, e := range h.events {
+				switch e.GetEventType() {
+				case s.EventType_TimerStarted:
+					started++
+				case s.EventType_TimerFired, s.EventType_TimerCanceled:
+					closed++
+				}
+			}
+			require.Equal(t, started, closed)
+		})
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SignalsDrainedBeforeContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		if runs == loopCountBeforeContinueAsNew {
+			// the signals arrive with the completion of the last run, in the decision that continues as new.
+			env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute})
+			env.SignalWorkflow(triggerNowSignalName, TriggerNowRequest{})
+		}
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 13, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+
+	// the next generation runs the manual run right away, and continues with the updated interval.
+	env = s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{0, time.Minute, time.Minute * 2}, runTimes)
+}
+
+func TestReplay_CronWorkflowSignalBeforeContinueAsNew(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute}
+	h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+	h.activityDuration = time.Second * 10
+	// the signals are in the decision task of the completion of the last run before continue-as-new.
+	h.signalAfter(time.Second*610, pauseSignalName, "maintenance")
+	h.signalAfter(time.Second*610, updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Hour})
+
+	closeDecision := h.run()
+	require.Equal(t, s.DecisionType_ContinueAsNewWorkflowExecution, closeDecision.GetDecisionType())
+	require.NoError(t, h.replay())
+	var next ScheduleSpec
+	input := closeDecision.GetContinueAsNewWorkflowExecutionDecisionAttributes().GetInput()
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(input)).Decode(&next))
+	require.True(t, next.Paused)
+	require.Equal(t, time.Hour, next.ScheduleInterval)
+	require.Equal(t, uint(2), next.JobCount)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SkipRun() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs, cancelled int
+	env.OverrideActivity(longRunningJobActivity, func(ctx context.Context, input CronJobInput) (CronJobResult,
+		error) {
+		runs++
+		if runs > 1 {
+			return jobResult(longJobProgress{Processed: 60, Items: 60})
+		}
+		// the first run takes 10 minutes, it is skipped after 30 seconds.
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{}, nil)
+		}, time.Minute*10)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.SetOnActivityCanceledListener(func(info *cadence.ActivityInfo) {
+		cancelled++
+	})
+	// no run is in progress yet, the first signal is ignored.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(skipRunSignalName, "too early")
+	}, time.Second*30)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(skipRunSignalName, "stuck")
+	}, time.Second*90)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute,
+		JobActivityName: longJobActivity}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	// only the activity of the run was cancelled, the schedule went on with the next runs.
+	s.Equal(1, cancelled)
+	s.Equal(loopCountBeforeContinueAsNew, runs)
+	state := continueAsNewArgs(env.GetWorkflowError())[1].(*CronState)
+	s.Equal(uint(loopCountBeforeContinueAsNew-1), state.SuccessfulRuns)
+	s.Zero(state.FailedRuns)
+	at := func(seconds int64) time.Time { return time.Unix(seconds, 0) }
+	s.Equal(RunRecord{ScheduledAt: at(60), StartedAt: at(60), CompletedAt: at(90), Status: RunSkippedBySignal,
+		ResultSummary: "skipped: stuck"}, state.RecentRuns[0])
+	s.Equal(RunSucceeded, state.RecentRuns[1].Status)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+/**
+ * A schedule with a name is started with a workflow ID derived from the name, so that starting the same schedule twice
+ * doesn't run two cron workflows side by side. This version of the client has no WorkflowIDReusePolicy, the server
+ * rejects a start while a workflow with the same ID is running, and allows it once the workflow is closed, e.g.
+ * completed, failed or terminated. The ID stays the same across continue-as-new. A schedule without a name gets a new
+ * workflow ID every time it is started.
+ *
+ * upsertSchedule makes the start idempotent: a schedule that is running already gets the updateSchedule signal with the
+ * interval and the job count of the new spec instead, the rest of the spec only applies to a new execution. This version
+ * of the client has no SignalWithStartWorkflow, so upsertSchedule starts the workflow first and signals the run that
+ * rejected the start. A run that closes in between, e.g. because it just completed or continued as new, rejects the
+ * signal, and the start is tried again.
+ */
+
+// scheduleWorkflowID returns the workflow ID of the schedule with the given name.
+func scheduleWorkflowID(name string) string {
+	return "cron_" + name
+}
+
+// startOptions returns the options of the start of the cron workflow of the spec with the given workflow ID.
+func (s *ScheduleSpec) startOptions(workflowID string) cadence.StartWorkflowOptions {
+	// the first run of the workflow gets the same timeouts as the runs after continue-as-new.
+	timeouts := s.timeouts()
+	return cadence.StartWorkflowOptions{
+		ID:                              workflowID,
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    timeouts.Workflow,
+		DecisionTaskStartToCloseTimeout: timeouts.Decision,
+	}
+}
+
+// upsertAttempts is how often upsertSchedule starts the workflow when the running run closes before it is signalled.
+const upsertAttempts = 3
+
+// errScheduleRunning is returned when the workflow of a schedule is already running.
+type errScheduleRunning struct {
+	WorkflowID string
+	RunID      string
+}
+
+func (e errScheduleRunning) Error() string {
+	return fmt.Sprintf("schedule workflow %s is already running with run ID %s", e.WorkflowID, e.RunID)
+}
+
+// startSchedule starts the cron workflow with the given options. If the workflow is already running, startSchedule
+// returns errScheduleRunning, or terminates the running workflow and starts a new one if replace is true.
+func startSchedule(client cadence.Client, options cadence.StartWorkflowOptions, replace bool, spec ScheduleSpec,
+	state *CronState) (*cadence.WorkflowExecution, error) {
+	we, err := client.StartWorkflow(options, SampleCronWorkflow, spec, state)
+	running, ok := err.(*shared.WorkflowExecutionAlreadyStartedError)
+	if !ok {
+		return we, err
+	}
+	if !replace {
+		return nil, errScheduleRunning{WorkflowID: options.ID, RunID: running.GetRunId()}
+	}
+	// the running workflow gets no chance to clean up, unlike a cancelled one.
+	if err := client.TerminateWorkflow(options.ID, running.GetRunId(), "replaced by a new start", nil); err != nil {
+		return nil, err
+	}
+	return client.StartWorkflow(options, SampleCronWorkflow, spec, state)
+}
+
+// triggerSchedule starts the cron workflow of the spec with the given options. A running workflow of the schedule is
+// terminated first if replace is true, or signalled the new schedule if upsert is true.
+func triggerSchedule(client cadence.Client, options cadence.StartWorkflowOptions, replace, upsert bool,
+	spec ScheduleSpec) (triggerResult, error) {
+	// the state of a new schedule is empty, a nil pointer can't be encoded as workflow input.
+	state := &CronState{}
+	var we *cadence.WorkflowExecution
+	started := true
+	var err error
+	if upsert {
+		we, started, err = upsertSchedule(client, options, spec, state)
+	} else {
+		we, err = startSchedule(client, options, replace, spec, state)
+	}
+	if err != nil {
+		return triggerResult{}, err
+	}
+	return triggerResult{WorkflowID: we.ID, RunID: we.RunID, AlreadyRunning: !started, TraceID: spec.TraceID}, nil
+}
+
+// upsertSchedule starts the cron workflow with the given options, or signals the new schedule to the run that is running
+// already. It returns the execution of the run and whether it was started.
+func upsertSchedule(client cadence.Client, options cadence.StartWorkflowOptions, spec ScheduleSpec,
+	state *CronState) (*cadence.WorkflowExecution, bool, error) {
+	update := ScheduleUpdate{ScheduleInterval: spec.ScheduleInterval, JobCount: spec.JobCount}
+	if err := update.validate(); err != nil {
+		return nil, false, err
+	}
+	for attempt := 0; attempt < upsertAttempts; attempt++ {
+		we, err := client.StartWorkflow(options, SampleCronWorkflow, spec, state)
+		running, ok := err.(*shared.WorkflowExecutionAlreadyStartedError)
+		if !ok {
+			return we, err == nil, err
+		}
+		err = client.SignalWorkflow(options.ID, running.GetRunId(), updateScheduleSignalName, update)
+		if _, ok := err.(*shared.EntityNotExistsError); ok {
+			// the run closed after it rejected the start.
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return &cadence.WorkflowExecution{ID: options.ID, RunID: running.GetRunId()}, false, nil
+	}
+	return nil, false, errors.New("schedule workflow " + options.ID + " closed after every start was rejected")
+}
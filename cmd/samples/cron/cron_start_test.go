@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+// fakeClient is a cadence.Client that keeps the run IDs of the running workflows, it panics on the methods that
+// startSchedule doesn't call.
+type fakeClient struct {
+	cadence.Client
+	running    map[string]string
+	starts     int
+	terminated []string
+	signals    []signalCall
+	// beforeSignal is called before a signal is delivered, e.g. to close the run.
+	beforeSignal func()
+}
+
+func (c *fakeClient) SignalWorkflow(workflowID string, runID string, signalName string, arg interface{}) error {
+	if c.beforeSignal != nil {
+		c.beforeSignal()
+	}
+	// an empty run ID signals the current run.
+	if current, ok := c.running[workflowID]; !ok || (runID != "" && current != runID) {
+		return &shared.EntityNotExistsError{Message: "workflow not running"}
+	}
+	c.signals = append(c.signals, signalCall{workflowID, runID, signalName, arg})
+	return nil
+}
+
+func (c *fakeClient) StartWorkflow(options cadence.StartWorkflowOptions, workflow interface{},
+	args ...interface{}) (*cadence.WorkflowExecution, error) {
+	if runID, ok := c.running[options.ID]; ok {
+		return nil, &shared.WorkflowExecutionAlreadyStartedError{RunId: &runID}
+	}
+	c.starts++
+	c.running[options.ID] = fmt.Sprintf("run-%d", c.starts)
+	return &cadence.WorkflowExecution{ID: options.ID, RunID: c.running[options.ID]}, nil
+}
+
+func (c *fakeClient) TerminateWorkflow(workflowID string, runID string, reason string, details []byte) error {
+	if c.running[workflowID] != runID {
+		return &shared.EntityNotExistsError{Message: "workflow not running"}
+	}
+	delete(c.running, workflowID)
+	c.terminated = append(c.terminated, runID)
+	return nil
+}
+
+func (s *UnitTestSuite) Test_StartSchedule_AlreadyRunning() {
+	client := &fakeClient{running: make(map[string]string)}
+	options := cadence.StartWorkflowOptions{ID: scheduleWorkflowID("nightly-report")}
+	we, err := startSchedule(client, options, false, ScheduleSpec{Name: "nightly-report"}, &CronState{})
+	s.NoError(err)
+	s.Equal(cadence.WorkflowExecution{ID: "cron_nightly-report", RunID: "run-1"}, *we)
+
+	_, err = startSchedule(client, options, false, ScheduleSpec{Name: "nightly-report"}, &CronState{})
+	s.Equal(errScheduleRunning{WorkflowID: "cron_nightly-report", RunID: "run-1"}, err)
+	s.Equal(1, client.starts)
+	s.Empty(client.terminated)
+
+	// a closed workflow can be started again.
+	delete(client.running, options.ID)
+	we, err = startSchedule(client, options, false, ScheduleSpec{Name: "nightly-report"}, &CronState{})
+	s.NoError(err)
+	s.Equal("run-2", we.RunID)
+}
+
+func (s *UnitTestSuite) Test_StartSchedule_Replace() {
+	client := &fakeClient{running: map[string]string{"cron_nightly-report": "run-0"}}
+	options := cadence.StartWorkflowOptions{ID: scheduleWorkflowID("nightly-report")}
+	we, err := startSchedule(client, options, true, ScheduleSpec{Name: "nightly-report"}, &CronState{})
+	s.NoError(err)
+	s.Equal("run-1", we.RunID)
+	s.Equal([]string{"run-0"}, client.terminated)
+
+	// nothing is terminated if the schedule isn't running.
+	client = &fakeClient{running: make(map[string]string)}
+	_, err = startSchedule(client, options, true, ScheduleSpec{Name: "nightly-report"}, &CronState{})
+	s.NoError(err)
+	s.Empty(client.terminated)
+}
+
+func (s *UnitTestSuite) Test_UpsertSchedule() {
+	client := &fakeClient{running: make(map[string]string)}
+	options := cadence.StartWorkflowOptions{ID: scheduleWorkflowID("nightly-report")}
+	spec := ScheduleSpec{Name: "nightly-report", JobCount: 3, ScheduleInterval: time.Hour}
+	we, started, err := upsertSchedule(client, options, spec, &CronState{})
+	s.NoError(err)
+	s.True(started)
+	s.Equal(cadence.WorkflowExecution{ID: "cron_nightly-report", RunID: "run-1"}, *we)
+	s.Empty(client.signals)
+
+	// the running schedule gets the new interval and job count.
+	spec.ScheduleInterval, spec.JobCount = time.Minute, 5
+	we, started, err = upsertSchedule(client, options, spec, &CronState{})
+	s.NoError(err)
+	s.False(started)
+	s.Equal(cadence.WorkflowExecution{ID: "cron_nightly-report", RunID: "run-1"}, *we)
+	s.Equal([]signalCall{{"cron_nightly-report", "run-1", updateScheduleSignalName,
+		ScheduleUpdate{ScheduleInterval: time.Minute, JobCount: 5}}}, client.signals)
+	s.Equal(1, client.starts)
+
+	_, _, err = upsertSchedule(client, options, ScheduleSpec{Name: "nightly-report", JobCount: 1}, &CronState{})
+	s.EqualError(err, "schedule interval must be positive, got 0s")
+}
+
+func (s *UnitTestSuite) Test_UpsertSchedule_RunJustCompleted() {
+	client := &fakeClient{running: map[string]string{"cron_nightly-report": "run-0"}}
+	// the run completes after it rejected the start, before the signal arrives.
+	client.beforeSignal = func() {
+		delete(client.running, "cron_nightly-report")
+	}
+	options := cadence.StartWorkflowOptions{ID: scheduleWorkflowID("nightly-report")}
+	spec := ScheduleSpec{Name: "nightly-report", JobCount: 3, ScheduleInterval: time.Hour}
+	we, started, err := upsertSchedule(client, options, spec, &CronState{})
+	s.NoError(err)
+	s.True(started)
+	s.Equal("run-1", we.RunID)
+	s.Empty(client.signals)
+
+	// a run that keeps closing, e.g. continuing as new, gives up after a few attempts.
+	continued := 0
+	client.beforeSignal = func() {
+		continued++
+		client.running["cron_nightly-report"] = fmt.Sprintf("continued-%d", continued)
+	}
+	_, _, err = upsertSchedule(client, options, spec, &CronState{})
+	s.EqualError(err, "schedule workflow cron_nightly-report closed after every start was rejected")
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_UpdateScheduleAtStart() {
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	// the signal of an upsert can arrive before the first decision.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute, JobCount: 2})
+	}, 0)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Minute, time.Minute * 2}, runTimes)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * A schedule that closes returns a CronSummary of all its runs, across the runs of the workflow that continued as new.
+ * The counters are carried over continue-as-new in the CronState and the ScheduleSpec, the StartTime of the state is
+ * the start of the first run of the workflow. The workflow passes the summary to reportSummaryActivity, which could
+ * ship it to a dashboard or a mail, the sample logs it.
+ *
+ * A schedule that completed its runs, or reached its deadline, returns the summary as the result of the workflow. A
+ * drained schedule completes too, with the summary as of the drain. A cancelled schedule closes as cancelled, its
+ * summary is the details of the CanceledError, recorded in the WorkflowExecutionCanceled event. A schedule that failed
+ * has no summary, the error of the failed run is its result.
+ *
+ * This version of the client has no WorkflowRun whose Get waits for the result, the starter polls the history of the
+ * workflow with waitForSummary instead, and follows it across continue-as-new.
+ */
+
+// The statuses of a CronSummary.
+const (
+	summaryCompleted       = "Completed"
+	summaryDeadlineReached = "DeadlineReached"
+	summaryDrained         = "Drained"
+	summaryCancelled       = "Cancelled"
+)
+
+// summaryPollInterval is how often waitForSummary gets the history of the workflow.
+var summaryPollInterval = time.Second * 2
+
+// CronSummary is the result of SampleCronWorkflow, it summarizes all runs of the schedule.
+type CronSummary struct {
+	Status         string
+	TotalRuns      uint
+	SuccessfulRuns uint
+	FailedRuns     uint
+	// SkippedRuns are the scheduled runs that didn't run or were skipped: the runs skipped due to the Exclusions, by
+	// the OverlapPolicy, the DailyBudget or the skipRun signal, and the missed runs that were not backfilled.
+	SkippedRuns uint
+	// FirstRunStarted and LastRunStarted are the start times of the first and the last run, zero without runs.
+	FirstRunStarted time.Time
+	LastRunStarted  time.Time
+	// Duration is the time from the start of the first run of the workflow until it closed.
+	Duration time.Duration
+	// FailedItems are the JobInputs whose runs failed, see cron_inputs.go.
+	FailedItems []FailedItem
+}
+
+// reportsSummary returns true if the schedule reports its summary, runs started before the change don't.
+func (s *ScheduleSpec) reportsSummary() bool {
+	return s.getVersion(changeAddSummaryReport, DefaultVersion, 1) >= 1
+}
+
+// onRunStarted records the start of a run in the state.
+func (s *CronState) onRunStarted(startTime time.Time) {
+	if s.FirstRunStarted.IsZero() {
+		s.FirstRunStarted = startTime
+	}
+	s.LastRunStarted = startTime
+}
+
+// summarize returns the summary of the schedule as of now.
+func summarize(ctx cadence.Context, spec *ScheduleSpec, state *CronState, status string) CronSummary {
+	skipped := spec.SkippedByExclusions + spec.SkippedByOverlap + spec.MissedRuns + state.SkippedBySignal +
+		state.SkippedByBudget
+	return CronSummary{
+		Status:          status,
+		TotalRuns:       state.TotalRuns,
+		SuccessfulRuns:  state.SuccessfulRuns,
+		FailedRuns:      state.FailedRuns,
+		SkippedRuns:     skipped,
+		FirstRunStarted: state.FirstRunStarted,
+		LastRunStarted:  state.LastRunStarted,
+		Duration:        cadence.Now(ctx).Sub(state.StartTime),
+		FailedItems:     state.FailedItems,
+	}
+}
+
+// closeSchedule summarizes the schedule that closes with the given status, and reports the summary. A failed report
+// doesn't fail the schedule, the summary is its result either way.
+func closeSchedule(ctx cadence.Context, spec *ScheduleSpec, state *CronState, status string) CronSummary {
+	if spec.DeadLetter != nil && len(state.DeadLetters) > 0 {
+		// there is no next run to deliver the buffered dead letters after.
+		deliverBufferedDeadLetters(ctx, spec.DeadLetter, state, newHistoryEstimate())
+	}
+	summary := summarize(ctx, spec, state, status)
+	workflowLogger(ctx).Info("Cron workflow closing.", zap.String("Status", status),
+		zap.Uint("TotalRuns", summary.TotalRuns), zap.Uint("SuccessfulRuns", summary.SuccessfulRuns),
+		zap.Uint("FailedRuns", summary.FailedRuns), zap.Uint("SkippedRuns", summary.SkippedRuns),
+		zap.Duration("Duration", summary.Duration))
+	if !spec.reportsSummary() {
+		return summary
+	}
+	if err := cadence.ExecuteActivity(ctx, reportSummaryActivity, summary).Get(ctx, nil); err != nil {
+		workflowLogger(ctx).Error("Cron workflow summary report failed.", zap.Error(err))
+	}
+	return summary
+}
+
+// reportSummaryActivity reports the summary of a schedule that closed.
+func reportSummaryActivity(ctx context.Context, summary CronSummary) error {
+	// ...
+	activityLogger(ctx).Info("Cron schedule summary.", zap.String("Status", summary.Status),
+		zap.Uint("TotalRuns", summary.TotalRuns), zap.Uint("SuccessfulRuns", summary.SuccessfulRuns),
+		zap.Uint("FailedRuns", summary.FailedRuns), zap.Uint("SkippedRuns", summary.SkippedRuns),
+		zap.Time("FirstRunStarted", summary.FirstRunStarted), zap.Time("LastRunStarted", summary.LastRunStarted),
+		zap.Duration("Duration", summary.Duration), zap.Int("FailedItems", len(summary.FailedItems)))
+	return nil
+}
+
+// waitForSummary waits until the schedule of the given run closes, and returns its summary. It follows the runs the
+// workflow continues as new with, and fails if the schedule closes without a summary, e.g. because it failed.
+func waitForSummary(client cadence.Client, workflowID, runID string) (CronSummary, error) {
+	for {
+		history, err := client.GetWorkflowHistory(workflowID, runID)
+		if err != nil {
+			return CronSummary{}, err
+		}
+		if len(history.Events) == 0 {
+			return CronSummary{}, fmt.Errorf("workflow %s has no history", workflowID)
+		}
+		last := history.Events[len(history.Events)-1]
+		var summary CronSummary
+		switch last.GetEventType() {
+		case shared.EventType_WorkflowExecutionContinuedAsNew:
+			runID = last.GetWorkflowExecutionContinuedAsNewEventAttributes().GetNewExecutionRunId_()
+			continue
+		case shared.EventType_WorkflowExecutionCompleted:
+			result := last.GetWorkflowExecutionCompletedEventAttributes().GetResult_()
+			return summary, cadence.EncodedValue(result).Get(&summary)
+		case shared.EventType_WorkflowExecutionCanceled:
+			details := last.GetWorkflowExecutionCanceledEventAttributes().GetDetails()
+			return summary, cadence.EncodedValues(details).Get(&summary)
+		case shared.EventType_WorkflowExecutionFailed:
+			attributes := last.GetWorkflowExecutionFailedEventAttributes()
+			return summary, fmt.Errorf("workflow %s failed: %s %s", workflowID, attributes.GetReason(),
+				attributes.GetDetails())
+		case shared.EventType_WorkflowExecutionTimedOut, shared.EventType_WorkflowExecutionTerminated:
+			return summary, fmt.Errorf("workflow %s closed without a summary: %v", workflowID, last.GetEventType())
+		}
+		time.Sleep(summaryPollInterval)
+	}
+}
+
+// printSummary prints the summary of a schedule as a table.
+func printSummary(out io.Writer, summary CronSummary) {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STATUS\t%s\n", summary.Status)
+	fmt.Fprintf(w, "RUNS\t%d\n", summary.TotalRuns)
+	fmt.Fprintf(w, "SUCCEEDED\t%d\n", summary.SuccessfulRuns)
+	fmt.Fprintf(w, "FAILED\t%d\n", summary.FailedRuns)
+	fmt.Fprintf(w, "SKIPPED\t%d\n", summary.SkippedRuns)
+	fmt.Fprintf(w, "FIRST RUN\t%s\n", formatTime(summary.FirstRunStarted))
+	fmt.Fprintf(w, "LAST RUN\t%s\n", formatTime(summary.LastRunStarted))
+	fmt.Fprintf(w, "DURATION\t%v\n", summary.Duration)
+	if len(summary.FailedItems) > 0 {
+		indexes := make([]string, len(summary.FailedItems))
+		for i, item := range summary.FailedItems {
+			indexes[i] = fmt.Sprint(item.Index)
+		}
+		fmt.Fprintf(w, "FAILED ITEMS\t%s\n", strings.Join(indexes, ", "))
+	}
+	w.Flush()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/common"
+)
+
+// historyClient is a cadence.Client that returns the last events of the runs, one per poll, the last one repeats. A
+// nil event is a run that is still running.
+type historyClient struct {
+	cadence.Client
+	histories map[string][]*shared.HistoryEvent
+	polls     []string
+}
+
+func (c *historyClient) GetWorkflowHistory(workflowID string, runID string) (*shared.History, error) {
+	events := c.histories[runID]
+	var poll int
+	for _, polled := range c.polls {
+		if polled == runID {
+			poll++
+		}
+	}
+	c.polls = append(c.polls, runID)
+	if poll >= len(events) {
+		poll = len(events) - 1
+	}
+	event := events[poll]
+	if event == nil {
+		event = &shared.HistoryEvent{EventType: eventTypePtr(shared.EventType_WorkflowExecutionStarted)}
+	}
+	return &shared.History{Events: []*shared.HistoryEvent{event}}, nil
+}
+
+func eventTypePtr(eventType shared.EventType) *shared.EventType {
+	return &eventType
+}
+
+// withSummaryPollInterval makes waitForSummary poll right away, and returns the function that restores the interval.
+func withSummaryPollInterval() func() {
+	original := summaryPollInterval
+	summaryPollInterval = time.Millisecond
+	return func() { summaryPollInterval = original }
+}
+
+var testSummary = CronSummary{Status: summaryCompleted, TotalRuns: 13, SuccessfulRuns: 12, FailedRuns: 1,
+	SkippedRuns: 2, FirstRunStarted: listStart, LastRunStarted: listStart.Add(time.Hour * 12),
+	Duration: time.Hour*12 + time.Minute}
+
+func Test_WaitForSummary(t *testing.T) {
+	defer withSummaryPollInterval()()
+	client := &historyClient{histories: map[string][]*shared.HistoryEvent{
+		"run-1": {nil, {
+			EventType: eventTypePtr(shared.EventType_WorkflowExecutionContinuedAsNew),
+			WorkflowExecutionContinuedAsNewEventAttributes: &shared.WorkflowExecutionContinuedAsNewEventAttributes{
+				NewExecutionRunId_: common.StringPtr("run-2")},
+		}},
+		"run-2": {nil, nil, {
+			EventType: eventTypePtr(shared.EventType_WorkflowExecutionCompleted),
+			WorkflowExecutionCompletedEventAttributes: &shared.WorkflowExecutionCompletedEventAttributes{
+				Result_: encodeValues(t, testSummary)},
+		}},
+	}}
+	summary, err := waitForSummary(client, "cron_1", "run-1")
+	require.NoError(t, err)
+	require.Equal(t, testSummary, summary)
+	// the starter followed the run the workflow continued as new with.
+	require.Equal(t, []string{"run-1", "run-1", "run-2", "run-2", "run-2"}, client.polls)
+}
+
+func Test_WaitForSummary_Cancelled(t *testing.T) {
+	cancelled := testSummary
+	cancelled.Status = summaryCancelled
+	client := &historyClient{histories: map[string][]*shared.HistoryEvent{"run-1": {{
+		EventType: eventTypePtr(shared.EventType_WorkflowExecutionCanceled),
+		WorkflowExecutionCanceledEventAttributes: &shared.WorkflowExecutionCanceledEventAttributes{
+			Details: encodeValues(t, cancelled)},
+	}}}}
+	summary, err := waitForSummary(client, "cron_1", "run-1")
+	require.NoError(t, err)
+	require.Equal(t, cancelled, summary)
+}
+
+func Test_WaitForSummary_NoSummary(t *testing.T) {
+	for _, event := range []*shared.HistoryEvent{{
+		EventType: eventTypePtr(shared.EventType_WorkflowExecutionFailed),
+		WorkflowExecutionFailedEventAttributes: &shared.WorkflowExecutionFailedEventAttributes{
+			Reason: common.StringPtr("cron job failed")},
+	}, {
+		EventType: eventTypePtr(shared.EventType_WorkflowExecutionTerminated),
+	}, {
+		EventType: eventTypePtr(shared.EventType_WorkflowExecutionTimedOut),
+	}} {
+		client := &historyClient{histories: map[string][]*shared.HistoryEvent{"run-1": {event}}}
+		_, err := waitForSummary(client, "cron_1", "run-1")
+		require.Error(t, err, event.GetEventType().String())
+	}
+}
+
+func Test_PrintSummary(t *testing.T) {
+	var out bytes.Buffer
+	printSummary(&out, testSummary)
+	require.Equal(t, "STATUS     Completed\n"+
+		"RUNS       13\n"+
+		"SUCCEEDED  12\n"+
+		"FAILED     1\n"+
+		"SKIPPED    2\n"+
+		"FIRST RUN  2023-06-01T08:00:00Z\n"+
+		"LAST RUN   2023-06-01T20:00:00Z\n"+
+		"DURATION   12h1m0s\n", out.String())
+
+	out.Reset()
+	printSummary(&out, CronSummary{Status: summaryDeadlineReached})
+	require.Contains(t, out.String(), "FIRST RUN  -\n")
+	require.NotContains(t, out.String(), "FAILED ITEMS")
+
+	// the failed items of a list are listed by their index.
+	out.Reset()
+	printSummary(&out, CronSummary{Status: summaryCompleted, FailedItems: []FailedItem{{Index: 1}, {Index: 11}}})
+	require.Contains(t, out.String(), "FAILED ITEMS  1, 11\n")
+}
+
+// reportedSummaries records the summaries reportSummaryActivity is called with.
+func reportedSummaries(env *cadence.TestWorkflowEnvironment) *[]CronSummary {
+	var summaries []CronSummary
+	env.OverrideActivity(reportSummaryActivity, func(ctx context.Context, summary CronSummary) error {
+		summaries = append(summaries, summary)
+		return nil
+	})
+	return &summaries
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SummaryAcrossContinueAsNew() {
+	spec := ScheduleSpec{JobCount: 13, ScheduleInterval: time.Hour, FailurePolicy: FailureContinue}
+	spec.withLatestVersions()
+	env := s.NewTestWorkflowEnvironment()
+	failingRuns(env, 2)
+	reports := reportedSummaries(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	// a run that continues as new doesn't close the schedule.
+	s.Empty(*reports)
+	args := continueAsNewArgs(env.GetWorkflowError())
+	state := args[1].(*CronState)
+	s.Equal(time.Unix(0, 0), state.StartTime)
+	s.Equal(time.Unix(0, 0).Add(time.Hour), state.FirstRunStarted)
+	// the clock of every test environment starts at the epoch, the times of the state are moved back by the time the
+	// first run of the workflow took.
+	elapsed := env.Now().Sub(time.Unix(0, 0))
+	state.StartTime = state.StartTime.Add(-elapsed)
+	state.FirstRunStarted = state.FirstRunStarted.Add(-elapsed)
+
+	env = s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	reports = reportedSummaries(env)
+	env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var summary CronSummary
+	s.NoError(env.GetWorkflowResult(&summary))
+	s.Equal(CronSummary{Status: summaryCompleted, TotalRuns: 13, SuccessfulRuns: 12, FailedRuns: 1,
+		FirstRunStarted: time.Unix(0, 0).Add(time.Hour - elapsed), LastRunStarted: time.Unix(0, 0).Add(time.Hour * 3),
+		Duration: elapsed + time.Hour*3}, summary)
+	s.Equal([]CronSummary{summary}, *reports)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SummaryStatus() {
+	for _, tc := range []struct {
+		name    string
+		spec    ScheduleSpec
+		drainAt time.Duration
+		summary CronSummary
+	}{
+		{
+			// the runs of the first day are skipped, the first run is at midnight.
+			name: "deadline and skipped",
+			spec: ScheduleSpec{JobCount: 10, ScheduleInterval: time.Hour,
+				NotAfter: time.Unix(0, 0).Add(time.Minute * 1470), Exclusions: Exclusions{Dates: []string{"1970-01-01"}},
+				// the excluded first day is longer than the default workflow timeout of the interval.
+				Timeouts: Timeouts{Workflow: time.Hour * 48}},
+			summary: CronSummary{Status: summaryDeadlineReached, TotalRuns: 1, SuccessfulRuns: 1, SkippedRuns: 23,
+				FirstRunStarted: time.Unix(0, 0).Add(time.Hour * 24), LastRunStarted: time.Unix(0, 0).Add(time.Hour * 24),
+				Duration: time.Minute * 1470},
+		},
+		{
+			name:    "drained",
+			spec:    ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour},
+			drainAt: time.Minute * 90,
+			summary: CronSummary{Status: summaryDrained, TotalRuns: 1, SuccessfulRuns: 1,
+				FirstRunStarted: time.Unix(0, 0).Add(time.Hour), LastRunStarted: time.Unix(0, 0).Add(time.Hour),
+				Duration: time.Minute * 90},
+		},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+		reports := reportedSummaries(env)
+		if tc.drainAt > 0 {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(drainSignalName, "decommission")
+			}, tc.drainAt)
+		}
+		tc.spec.withLatestVersions()
+		env.ExecuteWorkflow(SampleCronWorkflow, tc.spec, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.NoError(env.GetWorkflowError(), tc.name)
+		var summary CronSummary
+		s.NoError(env.GetWorkflowResult(&summary), tc.name)
+		s.Equal(tc.summary, summary, tc.name)
+		s.Equal([]CronSummary{summary}, *reports, tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SummaryCancelled() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	reports := reportedSummaries(env)
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Second*90)
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	cancelled, ok := env.GetWorkflowError().(cadence.CanceledError)
+	s.True(ok, "expected a CanceledError, got %v", env.GetWorkflowError())
+	// the summary of a cancelled schedule is in the details of the error.
+	var summary CronSummary
+	cancelled.Details(&summary)
+	at := time.Unix(0, 0).Add(time.Minute)
+	s.Equal(CronSummary{Status: summaryCancelled, TotalRuns: 1, SuccessfulRuns: 1, FirstRunStarted: at,
+		LastRunStarted: at, Duration: time.Second * 90}, summary)
+	s.Equal([]CronSummary{summary}, *reports)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_NoSummaryReportBeforeChange() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	reports := reportedSummaries(env)
+	// a spec without versions is the input of a run started before the change, it still returns its summary.
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var summary CronSummary
+	s.NoError(env.GetWorkflowResult(&summary))
+	s.Equal(uint(2), summary.SuccessfulRuns)
+	s.Empty(*reports)
+}
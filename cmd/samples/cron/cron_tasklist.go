@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/cadence"
+)
+
+/**
+ * The job activities of a schedule can be routed to the workers of an environment, e.g. staging or prod, that poll a
+ * task list of their own. The TaskList of the spec is the task list of its job activities, and the TaskList of a job
+ * of a schedule with Jobs wins over it for the runs of the job. Without either, the job activities go to the
+ * ActivityTaskList of the schedule like its other activities, the recording, the locks and the dead letters. The
+ * activity workers of the runner poll the task lists given with -pollTaskLists besides their own, one worker each.
+ *
+ * A job activity on a task list no worker polls is never picked up, it fails with a ScheduleToStart timeout once its
+ * retries are exhausted. The run records a noWorkerError naming the task list instead of the bare timeout, so that a
+ * misrouted schedule doesn't look like a slow worker.
+ */
+
+// noWorkerError is the error of a run whose job activity timed out before any worker of its task list picked it up.
+type noWorkerError struct {
+	taskList string
+	err      error
+}
+
+func (e *noWorkerError) Error() string {
+	return fmt.Sprintf("no worker polling task list %s: %v", e.taskList, e.err)
+}
+
+// validateTaskList checks a task list of the job activities, the given field names it in the error.
+func validateTaskList(field, taskList string) error {
+	if taskList == "" {
+		return nil
+	}
+	if strings.TrimSpace(taskList) == "" {
+		return fmt.Errorf("%s must not be blank when set", field)
+	}
+	if strings.HasPrefix(taskList, ApplicationName+"_") {
+		return fmt.Errorf("%s %s is the task list of a host, pick a name without the prefix %s_", field, taskList,
+			ApplicationName)
+	}
+	return nil
+}
+
+// validateTaskLists checks the task lists of the job activities of the spec and of its jobs.
+func (s *ScheduleSpec) validateTaskLists() error {
+	if err := validateTaskList("task list", s.TaskList); err != nil {
+		return err
+	}
+	for _, job := range s.Jobs {
+		if err := validateTaskList(fmt.Sprintf("task list of job %q", job.Name), job.TaskList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobTaskList returns the task list of the job activities of the given job, nil for a schedule without Jobs. Empty
+// means the task list of the context, the ActivityTaskList.
+func (s *ScheduleSpec) jobTaskList(job *JobSpec) string {
+	if job != nil && job.TaskList != "" {
+		return job.TaskList
+	}
+	return s.TaskList
+}
+
+// jobOptionsKey is the key of the jobOptions of the context of a run.
+type jobOptionsKey struct{}
+
+// jobOptions are the activity options of the job activities of a run, and the ones of the workflow.
+type jobOptions struct {
+	job      cadence.ActivityOptions
+	workflow cadence.ActivityOptions
+}
+
+// withJobTaskList returns the context of a run of the spec whose job activities are scheduled on the given task list,
+// empty means the ActivityTaskList.
+func (s *ScheduleSpec) withJobTaskList(ctx cadence.Context, taskList string) cadence.Context {
+	options := jobOptions{job: s.activityOptions(), workflow: s.activityOptions()}
+	if taskList != "" {
+		options.job.TaskList = taskList
+	}
+	return cadence.WithValue(ctx, jobOptionsKey{}, options)
+}
+
+// executeJobActivity executes a job activity, or the host pick, with the job options of the context of its run,
+// changed for this execution by the given function if it isn't nil. The contexts of a workflow share one set of
+// activity options in this version of the client, a cadence.WithTaskList on any of them moves every later activity of
+// the workflow. The options are set right before the activity is scheduled and reset to the ones of the workflow right
+// after, before another coroutine can schedule an activity.
+func executeJobActivity(ctx cadence.Context, change func(*cadence.ActivityOptions), activity interface{},
+	args ...interface{}) cadence.Future {
+	options, ok := ctx.Value(jobOptionsKey{}).(jobOptions)
+	if !ok {
+		return cadence.ExecuteActivity(ctx, activity, args...)
+	}
+	job := options.job
+	if change != nil {
+		change(&job)
+	}
+	future := cadence.ExecuteActivity(cadence.WithActivityOptions(ctx, job), activity, args...)
+	cadence.WithActivityOptions(ctx, options.workflow)
+	return future
+}
+
+// classifyNoWorker returns a noWorkerError for a ScheduleToStart timeout of a job activity on the given task list of
+// the spec, and the error as it is otherwise.
+func (s *ScheduleSpec) classifyNoWorker(ctx cadence.Context, taskList string, err error) error {
+	if err == nil || !scheduleToStartTimedOut(err) {
+		return err
+	}
+	if taskList == "" {
+		taskList = s.ActivityTaskList
+	}
+	if taskList == "" {
+		taskList = cadence.GetWorkflowInfo(ctx).TaskListName
+	}
+	return &noWorkerError{taskList: taskList, err: err}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func Test_CronWorkflow_TaskList(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	// the job activity can only execute on the task list of the environment, the recording on the activity task list.
+	env.SetActivityTaskList("staging", sampleCronActivity)
+	env.SetActivityTaskList("cron-activities", recordCronResultActivity)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(4)
+	env.OnActivity(recordCronResultActivity, mock.Anything, mock.Anything).Return(nil).Times(2)
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute, Parallelism: 2, FailurePolicy: FailureContinue,
+		ActivityTaskList: "cron-activities", TaskList: "staging"}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	// the host of a run is picked on the task list of the environment, the activities after the run stay on theirs.
+	env = suite.NewTestWorkflowEnvironment()
+	env.SetActivityTaskList("staging", pickHostActivity)
+	env.SetActivityTaskList("cronGroup_host1", sampleCronActivity)
+	env.SetActivityTaskList("cron-activities", recordCronResultActivity)
+	env.OnActivity(pickHostActivity, mock.Anything).Return("cronGroup_host1", nil).Times(2)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(4)
+	env.OnActivity(recordCronResultActivity, mock.Anything, mock.Anything).Return(nil).Times(2)
+	spec.HostAffinity = &HostAffinitySpec{}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	// a job has its own task list or the one of the spec.
+	for _, c := range []struct {
+		taskList, jobTaskList string
+	}{{"prod", "prod"}, {"staging", ""}} {
+		env = suite.NewTestWorkflowEnvironment()
+		env.SetActivityTaskList(c.taskList, sampleCronActivity)
+		env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(2)
+		env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 2, TaskList: "staging",
+			Jobs: []JobSpec{{Name: "report", Interval: time.Minute, TaskList: c.jobTaskList}}}, &CronState{})
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	}
+}
+
+func Test_CronWorkflow_NoWorkerPollingTaskList(t *testing.T) {
+	timeout, restore := withScheduleToStartTimeouts()
+	defer restore()
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		if input.Run.Sequence == 1 {
+			return CronJobResult{}, timeout
+		}
+		return CronJobResult{}, errors.New("disk full")
+	})
+	records := recordedRuns(env)
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute, FailurePolicy: FailureContinue,
+		TaskList: "staging"}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Len(t, *records, 2)
+	// the timeout before any worker picked up the activity names the task list, other errors stay as they are.
+	require.Equal(t, "1 of 1 shards failed [0], first error: no worker polling task list staging: "+timeout.Error(),
+		(*records)[0].Error)
+	require.Equal(t, "1 of 1 shards failed [0], first error: disk full", (*records)[1].Error)
+
+	// the task list of a job without one is the activity task list, or the one of the workflow.
+	env = suite.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		return CronJobResult{}, timeout
+	})
+	records = recordedRuns(env)
+	spec = ScheduleSpec{JobCount: 1, FailurePolicy: FailureContinue, ActivityTaskList: "cron-activities",
+		Jobs: []JobSpec{{Name: "report", Interval: time.Minute}}}
+	spec.withLatestVersions()
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Len(t, *records, 1)
+	require.Equal(t, "no worker polling task list cron-activities: "+timeout.Error(), (*records)[0].Error)
+}
+
+func Test_ScheduleSpec_ClassifyNoWorker(t *testing.T) {
+	timeout, restore := withScheduleToStartTimeouts()
+	defer restore()
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var classified []error
+	env.ExecuteWorkflow(func(ctx cadence.Context) error {
+		spec := ScheduleSpec{}
+		// the test environment fails the activity with an error of the reason of the timeout.
+		timedOut := cadence.NewErrorWithDetails(timeout.Error())
+		classified = append(classified, spec.classifyNoWorker(ctx, "", timedOut),
+			spec.classifyNoWorker(ctx, "staging", errors.New("disk full")), spec.classifyNoWorker(ctx, "staging", nil))
+		return nil
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.IsType(t, &noWorkerError{}, classified[0])
+	require.Equal(t, "no worker polling task list default-test-tasklist: "+timeout.Error(), classified[0].Error())
+	require.EqualError(t, classified[1], "disk full")
+	require.NoError(t, classified[2])
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+// Timeouts are the timeouts of the cron workflow and of the activities of its runs. Zero fields use the defaults.
+type Timeouts struct {
+	// ScheduleToStart is how long an activity may wait for a worker.
+	ScheduleToStart time.Duration
+	// StartToClose is how long an attempt of an activity may take once a worker picked it up.
+	StartToClose time.Duration
+	// ScheduleToClose caps ScheduleToStart and StartToClose together. Zero means their sum.
+	ScheduleToClose time.Duration
+	// Heartbeat is how long an activity may go without heartbeating before it is considered lost.
+	Heartbeat time.Duration
+	// Workflow is the execution timeout of a run of the workflow, it has to cover all the job runs until the next
+	// continue-as-new.
+	Workflow time.Duration
+	// Decision is the timeout of a decision task of the workflow.
+	Decision time.Duration
+}
+
+// timeouts returns the timeouts of the spec with the defaults applied.
+func (s *ScheduleSpec) timeouts() Timeouts {
+	t := s.Timeouts
+	if t.ScheduleToStart == 0 {
+		t.ScheduleToStart = scheduleToStartTimeout
+	}
+	if t.StartToClose == 0 {
+		t.StartToClose = startToCloseTimeout
+	}
+	if t.ScheduleToClose == 0 {
+		t.ScheduleToClose = t.ScheduleToStart + t.StartToClose
+	}
+	if t.Heartbeat == 0 {
+		t.Heartbeat = heartbeatTimeout
+	}
+	if t.Workflow == 0 {
+		t.Workflow = s.defaultWorkflowTimeout()
+	}
+	if t.Decision == 0 {
+		t.Decision = decisionTimeout
+	}
+	return t
+}
+
+// defaultWorkflowTimeout returns the workflow timeout of the spec without a Timeouts.Workflow, it covers the runs until
+// the next continue-as-new and the wait after the last of them, up to the maxScheduleSpan.
+func (s *ScheduleSpec) defaultWorkflowTimeout() time.Duration {
+	wait := s.longestWait()
+	if wait <= 0 {
+		return workflowTimeout
+	}
+	waits := s.runsBeforeContinueAsNew() + 1
+	if waits > uint(maxScheduleSpan/wait) {
+		return maxScheduleSpan
+	}
+	if timeout := wait * time.Duration(waits); timeout > workflowTimeout {
+		return timeout
+	}
+	return workflowTimeout
+}
+
+// longestWait returns the longest wait of the workflow for a scheduled run: the interval, a day for a schedule by time
+// of day, or the longest interval of the jobs of a schedule with Jobs.
+func (s *ScheduleSpec) longestWait() time.Duration {
+	if s.TimeOfDay != "" {
+		return time.Hour * 24
+	}
+	wait := s.ScheduleInterval
+	for _, job := range s.Jobs {
+		if job.Interval > wait {
+			wait = job.Interval
+		}
+	}
+	return wait
+}
+
+// startRunDeadline sets the deadline of the run of the workflow that started at the given time, the decision timeout
+// before its workflow timeout, so that the decision that continues as new completes before the run times out. The
+// run is assumed to be started with the workflow timeout of its spec, as the starter and continue-as-new do.
+func (s *ScheduleSpec) startRunDeadline(start time.Time) {
+	if s.getVersion(changeContinueAsNewBeforeTimeout, DefaultVersion, 1) < 1 {
+		return
+	}
+	s.runDeadline = start.Add(s.runLength())
+}
+
+// runLength returns how long a run of the workflow with the timeouts of the spec waits for runs before it continues as
+// new, the decision timeout before its workflow timeout.
+func (s *ScheduleSpec) runLength() time.Duration {
+	t := s.timeouts()
+	return t.Workflow - t.Decision
+}
+
+// validateWorkflowTimeout checks that a run of the workflow lasts until its first scheduled run, after the given
+// initial wait or else after the longest wait for a run. A run that doesn't can only continue as new before the run.
+func (s *ScheduleSpec) validateWorkflowTimeout(initialWait time.Duration) error {
+	firstRun := initialWait
+	if firstRun <= 0 {
+		firstRun = s.longestWait()
+	}
+	if s.runLength() < firstRun {
+		return fmt.Errorf("workflow timeout %v doesn't last until the first run in %v", s.timeouts().Workflow,
+			firstRun)
+	}
+	return nil
+}
+
+// pastRunDeadline returns true if the given time is after the deadline of the run of the workflow.
+func (s *ScheduleSpec) pastRunDeadline(t time.Time) bool {
+	return !s.runDeadline.IsZero() && t.After(s.runDeadline)
+}
+
+// orDefaults returns the timeouts with the zero ones taken from the given defaults.
+func (t Timeouts) orDefaults(defaults Timeouts) Timeouts {
+	for _, timeout := range []struct{ value, defaultValue *time.Duration }{
+		{&t.ScheduleToStart, &defaults.ScheduleToStart}, {&t.StartToClose, &defaults.StartToClose},
+		{&t.ScheduleToClose, &defaults.ScheduleToClose}, {&t.Heartbeat, &defaults.Heartbeat},
+		{&t.Workflow, &defaults.Workflow}, {&t.Decision, &defaults.Decision},
+	} {
+		if *timeout.value == 0 {
+			*timeout.value = *timeout.defaultValue
+		}
+	}
+	return t
+}
+
+// activityOptions returns the options of the sampleCronActivity executions.
+func (t Timeouts) activityOptions() cadence.ActivityOptions {
+	return cadence.ActivityOptions{
+		ScheduleToStartTimeout: t.ScheduleToStart,
+		StartToCloseTimeout:    t.StartToClose,
+		ScheduleToCloseTimeout: t.ScheduleToClose,
+		HeartbeatTimeout:       t.Heartbeat,
+	}
+}
+
+// activityOptions returns the options of the activities of the workflow of the spec.
+func (s *ScheduleSpec) activityOptions() cadence.ActivityOptions {
+	ao := s.timeouts().activityOptions()
+	ao.TaskList = s.ActivityTaskList
+	return ao
+}
+
+// validate checks timeouts that have the defaults applied.
+func (t Timeouts) validate() error {
+	if t.ScheduleToStart < 0 || t.StartToClose < 0 || t.ScheduleToClose < 0 || t.Heartbeat < 0 || t.Workflow < 0 ||
+		t.Decision < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	if t.ScheduleToClose < t.StartToClose {
+		return errors.New("schedule to close timeout must not be less than the start to close timeout")
+	}
+	if t.StartToClose > t.Workflow {
+		return errors.New("activity start to close timeout must not exceed the workflow timeout")
+	}
+	// a heartbeat timeout longer than the activity can take would never detect a lost activity.
+	if t.Heartbeat > t.StartToClose {
+		return errors.New("heartbeat timeout must not exceed the activity start to close timeout")
+	}
+	if t.Decision > t.Workflow {
+		return errors.New("decision timeout must not exceed the workflow timeout")
+	}
+	return nil
+}
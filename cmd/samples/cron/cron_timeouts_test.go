@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func (s *UnitTestSuite) Test_Timeouts_Defaults() {
+	spec := ScheduleSpec{Timeouts: Timeouts{StartToClose: time.Hour, Workflow: time.Hour * 12}}
+	s.Equal(Timeouts{
+		ScheduleToStart: scheduleToStartTimeout,
+		StartToClose:    time.Hour,
+		ScheduleToClose: scheduleToStartTimeout + time.Hour,
+		Heartbeat:       heartbeatTimeout,
+		Workflow:        time.Hour * 12,
+		Decision:        decisionTimeout,
+	}, spec.timeouts())
+}
+
+func (s *UnitTestSuite) Test_Timeouts_DefaultWorkflow() {
+	for _, tc := range []struct {
+		name     string
+		spec     ScheduleSpec
+		workflow time.Duration
+	}{
+		{"short interval", ScheduleSpec{ScheduleInterval: time.Minute}, workflowTimeout},
+		{"interval", ScheduleSpec{ScheduleInterval: time.Hour}, time.Hour * (loopCountBeforeContinueAsNew + 1)},
+		{"time of day", ScheduleSpec{TimeOfDay: "02:00"}, time.Hour * 24 * (loopCountBeforeContinueAsNew + 1)},
+		// a run of the sample adds 11 events, 454 of them fit.
+		{"max history events", ScheduleSpec{ScheduleInterval: time.Minute * 10, MaxHistoryEvents: 5000},
+			time.Minute * 10 * 455},
+		{"capped", ScheduleSpec{ScheduleInterval: time.Hour * 24 * 365, MaxHistoryEvents: 5000}, maxScheduleSpan},
+	} {
+		s.Equal(tc.workflow, tc.spec.timeouts().Workflow, tc.name)
+	}
+}
+
+func (s *UnitTestSuite) Test_Timeouts_Validate() {
+	valid := func(t Timeouts) error {
+		return (&ScheduleSpec{ScheduleInterval: time.Minute, Timeouts: t}).Validate(time.Time{})
+	}
+	s.NoError(valid(Timeouts{}))
+	s.NoError(valid(Timeouts{StartToClose: time.Hour, Workflow: time.Hour * 12}))
+	s.Error(valid(Timeouts{StartToClose: time.Hour}))
+	s.Error(valid(Timeouts{StartToClose: time.Minute, ScheduleToClose: time.Second * 30}))
+	s.Error(valid(Timeouts{StartToClose: time.Minute}))
+	s.Error(valid(Timeouts{Heartbeat: -time.Second}))
+	s.Error(valid(Timeouts{Decision: time.Hour}))
+}
+
+func TestReplay_CronWorkflowTimeouts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		timeouts Timeouts
+		// the timeouts of the activities and of the workflow after continue-as-new, in seconds.
+		scheduleToStart, startToClose, scheduleToClose, heartbeat, workflow, decision int32
+	}{
+		{"defaults", Timeouts{}, 600, 600, 1200, 600, 1200, 60},
+		{"overrides", Timeouts{ScheduleToStart: time.Minute, StartToClose: time.Hour, Heartbeat: time.Minute * 5,
+			Workflow: time.Hour * 12, Decision: time.Second * 30}, 60, 3600, 3660, 300, 43200, 30},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ScheduleSpec{JobCount: 12, ScheduleInterval: time.Minute, Timeouts: tc.timeouts}
+			h := newHistorySimulator(t, time.Unix(0, 0), SampleCronWorkflow, spec, &CronState{})
+
+			closeDecision := h.run()
+			require.Equal(t, s.DecisionType_ContinueAsNewWorkflowExecution, closeDecision.GetDecisionType())
+			require.NoError(t, h.replay())
+			for _, e := range h.events {
+				if e.GetEventType() != s.EventType_ActivityTaskScheduled {
+					continue
+				}
+				attributes := e.GetActivityTaskScheduledEventAttributes()
+				require.Equal(t, tc.scheduleToStart, attributes.GetScheduleToStartTimeoutSeconds())
+				require.Equal(t, tc.startToClose, attributes.GetStartToCloseTimeoutSeconds())
+				require.Equal(t, tc.scheduleToClose, attributes.GetScheduleToCloseTimeoutSeconds())
+				require.Equal(t, tc.heartbeat, attributes.GetHeartbeatTimeoutSeconds())
+			}
+
+			attributes := closeDecision.GetContinueAsNewWorkflowExecutionDecisionAttributes()
+			require.Equal(t, tc.workflow, attributes.GetExecutionStartToCloseTimeoutSeconds())
+			require.Equal(t, tc.decision, attributes.GetTaskStartToCloseTimeoutSeconds())
+			// the next run keeps the overrides and the defaults the first one filled in, so that it applies the same
+			// timeouts again.
+			var next ScheduleSpec
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(attributes.Input)).Decode(&next))
+			want := tc.timeouts
+			want.Workflow, want.Decision = time.Second*time.Duration(tc.workflow), time.Second*time.Duration(tc.decision)
+			require.Equal(t, want, next.Timeouts)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The trace ID of a schedule correlates the logs of its runs with the system that started it. This version of the
+ * client has no context propagators and no headers, the ID travels in the ScheduleSpec instead: the starter sets it,
+ * the workflow puts it in its context and carries it over continue-as-new with the spec, and passes it to
+ * sampleCronActivity in the CronJobInput, which puts it in the context of the activity. The loggers of workflowLogger
+ * and activityLogger have it as the TraceID field.
+ */
+
+// traceIDKey is the key of the trace ID in the context of the workflow and of the activity.
+type traceIDKey struct{}
+
+// withTraceID returns a context of the workflow with the given trace ID, the context as it is if the ID is empty.
+func withTraceID(ctx cadence.Context, traceID string) cadence.Context {
+	if traceID == "" {
+		return ctx
+	}
+	return cadence.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// workflowLogger returns the logger of the workflow with the trace ID of the context.
+func workflowLogger(ctx cadence.Context) *zap.Logger {
+	logger := cadence.GetLogger(ctx)
+	if traceID, ok := ctx.Value(traceIDKey{}).(string); ok {
+		return logger.With(zap.String("TraceID", traceID))
+	}
+	return logger
+}
+
+// withActivityTraceID returns a context of the activity with the given trace ID, the context as it is if the ID is
+// empty.
+func withActivityTraceID(ctx context.Context, traceID string) context.Context {
+	if traceID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// activityTraceID returns the trace ID of the context of the activity, empty if it has none.
+func activityTraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// activityLogger returns the logger of the activity with the fields of common.ActivityLogger, and the trace ID of the
+// context.
+func activityLogger(ctx context.Context) *zap.Logger {
+	logger := common.ActivityLogger(ctx)
+	if traceID := activityTraceID(ctx); traceID != "" {
+		return logger.With(zap.String("TraceID", traceID))
+	}
+	return logger
+}
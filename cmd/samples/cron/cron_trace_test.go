@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_TraceID() {
+	traceField := zap.String("TraceID", "trace-1")
+	for _, child := range []bool{false, true} {
+		spec := ScheduleSpec{JobCount: 12, ScheduleInterval: time.Hour, RunAsChildWorkflow: child, TraceID: "trace-1"}
+		for run := 0; run < 2; run++ {
+			core, logs := observer.New(zap.InfoLevel)
+			s.SetLogger(zap.New(core))
+			env := s.NewTestWorkflowEnvironment()
+			s.SetLogger(nil)
+			var traceIDs []string
+			env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+				traceIDs = append(traceIDs, input.TraceID)
+				return CronJobResult{}, nil
+			})
+			env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+			s.True(env.IsWorkflowCompleted())
+			s.NotEmpty(traceIDs)
+			for _, traceID := range traceIDs {
+				s.Equal("trace-1", traceID)
+			}
+			// the logs of the workflow have the trace ID.
+			started := logs.FilterMessage("Cron workflow started.")
+			s.Equal(1, started.Len())
+			s.Equal(1, started.FilterField(traceField).Len())
+			s.Equal(1, started.FilterField(zap.Duration("ScheduleInterval", time.Hour)).Len())
+			if run == 0 {
+				// the first run continues as new with the trace ID, the second one completes.
+				_, ok := env.GetWorkflowError().(cadence.ContinueAsNewError)
+				s.True(ok)
+				spec = continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+				s.Equal("trace-1", spec.TraceID)
+			} else {
+				s.NoError(env.GetWorkflowError())
+			}
+		}
+	}
+}
+
+func (s *UnitTestSuite) Test_SampleCronActivity_TraceID() {
+	core, logs := observer.New(zap.InfoLevel)
+	s.SetLogger(zap.New(core))
+	env := s.NewTestActivityEnvironment()
+	s.SetLogger(nil)
+	// the invalid shard makes the activity fail right after its first log.
+	_, err := env.ExecuteActivity(sampleCronActivity, CronJobInput{Shard: maxParallelism, TraceID: "trace-1"})
+	s.Error(err)
+	s.Equal(1, logs.FilterMessage("Cron job running.").FilterField(zap.String("TraceID", "trace-1")).Len())
+
+	s.Equal("trace-1", activityTraceID(withActivityTraceID(context.Background(), "trace-1")))
+	s.Equal("", activityTraceID(withActivityTraceID(context.Background(), "")))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence"
+)
+
+/**
+ * Validate checks the whole spec and reports all of its problems at once, not just the first one, so a bogus schedule
+ * is fixed in one go instead of being discovered by watching the workflow misbehave. The starter validates the spec
+ * before it starts the workflow and refuses to start an invalid one. A client can start the workflow without the
+ * starter, so SampleCronWorkflow validates its input again as its first step, and fails with an error with the reason
+ * errReasonInvalidSpec whose details are the problems. Retrying the workflow with the same input can't fix them.
+ *
+ * A valid spec is normalized: the workflow and decision timeouts that are not set are filled in, the workflow timeout
+ * of a schedule by interval is derived from the interval so it covers the runs until the next continue-as-new, and the
+ * initial wait of a schedule that has not started yet. A derived workflow timeout follows the interval when a signal
+ * or the config changes it, see setInterval.
+ */
+
+// errReasonInvalidSpec is the reason of the error the workflow fails with when its spec is invalid, the details are
+// the problems of the spec.
+const errReasonInvalidSpec = "invalidSpec"
+
+const (
+	// maxJobCount is the most runs a schedule can be started with.
+	maxJobCount = 1000000
+	// maxScheduleSpan is the longest time the runs of a schedule may take, a schedule running for longer is most
+	// likely a typo in the job count or the interval.
+	maxScheduleSpan = time.Hour * 24 * 365 * 10
+)
+
+// SpecError are the problems Validate found with a spec.
+type SpecError struct {
+	Problems []string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("invalid schedule: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate returns a SpecError with all the problems of the spec, and normalizes a valid spec. It parses the calendar
+// rules too, the workflow can't run with an invalid schedule. A NotAfter before the given time is a problem, unless the
+// time is zero: a schedule that continued as new may be past its deadline, it closes on its own.
+func (s *ScheduleSpec) Validate(now time.Time) error {
+	var problems []string
+	check := func(err error) {
+		if err == nil {
+			return
+		}
+		if specErr, ok := err.(*SpecError); ok {
+			problems = append(problems, specErr.Problems...)
+			return
+		}
+		problems = append(problems, err.Error())
+	}
+
+	check(s.validateInterval())
+	if !now.IsZero() && !s.NotAfter.IsZero() && s.NotAfter.Before(now) {
+		check(fmt.Errorf("deadline %v is in the past", s.NotAfter.Format(time.RFC3339)))
+	}
+	check(s.validateInitialWait())
+	check(validateParallelism(s.Parallelism))
+	if s.RetryPolicy != nil {
+		check(s.RetryPolicy.validate())
+	}
+	check(s.timeouts().validate())
+	if s.Timeouts.Workflow != 0 {
+		// a derived workflow timeout lasts until the first run.
+		check(s.validateWorkflowTimeout(s.initialWait(now)))
+	}
+	check(s.validateMaxHistoryEvents())
+	check(s.validateChildWorkflow())
+	check(s.validateBackoff())
+	check(s.validateBudget())
+	check(s.validateDeadLetter())
+	check(s.validateJobs())
+	check(s.validateJobActivities())
+	check(s.validateJobInputs())
+	check(s.validateTaskLists())
+	if s.HostAffinity != nil {
+		check(s.HostAffinity.validate())
+	}
+	if s.Lock != nil {
+		check(s.Lock.validate())
+	}
+	if s.ConfigSource != nil {
+		check(s.ConfigSource.validate())
+	}
+	if s.AlignToInterval && s.TimeOfDay != "" {
+		check(errors.New("align to interval doesn't apply to a schedule by time of day"))
+	}
+	check(s.parseCalendar())
+	if len(problems) > 0 {
+		return &SpecError{Problems: problems}
+	}
+
+	t := s.timeouts()
+	if s.Timeouts.Workflow == 0 {
+		// the first run of the workflow waits for the first scheduled run before the runs the timeout covers.
+		t.Workflow += s.initialWait(now)
+	}
+	s.Timeouts.Workflow, s.Timeouts.Decision = t.Workflow, t.Decision
+	return nil
+}
+
+// validateInterval checks the interval, the jitter and the job count against each other.
+func (s *ScheduleSpec) validateInterval() error {
+	var problems []string
+	// the interval of a schedule with jobs or a config source comes from them, a daily schedule has none.
+	byInterval := len(s.Jobs) == 0 && s.TimeOfDay == ""
+	if s.ScheduleInterval < 0 || (byInterval && s.ConfigSource == nil && s.ScheduleInterval == 0) {
+		problems = append(problems, fmt.Sprintf("schedule interval must be positive without a time of day or jobs, "+
+			"got %v", s.ScheduleInterval))
+	}
+	if s.Jitter < 0 {
+		problems = append(problems, fmt.Sprintf("jitter must not be negative, got %v", s.Jitter))
+	}
+	period := s.ScheduleInterval
+	if s.TimeOfDay != "" {
+		period = time.Hour * 24
+	}
+	if period > 0 && s.Jitter > period {
+		problems = append(problems, fmt.Sprintf("jitter %v exceeds the interval %v between the runs", s.Jitter,
+			period))
+	}
+	if s.JobCount > maxJobCount {
+		problems = append(problems, fmt.Sprintf("job count %d exceeds the maximum of %d runs", s.JobCount,
+			maxJobCount))
+	}
+	if period > 0 && s.JobCount > uint(maxScheduleSpan/period) {
+		problems = append(problems, fmt.Sprintf("%d runs every %v take longer than the maximum of %v", s.JobCount,
+			period, maxScheduleSpan))
+	}
+	if len(problems) > 0 {
+		return &SpecError{Problems: problems}
+	}
+	return nil
+}
+
+// setInterval sets the interval of the schedule, a workflow timeout derived from the old interval is derived from the
+// new one.
+func (s *ScheduleSpec) setInterval(interval time.Duration) {
+	derived := s.Timeouts.Workflow != 0 && s.Timeouts.Workflow == s.defaultWorkflowTimeout()
+	s.ScheduleInterval = interval
+	if derived {
+		s.Timeouts.Workflow = s.defaultWorkflowTimeout()
+	}
+}
+
+// invalidSpecError returns the error the workflow fails with for the problems of an invalid spec.
+func invalidSpecError(err error) error {
+	problems := []string{err.Error()}
+	if specErr, ok := err.(*SpecError); ok {
+		problems = specErr.Problems
+	}
+	return cadence.NewErrorWithDetails(errReasonInvalidSpec, problems)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+)
+
+func Test_ScheduleSpec_Validate(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		spec ScheduleSpec
+		// problems are the problems Validate reports, in order, none for a valid spec.
+		problems []string
+	}{
+		{"interval", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}, nil},
+		{"time of day", ScheduleSpec{JobCount: 5, TimeOfDay: "02:00", Jitter: time.Hour}, nil},
+		{"jobs", ScheduleSpec{JobCount: 5, Jobs: []JobSpec{{Name: "report", Interval: time.Minute}}}, nil},
+		{"config source", ScheduleSpec{JobCount: 5, ConfigSource: &ConfigSourceSpec{Name: "nightly"}}, nil},
+		{"deadline ahead", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, NotAfter: now.Add(time.Hour)}, nil},
+		{"no interval", ScheduleSpec{JobCount: 5},
+			[]string{"schedule interval must be positive without a time of day or jobs, got 0s"}},
+		{"negative interval", ScheduleSpec{JobCount: 5, ScheduleInterval: -time.Minute},
+			[]string{"schedule interval must be positive without a time of day or jobs, got -1m0s"}},
+		{"jitter beyond interval", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, Jitter: time.Hour},
+			[]string{"jitter 1h0m0s exceeds the interval 1m0s between the runs"}},
+		{"jitter beyond a day", ScheduleSpec{JobCount: 5, TimeOfDay: "02:00", Jitter: time.Hour * 25},
+			[]string{"jitter 25h0m0s exceeds the interval 24h0m0s between the runs"}},
+		{"negative jitter", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, Jitter: -time.Second},
+			[]string{"jitter must not be negative, got -1s"}},
+		{"deadline passed", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, NotAfter: now.Add(-time.Hour)},
+			[]string{"deadline 2020-03-01T11:00:00Z is in the past"}},
+		{"millions of runs", ScheduleSpec{JobCount: 5000000, ScheduleInterval: time.Second},
+			[]string{"job count 5000000 exceeds the maximum of 1000000 runs"}},
+		{"runs for years", ScheduleSpec{JobCount: 4000, TimeOfDay: "02:00"},
+			[]string{"4000 runs every 24h0m0s take longer than the maximum of 87600h0m0s"}},
+		{"initial delay", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, InitialDelay: time.Hour}, nil},
+		{"start at", ScheduleSpec{JobCount: 5, TimeOfDay: "02:00", StartAt: now.Add(-time.Hour)}, nil},
+		{"negative initial delay", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, InitialDelay: -time.Hour},
+			[]string{"initial delay must not be negative"}},
+		{"initial delay and start at", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			InitialDelay: time.Hour, StartAt: now}, []string{"initial delay and start at are mutually exclusive"}},
+		{"start at with jobs", ScheduleSpec{JobCount: 5, Jobs: []JobSpec{{Name: "report", Interval: time.Minute}},
+			StartAt: now}, []string{"a schedule with jobs doesn't support an initial delay or start at"}},
+		{"workflow timeout", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour,
+			Timeouts: Timeouts{Workflow: time.Hour * 2}}, nil},
+		{"workflow timeout before the first run", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour,
+			Timeouts: Timeouts{Workflow: time.Minute * 30}},
+			[]string{"workflow timeout 30m0s doesn't last until the first run in 1h0m0s"}},
+		{"workflow timeout before the start at", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			StartAt: now.Add(time.Hour * 3), Timeouts: Timeouts{Workflow: time.Hour * 2}},
+			[]string{"workflow timeout 2h0m0s doesn't last until the first run in 3h0m0s"}},
+		{"dead letter", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, FailurePolicy: FailureContinue,
+			DeadLetter: &DeadLetterSpec{Path: "/var/dead-letters.jsonl"}}, nil},
+		{"dead letter without continue", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			DeadLetter: &DeadLetterSpec{Path: "/var/dead-letters.jsonl"}},
+			[]string{"dead letters apply to a schedule with the failure policy Continue"}},
+		{"dead letter without path", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			FailurePolicy: FailureContinue, DeadLetter: &DeadLetterSpec{RetryPolicy: &RetryPolicy{}}},
+			[]string{"dead letter path must not be empty"}},
+		{"dead letter retry policy", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+			FailurePolicy: FailureContinue, DeadLetter: &DeadLetterSpec{Path: "/var/dead-letters.jsonl",
+				RetryPolicy: &RetryPolicy{InitialInterval: time.Second}}},
+			[]string{"dead letter retry policy needs maximum attempts or an expiration interval"}},
+		{"task lists", ScheduleSpec{JobCount: 5, TaskList: "staging", Jobs: []JobSpec{
+			{Name: "report", Interval: time.Minute, TaskList: "prod"}, {Name: "cleanup", Interval: time.Minute}}}, nil},
+		{"blank task list", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, TaskList: " "},
+			[]string{"task list must not be blank when set"}},
+		{"task list of a host", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, TaskList: hostTaskList},
+			[]string{"task list " + hostTaskList + " is the task list of a host, pick a name without the prefix " +
+				ApplicationName + "_"}},
+		{"blank task list of a job", ScheduleSpec{JobCount: 5, Jobs: []JobSpec{
+			{Name: "report", Interval: time.Minute, TaskList: "\t"}}},
+			[]string{`task list of job "report" must not be blank when set`}},
+		{"all problems at once", ScheduleSpec{JobCount: 5, Jitter: -time.Second, Parallelism: maxParallelism + 1,
+			TimeOfDay: "25:00", AlignToInterval: true},
+			[]string{"jitter must not be negative, got -1s",
+				"parallelism 11 exceeds the maximum of 10 shards per run",
+				"align to interval doesn't apply to a schedule by time of day",
+				`invalid schedule time of day "25:00", expected HH:MM: parsing time "25:00": hour out of range`}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := tc.spec
+			err := spec.Validate(now)
+			if tc.problems == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			specErr, ok := err.(*SpecError)
+			require.True(t, ok, "%T %v", err, err)
+			require.Equal(t, tc.problems, specErr.Problems)
+		})
+	}
+}
+
+func Test_ScheduleSpec_ValidateNormalizesTimeouts(t *testing.T) {
+	spec := ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, Timeouts: Timeouts{StartToClose: time.Hour}}
+	require.NoError(t, spec.Validate(time.Time{}))
+	// the timeout of the workflow covers the runs until continue-as-new, the activity timeouts keep their defaults.
+	require.Equal(t, Timeouts{StartToClose: time.Hour, Workflow: time.Hour * (loopCountBeforeContinueAsNew + 1),
+		Decision: decisionTimeout}, spec.Timeouts)
+
+	// a derived timeout follows the interval, one that was set doesn't.
+	spec.setInterval(time.Hour * 2)
+	require.Equal(t, time.Hour*2*(loopCountBeforeContinueAsNew+1), spec.Timeouts.Workflow)
+	spec.Timeouts.Workflow = time.Hour * 48
+	spec.setInterval(time.Hour)
+	require.Equal(t, time.Hour*48, spec.Timeouts.Workflow)
+
+	// a short interval keeps the default.
+	spec = ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute}
+	require.NoError(t, spec.Validate(time.Time{}))
+	require.Equal(t, workflowTimeout, spec.Timeouts.Workflow)
+}
+
+func Test_CronWorkflow_InvalidSpec(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	ran := false
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		ran = true
+		return CronJobResult{}, nil
+	})
+	// the test environment starts at the Unix epoch.
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+		Jitter: time.Hour, NotAfter: time.Unix(0, 0).Add(-time.Hour)}, &CronState{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.False(t, ran)
+	err, ok := env.GetWorkflowError().(cadence.ErrorWithDetails)
+	require.True(t, ok, "%T %v", env.GetWorkflowError(), env.GetWorkflowError())
+	require.Equal(t, errReasonInvalidSpec, err.Reason())
+	var problems []string
+	err.Details(&problems)
+	require.Equal(t, []string{"jitter 1h0m0s exceeds the interval 1m0s between the runs",
+		"deadline " + time.Unix(0, 0).Add(-time.Hour).Format(time.RFC3339) + " is in the past"}, problems)
+	require.Equal(t, time.Duration(0), env.Now().Sub(time.Unix(0, 0)))
+}
+
+func Test_CronWorkflow_DeadlinePassedAfterContinueAsNew(t *testing.T) {
+	var suite cadence.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	// a run after continue-as-new has the start time of the schedule, its deadline passed while it continued.
+	state := &CronState{StartTime: time.Unix(0, 0).Add(-time.Hour * 2)}
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute,
+		NotAfter: time.Unix(0, 0).Add(-time.Second)}, state)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var summary CronSummary
+	require.NoError(t, env.GetWorkflowResult(&summary))
+	require.Equal(t, summaryDeadlineReached, summary.Status)
+}
+
+// specProblems returns the problems of the invalid spec the workflow failed with, joined as in the SpecError.
+func specProblems(err error) string {
+	withDetails, ok := err.(cadence.ErrorWithDetails)
+	if !ok || withDetails.Reason() != errReasonInvalidSpec {
+		return ""
+	}
+	var problems []string
+	withDetails.Details(&problems)
+	return strings.Join(problems, "; ")
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_SpecProblems() {
+	for _, tc := range []struct {
+		name string
+		spec ScheduleSpec
+		// problem is a part of the problems the workflow fails with, or all of them if only is set.
+		problem string
+		only    bool
+	}{
+		{"unknown timezone", ScheduleSpec{JobCount: 3, TimeOfDay: "02:00", Timezone: "America/Nowhere"},
+			"America/Nowhere", false},
+		{"invalid exclusions", ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour,
+			Exclusions: Exclusions{Dates: []string{"12/25/2023"}}}, "12/25/2023", false},
+		{"align to interval with time of day", ScheduleSpec{JobCount: 3, TimeOfDay: "02:00", AlignToInterval: true},
+			"align to interval", false},
+		{"parallelism too large", ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour,
+			Parallelism: maxParallelism + 1}, "parallelism", false},
+		{"max history events too small", ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute, Parallelism: 5,
+			MaxHistoryEvents: 30}, "max history events 30 don't fit a single run of 5 shards", true},
+		{"duplicate job names", ScheduleSpec{JobCount: 3, Jobs: []JobSpec{
+			{Name: "report", Interval: time.Minute}, {Name: "report", Interval: time.Hour}}},
+			`duplicate job name "report"`, false},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		env.ExecuteWorkflow(SampleCronWorkflow, tc.spec, &CronState{})
+
+		s.True(env.IsWorkflowCompleted(), tc.name)
+		s.Error(env.GetWorkflowError(), tc.name)
+		if tc.only {
+			s.Equal(tc.problem, specProblems(env.GetWorkflowError()), tc.name)
+		} else {
+			s.Contains(specProblems(env.GetWorkflowError()), tc.problem, tc.name)
+		}
+	}
+}
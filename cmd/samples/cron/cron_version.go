@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+/**
+ * A change of the workflow code must not change the decisions of histories recorded before it, a worker with the new
+ * code replays them when it picks up a workflow that was in flight during the deployment. Every change that does is
+ * guarded with getVersion, the old histories take the DefaultVersion path and new runs the new one. This version of
+ * the client has no cadence.GetVersion that records the version in the history, so the versions are carried in the
+ * ScheduleSpec instead. The input of an old run has no versions and decodes with the DefaultVersion of every change.
+ * Continue-as-new starts the next run with the latest versions, it has no history yet that could replay the old path.
+ *
+ * The guard of a change can be removed once no run is left that was started with an older version. Since every run
+ * continues as new with the latest versions, that is one workflow run after the change was deployed to all workers,
+ * at most Timeouts.Workflow. Only then the minimum supported version can be raised and the old path deleted.
+ */
+
+// Version is the version of a change of the workflow code.
+type Version int
+
+// DefaultVersion is the version of the code before a change was made.
+const DefaultVersion Version = 0
+
+// The IDs of the changes of the workflow code.
+const (
+	// changeAddResultRecording records the result of every run with recordCronResultActivity.
+	changeAddResultRecording = "AddResultRecording"
+	// changeAddSummaryReport reports the summary of a schedule that closes with reportSummaryActivity.
+	changeAddSummaryReport = "AddSummaryReport"
+	// changeAddCorrelationID gives every run a correlation ID, see cron_correlation.go.
+	changeAddCorrelationID = "AddCorrelationID"
+	// changeJitterSideEffect draws the jitter of every wait with a side effect, see getJitter.
+	changeJitterSideEffect = "JitterSideEffect"
+	// changeContinueAsNewBeforeTimeout continues as new before the run of the workflow times out, see runDeadline.
+	changeContinueAsNewBeforeTimeout = "ContinueAsNewBeforeTimeout"
+)
+
+// latestVersions are the versions of the changes the current code makes in new runs.
+var latestVersions = map[string]Version{
+	changeAddResultRecording:         1,
+	changeAddSummaryReport:           1,
+	changeAddCorrelationID:           1,
+	changeJitterSideEffect:           1,
+	changeContinueAsNewBeforeTimeout: 1,
+}
+
+// withLatestVersions sets the versions of all changes to the latest, for a run that is started or continued as new.
+func (s *ScheduleSpec) withLatestVersions() {
+	s.ChangeVersions = make(map[string]Version, len(latestVersions))
+	for changeID, version := range latestVersions {
+		s.ChangeVersions[changeID] = version
+	}
+}
+
+// getVersion returns the version of the change the run was started with. It panics if the code no longer supports
+// that version, the decision task fails until a worker with code that supports it picks it up.
+func (s *ScheduleSpec) getVersion(changeID string, minSupported, maxSupported Version) Version {
+	version := s.ChangeVersions[changeID]
+	if version < minSupported || version > maxSupported {
+		panic(fmt.Sprintf("version %d of change %s is not supported, supported versions are %d to %d", version,
+			changeID, minSupported, maxSupported))
+	}
+	return version
+}
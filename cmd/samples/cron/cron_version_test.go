@@ -0,0 +1,18 @@
+package main
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *UnitTestSuite) Test_CronWorkflow_UnsupportedVersion() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	spec := ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute,
+		ChangeVersions: map[string]Version{changeAddResultRecording: 2}}
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * The workers of the runner can be deployed together or apart. The worker mode executes the workflows and the
+ * activities in one process, as the sample always did. The workflowWorker mode executes only the workflows, its worker
+ * has DisableActivityWorker set, and the activityWorker mode executes only the activities, its workers have
+ * DisableWorkflowWorker set. The deployments of the two scale on their own: the decision tasks are short and need few
+ * pollers, the activities run long and need many.
+ *
+ * The workflows schedule their activities on the ActivityTaskList of their schedule, ApplicationName by default. A
+ * schedule started with -activityTaskList has its activities executed by the activity workers started with the same
+ * -activityTaskList. The lock workflow schedules its activities on the task list of the schedule that started it. The
+ * job activities of a schedule with a TaskList go to the activity workers started with it in -pollTaskLists, see
+ * cron_tasklist.go.
+ *
+ * A worker only registers what it executes. This version of the client registers the workflows and activities with the
+ * process, not with a worker, so the runner registers those of its mode before it starts the workers. A workflow
+ * worker doesn't register the job activities, a new job activity is deployed with the activity workers alone.
+ *
+ * The vendored client has a single cadence package. The workflow, activity, worker and client packages of newer
+ * clients split it up: cadence.Context becomes workflow.Context, cadence.GetActivityLogger becomes activity.GetLogger,
+ * and the workflows and activities are registered with the worker returned by worker.New. The code of the sample maps
+ * one to one onto them once the client is upgraded.
+ */
+
+// The modes of the runner that start workers.
+const (
+	modeWorker         = "worker"
+	modeWorkflowWorker = "workflowWorker"
+	modeActivityWorker = "activityWorker"
+)
+
+type (
+	// workerRole is what the workers of a mode execute.
+	workerRole struct {
+		Workflows  bool
+		Activities bool
+	}
+
+	// workerTaskList is a task list the workers of a mode poll, and the options that disable what they don't
+	// execute on it.
+	workerTaskList struct {
+		TaskList string
+		Options  cadence.WorkerOptions
+	}
+)
+
+// workerRoles are the roles of the modes that start workers.
+var workerRoles = map[string]workerRole{
+	modeWorker:         {Workflows: true, Activities: true},
+	modeWorkflowWorker: {Workflows: true},
+	modeActivityWorker: {Activities: true},
+}
+
+// registrations returns the workflows and the activities the workers of the role execute.
+func (r workerRole) registrations() (workflows []interface{}, activities []interface{}) {
+	if r.Workflows {
+		workflows = []interface{}{SampleCronWorkflow, CronJobWorkflow, CronLockWorkflow, CronAggregatorWorkflow}
+	}
+	if r.Activities {
+		activities = []interface{}{acquireLeaseActivity, releaseLeaseActivity, grantLeaseActivity, cronCleanupActivity,
+			recordCronResultActivity, pickHostActivity, getScheduleConfigActivity, reportSummaryActivity,
+			forwardRunActivity, deadLetterActivity}
+		for _, name := range knownJobActivities() {
+			activities = append(activities, jobActivities[name])
+		}
+	}
+	return workflows, activities
+}
+
+// register registers the workflows and the activities of the role, it is called once per process.
+func (r workerRole) register() {
+	workflows, activities := r.registrations()
+	for _, workflow := range workflows {
+		cadence.RegisterWorkflow(workflow)
+	}
+	for _, activity := range activities {
+		cadence.RegisterActivity(activity)
+	}
+}
+
+// taskLists returns the task lists the workers of the role poll, given the activity task list of the schedules they
+// execute and the task lists of their job activities. The activity workers poll the task list of their host too, see
+// cron_host.go.
+func (r workerRole) taskLists(activityTaskList string, jobTaskLists []string) []workerTaskList {
+	if activityTaskList == "" {
+		activityTaskList = ApplicationName
+	}
+	// a worker that executes both polls the activities of ApplicationName with its workflows.
+	shared := r.Workflows && r.Activities && activityTaskList == ApplicationName
+	var taskLists []workerTaskList
+	if r.Workflows {
+		taskLists = append(taskLists, workerTaskList{TaskList: ApplicationName,
+			Options: cadence.WorkerOptions{DisableActivityWorker: !shared}})
+	}
+	if !r.Activities {
+		return taskLists
+	}
+	if !shared {
+		taskLists = append(taskLists, workerTaskList{TaskList: activityTaskList,
+			Options: cadence.WorkerOptions{DisableWorkflowWorker: true}})
+	}
+	for _, taskList := range jobTaskLists {
+		if taskList != activityTaskList {
+			taskLists = append(taskLists, workerTaskList{TaskList: taskList,
+				Options: cadence.WorkerOptions{DisableWorkflowWorker: true}})
+		}
+	}
+	// the registration is per process, the worker of the host could execute any activity, but only the shards are
+	// scheduled on it.
+	return append(taskLists, workerTaskList{TaskList: hostTaskList,
+		Options: cadence.WorkerOptions{DisableWorkflowWorker: true}})
+}
+
+// checkWorkerMode returns an error for a mode and flags whose workers could never execute what they are configured
+// for, given the names of the flags that are set on the command line.
+func checkWorkerMode(mode string, set map[string]bool, activityTaskList string) error {
+	if strings.HasPrefix(activityTaskList, ApplicationName+"_") {
+		return fmt.Errorf("-activityTaskList %s is the task list of a host, pick a name without the prefix %s_",
+			activityTaskList, ApplicationName)
+	}
+	switch mode {
+	case modeWorkflowWorker:
+		// the schedules tell the workflows where their activities go, a workflow worker polls none of them.
+		for _, name := range []string{"activityTaskList", "pollTaskLists", "activityPollers"} {
+			if set[name] {
+				return fmt.Errorf("-%s configures the activity workers, -m %s executes no activities", name, mode)
+			}
+		}
+	case modeActivityWorker:
+		if set["decisionPollers"] {
+			return fmt.Errorf("-decisionPollers configures the workflow workers, -m %s executes no workflows", mode)
+		}
+	}
+	return nil
+}
+
+// parsePollTaskLists returns the comma separated task lists of -pollTaskLists, without duplicates.
+func parsePollTaskLists(taskLists string) ([]string, error) {
+	var parsed []string
+	seen := make(map[string]bool)
+	for _, taskList := range strings.Split(taskLists, ",") {
+		taskList = strings.TrimSpace(taskList)
+		if taskList == "" || seen[taskList] {
+			continue
+		}
+		if err := validateTaskList("-pollTaskLists", taskList); err != nil {
+			return nil, err
+		}
+		seen[taskList] = true
+		parsed = append(parsed, taskList)
+	}
+	return parsed, nil
+}
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper, role workerRole, activityTaskList string, jobTaskLists []string) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+		// the running activities are cancelled when the drain on shutdown times out.
+		BackgroundActivityContext: h.BackgroundActivityContext(),
+	}
+	// the workflow and activities of this worker emit their metrics to the scope of the worker.
+	metricsScope = h.Scope
+	if role.Activities {
+		// the lock activities and forwardRunActivity signal the workflows with the client of the worker.
+		client, err := h.Builder.BuildCadenceClient()
+		if err != nil {
+			h.Logger.Error("Failed to build cadence client.", zap.Error(err))
+			panic(err)
+		}
+		lockClient = client
+	}
+	h.Logger.Info("Starting cron workers.", zap.Bool("Workflows", role.Workflows),
+		zap.Bool("Activities", role.Activities), zap.String("ActivityTaskList", activityTaskList),
+		zap.Strings("PollTaskLists", jobTaskLists))
+	for _, taskList := range role.taskLists(activityTaskList, jobTaskLists) {
+		options := workerOptions
+		options.DisableWorkflowWorker = taskList.Options.DisableWorkflowWorker
+		options.DisableActivityWorker = taskList.Options.DisableActivityWorker
+		h.StartWorkers(h.Config.DomainName, taskList.TaskList, options)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence"
+)
+
+// functionNames returns the names of the functions without the package.
+func functionNames(functions []interface{}) []string {
+	var names []string
+	for _, function := range functions {
+		name := runtime.FuncForPC(reflect.ValueOf(function).Pointer()).Name()
+		names = append(names, name[strings.LastIndex(name, ".")+1:])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *UnitTestSuite) Test_WorkerRoles_Registrations() {
+	cronWorkflows := []string{"CronAggregatorWorkflow", "CronJobWorkflow", "CronLockWorkflow", "SampleCronWorkflow"}
+	cronActivities := []string{"acquireLeaseActivity", "archiveJobActivity", "cronCleanupActivity", "deadLetterActivity",
+		"forwardRunActivity", "getScheduleConfigActivity", "grantLeaseActivity", "longRunningJobActivity", "pickHostActivity",
+		"recordCronResultActivity", "releaseLeaseActivity", "reportJobActivity", "reportSummaryActivity",
+		"sampleCronActivity"}
+
+	workflows, activities := workerRoles[modeWorker].registrations()
+	s.Equal(cronWorkflows, functionNames(workflows))
+	s.Equal(cronActivities, functionNames(activities))
+
+	// the workflow worker has none of the activities, the job activities are deployed with the activity workers.
+	workflows, activities = workerRoles[modeWorkflowWorker].registrations()
+	s.Equal(cronWorkflows, functionNames(workflows))
+	s.Empty(activities)
+
+	workflows, activities = workerRoles[modeActivityWorker].registrations()
+	s.Empty(workflows)
+	s.Equal(cronActivities, functionNames(activities))
+}
+
+func (s *UnitTestSuite) Test_WorkerRoles_TaskLists() {
+	both := cadence.WorkerOptions{}
+	workflowsOnly := cadence.WorkerOptions{DisableActivityWorker: true}
+	activitiesOnly := cadence.WorkerOptions{DisableWorkflowWorker: true}
+	host := workerTaskList{TaskList: hostTaskList, Options: activitiesOnly}
+
+	// the combined worker polls as before, or the activity task list of its schedules next to its workflows.
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: both}, host},
+		workerRoles[modeWorker].taskLists("", nil))
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: workflowsOnly},
+		{TaskList: "cron-activities", Options: activitiesOnly}, host},
+		workerRoles[modeWorker].taskLists("cron-activities", nil))
+
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: workflowsOnly}},
+		workerRoles[modeWorkflowWorker].taskLists("", nil))
+
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: activitiesOnly}, host},
+		workerRoles[modeActivityWorker].taskLists("", nil))
+	s.Equal([]workerTaskList{{TaskList: "cron-activities", Options: activitiesOnly}, host},
+		workerRoles[modeActivityWorker].taskLists("cron-activities", nil))
+
+	// the task lists of the job activities are polled by a worker each, next to the activity task list.
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: both}, {TaskList: "staging", Options: activitiesOnly},
+		{TaskList: "prod", Options: activitiesOnly}, host},
+		workerRoles[modeWorker].taskLists("", []string{"staging", ApplicationName, "prod"}))
+	s.Equal([]workerTaskList{{TaskList: "cron-activities", Options: activitiesOnly},
+		{TaskList: "staging", Options: activitiesOnly}, host},
+		workerRoles[modeActivityWorker].taskLists("cron-activities", []string{"staging", "cron-activities"}))
+	s.Equal([]workerTaskList{{TaskList: ApplicationName, Options: workflowsOnly}},
+		workerRoles[modeWorkflowWorker].taskLists("", []string{"staging"}))
+
+	taskLists, err := parsePollTaskLists("staging, prod,,staging")
+	s.NoError(err)
+	s.Equal([]string{"staging", "prod"}, taskLists)
+	taskLists, err = parsePollTaskLists("")
+	s.NoError(err)
+	s.Nil(taskLists)
+	_, err = parsePollTaskLists("staging," + hostTaskList)
+	s.Error(err)
+}
+
+func (s *UnitTestSuite) Test_CheckWorkerMode() {
+	for _, tc := range []struct {
+		mode             string
+		flags            []string
+		activityTaskList string
+		valid            bool
+	}{
+		{modeWorker, []string{"activityTaskList"}, "cron-activities", true},
+		{modeWorkflowWorker, []string{"decisionPollers"}, "", true},
+		{modeActivityWorker, []string{"activityTaskList", "activityPollers"}, "cron-activities", true},
+		{"trigger", []string{"activityTaskList"}, "cron-activities", true},
+		// the activities of the schedules are scheduled where the flag says, not on the workflow worker.
+		{modeWorkflowWorker, []string{"activityTaskList"}, "cron-activities", false},
+		{modeWorkflowWorker, []string{"activityPollers"}, "", false},
+		{modeWorkflowWorker, []string{"pollTaskLists"}, "", false},
+		{modeActivityWorker, []string{"decisionPollers"}, "", false},
+		// only the shards of a run pinned to the host are scheduled on the task list of a host.
+		{modeActivityWorker, []string{"activityTaskList"}, hostTaskList, false},
+		{"trigger", []string{"activityTaskList"}, ApplicationName + "_other", false},
+	} {
+		set := make(map[string]bool)
+		for _, name := range tc.flags {
+			set[name] = true
+		}
+		err := checkWorkerMode(tc.mode, set, tc.activityTaskList)
+		s.Equal(tc.valid, err == nil, "%+v: %v", tc, err)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ActivityTaskList() {
+	spec := ScheduleSpec{ActivityTaskList: "cron-activities"}
+	s.Equal("cron-activities", spec.activityOptions().TaskList)
+	s.Empty((&ScheduleSpec{}).activityOptions().TaskList)
+
+	// the lock workflow started by a run schedules its activities on the activity task list of the schedule.
+	env := s.NewTestWorkflowEnvironment()
+	var locks []LockSpec
+	env.OverrideActivity(acquireLeaseActivity, func(ctx context.Context, lock LockSpec, request LeaseRequest) error {
+		locks = append(locks, lock)
+		env.SignalWorkflow(leaseGrantedSignalName, request.LeaseID)
+		return nil
+	})
+	env.OnActivity(releaseLeaseActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 1, ScheduleInterval: time.Hour,
+		ActivityTaskList: "cron-activities", Lock: &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Hour}},
+		&CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]LockSpec{{Name: "backend", Permits: 1, LeaseTimeout: time.Hour, ActivityTaskList: "cron-activities"}},
+		locks)
+}
@@ -2,8 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
 	"time"
 
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
 	"go.uber.org/cadence"
 	"go.uber.org/zap"
 )
@@ -11,105 +19,812 @@ import (
 /**
  * This cron sample workflow will schedule job based on given schedule spec. The schedule spec in this sample demo is
  * very simple, but you could have more complicated scheduler logic that meet your needs.
+ *
+ * Newer versions of Cadence can also run a workflow on a cron schedule of the server, with the CronSchedule start
+ * option, every run is a new execution that can read the result of the previous one. This version of the client has
+ * no CronSchedule option, so the schedule is implemented in the workflow. That is also what makes the signals of the
+ * workflow possible, a server-side schedule can't be paused, triggered or updated while it runs, it has no catch-up or
+ * overlap policy of its own, and it passes only the last completion result from one run to the next.
  */
 
 type (
 	// ScheduleSpec specify how the cron job will be scheduled.
 	ScheduleSpec struct {
-		// How many times you want the cron job to be scheduled.
+		// How many times you want the cron job to be scheduled. Runs skipped by Exclusions, the OverlapPolicy or the
+		// DailyBudget don't count.
 		JobCount         uint
 		ScheduleInterval time.Duration
+		// AlignToInterval fires the runs on the multiples of the ScheduleInterval since the Unix epoch, instead of a
+		// ScheduleInterval after the wait for the run started. It doesn't apply to a schedule by TimeOfDay.
+		AlignToInterval bool
+		// Paused is set while the schedule is paused by the pause signal. It is part of the spec so that a pause
+		// survives continue-as-new.
+		Paused bool
+		// Draining is set by the drain signal, no more runs are started once the runs in progress completed.
+		Draining bool
+		// PendingTrigger is a manual run requested by the triggerNow signal that has not run yet. It is part of the
+		// spec so that a trigger received right before continue-as-new is not lost.
+		PendingTrigger *TriggerNowRequest
+		// Jitter adds a random delay in [0, Jitter) to every scheduled run, so that many cron workflows started with
+		// the same interval don't all fire at the same instant.
+		Jitter time.Duration
+		// TimeOfDay runs the job once a day at this wall clock time in the "15:04" format, instead of every
+		// ScheduleInterval.
+		TimeOfDay string
+		// Timezone is the IANA name of the timezone of TimeOfDay, Exclusions and the days of the DailyBudget, e.g.
+		// "America/New_York". Empty means UTC.
+		Timezone string
+		// Exclusions are blackout days on which the job must not run.
+		Exclusions Exclusions
+		// SkippedByExclusions counts the scheduled runs skipped due to Exclusions, it is carried over continue-as-new.
+		SkippedByExclusions uint
+		// InitialDelay delays the first scheduled run by this time after the start of the schedule, and StartAt is the
+		// time the first scheduled run is due, instead of an interval after the start. They are mutually exclusive, the
+		// workflow turns an InitialDelay into a StartAt when it starts, see cron_initial.go. StartAt is cleared once the
+		// first scheduled run was due, until then it is carried over continue-as-new.
+		InitialDelay time.Duration
+		StartAt      time.Time
+		// NotAfter is the deadline of the schedule, the workflow completes once it is reached even if JobCount is not
+		// used up, and no run scheduled after it is started. Zero means no deadline.
+		NotAfter time.Time
+		// OverlapPolicy decides what happens to a scheduled run that is due while the previous run is still executing.
+		OverlapPolicy OverlapPolicy
+		// BufferedRun is set while a run buffered by OverlapBufferOne waits for the previous run to complete.
+		BufferedRun bool
+		// SkippedByOverlap and BufferedByOverlap count the scheduled runs that were due while the previous run was
+		// still executing, they are carried over continue-as-new.
+		SkippedByOverlap  uint
+		BufferedByOverlap uint
+		// Parallelism is the number of shards every run is split into, each shard is processed by its own
+		// sampleCronActivity execution. Zero means a single shard.
+		Parallelism uint
+		// CancelShardsOnFailure cancels the other shards of a run as soon as one of them fails, instead of letting them
+		// complete.
+		CancelShardsOnFailure bool
+		// RunAsChildWorkflow executes every run as a CronJobWorkflow child workflow, which executes the activities of
+		// the shards, instead of executing them in the cron workflow.
+		RunAsChildWorkflow bool
+		// ChildWorkflow configures the child workflows of the runs with RunAsChildWorkflow.
+		ChildWorkflow ChildWorkflowSpec
+		// HostAffinity executes the shards of every run on the worker host that picked up the run, nil means any
+		// worker executes them.
+		HostAffinity *HostAffinitySpec
+		// RetryPolicy retries the failed shards of a run, nil means no retries.
+		RetryPolicy *RetryPolicy
+		// FailurePolicy decides if a failed run ends the schedule.
+		FailurePolicy FailurePolicy
+		// MaxConsecutiveFailures ends the schedule when more runs in a row failed with FailureContinue. Zero means no
+		// limit.
+		MaxConsecutiveFailures uint
+		// DeadLetter delivers the failed runs of a schedule with FailureContinue to a dead letter sink, see
+		// cron_deadletter.go. Nil means the failed runs are only counted.
+		DeadLetter *DeadLetterSpec
+		// BackoffCoefficient multiplies the interval before the next run after every consecutive failed run, see
+		// backoffInterval. Zero or 1 means the next run is due after the interval, whatever the failures.
+		BackoffCoefficient float64
+		// MaxBackoff caps the interval that failed runs back off to, it is required with a BackoffCoefficient.
+		MaxBackoff time.Duration
+		// DailyBudget is the time the runs may execute per day, the runs due once it is used up are skipped until the
+		// next day, see cron_budget.go. Zero means no budget.
+		DailyBudget time.Duration
+		// Timeouts of the workflow and of the activities of its runs, zero fields use the defaults.
+		Timeouts Timeouts
+		// MaxHistoryEvents makes the workflow continue as new before the estimated length of its history exceeds it.
+		// Zero means to continue as new after every loopCountBeforeContinueAsNew runs. The default Timeouts.Workflow
+		// covers the runs until then, a shorter one makes the workflow continue as new earlier.
+		MaxHistoryEvents uint
+		// CatchUpPolicy decides what happens to the scheduled runs that were missed because the workflow woke up late,
+		// e.g. because no worker was running.
+		CatchUpPolicy CatchUpPolicy
+		// Backlog are the scheduled times of the missed runs that wait to be backfilled, oldest first. It is part of
+		// the spec so that the backlog is carried over continue-as-new.
+		Backlog []time.Time
+		// MissedRuns counts the missed runs that were not backfilled, it is carried over continue-as-new. A wake up
+		// that missed more than maxBacklog runs counts only the first maxBacklog of them.
+		MissedRuns uint
+		// Jobs are the named jobs of a schedule that runs several jobs with their own intervals, instead of a single
+		// job every ScheduleInterval.
+		Jobs []JobSpec
+		// Lock is the lock the runs hold while their job executes, shared with other schedules. No lock by default.
+		Lock *LockSpec
+		// Name is the name of the schedule, it tags the metrics of the workflow. A schedule with a name is started with
+		// a workflow ID derived from it, see scheduleWorkflowID.
+		Name string
+		// Description describes the schedule as it was started, e.g. its owner and purpose, see describe. Signals that
+		// change the schedule don't change it, compare it with the logged spec for the effective schedule.
+		Description map[string]string
+		// ChangeVersions are the versions of the changes of the workflow code the run was started with, by change ID.
+		// A change that is missing has the DefaultVersion.
+		ChangeVersions map[string]Version
+		// TraceID correlates the logs of the workflow and its jobs with the system that started it, see workflowLogger.
+		TraceID string
+		// CorrelationIDs decides how the correlation IDs of the runs are generated, see cron_correlation.go.
+		CorrelationIDs CorrelationIDScheme
+		// JobActivityName is the name of the job activity that runs the job, one of the jobActivities. Empty means
+		// sampleCronActivity. A schedule with Jobs names the activities of its jobs in their JobSpecs instead.
+		JobActivityName string
+		// JobInput is the JSON input of the job, passed to the job activity as CronJobInput.JobInput.
+		JobInput json.RawMessage
+		// JobInputs are the inputs of the runs of a schedule that works through a list, every run takes the next one as
+		// its JobInput, see cron_inputs.go. The inputs taken are removed, the ones left are carried over
+		// continue-as-new.
+		JobInputs []json.RawMessage
+		// JobInputIndex is the index of the first of the JobInputs in the list the schedule was started with, the
+		// input of a run has it as CronJobInput.JobInputIndex.
+		JobInputIndex uint
+		// ConfigSource fetches the schedule from a config service, see cron_config.go. Nil means the schedule of the
+		// input.
+		ConfigSource *ConfigSourceSpec
+		// ActivityTaskList is the task list the activities of the workflow are scheduled on, polled by the activity
+		// workers, see cron_worker.go. Empty means the task list of the workflow.
+		ActivityTaskList string
+		// TaskList is the task list the job activities of the runs are scheduled on, e.g. the one of the workers of an
+		// environment, see cron_tasklist.go. Empty means the ActivityTaskList.
+		TaskList string
+		// AggregatorWorkflowID is the ID of the CronAggregatorWorkflow the runs forward their outcome to, see
+		// cron_aggregator.go. Empty means no aggregator. StartAggregator starts it if it is not running, otherwise the
+		// outcome is dropped.
+		AggregatorWorkflowID string
+		StartAggregator      bool
+
+		// daily is the parsed TimeOfDay and Timezone, or nil for a schedule by interval.
+		daily *dailySchedule
+		// exclusions is the parsed Exclusions, or nil if there are none.
+		exclusions *exclusionCalendar
+		// budgetLocation is the parsed Timezone of a validated spec with a DailyBudget, nil otherwise.
+		budgetLocation *time.Location
+		// runDeadline is the time the current run of the workflow has to continue as new by, see startRunDeadline, and
+		// outOfTime is set once a wait reached it.
+		runDeadline time.Time
+		outOfTime   bool
+	}
+
+	// CronState is what the cron workflow produced so far, it is carried over continue-as-new next to the
+	// ScheduleSpec. A nil state is a new schedule.
+	CronState struct {
+		// LastRunTime is the time the last successful run completed.
+		LastRunTime time.Time
+		// LastResults are the last results of the shards by shard index, the next run of a shard continues from its
+		// last result. The result of a failed shard is not updated. With overlapping runs, the run completing last wins.
+		LastResults    []CronJobResult
+		TotalRuns      uint
+		SuccessfulRuns uint
+		FailedRuns     uint
+		// ConsecutiveFailures counts the failed runs since the last successful one.
+		ConsecutiveFailures uint
+		// Jobs are the states of the jobs of a schedule with Jobs by name, the counters above count the runs of all
+		// jobs.
+		Jobs map[string]*JobState
+		// RecentRuns are the records of the last maxRecentRuns runs, oldest first.
+		RecentRuns []RunRecord
+		// StartTime is the start of the first run of the workflow, and FirstRunStarted and LastRunStarted are the start
+		// times of the first and the last run, see CronSummary.
+		StartTime       time.Time
+		FirstRunStarted time.Time
+		LastRunStarted  time.Time
+		// SkippedBySignal counts the runs skipped by the skipRun signal.
+		SkippedBySignal uint
+		// FailedItems are the JobInputs whose runs failed with FailureContinue.
+		FailedItems []FailedItem
+		// DeadLetters are the dead letters whose delivery failed, oldest first, and DroppedDeadLetters counts the ones
+		// dropped from the full buffer.
+		DeadLetters        []DeadLetter
+		DroppedDeadLetters uint
+		// BudgetDay is the start of the day the BudgetUsed was used on, the time the runs executed that day, and
+		// SkippedByBudget counts the runs skipped because the DailyBudget was used up, see cron_budget.go.
+		BudgetDay       time.Time
+		BudgetUsed      time.Duration
+		SkippedByBudget uint
+	}
+
+	// CronJobInput is the input of a job activity execution, e.g. of sampleCronActivity.
+	CronJobInput struct {
+		PendingJobCount uint
+		// ScheduledTime is the time the run was scheduled for. It is the start time of a manual run, and earlier than
+		// the start time of a late or backfilled run.
+		ScheduledTime time.Time
+		Shard         uint
+		// Attempt is the attempt of this shard of the run, it starts at 0 and is incremented by every retry.
+		Attempt uint
+		// Progress is the number of work items of the run that previous attempts of this shard completed.
+		Progress uint
+		// LastResult is the result of the last successful run of this shard.
+		LastResult CronJobResult
+		// Job is the name of the job of a schedule with Jobs, and JobInput its JobSpec.Input. The JobInput of a schedule
+		// without Jobs is its JSON JobInput.
+		Job      string
+		JobInput string
+		// JobInputIndex is the index of the JobInput in the JobInputs of a schedule that works through a list.
+		JobInputIndex uint
+		// TraceID is the TraceID of the schedule.
+		TraceID string
+		// Schedule is the name of the schedule, it tags the metrics of the activity.
+		Schedule string
+		// Run identifies the run the activity executes a shard of. The ScheduledTime and the Schedule above are its
+		// FireTime and its Schedule, they are kept for the job activities that read them.
+		Run RunContext
+	}
+
+	// RunContext identifies a run of the schedule to its job activities, a retry of a shard gets the same one.
+	RunContext struct {
+		// FireTime is the time the run was scheduled for, and DispatchTime the workflow time the run started at.
+		FireTime     time.Time
+		DispatchTime time.Time
+		// Sequence is the number of the run among all the runs the schedule started, across continue-as-new, the first
+		// run is 1.
+		Sequence   uint
+		Schedule   string
+		WorkflowID string
+		// Backfill is true for a missed run started by the CatchUpBackfill policy, and Manual for a run requested by
+		// the triggerNow signal.
+		Backfill bool
+		Manual   bool
+		// CorrelationID identifies the run to the systems its job talks to, see cron_correlation.go. It is empty for a
+		// run started before the change.
+		CorrelationID string
+	}
+
+	// CronJobResult is the result of a job activity execution.
+	CronJobResult struct {
+		// ProcessedBatches is the number of batches processed by the shard so far.
+		ProcessedBatches uint
+		// Payload is the JSON result of a job activity other than sampleCronActivity, the workflow doesn't read it.
+		Payload json.RawMessage
+	}
+
+	// dueRun is a run that is due to start.
+	dueRun struct {
+		// trigger is the manual trigger that requested the run, nil for a scheduled run.
+		trigger *TriggerNowRequest
+		// job is the job of a schedule with Jobs that is due, nil for the single job of a schedule without Jobs.
+		job           *JobSpec
+		scheduledTime time.Time
+		// backfill is true for a missed run taken from the Backlog.
+		backfill bool
 	}
 )
 
+// workItemDuration is how long sampleCronActivity takes for a work item.
+var workItemDuration = time.Second
+
 const (
 	// ApplicationName is the task list for this sample
 	ApplicationName = "cronGroup"
+	// cronWorkflowType is the name SampleCronWorkflow is registered with, the name of its function.
+	cronWorkflowType = "main.SampleCronWorkflow"
 
-	// timeouts for activity
-	scheduleToCloseTimeout = time.Minute * 10
+	// default timeouts for activity
 	scheduleToStartTimeout = time.Minute * 10
 	startToCloseTimeout    = time.Minute * 10
 	heartbeatTimeout       = time.Minute * 10
 
-	// timeout for workflow
+	// default timeout for workflow, a longer interval or a daily schedule derives a longer one, see
+	// defaultWorkflowTimeout.
 	workflowTimeout = time.Minute * 20
 	decisionTimeout = time.Minute * 1
 
 	// Every activity execution in workflow increases the size of workflow execution's history. We don't want the history
 	// grow to very large because large history is expensive to process. So, in this sample, we will create new workflow
-	// for every 10 job runs.
+	// for every 10 job runs, unless ScheduleSpec.MaxHistoryEvents is set.
 	loopCountBeforeContinueAsNew = 10
+
+	// sampleCronActivity fails the first attempts of every shard to show the retries.
+	transientFailureAttempts = 2
+	// errReasonDependencyUnavailable is the reason of the error for such a failed attempt, its details are the
+	// progress of the attempt.
+	errReasonDependencyUnavailable = "dependencyUnavailable"
+	// workItemsPerRun is the number of work items every shard processes in a run.
+	workItemsPerRun = 6
+	// errReasonInvalidShard is the reason of the error for a shard that doesn't exist, retrying doesn't help.
+	errReasonInvalidShard = "invalidShard"
 )
 
-func (s *ScheduleSpec) getDelayBeforeNextRun() time.Duration {
+// getDelayBeforeNextRun returns the delay of the next run after a wait that started at the given time, following the
+// given number of consecutive failed runs, and the number of runs skipped by the exclusions. The marker of the jitter
+// is added to the given history estimate.
+func (s *ScheduleSpec) getDelayBeforeNextRun(ctx cadence.Context, waitStart time.Time, failures uint,
+	history *historyEstimate) (time.Duration, uint) {
 	// For this sample, we use this naive solution. But you could have your own logic that meets your scheduling requirement.
-	return s.ScheduleInterval
+	nextRun := waitStart.Add(s.ScheduleInterval)
+	if s.AlignToInterval {
+		nextRun = nextBoundary(waitStart, s.ScheduleInterval)
+	}
+	if s.daily != nil {
+		nextRun = s.daily.next(waitStart)
+	}
+	if backoff := s.backoffInterval(failures); backoff > s.ScheduleInterval {
+		// a backed off run is due the backoff after the wait started, the alignment resumes after a success.
+		nextRun = waitStart.Add(backoff)
+	}
+	jitter := s.getJitter(ctx, history)
+
+	// Runs that would fire on an excluded day roll forward to the next slot of the schedule on an allowed day.
+	var skipped uint
+	for s.exclusions != nil && s.exclusions.excludes(nextRun.Add(jitter)) {
+		if s.daily != nil {
+			nextRun = s.daily.next(nextRun)
+			skipped++
+			continue
+		}
+		allowed := s.exclusions.nextAllowed(nextRun.Add(jitter))
+		slots := (allowed.Sub(nextRun.Add(jitter)) + s.ScheduleInterval - 1) / s.ScheduleInterval
+		nextRun = nextRun.Add(slots * s.ScheduleInterval)
+		skipped += uint(slots)
+	}
+	if s.daily != nil || skipped > 0 {
+		workflowLogger(ctx).Info("Cron job next run scheduled.",
+			zap.Time("NextRun", nextRun.Add(jitter)), zap.Uint("SkippedByExclusions", skipped))
+	}
+	return nextRun.Add(jitter).Sub(waitStart), skipped
 }
 
+// getJitter returns a random delay below Jitter. Random numbers are not deterministic, but replay has to come up with
+// the same jitter as the original execution, so the jitter is drawn with a side effect that records it in the history
+// as a marker, which is added to the given history estimate.
 //
-// This is registration process where you register all your workflows
-// and activity function handlers.
-//
-func init() {
-	cadence.RegisterWorkflow(SampleCronWorkflow)
-	cadence.RegisterActivity(sampleCronActivity)
+// Runs started before changeJitterSideEffect seed the random source from the run ID and the pending job count
+// instead, and keep doing so on replay. Their waits that keep the job count, e.g. after a skipped run or a manual run
+// with KeepJobCount, draw the same jitter again.
+func (s *ScheduleSpec) getJitter(ctx cadence.Context, history *historyEstimate) time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+
+	var jitter time.Duration
+	if s.drawsJitter() {
+		if err := cadence.SideEffect(ctx, func(cadence.Context) interface{} {
+			return time.Duration(rand.Int63n(int64(s.Jitter)))
+		}).Get(&jitter); err != nil {
+			// the value is encoded by the workflow itself, it only fails to decode if the code changed its type.
+			panic(err)
+		}
+		history.addMarker()
+	} else {
+		seed := fnv.New64a()
+		seed.Write([]byte(cadence.GetWorkflowInfo(ctx).WorkflowExecution.RunID))
+		binary.Write(seed, binary.LittleEndian, uint64(s.JobCount))
+		jitter = time.Duration(rand.New(rand.NewSource(int64(seed.Sum64()))).Int63n(int64(s.Jitter)))
+	}
+
+	workflowLogger(ctx).Info("Cron job delay jittered.", zap.Duration("Jitter", jitter))
+	return jitter
+}
+
+// drawsJitter returns true if the jitter of the waits of the run is drawn with a side effect.
+func (s *ScheduleSpec) drawsJitter() bool {
+	return s.Jitter > 0 && s.getVersion(changeJitterSideEffect, DefaultVersion, 1) >= 1
+}
+
+// describe returns the description of the schedule as it is now with the given fields, e.g. owner and purpose, for a
+// new workflow.
+func (s *ScheduleSpec) describe(fields map[string]string) map[string]string {
+	description := map[string]string{"jobCount": fmt.Sprint(s.JobCount)}
+	switch {
+	case len(s.Jobs) > 0:
+		jobs := make([]string, len(s.Jobs))
+		for i, job := range s.Jobs {
+			jobs[i] = fmt.Sprintf("%s every %v", job.Name, job.Interval)
+		}
+		description["schedule"] = strings.Join(jobs, ", ")
+	case s.TimeOfDay != "":
+		description["schedule"] = fmt.Sprintf("daily at %s %s", s.TimeOfDay, s.Timezone)
+	default:
+		description["schedule"] = fmt.Sprintf("every %v", s.ScheduleInterval)
+	}
+	for k, v := range fields {
+		description[k] = v
+	}
+	return description
+}
+
+// isPastDeadline returns true if the given time is after the deadline of the schedule.
+func (s *ScheduleSpec) isPastDeadline(t time.Time) bool {
+	return !s.NotAfter.IsZero() && t.After(s.NotAfter)
 }
 
 //
 // Cron sample job activity.
 //
-func sampleCronActivity(ctx context.Context, pendingJobCount uint) error {
-	cadence.GetActivityLogger(ctx).Info("Cron job running.",
-		zap.Uint("PendingJobCount", pendingJobCount))
-	// ...
-	return nil
+func sampleCronActivity(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+	// a worker that shuts down waits for the job, it is cancelled if the drain times out.
+	defer common.TrackActivity(ctx)()
+	ctx = withActivityTraceID(ctx, input.TraceID)
+	metrics := activityMetrics(ctx, input.Schedule)
+	defer metrics.Timer(metricJobLatency).Start().Stop()
+	if input.Attempt > 0 {
+		metrics.Counter(metricJobRetries).Inc(1)
+	}
+	logger := activityLogger(ctx)
+	// the input of the job may be sealed, it is opened with the keyring of the worker and not logged.
+	jobInput, err := common.ActivityKeyring(ctx).Open(input.JobInput)
+	if err != nil {
+		return CronJobResult{}, cadence.NewErrorWithDetails(errReasonInvalidInput, err.Error())
+	}
+	run := input.Run
+	logger.Info("Cron job running.", zap.String("Job", input.Job), zap.Uint("PendingJobCount", input.PendingJobCount),
+		zap.Time("ScheduledTime", input.ScheduledTime), zap.Uint("Shard", input.Shard), zap.Uint("Attempt", input.Attempt),
+		zap.Int("JobInputLength", len(jobInput)), zap.Time("FireTime", run.FireTime),
+		zap.Time("DispatchTime", run.DispatchTime), zap.Uint("Sequence", run.Sequence), zap.String("Schedule", run.Schedule),
+		zap.String("WorkflowID", run.WorkflowID), zap.Bool("Backfill", run.Backfill), zap.Bool("Manual", run.Manual),
+		zap.String("CorrelationID", run.CorrelationID))
+	if input.Shard >= maxParallelism {
+		// the shard is part of the input, a retry would get the same one.
+		return CronJobResult{}, cadence.NewErrorWithDetails(errReasonInvalidShard, input.Shard)
+	}
+	if input.Progress > 0 {
+		logger.Info("Cron job resuming, skipping items done by a previous attempt.", zap.Uint("Skipped", input.Progress))
+	}
+	// Simulates a dependency that becomes unavailable in the middle of the first attempts, the workflow retries the
+	// shard.
+	failAt := uint(workItemsPerRun)
+	if input.Attempt < transientFailureAttempts {
+		failAt = (input.Attempt + 1) * workItemsPerRun / (transientFailureAttempts + 1)
+	}
+	// every run processes the next batch after the one the last run of the shard stopped at.
+	batch := input.LastResult.ProcessedBatches + 1
+	for progress := input.Progress; progress < workItemsPerRun; progress++ {
+		if progress == failAt {
+			logger.Info("Cron job failed, please retry.", zap.Uint("Progress", progress))
+			// the progress reaches the next attempt through the workflow.
+			return CronJobResult{}, cadence.NewErrorWithDetails(errReasonDependencyUnavailable, progress)
+		}
+		select {
+		case <-ctx.Done():
+			// the context is cancelled when a heartbeat learns that the workflow cancelled the activity.
+			logger.Info("Cron job cancelled.", zap.Uint("Progress", progress))
+			return CronJobResult{}, ctx.Err()
+		case <-time.After(workItemDuration):
+		}
+		// ...
+		logger.Info("Cron job item processed.", zap.String("Item", fmt.Sprintf("%d/%d/%d", input.Shard, batch, progress)))
+		// the details of the last heartbeat are the progress of an attempt that timed out.
+		cadence.RecordActivityHeartbeat(ctx, progress+1)
+	}
+	result := CronJobResult{ProcessedBatches: batch}
+	publishBatch(logger, run.idempotencyKey(input.Shard), run.CorrelationID, result)
+	logger.Info("Cron job completed.", zap.Uint("ProcessedBatches", result.ProcessedBatches))
+	return result, nil
 }
 
-// SampleCronWorkflow workflow decider
-func SampleCronWorkflow(ctx cadence.Context, scheduleSpec ScheduleSpec) (err error) {
+// idempotencyKey returns the key of the side effects of the given shard of the run. The fire time and the sequence
+// identify the run, the retries of the shard and a backfill of a run that already ran get the same key.
+func (r RunContext) idempotencyKey(shard uint) string {
+	return fmt.Sprintf("%s/%d/%d", r.FireTime.UTC().Format(time.RFC3339), r.Sequence, shard)
+}
+
+// publishBatch stubs the side effect of sampleCronActivity, publishing the processed batch to a sink that drops what
+// it already has under the key. The correlation ID tells the sink which run the batch belongs to.
+func publishBatch(logger *zap.Logger, idempotencyKey, correlationID string, result CronJobResult) {
+	logger.Info("Cron job batch published.", zap.String("IdempotencyKey", idempotencyKey),
+		zap.String("CorrelationID", correlationID), zap.Uint("Batch", result.ProcessedBatches))
+}
+
+// waitForNextRun blocks until it is time to run the next job. It waits for the schedule interval, but while the
+// schedule is paused no job is launched and nothing is counted down. On resume the interval starts over from the time
+// of the resume, so runs missed during the pause are not caught up. A schedule update received during the wait
+// recalculates the remaining wait against the new interval, and a manual trigger ends the wait right away. A run that
+// is due while the previous run is still executing is handled by the OverlapPolicy, and the runs missed because the
+// wait ended late are handled by the CatchUpPolicy. Backfilled runs start one after the other before the next scheduled
+// run. It returns false instead when the deadline of the schedule is reached, a run failed, or the workflow is drained
+// or cancelled, and no more runs will happen. It returns false with outOfTime set when a schedule update makes the next
+// run due after the run of the workflow ends, which continues as new. A schedule with Jobs waits for the next run of
+// any of its jobs instead.
+func waitForNextRun(ctx cadence.Context, spec *ScheduleSpec, signals *cronSignals, jobs *cronJobs) (dueRun, bool) {
+	if len(spec.Jobs) > 0 {
+		return waitForNextJob(ctx, spec, signals, jobs)
+	}
+	for {
+		if spec.Paused && !waitForResume(ctx, spec, signals, jobs) {
+			return dueRun{}, false
+		}
+
+		waitStart := cadence.Now(ctx)
+		updated := false
+		for !spec.Paused {
+			if spec.Draining || jobs.err != nil || ctx.Err() != nil || spec.isPastDeadline(cadence.Now(ctx)) {
+				return dueRun{}, false
+			}
+			if trigger := spec.PendingTrigger; trigger != nil && jobs.canStart(spec.OverlapPolicy) {
+				spec.PendingTrigger = nil
+				return dueRun{trigger: trigger, scheduledTime: cadence.Now(ctx)}, true
+			}
+			if len(spec.Backlog) > 0 && jobs.canStart(spec.OverlapPolicy) {
+				scheduledTime := spec.Backlog[0]
+				spec.Backlog = spec.Backlog[1:]
+				workflowLogger(ctx).Info("Cron job backfilling missed run.",
+					zap.Time("ScheduledTime", scheduledTime), zap.Int("Backlog", len(spec.Backlog)))
+				return dueRun{scheduledTime: scheduledTime, backfill: true}, true
+			}
+			if spec.BufferedRun && jobs.canStart(spec.OverlapPolicy) {
+				spec.BufferedRun = false
+				workflowLogger(ctx).Info("Cron job starting buffered run.")
+				return dueRun{scheduledTime: cadence.Now(ctx)}, true
+			}
+
+			interval := spec.ScheduleInterval
+			failures := jobs.state.ConsecutiveFailures
+			timerCtx, cancelTimer := cadence.WithCancel(ctx)
+			timerFired := false
+			selector := cadence.NewSelector(ctx)
+			jobs.addFutures(ctx, selector)
+			delay, skipped := spec.getDelayBeforeNextRun(ctx, waitStart, failures, jobs.history)
+			if spec.backsOff() && failures > 0 {
+				workflowLogger(ctx).Info("Cron job next run backed off.", zap.Uint("ConsecutiveFailures", failures),
+					zap.Duration("Delay", delay))
+			}
+			runTime := waitStart.Add(delay)
+			initialWait := spec.waitsForStart()
+			if initialWait {
+				runTime, skipped = spec.initialRunTime(ctx), 0
+			} else if now := cadence.Now(ctx); runTime.Before(now) {
+				// a shorter interval can make the run due already, it is not late but runs right away.
+				runTime = now
+			}
+			afterDeadline := spec.isPastDeadline(runTime)
+			if afterDeadline {
+				// don't sleep past the deadline, manual runs can still be triggered until then.
+				runTime = spec.NotAfter
+			}
+			if updated && spec.pastRunDeadline(runTime) && !runTime.After(cadence.Now(ctx).Add(spec.runLength())) {
+				// the next run under the new interval is due after this run of the workflow, but before the next one.
+				spec.outOfTime = true
+				return dueRun{}, false
+			}
+			jobs.history.addTimer()
+			fireTime := runTime
+			if now := cadence.Now(ctx); fireTime.Before(now) {
+				// a first run backfilled for a StartAt that passed.
+				fireTime = now
+			}
+			selector.AddFuture(cadence.NewTimer(timerCtx, timerDelay(ctx, fireTime)), func(f cadence.Future) {
+				timerFired = true
+			})
+			signals.addReceives(ctx, selector, spec)
+			// the wait is cut short when the schedule changes or a run can start before the timer fires. A run that
+			// completes meanwhile changes the backoff of the wait.
+			interrupted := func() bool {
+				return spec.Paused || spec.Draining || spec.ScheduleInterval != interval || jobs.err != nil ||
+					(spec.backsOff() && jobs.state.ConsecutiveFailures != failures) ||
+					(jobs.canStart(spec.OverlapPolicy) &&
+						(spec.PendingTrigger != nil || len(spec.Backlog) > 0 || spec.BufferedRun))
+			}
+			for !timerFired && !interrupted() {
+				signals.selectSignals(ctx, selector, spec)
+			}
+			cancelTimer()
+			if ctx.Err() != nil {
+				// the timer fires when the workflow is cancelled.
+				return dueRun{}, false
+			}
+			updated = updated || spec.ScheduleInterval != interval
+
+			if timerFired {
+				if afterDeadline {
+					return dueRun{}, false
+				}
+				if skipped > 0 {
+					jobs.state.addRecentRun(RunRecord{ScheduledAt: runTime, Status: RunSkippedByExclusions,
+						ResultSummary: fmt.Sprintf("%d runs skipped due to blackout", skipped)})
+					spec.SkippedByExclusions += skipped
+					countSkipped(ctx, skipReasonExclusions, skipped)
+					workflowLogger(ctx).Info("Cron job runs skipped due to blackout.",
+						zap.Uint("Skipped", skipped), zap.Uint("TotalSkipped", spec.SkippedByExclusions))
+				}
+				// a pause or drain could arrive in the same decision as the timer.
+				signals.receivePending(ctx, spec)
+				if spec.Paused || spec.Draining {
+					continue
+				}
+				if initialWait {
+					// the first run is due, the regular schedule follows from it.
+					spec.StartAt = time.Time{}
+				} else {
+					// the timer fired in time, but the decision can run much later when no worker was available.
+					spec.catchUp(ctx, runTime, jobs.state.ConsecutiveFailures, jobs.history)
+				}
+				if !jobs.canStart(spec.OverlapPolicy) {
+					// the next run is scheduled from this one, even though it didn't start.
+					onOverlap(ctx, spec, jobs.state, runTime)
+					waitStart = cadence.Now(ctx)
+				} else if spec.PendingTrigger == nil {
+					return dueRun{scheduledTime: runTime}, true
+				}
+			}
+		}
+	}
+}
+
+// waitForResume blocks until the resume signal is received, it returns false if the deadline of the schedule is
+// reached, a run failed, the workflow is drained or cancelled first. A pause can last longer than the run of the
+// workflow, it returns false with outOfTime set at the deadline of the run, which continues as new still paused.
+func waitForResume(ctx cadence.Context, spec *ScheduleSpec, signals *cronSignals, jobs *cronJobs) bool {
+	workflowLogger(ctx).Info("Cron workflow paused, waiting for resume signal.")
+	if spec.pastRunDeadline(cadence.Now(ctx)) {
+		spec.outOfTime = true
+		return false
+	}
+	selector := cadence.NewSelector(ctx)
+	jobs.addFutures(ctx, selector)
+	deadlineReached := false
+	if !spec.NotAfter.IsZero() {
+		timerCtx, cancelTimer := cadence.WithCancel(ctx)
+		defer cancelTimer()
+		jobs.history.addTimer()
+		selector.AddFuture(cadence.NewTimer(timerCtx, timerDelay(ctx, spec.NotAfter)), func(f cadence.Future) {
+			deadlineReached = true
+		})
+	}
+	if !spec.runDeadline.IsZero() {
+		timerCtx, cancelTimer := cadence.WithCancel(ctx)
+		defer cancelTimer()
+		jobs.history.addTimer()
+		selector.AddFuture(cadence.NewTimer(timerCtx, timerDelay(ctx, spec.runDeadline)), func(f cadence.Future) {
+			spec.outOfTime = true
+		})
+	}
+	selector.AddReceive(ctx.Done(), func(c cadence.Channel, more bool) {})
+	signals.addReceives(ctx, selector, spec)
+	for spec.Paused && !spec.Draining && !deadlineReached && !spec.outOfTime && jobs.err == nil && ctx.Err() == nil {
+		signals.selectSignals(ctx, selector, spec)
+	}
+	if spec.Draining || deadlineReached || jobs.err != nil || ctx.Err() != nil {
+		return false
+	}
+	if spec.outOfTime {
+		workflowLogger(ctx).Info("Cron workflow still paused at the deadline of the run.",
+			zap.Time("RunDeadline", spec.runDeadline))
+		return false
+	}
+	workflowLogger(ctx).Info("Cron workflow resumed.")
+	return true
+}
+
+// timerDelay returns the delay of a timer that fires at the given time. Timers have a resolution of seconds, the
+// delay is rounded up so that the timer never fires early.
+func timerDelay(ctx cadence.Context, fireTime time.Time) time.Duration {
+	return (fireTime.Sub(cadence.Now(ctx)) + time.Second - 1).Truncate(time.Second)
+}
+
+// SampleCronWorkflow workflow decider, it returns the summary of the schedule once it closed, see cron_summary.go.
+func SampleCronWorkflow(ctx cadence.Context, scheduleSpec ScheduleSpec, state *CronState) (CronSummary, error) {
+	if state == nil {
+		state = &CronState{}
+	}
+	// the deadline of a schedule that continued as new may have passed in between, it closes on its own.
+	var now time.Time
+	if state.StartTime.IsZero() {
+		now = cadence.Now(ctx)
+	}
+	if err := scheduleSpec.Validate(now); err != nil {
+		workflowLogger(ctx).Error("Cron workflow started with invalid schedule.", zap.Error(err))
+		return CronSummary{}, invalidSpecError(err)
+	}
+	if state.StartTime.IsZero() {
+		// the first run of the workflow, the next ones get the time with the state.
+		state.StartTime = cadence.Now(ctx)
+		scheduleSpec.startInitialWait(state.StartTime)
+	}
+	scheduleSpec.startRunDeadline(cadence.Now(ctx))
+
+	ctx = withScheduleName(ctx, scheduleSpec.Name)
+	ctx = withTraceID(ctx, scheduleSpec.TraceID)
+
+	scheduleSpec.startJobInputs()
 	if scheduleSpec.JobCount == 0 {
 		// should not happen... but if it does, there is nothing to do, since we are done here.
-		cadence.GetLogger(ctx).Info("Cron workflow started with 0 JobCount.")
-		return nil
+		workflowLogger(ctx).Info("Cron workflow started with 0 JobCount.")
+		return summarize(ctx, &scheduleSpec, state, summaryCompleted), nil
 	}
 
-	cadence.GetLogger(ctx).Info("Cron workflow started.",
-		zap.Duration("IntervalInterval", scheduleSpec.ScheduleInterval),
-		zap.Uint("ScheduledCount", scheduleSpec.JobCount))
+	workflowLogger(ctx).Info("Cron workflow started.",
+		zap.String("Schedule", scheduleSpec.Name),
+		zap.Duration("ScheduleInterval", scheduleSpec.ScheduleInterval),
+		zap.Bool("AlignToInterval", scheduleSpec.AlignToInterval),
+		zap.String("TimeOfDay", scheduleSpec.TimeOfDay),
+		zap.String("Timezone", scheduleSpec.Timezone),
+		zap.Duration("Jitter", scheduleSpec.Jitter),
+		zap.Time("NotAfter", scheduleSpec.NotAfter),
+		zap.Stringer("OverlapPolicy", scheduleSpec.OverlapPolicy),
+		zap.Uint("Parallelism", scheduleSpec.Parallelism),
+		zap.Bool("RunAsChildWorkflow", scheduleSpec.RunAsChildWorkflow),
+		zap.Bool("HostAffinity", scheduleSpec.HostAffinity != nil),
+		zap.Stringer("FailurePolicy", scheduleSpec.FailurePolicy),
+		zap.Stringer("CatchUpPolicy", scheduleSpec.CatchUpPolicy),
+		zap.Duration("DailyBudget", scheduleSpec.DailyBudget),
+		zap.Stringer("CorrelationIDs", scheduleSpec.CorrelationIDs),
+		zap.Uint("MaxHistoryEvents", scheduleSpec.MaxHistoryEvents),
+		zap.Int("Backlog", len(scheduleSpec.Backlog)),
+		zap.Int("Jobs", len(scheduleSpec.Jobs)),
+		zap.Int("JobInputs", len(scheduleSpec.JobInputs)),
+		zap.Bool("ConfigSource", scheduleSpec.ConfigSource != nil),
+		zap.Any("Description", scheduleSpec.Description),
+		zap.Uint("ScheduledCount", scheduleSpec.JobCount),
+		zap.Uint("TotalRuns", state.TotalRuns),
+		zap.Time("LastRunTime", state.LastRunTime))
 
-	ao := cadence.ActivityOptions{
-		ScheduleToStartTimeout: scheduleToStartTimeout,
-		StartToCloseTimeout:    startToCloseTimeout,
-		HeartbeatTimeout:       heartbeatTimeout,
-	}
+	timeouts := scheduleSpec.timeouts()
+	ao := scheduleSpec.activityOptions()
 	ctx1 := cadence.WithActivityOptions(ctx, ao)
 
-	for i := 0; i < loopCountBeforeContinueAsNew && scheduleSpec.JobCount > 0; i++ {
-		scheduleSpec.JobCount--
+	history := newHistoryEstimate()
+	jobs := newCronJobs(&scheduleSpec, state, history, newCronLeases(ctx, &scheduleSpec, history))
+	signals := newCronSignals(ctx, history, jobs)
 
-		sleepDuration := scheduleSpec.getDelayBeforeNextRun()
-		cadence.Sleep(ctx, sleepDuration)
+	for runs := 0; scheduleSpec.JobCount > 0 &&
+		!scheduleSpec.continueAsNewDue(runs, history, cadence.Now(ctx), state.ConsecutiveFailures); runs++ {
+		if source := scheduleSpec.ConfigSource; source != nil && source.refreshDue(runs) {
+			// the config takes effect with the wait for this run.
+			refreshConfig(ctx1, &scheduleSpec, history)
+		}
+		run, ok := waitForNextRun(ctx, &scheduleSpec, signals, jobs)
+		if !ok && ctx.Err() != nil {
+			return CronSummary{}, onCancel(ctx, ao, &scheduleSpec, state, jobs)
+		}
+		if !ok && jobs.err != nil {
+			// The shards of the run were already retried according to the RetryPolicy of the schedule.
+			workflowLogger(ctx).Error("Cron workflow aborted.", zap.Error(jobs.err),
+				zap.Uint("FailedRuns", state.FailedRuns))
+			return CronSummary{}, jobs.err
+		}
+		if !ok && scheduleSpec.Draining {
+			return onDrain(ctx, ao, &scheduleSpec, state, jobs)
+		}
+		if !ok && scheduleSpec.outOfTime {
+			// the runs in progress complete before the workflow continues as new below.
+			break
+		}
+		if !ok {
+			workflowLogger(ctx).Info("Cron workflow reached its deadline.",
+				zap.Time("NotAfter", scheduleSpec.NotAfter), zap.Uint("AbandonedRuns", scheduleSpec.JobCount))
+			if err := jobs.wait(ctx); err != nil {
+				return CronSummary{}, err
+			}
+			return closeSchedule(ctx1, &scheduleSpec, state, summaryDeadlineReached), nil
+		}
+		if trigger := run.trigger; trigger != nil {
+			workflowLogger(ctx).Info("Cron job triggered manually.", zap.Bool("KeepJobCount", trigger.KeepJobCount))
+		}
+		if jobs.skipsOverBudget(ctx, run) {
+			// the skipped run doesn't count against the job count, like a run skipped by the OverlapPolicy, but it
+			// counts against the runs before continue-as-new, see cron_budget.go.
+			continue
+		}
+		if run.trigger == nil || !run.trigger.KeepJobCount {
+			scheduleSpec.JobCount--
+		}
 
-		err = cadence.ExecuteActivity(ctx1, sampleCronActivity, scheduleSpec.JobCount).Get(ctx, nil)
-		if err != nil {
-			// Appropriate retries needed for the workflow business logic.
-			// - The activity can be retired on multiple failures look at cadence.ExecuteActivity documentation to
-			// see what possible errors it can return.
-			// - look at our sample recipes/retryActivity.
-			return err
+		if run.job != nil {
+			jobs.startJob(ctx1, run)
+		} else {
+			jobs.start(ctx1, run)
 		}
 	}
 
+	// the runs in progress have to complete in this run of the workflow, their results can't reach the next one.
+	if err := jobs.wait(ctx); err != nil {
+		return CronSummary{}, err
+	}
+	if ctx.Err() != nil {
+		return CronSummary{}, onCancel(ctx, ao, &scheduleSpec, state, jobs)
+	}
+
 	if scheduleSpec.JobCount == 0 {
 		// done with this cron workflow
-		cadence.GetLogger(ctx).Info("Cron workflow completed.")
-		return nil
+		workflowLogger(ctx).Info("Cron workflow completed.")
+		return closeSchedule(ctx1, &scheduleSpec, state, summaryCompleted), nil
 	}
 
-	// schedule next cron job
-	ctx = cadence.WithExecutionStartToCloseTimeout(ctx, workflowTimeout)
-	ctx = cadence.WithWorkflowTaskStartToCloseTimeout(ctx, decisionTimeout)
+	// schedule next cron job. The next run starts with empty signal channels, the signals received so far are carried
+	// over to it through the spec, including the ones that arrived with the last decision of this run.
+	drained := signals.receivePending(ctx, &scheduleSpec)
+	if scheduleSpec.Draining {
+		return onDrain(ctx, ao, &scheduleSpec, state, jobs)
+	}
+	workflowLogger(ctx).Info("Cron workflow continuing as new.", zap.Uint("EstimatedHistoryEvents", history.events),
+		zap.Int("DrainedSignals", drained))
+	scheduleSpec.withLatestVersions()
+	ctx = cadence.WithExecutionStartToCloseTimeout(ctx, timeouts.Workflow)
+	ctx = cadence.WithWorkflowTaskStartToCloseTimeout(ctx, timeouts.Decision)
 
-	return cadence.NewContinueAsNewError(ctx, SampleCronWorkflow, scheduleSpec)
+	return CronSummary{}, cadence.NewContinueAsNewError(ctx, SampleCronWorkflow, scheduleSpec, state)
 }
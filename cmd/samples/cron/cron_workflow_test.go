@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"os"
+	"reflect"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/cadence"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type UnitTestSuite struct {
@@ -14,14 +20,24 @@ type UnitTestSuite struct {
 	cadence.WorkflowTestSuite
 }
 
+func TestMain(m *testing.M) {
+	// the tests execute the workflows and the activities of a combined worker with its interceptors.
+	installInterceptors()
+	workerRoles[modeWorker].register()
+	cadence.RegisterActivity(panickingActivity)
+	os.Exit(m.Run())
+}
+
 func TestUnitTestSuite(t *testing.T) {
+	// the work items of sampleCronActivity are processed right away.
+	workItemDuration = 0
 	suite.Run(t, new(UnitTestSuite))
 }
 
 func (s *UnitTestSuite) Test_CronWorkflow_SmallCount() {
 	env := s.NewTestWorkflowEnvironment()
-	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(nil).Times(3)
-	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour})
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(3)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Hour}, &CronState{})
 
 	s.True(env.IsWorkflowCompleted())
 	s.NoError(env.GetWorkflowError())
@@ -30,8 +46,8 @@ func (s *UnitTestSuite) Test_CronWorkflow_SmallCount() {
 
 func (s *UnitTestSuite) Test_CronWorkflow_LargeCount() {
 	env := s.NewTestWorkflowEnvironment()
-	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(nil).Times(10)
-	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour})
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(10)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour}, &CronState{})
 
 	s.True(env.IsWorkflowCompleted())
 	s.NotNil(env.GetWorkflowError())
@@ -39,3 +55,231 @@ func (s *UnitTestSuite) Test_CronWorkflow_LargeCount() {
 	s.True(ok)
 	env.AssertExpectations(s.T())
 }
+
+func (s *UnitTestSuite) Test_CronWorkflow_PendingJobCount() {
+	env := s.NewTestWorkflowEnvironment()
+	var pending []uint
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		pending = append(pending, input.PendingJobCount)
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 4, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	// the job count is counted down before every run, the mock clock skips the intervals.
+	s.Equal([]uint{3, 2, 1, 0}, pending)
+	s.Equal([]time.Duration{time.Hour, time.Hour * 2, time.Hour * 3, time.Hour * 4}, runTimes)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ContinueAsNewSpec() {
+	env := s.NewTestWorkflowEnvironment()
+	var pending []uint
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		pending = append(pending, input.PendingJobCount)
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 25, ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	_, ok := env.GetWorkflowError().(cadence.ContinueAsNewError)
+	s.True(ok)
+	s.Len(pending, loopCountBeforeContinueAsNew)
+	s.Equal(uint(15), pending[len(pending)-1])
+	// the next run gets the jobs that are left, with the same interval.
+	args := continueAsNewArgs(env.GetWorkflowError())
+	spec := args[0].(ScheduleSpec)
+	s.Equal(uint(15), spec.JobCount)
+	s.Equal(time.Hour, spec.ScheduleInterval)
+	s.Equal(uint(loopCountBeforeContinueAsNew), args[1].(*CronState).TotalRuns)
+	s.Equal(time.Hour*loopCountBeforeContinueAsNew, env.Now().Sub(time.Unix(0, 0)))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_ZeroJobCount() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		s.Fail("no job must run")
+		return CronJobResult{}, nil
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{ScheduleInterval: time.Hour}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	// the workflow returns right away, without waiting for an interval.
+	s.Equal(time.Duration(0), env.Now().Sub(time.Unix(0, 0)))
+}
+
+func (s *UnitTestSuite) Test_SampleCronActivity() {
+	core, logs := observer.New(zap.InfoLevel)
+	s.SetLogger(zap.New(core))
+	env := s.NewTestActivityEnvironment()
+	s.SetLogger(nil)
+	// the last item of a retry after the transient failures, the batch follows the one of the last run.
+	value, err := env.ExecuteActivity(sampleCronActivity, CronJobInput{PendingJobCount: 7, Shard: 1,
+		Attempt: transientFailureAttempts, Progress: workItemsPerRun - 1, LastResult: CronJobResult{ProcessedBatches: 2}})
+	s.NoError(err)
+	var result CronJobResult
+	s.NoError(value.Get(&result))
+	s.Equal(CronJobResult{ProcessedBatches: 3}, result)
+
+	s.Equal(1, logs.FilterMessage("Cron job running.").FilterField(zap.Uint("PendingJobCount", 7)).Len())
+	s.Equal(1, logs.FilterMessage("Cron job item processed.").FilterField(zap.String("Item", "1/3/5")).Len())
+	s.Equal(1, logs.FilterMessage("Cron job completed.").FilterField(zap.Uint("ProcessedBatches", 3)).Len())
+	// every line of the activity names the activity it logs for.
+	s.Equal(logs.Len(), logs.FilterField(zap.String("ActivityType", getFunctionName(sampleCronActivity))).Len())
+}
+
+func (s *UnitTestSuite) Test_SampleCronActivity_RunContext() {
+	core, logs := observer.New(zap.InfoLevel)
+	s.SetLogger(zap.New(core))
+	env := s.NewTestActivityEnvironment()
+	s.SetLogger(nil)
+	fireTime := time.Date(2020, 3, 1, 2, 0, 0, 0, time.UTC)
+	run := RunContext{FireTime: fireTime, DispatchTime: fireTime.Add(time.Hour), Sequence: 42, Schedule: "nightly",
+		WorkflowID: "cron_nightly", Backfill: true, CorrelationID: "run-42"}
+	_, err := env.ExecuteActivity(sampleCronActivity, CronJobInput{Shard: 1, Attempt: transientFailureAttempts,
+		Progress: workItemsPerRun - 1, Run: run})
+	s.NoError(err)
+
+	s.Equal(1, logs.FilterMessage("Cron job running.").FilterField(zap.Time("FireTime", fireTime)).
+		FilterField(zap.Uint("Sequence", 42)).FilterField(zap.String("WorkflowID", "cron_nightly")).
+		FilterField(zap.Bool("Backfill", true)).FilterField(zap.Bool("Manual", false)).
+		FilterField(zap.String("CorrelationID", "run-42")).Len())
+	s.Equal(1, logs.FilterMessage("Cron job batch published.").
+		FilterField(zap.String("IdempotencyKey", "2020-03-01T02:00:00Z/42/1")).
+		FilterField(zap.String("CorrelationID", "run-42")).Len())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DescriptionSurvivesContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(updateScheduleSignalName, ScheduleUpdate{ScheduleInterval: time.Minute})
+	}, time.Minute*30)
+	spec := ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour}
+	spec.Description = spec.describe(map[string]string{"owner": "payments"})
+	env.ExecuteWorkflow(SampleCronWorkflow, spec, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	args := continueAsNewArgs(env.GetWorkflowError())
+	next := args[0].(ScheduleSpec)
+	s.Equal(time.Minute, next.ScheduleInterval)
+	s.Equal(map[string]string{"schedule": "every 1h0m0s", "jobCount": "20", "owner": "payments"}, next.Description)
+}
+
+func (s *UnitTestSuite) Test_ScheduleSpec_Describe() {
+	s.Equal(map[string]string{"schedule": "every 1m0s", "jobCount": "3"},
+		(&ScheduleSpec{JobCount: 3, ScheduleInterval: time.Minute}).describe(nil))
+	s.Equal(map[string]string{"schedule": "daily at 02:00 UTC", "jobCount": "1", "purpose": "cleanup"},
+		(&ScheduleSpec{JobCount: 1, TimeOfDay: "02:00", Timezone: "UTC"}).describe(map[string]string{"purpose": "cleanup"}))
+	s.Equal(map[string]string{"schedule": "fast every 1m0s, slow every 1h0m0s", "jobCount": "0"},
+		(&ScheduleSpec{Jobs: []JobSpec{{Name: "fast", Interval: time.Minute}, {Name: "slow", Interval: time.Hour}}}).describe(nil))
+	// the fields override the description of the schedule.
+	s.Equal("hourly", (&ScheduleSpec{ScheduleInterval: time.Hour}).describe(map[string]string{"schedule": "hourly"})["schedule"])
+}
+
+// continueAsNewArgs returns the arguments of the next run carried by a ContinueAsNewError. The client does not expose
+// them, so they are read from the unexported field.
+func continueAsNewArgs(err error) []interface{} {
+	field := reflect.ValueOf(err).Elem().FieldByName("args")
+	return *(*[]interface{})(unsafe.Pointer(field.UnsafeAddr()))
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DeadlineDuringSleep() {
+	env := s.NewTestWorkflowEnvironment()
+	var runTimes []time.Duration
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runTimes = append(runTimes, env.Now().Sub(time.Unix(0, 0)))
+		return CronJobResult{}, nil
+	})
+	notAfter := time.Unix(0, 0).Add(time.Minute * 150)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, NotAfter: notAfter}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]time.Duration{time.Hour, time.Hour * 2}, runTimes)
+	// the workflow doesn't sleep until the run after the deadline.
+	s.Equal(notAfter, env.Now())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DeadlineDuringActivity() {
+	env := s.NewTestWorkflowEnvironment()
+	var runs int
+	env.OverrideActivity(sampleCronActivity, func(ctx context.Context, input CronJobInput) (CronJobResult, error) {
+		runs++
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, CronJobResult{}, nil)
+		}, time.Hour)
+		return CronJobResult{}, cadence.ErrActivityResultPending
+	})
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour,
+		NotAfter: time.Unix(0, 0).Add(time.Minute * 90)}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(1, runs)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DeadlineWhilePaused() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil).Times(1)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(pauseSignalName, "incident")
+	}, time.Minute*90)
+	notAfter := time.Unix(0, 0).Add(time.Hour * 3)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 5, ScheduleInterval: time.Hour, NotAfter: notAfter}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(notAfter, env.Now())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_DeadlineSurvivesContinueAsNew() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{}, nil)
+	notAfter := time.Unix(0, 0).Add(time.Hour * 100)
+	env.ExecuteWorkflow(SampleCronWorkflow, ScheduleSpec{JobCount: 20, ScheduleInterval: time.Hour, NotAfter: notAfter}, &CronState{})
+
+	s.True(env.IsWorkflowCompleted())
+	spec := continueAsNewArgs(env.GetWorkflowError())[0].(ScheduleSpec)
+	s.Equal(notAfter, spec.NotAfter)
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_StateAcrossContinueAsNew() {
+	spec := ScheduleSpec{JobCount: 25, ScheduleInterval: time.Minute, Parallelism: 2,
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3}}
+	args := []interface{}{spec, &CronState{}}
+	for generation := uint(1); generation <= 2; generation++ {
+		env := s.NewTestWorkflowEnvironment()
+		env.ExecuteWorkflow(SampleCronWorkflow, args...)
+
+		s.True(env.IsWorkflowCompleted())
+		args = continueAsNewArgs(env.GetWorkflowError())
+		state := args[1].(*CronState)
+		runs := generation * loopCountBeforeContinueAsNew
+		s.Equal(runs, state.TotalRuns)
+		s.Equal(runs, state.SuccessfulRuns)
+		s.Equal(uint(0), state.FailedRuns)
+		// every run continued from the result of the last one, also in the next workflow run.
+		s.Equal([]CronJobResult{{ProcessedBatches: runs}, {ProcessedBatches: runs}}, state.LastResults)
+		// the last run started after 10 minutes and retried twice, after 1s and 2s.
+		s.Equal(time.Unix(0, 0).Add(time.Minute*10+time.Second*3), state.LastRunTime)
+	}
+}
+
+func (s *UnitTestSuite) Test_CronWorkflow_NilState() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleCronActivity, mock.Anything, mock.Anything).Return(CronJobResult{ProcessedBatches: 1}, nil)
+	// a nil state can't be encoded as workflow input, so the workflow function is called directly.
+	var state *CronState
+	env.ExecuteWorkflow(func(ctx cadence.Context, spec ScheduleSpec) (CronSummary, error) {
+		return SampleCronWorkflow(ctx, spec, state)
+	}, ScheduleSpec{JobCount: 2, ScheduleInterval: time.Minute})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
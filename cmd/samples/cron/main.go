@@ -1,71 +1,643 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/samarabbas/cadence-samples/cmd/samples/common"
 
 	"github.com/pborman/uuid"
-	"go.uber.org/cadence"
+	"go.uber.org/zap"
 )
 
 // The cron job can be scheduled with a specified timer interval, if you need cron at a shorter durations less than
 // a few minutes on production load, talk to cadence team before doing so. We might have better solutions for you.
 var cronSchedule = ScheduleSpec{JobCount: 5, ScheduleInterval: time.Minute * 10}
 
-// This needs to be done as part of a bootstrap step when the process starts.
-// The workers are supposed to be long running.
-func startWorkers(h *common.SampleHelper) {
-	// Configure worker options.
-	workerOptions := cadence.WorkerOptions{
-		MetricsScope: h.Scope,
-		Logger:       h.Logger,
-	}
-	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
-}
+// runnerOutput is the -output of the runner, text or json.
+var runnerOutput = outputText
 
 //
 // To start instance of the workflow.
 //
-func startWorkflow(h *common.SampleHelper) {
+func startWorkflow(h *common.SampleHelper, replace, upsert, wait bool, command cliCommand) int {
 	// This workflow ID can be user business logic identifier as well.
 	workflowID := "cron_" + uuid.New()
-	workflowOptions := cadence.StartWorkflowOptions{
-		ID:                              workflowID,
-		TaskList:                        ApplicationName,
-		ExecutionStartToCloseTimeout:    time.Minute,
-		DecisionTaskStartToCloseTimeout: time.Minute,
+	if cronSchedule.Name != "" {
+		workflowID = scheduleWorkflowID(cronSchedule.Name)
+	}
+	command.WorkflowID = workflowID
+	// the errors are logged, or printed as JSON with -output json.
+	fail := func(message string, err error) int {
+		if command.JSON {
+			return reportError(os.Stderr, command, err)
+		}
+		h.Logger.Error(message, zap.Error(err))
+		return exitCode(err)
+	}
+	// a new workflow takes the new path of every change of the workflow code.
+	cronSchedule.withLatestVersions()
+	if cronSchedule.TraceID == "" {
+		cronSchedule.TraceID = uuid.New()
+	}
+	// the workflow fails an invalid spec right away, the starter doesn't start it.
+	if err := cronSchedule.Validate(time.Now()); err != nil {
+		return fail("Invalid schedule, the workflow is not started.", cliUsageError{err})
+	}
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		return fail("Failed to build cadence client.", err)
+	}
+	var result triggerResult
+	err = withTimeout(command.Timeout, func() (err error) {
+		result, err = triggerSchedule(workflowClient, cronSchedule.startOptions(workflowID), replace, upsert,
+			cronSchedule)
+		return err
+	})
+	if running, ok := err.(errScheduleRunning); ok && !command.JSON {
+		h.Logger.Error("Schedule already running, use -replace to terminate it and start a new one.",
+			zap.String("WorkflowID", running.WorkflowID), zap.String("RunID", running.RunID))
+		return exitAlreadyExists
+	}
+	if err != nil && upsert {
+		return fail("Failed to start or update workflow", err)
+	}
+	if err != nil {
+		return fail("Failed to create workflow", err)
+	}
+	if !command.JSON {
+		message := "Started Workflow"
+		if result.AlreadyRunning {
+			message = "Signaled running Workflow with the new schedule"
+		}
+		h.Logger.Info(message, zap.String("WorkflowID", result.WorkflowID), zap.String("RunID", result.RunID),
+			zap.Bool("AlreadyRunning", result.AlreadyRunning), zap.String("TraceID", result.TraceID))
+	}
+	if wait {
+		h.Logger.Info("Waiting for the schedule to close.", zap.String("WorkflowID", result.WorkflowID))
+		summary, err := waitForSummary(workflowClient, result.WorkflowID, result.RunID)
+		if err != nil {
+			return fail("Schedule closed without a summary.", err)
+		}
+		if !command.JSON {
+			printSummary(os.Stdout, summary)
+		}
+		result.Summary = &summary
 	}
-	h.StartWorkflow(workflowOptions, SampleCronWorkflow, cronSchedule)
+	if command.JSON {
+		printJSONLine(os.Stdout, result)
+	}
+	return 0
+}
+
+// checkFlags returns an error for flags that contradict each other, given the names of the flags that are set on the
+// command line.
+func checkFlags(set map[string]bool, jobCount uint) error {
+	if set["i"] && set["t"] {
+		return errors.New("-i and -t are mutually exclusive, the schedule is by interval or by time of day")
+	}
+	if set["jobs"] && (set["i"] || set["t"]) {
+		return errors.New("-jobs can't be combined with -i or -t, every job has its own interval")
+	}
+	if set["metrics"] && set["prometheus"] {
+		return errors.New("-metrics and -prometheus are mutually exclusive, the metrics are logged or served")
+	}
+	if set["upsert"] && set["replace"] {
+		return errors.New("-upsert and -replace are mutually exclusive, the running schedule is updated or replaced")
+	}
+	if set["startAggregator"] && !set["aggregator"] {
+		return errors.New("-startAggregator needs -aggregator, the workflow ID of the aggregator to start")
+	}
+	if set["upsert"] && !set["name"] {
+		return errors.New("-upsert needs -name, only a named schedule can be found again")
+	}
+	if jobCount == 0 {
+		return errors.New("-c must be positive, a schedule without runs completes right away")
+	}
+	return nil
+}
+
+// usageError prints the error and the usage of the flags, or the error as JSON with -output json, and exits.
+func usageError(err error) {
+	if runnerOutput == outputJSON {
+		os.Exit(reportError(os.Stderr, cliCommand{JSON: true}, cliUsageError{err}))
+	}
+	fmt.Fprintln(os.Stderr, err)
+	flag.Usage()
+	os.Exit(exitUsage)
+}
+
+func parseExclusions(weekdays, dates string) (Exclusions, error) {
+	var exclusions Exclusions
+	for _, name := range strings.Split(weekdays, ",") {
+		if name == "" {
+			continue
+		}
+		found := false
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(weekday.String(), strings.TrimSpace(name)) {
+				exclusions.Weekdays = append(exclusions.Weekdays, weekday)
+				found = true
+			}
+		}
+		if !found {
+			return Exclusions{}, fmt.Errorf("unknown weekday %q in -xw", name)
+		}
+	}
+	for _, date := range strings.Split(dates, ",") {
+		if date != "" {
+			exclusions.Dates = append(exclusions.Dates, strings.TrimSpace(date))
+		}
+	}
+	return exclusions, nil
+}
+
+// parseJobInputs parses the JSON array of the inputs of the runs, or the file with it.
+func parseJobInputs(inputs string) ([]json.RawMessage, error) {
+	if inputs == "" {
+		return nil, nil
+	}
+	data, err := readInput(inputs)
+	if err != nil {
+		return nil, err
+	}
+	var parsed []json.RawMessage
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("inputs are not a JSON array: %v", err)
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("inputs are an empty list, the schedule would have nothing to run")
+	}
+	return parsed, nil
+}
+
+func parseJobs(jobs string) ([]JobSpec, error) {
+	var specs []JobSpec
+	for _, job := range strings.Split(jobs, ",") {
+		if job == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(job), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("job %q in -jobs is not name=interval", job)
+		}
+		// the interval may be followed by the task list of the job, e.g. reports=1m@staging.
+		schedule := strings.SplitN(parts[1], "@", 2)
+		interval, err := time.ParseDuration(schedule[0])
+		if err != nil {
+			return nil, fmt.Errorf("job %q in -jobs has no valid interval: %v", job, err)
+		}
+		spec := JobSpec{Name: parts[0], Interval: interval}
+		if len(schedule) == 2 {
+			spec.TaskList = schedule[1]
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// printRecentRuns prints the records of the recent runs as a table, oldest first.
+func printRecentRuns(out io.Writer, runs []RunRecord) {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSCHEDULED\tSTARTED\tCOMPLETED\tSTATUS\tCORRELATION ID\tRESULT\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", run.Job, formatTime(run.ScheduledAt),
+			formatTime(run.StartedAt), formatTime(run.CompletedAt), run.Status, run.CorrelationID, run.ResultSummary, run.Error)
+	}
+	w.Flush()
+}
+
+func parseFields(fields string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, field := range strings.Split(fields, ",") {
+		if field == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("field %q in -describe is not key=value", field)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+func parseOverlapPolicy(name string) (OverlapPolicy, error) {
+	for policy := OverlapSkip; policy <= OverlapAllowAll; policy++ {
+		if strings.EqualFold(policy.String(), name) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overlap policy %q, the policies are Skip, BufferOne and AllowAll", name)
+}
+
+func parseFailurePolicy(name string) (FailurePolicy, error) {
+	for policy := FailureAbort; policy <= FailureContinue; policy++ {
+		if strings.EqualFold(policy.String(), name) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown failure policy %q, the policies are Abort and Continue", name)
+}
+
+func parseCatchUpPolicy(name string) (CatchUpPolicy, error) {
+	for policy := CatchUpSkip; policy <= CatchUpBackfill; policy++ {
+		if strings.EqualFold(policy.String(), name) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown catch-up policy %q, the policies are Skip and Backfill", name)
+}
+
+func parseCorrelationIDScheme(name string) (CorrelationIDScheme, error) {
+	for scheme := CorrelationRandom; scheme <= CorrelationDeterministic; scheme++ {
+		if strings.EqualFold(scheme.String(), name) {
+			return scheme, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown correlation ID scheme %q, the schemes are Random and Deterministic", name)
+}
+
+// scheduleFlags are the flags of the schedule of a new workflow.
+type scheduleFlags struct {
+	IntervalSeconds        uint
+	AlignToInterval        bool
+	JitterSeconds          uint
+	TimeOfDay              string
+	Timezone               string
+	ExcludedWeekdays       string
+	ExcludedDates          string
+	InitialDelaySeconds    uint
+	StartAt                string
+	DurationSeconds        uint
+	JobActivity            string
+	JobInput               string
+	JobInputs              string
+	ScheduleConfig         string
+	ConfigRefresh          uint
+	ActivityTaskList       string
+	JobTaskList            string
+	Jobs                   string
+	OverlapPolicy          string
+	Parallelism            uint
+	CancelShards           bool
+	RunAsChild             bool
+	PinToHost              bool
+	Lock                   string
+	LockPermits            uint
+	LeaseTimeoutSeconds    uint
+	RetryAttempts          uint
+	RetrySeconds           uint
+	FailurePolicy          string
+	DeadLetter             string
+	MaxFailures            uint
+	BackoffCoefficient     float64
+	MaxBackoffSeconds      uint
+	DailyBudgetSeconds     uint
+	CatchUpPolicy          string
+	CorrelationIDs         string
+	ScheduleToStartSeconds uint
+	StartToCloseSeconds    uint
+	HeartbeatSeconds       uint
+	WorkflowTimeoutSeconds uint
+	DecisionTimeoutSeconds uint
+	MaxHistoryEvents       uint
+	JobCount               uint
+	Name                   string
+	TraceID                string
+	Aggregator             string
+	StartAggregator        bool
+}
+
+// spec returns the schedule of the flags, given the names of the flags that are set on the command line and the time
+// the deadline is counted from, or an error for a flag value that can't be parsed. The schedule starts from the
+// defaults of cronSchedule, it is validated by startWorkflow.
+func (f scheduleFlags) spec(set map[string]bool, now time.Time) (ScheduleSpec, error) {
+	spec := cronSchedule
+	var err error
+	if f.IntervalSeconds > 0 {
+		spec.ScheduleInterval = time.Second * time.Duration(f.IntervalSeconds)
+	}
+	spec.AlignToInterval = f.AlignToInterval
+	spec.Jitter = time.Second * time.Duration(f.JitterSeconds)
+	spec.TimeOfDay = f.TimeOfDay
+	spec.Timezone = f.Timezone
+	if spec.Exclusions, err = parseExclusions(f.ExcludedWeekdays, f.ExcludedDates); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if spec.Jobs, err = parseJobs(f.Jobs); err != nil {
+		return ScheduleSpec{}, err
+	}
+	spec.JobActivityName = f.JobActivity
+	if spec.JobInput, err = readInput(f.JobInput); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if spec.JobInputs, err = parseJobInputs(f.JobInputs); err != nil {
+		return ScheduleSpec{}, err
+	}
+	spec.ActivityTaskList = f.ActivityTaskList
+	spec.TaskList = f.JobTaskList
+	if f.ScheduleConfig != "" {
+		// the workers read the config, the path must not depend on the directory of the starter.
+		path, err := filepath.Abs(f.ScheduleConfig)
+		if err != nil {
+			return ScheduleSpec{}, err
+		}
+		spec.ConfigSource = &ConfigSourceSpec{Name: path, RefreshEvery: f.ConfigRefresh}
+	}
+	// a job activity that doesn't exist would only fail the runs with a ScheduleToStart timeout.
+	if err := spec.validateJobActivities(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if err := spec.validateJobInputs(); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if spec.OverlapPolicy, err = parseOverlapPolicy(f.OverlapPolicy); err != nil {
+		return ScheduleSpec{}, err
+	}
+	spec.Parallelism = f.Parallelism
+	spec.CancelShardsOnFailure = f.CancelShards
+	spec.RunAsChildWorkflow = f.RunAsChild
+	if f.PinToHost {
+		spec.HostAffinity = &HostAffinitySpec{}
+	}
+	if spec.FailurePolicy, err = parseFailurePolicy(f.FailurePolicy); err != nil {
+		return ScheduleSpec{}, err
+	}
+	spec.MaxConsecutiveFailures = f.MaxFailures
+	if f.DeadLetter != "" {
+		// the workers append to the file, the path must not depend on the directory of the starter.
+		path, err := filepath.Abs(f.DeadLetter)
+		if err != nil {
+			return ScheduleSpec{}, err
+		}
+		spec.DeadLetter = &DeadLetterSpec{Path: path}
+	}
+	spec.BackoffCoefficient = f.BackoffCoefficient
+	spec.MaxBackoff = time.Second * time.Duration(f.MaxBackoffSeconds)
+	spec.DailyBudget = time.Second * time.Duration(f.DailyBudgetSeconds)
+	if spec.CatchUpPolicy, err = parseCatchUpPolicy(f.CatchUpPolicy); err != nil {
+		return ScheduleSpec{}, err
+	}
+	if spec.CorrelationIDs, err = parseCorrelationIDScheme(f.CorrelationIDs); err != nil {
+		return ScheduleSpec{}, err
+	}
+	spec.MaxHistoryEvents = f.MaxHistoryEvents
+	spec.Timeouts = Timeouts{
+		ScheduleToStart: time.Second * time.Duration(f.ScheduleToStartSeconds),
+		StartToClose:    time.Second * time.Duration(f.StartToCloseSeconds),
+		Heartbeat:       time.Second * time.Duration(f.HeartbeatSeconds),
+		Workflow:        time.Second * time.Duration(f.WorkflowTimeoutSeconds),
+		Decision:        time.Second * time.Duration(f.DecisionTimeoutSeconds),
+	}
+	if f.RetryAttempts > 0 {
+		spec.RetryPolicy = &RetryPolicy{
+			InitialInterval:          time.Second * time.Duration(f.RetrySeconds),
+			MaximumAttempts:          f.RetryAttempts,
+			NonRetriableErrorReasons: []string{errReasonInvalidShard, errReasonInvalidInput},
+		}
+	}
+	spec.InitialDelay = time.Second * time.Duration(f.InitialDelaySeconds)
+	if f.StartAt != "" {
+		if spec.StartAt, err = time.Parse(time.RFC3339, f.StartAt); err != nil {
+			return ScheduleSpec{}, fmt.Errorf("-startAt %q is not a time in RFC3339: %v", f.StartAt, err)
+		}
+	}
+	if f.DurationSeconds > 0 {
+		spec.NotAfter = now.Add(time.Second * time.Duration(f.DurationSeconds))
+	}
+	spec.JobCount = f.JobCount
+	if set["inputs"] && !set["c"] {
+		// the schedule runs every input of the list.
+		spec.JobCount = 0
+	}
+	spec.Name = f.Name
+	spec.AggregatorWorkflowID = f.Aggregator
+	spec.StartAggregator = f.StartAggregator
+	spec.TraceID = f.TraceID
+	if f.Lock != "" {
+		spec.Lock = &LockSpec{Name: f.Lock, Permits: int(f.LockPermits),
+			LeaseTimeout: time.Second * time.Duration(f.LeaseTimeoutSeconds)}
+	}
+	return spec, nil
 }
 
 func main() {
-	var mode string
-	var intervalInSeconds, jobCount uint
-	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
-	flag.UintVar(&intervalInSeconds, "i", 5, "Schedule interval in seconds.")
-	flag.UintVar(&jobCount, "c", 3, "Job count to schedule")
+	// the verbs have their own flags, e.g. cron signal --workflow-id X --name pause.
+	if len(os.Args) > 1 && cliVerbs[os.Args[1]] {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+	var mode, workflowID, reason, description, configFile, prometheusAddress, pollTaskList string
+	var metricsInSeconds, drainTimeoutInSeconds, timeoutInSeconds uint
+	var keepJobCount, overrideBudget, replace, upsert, wait, interceptors bool
+	var schedule scheduleFlags
+	var shadow shadowFlags
+	flag.StringVar(&configFile, "config", "", "Path of the config file, default is $CADENCE_SAMPLES_CONFIG or config/development.yaml.")
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker, workflowWorker, activityWorker, trigger, pause, resume, update, triggerNow, drain, skip, cancel, list, recentRuns, report or shadow.")
+	flag.UintVar(&schedule.IntervalSeconds, "i", 5, "Schedule interval in seconds.")
+	flag.BoolVar(&schedule.AlignToInterval, "align", false, "Run on the multiples of the interval, e.g. every full hour, instead of an interval after the last wait started.")
+	flag.UintVar(&schedule.JitterSeconds, "j", 0, "Max random delay in seconds added to every scheduled run.")
+	flag.StringVar(&schedule.TimeOfDay, "t", "", "Time of day in HH:MM to run the job every day at, instead of every interval.")
+	flag.StringVar(&schedule.Timezone, "tz", "", "Timezone of the time of day, e.g. America/New_York. Default is UTC.")
+	flag.StringVar(&schedule.ExcludedWeekdays, "xw", "", "Comma separated weekdays to not run the job on, e.g. Saturday,Sunday.")
+	flag.StringVar(&schedule.ExcludedDates, "xd", "", "Comma separated dates in YYYY-MM-DD to not run the job on.")
+	flag.UintVar(&schedule.InitialDelaySeconds, "initialDelay", 0, "Seconds after the start the first run of a new schedule is due, instead of an interval.")
+	flag.StringVar(&schedule.StartAt, "startAt", "", "Time in RFC3339 the first run of a new schedule is due, e.g. 2020-03-01T09:00:00Z, instead of an interval after the start.")
+	flag.UintVar(&schedule.DurationSeconds, "d", 0, "Seconds from now after which no more jobs are run. Default is no deadline.")
+	flag.StringVar(&schedule.JobActivity, "activity", "", "Job activity of a new schedule, one of "+
+		strings.Join(knownJobActivities(), ", ")+". Default is "+defaultJobActivity+".")
+	flag.StringVar(&schedule.JobInput, "input", "", "JSON input of the job activity, or the path of a file with it.")
+	flag.StringVar(&schedule.JobInputs, "inputs", "", "JSON array of the inputs of the runs of a new schedule, or the path of a file with it. Every run takes the next input, the schedule completes once all are taken, -c limits the runs further.")
+	flag.StringVar(&schedule.ScheduleConfig, "scheduleConfig", "", "Path of a JSON config the workers read the schedule of a new schedule from, it overrides -i, -j, -p, -activity and -input once read.")
+	flag.UintVar(&schedule.ConfigRefresh, "configRefresh", 0, "Read the -scheduleConfig again after every this many runs, 0 reads it once per run of the workflow.")
+	flag.StringVar(&schedule.ActivityTaskList, "activityTaskList", "", "Task list the activities of a new schedule are scheduled on, and the activity workers poll. Default is the task list of the workflow.")
+	flag.StringVar(&schedule.JobTaskList, "jobTaskList", "", "Task list the job activities of a new schedule are scheduled on, e.g. the one of the workers of an environment. Default is the -activityTaskList.")
+	flag.StringVar(&pollTaskList, "pollTaskLists", "", "Comma separated task lists of job activities the activity workers poll besides their own, one worker each, e.g. staging,prod.")
+	flag.StringVar(&schedule.Jobs, "jobs", "", "Comma separated name=interval jobs to run in one workflow, e.g. reports=1m,cleanup=3m, instead of a single job. An interval followed by @taskList schedules the job on that task list, e.g. reports=1m@staging.")
+	flag.StringVar(&schedule.OverlapPolicy, "overlap", "Skip", "What to do when a run is due while the previous one is still executing: Skip, BufferOne or AllowAll.")
+	flag.UintVar(&schedule.Parallelism, "p", 1, "Number of shards every run is split into, each processed by its own activity.")
+	flag.BoolVar(&schedule.CancelShards, "cancelShards", false, "Cancel the other shards of a run as soon as one of them fails.")
+	flag.BoolVar(&schedule.RunAsChild, "child", false, "Execute every run as a child workflow with its own history.")
+	flag.BoolVar(&schedule.PinToHost, "pinToHost", false, "Execute the shards of every run on the worker host that picked up the run, another host is picked if it is unavailable.")
+	flag.StringVar(&schedule.Lock, "lock", "", "Name of a lock shared with other schedules, a run holds it while its job executes.")
+	flag.UintVar(&schedule.LockPermits, "permits", 1, "Number of runs of all schedules that can hold the lock at the same time.")
+	flag.UintVar(&schedule.LeaseTimeoutSeconds, "leaseTimeout", 600, "Seconds after which the lock is taken from a run that didn't release it.")
+	flag.UintVar(&schedule.RetryAttempts, "retries", 3, "Maximum attempts of every shard of a run, 0 disables retries.")
+	flag.UintVar(&schedule.RetrySeconds, "retryInterval", 1, "Backoff in seconds before the first retry, doubled by every retry.")
+	flag.StringVar(&schedule.FailurePolicy, "onFailure", "Abort", "What to do when a run failed: Abort or Continue.")
+	flag.StringVar(&schedule.DeadLetter, "deadLetter", "", "Path of a JSON lines file the workers append the runs that failed with -onFailure Continue to, after their retries.")
+	flag.UintVar(&schedule.MaxFailures, "maxFailures", 0, "Abort after more consecutive failed runs with -onFailure Continue, 0 means no limit.")
+	flag.Float64Var(&schedule.BackoffCoefficient, "backoff", 0, "Multiply the interval before the next run by this after every consecutive failed run with -onFailure Continue, e.g. 2. 0 disables the backoff.")
+	flag.UintVar(&schedule.MaxBackoffSeconds, "maxBackoff", 0, "Seconds the interval backs off to at most with -backoff.")
+	flag.UintVar(&schedule.DailyBudgetSeconds, "dailyBudget", 0, "Seconds the runs of a new schedule may execute per day in the -tz, the runs due once they are used up are skipped until the next day. 0 means no budget.")
+	flag.StringVar(&schedule.CatchUpPolicy, "catchUp", "Skip", "What to do with the runs missed while no worker was running: Skip or Backfill.")
+	flag.StringVar(&schedule.CorrelationIDs, "correlationIDs", "Random", "How the correlation IDs the runs pass to their jobs are generated: Random, a UUID recorded in the history, or Deterministic, derived from the workflow ID and the number of the run.")
+	flag.UintVar(&schedule.ScheduleToStartSeconds, "scheduleToStart", 0, "Seconds an activity may wait for a worker, 0 means the default.")
+	flag.UintVar(&schedule.StartToCloseSeconds, "startToClose", 0, "Seconds an activity attempt may take, 0 means the default.")
+	flag.UintVar(&schedule.HeartbeatSeconds, "heartbeat", 0, "Heartbeat timeout of the activities in seconds, 0 means the default.")
+	flag.UintVar(&schedule.WorkflowTimeoutSeconds, "workflowTimeout", 0, "Seconds a run of the workflow may take until it continues as new, 0 means the default.")
+	flag.UintVar(&schedule.DecisionTimeoutSeconds, "decisionTimeout", 0, "Decision task timeout in seconds, 0 means the default.")
+	flag.UintVar(&schedule.MaxHistoryEvents, "maxHistory", 0, "Continue as new before the history exceeds this many events, 0 means after every 10 runs.")
+	flag.UintVar(&metricsInSeconds, "metrics", 0, "Log the metrics of the worker every this many seconds, 0 discards them.")
+	flag.UintVar(&drainTimeoutInSeconds, "drainTimeout", 30, "Seconds the worker waits for its running activities on SIGINT or SIGTERM before cancelling them.")
+	flag.BoolVar(&interceptors, "interceptors", true, "Log the start and the end of every workflow and activity of the worker, count them and return the panics of the activities as errors.")
+	flag.StringVar(&prometheusAddress, "prometheus", "", "Serve the metrics of the worker to prometheus on this address at /metrics, e.g. :9090.")
+	flag.UintVar(&schedule.JobCount, "c", 3, "Job count to schedule")
+	flag.StringVar(&schedule.Name, "name", "", "Name of a new schedule, its workflow ID is cron_<name> and it can't be started again while running.")
+	flag.StringVar(&schedule.TraceID, "traceID", "", "Trace ID of a new schedule that its logs carry, e.g. the request ID of the caller. A new ID by default.")
+	flag.BoolVar(&replace, "replace", false, "Terminate the running workflow of the named schedule and start a new one.")
+	flag.BoolVar(&upsert, "upsert", false, "Signal the new interval and job count to the running workflow of the named schedule, or start it if it is not running.")
+	flag.BoolVar(&wait, "wait", false, "Wait until the new schedule closes and print the summary of its runs.")
+	flag.StringVar(&runnerOutput, "output", outputText, "Output of the modes: text or json, json prints the workflow and run IDs of the trigger mode and the error of every mode as one JSON object.")
+	flag.UintVar(&timeoutInSeconds, "timeout", uint(defaultCommandTimeout.Seconds()), "Seconds the trigger mode waits for the frontend to start the workflow, 0 waits for the retries of the client.")
+	flag.StringVar(&schedule.Aggregator, "aggregator", "", "Workflow ID of the aggregator the runs of a new schedule forward their outcome to, the report mode prints its tallies.")
+	flag.BoolVar(&schedule.StartAggregator, "startAggregator", false, "Start the -aggregator if it is not running when a run forwards its outcome, instead of dropping the outcome.")
+	flag.StringVar(&description, "describe", "", "Comma separated key=value fields describing a new schedule, e.g. owner=payments,purpose=reconciliation.")
+	flag.StringVar(&workflowID, "w", "", "WorkflowID of the cron workflow to signal.")
+	flag.StringVar(&reason, "reason", "", "Reason for pausing, resuming, draining or skipping a run, logged by the workflow.")
+	flag.BoolVar(&keepJobCount, "keepJobCount", false, "Manual run triggered by triggerNow does not count against the job count.")
+	flag.BoolVar(&overrideBudget, "overrideBudget", false, "Manual run triggered by triggerNow starts even if the daily budget is used up.")
+	flag.UintVar(&shadow.Days, "shadowDays", defaultShadowDays, "The shadow mode replays the runs of the cron workflow that closed within this many days.")
+	flag.Float64Var(&shadow.SamplingRate, "shadowSampling", 1, "Share of the closed runs the shadow mode replays, in (0, 1].")
+	flag.UintVar(&shadow.Concurrency, "shadowConcurrency", defaultShadowConcurrency, "Number of histories the shadow mode replays at the same time.")
+	flag.UintVar(&shadow.Count, "shadowCount", 0, "The shadow mode stops after replaying this many runs, 0 means no limit.")
+	flag.UintVar(&shadow.DurationSeconds, "shadowDuration", 0, "Seconds after which the shadow mode stops, 0 means no limit.")
+	flag.BoolVar(&shadow.Continuous, "shadowContinuous", false, "The shadow mode scans for new closed runs until -shadowCount or -shadowDuration is reached, instead of scanning once.")
+	flag.UintVar(&shadow.IntervalSeconds, "shadowInterval", 0, "Seconds between the scans of -shadowContinuous, 0 means every 5 minutes.")
 	flag.Parse()
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if runnerOutput != outputText && runnerOutput != outputJSON {
+		output := runnerOutput
+		runnerOutput = outputText
+		usageError(fmt.Errorf("unknown output %q, the outputs are text and json", output))
+	}
+	if err := checkFlags(set, schedule.JobCount); err != nil {
+		usageError(err)
+	}
+	if err := checkWorkerMode(mode, set, schedule.ActivityTaskList); err != nil {
+		usageError(err)
+	}
 
-	if intervalInSeconds > 0 {
-		cronSchedule.ScheduleInterval = time.Second * time.Duration(intervalInSeconds)
+	var err error
+	if cronSchedule, err = schedule.spec(set, time.Now()); err != nil {
+		usageError(err)
 	}
-	if jobCount > 0 {
-		cronSchedule.JobCount = jobCount
+	pollTaskLists, err := parsePollTaskLists(pollTaskList)
+	if err != nil {
+		usageError(err)
 	}
 
-	var h common.SampleHelper
+	h := common.SampleHelper{ConfigFile: configFile}
 	h.SetupServiceConfig()
 
+	// the errors of every mode are printed like the ones of the verbs, with the exit code of the error.
+	command := cliCommand{Verb: mode, WorkflowID: workflowID, JSON: runnerOutput == outputJSON,
+		Timeout: time.Second * time.Duration(timeoutInSeconds)}
+	exitOnError := func(err error) {
+		if err != nil {
+			os.Exit(reportError(os.Stderr, command, err))
+		}
+	}
 	switch mode {
-	case "worker":
-		startWorkers(&h)
+	case modeWorker, modeWorkflowWorker, modeActivityWorker:
+		command.WorkflowID = ""
+		if metricsInSeconds > 0 {
+			h.EnableMetrics(time.Second * time.Duration(metricsInSeconds))
+		}
+		if prometheusAddress != "" {
+			exitOnError(h.EnablePrometheus(prometheusAddress))
+		}
+		if interceptors {
+			installInterceptors()
+		}
+		role := workerRoles[mode]
+		role.register()
+		startWorkers(&h, role, schedule.ActivityTaskList, pollTaskLists)
 
 		// The workers are supposed to be long running process that should not exit.
-		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
-		select {}
+		// On CMD+C or SIGTERM the worker stops polling and waits for its running activities, a second CMD+C exits
+		// right away.
+		if !h.WaitForShutdown(time.Second * time.Duration(drainTimeoutInSeconds)) {
+			os.Exit(1)
+		}
 	case "trigger":
-		startWorkflow(&h)
+		fields, err := parseFields(description)
+		if err != nil {
+			usageError(err)
+		}
+		fields, err = sealSchedule(h.Keyring, &cronSchedule, fields)
+		exitOnError(err)
+		cronSchedule.Description = cronSchedule.describe(fields)
+		os.Exit(startWorkflow(&h, replace, upsert, wait, command))
+	case "pause":
+		exitOnError(h.SignalWorkflow(workflowID, pauseSignalName, reason))
+	case "resume":
+		exitOnError(h.SignalWorkflow(workflowID, resumeSignalName, reason))
+	case "update":
+		update := ScheduleUpdate{ScheduleInterval: cronSchedule.ScheduleInterval}
+		if err := update.validate(); err != nil {
+			exitOnError(cliUsageError{err})
+		}
+		exitOnError(h.SignalWorkflow(workflowID, updateScheduleSignalName, update))
+	case "triggerNow":
+		exitOnError(h.SignalWorkflow(workflowID, triggerNowSignalName, TriggerNowRequest{KeepJobCount: keepJobCount,
+			OverrideBudget: overrideBudget}))
+	case "drain":
+		exitOnError(h.SignalWorkflow(workflowID, drainSignalName, reason))
+	case "skip":
+		exitOnError(h.SignalWorkflow(workflowID, skipRunSignalName, reason))
+	case "cancel":
+		exitOnError(h.CancelWorkflow(workflowID))
+	case "list":
+		command.WorkflowID = ""
+		exitOnError(h.ListOpenWorkflows(cronWorkflowType))
+	case "report":
+		client, err := h.Builder.BuildCadenceClient()
+		exitOnError(err)
+		// this version of the client has no queries, the tallies are replayed from the history of the aggregator.
+		state, err := aggregatorReport(client, workflowID)
+		exitOnError(err)
+		printAggregatorReport(os.Stdout, state)
+	case "shadow":
+		command.WorkflowID = ""
+		options, err := shadow.options()
+		if err != nil {
+			usageError(err)
+		}
+		// the histories are replayed with the workflows of this binary, the activities are not executed.
+		workerRoles[modeWorkflowWorker].register()
+		client, err := h.Builder.BuildCadenceClient()
+		exitOnError(err)
+		result, err := shadowWorkflows(client, h.Config.DomainName, options, h.Logger)
+		printShadowResult(os.Stdout, result)
+		exitOnError(err)
+		if len(result.Failures) > 0 {
+			os.Exit(exitFailed)
+		}
+	case "recentRuns":
+		// this version of the client has no queries, the records are as of the last continue-as-new.
+		var spec ScheduleSpec
+		state := &CronState{}
+		exitOnError(h.GetWorkflowInput(workflowID, &spec, state))
+		printRecentRuns(os.Stdout, state.RecentRuns)
+	default:
+		usageError(fmt.Errorf("unknown mode %s", mode))
 	}
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckFlags(t *testing.T) {
+	testCases := []struct {
+		set      map[string]bool
+		jobCount uint
+		valid    bool
+	}{
+		{map[string]bool{}, 3, true},
+		{map[string]bool{"i": true, "j": true}, 3, true},
+		{map[string]bool{"t": true, "tz": true}, 3, true},
+		{map[string]bool{"jobs": true}, 3, true},
+		{map[string]bool{"upsert": true, "name": true}, 3, true},
+		{map[string]bool{"i": true, "t": true}, 3, false},
+		{map[string]bool{"jobs": true, "i": true}, 3, false},
+		{map[string]bool{"jobs": true, "t": true}, 3, false},
+		{map[string]bool{}, 0, false},
+		{map[string]bool{"metrics": true, "prometheus": true}, 3, false},
+		{map[string]bool{"upsert": true}, 3, false},
+		{map[string]bool{"upsert": true, "name": true, "replace": true}, 3, false},
+	}
+	for _, tc := range testCases {
+		err := checkFlags(tc.set, tc.jobCount)
+		require.Equal(t, tc.valid, err == nil, "%v %d: %v", tc.set, tc.jobCount, err)
+	}
+}
+
+func Test_ParseFlags(t *testing.T) {
+	jobs, err := parseJobs("reports=1m, cleanup=3m")
+	require.NoError(t, err)
+	require.Equal(t, []JobSpec{{Name: "reports", Interval: time.Minute}, {Name: "cleanup", Interval: time.Minute * 3}},
+		jobs)
+	jobs, err = parseJobs("reports=1m@staging")
+	require.NoError(t, err)
+	require.Equal(t, []JobSpec{{Name: "reports", Interval: time.Minute, TaskList: "staging"}}, jobs)
+	jobs, err = parseJobs("")
+	require.NoError(t, err)
+	require.Nil(t, jobs)
+	_, err = parseJobs("reports")
+	require.EqualError(t, err, `job "reports" in -jobs is not name=interval`)
+	_, err = parseJobs("reports=often")
+	require.Error(t, err)
+
+	exclusions, err := parseExclusions("saturday,Sunday", "2023-12-25")
+	require.NoError(t, err)
+	require.Equal(t, Exclusions{Weekdays: []time.Weekday{time.Saturday, time.Sunday}, Dates: []string{"2023-12-25"}},
+		exclusions)
+	_, err = parseExclusions("Caturday", "")
+	require.EqualError(t, err, `unknown weekday "Caturday" in -xw`)
+
+	fields, err := parseFields("owner=payments,purpose=a=b")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"owner": "payments", "purpose": "a=b"}, fields)
+	_, err = parseFields("owner")
+	require.EqualError(t, err, `field "owner" in -describe is not key=value`)
+
+	overlap, err := parseOverlapPolicy("bufferone")
+	require.NoError(t, err)
+	require.Equal(t, OverlapBufferOne, overlap)
+	_, err = parseOverlapPolicy("Queue")
+	require.EqualError(t, err, `unknown overlap policy "Queue", the policies are Skip, BufferOne and AllowAll`)
+	failure, err := parseFailurePolicy("Continue")
+	require.NoError(t, err)
+	require.Equal(t, FailureContinue, failure)
+	_, err = parseFailurePolicy("Retry")
+	require.Error(t, err)
+	catchUp, err := parseCatchUpPolicy("Backfill")
+	require.NoError(t, err)
+	require.Equal(t, CatchUpBackfill, catchUp)
+	_, err = parseCatchUpPolicy("Replay")
+	require.Error(t, err)
+	scheme, err := parseCorrelationIDScheme("deterministic")
+	require.NoError(t, err)
+	require.Equal(t, CorrelationDeterministic, scheme)
+	_, err = parseCorrelationIDScheme("Sequential")
+	require.Error(t, err)
+}
+
+func Test_ParseJobInputs(t *testing.T) {
+	inputs, err := parseJobInputs(`[{"item":"i0"}, 7]`)
+	require.NoError(t, err)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"item":"i0"}`), json.RawMessage(`7`)}, inputs)
+	inputs, err = parseJobInputs("")
+	require.NoError(t, err)
+	require.Nil(t, inputs)
+	_, err = parseJobInputs(`{"item":"i0"}`)
+	require.Error(t, err)
+	_, err = parseJobInputs(`[]`)
+	require.Error(t, err)
+}
+
+// testScheduleFlags are the defaults of the flags of a new schedule.
+var testScheduleFlags = scheduleFlags{IntervalSeconds: 5, OverlapPolicy: "Skip", LockPermits: 1,
+	LeaseTimeoutSeconds: 600, RetryAttempts: 3, RetrySeconds: 1, FailurePolicy: "Abort", CatchUpPolicy: "Skip",
+	CorrelationIDs: "Random", JobCount: 3}
+
+func Test_ScheduleFlags_Spec(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	spec, err := testScheduleFlags.spec(map[string]bool{}, now)
+	require.NoError(t, err)
+	require.Equal(t, ScheduleSpec{JobCount: 3, ScheduleInterval: time.Second * 5, JobInput: json.RawMessage{},
+		RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3,
+			NonRetriableErrorReasons: []string{errReasonInvalidShard, errReasonInvalidInput}}}, spec)
+
+	flags := testScheduleFlags
+	flags.IntervalSeconds = 0
+	flags.JitterSeconds = 30
+	flags.ExcludedWeekdays = "Sunday"
+	flags.OverlapPolicy = "AllowAll"
+	flags.FailurePolicy = "Continue"
+	flags.CatchUpPolicy = "Backfill"
+	flags.CorrelationIDs = "Deterministic"
+	flags.RetryAttempts = 0
+	flags.DurationSeconds = 3600
+	flags.StartAt = "2023-06-02T09:00:00Z"
+	flags.Lock = "backend"
+	flags.DeadLetter = "dead.jsonl"
+	flags.JobInputs = `[1, 2]`
+	flags.DailyBudgetSeconds = 60
+	spec, err = flags.spec(map[string]bool{"inputs": true}, now)
+	require.NoError(t, err)
+	// the interval of the defaults, and every input of the list since -c is not set.
+	require.Equal(t, cronSchedule.ScheduleInterval, spec.ScheduleInterval)
+	require.Equal(t, uint(0), spec.JobCount)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`2`)}, spec.JobInputs)
+	require.Equal(t, time.Second*30, spec.Jitter)
+	require.Equal(t, []time.Weekday{time.Sunday}, spec.Exclusions.Weekdays)
+	require.Equal(t, OverlapAllowAll, spec.OverlapPolicy)
+	require.Equal(t, FailureContinue, spec.FailurePolicy)
+	require.Equal(t, CatchUpBackfill, spec.CatchUpPolicy)
+	require.Equal(t, CorrelationDeterministic, spec.CorrelationIDs)
+	require.Nil(t, spec.RetryPolicy)
+	require.Equal(t, now.Add(time.Hour), spec.NotAfter)
+	require.Equal(t, time.Date(2023, 6, 2, 9, 0, 0, 0, time.UTC), spec.StartAt.UTC())
+	require.Equal(t, &LockSpec{Name: "backend", Permits: 1, LeaseTimeout: time.Minute * 10}, spec.Lock)
+	require.True(t, filepath.IsAbs(spec.DeadLetter.Path))
+	require.Equal(t, time.Minute, spec.DailyBudget)
+
+	for _, update := range []func(f *scheduleFlags){
+		func(f *scheduleFlags) { f.ExcludedWeekdays = "Caturday" },
+		func(f *scheduleFlags) { f.Jobs = "reports" },
+		func(f *scheduleFlags) { f.JobInput = "no-such-file.json" },
+		func(f *scheduleFlags) { f.JobInputs = `[]` },
+		func(f *scheduleFlags) { f.JobActivity = "unknown" },
+		func(f *scheduleFlags) { f.OverlapPolicy = "Queue" },
+		func(f *scheduleFlags) { f.FailurePolicy = "Retry" },
+		func(f *scheduleFlags) { f.CatchUpPolicy = "Replay" },
+		func(f *scheduleFlags) { f.CorrelationIDs = "Sequential" },
+		func(f *scheduleFlags) { f.StartAt = "tomorrow" },
+	} {
+		flags := testScheduleFlags
+		update(&flags)
+		_, err := flags.spec(map[string]bool{}, now)
+		require.Error(t, err, "%+v", flags)
+	}
+}
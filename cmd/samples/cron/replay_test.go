@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+// historySimulator plays the part of the Cadence server for a single workflow run. It feeds decision tasks to the
+// workflow task handler used by the workers, turns the returned decisions into history events, completes activities
+// and fires timers in time order. The handler doesn't cache workflow state, so every decision task replays the history
+// recorded so far, and a workflow that doesn't replay deterministically fails the simulation.
+type historySimulator struct {
+	t            *testing.T
+	handler      cadence.WorkflowTaskHandler
+	workflowType string
+	// workflowID and runID identify the simulated run.
+	workflowID             string
+	runID                  string
+	events                 []*s.HistoryEvent
+	previousStartedEventID int64
+	start                  time.Time
+	now                    time.Time
+	activityDuration       time.Duration
+	timers                 map[string]time.Time
+	activities             map[int64]time.Time
+	children               map[int64]simulatedChild
+	signals                []simulatedSignal
+	// onActivityCompleted is called with the scheduled event of every activity when it completes.
+	onActivityCompleted func(scheduled *s.HistoryEvent)
+	// runningWorkflowIDs are the IDs of workflows that run outside of the simulation, a child workflow with one of
+	// them fails to start.
+	runningWorkflowIDs map[string]bool
+	// no decision task starts while the workers are down, they start when the workers are back instead.
+	workersDown, workersBack time.Time
+}
+
+// simulatedChild is a child workflow that closes at the given time, child workflows take as long as activities.
+type simulatedChild struct {
+	workflowID string
+	at         time.Time
+}
+
+type simulatedSignal struct {
+	at    time.Time
+	name  string
+	input []byte
+}
+
+func newHistorySimulator(t *testing.T, start time.Time, workflowFn interface{}, args ...interface{}) *historySimulator {
+	h := &historySimulator{
+		t:            t,
+		handler:      cadence.NewWorkflowTaskHandler("replay-domain", "replay-identity", zap.NewNop()),
+		workflowType: getFunctionName(workflowFn),
+		workflowID:   "replay-workflow",
+		runID:        "replay-run",
+		start:        start,
+		now:          start,
+		timers:       make(map[string]time.Time),
+		activities:   make(map[int64]time.Time),
+		children:     make(map[int64]simulatedChild),
+	}
+	h.addEvent(s.EventType_WorkflowExecutionStarted, func(e *s.HistoryEvent) {
+		e.WorkflowExecutionStartedEventAttributes = &s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType:                        &s.WorkflowType{Name: &h.workflowType},
+			TaskList:                            &s.TaskList{Name: stringPtr(ApplicationName)},
+			Input:                               encodeValues(t, args...),
+			ExecutionStartToCloseTimeoutSeconds: int32Ptr(int32(workflowTimeout.Seconds())),
+			TaskStartToCloseTimeoutSeconds:      int32Ptr(int32(decisionTimeout.Seconds())),
+		}
+	})
+	return h
+}
+
+// loadHistory returns a simulator with the complete history of a closed run read from a JSON file, to replay it with
+// the current code of the workflow.
+func loadHistory(t *testing.T, path string, workflowFn interface{}) *historySimulator {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var history s.History
+	require.NoError(t, json.Unmarshal(data, &history))
+	h := &historySimulator{
+		t:            t,
+		handler:      cadence.NewWorkflowTaskHandler("replay-domain", "replay-identity", zap.NewNop()),
+		workflowType: getFunctionName(workflowFn),
+		workflowID:   "replay-workflow",
+		runID:        "replay-run",
+		events:       history.Events,
+	}
+	// the name of the workflow type depends on the import path the history was recorded with.
+	h.events[0].WorkflowExecutionStartedEventAttributes.WorkflowType = &s.WorkflowType{Name: &h.workflowType}
+	// the cron binary registers the activities as main.<function>, the test binary with the import path.
+	packagePath := h.workflowType[:strings.LastIndex(h.workflowType, ".")]
+	for _, e := range h.events {
+		if attributes := e.ActivityTaskScheduledEventAttributes; attributes != nil {
+			if name := attributes.GetActivityType().GetName(); strings.HasPrefix(name, "main.") {
+				attributes.ActivityType = &s.ActivityType{Name: stringPtr(packagePath + strings.TrimPrefix(name, "main"))}
+			}
+		}
+	}
+	return h
+}
+
+// signalAfter delivers a signal the given time after the run started.
+func (h *historySimulator) signalAfter(d time.Duration, name string, arg interface{}) {
+	h.signalAt(h.start.Add(d), name, arg)
+}
+
+// signalAt delivers a signal at the given time.
+func (h *historySimulator) signalAt(at time.Time, name string, arg interface{}) {
+	h.signals = append(h.signals, simulatedSignal{at: at, name: name, input: encodeValues(h.t, arg)})
+}
+
+// workersDownBetween makes the workers unavailable from the first to the second time after the run started.
+func (h *historySimulator) workersDownBetween(from, until time.Duration) {
+	h.workersDown, h.workersBack = h.start.Add(from), h.start.Add(until)
+}
+
+// run drives the workflow until it closes and returns the decision that closed it.
+func (h *historySimulator) run() *s.Decision {
+	for {
+		if d := h.decide(); d != nil {
+			return d
+		}
+		require.True(h.t, h.advance(), "workflow is stuck, nothing left to wake it up")
+	}
+}
+
+// decide runs a decision task and records its decisions, it returns the decision that closed the run if any.
+func (h *historySimulator) decide() *s.Decision {
+	startedEventID := h.startDecisionTask()
+	response, _, err := h.handler.ProcessWorkflowTask(h.newTask(h.previousStartedEventID), false)
+	require.NoError(h.t, err)
+	h.previousStartedEventID = startedEventID
+
+	h.addEvent(s.EventType_DecisionTaskCompleted, func(e *s.HistoryEvent) {
+		e.DecisionTaskCompletedEventAttributes = &s.DecisionTaskCompletedEventAttributes{
+			ScheduledEventId: int64Ptr(startedEventID - 1),
+			StartedEventId:   int64Ptr(startedEventID),
+		}
+	})
+	for _, d := range response.Decisions {
+		if h.applyDecision(d) {
+			return d
+		}
+	}
+	return nil
+}
+
+// runTogether drives the workflows of the simulators together in time order, so that the activities of one can signal
+// the others, until the workflows of the given number of simulators closed. The others may run forever.
+func runTogether(t *testing.T, closing int, simulators ...*historySimulator) {
+	closed := make(map[*historySimulator]bool)
+	for _, h := range simulators {
+		if h.decide() != nil {
+			closed[h] = true
+		}
+	}
+	for len(closed) < closing {
+		var next *historySimulator
+		for _, h := range simulators {
+			if at := h.nextTime(); !closed[h] && !at.IsZero() && (next == nil || at.Before(next.nextTime())) {
+				next = h
+			}
+		}
+		require.NotNil(t, next, "workflows are stuck, nothing left to wake them up")
+		next.advance()
+		if next.decide() != nil {
+			closed[next] = true
+		}
+	}
+}
+
+// replay runs the complete history through the handler again, all events are treated as replayed.
+func (h *historySimulator) replay() error {
+	_, _, err := h.handler.ProcessWorkflowTask(h.newTask(int64(len(h.events))), false)
+	return err
+}
+
+// timerDurations returns the durations of all timers the workflow started, in the order they were started.
+func (h *historySimulator) timerDurations() []time.Duration {
+	var result []time.Duration
+	for _, e := range h.events {
+		if e.GetEventType() == s.EventType_TimerStarted {
+			seconds := e.GetTimerStartedEventAttributes().GetStartToFireTimeoutSeconds()
+			result = append(result, time.Duration(seconds)*time.Second)
+		}
+	}
+	return result
+}
+
+// activityInputs returns the inputs of the sampleCronActivity executions, in the order they were scheduled.
+func (h *historySimulator) activityInputs() []CronJobInput {
+	var result []CronJobInput
+	for _, e := range h.events {
+		attributes := e.GetActivityTaskScheduledEventAttributes()
+		if e.GetEventType() == s.EventType_ActivityTaskScheduled &&
+			attributes.GetActivityType().GetName() == getFunctionName(sampleCronActivity) {
+			var input CronJobInput
+			require.NoError(h.t, gob.NewDecoder(bytes.NewReader(attributes.Input)).Decode(&input))
+			result = append(result, input)
+		}
+	}
+	return result
+}
+
+// activityTypes returns the names of the activities scheduled, in the order they were scheduled.
+func (h *historySimulator) activityTypes() []string {
+	var result []string
+	for _, e := range h.events {
+		if e.GetEventType() == s.EventType_ActivityTaskScheduled {
+			result = append(result, e.GetActivityTaskScheduledEventAttributes().GetActivityType().GetName())
+		}
+	}
+	return result
+}
+
+// childWorkflowIDs returns the IDs of the child workflows the workflow started, in the order they were initiated.
+func (h *historySimulator) childWorkflowIDs() []string {
+	var result []string
+	for _, e := range h.events {
+		if e.GetEventType() == s.EventType_StartChildWorkflowExecutionInitiated {
+			result = append(result, e.GetStartChildWorkflowExecutionInitiatedEventAttributes().GetWorkflowId())
+		}
+	}
+	return result
+}
+
+// activityScheduleTimes returns the times at which activities were scheduled.
+func (h *historySimulator) activityScheduleTimes() []time.Time {
+	var result []time.Time
+	for _, e := range h.events {
+		if e.GetEventType() == s.EventType_ActivityTaskScheduled {
+			result = append(result, time.Unix(0, e.GetTimestamp()))
+		}
+	}
+	return result
+}
+
+func (h *historySimulator) newTask(previousStartedEventID int64) *s.PollForDecisionTaskResponse {
+	events := make([]*s.HistoryEvent, len(h.events))
+	copy(events, h.events)
+	return &s.PollForDecisionTaskResponse{
+		TaskToken:              []byte("replay-task"),
+		WorkflowExecution:      &s.WorkflowExecution{WorkflowId: &h.workflowID, RunId: &h.runID},
+		WorkflowType:           &s.WorkflowType{Name: &h.workflowType},
+		PreviousStartedEventId: int64Ptr(previousStartedEventID),
+		History:                &s.History{Events: events},
+	}
+}
+
+func (h *historySimulator) startDecisionTask() int64 {
+	scheduled := h.addEvent(s.EventType_DecisionTaskScheduled, func(e *s.HistoryEvent) {
+		e.DecisionTaskScheduledEventAttributes = &s.DecisionTaskScheduledEventAttributes{
+			TaskList:                   &s.TaskList{Name: stringPtr(ApplicationName)},
+			StartToCloseTimeoutSeconds: int32Ptr(int32(decisionTimeout.Seconds())),
+		}
+	})
+	if !h.now.Before(h.workersDown) && h.now.Before(h.workersBack) {
+		h.now = h.workersBack
+	}
+	return h.addEvent(s.EventType_DecisionTaskStarted, func(e *s.HistoryEvent) {
+		e.DecisionTaskStartedEventAttributes = &s.DecisionTaskStartedEventAttributes{ScheduledEventId: &scheduled}
+	})
+}
+
+// applyDecision records the event of a decision, it returns true when the decision closes the run.
+func (h *historySimulator) applyDecision(d *s.Decision) bool {
+	switch d.GetDecisionType() {
+	case s.DecisionType_ScheduleActivityTask:
+		attributes := d.ScheduleActivityTaskDecisionAttributes
+		id := h.addEvent(s.EventType_ActivityTaskScheduled, func(e *s.HistoryEvent) {
+			e.ActivityTaskScheduledEventAttributes = &s.ActivityTaskScheduledEventAttributes{
+				ActivityId:                    attributes.ActivityId,
+				ActivityType:                  attributes.ActivityType,
+				TaskList:                      attributes.TaskList,
+				Input:                         attributes.Input,
+				ScheduleToStartTimeoutSeconds: attributes.ScheduleToStartTimeoutSeconds,
+				StartToCloseTimeoutSeconds:    attributes.StartToCloseTimeoutSeconds,
+				ScheduleToCloseTimeoutSeconds: attributes.ScheduleToCloseTimeoutSeconds,
+				HeartbeatTimeoutSeconds:       attributes.HeartbeatTimeoutSeconds,
+			}
+		})
+		h.activities[id] = h.now.Add(h.activityDuration)
+	case s.DecisionType_StartChildWorkflowExecution:
+		attributes := d.StartChildWorkflowExecutionDecisionAttributes
+		id := h.addEvent(s.EventType_StartChildWorkflowExecutionInitiated, func(e *s.HistoryEvent) {
+			e.StartChildWorkflowExecutionInitiatedEventAttributes = &s.StartChildWorkflowExecutionInitiatedEventAttributes{
+				Domain:                              attributes.Domain,
+				WorkflowId:                          attributes.WorkflowId,
+				WorkflowType:                        attributes.WorkflowType,
+				TaskList:                            attributes.TaskList,
+				Input:                               attributes.Input,
+				ExecutionStartToCloseTimeoutSeconds: attributes.ExecutionStartToCloseTimeoutSeconds,
+				TaskStartToCloseTimeoutSeconds:      attributes.TaskStartToCloseTimeoutSeconds,
+				ChildPolicy:                         attributes.ChildPolicy,
+			}
+		})
+		at := h.now.Add(h.activityDuration)
+		if h.runningWorkflowIDs[attributes.GetWorkflowId()] {
+			at = h.now
+		}
+		h.children[id] = simulatedChild{workflowID: attributes.GetWorkflowId(), at: at}
+	case s.DecisionType_StartTimer:
+		attributes := d.StartTimerDecisionAttributes
+		h.addEvent(s.EventType_TimerStarted, func(e *s.HistoryEvent) {
+			e.TimerStartedEventAttributes = &s.TimerStartedEventAttributes{
+				TimerId:                   attributes.TimerId,
+				StartToFireTimeoutSeconds: attributes.StartToFireTimeoutSeconds,
+			}
+		})
+		h.timers[attributes.GetTimerId()] = h.now.Add(time.Duration(attributes.GetStartToFireTimeoutSeconds()) * time.Second)
+	case s.DecisionType_CancelTimer:
+		attributes := d.CancelTimerDecisionAttributes
+		delete(h.timers, attributes.GetTimerId())
+		h.addEvent(s.EventType_TimerCanceled, func(e *s.HistoryEvent) {
+			e.TimerCanceledEventAttributes = &s.TimerCanceledEventAttributes{TimerId: attributes.TimerId}
+		})
+	case s.DecisionType_RecordMarker:
+		attributes := d.RecordMarkerDecisionAttributes
+		h.addEvent(s.EventType_MarkerRecorded, func(e *s.HistoryEvent) {
+			e.MarkerRecordedEventAttributes = &s.MarkerRecordedEventAttributes{
+				MarkerName: attributes.MarkerName,
+				Details:    attributes.Details,
+			}
+		})
+	case s.DecisionType_CompleteWorkflowExecution:
+		h.addEvent(s.EventType_WorkflowExecutionCompleted, func(e *s.HistoryEvent) {
+			e.WorkflowExecutionCompletedEventAttributes = &s.WorkflowExecutionCompletedEventAttributes{
+				Result_: d.CompleteWorkflowExecutionDecisionAttributes.Result_,
+			}
+		})
+		return true
+	case s.DecisionType_FailWorkflowExecution:
+		h.addEvent(s.EventType_WorkflowExecutionFailed, func(e *s.HistoryEvent) {
+			e.WorkflowExecutionFailedEventAttributes = &s.WorkflowExecutionFailedEventAttributes{
+				Reason:  d.FailWorkflowExecutionDecisionAttributes.Reason,
+				Details: d.FailWorkflowExecutionDecisionAttributes.Details,
+			}
+		})
+		return true
+	case s.DecisionType_ContinueAsNewWorkflowExecution:
+		h.addEvent(s.EventType_WorkflowExecutionContinuedAsNew, func(e *s.HistoryEvent) {
+			e.WorkflowExecutionContinuedAsNewEventAttributes = &s.WorkflowExecutionContinuedAsNewEventAttributes{
+				WorkflowType: d.ContinueAsNewWorkflowExecutionDecisionAttributes.WorkflowType,
+				Input:        d.ContinueAsNewWorkflowExecutionDecisionAttributes.Input,
+			}
+		})
+		return true
+	default:
+		h.t.Fatalf("unsupported decision %v", d.GetDecisionType())
+	}
+	return false
+}
+
+// nextTime returns the time of the next activity completion, timer or signal, it is zero if there is none.
+func (h *historySimulator) nextTime() time.Time {
+	next := time.Time{}
+	earlier := func(t time.Time) bool {
+		return next.IsZero() || t.Before(next)
+	}
+	for _, t := range h.activities {
+		if earlier(t) {
+			next = t
+		}
+	}
+	for _, t := range h.timers {
+		if earlier(t) {
+			next = t
+		}
+	}
+	for _, child := range h.children {
+		if earlier(child.at) {
+			next = child.at
+		}
+	}
+	for _, signal := range h.signals {
+		if earlier(signal.at) {
+			next = signal.at
+		}
+	}
+	return next
+}
+
+// advance moves the clock to the next activity completion, timer or signal and records its events.
+func (h *historySimulator) advance() bool {
+	next := h.nextTime()
+	if next.IsZero() {
+		return false
+	}
+	if next.After(h.now) {
+		h.now = next
+	}
+
+	for scheduledID, t := range h.activities {
+		if t.After(next) {
+			continue
+		}
+		delete(h.activities, scheduledID)
+		startedID := h.addEvent(s.EventType_ActivityTaskStarted, func(e *s.HistoryEvent) {
+			e.ActivityTaskStartedEventAttributes = &s.ActivityTaskStartedEventAttributes{ScheduledEventId: &scheduledID}
+		})
+		h.addEvent(s.EventType_ActivityTaskCompleted, func(e *s.HistoryEvent) {
+			e.ActivityTaskCompletedEventAttributes = &s.ActivityTaskCompletedEventAttributes{
+				ScheduledEventId: &scheduledID,
+				StartedEventId:   &startedID,
+			}
+		})
+		if h.onActivityCompleted != nil {
+			h.onActivityCompleted(h.events[scheduledID-1])
+		}
+	}
+	for initiatedID, child := range h.children {
+		if child.at.After(next) {
+			continue
+		}
+		delete(h.children, initiatedID)
+		initiatedID, child := initiatedID, child
+		if h.runningWorkflowIDs[child.workflowID] {
+			cause := s.ChildWorkflowExecutionFailedCause_WORKFLOW_ALREADY_RUNNING
+			h.addEvent(s.EventType_StartChildWorkflowExecutionFailed, func(e *s.HistoryEvent) {
+				e.StartChildWorkflowExecutionFailedEventAttributes = &s.StartChildWorkflowExecutionFailedEventAttributes{
+					WorkflowId:       &child.workflowID,
+					Cause:            &cause,
+					InitiatedEventId: &initiatedID,
+				}
+			})
+			continue
+		}
+		execution := &s.WorkflowExecution{WorkflowId: &child.workflowID, RunId: stringPtr(child.workflowID + "-run")}
+		startedID := h.addEvent(s.EventType_ChildWorkflowExecutionStarted, func(e *s.HistoryEvent) {
+			e.ChildWorkflowExecutionStartedEventAttributes = &s.ChildWorkflowExecutionStartedEventAttributes{
+				InitiatedEventId:  &initiatedID,
+				WorkflowExecution: execution,
+			}
+		})
+		h.addEvent(s.EventType_ChildWorkflowExecutionCompleted, func(e *s.HistoryEvent) {
+			e.ChildWorkflowExecutionCompletedEventAttributes = &s.ChildWorkflowExecutionCompletedEventAttributes{
+				WorkflowExecution: execution,
+				InitiatedEventId:  &initiatedID,
+				StartedEventId:    &startedID,
+			}
+		})
+	}
+	for timerID, t := range h.timers {
+		if t.After(next) {
+			continue
+		}
+		delete(h.timers, timerID)
+		id := timerID
+		h.addEvent(s.EventType_TimerFired, func(e *s.HistoryEvent) {
+			e.TimerFiredEventAttributes = &s.TimerFiredEventAttributes{TimerId: &id}
+		})
+	}
+	var pending []simulatedSignal
+	for _, signal := range h.signals {
+		if signal.at.After(next) {
+			pending = append(pending, signal)
+			continue
+		}
+		signal := signal
+		h.addEvent(s.EventType_WorkflowExecutionSignaled, func(e *s.HistoryEvent) {
+			e.WorkflowExecutionSignaledEventAttributes = &s.WorkflowExecutionSignaledEventAttributes{
+				SignalName: &signal.name,
+				Input:      signal.input,
+			}
+		})
+	}
+	h.signals = pending
+	return true
+}
+
+func (h *historySimulator) addEvent(eventType s.EventType, setAttributes func(e *s.HistoryEvent)) int64 {
+	id := int64(len(h.events) + 1)
+	event := &s.HistoryEvent{
+		EventId:   int64Ptr(id),
+		Timestamp: int64Ptr(h.now.UnixNano()),
+		EventType: &eventType,
+	}
+	setAttributes(event)
+	h.events = append(h.events, event)
+	return id
+}
+
+// encodeValues encodes arguments the same way the client does.
+func encodeValues(t *testing.T, values ...interface{}) []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, v := range values {
+		require.NoError(t, enc.Encode(v))
+	}
+	return buf.Bytes()
+}
+
+// getFunctionName returns the name the client registers a workflow or activity function with.
+func getFunctionName(i interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
+}
+
+func stringPtr(v string) *string { return &v }
+func int32Ptr(v int32) *int32    { return &v }
+func int64Ptr(v int64) *int64    { return &v }
+
+// markerEvents returns the MarkerRecorded events of the history.
+func (h *historySimulator) markerEvents() []*s.HistoryEvent {
+	var result []*s.HistoryEvent
+	for _, e := range h.events {
+		if e.GetEventType() == s.EventType_MarkerRecorded {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// continuedState returns the state the run handed over to its next run with the given continue-as-new decision.
+func continuedState(t *testing.T, closeDecision *s.Decision) CronState {
+	_, state := continuedArgs(t, closeDecision)
+	return state
+}
+
+// continuedArgs returns the spec and the state the run handed over to its next run with the given continue-as-new
+// decision.
+func continuedArgs(t *testing.T, closeDecision *s.Decision) (spec ScheduleSpec, state CronState) {
+	require.Equal(t, s.DecisionType_ContinueAsNewWorkflowExecution, closeDecision.GetDecisionType())
+	decoder := gob.NewDecoder(bytes.NewReader(closeDecision.GetContinueAsNewWorkflowExecutionDecisionAttributes().Input))
+	require.NoError(t, decoder.Decode(&spec))
+	require.NoError(t, decoder.Decode(&state))
+	return spec, state
+}
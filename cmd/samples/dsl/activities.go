@@ -4,6 +4,15 @@ import (
 	"fmt"
 )
 
+// activities are the activities a DSL document can invoke, by the name the document refers to them with.
+var activities = map[string]interface{}{
+	"sampleActivity1": sampleActivity1,
+	"sampleActivity2": sampleActivity2,
+	"sampleActivity3": sampleActivity3,
+	"sampleActivity4": sampleActivity4,
+	"sampleActivity5": sampleActivity5,
+}
+
 func sampleActivity1(input []string) (string, error) {
 	name := "sampleActivity1"
 	fmt.Printf("Run %s with input %v \n", name, input)
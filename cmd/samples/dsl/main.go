@@ -34,6 +34,22 @@ func startWorkflow(h *common.SampleHelper, w Workflow) {
 	h.StartWorkflow(workflowOptions, SimpleDSLWorkflow, w)
 }
 
+// loadWorkflow reads the workflow from the yaml file, and validates it so that an invalid workflow isn't started.
+func loadWorkflow(dslConfig string) (Workflow, error) {
+	var workflow Workflow
+	data, err := ioutil.ReadFile(dslConfig)
+	if err != nil {
+		return workflow, fmt.Errorf("failed to load dsl config file %v", err)
+	}
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return workflow, fmt.Errorf("failed to unmarshal dsl config %v", err)
+	}
+	if err := workflow.validate(); err != nil {
+		return workflow, fmt.Errorf("invalid dsl config %v: %v", dslConfig, err)
+	}
+	return workflow, nil
+}
+
 func main() {
 	var mode, dslConfig string
 	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
@@ -51,14 +67,9 @@ func main() {
 		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
 		select {}
 	case "trigger":
-
-		data, err := ioutil.ReadFile(dslConfig)
+		workflow, err := loadWorkflow(dslConfig)
 		if err != nil {
-			panic(fmt.Sprintf("failed to load dsl config file %v", err))
-		}
-		var workflow Workflow
-		if err := yaml.Unmarshal(data, &workflow); err != nil {
-			panic(fmt.Sprintf("failed to unmarshal dsl config %v", err))
+			panic(err)
 		}
 		startWorkflow(&h, workflow)
 	}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// step is an ActivityInvocation of a Workflow, with the path of its statement in the document for the errors.
+type step struct {
+	path       string
+	invocation *ActivityInvocation
+}
+
+func (s *step) String() string {
+	return fmt.Sprintf("%s (%s)", s.path, s.invocation.Name)
+}
+
+// validate checks the workflow before it runs any activity: every statement is exactly one activity, sequence or
+// parallel, every activity is one that the DSL can invoke, every result is bound once, and every argument refers to a
+// variable or to the result of a step that completed before. A step that depends on its own result, directly or through
+// other steps, is reported as a cycle.
+func (w *Workflow) validate() error {
+	var steps []*step
+	if err := collectSteps(&w.Root, "root", &steps); err != nil {
+		return err
+	}
+
+	// binders are the steps that bind the results, nil for the variables.
+	binders := make(map[string]*step)
+	for name := range w.Variables {
+		binders[name] = nil
+	}
+	for _, s := range steps {
+		result := s.invocation.Result
+		if result == "" {
+			continue
+		}
+		if binder, ok := binders[result]; ok {
+			if binder == nil {
+				return fmt.Errorf("%v binds its result to %q, which is a variable", s, result)
+			}
+			return fmt.Errorf("%v binds its result to %q, which %v binds already", s, result, binder)
+		}
+		binders[result] = s
+	}
+	for _, s := range steps {
+		for _, arg := range s.invocation.Arguments {
+			if _, ok := binders[arg]; !ok {
+				return fmt.Errorf("%v refers to %q, which is neither a variable nor a result", s, arg)
+			}
+		}
+	}
+	if err := checkCycles(steps, binders); err != nil {
+		return err
+	}
+
+	bound := make(map[string]bool)
+	for name := range w.Variables {
+		bound[name] = true
+	}
+	_, err := checkOrder(&w.Root, "root", bound, binders)
+	return err
+}
+
+func collectSteps(statement *Statement, path string, steps *[]*step) error {
+	if statement == nil {
+		return fmt.Errorf("%s is empty", path)
+	}
+	kinds := 0
+	for _, set := range []bool{statement.Activity != nil, statement.Sequence != nil, statement.Parallel != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%s must be exactly one of activity, sequence or parallel", path)
+	}
+	switch {
+	case statement.Activity != nil:
+		if _, ok := activityNames[statement.Activity.Name]; !ok {
+			return fmt.Errorf("%s invokes unknown activity %q, known activities are %s", path, statement.Activity.Name,
+				strings.Join(knownActivities(), ", "))
+		}
+		*steps = append(*steps, &step{path: path, invocation: statement.Activity})
+	case statement.Sequence != nil:
+		for i, element := range statement.Sequence.Elements {
+			if err := collectSteps(element, fmt.Sprintf("%s.sequence[%d]", path, i), steps); err != nil {
+				return err
+			}
+		}
+	case statement.Parallel != nil:
+		for i, branch := range statement.Parallel.Branches {
+			if err := collectSteps(branch, fmt.Sprintf("%s.parallel[%d]", path, i), steps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCycles reports the first step that depends on its own result, with the steps in between.
+func checkCycles(steps []*step, binders map[string]*step) error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[*step]int)
+	var visit func(s *step, chain []*step) error
+	visit = func(s *step, chain []*step) error {
+		chain = append(chain, s)
+		switch state[s] {
+		case visiting:
+			var names []string
+			for _, c := range chain[indexOf(chain, s):] {
+				names = append(names, c.String())
+			}
+			return fmt.Errorf("cycle in the variable references: %s", strings.Join(names, " uses the result of "))
+		case visited:
+			return nil
+		}
+		state[s] = visiting
+		for _, arg := range s.invocation.Arguments {
+			if binder := binders[arg]; binder != nil {
+				if err := visit(binder, chain); err != nil {
+					return err
+				}
+			}
+		}
+		state[s] = visited
+		return nil
+	}
+	for _, s := range steps {
+		if err := visit(s, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexOf(chain []*step, s *step) int {
+	for i, c := range chain {
+		if c == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkOrder checks that the arguments of the steps are bound by the time they run, i.e. by a step earlier in a
+// sequence and not by a parallel branch that runs alongside. It returns the variables bound once the statement is done.
+func checkOrder(statement *Statement, path string, bound map[string]bool, binders map[string]*step) (map[string]bool,
+	error) {
+	switch {
+	case statement.Activity != nil:
+		for _, arg := range statement.Activity.Arguments {
+			if !bound[arg] {
+				return nil, fmt.Errorf("%s (%s) refers to %q, which %v binds but doesn't complete before",
+					path, statement.Activity.Name, arg, binders[arg])
+			}
+		}
+		if statement.Activity.Result == "" {
+			return bound, nil
+		}
+		return with(bound, statement.Activity.Result), nil
+	case statement.Sequence != nil:
+		for i, element := range statement.Sequence.Elements {
+			var err error
+			if bound, err = checkOrder(element, fmt.Sprintf("%s.sequence[%d]", path, i), bound, binders); err != nil {
+				return nil, err
+			}
+		}
+		return bound, nil
+	default:
+		// the branches only see what was bound before the parallel block.
+		all := bound
+		for i, branch := range statement.Parallel.Branches {
+			done, err := checkOrder(branch, fmt.Sprintf("%s.parallel[%d]", path, i), bound, binders)
+			if err != nil {
+				return nil, err
+			}
+			for name := range done {
+				all = with(all, name)
+			}
+		}
+		return all, nil
+	}
+}
+
+// with returns a copy of bound that binds name too.
+func with(bound map[string]bool, name string) map[string]bool {
+	if bound[name] {
+		return bound
+	}
+	copied := make(map[string]bool, len(bound)+1)
+	for n := range bound {
+		copied[n] = true
+	}
+	copied[name] = true
+	return copied
+}
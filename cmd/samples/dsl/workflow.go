@@ -1,6 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/cadence"
@@ -36,9 +41,9 @@ type (
 		Branches []*Statement
 	}
 
-	// ActivityInvocation is used to express invoking an Activity. The Arguments defined expected arguments as input to
-	// the Activity, the result specify the name of variable that it will store the result as which can then be used as
-	// arguments to subsequent ActivityInvocation.
+	// ActivityInvocation is used to express invoking an Activity. The Name is the name of the Activity in activities.
+	// The Arguments defined expected arguments as input to the Activity, the result specify the name of variable that
+	// it will store the result as which can then be used as arguments to subsequent ActivityInvocation.
 	ActivityInvocation struct {
 		Name      string
 		Arguments []string
@@ -50,19 +55,34 @@ type (
 	}
 )
 
+// activityNames maps the names of the activities in the DSL to the names they are registered with, which is the
+// name of the function.
+var activityNames = make(map[string]string)
+
 // This is registration process where you register all your workflows
 // and activity function handlers.
 func init() {
 	cadence.RegisterWorkflow(SimpleDSLWorkflow)
-	cadence.RegisterActivity(sampleActivity1)
-	cadence.RegisterActivity(sampleActivity2)
-	cadence.RegisterActivity(sampleActivity3)
-	cadence.RegisterActivity(sampleActivity4)
-	cadence.RegisterActivity(sampleActivity5)
+	for name, activity := range activities {
+		cadence.RegisterActivity(activity)
+		activityNames[name] = runtime.FuncForPC(reflect.ValueOf(activity).Pointer()).Name()
+	}
+}
+
+func knownActivities() []string {
+	var names []string
+	for name := range activityNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-// SimpleDSLWorkflow workflow decider
-func SimpleDSLWorkflow(ctx cadence.Context, workflow Workflow) ([]byte, error) {
+// SimpleDSLWorkflow workflow decider, it returns the variables and the results bound by the workflow.
+func SimpleDSLWorkflow(ctx cadence.Context, workflow Workflow) (map[string]string, error) {
+	if err := workflow.validate(); err != nil {
+		return nil, err
+	}
 	bindings := make(map[string]string)
 	for k, v := range workflow.Variables {
 		bindings[k] = v
@@ -83,7 +103,7 @@ func SimpleDSLWorkflow(ctx cadence.Context, workflow Workflow) ([]byte, error) {
 	}
 
 	logger.Info("DSL Workflow completed.")
-	return nil, err
+	return bindings, nil
 }
 
 func (b *Statement) execute(ctx cadence.Context, bindings map[string]string) error {
@@ -111,7 +131,7 @@ func (b *Statement) execute(ctx cadence.Context, bindings map[string]string) err
 func (a ActivityInvocation) execute(ctx cadence.Context, bindings map[string]string) error {
 	inputParam := makeInput(a.Arguments, bindings)
 	var result string
-	err := cadence.ExecuteActivity(ctx, a.Name, inputParam).Get(ctx, &result)
+	err := cadence.ExecuteActivity(ctx, activityNames[a.Name], inputParam).Get(ctx, &result)
 	if err != nil {
 		return err
 	}
@@ -132,35 +152,33 @@ func (s Sequence) execute(ctx cadence.Context, bindings map[string]string) error
 }
 
 func (p Parallel) execute(ctx cadence.Context, bindings map[string]string) error {
-	//
-	// You can use the context passed in to activity as a way to cancel the activity like standard GO way.
-	// Cancelling a parent context will cancel all the derived contexts as well.
-	//
-
-	// In the parallel block, we want to execute all of them in parallel and wait for all of them.
-	// if one activity fails then we want to cancel all the rest of them as well.
-	childCtx, cancelHandler := cadence.WithCancel(ctx)
+	// In the parallel block, we want to execute all of them in parallel and wait for all of them. If some branches
+	// fail then the block fails with the errors of all of them, in the order of the branches, after the other
+	// branches completed.
 	selector := cadence.NewSelector(ctx)
-	var activityErr error
-	for _, s := range p.Branches {
-		f := executeAsync(s, childCtx, bindings)
+	errs := make([]error, len(p.Branches))
+	for i, s := range p.Branches {
+		branch := i
+		f := executeAsync(s, ctx, bindings)
 		selector.AddFuture(f, func(f cadence.Future) {
-			err := f.Get(ctx, nil)
-			if err != nil {
-				// cancel all pending activities
-				cancelHandler()
-				activityErr = err
-			}
+			errs[branch] = f.Get(ctx, nil)
 		})
 	}
 
 	for i := 0; i < len(p.Branches); i++ {
 		selector.Select(ctx) // this will wait for one branch
-		if activityErr != nil {
-			return activityErr
-		}
 	}
 
+	var failures []string
+	for branch, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("branch %d: %v", branch, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d parallel branches failed: %s", len(failures), len(p.Branches),
+			strings.Join(failures, "; "))
+	}
 	return nil
 }
 
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+	"gopkg.in/yaml.v2"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) Test_Workflow1() {
+	workflow, err := loadWorkflow("workflow1.yaml")
+	s.NoError(err)
+	env := s.NewTestWorkflowEnvironment()
+	// every step receives the results of the steps before.
+	env.OnActivity(sampleActivity1, []string{"value1"}).Return("r1", nil).Once()
+	env.OnActivity(sampleActivity2, []string{"r1"}).Return("r2", nil).Once()
+	env.OnActivity(sampleActivity3, []string{"value2", "r2"}).Return("r3", nil).Once()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, workflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var bindings map[string]string
+	s.NoError(env.GetWorkflowResult(&bindings))
+	s.Equal(map[string]string{"arg1": "value1", "arg2": "value2", "result1": "r1", "result2": "r2", "result3": "r3"},
+		bindings)
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_Workflow2() {
+	workflow, err := loadWorkflow("workflow2.yaml")
+	s.NoError(err)
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleActivity1, []string{"value1"}).Return("r1", nil).Once()
+	env.OnActivity(sampleActivity2, []string{"r1"}).Return("r2", nil).Once()
+	env.OnActivity(sampleActivity3, []string{"value2", "r2"}).Return("r3", nil).Once()
+	env.OnActivity(sampleActivity4, []string{"r1"}).Return("r4", nil).Once()
+	env.OnActivity(sampleActivity5, []string{"value3", "r4"}).Return("r5", nil).Once()
+	// the last step receives the results of both parallel branches.
+	env.OnActivity(sampleActivity1, []string{"r3", "r5"}).Return("r6", nil).Once()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, workflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var bindings map[string]string
+	s.NoError(env.GetWorkflowResult(&bindings))
+	s.Equal("r6", bindings["result6"])
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_Workflow2_ParallelBranchesFail() {
+	workflow, err := loadWorkflow("workflow2.yaml")
+	s.NoError(err)
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(sampleActivity1, []string{"value1"}).Return("r1", nil).Once()
+	env.OnActivity(sampleActivity2, []string{"r1"}).Return("", errors.New("first")).Once()
+	env.OnActivity(sampleActivity4, []string{"r1"}).Return("r4", nil).Once()
+	env.OnActivity(sampleActivity5, []string{"value3", "r4"}).Return("", errors.New("second")).Once()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, workflow)
+
+	s.True(env.IsWorkflowCompleted())
+	s.EqualError(env.GetWorkflowError(), "2 of 2 parallel branches failed: branch 0: first; branch 1: second")
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_InvalidWorkflow() {
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{Root: Statement{Activity: &ActivityInvocation{Name: "unknown"}}})
+
+	s.True(env.IsWorkflowCompleted())
+	s.EqualError(env.GetWorkflowError(), `root invokes unknown activity "unknown", known activities are `+
+		`sampleActivity1, sampleActivity2, sampleActivity3, sampleActivity4, sampleActivity5`)
+}
+
+func (s *UnitTestSuite) Test_Validate() {
+	for _, test := range []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{"empty statement", `
+root:
+  sequence:
+    elements:
+      - activity:
+          name: sampleActivity1
+      -`, "root.sequence[1] is empty"},
+		{"two kinds", `
+root:
+  activity:
+    name: sampleActivity1
+  sequence:
+    elements: []`, "root must be exactly one of activity, sequence or parallel"},
+		{"unknown variable", `
+root:
+  activity:
+    name: sampleActivity1
+    arguments: [arg1]`, `root (sampleActivity1) refers to "arg1", which is neither a variable nor a result`},
+		{"result bound twice", `
+variables:
+  arg1: value1
+root:
+  sequence:
+    elements:
+      - activity: {name: sampleActivity1, arguments: [arg1], result: result1}
+      - activity: {name: sampleActivity2, arguments: [arg1], result: result1}`,
+			`root.sequence[1] (sampleActivity2) binds its result to "result1", which root.sequence[0] ` +
+				`(sampleActivity1) binds already`},
+		{"result bound to a variable", `
+variables:
+  arg1: value1
+root:
+  activity: {name: sampleActivity1, result: arg1}`,
+			`root (sampleActivity1) binds its result to "arg1", which is a variable`},
+		{"own result", `
+root:
+  activity: {name: sampleActivity1, arguments: [result1], result: result1}`,
+			"cycle in the variable references: root (sampleActivity1) uses the result of root (sampleActivity1)"},
+		{"cycle", `
+root:
+  sequence:
+    elements:
+      - activity: {name: sampleActivity1, arguments: [result2], result: result1}
+      - activity: {name: sampleActivity2, arguments: [result1], result: result2}`,
+			"cycle in the variable references: root.sequence[0] (sampleActivity1) uses the result of " +
+				"root.sequence[1] (sampleActivity2) uses the result of root.sequence[0] (sampleActivity1)"},
+		{"later result", `
+root:
+  sequence:
+    elements:
+      - activity: {name: sampleActivity1, arguments: [result2], result: result1}
+      - activity: {name: sampleActivity2, result: result2}`,
+			`root.sequence[0] (sampleActivity1) refers to "result2", which root.sequence[1] (sampleActivity2) ` +
+				`binds but doesn't complete before`},
+		{"parallel sibling", `
+root:
+  parallel:
+    branches:
+      - activity: {name: sampleActivity1, result: result1}
+      - activity: {name: sampleActivity2, arguments: [result1]}`,
+			`root.parallel[1] (sampleActivity2) refers to "result1", which root.parallel[0] (sampleActivity1) ` +
+				`binds but doesn't complete before`},
+	} {
+		var workflow Workflow
+		s.NoError(yaml.Unmarshal([]byte(test.document), &workflow), test.name)
+		s.EqualError(workflow.validate(), test.expected, test.name)
+	}
+}
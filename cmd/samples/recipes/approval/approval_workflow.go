@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow waits for an external event, but gives up after a while. It prepares a request, then waits for
+ * the approval signal with a selector on the signal channel and a timer. The first approval completes the request.
+ * When the timer fires first the request is escalated instead, and the workflow completes as escalated.
+ *
+ * Signals are buffered by the channel until the workflow receives them, an approval sent while the request is still
+ * being prepared is received by the wait. The approvals that arrived together with the first one are part of the
+ * result, and the ones that arrive after the timeout while the escalation runs are ignored. Once the workflow is
+ * closed the server rejects the signal, the starter reports that the request was decided already.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "approvalGroup"
+
+// approvalSignalName is the name of the signal that approves a request.
+const approvalSignalName = "approval"
+
+// Statuses of the ApprovalResult.
+const (
+	statusApproved  = "approved"
+	statusRejected  = "rejected"
+	statusEscalated = "escalated"
+)
+
+type (
+	// ApprovalRequest is the input of the workflow.
+	ApprovalRequest struct {
+		ID string
+		// Timeout is how long the workflow waits for the approval once the request is prepared.
+		Timeout time.Duration
+	}
+
+	// Approval is the payload of the approval signal.
+	Approval struct {
+		Approver string `json:"approver"`
+		Approved bool   `json:"approved"`
+		Comment  string `json:"comment"`
+	}
+
+	// ApprovalResult is the result of the workflow.
+	ApprovalResult struct {
+		// Status is approved or rejected by the first approval, or escalated if none arrived in time.
+		Status string
+		// Approvals are the approvals received by the time the request was decided, the first one decided it.
+		Approvals []Approval
+		// IgnoredApprovals counts the approvals that arrived after the timeout.
+		IgnoredApprovals int
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(ApprovalWorkflow)
+	cadence.RegisterActivity(prepareRequestActivity)
+	cadence.RegisterActivity(completeRequestActivity)
+	cadence.RegisterActivity(escalateRequestActivity)
+}
+
+// ApprovalWorkflow workflow decider
+func ApprovalWorkflow(ctx cadence.Context, request ApprovalRequest) (ApprovalResult, error) {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx).With(zap.String("RequestID", request.ID))
+	approvals := cadence.GetSignalChannel(ctx, approvalSignalName)
+
+	var result ApprovalResult
+	if err := cadence.ExecuteActivity(ctx, prepareRequestActivity, request).Get(ctx, nil); err != nil {
+		return result, err
+	}
+
+	// the timer is cancelled once the approval arrived, it doesn't fire into a closed workflow.
+	timerCtx, cancelTimer := cadence.WithCancel(ctx)
+	defer cancelTimer()
+	timedOut := false
+	selector := cadence.NewSelector(ctx)
+	selector.AddReceive(approvals, func(c cadence.Channel, more bool) {
+		var approval Approval
+		c.Receive(ctx, &approval)
+		result.Approvals = append(result.Approvals, approval)
+	})
+	selector.AddFuture(cadence.NewTimer(timerCtx, request.Timeout), func(f cadence.Future) {
+		timedOut = true
+	})
+	logger.Info("Waiting for approval.", zap.Duration("Timeout", request.Timeout))
+	selector.Select(ctx)
+
+	if timedOut {
+		logger.Info("Approval timed out, escalating.")
+		if err := cadence.ExecuteActivity(ctx, escalateRequestActivity, request).Get(ctx, nil); err != nil {
+			return result, err
+		}
+		result.Status = statusEscalated
+		// the approvals that arrived meanwhile are too late.
+		result.IgnoredApprovals = drain(ctx, approvals, func(approval Approval) {
+			logger.Info("Approval arrived after timeout, ignored.", zap.String("Approver", approval.Approver))
+		})
+		return result, nil
+	}
+
+	cancelTimer()
+	// the approvals that arrived together with the first one are not lost.
+	drain(ctx, approvals, func(approval Approval) {
+		result.Approvals = append(result.Approvals, approval)
+	})
+	first := result.Approvals[0]
+	result.Status = statusRejected
+	if first.Approved {
+		result.Status = statusApproved
+	}
+	logger.Info("Request decided.", zap.String("Status", result.Status), zap.String("Approver", first.Approver),
+		zap.Int("Approvals", len(result.Approvals)))
+	if err := cadence.ExecuteActivity(ctx, completeRequestActivity, request, first).Get(ctx, nil); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// drain receives the approvals pending on the channel, and returns how many there were.
+func drain(ctx cadence.Context, approvals cadence.Channel, handle func(Approval)) int {
+	count := 0
+	for {
+		var approval Approval
+		if !approvals.ReceiveAsync(&approval) {
+			return count
+		}
+		handle(approval)
+		count++
+	}
+}
+
+func prepareRequestActivity(ctx context.Context, request ApprovalRequest) error {
+	// ...
+	cadence.GetActivityLogger(ctx).Info("Request prepared.", zap.String("RequestID", request.ID))
+	return nil
+}
+
+func completeRequestActivity(ctx context.Context, request ApprovalRequest, approval Approval) error {
+	// ...
+	cadence.GetActivityLogger(ctx).Info("Request completed.", zap.String("RequestID", request.ID),
+		zap.String("Approver", approval.Approver), zap.Bool("Approved", approval.Approved),
+		zap.String("Comment", approval.Comment))
+	return nil
+}
+
+func escalateRequestActivity(ctx context.Context, request ApprovalRequest) error {
+	// ...
+	cadence.GetActivityLogger(ctx).Info("Request escalated.", zap.String("RequestID", request.ID))
+	return nil
+}
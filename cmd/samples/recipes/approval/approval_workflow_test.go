@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) Test_ApprovalWorkflow_ApproveBeforeWait() {
+	env := s.NewTestWorkflowEnvironment()
+	// the request takes a minute to prepare, both approvals arrive meanwhile.
+	env.OverrideActivity(prepareRequestActivity, func(ctx context.Context, request ApprovalRequest) error {
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, nil, nil)
+		}, time.Minute)
+		return cadence.ErrActivityResultPending
+	})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(approvalSignalName, Approval{Approver: "alice", Approved: true})
+		env.SignalWorkflow(approvalSignalName, Approval{Approver: "bob", Approved: false})
+	}, time.Second*10)
+	env.OnActivity(completeRequestActivity, mock.Anything, mock.Anything,
+		Approval{Approver: "alice", Approved: true}).Return(nil).Once()
+	env.ExecuteWorkflow(ApprovalWorkflow, ApprovalRequest{ID: "request-1", Timeout: time.Hour})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result ApprovalResult
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(ApprovalResult{Status: statusApproved, Approvals: []Approval{{Approver: "alice", Approved: true},
+		{Approver: "bob"}}}, result)
+	// the workflow didn't wait for the timer.
+	s.Equal(time.Unix(0, 0).Add(time.Minute), env.Now())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_ApprovalWorkflow_ApproveDuringWait() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(approvalSignalName, Approval{Approver: "alice", Comment: "over budget"})
+	}, time.Minute*10)
+	env.OnActivity(completeRequestActivity, mock.Anything, mock.Anything,
+		Approval{Approver: "alice", Comment: "over budget"}).Return(nil).Once()
+	env.ExecuteWorkflow(ApprovalWorkflow, ApprovalRequest{ID: "request-1", Timeout: time.Hour})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result ApprovalResult
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(statusRejected, result.Status)
+	s.Len(result.Approvals, 1)
+	s.Equal(time.Unix(0, 0).Add(time.Minute*10), env.Now())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_ApprovalWorkflow_Timeout() {
+	env := s.NewTestWorkflowEnvironment()
+	// the approval arrives while the request is escalated, after the timeout.
+	env.OverrideActivity(escalateRequestActivity, func(ctx context.Context, request ApprovalRequest) error {
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		env.RegisterDelayedCallback(func() {
+			env.CompleteActivity(taskToken, nil, nil)
+		}, time.Minute)
+		return cadence.ErrActivityResultPending
+	})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(approvalSignalName, Approval{Approver: "alice", Approved: true})
+	}, time.Hour+time.Second*10)
+	var activities []string
+	env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context, args cadence.EncodedValues) {
+		name := info.ActivityType.Name
+		activities = append(activities, name[strings.LastIndex(name, ".")+1:])
+	})
+	env.ExecuteWorkflow(ApprovalWorkflow, ApprovalRequest{ID: "request-1", Timeout: time.Hour})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result ApprovalResult
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal(ApprovalResult{Status: statusEscalated, IgnoredApprovals: 1}, result)
+	s.Equal([]string{"prepareRequestActivity", "escalateRequestActivity"}, activities)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+func startWorkflow(h *common.SampleHelper, timeout time.Duration) {
+	request := ApprovalRequest{ID: uuid.New(), Timeout: timeout}
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:                              "approval_" + request.ID,
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    timeout + time.Minute*5,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, ApprovalWorkflow, request)
+}
+
+// approve sends the approval signal with the given JSON payload to the workflow. A workflow that is closed already,
+// e.g. because the approval timed out, rejects the signal.
+func approve(h *common.SampleHelper, workflowID, payload string) {
+	var approval Approval
+	if err := json.Unmarshal([]byte(payload), &approval); err != nil {
+		h.Logger.Error("Invalid approval payload.", zap.String("Payload", payload), zap.Error(err))
+		panic(err)
+	}
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		h.Logger.Error("Failed to build cadence client.", zap.Error(err))
+		panic(err)
+	}
+	err = workflowClient.SignalWorkflow(workflowID, "", approvalSignalName, approval)
+	if _, ok := err.(*s.EntityNotExistsError); ok {
+		h.Logger.Info("Approval ignored, the request was decided already.", zap.String("WorkflowID", workflowID))
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to signal workflow", zap.Error(err))
+		panic("Failed to signal workflow.")
+	}
+	h.Logger.Info("Approval sent.", zap.String("WorkflowID", workflowID), zap.String("Approver", approval.Approver))
+}
+
+func main() {
+	var mode, workflowID, payload string
+	var timeoutInSeconds uint
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker, trigger or approve.")
+	flag.UintVar(&timeoutInSeconds, "timeout", 60, "Seconds the workflow waits for the approval before it escalates.")
+	flag.StringVar(&workflowID, "w", "", "WorkflowID of the workflow to approve.")
+	flag.StringVar(&payload, "payload", `{"approver":"me","approved":true}`, "JSON payload of the approval.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	h.SetupServiceConfig()
+
+	switch mode {
+	case "worker":
+		startWorkers(&h)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		startWorkflow(&h, time.Second*time.Duration(timeoutInSeconds))
+	case "approve":
+		approve(&h, workflowID, payload)
+	}
+}
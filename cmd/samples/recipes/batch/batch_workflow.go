@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow processes a dataset that is too large for the history of one workflow run. It fetches the record
+ * IDs a page at a time, processes the records of a page with at most Parallelism activities in flight, and moves on to
+ * the next page with the token the fetch returned. Every PagesBeforeContinueAsNew pages it continues as new, the way
+ * the cron sample does after its runs, so the history of a run stays small however large the dataset is.
+ *
+ * Everything the next run needs is in its BatchInput: the token of the next page and the progress so far, including
+ * the records that failed. A batch that failed part way is restarted the same way, with the token of the page it
+ * failed on as the PageToken of the input.
+ *
+ * A record that fails to process is recorded and skipped, a page that fails to fetch fails the batch once the fetch
+ * failed maxFetchAttempts times.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "batchGroup"
+
+// maxFetchAttempts is the number of times a page is fetched before the batch fails.
+const maxFetchAttempts = 3
+
+// fetchRetryInterval is the time to wait before fetching a page again, it doubles with every attempt.
+var fetchRetryInterval = time.Second * 10
+
+type (
+	// BatchInput is the input of the BatchWorkflow, and of the runs it continues as new with.
+	BatchInput struct {
+		// PageSize is the number of record IDs of a page.
+		PageSize int
+		// Parallelism is the number of records in flight at most.
+		Parallelism int
+		// PagesBeforeContinueAsNew is the number of pages a run processes before it continues as new.
+		PagesBeforeContinueAsNew int
+		// PageToken is the token of the page to process first, empty for the first page of the dataset.
+		PageToken string
+		// Progress is the progress of the previous runs.
+		Progress BatchProgress
+	}
+
+	// BatchProgress is what the batch processed, it is the result of the BatchWorkflow.
+	BatchProgress struct {
+		Pages     int
+		Processed int
+		// FailedRecords are the IDs of the records that failed to process, in the order of the pages.
+		FailedRecords []string
+	}
+
+	// FetchPageInput is the input of fetchPageActivity.
+	FetchPageInput struct {
+		PageToken string
+		PageSize  int
+	}
+
+	// Page is a page of the dataset.
+	Page struct {
+		RecordIDs []string
+		// NextPageToken is the token of the next page, it is empty on the last page.
+		NextPageToken string
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(BatchWorkflow)
+	cadence.RegisterActivity(fetchPageActivity)
+	cadence.RegisterActivity(processRecordActivity)
+}
+
+func (input BatchInput) validate() error {
+	if input.PageSize < 1 {
+		return errors.New("page size must be at least 1")
+	}
+	if input.Parallelism < 1 {
+		return errors.New("parallelism must be at least 1")
+	}
+	if input.PagesBeforeContinueAsNew < 1 {
+		return errors.New("pages before continue as new must be at least 1")
+	}
+	return nil
+}
+
+// BatchWorkflow workflow decider
+func BatchWorkflow(ctx cadence.Context, input BatchInput) (BatchProgress, error) {
+	if err := input.validate(); err != nil {
+		return BatchProgress{}, err
+	}
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx)
+
+	progress := input.Progress
+	for pages := 0; pages < input.PagesBeforeContinueAsNew; pages++ {
+		page, err := fetchPage(ctx, FetchPageInput{PageToken: input.PageToken, PageSize: input.PageSize})
+		if err != nil {
+			logger.Error("Workflow failed to fetch a page.", zap.String("PageToken", input.PageToken), zap.Error(err))
+			return progress, fmt.Errorf("fetching page %q failed, restart the batch with it: %v", input.PageToken, err)
+		}
+		if progress.Pages == 0 && len(page.RecordIDs) == 0 && page.NextPageToken == "" {
+			logger.Info("Workflow completed, the dataset is empty.")
+			return progress, nil
+		}
+
+		failed := processRecords(ctx, page.RecordIDs, input.Parallelism)
+		progress.Pages++
+		progress.Processed += len(page.RecordIDs) - len(failed)
+		progress.FailedRecords = append(progress.FailedRecords, failed...)
+		logger.Info("Page processed.", zap.String("PageToken", input.PageToken),
+			zap.Int("Records", len(page.RecordIDs)), zap.Strings("FailedRecords", failed))
+
+		if page.NextPageToken == "" {
+			logger.Info("Workflow completed.", zap.Int("Pages", progress.Pages), zap.Int("Processed", progress.Processed),
+				zap.Int("Failed", len(progress.FailedRecords)))
+			return progress, nil
+		}
+		input.PageToken = page.NextPageToken
+	}
+
+	// continue with the next page in a new run, which starts with an empty history.
+	input.Progress = progress
+	logger.Info("Workflow continues as new.", zap.String("PageToken", input.PageToken),
+		zap.Int("Pages", progress.Pages))
+	return progress, cadence.NewContinueAsNewError(ctx, BatchWorkflow, input)
+}
+
+// fetchPage fetches the page, and fetches it again with a backoff while it fails.
+func fetchPage(ctx cadence.Context, input FetchPageInput) (Page, error) {
+	backoff := fetchRetryInterval
+	for attempt := 1; ; attempt++ {
+		var page Page
+		err := cadence.ExecuteActivity(ctx, fetchPageActivity, input).Get(ctx, &page)
+		if err == nil || attempt >= maxFetchAttempts {
+			return page, err
+		}
+		cadence.GetLogger(ctx).Info("Fetching the page failed, retrying.", zap.Int("Attempt", attempt),
+			zap.Duration("Backoff", backoff), zap.Error(err))
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return page, err
+		}
+		backoff *= 2
+	}
+}
+
+// processRecords processes the records with at most parallelism activities in flight, and returns the IDs of the ones
+// that failed in the order of the records.
+func processRecords(ctx cadence.Context, recordIDs []string, parallelism int) []string {
+	failed := make([]bool, len(recordIDs))
+	selector := cadence.NewSelector(ctx)
+	inFlight, next := 0, 0
+	for {
+		for ; inFlight < parallelism && next < len(recordIDs); next++ {
+			index := next
+			f := cadence.ExecuteActivity(ctx, processRecordActivity, recordIDs[index])
+			inFlight++
+			selector.AddFuture(f, func(f cadence.Future) {
+				inFlight--
+				if err := f.Get(ctx, nil); err != nil {
+					cadence.GetLogger(ctx).Info("Record failed.", zap.String("RecordID", recordIDs[index]),
+						zap.Error(err))
+					failed[index] = true
+				}
+			})
+		}
+		if inFlight == 0 {
+			break
+		}
+		selector.Select(ctx)
+	}
+
+	var failedIDs []string
+	for index, recordID := range recordIDs {
+		if failed[index] {
+			failedIDs = append(failedIDs, recordID)
+		}
+	}
+	return failedIDs
+}
+
+// datasetSize is the number of records of the fake dataset, every corruptEvery-th record fails to process.
+const (
+	datasetSize  = 95
+	corruptEvery = 20
+)
+
+// fetchPageActivity fetches a page of the fake dataset, the page token is the offset of the page.
+func fetchPageActivity(ctx context.Context, input FetchPageInput) (Page, error) {
+	offset := 0
+	if input.PageToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(input.PageToken); err != nil {
+			return Page{}, cadence.NewErrorWithDetails("invalidPageToken", input.PageToken)
+		}
+	}
+	var page Page
+	for record := offset; record < datasetSize && record < offset+input.PageSize; record++ {
+		page.RecordIDs = append(page.RecordIDs, fmt.Sprintf("record-%03d", record+1))
+	}
+	if offset+input.PageSize < datasetSize {
+		page.NextPageToken = strconv.Itoa(offset + input.PageSize)
+	}
+	cadence.GetActivityLogger(ctx).Info("Page fetched", zap.String("pageToken", input.PageToken),
+		zap.Int("records", len(page.RecordIDs)))
+	return page, nil
+}
+
+func processRecordActivity(ctx context.Context, recordID string) error {
+	var record int
+	fmt.Sscanf(recordID, "record-%d", &record)
+	if record%corruptEvery == 0 {
+		return errors.New("record is corrupt")
+	}
+	// some fake processing logic here
+	cadence.GetActivityLogger(ctx).Info("Record processed", zap.String("recordID", recordID))
+	return nil
+}
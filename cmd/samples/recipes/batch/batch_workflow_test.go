@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+// dataset is a fake dataset of 5 pages of 3 records, the token of a page is its number.
+type dataset struct {
+	pages int
+	// fetchFailures are the number of times fetching a page fails, by page token.
+	fetchFailures map[string]int
+	// failingRecords are the records that fail to process.
+	failingRecords map[string]bool
+
+	env         *cadence.TestWorkflowEnvironment
+	fetched     []string
+	processed   int
+	inFlight    int
+	maxInFlight int
+}
+
+func newDataset() *dataset {
+	return &dataset{pages: 5, fetchFailures: make(map[string]int), failingRecords: make(map[string]bool)}
+}
+
+func (d *dataset) fetch(ctx context.Context, input FetchPageInput) (Page, error) {
+	d.fetched = append(d.fetched, input.PageToken)
+	if d.fetchFailures[input.PageToken] > 0 {
+		d.fetchFailures[input.PageToken]--
+		return Page{}, errors.New("service unavailable")
+	}
+	number := 1
+	if input.PageToken != "" {
+		fmt.Sscanf(input.PageToken, "%d", &number)
+	}
+	var page Page
+	if number > d.pages {
+		return page, nil
+	}
+	for record := 1; record <= 3; record++ {
+		page.RecordIDs = append(page.RecordIDs, fmt.Sprintf("r%d-%d", number, record))
+	}
+	if number < d.pages {
+		page.NextPageToken = fmt.Sprint(number + 1)
+	}
+	return page, nil
+}
+
+// process completes the record a minute later, so that the records of a page are in flight together.
+func (d *dataset) process(ctx context.Context, recordID string) error {
+	taskToken := cadence.GetActivityInfo(ctx).TaskToken
+	d.inFlight++
+	if d.inFlight > d.maxInFlight {
+		d.maxInFlight = d.inFlight
+	}
+	d.env.RegisterDelayedCallback(func() {
+		d.inFlight--
+		d.processed++
+		var err error
+		if d.failingRecords[recordID] {
+			err = errors.New("record is corrupt")
+		}
+		d.env.CompleteActivity(taskToken, nil, err)
+	}, time.Minute)
+	return cadence.ErrActivityResultPending
+}
+
+// runBatch executes the workflow with the dataset, and the runs it continues as new with until one of them completes.
+// It returns the inputs of the runs.
+func (s *UnitTestSuite) runBatch(d *dataset, input BatchInput) (BatchProgress, []BatchInput, error) {
+	var runs []BatchInput
+	for {
+		runs = append(runs, input)
+		env := s.NewTestWorkflowEnvironment()
+		d.env = env
+		env.OverrideActivity(fetchPageActivity, d.fetch)
+		env.OverrideActivity(processRecordActivity, d.process)
+		env.ExecuteWorkflow(BatchWorkflow, input)
+
+		s.True(env.IsWorkflowCompleted())
+		err := env.GetWorkflowError()
+		if _, ok := err.(cadence.ContinueAsNewError); ok {
+			input = continueAsNewArgs(err)[0].(BatchInput)
+			continue
+		}
+		var progress BatchProgress
+		if err == nil {
+			s.NoError(env.GetWorkflowResult(&progress))
+		}
+		return progress, runs, err
+	}
+}
+
+// continueAsNewArgs returns the arguments of the run the workflow continues as new with, the ContinueAsNewError of
+// this version of the client doesn't expose them.
+func continueAsNewArgs(err error) []interface{} {
+	field := reflect.ValueOf(err).Elem().FieldByName("args")
+	return *(*[]interface{})(unsafe.Pointer(field.UnsafeAddr()))
+}
+
+func (s *UnitTestSuite) Test_Batch() {
+	d := newDataset()
+	d.failingRecords["r2-3"] = true
+	d.failingRecords["r5-1"] = true
+	progress, runs, err := s.runBatch(d, BatchInput{PageSize: 3, Parallelism: 2, PagesBeforeContinueAsNew: 2})
+
+	s.NoError(err)
+	s.Equal(BatchProgress{Pages: 5, Processed: 13, FailedRecords: []string{"r2-3", "r5-1"}}, progress)
+	s.Equal(15, d.processed)
+	s.Equal(2, d.maxInFlight)
+	s.Equal([]string{"", "2", "3", "4", "5"}, d.fetched)
+	// the runs continued as new after 2 pages, with the token of the next page and the progress so far.
+	s.Len(runs, 3)
+	s.Equal("3", runs[1].PageToken)
+	s.Equal(BatchProgress{Pages: 2, Processed: 5, FailedRecords: []string{"r2-3"}}, runs[1].Progress)
+	s.Equal("5", runs[2].PageToken)
+	s.Equal(BatchProgress{Pages: 4, Processed: 11, FailedRecords: []string{"r2-3"}}, runs[2].Progress)
+}
+
+func (s *UnitTestSuite) Test_Batch_ResumeFromToken() {
+	d := newDataset()
+	progress, runs, err := s.runBatch(d, BatchInput{PageSize: 3, Parallelism: 3, PagesBeforeContinueAsNew: 5,
+		PageToken: "4"})
+
+	s.NoError(err)
+	s.Equal(BatchProgress{Pages: 2, Processed: 6}, progress)
+	s.Equal([]string{"4", "5"}, d.fetched)
+	s.Len(runs, 1)
+}
+
+func (s *UnitTestSuite) Test_Batch_EmptyDataset() {
+	d := newDataset()
+	d.pages = 0
+	progress, _, err := s.runBatch(d, BatchInput{PageSize: 3, Parallelism: 2, PagesBeforeContinueAsNew: 2})
+
+	s.NoError(err)
+	s.Equal(BatchProgress{}, progress)
+	s.Equal(0, d.processed)
+}
+
+func (s *UnitTestSuite) Test_Batch_FetchRetried() {
+	d := newDataset()
+	d.fetchFailures["3"] = maxFetchAttempts - 1
+	progress, _, err := s.runBatch(d, BatchInput{PageSize: 3, Parallelism: 2, PagesBeforeContinueAsNew: 2})
+
+	s.NoError(err)
+	s.Equal(BatchProgress{Pages: 5, Processed: 15}, progress)
+	s.Equal([]string{"", "2", "3", "3", "3", "4", "5"}, d.fetched)
+}
+
+func (s *UnitTestSuite) Test_Batch_FetchFails() {
+	d := newDataset()
+	d.fetchFailures["3"] = maxFetchAttempts
+	_, runs, err := s.runBatch(d, BatchInput{PageSize: 3, Parallelism: 2, PagesBeforeContinueAsNew: 2})
+
+	s.Error(err)
+	// the error names the page to restart the batch from, the progress of the previous runs is kept.
+	s.Contains(err.Error(), `fetching page "3" failed`)
+	s.Len(runs, 2)
+	s.Equal(BatchProgress{Pages: 2, Processed: 6}, runs[1].Progress)
+	s.Equal([]string{"", "2", "3", "3", "3"}, d.fetched)
+}
+
+func (s *UnitTestSuite) Test_Batch_InvalidInput() {
+	_, _, err := s.runBatch(newDataset(), BatchInput{PageSize: 3, Parallelism: 0, PagesBeforeContinueAsNew: 2})
+	s.Error(err)
+	s.Contains(err.Error(), "parallelism")
+}
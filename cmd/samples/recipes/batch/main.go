@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+func startWorkflow(h *common.SampleHelper, input BatchInput) {
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:       "batch_" + uuid.New(),
+		TaskList: ApplicationName,
+		// the timeout is the one of every run, a run processes PagesBeforeContinueAsNew pages.
+		ExecutionStartToCloseTimeout:    time.Minute * 10,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, BatchWorkflow, input)
+}
+
+func main() {
+	var mode string
+	var input BatchInput
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.IntVar(&input.PageSize, "pageSize", 10, "Number of records of a page.")
+	flag.IntVar(&input.Parallelism, "parallelism", 4, "Number of records in flight at most.")
+	flag.IntVar(&input.PagesBeforeContinueAsNew, "pagesPerRun", 3, "Number of pages before continuing as new.")
+	flag.StringVar(&input.PageToken, "resume", "", "Token of the page to resume a batch from.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	h.SetupServiceConfig()
+
+	switch mode {
+	case "worker":
+		startWorkers(&h)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		startWorkflow(&h, input)
+	}
+}
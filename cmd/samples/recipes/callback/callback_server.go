@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+// Responses of the callback server.
+const (
+	callbackSucceed   = "SUCCEED"
+	callbackInvalidID = "ERROR:INVALID_ID"
+	callbackExpired   = "ERROR:EXPIRED"
+)
+
+type (
+	// activityClient is the part of cadence.Client that completes and heartbeats activities by their task token.
+	activityClient interface {
+		CompleteActivity(taskToken []byte, result interface{}, err error) error
+		RecordActivityHeartbeat(taskToken []byte, details ...interface{}) error
+	}
+
+	// callbackServer is the stand-in for the external system. It keeps the task tokens of the pending activities in
+	// memory, heartbeats them, and completes them when a callback arrives:
+	//	/register?id=<id>               registers the task_token form value of the request
+	//	/complete?id=<id>&result=<r>    completes the activity of the request with the result
+	//	/fail?id=<id>&reason=<r>        fails the activity of the request with the reason
+	//	/list                           lists the pending requests
+	callbackServer struct {
+		client activityClient
+
+		sync.Mutex
+		tokens map[string][]byte
+	}
+)
+
+func newCallbackServer(client activityClient) *callbackServer {
+	return &callbackServer{client: client, tokens: make(map[string][]byte)}
+}
+
+func (c *callbackServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", c.registerHandler)
+	mux.HandleFunc("/complete", func(w http.ResponseWriter, r *http.Request) {
+		c.callback(w, r.URL.Query().Get("id"), r.URL.Query().Get("result"), nil)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		c.callback(w, r.URL.Query().Get("id"), nil, cadence.NewErrorWithDetails(r.URL.Query().Get("reason")))
+	})
+	mux.HandleFunc("/list", c.listHandler)
+	return mux
+}
+
+func (c *callbackServer) registerHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	token := r.PostFormValue("task_token")
+	if id == "" || token == "" {
+		fmt.Fprint(w, callbackInvalidID)
+		return
+	}
+	c.Lock()
+	c.tokens[id] = []byte(token)
+	c.Unlock()
+	fmt.Fprint(w, callbackSucceed)
+	fmt.Printf("Registered callback for %s.\n", id)
+}
+
+// callback completes the activity of the request, a token that expired is dropped.
+func (c *callbackServer) callback(w http.ResponseWriter, id string, result interface{}, err error) {
+	c.Lock()
+	token, ok := c.tokens[id]
+	delete(c.tokens, id)
+	c.Unlock()
+	if !ok {
+		fmt.Fprint(w, callbackInvalidID)
+		return
+	}
+	completeErr := c.client.CompleteActivity(token, result, err)
+	if _, ok := completeErr.(*shared.EntityNotExistsError); ok {
+		fmt.Fprint(w, callbackExpired)
+		fmt.Printf("Callback for %s expired.\n", id)
+		return
+	}
+	if completeErr != nil {
+		// keep the token, the callback can be retried.
+		c.Lock()
+		c.tokens[id] = token
+		c.Unlock()
+		fmt.Fprintf(w, "ERROR:%v", completeErr)
+		return
+	}
+	fmt.Fprint(w, callbackSucceed)
+	fmt.Printf("Completed callback for %s, error %v.\n", id, err)
+}
+
+func (c *callbackServer) listHandler(w http.ResponseWriter, r *http.Request) {
+	for _, id := range c.pending() {
+		fmt.Fprintln(w, id)
+	}
+}
+
+func (c *callbackServer) pending() []string {
+	c.Lock()
+	defer c.Unlock()
+	var ids []string
+	for id := range c.tokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// heartbeat heartbeats the pending activities, so that the workflows know the external system is alive. The tokens
+// of the activities that timed out are dropped.
+func (c *callbackServer) heartbeat() {
+	c.Lock()
+	tokens := make(map[string][]byte, len(c.tokens))
+	for id, token := range c.tokens {
+		tokens[id] = token
+	}
+	c.Unlock()
+	for id, token := range tokens {
+		err := c.client.RecordActivityHeartbeat(token, id)
+		if _, ok := err.(*shared.EntityNotExistsError); ok {
+			c.Lock()
+			// unless the request registered again meanwhile.
+			if string(c.tokens[id]) == string(token) {
+				delete(c.tokens, id)
+			}
+			c.Unlock()
+			fmt.Printf("Callback for %s expired.\n", id)
+		}
+	}
+}
+
+// heartbeatEvery heartbeats the pending activities at every interval, forever.
+func (c *callbackServer) heartbeatEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		c.heartbeat()
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow hands a request to an external system, and completes once the external system calls back. The
+ * activity registers its task token with the external system and returns cadence.ErrActivityResultPending, the
+ * external system completes the activity with the token later, with a result or with an error.
+ *
+ * The external system is an HTTP server embedded in the worker, see callbackServer. While the activity is pending the
+ * server heartbeats it with the token, the workflow gives up on the external system once the heartbeats stop for the
+ * heartbeat timeout, and on the callback once the schedule to close timeout elapsed. A callback after that fails, the
+ * server reports the token expired.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "callbackGroup"
+
+// callbackServerHostPort is the address of the embedded callback server.
+var callbackServerHostPort = "http://localhost:8090"
+
+type (
+	// CallbackRequest is the input of the workflow.
+	CallbackRequest struct {
+		ID string
+		// Timeout is how long the external system has to call back, from when the activity is scheduled.
+		Timeout time.Duration
+		// HeartbeatTimeout is how long the workflow waits for a heartbeat of the external system before it considers
+		// the external system dead.
+		HeartbeatTimeout time.Duration
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(CallbackWorkflow)
+	cadence.RegisterActivity(requestCallbackActivity)
+}
+
+// timeoutOf returns the type of the timeout if the activity timed out. The test environment of this client doesn't
+// time out activities, the tests replace it.
+var timeoutOf = func(err error) (shared.TimeoutType, bool) {
+	timeoutErr, ok := err.(cadence.TimeoutError)
+	if !ok {
+		return 0, false
+	}
+	return timeoutErr.TimeoutType(), true
+}
+
+// CallbackWorkflow workflow decider
+func CallbackWorkflow(ctx cadence.Context, request CallbackRequest) (string, error) {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: request.Timeout,
+		StartToCloseTimeout:    request.Timeout,
+		// the token expires once the ScheduleToCloseTimeout elapsed.
+		ScheduleToCloseTimeout: request.Timeout,
+		HeartbeatTimeout:       request.HeartbeatTimeout,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx).With(zap.String("RequestID", request.ID))
+
+	var result string
+	err := cadence.ExecuteActivity(ctx, requestCallbackActivity, request.ID).Get(ctx, &result)
+	if timeoutType, ok := timeoutOf(err); ok {
+		if timeoutType == shared.TimeoutType_HEARTBEAT {
+			logger.Warn("External system stopped heartbeating.",
+				zap.Duration("HeartbeatTimeout", request.HeartbeatTimeout))
+			return "", fmt.Errorf("external system stopped heartbeating request %s", request.ID)
+		}
+		logger.Warn("Callback expired.", zap.Duration("Timeout", request.Timeout), zap.Error(err))
+		return "", fmt.Errorf("no callback for request %s within %v", request.ID, request.Timeout)
+	}
+	if err != nil {
+		logger.Info("Callback failed.", zap.Error(err))
+		return "", fmt.Errorf("callback for request %s failed: %v", request.ID, err)
+	}
+	logger.Info("Workflow completed.", zap.String("Result", result))
+	return result, nil
+}
+
+// requestCallbackActivity registers the task token with the external system, and leaves the activity pending until the
+// external system calls Client.CompleteActivity() with the token, or the activity times out.
+func requestCallbackActivity(ctx context.Context, id string) (string, error) {
+	if len(id) == 0 {
+		return "", errors.New("request id is empty")
+	}
+	logger := cadence.GetActivityLogger(ctx)
+
+	formData := url.Values{}
+	formData.Add("task_token", string(cadence.GetActivityInfo(ctx).TaskToken))
+	resp, err := http.PostForm(callbackServerHostPort+"/register?id="+url.QueryEscape(id), formData)
+	if err != nil {
+		logger.Info("requestCallbackActivity failed to register callback.", zap.Error(err))
+		return "", err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	if status := string(body); status != callbackSucceed {
+		return "", cadence.NewErrorWithDetails(fmt.Sprintf("register callback failed status:%s", status), nil)
+	}
+
+	logger.Info("Successfully registered callback.", zap.String("RequestID", id))
+	// ErrActivityResultPending is returned from activity's execution to indicate the activity is not completed when it
+	// returns. activity will be completed asynchronously when Client.CompleteActivity() is called.
+	return "", cadence.ErrActivityResultPending
+}
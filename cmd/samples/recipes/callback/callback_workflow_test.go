@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+
+	env      *cadence.TestWorkflowEnvironment
+	client   *testActivityClient
+	server   *callbackServer
+	http     *httptest.Server
+	original func(err error) (shared.TimeoutType, bool)
+}
+
+// testActivityClient completes the activities in the test environment. It remembers the activities that timed out,
+// the test environment doesn't time out activities.
+type testActivityClient struct {
+	env        *cadence.TestWorkflowEnvironment
+	closed     map[string]bool
+	heartbeats []string
+}
+
+func (c *testActivityClient) CompleteActivity(taskToken []byte, result interface{}, err error) error {
+	if c.closed[string(taskToken)] {
+		return &shared.EntityNotExistsError{}
+	}
+	c.closed[string(taskToken)] = true
+	return c.env.CompleteActivity(taskToken, result, err)
+}
+
+func (c *testActivityClient) RecordActivityHeartbeat(taskToken []byte, details ...interface{}) error {
+	if c.closed[string(taskToken)] {
+		return &shared.EntityNotExistsError{}
+	}
+	c.heartbeats = append(c.heartbeats, details[0].(string))
+	return nil
+}
+
+// timeOut times out the activity like the server does.
+func (c *testActivityClient) timeOut(taskToken []byte, timeoutType shared.TimeoutType) {
+	c.closed[string(taskToken)] = true
+	c.env.CompleteActivity(taskToken, nil, cadence.NewTimeoutError(timeoutType))
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.client = &testActivityClient{env: s.env, closed: make(map[string]bool)}
+	s.server = newCallbackServer(s.client)
+	s.http = httptest.NewServer(s.server.handler())
+	callbackServerHostPort = s.http.URL
+	// the timeout errors arrive as errors with the message of the timeout error as the reason.
+	s.original = timeoutOf
+	timeoutOf = func(err error) (shared.TimeoutType, bool) {
+		timeoutTypes := []shared.TimeoutType{shared.TimeoutType_SCHEDULE_TO_CLOSE, shared.TimeoutType_HEARTBEAT}
+		for _, timeoutType := range timeoutTypes {
+			if err != nil && err.Error() == cadence.NewTimeoutError(timeoutType).Error() {
+				return timeoutType, true
+			}
+		}
+		return 0, false
+	}
+}
+
+func (s *UnitTestSuite) TearDownTest() {
+	s.http.Close()
+	timeoutOf = s.original
+}
+
+func (s *UnitTestSuite) get(path string) string {
+	resp, err := http.Get(s.http.URL + path)
+	s.NoError(err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	s.NoError(err)
+	return string(body)
+}
+
+func (s *UnitTestSuite) token(id string) []byte {
+	s.server.Lock()
+	defer s.server.Unlock()
+	return s.server.tokens[id]
+}
+
+var request = CallbackRequest{ID: "request-1", Timeout: time.Hour, HeartbeatTimeout: time.Minute}
+
+func (s *UnitTestSuite) Test_CallbackWorkflow_Complete() {
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal([]string{"request-1"}, s.server.pending())
+		s.Equal(callbackSucceed, s.get("/complete?id=request-1&result=done"))
+	}, time.Minute*10)
+	s.env.ExecuteWorkflow(CallbackWorkflow, request)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result string
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("done", result)
+	s.Empty(s.server.pending())
+	// the request is completed, a second callback doesn't find it.
+	s.Equal(callbackInvalidID, s.get("/complete?id=request-1&result=again"))
+}
+
+func (s *UnitTestSuite) Test_CallbackWorkflow_Fail() {
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal(callbackSucceed, s.get("/fail?id=request-1&reason=rejected"))
+	}, time.Minute*10)
+	s.env.ExecuteWorkflow(CallbackWorkflow, request)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.EqualError(s.env.GetWorkflowError(), "callback for request request-1 failed: rejected")
+}
+
+func (s *UnitTestSuite) Test_CallbackWorkflow_Expired() {
+	s.env.RegisterDelayedCallback(func() {
+		s.client.timeOut(s.token("request-1"), shared.TimeoutType_SCHEDULE_TO_CLOSE)
+	}, request.Timeout)
+	s.env.ExecuteWorkflow(CallbackWorkflow, request)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.EqualError(s.env.GetWorkflowError(), "no callback for request request-1 within 1h0m0s")
+	// the callback arrives too late.
+	s.Equal(callbackExpired, s.get("/complete?id=request-1&result=done"))
+	s.Empty(s.server.pending())
+}
+
+func (s *UnitTestSuite) Test_CallbackWorkflow_HeartbeatTimeout() {
+	s.env.RegisterDelayedCallback(func() {
+		s.server.heartbeat()
+	}, time.Second*10)
+	// the external system dies, the heartbeats stop.
+	s.env.RegisterDelayedCallback(func() {
+		s.client.timeOut(s.token("request-1"), shared.TimeoutType_HEARTBEAT)
+	}, time.Second*10+request.HeartbeatTimeout)
+	s.env.ExecuteWorkflow(CallbackWorkflow, request)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.EqualError(s.env.GetWorkflowError(), "external system stopped heartbeating request request-1")
+	s.Equal([]string{"request-1"}, s.client.heartbeats)
+	// the next heartbeat finds the activity timed out, and drops it.
+	s.server.heartbeat()
+	s.Empty(s.server.pending())
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+// startCallbackServer starts the embedded external system, it heartbeats the pending activities at every
+// heartbeatInterval, or not at all if it is 0.
+func startCallbackServer(h *common.SampleHelper, address string, heartbeatInterval time.Duration) {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		h.Logger.Error("Failed to build cadence client.", zap.Error(err))
+		panic(err)
+	}
+	server := newCallbackServer(workflowClient)
+	if heartbeatInterval > 0 {
+		go server.heartbeatEvery(heartbeatInterval)
+	}
+	go func() {
+		h.Logger.Info("Callback server started.", zap.String("Address", address))
+		if err := http.ListenAndServe(address, server.handler()); err != nil {
+			h.Logger.Fatal("Callback server failed.", zap.Error(err))
+		}
+	}()
+}
+
+func startWorkflow(h *common.SampleHelper, request CallbackRequest) {
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:                              "callback_" + request.ID,
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    request.Timeout + time.Minute,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, CallbackWorkflow, request)
+}
+
+// fireCallback calls the callback server back for the request, like the external system does once the request is
+// handled. The callback fails the request if reason is set.
+func fireCallback(id, result, reason string) {
+	callbackURL := callbackServerHostPort + "/complete?id=" + url.QueryEscape(id) + "&result=" + url.QueryEscape(result)
+	if reason != "" {
+		callbackURL = callbackServerHostPort + "/fail?id=" + url.QueryEscape(id) + "&reason=" + url.QueryEscape(reason)
+	}
+	resp, err := http.Get(callbackURL)
+	if err != nil {
+		panic(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Callback for %s: %s\n", id, body)
+}
+
+func main() {
+	var mode, address, id, result, reason string
+	var timeoutInSeconds, heartbeatTimeoutInSeconds, heartbeatIntervalInSeconds uint
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker, trigger or callback.")
+	flag.StringVar(&address, "address", "localhost:8090", "Address of the callback server the worker embeds.")
+	flag.UintVar(&heartbeatIntervalInSeconds, "heartbeat", 5,
+		"Seconds between the heartbeats of the callback server, 0 simulates a dead external system.")
+	flag.UintVar(&timeoutInSeconds, "timeout", 300, "Seconds the workflow waits for the callback.")
+	flag.UintVar(&heartbeatTimeoutInSeconds, "heartbeatTimeout", 20,
+		"Seconds the workflow waits for a heartbeat of the callback server.")
+	flag.StringVar(&id, "id", "", "ID of the request, a new one by default when triggering.")
+	flag.StringVar(&result, "result", "done", "Result of the callback.")
+	flag.StringVar(&reason, "fail", "", "Reason the callback fails the request with, if set.")
+	flag.Parse()
+	callbackServerHostPort = "http://" + address
+
+	switch mode {
+	case "worker":
+		var h common.SampleHelper
+		h.SetupServiceConfig()
+		startCallbackServer(&h, address, time.Second*time.Duration(heartbeatIntervalInSeconds))
+		startWorkers(&h)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		var h common.SampleHelper
+		h.SetupServiceConfig()
+		if id == "" {
+			id = uuid.New()
+		}
+		startWorkflow(&h, CallbackRequest{
+			ID:               id,
+			Timeout:          time.Second * time.Duration(timeoutInSeconds),
+			HeartbeatTimeout: time.Second * time.Duration(heartbeatTimeoutInSeconds),
+		})
+	case "callback":
+		fireCallback(id, result, reason)
+	}
+}
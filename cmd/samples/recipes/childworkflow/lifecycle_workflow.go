@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow shows what happens to a child workflow that is still running when its parent goes away. The
+ * parent starts a long running child with the child policy of its input, waits until the child started, and exits in
+ * the way of its input while the child runs: it completes, it continues as new, or it waits for the child until it is
+ * cancelled. The child policy tells the server what to do with the open children once the parent closes: terminate
+ * them, request their cancellation so that they can clean up, or abandon them so that they run on.
+ *
+ * A run that continues as new closes too, the new run doesn't inherit the children of the old one. Only an abandoned
+ * child survives it, which is what the children of the cron workflow need when it continues as new. The new run knows
+ * the child by the execution its input carries, the child's workflow ID is derived from the parent's, which doesn't
+ * change when it continues as new, so the runner describes the child of a parent without asking the parent.
+ *
+ * Cancelling the parent cancels the context the child was started with, and this version of the client requests the
+ * cancellation of a child whose context is cancelled, whatever its policy. It has no disconnected context to start the
+ * child with instead, a child that has to survive the cancellation of its parent has to be started by another workflow.
+ */
+
+// The child policies of the LifecycleInput.
+const (
+	policyTerminate     = "terminate"
+	policyRequestCancel = "requestCancel"
+	policyAbandon       = "abandon"
+)
+
+// The ways the parent exits while the child runs.
+const (
+	exitComplete      = "complete"
+	exitCancel        = "cancel"
+	exitContinueAsNew = "continueAsNew"
+)
+
+var childPolicies = map[string]cadence.ChildWorkflowPolicy{
+	policyTerminate:     cadence.ChildWorkflowPolicyTerminate,
+	policyRequestCancel: cadence.ChildWorkflowPolicyRequestCancel,
+	policyAbandon:       cadence.ChildWorkflowPolicyAbandon,
+}
+
+type (
+	// LifecycleInput is the input of the LifecycleParentWorkflow.
+	LifecycleInput struct {
+		// Policy is the child policy, terminate, requestCancel or abandon.
+		Policy string
+		// Exit is the way the parent exits, complete, cancel or continueAsNew.
+		Exit string
+		// ChildDuration is how long the child runs.
+		ChildDuration time.Duration
+		// Child is the child of the run that continued as new, it is nil for the first run.
+		Child *cadence.WorkflowExecution
+	}
+
+	// LifecycleResult is the result of the LifecycleParentWorkflow.
+	LifecycleResult struct {
+		Child cadence.WorkflowExecution
+		// ContinuedAsNew is true if the child was started by the run that continued as new.
+		ContinuedAsNew bool
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(LifecycleParentWorkflow)
+	cadence.RegisterWorkflow(LongRunningChildWorkflow)
+}
+
+// childWorkflowID returns the workflow ID of the child of the parent with the given workflow ID.
+func childWorkflowID(parentWorkflowID string) string {
+	return parentWorkflowID + "_child"
+}
+
+func (input LifecycleInput) validate() error {
+	if _, ok := childPolicies[input.Policy]; !ok {
+		return fmt.Errorf("unknown child policy %q, it is %s, %s or %s", input.Policy, policyTerminate,
+			policyRequestCancel, policyAbandon)
+	}
+	switch input.Exit {
+	case exitComplete, exitCancel, exitContinueAsNew:
+		return nil
+	}
+	return fmt.Errorf("unknown exit %q, it is %s, %s or %s", input.Exit, exitComplete, exitCancel, exitContinueAsNew)
+}
+
+// childOptions returns the options of the child of the parent with the given workflow ID.
+func (input LifecycleInput) childOptions(parentWorkflowID string) cadence.ChildWorkflowOptions {
+	return cadence.ChildWorkflowOptions{
+		WorkflowID:                   childWorkflowID(parentWorkflowID),
+		ExecutionStartToCloseTimeout: input.ChildDuration + time.Minute,
+		ChildPolicy:                  childPolicies[input.Policy],
+	}
+}
+
+// LifecycleParentWorkflow workflow decider
+func LifecycleParentWorkflow(ctx cadence.Context, input LifecycleInput) (LifecycleResult, error) {
+	if err := input.validate(); err != nil {
+		return LifecycleResult{}, err
+	}
+	logger := cadence.GetLogger(ctx).With(zap.String("Policy", input.Policy), zap.String("Exit", input.Exit))
+	if input.Child != nil {
+		// the child of the run that continued as new is no child of this run, it runs on if it was abandoned.
+		logger.Info("Parent continued as new, completed.", zap.String("ChildWorkflowID", input.Child.ID),
+			zap.String("ChildRunID", input.Child.RunID))
+		return LifecycleResult{Child: *input.Child, ContinuedAsNew: true}, nil
+	}
+
+	parentWorkflowID := cadence.GetWorkflowInfo(ctx).WorkflowExecution.ID
+	childCtx := cadence.WithChildWorkflowOptions(ctx, input.childOptions(parentWorkflowID))
+	future := cadence.ExecuteChildWorkflow(childCtx, LongRunningChildWorkflow, input.ChildDuration)
+	// the execution is ready once the child started, the future itself once the child completed.
+	var child cadence.WorkflowExecution
+	if err := future.GetChildWorkflowExecution().Get(ctx, &child); err != nil {
+		logger.Error("Child failed to start.", zap.Error(err))
+		return LifecycleResult{}, err
+	}
+	logger = logger.With(zap.String("ChildWorkflowID", child.ID), zap.String("ChildRunID", child.RunID))
+	logger.Info("Child started.")
+
+	switch input.Exit {
+	case exitContinueAsNew:
+		logger.Info("Parent continues as new while the child runs.")
+		input.Child = &child
+		return LifecycleResult{}, cadence.NewContinueAsNewError(ctx, LifecycleParentWorkflow, input)
+	case exitCancel:
+		// wait for the child, the parent is cancelled meanwhile.
+		logger.Info("Parent waits for the child until it is cancelled.")
+		var result string
+		if err := future.Get(ctx, &result); err != nil {
+			logger.Info("Parent completed, waiting for the child failed.", zap.Error(err))
+			return LifecycleResult{Child: child}, err
+		}
+		logger.Info("Parent completed, the child completed.", zap.String("Result", result))
+		return LifecycleResult{Child: child}, nil
+	}
+	logger.Info("Parent completed while the child runs.")
+	return LifecycleResult{Child: child}, nil
+}
+
+// LongRunningChildWorkflow workflow decider
+func LongRunningChildWorkflow(ctx cadence.Context, duration time.Duration) (string, error) {
+	logger := cadence.GetLogger(ctx)
+	logger.Info("Child workflow started.", zap.Duration("Duration", duration))
+	if err := cadence.Sleep(ctx, duration); err != nil {
+		// a requested cancellation lets the child clean up, a termination doesn't.
+		logger.Info("Child workflow cancelled, cleaning up.", zap.Error(err))
+		return "", err
+	}
+	logger.Info("Child workflow completed.")
+	return fmt.Sprintf("child ran for %v", duration), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+var policies = []string{policyTerminate, policyRequestCancel, policyAbandon}
+
+// lifecycleRun is an execution of the parent with a mocked child.
+type lifecycleRun struct {
+	env            *cadence.TestWorkflowEnvironment
+	childStarted   []string
+	childCompleted bool
+	childCancelled bool
+}
+
+// runLifecycle executes the parent with a child that runs for an hour.
+func (s *UnitTestSuite) runLifecycle(input LifecycleInput, callbacks ...func(run *lifecycleRun)) *lifecycleRun {
+	run := &lifecycleRun{env: s.NewTestWorkflowEnvironment()}
+	env := run.env
+	env.OverrideWorkflow(LongRunningChildWorkflow, func(ctx cadence.Context, duration time.Duration) (string, error) {
+		return "done", cadence.Sleep(ctx, duration)
+	})
+	env.SetOnChildWorkflowStartedListener(func(info *cadence.WorkflowInfo, ctx cadence.Context,
+		args cadence.EncodedValues) {
+		run.childStarted = append(run.childStarted, info.WorkflowExecution.ID)
+	})
+	env.SetOnChildWorkflowCompletedListener(func(info *cadence.WorkflowInfo, result cadence.EncodedValue, err error) {
+		run.childCompleted = true
+	})
+	env.SetOnChildWorkflowCanceledListener(func(info *cadence.WorkflowInfo) {
+		run.childCancelled = true
+	})
+	for _, callback := range callbacks {
+		callback(run)
+	}
+	input.ChildDuration = time.Hour
+	env.ExecuteWorkflow(LifecycleParentWorkflow, input)
+
+	s.True(env.IsWorkflowCompleted())
+	return run
+}
+
+func (run *lifecycleRun) result(s *UnitTestSuite) LifecycleResult {
+	s.NoError(run.env.GetWorkflowError())
+	var result LifecycleResult
+	s.NoError(run.env.GetWorkflowResult(&result))
+	return result
+}
+
+// continueAsNewArgs returns the arguments of the run the workflow continues as new with, the ContinueAsNewError of
+// this version of the client doesn't expose them.
+func continueAsNewArgs(err error) []interface{} {
+	field := reflect.ValueOf(err).Elem().FieldByName("args")
+	return *(*[]interface{})(unsafe.Pointer(field.UnsafeAddr()))
+}
+
+func (s *UnitTestSuite) Test_ChildOptions() {
+	for policy, childPolicy := range map[string]cadence.ChildWorkflowPolicy{
+		policyTerminate:     cadence.ChildWorkflowPolicyTerminate,
+		policyRequestCancel: cadence.ChildWorkflowPolicyRequestCancel,
+		policyAbandon:       cadence.ChildWorkflowPolicyAbandon,
+	} {
+		input := LifecycleInput{Policy: policy, Exit: exitComplete, ChildDuration: time.Hour}
+		s.NoError(input.validate())
+		// the test environment doesn't apply the child policy when the parent closes, the server gets it with the
+		// options. The child of a parent is known without asking the parent.
+		s.Equal(cadence.ChildWorkflowOptions{WorkflowID: "lifecycle_4711_child",
+			ExecutionStartToCloseTimeout: time.Hour + time.Minute, ChildPolicy: childPolicy},
+			input.childOptions("lifecycle_4711"), policy)
+	}
+	s.Error(LifecycleInput{Policy: "orphan", Exit: exitComplete}.validate())
+	s.Error(LifecycleInput{Policy: policyAbandon, Exit: "crash"}.validate())
+}
+
+func (s *UnitTestSuite) Test_Complete() {
+	for _, policy := range policies {
+		run := s.runLifecycle(LifecycleInput{Policy: policy, Exit: exitComplete})
+		result := run.result(s)
+
+		// the parent waited for the child to start, not to complete.
+		s.Equal([]string{result.Child.ID}, run.childStarted, policy)
+		s.NotEmpty(result.Child.RunID, policy)
+		s.False(result.ContinuedAsNew, policy)
+		s.False(run.childCompleted, policy)
+	}
+}
+
+func (s *UnitTestSuite) Test_ContinueAsNew() {
+	for _, policy := range policies {
+		run := s.runLifecycle(LifecycleInput{Policy: policy, Exit: exitContinueAsNew})
+
+		err := run.env.GetWorkflowError()
+		_, continuedAsNew := err.(cadence.ContinueAsNewError)
+		s.True(continuedAsNew, policy)
+		s.Len(run.childStarted, 1, policy)
+		s.False(run.childCompleted, policy)
+		// the new run gets the child of the old one, and doesn't start another one.
+		input := continueAsNewArgs(err)[0].(LifecycleInput)
+		s.NotNil(input.Child, policy)
+		s.Equal(run.childStarted[0], input.Child.ID, policy)
+
+		continued := s.runLifecycle(input)
+		s.Equal(LifecycleResult{Child: *input.Child, ContinuedAsNew: true}, continued.result(s), policy)
+		s.Empty(continued.childStarted, policy)
+	}
+}
+
+func (s *UnitTestSuite) Test_Cancel() {
+	for _, policy := range policies {
+		run := s.runLifecycle(LifecycleInput{Policy: policy, Exit: exitCancel}, func(run *lifecycleRun) {
+			run.env.RegisterDelayedCallback(run.env.CancelWorkflow, time.Minute)
+		})
+
+		// cancelling the parent cancels the context of the child, the child is cancelled whatever the policy.
+		_, cancelled := run.env.GetWorkflowError().(cadence.CanceledError)
+		s.True(cancelled, policy)
+		s.Len(run.childStarted, 1, policy)
+		s.True(run.childCancelled, policy)
+	}
+}
+
+func (s *UnitTestSuite) Test_Cancel_ChildCompletesFirst() {
+	run := s.runLifecycle(LifecycleInput{Policy: policyAbandon, Exit: exitCancel})
+
+	result := run.result(s)
+	s.Equal([]string{result.Child.ID}, run.childStarted)
+	s.True(run.childCompleted)
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/pborman/uuid"
 	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
 )
 
 // This needs to be done as part of a bootstrap step when the process starts.
@@ -31,9 +33,75 @@ func startWorkflow(h *common.SampleHelper) {
 	h.StartWorkflow(workflowOptions, SampleParentWorkflow)
 }
 
+func startLifecycleWorkflow(h *common.SampleHelper, input LifecycleInput) {
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:                              "lifecycle_" + uuid.New(),
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    input.ChildDuration + time.Minute*5,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, LifecycleParentWorkflow, input)
+}
+
+func buildClient(h *common.SampleHelper) cadence.Client {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		h.Logger.Error("Failed to build cadence client.", zap.Error(err))
+		panic(err)
+	}
+	return workflowClient
+}
+
+// cancelParent requests the cancellation of the parent workflow.
+func cancelParent(h *common.SampleHelper, workflowID string) {
+	if err := buildClient(h).CancelWorkflow(workflowID, ""); err != nil {
+		h.Logger.Error("Failed to cancel workflow.", zap.String("WorkflowID", workflowID), zap.Error(err))
+		panic("Failed to cancel workflow.")
+	}
+	h.Logger.Info("Cancellation requested.", zap.String("WorkflowID", workflowID))
+}
+
+// describeChild logs the fate of the child of the parent workflow, read from the history of the child: whether it is
+// running or how it closed, and whether its cancellation was requested.
+func describeChild(h *common.SampleHelper, parentWorkflowID string) {
+	workflowID := childWorkflowID(parentWorkflowID)
+	history, err := buildClient(h).GetWorkflowHistory(workflowID, "")
+	if err != nil {
+		h.Logger.Error("Failed to get the history of the child.", zap.String("WorkflowID", workflowID), zap.Error(err))
+		panic("Failed to describe child.")
+	}
+	status, cancelRequested := "RUNNING", false
+	for _, event := range history.Events {
+		switch event.GetEventType() {
+		case s.EventType_WorkflowExecutionCancelRequested:
+			cancelRequested = true
+		case s.EventType_WorkflowExecutionCompleted:
+			status = s.WorkflowExecutionCloseStatus_COMPLETED.String()
+		case s.EventType_WorkflowExecutionFailed:
+			status = s.WorkflowExecutionCloseStatus_FAILED.String()
+		case s.EventType_WorkflowExecutionCanceled:
+			status = s.WorkflowExecutionCloseStatus_CANCELED.String()
+		case s.EventType_WorkflowExecutionTerminated:
+			status = s.WorkflowExecutionCloseStatus_TERMINATED.String()
+		case s.EventType_WorkflowExecutionTimedOut:
+			status = s.WorkflowExecutionCloseStatus_TIMED_OUT.String()
+		}
+	}
+	h.Logger.Info("Child described.", zap.String("WorkflowID", workflowID), zap.String("Status", status),
+		zap.Bool("CancelRequested", cancelRequested))
+}
+
 func main() {
-	var mode string
-	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	var mode, workflowID string
+	var childDurationInSeconds uint
+	var input LifecycleInput
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker, trigger, lifecycle, cancel or describe.")
+	flag.StringVar(&input.Policy, "policy", policyAbandon, "Child policy of the lifecycle workflow, terminate, "+
+		"requestCancel or abandon.")
+	flag.StringVar(&input.Exit, "exit", exitComplete, "How the lifecycle workflow exits while the child runs, "+
+		"complete, cancel or continueAsNew.")
+	flag.UintVar(&childDurationInSeconds, "childDuration", 300, "Seconds the child of the lifecycle workflow runs.")
+	flag.StringVar(&workflowID, "w", "", "WorkflowID of the lifecycle workflow to cancel, or to describe the child of.")
 	flag.Parse()
 
 	var h common.SampleHelper
@@ -48,5 +116,12 @@ func main() {
 		select {}
 	case "trigger":
 		startWorkflow(&h)
+	case "lifecycle":
+		input.ChildDuration = time.Second * time.Duration(childDurationInSeconds)
+		startLifecycleWorkflow(&h, input)
+	case "cancel":
+		cancelParent(&h, workflowID)
+	case "describe":
+		describeChild(&h, workflowID)
 	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow runs an action after a delay, unless someone cancels it first, e.g. deleting an account 24h
+ * after it was closed. It starts a durable timer in a context of its own and waits with a selector for the timer and
+ * for two signals: cancelDelay cancels the timer and completes the workflow without running the action, reschedule
+ * cancels the timer and starts a new one with the delay of the signal, counted from when the signal arrived. The
+ * action runs when the timer fires.
+ *
+ * The timer is a timer of the server, the delay holds while no worker runs. Once the timer fired the action runs, the
+ * signals that arrive meanwhile are too late and are ignored. Once the workflow is closed the server rejects the
+ * signals, the starter reports that the action was decided already.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "delayGroup"
+
+// The names of the signals.
+const (
+	// cancelDelaySignalName cancels the action, its payload is the reason.
+	cancelDelaySignalName = "cancelDelay"
+	// rescheduleSignalName replaces the delay, its payload is the new delay.
+	rescheduleSignalName = "reschedule"
+)
+
+// Statuses of the DelayResult.
+const (
+	statusExecuted  = "executed"
+	statusCancelled = "cancelled"
+)
+
+type (
+	// DelayRequest is the input of the workflow.
+	DelayRequest struct {
+		ID string
+		// Delay is how long the workflow waits before it runs the action.
+		Delay time.Duration
+	}
+
+	// DelayResult is the result of the workflow.
+	DelayResult struct {
+		// Status is executed if the action ran, or cancelled.
+		Status string
+		// CancelReason is the reason of the cancelDelay signal.
+		CancelReason string
+		// Reschedules counts the reschedule signals.
+		Reschedules int
+		// IgnoredSignals counts the signals that arrived after the timer fired.
+		IgnoredSignals int
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(DelayWorkflow)
+	cadence.RegisterActivity(delayedActivity)
+}
+
+// DelayWorkflow workflow decider
+func DelayWorkflow(ctx cadence.Context, request DelayRequest) (DelayResult, error) {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx).With(zap.String("RequestID", request.ID))
+	cancelDelay := cadence.GetSignalChannel(ctx, cancelDelaySignalName)
+	reschedule := cadence.GetSignalChannel(ctx, rescheduleSignalName)
+
+	var result DelayResult
+	delay := request.Delay
+	for result.Status == "" {
+		// the timer has a context of its own, cancelling it doesn't cancel the workflow.
+		timerCtx, cancelTimer := cadence.WithCancel(ctx)
+		logger.Info("Waiting for the delay.", zap.Duration("Delay", delay))
+		// a selector for every timer, the timers that were cancelled are not selected again.
+		selector := cadence.NewSelector(ctx)
+		selector.AddFuture(cadence.NewTimer(timerCtx, delay), func(f cadence.Future) {
+			result.Status = statusExecuted
+		})
+		selector.AddReceive(cancelDelay, func(c cadence.Channel, more bool) {
+			c.Receive(ctx, &result.CancelReason)
+			result.Status = statusCancelled
+		})
+		selector.AddReceive(reschedule, func(c cadence.Channel, more bool) {
+			c.Receive(ctx, &delay)
+			result.Reschedules++
+		})
+		selector.Select(ctx)
+		if result.Status != statusExecuted {
+			cancelTimer()
+		}
+	}
+
+	if result.Status == statusCancelled {
+		logger.Info("Workflow completed, the action was cancelled.", zap.String("Reason", result.CancelReason))
+		return result, nil
+	}
+	if err := cadence.ExecuteActivity(ctx, delayedActivity, request).Get(ctx, nil); err != nil {
+		return result, err
+	}
+	// the signals that arrived once the timer fired are too late.
+	result.IgnoredSignals = drain(cancelDelay, func() {
+		logger.Info("Cancellation arrived after the delay, ignored.")
+	}) + drain(reschedule, func() {
+		logger.Info("Reschedule arrived after the delay, ignored.")
+	})
+	logger.Info("Workflow completed, the action ran.", zap.Int("Reschedules", result.Reschedules),
+		zap.Int("IgnoredSignals", result.IgnoredSignals))
+	return result, nil
+}
+
+// drain receives the signals pending on the channel, and returns how many there were.
+func drain(signals cadence.Channel, handle func()) int {
+	count := 0
+	for signals.ReceiveAsync(nil) {
+		handle()
+		count++
+	}
+	return count
+}
+
+func delayedActivity(ctx context.Context, request DelayRequest) error {
+	// ...
+	cadence.GetActivityLogger(ctx).Info("Delayed action ran.", zap.String("RequestID", request.ID))
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+
+	env *cadence.TestWorkflowEnvironment
+	// ranAfter is how long after the start the action ran, zero if it didn't.
+	ranAfter time.Duration
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.ranAfter = 0
+	started := s.env.Now()
+	s.env.OverrideActivity(delayedActivity, func(ctx context.Context, request DelayRequest) error {
+		s.ranAfter = s.env.Now().Sub(started)
+		return nil
+	})
+}
+
+func (s *UnitTestSuite) run() DelayResult {
+	s.env.ExecuteWorkflow(DelayWorkflow, DelayRequest{ID: "account-4711", Delay: time.Hour * 24})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result DelayResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+func (s *UnitTestSuite) Test_Fire() {
+	result := s.run()
+
+	s.Equal(DelayResult{Status: statusExecuted}, result)
+	s.Equal(time.Hour*24, s.ranAfter)
+}
+
+func (s *UnitTestSuite) Test_Cancel() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(cancelDelaySignalName, "account reopened")
+	}, time.Hour)
+	result := s.run()
+
+	s.Equal(DelayResult{Status: statusCancelled, CancelReason: "account reopened"}, result)
+	s.Zero(s.ranAfter)
+}
+
+func (s *UnitTestSuite) Test_Reschedule() {
+	// the action runs 2h after the first reschedule, then 30m after the second.
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(rescheduleSignalName, time.Hour*2)
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(rescheduleSignalName, time.Minute*30)
+	}, time.Hour*2)
+	result := s.run()
+
+	s.Equal(DelayResult{Status: statusExecuted, Reschedules: 2}, result)
+	s.Equal(time.Hour*2+time.Minute*30, s.ranAfter)
+}
+
+func (s *UnitTestSuite) Test_RescheduleThenCancel() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(rescheduleSignalName, time.Hour*48)
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(cancelDelaySignalName, "account reopened")
+	}, time.Hour*30)
+	result := s.run()
+
+	s.Equal(DelayResult{Status: statusCancelled, CancelReason: "account reopened", Reschedules: 1}, result)
+	s.Zero(s.ranAfter)
+}
+
+func (s *UnitTestSuite) Test_SignalsAfterFireIgnored() {
+	// the action takes a minute, the signals arrive while it runs.
+	s.env.OverrideActivity(delayedActivity, func(ctx context.Context, request DelayRequest) error {
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		s.env.RegisterDelayedCallback(func() {
+			s.env.CompleteActivity(taskToken, nil, nil)
+		}, time.Minute)
+		return cadence.ErrActivityResultPending
+	})
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(cancelDelaySignalName, "too late")
+		s.env.SignalWorkflow(rescheduleSignalName, time.Hour)
+	}, time.Hour*24+time.Second*30)
+	result := s.run()
+
+	s.Equal(DelayResult{Status: statusExecuted, IgnoredSignals: 2}, result)
+}
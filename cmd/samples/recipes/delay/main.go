@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/zap"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+func startWorkflow(h *common.SampleHelper, delay, maxDelay time.Duration) {
+	request := DelayRequest{ID: uuid.New(), Delay: delay}
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:       "delay_" + request.ID,
+		TaskList: ApplicationName,
+		// the workflow has to outlive the delays it is rescheduled with.
+		ExecutionStartToCloseTimeout:    maxDelay + time.Minute*5,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, DelayWorkflow, request)
+}
+
+// signal sends the signal to the workflow. A workflow that is closed already, e.g. because the action ran, rejects the
+// signal.
+func signal(h *common.SampleHelper, workflowID, signalName string, arg interface{}) {
+	workflowClient, err := h.Builder.BuildCadenceClient()
+	if err != nil {
+		h.Logger.Error("Failed to build cadence client.", zap.Error(err))
+		panic(err)
+	}
+	err = workflowClient.SignalWorkflow(workflowID, "", signalName, arg)
+	if _, ok := err.(*s.EntityNotExistsError); ok {
+		h.Logger.Info("Signal ignored, the action was decided already.", zap.String("WorkflowID", workflowID),
+			zap.String("Signal", signalName))
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to signal workflow", zap.Error(err))
+		panic("Failed to signal workflow.")
+	}
+	h.Logger.Info("Signal sent.", zap.String("WorkflowID", workflowID), zap.String("Signal", signalName))
+}
+
+func main() {
+	var mode, workflowID, reason string
+	var delayInSeconds, maxDelayInSeconds uint
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker, trigger, cancel or reschedule.")
+	flag.UintVar(&delayInSeconds, "delay", 60, "Seconds until the action runs, from now when rescheduling.")
+	flag.UintVar(&maxDelayInSeconds, "maxDelay", 3600, "Seconds the workflow may be rescheduled to at most.")
+	flag.StringVar(&workflowID, "w", "", "WorkflowID of the workflow to cancel or reschedule.")
+	flag.StringVar(&reason, "reason", "cancelled by the user", "Reason of the cancellation.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	h.SetupServiceConfig()
+
+	delay := time.Second * time.Duration(delayInSeconds)
+	switch mode {
+	case "worker":
+		startWorkers(&h)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		startWorkflow(&h, delay, time.Second*time.Duration(maxDelayInSeconds))
+	case "cancel":
+		signal(&h, workflowID, cancelDelaySignalName, reason)
+	case "reschedule":
+		signal(&h, workflowID, rescheduleSignalName, delay)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow shows how a workflow tells apart the ways an activity fails. The activity fails in the way its
+ * input selects: with a custom error that carries structured details, with a generic error, with a panic, by no longer
+ * heartbeating until it times out, or by being cancelled by the workflow. The workflow classifies the error with
+ * common.ClassifyError, and returns what happened instead of failing.
+ *
+ * A custom error is a failure that the activity reports on purpose, e.g. a validation that failed, retrying the activity
+ * doesn't fix it. A timeout, a generic error or a panic may go away with a retry. The cron sample decides which runs to
+ * retry the same way.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "errorhandlingGroup"
+
+// The ways failingActivity fails.
+const (
+	modeSucceed          = "none"
+	modeCustom           = "custom"
+	modeGeneric          = "generic"
+	modePanic            = "panic"
+	modeHeartbeatTimeout = "heartbeatTimeout"
+	modeCancel           = "cancel"
+)
+
+// errReasonValidation is the reason of the custom error of failingActivity, its details are the ValidationDetails.
+const errReasonValidation = "validationFailed"
+
+// the activity has to heartbeat every 10 seconds, the workflow cancels it after 5 seconds.
+const heartbeatTimeout = time.Second * 10
+
+var (
+	heartbeatInterval = time.Second
+	cancelAfter       = time.Second * 5
+)
+
+// classifyError is common.ClassifyError. The test environment of this client doesn't time out activities, the tests
+// replace it.
+var classifyError = common.ClassifyError
+
+type (
+	// ValidationDetails are the details of the custom error.
+	ValidationDetails struct {
+		Field   string
+		Problem string
+	}
+
+	// Outcome describes how the activity completed.
+	Outcome struct {
+		Mode string
+		// Kind is the kind of the failure, it is empty if the activity succeeded.
+		Kind   common.FailureKind
+		Result string
+		// Reason is the reason of a custom or generic error, and the panic value of a panic.
+		Reason string
+		// TimeoutType is the type of the timeout of a timeout.
+		TimeoutType string
+		Retryable   bool
+		// Validation is the details of a custom error.
+		Validation *ValidationDetails
+		// Progress is the progress of the last heartbeat of the activity before it timed out.
+		Progress int
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(ErrorHandlingWorkflow)
+	cadence.RegisterActivity(failingActivity)
+}
+
+// ErrorHandlingWorkflow workflow decider
+func ErrorHandlingWorkflow(ctx cadence.Context, mode string) (Outcome, error) {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       heartbeatTimeout,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx).With(zap.String("Mode", mode))
+
+	activityCtx, cancel := cadence.WithCancel(ctx)
+	future := cadence.ExecuteActivity(activityCtx, failingActivity, mode)
+	if mode == modeCancel {
+		if err := cadence.Sleep(ctx, cancelAfter); err != nil {
+			return Outcome{}, err
+		}
+		cancel()
+	}
+	var result string
+	err := future.Get(ctx, &result)
+	if err == nil {
+		logger.Info("Workflow completed.", zap.String("Result", result))
+		return Outcome{Mode: mode, Result: result}, nil
+	}
+
+	failure := classifyError(err, errReasonValidation)
+	outcome := Outcome{Mode: mode, Kind: failure.Kind, Reason: failure.Reason, Retryable: failure.Retryable()}
+	switch failure.Kind {
+	case common.FailureCustom:
+		var details ValidationDetails
+		if err := failure.Details(&details); err != nil {
+			return Outcome{}, err
+		}
+		outcome.Validation = &details
+	case common.FailureTimeout:
+		outcome.TimeoutType = failure.TimeoutType.String()
+		if failure.HasDetails() {
+			if err := failure.Details(&outcome.Progress); err != nil {
+				return Outcome{}, err
+			}
+		}
+	case common.FailurePanic:
+		// the stack trace is the one of the activity worker.
+		logger.Error("Activity panicked.", zap.String("PanicError", failure.Reason),
+			zap.String("PanicStack", failure.StackTrace))
+	}
+	logger.Info("Workflow completed, activity failed.", zap.String("Kind", string(outcome.Kind)),
+		zap.String("Reason", outcome.Reason), zap.Bool("Retryable", outcome.Retryable))
+	return outcome, nil
+}
+
+// failingActivity fails in the way of the mode.
+func failingActivity(ctx context.Context, mode string) (string, error) {
+	logger := cadence.GetActivityLogger(ctx).With(zap.String("Mode", mode))
+	switch mode {
+	case modeSucceed:
+		return "done", nil
+	case modeCustom:
+		logger.Info("Activity failed validation.")
+		return "", cadence.NewErrorWithDetails(errReasonValidation,
+			ValidationDetails{Field: "email", Problem: "address has no @"})
+	case modeGeneric:
+		return "", errors.New("connection refused")
+	case modePanic:
+		panic("inventory is corrupted")
+	case modeHeartbeatTimeout:
+		// report some progress, then get stuck. The server times the activity out once its heartbeat timeout elapsed,
+		// the activity learns about it at its deadline.
+		if err := work(ctx, 3); err != nil {
+			return "", err
+		}
+		logger.Info("Activity stopped heartbeating.")
+		<-ctx.Done()
+		return "", ctx.Err()
+	case modeCancel:
+		// work until the workflow cancels the activity, a heartbeat learns about the cancellation.
+		err := work(ctx, -1)
+		logger.Info("Activity cancelled.")
+		return "", err
+	}
+	return "", fmt.Errorf("unknown mode %s", mode)
+}
+
+// work heartbeats the progress of the given number of steps, forever if it is negative, until it is cancelled.
+func work(ctx context.Context, steps int) error {
+	for progress := 1; steps < 0 || progress <= steps; progress++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(heartbeatInterval):
+		}
+		cadence.RecordActivityHeartbeat(ctx, progress)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+
+	env *cadence.TestWorkflowEnvironment
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	// the activities heartbeat right away, the workflow cancels its activity once it heartbeated a few times.
+	heartbeatInterval = time.Millisecond
+	cancelAfter = time.Millisecond * 20
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *UnitTestSuite) run(mode string) Outcome {
+	s.env.ExecuteWorkflow(ErrorHandlingWorkflow, mode)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var outcome Outcome
+	s.NoError(s.env.GetWorkflowResult(&outcome))
+	s.Equal(mode, outcome.Mode)
+	return outcome
+}
+
+func (s *UnitTestSuite) Test_Succeed() {
+	outcome := s.run(modeSucceed)
+	s.Equal(Outcome{Mode: modeSucceed, Result: "done"}, outcome)
+}
+
+func (s *UnitTestSuite) Test_CustomError() {
+	outcome := s.run(modeCustom)
+	s.Equal(common.FailureCustom, outcome.Kind)
+	s.Equal(errReasonValidation, outcome.Reason)
+	s.False(outcome.Retryable)
+	// the details round-trip.
+	s.Equal(&ValidationDetails{Field: "email", Problem: "address has no @"}, outcome.Validation)
+}
+
+func (s *UnitTestSuite) Test_GenericError() {
+	outcome := s.run(modeGeneric)
+	s.Equal(Outcome{Mode: modeGeneric, Kind: common.FailureGeneric, Reason: "connection refused", Retryable: true},
+		outcome)
+}
+
+func (s *UnitTestSuite) Test_Panic() {
+	outcome := s.run(modePanic)
+	s.Equal(Outcome{Mode: modePanic, Kind: common.FailurePanic, Reason: "inventory is corrupted", Retryable: true},
+		outcome)
+}
+
+func (s *UnitTestSuite) Test_HeartbeatTimeout() {
+	// the server times the activity out with the details of its last heartbeat, the test environment doesn't. The fake
+	// fails the activity with them, with the message of the timeout error as the reason.
+	heartbeatTimeoutReason := cadence.NewHeartbeatTimeoutError().Error()
+	s.env.OverrideActivity(failingActivity, func(ctx context.Context, mode string) (string, error) {
+		if err := work(ctx, 3); err != nil {
+			return "", err
+		}
+		return "", cadence.NewErrorWithDetails(heartbeatTimeoutReason, 3)
+	})
+	original := classifyError
+	defer func() { classifyError = original }()
+	classifyError = func(err error, customReasons ...string) common.Failure {
+		if withDetails, ok := err.(cadence.ErrorWithDetails); ok && withDetails.Reason() == heartbeatTimeoutReason {
+			var details []byte
+			withDetails.Details(&details)
+			err = cadence.NewHeartbeatTimeoutError(details)
+		}
+		return original(err, customReasons...)
+	}
+
+	outcome := s.run(modeHeartbeatTimeout)
+	s.Equal(Outcome{Mode: modeHeartbeatTimeout, Kind: common.FailureTimeout, Reason: heartbeatTimeoutReason,
+		TimeoutType: "HEARTBEAT", Retryable: true, Progress: 3}, outcome)
+}
+
+func (s *UnitTestSuite) Test_Cancel() {
+	cancelled := false
+	s.env.SetOnActivityCanceledListener(func(info *cadence.ActivityInfo) {
+		cancelled = true
+	})
+
+	outcome := s.run(modeCancel)
+	s.True(cancelled)
+	s.Equal(Outcome{Mode: modeCancel, Kind: common.FailureCanceled, Reason: "CanceledError"}, outcome)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/samarabbas/cadence-samples/cmd/samples/common"
@@ -12,7 +14,7 @@ import (
 
 // This needs to be done as part of a bootstrap step when the process starts.
 // The workers are supposed to be long running.
-func startWorkers(h *common.SampleHelper) {
+func startWorkers(h *common.SampleHelper, taskLists []string) {
 	// Configure worker options.
 	workerOptions := cadence.WorkerOptions{
 		MetricsScope: h.Scope,
@@ -29,21 +31,33 @@ func startWorkers(h *common.SampleHelper) {
 	if err != nil {
 		panic("Failed to start workers")
 	}
+
+	// The branches that run on their own task lists are picked up by activity workers of these task lists.
+	for _, taskList := range taskLists {
+		activityWorkerOptions := workerOptions
+		activityWorkerOptions.DisableWorkflowWorker = true
+		h.StartWorkers(h.Config.DomainName, taskList, activityWorkerOptions)
+	}
 }
 
-func startWorkflow(h *common.SampleHelper) {
+func startWorkflow(h *common.SampleHelper, input PickFirstInput) {
 	workflowOptions := cadence.StartWorkflowOptions{
 		ID:                              "pickfirst_" + uuid.New(),
 		TaskList:                        ApplicationName,
 		ExecutionStartToCloseTimeout:    time.Minute,
 		DecisionTaskStartToCloseTimeout: time.Minute,
 	}
-	h.StartWorkflow(workflowOptions, SamplePickFirstWorkflow)
+	h.StartWorkflow(workflowOptions, SamplePickFirstWorkflow, input)
 }
 
 func main() {
-	var mode string
+	var mode, failingBranches, taskLists string
+	var branches, latencyInSeconds int
 	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.IntVar(&branches, "branches", 3, "Number of branches that race.")
+	flag.IntVar(&latencyInSeconds, "latency", 2, "Seconds the first branch takes, every other branch takes as long more.")
+	flag.StringVar(&failingBranches, "fail", "", "Comma separated branches that fail, e.g. 0,1.")
+	flag.StringVar(&taskLists, "taskLists", "", "Comma separated task lists the branches run on, round robin.")
 	flag.Parse()
 
 	var h common.SampleHelper
@@ -51,12 +65,31 @@ func main() {
 
 	switch mode {
 	case "worker":
-		startWorkers(&h)
+		startWorkers(&h, splitList(taskLists))
 
 		// The workers are supposed to be long running process that should not exit.
 		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
 		select {}
 	case "trigger":
-		startWorkflow(&h)
+		input := PickFirstInput{
+			Branches:  branches,
+			Latency:   time.Second * time.Duration(latencyInSeconds),
+			TaskLists: splitList(taskLists),
+		}
+		for _, branch := range splitList(failingBranches) {
+			b, err := strconv.Atoi(branch)
+			if err != nil {
+				panic("Invalid failing branch " + branch)
+			}
+			input.FailingBranches = append(input.FailingBranches, b)
+		}
+		startWorkflow(&h, input)
+	}
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
 	}
+	return strings.Split(list, ",")
 }
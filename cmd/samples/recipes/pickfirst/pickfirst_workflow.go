@@ -2,20 +2,54 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/cadence"
+	"go.uber.org/zap"
 )
 
 /**
  * This sample workflow execute activities in parallel branches, pick the result of the branch that completes first,
  * and then cancels other activities that are not finished yet.
+ *
+ * A branch that fails doesn't decide the race, the workflow keeps waiting for the other branches, and fails with the
+ * errors of all of them only if none succeeds. The branches can run on different task lists, e.g. one per region, and
+ * take longer the higher their index, so that the race is the same every time.
  */
 
 // ApplicationName is the task list for this sample
 const ApplicationName = "pickfirstGroup"
 
+// errReasonBranchFailed is the reason of the error of a failing branch.
+const errReasonBranchFailed = "branchFailed"
+
+// heartbeatInterval is how often a branch heartbeats, which is also how soon it notices its cancellation.
+var heartbeatInterval = time.Second
+
+type (
+	// PickFirstInput is the input of the workflow.
+	PickFirstInput struct {
+		// Branches is the number of branches that race.
+		Branches int
+		// Latency is the simulated latency of the first branch, every branch takes Latency longer than the one before.
+		Latency time.Duration
+		// FailingBranches are the branches that fail once their latency passed.
+		FailingBranches []int
+		// TaskLists are the task lists of the branches, round robin. Empty means the task list of the workflow.
+		TaskLists []string
+	}
+
+	// BranchInput is the input of a branch.
+	BranchInput struct {
+		Branch   int
+		Duration time.Duration
+		Fail     bool
+	}
+)
+
 // This is registration process where you register all your workflows and activities
 func init() {
 	cadence.RegisterWorkflow(SamplePickFirstWorkflow)
@@ -23,38 +57,58 @@ func init() {
 }
 
 // SamplePickFirstWorkflow workflow decider
-func SamplePickFirstWorkflow(ctx cadence.Context) error {
+func SamplePickFirstWorkflow(ctx cadence.Context, input PickFirstInput) (string, error) {
+	if input.Branches < 1 {
+		return "", errors.New("at least one branch is needed")
+	}
+	logger := cadence.GetLogger(ctx)
 	selector := cadence.NewSelector(ctx)
 	var firstResponse string
+	winner := -1
+	var errs []string
 
-	// Use one cancel handler to cancel all of them. Cancelling on parent handler will close all the child ones
-	// as well.
-	childCtx, cancelHandler := cadence.WithCancel(ctx)
 	ao := cadence.ActivityOptions{
 		ScheduleToStartTimeout: time.Minute,
 		StartToCloseTimeout:    time.Minute,
 		HeartbeatTimeout:       time.Second * 20,
 		WaitForCancellation:    true, // Wait for cancellation to complete.
 	}
-	childCtx = cadence.WithActivityOptions(ctx, ao)
+	// Use one cancel handler to cancel all of them. Cancelling on parent handler will close all the child ones
+	// as well.
+	childCtx, cancelHandler := cadence.WithCancel(cadence.WithActivityOptions(ctx, ao))
 
 	// Set WaitForCancellation to true to demonstrate the cancellation to the other activities. In real world case,
 	// you might not care about them and could set WaitForCancellation to false (which is default value).
 
-	// starts 2 activities in parallel
-	f1 := cadence.ExecuteActivity(childCtx, sampleActivity, 0, time.Second*2)
-	f2 := cadence.ExecuteActivity(childCtx, sampleActivity, 1, time.Second*10)
-	pendingFutures := []cadence.Future{f1, f2}
-	selector.AddFuture(f1, func(f cadence.Future) {
-		f.Get(ctx, &firstResponse)
-	}).AddFuture(f2, func(f cadence.Future) {
-		f.Get(ctx, &firstResponse)
-	})
+	// starts the branches in parallel
+	pendingFutures := make([]cadence.Future, input.Branches)
+	for branch := 0; branch < input.Branches; branch++ {
+		branch := branch
+		branchCtx := childCtx
+		if len(input.TaskLists) > 0 {
+			branchCtx = cadence.WithTaskList(childCtx, input.TaskLists[branch%len(input.TaskLists)])
+		}
+		branchInput := BranchInput{Branch: branch, Duration: input.Latency * time.Duration(branch+1),
+			Fail: containsBranch(input.FailingBranches, branch)}
+		f := cadence.ExecuteActivity(branchCtx, sampleActivity, branchInput)
+		pendingFutures[branch] = f
+		selector.AddFuture(f, func(f cadence.Future) {
+			var response string
+			if err := f.Get(ctx, &response); err != nil {
+				logger.Info("Branch failed, waiting for the others.", zap.Int("Branch", branch), zap.Error(err))
+				errs = append(errs, fmt.Sprintf("branch %d: %v", branch, err))
+				return
+			}
+			winner, firstResponse = branch, response
+		})
+	}
 
-	// wait for any of the future to complete
-	selector.Select(ctx)
+	// wait for the first branch that succeeds, or for all of them to fail
+	for completed := 0; completed < input.Branches && winner < 0; completed++ {
+		selector.Select(ctx)
+	}
 
-	// now at least one future is complete, so cancel all other pending futures.
+	// now a branch succeeded or all of them failed, so cancel all other pending futures.
 	cancelHandler()
 
 	// - If you want to wait for pending activities to finish after issuing cancellation
@@ -64,35 +118,50 @@ func SamplePickFirstWorkflow(ctx cadence.Context) error {
 	for _, f := range pendingFutures {
 		f.Get(ctx, nil)
 	}
-	cadence.GetLogger(ctx).Info("Workflow completed.")
-	return nil
+	if winner < 0 {
+		return "", fmt.Errorf("all %d branches failed: %s", input.Branches, strings.Join(errs, "; "))
+	}
+	logger.Info("Workflow completed.", zap.Int("Winner", winner), zap.String("Response", firstResponse))
+	return firstResponse, nil
 }
 
-func sampleActivity(ctx context.Context, currentBranchID int, totalDuration time.Duration) (string, error) {
-
-	logger := cadence.GetActivityLogger(ctx)
-	elapsedDuration := time.Nanosecond
-	for elapsedDuration < totalDuration {
-		time.Sleep(time.Second)
-		elapsedDuration += time.Second
-
-		// record heartbeat every second to check if we are been cancelled
-		cadence.RecordActivityHeartbeat(ctx, "status-report-to-workflow")
+func containsBranch(branches []int, branch int) bool {
+	for _, b := range branches {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
 
+func sampleActivity(ctx context.Context, input BranchInput) (string, error) {
+	logger := cadence.GetActivityLogger(ctx).With(zap.Int("Branch", input.Branch))
+	elapsedDuration := time.Duration(0)
+	for elapsedDuration < input.Duration {
+		step := heartbeatInterval
+		if remaining := input.Duration - elapsedDuration; remaining < step {
+			step = remaining
+		}
 		select {
 		case <-ctx.Done():
-			// We have been cancelled.
-			msg := fmt.Sprintf("Branch %d is cancelled.", currentBranchID)
+			// We have been cancelled, the heartbeat learned that the workflow cancelled the branch.
+			msg := fmt.Sprintf("Branch %d is cancelled.", input.Branch)
 			logger.Info(msg)
 			return msg, ctx.Err()
-		default:
-			// We are not cancelled yet.
+		case <-time.After(step):
+			// Do some custom work
+			// ...
 		}
+		elapsedDuration += step
 
-		// Do some custom work
-		// ...
+		// record heartbeat every interval to check if we are been cancelled
+		cadence.RecordActivityHeartbeat(ctx, "status-report-to-workflow")
 	}
 
-	msg := fmt.Sprintf("Branch %d done in %s.", currentBranchID, totalDuration)
+	if input.Fail {
+		logger.Info("Branch failed.")
+		return "", cadence.NewErrorWithDetails(errReasonBranchFailed, input.Branch)
+	}
+	msg := fmt.Sprintf("Branch %d done in %s.", input.Branch, input.Duration)
 	return msg, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"sort"
 	"testing"
 	"time"
 
@@ -15,20 +16,82 @@ type UnitTestSuite struct {
 }
 
 func TestUnitTestSuite(t *testing.T) {
+	// the branches notice their cancellation right away.
+	heartbeatInterval = time.Millisecond
 	suite.Run(t, new(UnitTestSuite))
 }
 
-func (s *UnitTestSuite) Test_Workflow() {
+// runRace executes the workflow with the real branches, and returns the branches that the workflow cancelled and the
+// branches that stopped because of their cancellation.
+func (s *UnitTestSuite) runRace(input PickFirstInput) (*cadence.TestWorkflowEnvironment, int, []int) {
 	env := s.NewTestWorkflowEnvironment()
-	env.OverrideActivity(sampleActivity, func(ctx context.Context, currentBranchID int, totalDuration time.Duration) (string, error) {
-		// make branch 0 super fast so we don't have to wait sleep time in unit test
-		if currentBranchID == 0 {
-			totalDuration = time.Nanosecond
+	stopped := make(chan int, input.Branches)
+	env.OverrideActivity(sampleActivity, func(ctx context.Context, input BranchInput) (string, error) {
+		response, err := sampleActivity(ctx, input)
+		if ctx.Err() != nil {
+			stopped <- input.Branch
 		}
-		return sampleActivity(ctx, currentBranchID, totalDuration)
+		return response, err
+	})
+	cancelled := 0
+	env.SetOnActivityCanceledListener(func(info *cadence.ActivityInfo) {
+		cancelled++
 	})
-	env.ExecuteWorkflow(SamplePickFirstWorkflow)
+	env.ExecuteWorkflow(SamplePickFirstWorkflow, input)
+
+	// the losers stop once their next heartbeat learns of the cancellation.
+	var stoppedBranches []int
+	for len(stoppedBranches) < cancelled {
+		select {
+		case branch := <-stopped:
+			stoppedBranches = append(stoppedBranches, branch)
+		case <-time.After(time.Second * 5):
+			s.Fail("cancelled branches didn't stop", "stopped %v of %d", stoppedBranches, cancelled)
+			return env, cancelled, stoppedBranches
+		}
+	}
+	sort.Ints(stoppedBranches)
+	return env, cancelled, stoppedBranches
+}
+
+func (s *UnitTestSuite) Test_Workflow() {
+	env, cancelled, stopped := s.runRace(PickFirstInput{Branches: 3, Latency: time.Millisecond * 20})
 
 	s.True(env.IsWorkflowCompleted())
 	s.NoError(env.GetWorkflowError())
+	var response string
+	s.NoError(env.GetWorkflowResult(&response))
+	s.Equal("Branch 0 done in 20ms.", response)
+	s.Equal(2, cancelled)
+	s.Equal([]int{1, 2}, stopped)
+}
+
+func (s *UnitTestSuite) Test_Workflow_FirstCompletionFails() {
+	env, cancelled, stopped := s.runRace(PickFirstInput{Branches: 3, Latency: time.Millisecond * 20,
+		FailingBranches: []int{0}, TaskLists: []string{"region1", "region2"}})
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var response string
+	s.NoError(env.GetWorkflowResult(&response))
+	s.Equal("Branch 1 done in 40ms.", response)
+	s.Equal(1, cancelled)
+	s.Equal([]int{2}, stopped)
+}
+
+func (s *UnitTestSuite) Test_Workflow_AllBranchesFail() {
+	env, cancelled, _ := s.runRace(PickFirstInput{Branches: 2, Latency: time.Millisecond,
+		FailingBranches: []int{0, 1}})
+
+	s.True(env.IsWorkflowCompleted())
+	s.EqualError(env.GetWorkflowError(), "all 2 branches failed: branch 0: branchFailed; branch 1: branchFailed")
+	s.Equal(0, cancelled)
+}
+
+func (s *UnitTestSuite) Test_Workflow_NoBranches() {
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SamplePickFirstWorkflow, PickFirstInput{})
+
+	s.True(env.IsWorkflowCompleted())
+	s.EqualError(env.GetWorkflowError(), "at least one branch is needed")
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper, callsPerMinute float64) {
+	// Configure worker options. A worker starts the activities at most at the rate of the API. The rate is the one of
+	// each worker, and this version of the client doesn't enforce it; the rate of the task list, which all workers
+	// share, is an option of newer clients. The workflow paces the calls with a timer instead.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope:             h.Scope,
+		Logger:                   h.Logger,
+		MaxActivityExecutionRate: float32(callsPerMinute / 60),
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+func startWorkflow(h *common.SampleHelper, input RateLimitInput) {
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:       "ratelimit_" + uuid.New(),
+		TaskList: ApplicationName,
+		// the calls take a minute for every CallsPerMinute items.
+		ExecutionStartToCloseTimeout:    time.Duration(float64(len(input.Items))/input.CallsPerMinute)*time.Minute + time.Minute*5,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, RateLimitedWorkflow, input)
+}
+
+func main() {
+	var mode string
+	var items int
+	var input RateLimitInput
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.Float64Var(&input.CallsPerMinute, "rate", 10, "Calls per minute the downstream API allows.")
+	flag.IntVar(&items, "items", 20, "Number of items to call the API with.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	h.SetupServiceConfig()
+
+	switch mode {
+	case "worker":
+		startWorkers(&h, input.CallsPerMinute)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		if input.CallsPerMinute <= 0 {
+			panic("The rate must be positive")
+		}
+		for i := 1; i <= items; i++ {
+			input.Items = append(input.Items, fmt.Sprintf("item-%d", i))
+		}
+		startWorkflow(&h, input)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow calls a downstream API that allows a number of calls per minute. The workflow paces the calls
+ * itself: it starts the activity of an item, waits for a timer of a minute divided by the rate, and starts the next
+ * one. The pacing is in the decisions of the workflow, so it holds however many workers poll the task list, and the
+ * calls still overlap when the API is slower than the rate.
+ *
+ * The pacing has to be deterministic, the workflow code is replayed. It uses cadence.Now and timers, never time.Now,
+ * time.Sleep or a token bucket that refills with the wall clock: on replay those would see another time, and start the
+ * activities at other points of the history than the first execution did.
+ *
+ * The pacing limits the calls of one workflow. The workers can be throttled too, see main.go, but this version of the
+ * client doesn't enforce the activity rate of a worker and has no rate for the task list of all workers.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "ratelimitGroup"
+
+type (
+	// RateLimitInput is the input of the RateLimitedWorkflow.
+	RateLimitInput struct {
+		// CallsPerMinute is the rate of the downstream API.
+		CallsPerMinute float64
+		Items          []string
+	}
+
+	// Throughput is what the RateLimitedWorkflow achieved.
+	Throughput struct {
+		Calls  int
+		Failed int
+		// Elapsed is the time from the first call until the rate allowed another call after the last one, or until the
+		// last call completed if that was later.
+		Elapsed time.Duration
+		// CallsPerMinute is the rate the calls achieved over Elapsed.
+		CallsPerMinute float64
+	}
+)
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(RateLimitedWorkflow)
+	cadence.RegisterActivity(callDownstreamActivity)
+}
+
+// interval returns the time between two calls at the rate.
+func (input RateLimitInput) interval() time.Duration {
+	return time.Duration(float64(time.Minute) / input.CallsPerMinute)
+}
+
+// RateLimitedWorkflow workflow decider
+func RateLimitedWorkflow(ctx cadence.Context, input RateLimitInput) (Throughput, error) {
+	if input.CallsPerMinute <= 0 {
+		return Throughput{}, errors.New("calls per minute must be positive")
+	}
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx)
+	interval := input.interval()
+
+	var futures []cadence.Future
+	started := cadence.Now(ctx)
+	for i, item := range input.Items {
+		if i > 0 {
+			// the timer is part of the history, on replay it fires at the same point as in the first execution.
+			if err := cadence.Sleep(ctx, interval); err != nil {
+				return Throughput{}, err
+			}
+		}
+		futures = append(futures, cadence.ExecuteActivity(ctx, callDownstreamActivity, item))
+	}
+	// the rate allows the next call one interval after the last one.
+	nextCall := cadence.NewTimer(ctx, interval)
+
+	throughput := Throughput{Calls: len(futures)}
+	for i, f := range futures {
+		if err := f.Get(ctx, nil); err != nil {
+			logger.Info("Call failed.", zap.String("Item", input.Items[i]), zap.Error(err))
+			throughput.Failed++
+		}
+	}
+	if err := nextCall.Get(ctx, nil); err != nil {
+		return Throughput{}, err
+	}
+	throughput.Elapsed = cadence.Now(ctx).Sub(started)
+	if throughput.Calls > 0 {
+		throughput.CallsPerMinute = float64(throughput.Calls) / throughput.Elapsed.Minutes()
+	}
+	logger.Info("Workflow completed.", zap.Int("Calls", throughput.Calls), zap.Int("Failed", throughput.Failed),
+		zap.Duration("Elapsed", throughput.Elapsed), zap.Float64("CallsPerMinute", throughput.CallsPerMinute))
+	return throughput, nil
+}
+
+func callDownstreamActivity(ctx context.Context, item string) error {
+	// some fake call of the downstream API here
+	cadence.GetActivityLogger(ctx).Info("Downstream called", zap.String("item", item))
+	return nil
+}
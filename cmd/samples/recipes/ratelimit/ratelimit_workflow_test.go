@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+
+	env *cadence.TestWorkflowEnvironment
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+// recordStarts records the times the calls start at, relative to the start of the workflow.
+func (s *UnitTestSuite) recordStarts(callDuration time.Duration, failing string) *[]time.Duration {
+	var starts []time.Duration
+	started := s.env.Now()
+	s.env.OverrideActivity(callDownstreamActivity, func(ctx context.Context, item string) error {
+		starts = append(starts, s.env.Now().Sub(started))
+		var err error
+		if item == failing {
+			err = errors.New("too many requests")
+		}
+		if callDuration == 0 {
+			return err
+		}
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		s.env.RegisterDelayedCallback(func() {
+			s.env.CompleteActivity(taskToken, nil, err)
+		}, callDuration)
+		return cadence.ErrActivityResultPending
+	})
+	return &starts
+}
+
+func (s *UnitTestSuite) run(input RateLimitInput) Throughput {
+	s.env.ExecuteWorkflow(RateLimitedWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var throughput Throughput
+	s.NoError(s.env.GetWorkflowResult(&throughput))
+	return throughput
+}
+
+func (s *UnitTestSuite) Test_Paced() {
+	starts := s.recordStarts(0, "c")
+	throughput := s.run(RateLimitInput{CallsPerMinute: 10, Items: []string{"a", "b", "c", "d", "e"}})
+
+	// 10 calls per minute are a call every 6 seconds.
+	s.Equal([]time.Duration{0, time.Second * 6, time.Second * 12, time.Second * 18, time.Second * 24}, *starts)
+	s.Equal(Throughput{Calls: 5, Failed: 1, Elapsed: time.Second * 30, CallsPerMinute: 10}, throughput)
+}
+
+func (s *UnitTestSuite) Test_Paced_SlowCalls() {
+	// the calls overlap, the pacing doesn't wait for them.
+	starts := s.recordStarts(time.Second*15, "")
+	throughput := s.run(RateLimitInput{CallsPerMinute: 6, Items: []string{"a", "b", "c"}})
+
+	s.Equal([]time.Duration{0, time.Second * 10, time.Second * 20}, *starts)
+	// the last call completes 5 seconds after the rate allowed another one.
+	s.Equal(time.Second*35, throughput.Elapsed)
+	s.InDelta(3/(35.0/60), throughput.CallsPerMinute, 1e-9)
+}
+
+func (s *UnitTestSuite) Test_InvalidRate() {
+	s.env.ExecuteWorkflow(RateLimitedWorkflow, RateLimitInput{Items: []string{"a"}})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+}
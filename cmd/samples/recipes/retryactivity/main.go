@@ -21,19 +21,24 @@ func startWorkers(h *common.SampleHelper) {
 	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
 }
 
-func startWorkflow(h *common.SampleHelper) {
+func startWorkflow(h *common.SampleHelper, workflow interface{}, input ActivityInput) {
 	workflowOptions := cadence.StartWorkflowOptions{
 		ID:                              "retry_" + uuid.New(),
 		TaskList:                        ApplicationName,
 		ExecutionStartToCloseTimeout:    time.Minute,
 		DecisionTaskStartToCloseTimeout: time.Minute,
 	}
-	h.StartWorkflow(workflowOptions, RetryWorkflow, 5)
+	h.StartWorkflow(workflowOptions, workflow, input)
 }
 
 func main() {
-	var mode string
+	var mode, retry string
+	var failAttempts int
+	var permanent bool
 	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.StringVar(&retry, "retry", "loop", "How the activity is retried: loop retries it in the workflow code, policy with a RetryPolicy.")
+	flag.IntVar(&failAttempts, "fail", 2, "Number of attempts of the activity that fail before one succeeds.")
+	flag.BoolVar(&permanent, "permanent", false, "Fail the activity with a permanent error that is not retried.")
 	flag.Parse()
 
 	var h common.SampleHelper
@@ -47,6 +52,12 @@ func main() {
 		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
 		select {}
 	case "trigger":
-		startWorkflow(&h)
+		input := ActivityInput{FailAttempts: failAttempts, Permanent: permanent}
+		switch retry {
+		case "policy":
+			startWorkflow(&h, RetryPolicyWorkflow, input)
+		default:
+			startWorkflow(&h, RetryWorkflow, input)
+		}
 	}
 }
@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
-	"math/rand"
 	"time"
 
 	"go.uber.org/cadence"
@@ -14,20 +12,48 @@ import (
  * This sample workflow executes unreliable activity and would retry until it reaches a set maximum retry count.
  * It supports custom logic to determine if a retry is needed based on the error. It also support custom back off logic
  * to wait before a retry is issued.
+ *
+ * There are two ways to retry the activity, RetryWorkflow writes the retry loop in the workflow code, and
+ * RetryPolicyWorkflow declares a RetryPolicy, see retry_policy_workflow.go. Both retry the same activity with the same
+ * backoff, and neither retries its permanent error.
  */
 
 // ApplicationName is the task list for this sample
 const ApplicationName = "retryactivityGroup"
 
+const (
+	// errReasonTransient is the reason of the error of a failed attempt that a retry fixes, its details are the attempt.
+	errReasonTransient = "transientFailure"
+	// errReasonPermanent is the reason of the error of a failed attempt that no retry fixes.
+	errReasonPermanent = "permanentFailure"
+
+	// the backoff of both workflows starts at a second and doubles after every failed attempt, up to 10 seconds, for
+	// at most 5 attempts.
+	initialInterval = time.Second
+	maximumInterval = time.Second * 10
+	maximumAttempts = 5
+)
+
+// ActivityInput is the input of an attempt of sampleActivity.
+type ActivityInput struct {
+	// Attempt is the attempt of the activity, it starts at 0.
+	Attempt int
+	// FailAttempts is the number of attempts that fail with a transient error before one succeeds.
+	FailAttempts int
+	// Permanent fails every attempt with a permanent error.
+	Permanent bool
+}
+
 // This is registration process where you register all your workflows
 // and activity function handlers.
 func init() {
 	cadence.RegisterWorkflow(RetryWorkflow)
+	cadence.RegisterWorkflow(RetryPolicyWorkflow)
 	cadence.RegisterActivity(sampleActivity)
 }
 
 // RetryWorkflow workflow decider
-func RetryWorkflow(ctx cadence.Context, maxRetries int) error {
+func RetryWorkflow(ctx cadence.Context, input ActivityInput) error {
 	ao := cadence.ActivityOptions{
 		ScheduleToStartTimeout: time.Minute,
 		StartToCloseTimeout:    time.Minute,
@@ -36,11 +62,12 @@ func RetryWorkflow(ctx cadence.Context, maxRetries int) error {
 	ctx = cadence.WithActivityOptions(ctx, ao)
 
 	// User retry policy.
-	backOff := newBackOff(maxRetries)
+	backOff := newBackOff(maximumAttempts)
 
 	err := backOff.Retry(ctx,
-		func() (interface{}, error) {
-			return nil, cadence.ExecuteActivity(ctx, sampleActivity).Get(ctx, nil)
+		func(attempt int) error {
+			input.Attempt = attempt
+			return cadence.ExecuteActivity(ctx, sampleActivity, input).Get(ctx, nil)
 		})
 	if err != nil {
 		cadence.GetLogger(ctx).Info("Workflow completed with error.", zap.Error(err))
@@ -55,16 +82,18 @@ type backOff struct {
 	// User custom retry policy.
 	// This is a simple one.
 	// ...
-	maxRetries int
+	maxAttempts int
 }
 
-func newBackOff(maxRetries int) *backOff {
-	return &backOff{maxRetries: maxRetries}
+func newBackOff(maxAttempts int) *backOff {
+	return &backOff{maxAttempts: maxAttempts}
 }
 
-func (b *backOff) Retry(ctx cadence.Context, op func() (interface{}, error)) error {
-	for retryCount := 1; retryCount <= b.maxRetries; retryCount++ {
-		_, err := op()
+// Retry calls op until it succeeds, with the attempt starting at 0. It returns the error of the last attempt if it
+// wasn't worth a retry or if it was the last attempt.
+func (b *backOff) Retry(ctx cadence.Context, op func(attempt int) error) error {
+	for attempt := 0; ; attempt++ {
+		err := op(attempt)
 
 		if err == nil {
 			// activity succeed.
@@ -72,38 +101,54 @@ func (b *backOff) Retry(ctx cadence.Context, op func() (interface{}, error)) err
 		}
 
 		// check if we should retry or give up
-		if !b.shouldRetry(err) {
+		if !b.shouldRetry(err) || attempt+1 >= b.maxAttempts {
 			return err
 		}
 
-		// optional back off
-		cadence.Sleep(ctx, b.backoffDuration(retryCount))
+		// the backoff is a durable timer, a worker that restarts meanwhile doesn't retry any sooner.
+		backoff := b.backoffDuration(attempt)
+		cadence.GetLogger(ctx).Info("Activity failed, retrying.", zap.Int("Attempt", attempt),
+			zap.Duration("Backoff", backoff), zap.Error(err))
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return err
+		}
 	}
-	return errors.New("Exceeded max retry attempts")
 }
 
-func (b *backOff) backoffDuration(retryCount int) time.Duration {
-	// add custom logic to decide how long to wait before retry, for example exponentially backoff.
-	return 0 // 0 indicate to retry immediately
+// backoffDuration returns the time to wait after the given failed attempt, it doubles after every attempt.
+func (b *backOff) backoffDuration(attempt int) time.Duration {
+	backoff := initialInterval << uint(attempt)
+	if backoff > maximumInterval {
+		backoff = maximumInterval
+	}
+	return backoff
 }
 
 func (b *backOff) shouldRetry(err error) bool {
-	// add custom logic to decide if we should retry
-	switch err.(type) {
+	// a cancelled activity or a permanent error is not retried.
+	switch err := err.(type) {
+	case cadence.CanceledError:
+		return false
+	case cadence.ErrorWithDetails:
+		return err.Reason() != errReasonPermanent
 	}
 	return true
 }
 
 /**
- * Unreliable activity that fails randomly
+ * Unreliable activity that fails the first attempts
  */
-func sampleActivity(ctx context.Context) error {
-	logger := cadence.GetActivityLogger(ctx)
-	if rand.Float32() < 0.7 {
+func sampleActivity(ctx context.Context, input ActivityInput) error {
+	logger := cadence.GetActivityLogger(ctx).With(zap.Int("Attempt", input.Attempt))
+	if input.Permanent {
+		logger.Info("Activity failed permanently.")
+		return cadence.NewErrorWithDetails(errReasonPermanent)
+	}
+	if input.Attempt < input.FailAttempts {
 		logger.Info("Activity failed, please retry.")
 		// Activity could return different error types for different failures so workflow could handle them differently.
 		// For example, decide to retry or not based on error type.
-		return errors.New("failed")
+		return cadence.NewErrorWithDetails(errReasonTransient, input.Attempt)
 	}
 
 	logger.Info("Activity succeed.")
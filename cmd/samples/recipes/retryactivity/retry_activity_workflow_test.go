@@ -3,8 +3,8 @@ package main
 import (
 	"context"
 	"testing"
+	"time"
 
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/cadence"
 )
@@ -18,20 +18,49 @@ func TestUnitTestSuite(t *testing.T) {
 	suite.Run(t, new(UnitTestSuite))
 }
 
-func (s *UnitTestSuite) Test_Workflow() {
-	env := s.NewTestWorkflowEnvironment()
-	maxRetry := 5
-	retryCount := 0
-	env.OverrideActivity(sampleActivity, func(ctx context.Context) error {
-		retryCount++
-		if retryCount < maxRetry {
-			return errors.New("failed, please retry")
+func (s *UnitTestSuite) Test_Workflows() {
+	testCases := []struct {
+		name  string
+		input ActivityInput
+		// attempts are the start times of the attempts, in seconds after the start of the workflow.
+		attempts    []time.Duration
+		expectedErr string
+	}{
+		{"transient failures", ActivityInput{FailAttempts: 2}, []time.Duration{0, 1, 3}, ""},
+		{"no failure", ActivityInput{}, []time.Duration{0}, ""},
+		{"maximum attempts", ActivityInput{FailAttempts: 10}, []time.Duration{0, 1, 3, 7, 15},
+			errReasonTransient},
+		{"permanent failure", ActivityInput{Permanent: true}, []time.Duration{0}, errReasonPermanent},
+	}
+	for _, workflow := range []interface{}{RetryWorkflow, RetryPolicyWorkflow} {
+		for _, tc := range testCases {
+			env := s.NewTestWorkflowEnvironment()
+			var attempts []time.Duration
+			env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context, args cadence.EncodedValues) {
+				var input ActivityInput
+				s.NoError(args.Get(&input))
+				s.Equal(len(attempts), input.Attempt, tc.name)
+				attempts = append(attempts, env.Now().Sub(time.Unix(0, 0))/time.Second)
+			})
+			env.ExecuteWorkflow(workflow, tc.input)
+
+			s.True(env.IsWorkflowCompleted(), tc.name)
+			s.Equal(tc.attempts, attempts, tc.name)
+			if tc.expectedErr == "" {
+				s.NoError(env.GetWorkflowError(), tc.name)
+			} else {
+				s.Error(env.GetWorkflowError(), tc.name)
+				s.Equal(tc.expectedErr, env.GetWorkflowError().(cadence.ErrorWithDetails).Reason(), tc.name)
+			}
 		}
-		return nil
-	})
-	env.ExecuteWorkflow(RetryWorkflow, maxRetry)
+	}
+}
 
-	s.True(env.IsWorkflowCompleted())
-	s.NoError(env.GetWorkflowError())
-	s.Equal(maxRetry, retryCount)
+func (s *UnitTestSuite) Test_Backoff() {
+	policy := RetryPolicy{InitialInterval: time.Second, BackoffCoefficient: 2, MaximumInterval: time.Second * 10}
+	b := newBackOff(maximumAttempts)
+	for attempt, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		s.Equal(expected*time.Second, policy.backoff(attempt))
+		s.Equal(expected*time.Second, b.backoffDuration(attempt))
+	}
 }
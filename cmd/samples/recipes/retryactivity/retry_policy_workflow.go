@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * RetryPolicyWorkflow declares how the activity is retried instead of writing the retry loop. Newer versions of the
+ * client pass the RetryPolicy with the ActivityOptions and the server retries the activity: every attempt runs as the
+ * same activity execution, the history of the workflow has no events of the failed attempts and no timers for their
+ * backoff, and the activity reads its attempt from its activity info. This version of the client has no RetryPolicy in
+ * the ActivityOptions, so executeWithRetryPolicy interprets the policy in the workflow, with the same fields and the
+ * same semantics as the server, and passes the attempt with the input. Once the client is upgraded, the policy moves
+ * into the ActivityOptions as it is and executeWithRetryPolicy goes away.
+ *
+ * The explicit loop of RetryWorkflow can decide anything between the attempts, e.g. change the input of the next
+ * attempt. The policy can only decide what its fields allow, but it is declared in one place and retried by the server.
+ */
+
+// RetryPolicy is the retry policy of an activity, with the fields of the activity retry policy of the server.
+type RetryPolicy struct {
+	// InitialInterval is the backoff before the first retry.
+	InitialInterval time.Duration
+	// BackoffCoefficient multiplies the backoff after every retry.
+	BackoffCoefficient float64
+	// MaximumInterval caps the backoff. Zero means no cap.
+	MaximumInterval time.Duration
+	// MaximumAttempts is the maximum number of attempts including the first one.
+	MaximumAttempts int
+	// NonRetriableErrorReasons are the reasons of errors that are not retried.
+	NonRetriableErrorReasons []string
+}
+
+// RetryPolicyWorkflow workflow decider
+func RetryPolicyWorkflow(ctx cadence.Context, input ActivityInput) error {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+
+	policy := RetryPolicy{
+		InitialInterval:          initialInterval,
+		BackoffCoefficient:       2,
+		MaximumInterval:          maximumInterval,
+		MaximumAttempts:          maximumAttempts,
+		NonRetriableErrorReasons: []string{errReasonPermanent},
+	}
+	if err := executeWithRetryPolicy(ctx, policy, input); err != nil {
+		cadence.GetLogger(ctx).Info("Workflow completed with error.", zap.Error(err))
+		return err
+	}
+	cadence.GetLogger(ctx).Info("Workflow completed.")
+	return nil
+}
+
+// executeWithRetryPolicy executes sampleActivity and retries it according to the policy, the way the server does.
+func executeWithRetryPolicy(ctx cadence.Context, policy RetryPolicy, input ActivityInput) error {
+	for input.Attempt = 0; ; input.Attempt++ {
+		err := cadence.ExecuteActivity(ctx, sampleActivity, input).Get(ctx, nil)
+		if err == nil || !policy.isRetriable(err) || input.Attempt+1 >= policy.MaximumAttempts {
+			return err
+		}
+		backoff := policy.backoff(input.Attempt)
+		cadence.GetLogger(ctx).Info("Activity failed, retrying.", zap.Int("Attempt", input.Attempt),
+			zap.Duration("Backoff", backoff), zap.Error(err))
+		if err := cadence.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the time to wait after the given failed attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := time.Duration(float64(p.InitialInterval) * math.Pow(p.BackoffCoefficient, float64(attempt)))
+	if p.MaximumInterval > 0 && backoff > p.MaximumInterval {
+		backoff = p.MaximumInterval
+	}
+	return backoff
+}
+
+func (p RetryPolicy) isRetriable(err error) bool {
+	switch err := err.(type) {
+	case cadence.CanceledError:
+		return false
+	case cadence.ErrorWithDetails:
+		for _, reason := range p.NonRetriableErrorReasons {
+			if err.Reason() == reason {
+				return false
+			}
+		}
+	}
+	return true
+}
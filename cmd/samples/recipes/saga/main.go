@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence"
+)
+
+// This needs to be done as part of a bootstrap step when the process starts.
+// The workers are supposed to be long running.
+func startWorkers(h *common.SampleHelper) {
+	// Configure worker options.
+	workerOptions := cadence.WorkerOptions{
+		MetricsScope: h.Scope,
+		Logger:       h.Logger,
+	}
+	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
+}
+
+func startWorkflow(h *common.SampleHelper, order Order) {
+	workflowOptions := cadence.StartWorkflowOptions{
+		ID:                              "saga_" + order.ID,
+		TaskList:                        ApplicationName,
+		ExecutionStartToCloseTimeout:    time.Minute,
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	}
+	h.StartWorkflow(workflowOptions, SagaWorkflow, order)
+}
+
+func main() {
+	var mode, failAt, failCompensation string
+	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.StringVar(&failAt, "fail", "", "Step of the order that fails: reserve, charge or ship. Default is none.")
+	flag.StringVar(&failCompensation, "failCompensation", "", "Step whose compensation fails: reserve or charge. Default is none.")
+	flag.Parse()
+
+	var h common.SampleHelper
+	h.SetupServiceConfig()
+
+	switch mode {
+	case "worker":
+		startWorkers(&h)
+
+		// The workers are supposed to be long running process that should not exit.
+		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
+		select {}
+	case "trigger":
+		startWorkflow(&h, Order{ID: uuid.New(), Amount: 100, FailAt: failAt, FailCompensation: failCompensation})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samarabbas/cadence-samples/cmd/samples/common"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * This sample workflow places an order in three steps, it reserves the inventory, charges the payment and schedules
+ * the shipment. There is no transaction across the three services, so once a step completed the workflow adds the
+ * activity that undoes it to its compensations. When a later step fails or the workflow is cancelled, the completed
+ * steps are compensated in reverse order: the payment is refunded before the inventory is released. The shipment is
+ * the last step, nothing can fail after it, so it has no compensation.
+ *
+ * A compensation that fails is retried, and a compensation that still fails is part of the error of the workflow, an
+ * order that is neither placed nor undone needs someone to look at it.
+ */
+
+// ApplicationName is the task list for this sample
+const ApplicationName = "sagaGroup"
+
+// The steps of the saga.
+const (
+	stepReserve = "reserve"
+	stepCharge  = "charge"
+	stepShip    = "ship"
+)
+
+// errReasonStepFailed is the reason of the error of a failed step.
+const errReasonStepFailed = "stepFailed"
+
+// Order is the input of the saga and of its activities.
+type Order struct {
+	ID     string
+	Amount int
+	// FailAt is the step that fails, empty if all of them succeed.
+	FailAt string
+	// FailCompensation is the step whose compensation fails, empty if all of them succeed.
+	FailCompensation string
+}
+
+// This is registration process where you register all your workflows
+// and activity function handlers.
+func init() {
+	cadence.RegisterWorkflow(SagaWorkflow)
+	cadence.RegisterActivity(reserveInventoryActivity)
+	cadence.RegisterActivity(releaseInventoryActivity)
+	cadence.RegisterActivity(chargePaymentActivity)
+	cadence.RegisterActivity(refundPaymentActivity)
+	cadence.RegisterActivity(scheduleShipmentActivity)
+}
+
+// SagaWorkflow workflow decider
+func SagaWorkflow(ctx cadence.Context, order Order) (err error) {
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx).With(zap.String("OrderID", order.ID))
+
+	compensations := common.Compensations{RetryPolicy: common.RetryPolicy{
+		InitialInterval: time.Second,
+		MaximumAttempts: 3,
+	}}
+	defer func() {
+		if err == nil {
+			return
+		}
+		logger.Info("Order failed, compensating.", zap.Error(err))
+		if compensateErr := compensations.Compensate(ctx); compensateErr != nil {
+			err = fmt.Errorf("%v, and %v", err, compensateErr)
+		}
+	}()
+
+	steps := []struct {
+		name                   string
+		activity, compensation interface{}
+	}{
+		{stepReserve, reserveInventoryActivity, releaseInventoryActivity},
+		{stepCharge, chargePaymentActivity, refundPaymentActivity},
+		{stepShip, scheduleShipmentActivity, nil},
+	}
+	for _, step := range steps {
+		if err := cadence.ExecuteActivity(ctx, step.activity, order).Get(ctx, nil); err != nil {
+			return err
+		}
+		if step.compensation != nil {
+			compensations.AddCompensation(step.compensation, order)
+		}
+	}
+	logger.Info("Order placed.")
+	return nil
+}
+
+// executeStep executes a step of the order, it fails if the order fails at it.
+func executeStep(ctx context.Context, step string, order Order) error {
+	logger := cadence.GetActivityLogger(ctx).With(zap.String("OrderID", order.ID), zap.String("Step", step))
+	if order.FailAt == step {
+		logger.Info("Order step failed.")
+		return cadence.NewErrorWithDetails(errReasonStepFailed, step)
+	}
+	// ...
+	logger.Info("Order step completed.")
+	return nil
+}
+
+// compensateStep undoes a step of the order, it fails if the compensation of the order fails at it.
+func compensateStep(ctx context.Context, step string, order Order) error {
+	logger := cadence.GetActivityLogger(ctx).With(zap.String("OrderID", order.ID), zap.String("Step", step))
+	if order.FailCompensation == step {
+		logger.Info("Order step compensation failed.")
+		return fmt.Errorf("compensation of %s failed", step)
+	}
+	// ...
+	logger.Info("Order step compensated.")
+	return nil
+}
+
+func reserveInventoryActivity(ctx context.Context, order Order) error {
+	return executeStep(ctx, stepReserve, order)
+}
+
+func releaseInventoryActivity(ctx context.Context, order Order) error {
+	return compensateStep(ctx, stepReserve, order)
+}
+
+func chargePaymentActivity(ctx context.Context, order Order) error {
+	return executeStep(ctx, stepCharge, order)
+}
+
+func refundPaymentActivity(ctx context.Context, order Order) error {
+	return compensateStep(ctx, stepCharge, order)
+}
+
+func scheduleShipmentActivity(ctx context.Context, order Order) error {
+	return executeStep(ctx, stepShip, order)
+}
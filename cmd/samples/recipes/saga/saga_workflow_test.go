@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	cadence.WorkflowTestSuite
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
+
+// recordActivities returns the names of the activities the workflow executes, in order.
+func recordActivities(env *cadence.TestWorkflowEnvironment) *[]string {
+	var activities []string
+	env.SetOnActivityStartedListener(func(info *cadence.ActivityInfo, ctx context.Context, args cadence.EncodedValues) {
+		name := info.ActivityType.Name
+		activities = append(activities, name[strings.LastIndex(name, ".")+1:])
+	})
+	return &activities
+}
+
+func (s *UnitTestSuite) Test_SagaWorkflow_FailureAtEveryStep() {
+	testCases := []struct {
+		failAt      string
+		activities  []string
+		expectedErr bool
+	}{
+		{"", []string{"reserveInventoryActivity", "chargePaymentActivity", "scheduleShipmentActivity"}, false},
+		{stepReserve, []string{"reserveInventoryActivity"}, true},
+		{stepCharge, []string{"reserveInventoryActivity", "chargePaymentActivity", "releaseInventoryActivity"}, true},
+		// the compensations run in reverse order.
+		{stepShip, []string{"reserveInventoryActivity", "chargePaymentActivity", "scheduleShipmentActivity",
+			"refundPaymentActivity", "releaseInventoryActivity"}, true},
+	}
+	for _, tc := range testCases {
+		env := s.NewTestWorkflowEnvironment()
+		activities := recordActivities(env)
+		env.ExecuteWorkflow(SagaWorkflow, Order{ID: "order-1", Amount: 100, FailAt: tc.failAt})
+
+		s.True(env.IsWorkflowCompleted(), tc.failAt)
+		s.Equal(tc.activities, *activities, tc.failAt)
+		if !tc.expectedErr {
+			s.NoError(env.GetWorkflowError())
+			continue
+		}
+		// the compensations succeeded, the error is the one of the failed step.
+		err, ok := env.GetWorkflowError().(cadence.ErrorWithDetails)
+		s.True(ok, tc.failAt)
+		s.Equal(errReasonStepFailed, err.Reason(), tc.failAt)
+	}
+}
+
+func (s *UnitTestSuite) Test_SagaWorkflow_CompensationRetried() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(refundPaymentActivity, mock.Anything, mock.Anything).Return(errors.New("payment service unavailable")).Once()
+	env.OnActivity(refundPaymentActivity, mock.Anything, mock.Anything).Return(nil).Once()
+	activities := recordActivities(env)
+	env.ExecuteWorkflow(SagaWorkflow, Order{ID: "order-1", FailAt: stepShip})
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal([]string{"reserveInventoryActivity", "chargePaymentActivity", "scheduleShipmentActivity",
+		"refundPaymentActivity", "refundPaymentActivity", "releaseInventoryActivity"}, *activities)
+	err, ok := env.GetWorkflowError().(cadence.ErrorWithDetails)
+	s.True(ok)
+	s.Equal(errReasonStepFailed, err.Reason())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SagaWorkflow_CompensationFails() {
+	env := s.NewTestWorkflowEnvironment()
+	activities := recordActivities(env)
+	env.ExecuteWorkflow(SagaWorkflow, Order{ID: "order-1", FailAt: stepShip, FailCompensation: stepCharge})
+
+	s.True(env.IsWorkflowCompleted())
+	// the refund is retried twice with a backoff of 1s and 2s, the inventory is released even though it failed.
+	s.Equal([]string{"reserveInventoryActivity", "chargePaymentActivity", "scheduleShipmentActivity",
+		"refundPaymentActivity", "refundPaymentActivity", "refundPaymentActivity", "releaseInventoryActivity"},
+		*activities)
+	s.Equal(time.Unix(0, 0).Add(time.Second*3), env.Now())
+	s.Error(env.GetWorkflowError())
+	s.Contains(env.GetWorkflowError().Error(), errReasonStepFailed)
+	s.Contains(env.GetWorkflowError().Error(), "1 compensations failed: refundPaymentActivity: compensation of charge failed")
+}
+
+func (s *UnitTestSuite) Test_SagaWorkflow_CancelMidSequence() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OverrideActivity(chargePaymentActivity, func(ctx context.Context, order Order) error {
+		// the payment is still being charged when the workflow is cancelled.
+		return cadence.ErrActivityResultPending
+	})
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	activities := recordActivities(env)
+	env.ExecuteWorkflow(SagaWorkflow, Order{ID: "order-1"})
+
+	s.True(env.IsWorkflowCompleted())
+	// the inventory is released although the workflow is cancelled, the charge never completed.
+	s.Equal([]string{"reserveInventoryActivity", "chargePaymentActivity", "releaseInventoryActivity"}, *activities)
+	_, ok := env.GetWorkflowError().(cadence.CanceledError)
+	s.True(ok)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/zap"
+)
+
+/**
+ * BoundedSplitMergeWorkflow processes the chunks like SampleSplitMergeWorkflow, but with at most Parallelism chunk
+ * activities in flight at once. Instead of a coroutine per chunk it keeps a selector with the futures in flight, and
+ * starts the next chunk whenever one of them completes, so the order the chunks start and complete in is the same on
+ * replay.
+ *
+ * A failing chunk either fails the workflow right away, after cancelling the chunks in flight and starting no more, or
+ * is reported by the merge while the other chunks continue. The merge receives the chunks in chunk order, whatever the
+ * order they completed in.
+ */
+
+// Policies of the BoundedSplitMergeWorkflow for a failing chunk.
+const (
+	// policyFailFast cancels the chunks in flight and fails the workflow.
+	policyFailFast = "failFast"
+	// policyCollectErrors continues with the other chunks and merges the ones that succeeded.
+	policyCollectErrors = "collectErrors"
+)
+
+// errReasonChunkFailed is the reason of the error of a failing chunk.
+const errReasonChunkFailed = "chunkFailed"
+
+type (
+	// SplitMergeInput is the input of the BoundedSplitMergeWorkflow.
+	SplitMergeInput struct {
+		// Chunks is the number of chunks, they are numbered from 1.
+		Chunks int
+		// Parallelism is the number of chunks in flight at most.
+		Parallelism int
+		// Policy is what a failing chunk does, policyFailFast or policyCollectErrors.
+		Policy string
+		// FailingChunks are the chunks that fail.
+		FailingChunks []int
+	}
+
+	// ChunkInput is the input of a chunk.
+	ChunkInput struct {
+		ChunkID int
+		Fail    bool
+	}
+
+	// ChunkOutcome is the result or the error of a chunk.
+	ChunkOutcome struct {
+		ChunkID int
+		Result  ChunkResult
+		Error   string
+	}
+
+	// SplitMergeReport is the merged result of the chunks.
+	SplitMergeReport struct {
+		ChunkResult
+		// FailedChunks are the chunks that failed, in chunk order.
+		FailedChunks []int
+	}
+)
+
+func init() {
+	cadence.RegisterWorkflow(BoundedSplitMergeWorkflow)
+	cadence.RegisterActivity(processChunkActivity)
+	cadence.RegisterActivity(mergeChunksActivity)
+}
+
+func (input SplitMergeInput) validate() error {
+	if input.Chunks < 1 {
+		return errors.New("at least one chunk is needed")
+	}
+	if input.Parallelism < 1 {
+		return errors.New("parallelism must be at least 1")
+	}
+	if input.Policy != policyFailFast && input.Policy != policyCollectErrors {
+		return fmt.Errorf("unknown policy %q, it is %s or %s", input.Policy, policyFailFast, policyCollectErrors)
+	}
+	return nil
+}
+
+// BoundedSplitMergeWorkflow workflow decider
+func BoundedSplitMergeWorkflow(ctx cadence.Context, input SplitMergeInput) (SplitMergeReport, error) {
+	if err := input.validate(); err != nil {
+		return SplitMergeReport{}, err
+	}
+	ao := cadence.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		HeartbeatTimeout:       time.Second * 20,
+		WaitForCancellation:    true,
+	}
+	ctx = cadence.WithActivityOptions(ctx, ao)
+	logger := cadence.GetLogger(ctx)
+	// cancelling chunkCtx cancels the chunks in flight.
+	chunkCtx, cancelChunks := cadence.WithCancel(ctx)
+	defer cancelChunks()
+
+	outcomes := make([]ChunkOutcome, input.Chunks)
+	selector := cadence.NewSelector(ctx)
+	var failure error
+	inFlight, next := 0, 0
+	for {
+		// top up the chunks in flight, unless a chunk failed fast.
+		for ; failure == nil && inFlight < input.Parallelism && next < input.Chunks; next++ {
+			index := next
+			outcomes[index].ChunkID = index + 1
+			chunkInput := ChunkInput{ChunkID: index + 1, Fail: containsChunk(input.FailingChunks, index+1)}
+			f := cadence.ExecuteActivity(chunkCtx, processChunkActivity, chunkInput)
+			inFlight++
+			selector.AddFuture(f, func(f cadence.Future) {
+				inFlight--
+				err := f.Get(ctx, &outcomes[index].Result)
+				if err == nil {
+					return
+				}
+				outcomes[index].Error = err.Error()
+				if _, ok := err.(cadence.CanceledError); ok {
+					return
+				}
+				logger.Info("Chunk failed.", zap.Int("ChunkID", index+1), zap.Error(err))
+				if input.Policy == policyFailFast && failure == nil {
+					failure = fmt.Errorf("chunk %d failed: %v", index+1, err)
+					cancelChunks()
+				}
+			})
+		}
+		if inFlight == 0 {
+			break
+		}
+		selector.Select(ctx)
+	}
+	if failure != nil {
+		logger.Info("Workflow failed fast.", zap.Int("StartedChunks", next), zap.Error(failure))
+		return SplitMergeReport{}, failure
+	}
+
+	var report SplitMergeReport
+	err := cadence.ExecuteActivity(ctx, mergeChunksActivity, outcomes).Get(ctx, &report)
+	if err != nil {
+		return report, err
+	}
+	logger.Info("Workflow completed.", zap.Ints("FailedChunks", report.FailedChunks))
+	return report, nil
+}
+
+func containsChunk(chunks []int, chunkID int) bool {
+	for _, c := range chunks {
+		if c == chunkID {
+			return true
+		}
+	}
+	return false
+}
+
+func processChunkActivity(ctx context.Context, input ChunkInput) (ChunkResult, error) {
+	if input.Fail {
+		cadence.GetActivityLogger(ctx).Info("Chunk failed", zap.Int("chunkID", input.ChunkID))
+		return ChunkResult{}, cadence.NewErrorWithDetails(errReasonChunkFailed, input.ChunkID)
+	}
+	return chunkProcessingActivity(ctx, input.ChunkID)
+}
+
+func mergeChunksActivity(ctx context.Context, outcomes []ChunkOutcome) (SplitMergeReport, error) {
+	var report SplitMergeReport
+	for _, outcome := range outcomes {
+		if outcome.Error != "" {
+			report.FailedChunks = append(report.FailedChunks, outcome.ChunkID)
+			continue
+		}
+		report.NumberOfItemsInChunk += outcome.Result.NumberOfItemsInChunk
+		report.SumInChunk += outcome.Result.SumInChunk
+	}
+	cadence.GetActivityLogger(ctx).Info("Chunks merged", zap.Int("chunks", len(outcomes)),
+		zap.Ints("failedChunks", report.FailedChunks))
+	return report, nil
+}
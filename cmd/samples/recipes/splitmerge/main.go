@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/samarabbas/cadence-samples/cmd/samples/common"
@@ -21,19 +23,29 @@ func startWorkers(h *common.SampleHelper) {
 	h.StartWorkers(h.Config.DomainName, ApplicationName, workerOptions)
 }
 
-func startWorkflow(h *common.SampleHelper) {
+func startWorkflow(h *common.SampleHelper, bounded bool, input SplitMergeInput) {
 	workflowOptions := cadence.StartWorkflowOptions{
 		ID:                              "splitmerge_" + uuid.New(),
 		TaskList:                        ApplicationName,
 		ExecutionStartToCloseTimeout:    time.Minute,
 		DecisionTaskStartToCloseTimeout: time.Minute,
 	}
-	h.StartWorkflow(workflowOptions, SampleSplitMergeWorkflow, 5)
+	if bounded {
+		h.StartWorkflow(workflowOptions, BoundedSplitMergeWorkflow, input)
+		return
+	}
+	h.StartWorkflow(workflowOptions, SampleSplitMergeWorkflow, input.Chunks)
 }
 
 func main() {
-	var mode string
+	var mode, split, failingChunks string
+	var input SplitMergeInput
 	flag.StringVar(&mode, "m", "trigger", "Mode is worker or trigger.")
+	flag.StringVar(&split, "split", "unbounded", "Split is unbounded, a coroutine per chunk, or bounded.")
+	flag.IntVar(&input.Chunks, "chunks", 5, "Number of chunks.")
+	flag.IntVar(&input.Parallelism, "parallelism", 2, "Number of chunks in flight at most, when bounded.")
+	flag.StringVar(&input.Policy, "policy", policyFailFast, "Policy for a failing chunk, failFast or collectErrors.")
+	flag.StringVar(&failingChunks, "fail", "", "Comma separated chunks that fail when bounded, e.g. 2,4.")
 	flag.Parse()
 
 	var h common.SampleHelper
@@ -47,6 +59,15 @@ func main() {
 		// Use select{} to block indefinitely for samples, you can quit by CMD+C.
 		select {}
 	case "trigger":
-		startWorkflow(&h)
+		if failingChunks != "" {
+			for _, chunk := range strings.Split(failingChunks, ",") {
+				chunkID, err := strconv.Atoi(chunk)
+				if err != nil {
+					panic("Invalid failing chunk " + chunk)
+				}
+				input.FailingChunks = append(input.FailingChunks, chunkID)
+			}
+		}
+		startWorkflow(&h, split == "bounded", input)
 	}
 }
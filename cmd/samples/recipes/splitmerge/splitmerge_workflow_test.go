@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/cadence"
@@ -36,3 +38,110 @@ func (s *UnitTestSuite) Test_Workflow() {
 	s.Equal(totalItem, result.NumberOfItemsInChunk)
 	s.Equal(totalSum, result.SumInChunk)
 }
+
+// chunkDurations are how long the chunks take, so that with a parallelism of 2 they complete in the order 2, 1, 3, 5, 4.
+var chunkDurations = []time.Duration{time.Minute * 6, time.Minute * 2, time.Minute * 5, time.Minute * 3, time.Minute}
+
+type boundedRun struct {
+	env          *cadence.TestWorkflowEnvironment
+	started      []int
+	completed    []int
+	cancelled    int
+	maxInFlight  int
+	mergedChunks []int
+}
+
+// runBounded executes the workflow with chunks that take chunkDurations, and records what happened to them.
+func (s *UnitTestSuite) runBounded(input SplitMergeInput) *boundedRun {
+	run := &boundedRun{env: s.NewTestWorkflowEnvironment()}
+	env := run.env
+	inFlight := 0
+	env.OverrideActivity(processChunkActivity, func(ctx context.Context, input ChunkInput) (ChunkResult, error) {
+		taskToken := cadence.GetActivityInfo(ctx).TaskToken
+		run.started = append(run.started, input.ChunkID)
+		inFlight++
+		if inFlight > run.maxInFlight {
+			run.maxInFlight = inFlight
+		}
+		env.RegisterDelayedCallback(func() {
+			inFlight--
+			if input.Fail {
+				env.CompleteActivity(taskToken, nil, cadence.NewErrorWithDetails(errReasonChunkFailed))
+			} else {
+				result, _ := chunkProcessingActivity(ctx, input.ChunkID)
+				env.CompleteActivity(taskToken, result, nil)
+			}
+			run.completed = append(run.completed, input.ChunkID)
+		}, chunkDurations[input.ChunkID-1])
+		return ChunkResult{}, cadence.ErrActivityResultPending
+	})
+	env.OverrideActivity(mergeChunksActivity, func(ctx context.Context, outcomes []ChunkOutcome) (SplitMergeReport, error) {
+		for _, outcome := range outcomes {
+			run.mergedChunks = append(run.mergedChunks, outcome.ChunkID)
+		}
+		return mergeChunksActivity(ctx, outcomes)
+	})
+	env.SetOnActivityCanceledListener(func(info *cadence.ActivityInfo) {
+		inFlight--
+		run.cancelled++
+	})
+	env.ExecuteWorkflow(BoundedSplitMergeWorkflow, input)
+	return run
+}
+
+func (s *UnitTestSuite) Test_BoundedWorkflow() {
+	run := s.runBounded(SplitMergeInput{Chunks: 5, Parallelism: 2, Policy: policyFailFast})
+
+	s.True(run.env.IsWorkflowCompleted())
+	s.NoError(run.env.GetWorkflowError())
+	var report SplitMergeReport
+	s.NoError(run.env.GetWorkflowResult(&report))
+	s.Equal(SplitMergeReport{ChunkResult: ChunkResult{NumberOfItemsInChunk: 15, SumInChunk: 55}}, report)
+	s.Equal(2, run.maxInFlight)
+	s.Equal([]int{2, 1, 3, 5, 4}, run.completed)
+	// the merge receives the chunks in chunk order.
+	s.Equal([]int{1, 2, 3, 4, 5}, run.mergedChunks)
+}
+
+func (s *UnitTestSuite) Test_BoundedWorkflow_FailFast() {
+	run := s.runBounded(SplitMergeInput{Chunks: 5, Parallelism: 2, Policy: policyFailFast, FailingChunks: []int{3}})
+
+	s.True(run.env.IsWorkflowCompleted())
+	s.EqualError(run.env.GetWorkflowError(), "chunk 3 failed: chunkFailed")
+	// chunk 4 was in flight and cancelled, chunk 5 never started.
+	s.Equal([]int{1, 2, 3, 4}, run.started)
+	s.Equal(1, run.cancelled)
+	s.Nil(run.mergedChunks)
+}
+
+func (s *UnitTestSuite) Test_BoundedWorkflow_CollectErrors() {
+	run := s.runBounded(SplitMergeInput{Chunks: 5, Parallelism: 2, Policy: policyCollectErrors,
+		FailingChunks: []int{3}})
+
+	s.True(run.env.IsWorkflowCompleted())
+	s.NoError(run.env.GetWorkflowError())
+	var report SplitMergeReport
+	s.NoError(run.env.GetWorkflowResult(&report))
+	s.Equal(SplitMergeReport{ChunkResult: ChunkResult{NumberOfItemsInChunk: 12, SumInChunk: 46},
+		FailedChunks: []int{3}}, report)
+	s.Equal([]int{1, 2, 3, 4, 5}, run.started)
+	s.Equal(0, run.cancelled)
+	s.Equal([]int{1, 2, 3, 4, 5}, run.mergedChunks)
+}
+
+func (s *UnitTestSuite) Test_BoundedWorkflow_InvalidInput() {
+	for _, test := range []struct {
+		input    SplitMergeInput
+		expected string
+	}{
+		{SplitMergeInput{Parallelism: 1, Policy: policyFailFast}, "at least one chunk is needed"},
+		{SplitMergeInput{Chunks: 1, Policy: policyFailFast}, "parallelism must be at least 1"},
+		{SplitMergeInput{Chunks: 1, Parallelism: 1}, `unknown policy "", it is failFast or collectErrors`},
+	} {
+		env := s.NewTestWorkflowEnvironment()
+		env.ExecuteWorkflow(BoundedSplitMergeWorkflow, test.input)
+
+		s.True(env.IsWorkflowCompleted())
+		s.EqualError(env.GetWorkflowError(), test.expected)
+	}
+}